@@ -0,0 +1,39 @@
+// Package buildinfo reports the version a binary was built from.
+package buildinfo
+
+import "runtime/debug"
+
+// Version returns override when set (binaries take it from
+// -ldflags "-X main.version=v1.2.3"), else the module version, else
+// "devel-<revision>" from the VCS stamp, else "devel".
+func Version(override string) string {
+	if override != "" {
+		return override
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	rev, dirty := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if rev == "" {
+		return "devel"
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if dirty {
+		rev += "-dirty"
+	}
+	return "devel-" + rev
+}
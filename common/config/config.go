@@ -0,0 +1,104 @@
+// Package config loads tool settings from a TOML file with environment
+// variable overrides.
+//
+// Settings are a struct whose fields carry a toml tag for the file key and an
+// optional env tag naming the variable that overrides it:
+//
+//	type Settings struct {
+//		DataFile string        `toml:"data_file" env:"FEED_O_GRAM_FILE"`
+//		Interval time.Duration `toml:"interval"  env:"FEED_O_GRAM_INTERVAL"`
+//	}
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Load fills v (a pointer to a struct holding defaults) from the TOML file at
+// path, then applies environment overrides. A missing file is not an error.
+func Load(path string, v any) error {
+	if path != "" {
+		_, err := toml.DecodeFile(path, v)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	return applyEnv(v)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv sets every field with an env tag whose variable is set.
+func applyEnv(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: want pointer to struct, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			if err := applyEnv(rv.Field(i).Addr().Interface()); err != nil {
+				return err
+			}
+			continue
+		}
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(rv.Field(i), raw); err != nil {
+			return fmt.Errorf("config: $%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(f reflect.Value, raw string) error {
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type settings struct {
+	Name     string        `toml:"name" env:"CFGTEST_NAME"`
+	Workers  int           `toml:"workers" env:"CFGTEST_WORKERS"`
+	Verbose  bool          `toml:"verbose" env:"CFGTEST_VERBOSE"`
+	Interval time.Duration `toml:"interval" env:"CFGTEST_INTERVAL"`
+	Sub      struct {
+		Path string `toml:"path" env:"CFGTEST_SUB_PATH"`
+	} `toml:"sub"`
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("name = \"file\"\nworkers = 4\ninterval = \"5s\"\n[sub]\npath = \"/from/file\"\n"), 0o644)
+	t.Setenv("CFGTEST_WORKERS", "8")
+	t.Setenv("CFGTEST_SUB_PATH", "/from/env")
+
+	s := settings{Name: "default", Verbose: true}
+	if err := Load(path, &s); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if s.Name != "file" || s.Workers != 8 || !s.Verbose || s.Interval != 5*time.Second || s.Sub.Path != "/from/env" {
+		t.Fatalf("settings = %+v", s)
+	}
+}
+
+func TestLoadMissingFileKeepsDefaults(t *testing.T) {
+	s := settings{Name: "default"}
+	if err := Load(filepath.Join(t.TempDir(), "nope.toml"), &s); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if s.Name != "default" {
+		t.Fatalf("Name = %q", s.Name)
+	}
+}
+
+func TestLoadBadEnv(t *testing.T) {
+	t.Setenv("CFGTEST_WORKERS", "many")
+	if err := Load("", &settings{}); err == nil {
+		t.Fatal("expected error for non-numeric override")
+	}
+}
@@ -0,0 +1,93 @@
+// Package notify shows desktop notifications through the platform's own
+// tooling: osascript on macOS, notify-send on Linux and the BSDs, and a
+// PowerShell toast on Windows. Where none is available notifications are
+// silently dropped.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Notifier shows a notification with a title and a one-line body.
+type Notifier interface {
+	Notify(ctx context.Context, title, body string) error
+}
+
+// Noop discards notifications.
+type Noop struct{}
+
+func (Noop) Notify(context.Context, string, string) error { return nil }
+
+// Test seams.
+var (
+	goos     = runtime.GOOS
+	lookPath = exec.LookPath
+	run      = func(ctx context.Context, name string, args ...string) error {
+		out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+		if err != nil && len(out) > 0 {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return err
+	}
+)
+
+// New returns a notifier for this platform that attributes notifications to
+// app, or Noop when the platform has no supported backend installed.
+func New(app string) Notifier {
+	name, _ := commandFor(goos, app, "", "")
+	if name == "" {
+		return Noop{}
+	}
+	if _, err := lookPath(name); err != nil {
+		return Noop{}
+	}
+	return execNotifier{app: app}
+}
+
+type execNotifier struct{ app string }
+
+func (n execNotifier) Notify(ctx context.Context, title, body string) error {
+	name, args := commandFor(goos, n.app, title, body)
+	if err := run(ctx, name, args...); err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	return nil
+}
+
+// commandFor returns the command line that shows a notification on goos, or
+// an empty name when the platform is unsupported.
+func commandFor(goos, app, title, body string) (string, []string) {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return "osascript", []string{"-e", script}
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return "notify-send", []string{"--app-name=" + app, title, body}
+	case "windows":
+		return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", windowsToast(app, title, body)}
+	}
+	return "", nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// windowsToast is a PowerShell script showing a two-line toast through the
+// WinRT notification API, which needs no extra modules.
+func windowsToast(app, title, body string) string {
+	q := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+	return strings.Join([]string{
+		"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null",
+		"$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)",
+		"$x = $t.GetElementsByTagName('text')",
+		"$x.Item(0).AppendChild($t.CreateTextNode(" + q(title) + ")) > $null",
+		"$x.Item(1).AppendChild($t.CreateTextNode(" + q(body) + ")) > $null",
+		"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier(" + q(app) + ").Show([Windows.UI.Notifications.ToastNotification]::new($t))",
+	}, "; ")
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func stub(t *testing.T, os string, installed bool) *[]string {
+	t.Helper()
+	oldGOOS, oldLook, oldRun := goos, lookPath, run
+	t.Cleanup(func() { goos, lookPath, run = oldGOOS, oldLook, oldRun })
+	goos = os
+	lookPath = func(name string) (string, error) {
+		if !installed {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + name, nil
+	}
+	var got []string
+	run = func(_ context.Context, name string, args ...string) error {
+		got = append([]string{name}, args...)
+		return nil
+	}
+	return &got
+}
+
+func TestNotifyLinux(t *testing.T) {
+	got := stub(t, "linux", true)
+	if err := New("things").Notify(context.Background(), "New mail", "From Alice"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"notify-send", "--app-name=things", "New mail", "From Alice"}
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("ran %q, want %q", *got, want)
+	}
+}
+
+func TestNotifyDarwinEscapes(t *testing.T) {
+	got := stub(t, "darwin", true)
+	New("things").Notify(context.Background(), `Say "hi"`, `back\slash`)
+	want := []string{"osascript", "-e", `display notification "back\\slash" with title "Say \"hi\""`}
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("ran %q, want %q", *got, want)
+	}
+}
+
+func TestNotifyWindowsQuotes(t *testing.T) {
+	got := stub(t, "windows", true)
+	New("things").Notify(context.Background(), "It's time", "eat")
+	if len(*got) != 5 || (*got)[0] != "powershell" || !strings.Contains((*got)[4], "CreateTextNode('It''s time')") {
+		t.Fatalf("ran %q", *got)
+	}
+}
+
+func TestFallbackToNoop(t *testing.T) {
+	stub(t, "linux", false)
+	if _, ok := New("things").(Noop); !ok {
+		t.Fatal("missing notify-send should fall back to Noop")
+	}
+	stub(t, "plan9", true)
+	if _, ok := New("things").(Noop); !ok {
+		t.Fatal("unsupported platform should fall back to Noop")
+	}
+}
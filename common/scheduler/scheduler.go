@@ -0,0 +1,192 @@
+// Package scheduler runs recurring jobs for the tools' background modes.
+// Each job has an interval or a cron-like schedule and optional jitter, and
+// its last run is kept in a small JSON file so a restarted daemon picks up
+// where it left off instead of running everything again at once.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"time"
+
+	"common/atomicfile"
+)
+
+// Job is one recurring task.
+type Job struct {
+	Name string
+	// Every is the time between the starts of two runs.
+	Every time.Duration
+	// Schedule, if set, decides when the job runs instead of Every. A job
+	// that never ran is still due at once.
+	Schedule Schedule
+	// Jitter delays each run by a random amount up to this long, so that
+	// machines sharing a schedule do not all call an API at the same moment.
+	Jitter time.Duration
+	// Quiet leaves successful runs out of the log, for frequent checks
+	// that usually find nothing to do.
+	Quiet bool
+	Run   func(ctx context.Context) error
+}
+
+// Scheduler runs a set of jobs until its context is cancelled.
+type Scheduler struct {
+	Jobs []Job
+	// StateFile records when each job last ran; "" keeps that in memory
+	// only, so every job runs as soon as the scheduler starts.
+	StateFile string
+	// Logf, if set, receives one line per run and per failure.
+	Logf func(format string, args ...any)
+
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+// Run starts every job that is due, then sleeps until the next one is.
+// Jobs run one at a time; a failing job is logged and retried at its next
+// slot. Run returns nil once ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if len(s.Jobs) == 0 {
+		return errors.New("scheduler: no jobs")
+	}
+	for _, j := range s.Jobs {
+		if j.Every <= 0 && j.Schedule == nil {
+			return fmt.Errorf("scheduler: job %s has no interval", j.Name)
+		}
+	}
+	now, after := s.now, s.after
+	if now == nil {
+		now = time.Now
+	}
+	if after == nil {
+		after = time.After
+	}
+
+	last, err := loadState(s.StateFile)
+	if err != nil {
+		return err
+	}
+	next := make(map[string]time.Time, len(s.Jobs))
+	for _, j := range s.Jobs {
+		next[j.Name] = nextRun(j, last[j.Name])
+	}
+
+	for {
+		t := now()
+		wake := time.Time{}
+		for _, j := range s.Jobs {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if next[j.Name].After(t) {
+				if wake.IsZero() || next[j.Name].Before(wake) {
+					wake = next[j.Name]
+				}
+				continue
+			}
+			started := now()
+			err := j.Run(ctx)
+			last[j.Name] = started
+			next[j.Name] = nextRun(j, started)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				s.logf("%s failed after %s: %v", j.Name, now().Sub(started).Round(time.Millisecond), err)
+			case err == nil && !j.Quiet:
+				s.logf("%s done in %s", j.Name, now().Sub(started).Round(time.Millisecond))
+			}
+			if err := saveState(s.StateFile, last); err != nil {
+				s.logf("save schedule state: %v", err)
+			}
+			if wake.IsZero() || next[j.Name].Before(wake) {
+				wake = next[j.Name]
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-after(wake.Sub(now())):
+		}
+	}
+}
+
+func (s *Scheduler) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+// nextRun is when j should run after a run at last; a job that never ran is
+// due immediately.
+func nextRun(j Job, last time.Time) time.Time {
+	if last.IsZero() {
+		return time.Time{}
+	}
+	var t time.Time
+	if j.Schedule != nil {
+		if t = j.Schedule.Next(last); t.IsZero() {
+			return time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC) // never again
+		}
+	} else {
+		t = last.Add(j.Every)
+	}
+	if j.Jitter > 0 {
+		t = t.Add(rand.N(j.Jitter))
+	}
+	return t
+}
+
+func loadState(path string) (map[string]time.Time, error) {
+	last := map[string]time.Time{}
+	if path == "" {
+		return last, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return last, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, fmt.Errorf("read schedule state %s: %w", path, err)
+	}
+	return last, nil
+}
+
+func saveState(path string, last map[string]time.Time) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(last, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ParseEvery reads an interval in cron's shorthand (@hourly, @daily,
+// @weekly, "@every 15m") or as a plain Go duration ("15m").
+func ParseEvery(spec string) (time.Duration, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		return time.Hour, nil
+	case "@daily", "@midnight":
+		return 24 * time.Hour, nil
+	case "@weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every")))
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q: want a duration like 15m or @hourly, @daily, @weekly", spec)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid interval %q: must be positive", spec)
+	}
+	return d, nil
+}
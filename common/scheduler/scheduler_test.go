@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock advances time by exactly the requested sleep.
+type fakeClock struct {
+	t     time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	c.slept = append(c.slept, d)
+	c.t = c.t.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.t
+	return ch
+}
+
+func TestRunSchedulesAndPersists(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state", "schedule.json")
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs []string
+	s := &Scheduler{
+		StateFile: state,
+		now:       clock.now,
+		after:     clock.after,
+		Jobs: []Job{
+			{Name: "sync", Every: 10 * time.Minute, Run: func(context.Context) error {
+				runs = append(runs, "sync@"+clock.t.Format("15:04"))
+				return nil
+			}},
+			{Name: "report", Every: time.Hour, Run: func(context.Context) error {
+				runs = append(runs, "report@"+clock.t.Format("15:04"))
+				if len(runs) >= 8 {
+					cancel()
+				}
+				return errors.New("failing jobs are retried on schedule")
+			}},
+		},
+	}
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := "sync@12:00 report@12:00 sync@12:10 sync@12:20 sync@12:30 sync@12:40 sync@12:50 sync@13:00 report@13:00"
+	if got := strings.Join(runs, " "); got != want {
+		t.Fatalf("runs = %s\nwant   %s", got, want)
+	}
+
+	// A restart shortly after resumes the schedule instead of running both
+	// jobs again straight away.
+	clock.t = time.Date(2026, 1, 1, 13, 2, 0, 0, time.UTC)
+	clock.slept = nil
+	runs = nil
+	ctx, cancel = context.WithCancel(context.Background())
+	s.Jobs[0].Run = func(context.Context) error {
+		runs = append(runs, "sync@"+clock.t.Format("15:04"))
+		cancel()
+		return nil
+	}
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(runs) != 1 || runs[0] != "sync@13:10" || len(clock.slept) != 1 || clock.slept[0] != 8*time.Minute {
+		t.Fatalf("after restart runs = %v, slept %v", runs, clock.slept)
+	}
+}
+
+func TestNextRunJitter(t *testing.T) {
+	last := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	j := Job{Every: time.Hour, Jitter: 5 * time.Minute}
+	for range 100 {
+		next := nextRun(j, last)
+		if d := next.Sub(last); d < time.Hour || d >= time.Hour+5*time.Minute {
+			t.Fatalf("nextRun = +%s, want within [1h, 1h5m)", d)
+		}
+	}
+	if !nextRun(j, time.Time{}).IsZero() {
+		t.Fatal("a job that never ran should be due at once")
+	}
+}
+
+func TestParseEvery(t *testing.T) {
+	for spec, want := range map[string]time.Duration{
+		"@hourly":    time.Hour,
+		"@daily":     24 * time.Hour,
+		"@weekly":    7 * 24 * time.Hour,
+		"@every 15m": 15 * time.Minute,
+		" 90s ":      90 * time.Second,
+	} {
+		got, err := ParseEvery(spec)
+		if err != nil || got != want {
+			t.Errorf("ParseEvery(%q) = %v, %v; want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "@often", "-5m", "0s"} {
+		if _, err := ParseEvery(spec); err == nil {
+			t.Errorf("ParseEvery(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	u := Unit{
+		Name:        "chuckterm-sync",
+		Description: "chuckterm periodic sync",
+		Command:     []string{"/opt/my tools/chuckterm", "daemon", "--every", "15m"},
+		Dir:         "/home/me",
+	}
+	sd := u.Systemd()
+	for _, want := range []string{
+		`ExecStart="/opt/my tools/chuckterm" daemon --every 15m`,
+		"WorkingDirectory=/home/me",
+		"WantedBy=default.target",
+	} {
+		if !strings.Contains(sd, want) {
+			t.Errorf("systemd unit missing %q:\n%s", want, sd)
+		}
+	}
+	if got := systemdQuote("100%"); got != "100%%" {
+		t.Errorf("systemdQuote(100%%) = %s", got)
+	}
+
+	u.Command[1] = "a<b"
+	ld := u.Launchd()
+	for _, want := range []string{
+		"<string>fyi.niraj.chuckterm-sync</string>",
+		"<string>/opt/my tools/chuckterm</string>",
+		"<string>a&lt;b</string>",
+		"<key>RunAtLoad</key>",
+	} {
+		if !strings.Contains(ld, want) {
+			t.Errorf("launchd plist missing %q:\n%s", want, ld)
+		}
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, enable, err := install("linux", u)
+	if err != nil || !strings.HasSuffix(path, filepath.Join("systemd", "user", "chuckterm-sync.service")) || !strings.Contains(enable, "enable --now chuckterm-sync.service") {
+		t.Fatalf("install = %q, %q, %v", path, enable, err)
+	}
+	if _, _, err := install("windows", u); err == nil {
+		t.Fatal("install on windows should fail")
+	}
+}
+
+func TestQuietJobLogsOnlyFailures(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	ctx, cancel := context.WithCancel(context.Background())
+	var lines []string
+	runs := 0
+	s := &Scheduler{
+		now:   clock.now,
+		after: clock.after,
+		Logf:  func(format string, args ...any) { lines = append(lines, format) },
+		Jobs: []Job{{Name: "check", Every: time.Minute, Quiet: true, Run: func(context.Context) error {
+			runs++
+			switch runs {
+			case 2:
+				return errors.New("boom")
+			case 3:
+				cancel()
+			}
+			return nil
+		}}},
+	}
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(lines) != 1 || !strings.Contains(lines[0], "failed") {
+		t.Fatalf("logged %q", lines)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Thursday.
+	from := time.Date(2026, 1, 1, 12, 7, 30, 0, time.UTC)
+	for spec, want := range map[string]string{
+		"*/20 * * * *":          "2026-01-01 12:20",
+		"0 7 * * *":             "2026-01-02 07:00",
+		"*/15 7-19 * * mon-fri": "2026-01-01 12:15",
+		"30 9 * * 6,7":          "2026-01-03 09:30",
+		"0 0 1 */3 *":           "2026-04-01 00:00",
+		"0 0 13 * fri":          "2026-01-02 00:00", // either day matches
+		"0 12 29 feb *":         "2028-02-29 12:00",
+	} {
+		c, err := ParseCron(spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", spec, err)
+			continue
+		}
+		if got := c.Next(from).Format("2006-01-02 15:04"); got != want {
+			t.Errorf("%q: Next = %s; want %s", spec, got, want)
+		}
+	}
+	for _, spec := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "0 0 30 feb *", "0 0 * * funday"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	last := time.Date(2026, 1, 1, 12, 7, 0, 0, time.UTC)
+	for spec, want := range map[string]string{
+		"15m":         "2026-01-01 12:22",
+		"@hourly":     "2026-01-01 13:07",
+		"0 18 * * *":  "2026-01-01 18:00",
+		"@every 1h5m": "2026-01-01 13:12",
+	} {
+		s, err := ParseSchedule(spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", spec, err)
+			continue
+		}
+		if got := s.Next(last).Format("2006-01-02 15:04"); got != want {
+			t.Errorf("%q: Next = %s; want %s", spec, got, want)
+		}
+	}
+	if _, err := ParseSchedule("sometimes"); err == nil {
+		t.Error("ParseSchedule accepted a bad schedule")
+	}
+}
+
+func TestRunCronJob(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 7, 0, 0, time.UTC)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cron, err := ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []string
+	s := &Scheduler{
+		now:   clock.now,
+		after: clock.after,
+		Jobs: []Job{{Name: "sync", Schedule: cron, Run: func(context.Context) error {
+			runs = append(runs, clock.t.Format("15:04"))
+			if len(runs) == 3 {
+				cancel()
+			}
+			return nil
+		}}},
+	}
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := strings.Join(runs, " "); got != "12:07 13:00 14:00" {
+		t.Errorf("runs = %s; want 12:07 13:00 14:00", got)
+	}
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"common/atomicfile"
+	"common/xdg"
+)
+
+// Unit describes a daemon for the platform's service manager: a systemd
+// user service on Linux, a launchd agent on macOS.
+type Unit struct {
+	Name        string // file name without extension, e.g. "chuckterm-sync"
+	Description string
+	Command     []string // absolute executable path followed by its arguments
+	Dir         string   // working directory; "" leaves the manager's default
+}
+
+// launchdLabel is the reverse-DNS prefix of launchd agent labels.
+const launchdLabel = "fyi.niraj."
+
+// Systemd renders u as a systemd user service that restarts on failure.
+func (u Unit) Systemd() string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", u.Description)
+	b.WriteString("After=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	args := make([]string, len(u.Command))
+	for i, a := range u.Command {
+		args[i] = systemdQuote(a)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	if u.Dir != "" {
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdQuote(u.Dir))
+	}
+	b.WriteString("Restart=on-failure\nRestartSec=30\n\n")
+	b.WriteString("[Install]\nWantedBy=default.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes an ExecStart word when needed and escapes the
+// specifier character %.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;$") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$").Replace(s) + `"`
+}
+
+// Launchd renders u as a launchd agent plist that starts at login and is
+// kept alive.
+func (u Unit) Launchd() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	plistKey(&b, "Label", launchdLabel+u.Name)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, a := range u.Command {
+		b.WriteString("\t\t<string>" + xmlEscape(a) + "</string>\n")
+	}
+	b.WriteString("\t</array>\n")
+	if u.Dir != "" {
+		plistKey(&b, "WorkingDirectory", u.Dir)
+	}
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func plistKey(b *strings.Builder, key, value string) {
+	fmt.Fprintf(b, "\t<key>%s</key>\n\t<string>%s</string>\n", key, xmlEscape(value))
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Install writes u where this platform's service manager looks for user
+// services and returns the path plus the command that enables it.
+func Install(u Unit) (path, enable string, err error) {
+	return install(runtime.GOOS, u)
+}
+
+func install(goos string, u Unit) (path, enable string, err error) {
+	var content string
+	switch goos {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		dir, err := xdg.ConfigHome()
+		if err != nil {
+			return "", "", err
+		}
+		path = filepath.Join(dir, "systemd", "user", u.Name+".service")
+		content = u.Systemd()
+		enable = fmt.Sprintf("systemctl --user daemon-reload && systemctl --user enable --now %s.service", u.Name)
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		path = filepath.Join(home, "Library", "LaunchAgents", launchdLabel+u.Name+".plist")
+		content = u.Launchd()
+		enable = "launchctl load -w " + path
+	default:
+		return "", "", fmt.Errorf("no service manager support on %s; run the command from Task Scheduler instead", goos)
+	}
+	if err := atomicfile.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", "", err
+	}
+	return path, enable, nil
+}
+
+// CurrentCommand returns the command line of this process with an absolute
+// executable path and the --install flag dropped, for use as Unit.Command.
+func CurrentCommand() ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return nil, err
+	}
+	cmd := []string{exe}
+	for _, a := range os.Args[1:] {
+		switch a {
+		case "--install", "-install", "--install=true", "-install=true":
+			continue
+		}
+		cmd = append(cmd, a)
+	}
+	return cmd, nil
+}
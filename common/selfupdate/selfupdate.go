@@ -0,0 +1,332 @@
+// Package selfupdate replaces a running binary with the newest build
+// published on GitHub releases.
+//
+// A release carries one asset per tool and platform, named
+// <binary>_<goos>_<goarch> (with ".exe" on Windows), plus checksums.txt in
+// sha256sum format, as .github/workflows/release.yml publishes them. When
+// the binary was built with a release public key, checksums.txt must also
+// come with checksums.txt.sig, a base64 ed25519 signature of the file, or
+// the update is refused.
+//
+// Without a key only integrity is checked: checksums.txt comes from the
+// same release as the binary, so it catches a corrupted download but not a
+// release published by someone else.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRepo is where this repository's tools are released.
+const DefaultRepo = "niraj8/things"
+
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = checksumsAsset + ".sig"
+	maxAssetSize   = 256 << 20
+)
+
+// apiBase is the GitHub API root, replaced in tests.
+var apiBase = "https://api.github.com"
+
+// Updater checks for and installs releases of one binary.
+type Updater struct {
+	Repo    string // "owner/name"; DefaultRepo when empty
+	Binary  string // asset base name, e.g. "chuckterm"
+	Current string // version of the running binary
+	// PublicKey is a base64 ed25519 key. When set, checksums must be signed;
+	// when empty, releases are not authenticated at all.
+	PublicKey string
+	Client    *http.Client
+}
+
+// Release is a published GitHub release.
+type Release struct {
+	Tag    string
+	Assets map[string]string // asset name -> download URL
+}
+
+// AssetName is the release asset holding the binary for this platform.
+func (u Updater) AssetName() string {
+	name := fmt.Sprintf("%s_%s_%s", u.Binary, runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Latest fetches the newest release.
+func (u Updater) Latest(ctx context.Context) (Release, error) {
+	repo := u.Repo
+	if repo == "" {
+		repo = DefaultRepo
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiBase+"/repos/"+repo+"/releases/latest", nil)
+	if err != nil {
+		return Release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return Release{}, fmt.Errorf("check releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Release{}, fmt.Errorf("check releases: %s", resp.Status)
+	}
+	var body struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Release{}, fmt.Errorf("decode release: %w", err)
+	}
+	rel := Release{Tag: body.TagName, Assets: make(map[string]string, len(body.Assets))}
+	for _, a := range body.Assets {
+		rel.Assets[a.Name] = a.URL
+	}
+	return rel, nil
+}
+
+// Apply downloads this platform's binary from rel, verifies it against the
+// release checksums (and their signature when a key is configured), and
+// replaces exe with it.
+func (u Updater) Apply(ctx context.Context, rel Release, exe string) error {
+	name := u.AssetName()
+	url, ok := rel.Assets[name]
+	if !ok {
+		return fmt.Errorf("release %s has no %s", rel.Tag, name)
+	}
+	sumsURL, ok := rel.Assets[checksumsAsset]
+	if !ok {
+		return fmt.Errorf("release %s has no %s", rel.Tag, checksumsAsset)
+	}
+	sums, err := u.download(ctx, sumsURL)
+	if err != nil {
+		return err
+	}
+	if u.PublicKey != "" {
+		sigURL, ok := rel.Assets[signatureAsset]
+		if !ok {
+			return fmt.Errorf("release %s is not signed", rel.Tag)
+		}
+		sig, err := u.download(ctx, sigURL)
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(u.PublicKey, sums, sig); err != nil {
+			return err
+		}
+	}
+	want, err := checksumFor(sums, name)
+	if err != nil {
+		return err
+	}
+	bin, err := u.download(ctx, url)
+	if err != nil {
+		return err
+	}
+	if got := sha256.Sum256(bin); hex.EncodeToString(got[:]) != want {
+		return fmt.Errorf("checksum mismatch for %s", name)
+	}
+	return replaceExecutable(exe, bin)
+}
+
+// Run implements the "self-update" subcommand and returns the exit code.
+func (u Updater) Run(args []string) int {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	check := fs.Bool("check", false, "only report whether a newer release exists")
+	force := fs.Bool("force", false, "install the latest release even if it is not newer")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	rel, err := u.Latest(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !*force && !Newer(rel.Tag, u.Current) {
+		fmt.Printf("%s %s is up to date (latest release %s)\n", u.Binary, u.Current, rel.Tag)
+		return 0
+	}
+	if *check {
+		fmt.Printf("%s %s is available (running %s)\n", u.Binary, rel.Tag, u.Current)
+		return 0
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot locate the running binary: %v\n", err)
+		return 1
+	}
+	if u.PublicKey == "" {
+		fmt.Fprintln(os.Stderr, "This build has no release key: the download is checked against the release's checksums only, not a signature.")
+	}
+	if err := u.Apply(ctx, rel, exe); err != nil {
+		fmt.Fprintf(os.Stderr, "Update failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Updated %s to %s\n", u.Binary, rel.Tag)
+	return 0
+}
+
+func (u Updater) client() *http.Client {
+	if u.Client != nil {
+		return u.Client
+	}
+	return &http.Client{Timeout: 2 * time.Minute}
+}
+
+func (u Updater) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxAssetSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	if len(b) > maxAssetSize {
+		return nil, fmt.Errorf("download %s: larger than %d bytes", url, maxAssetSize)
+	}
+	return b, nil
+}
+
+// checksumFor finds name in a sha256sum-format file.
+func checksumFor(sums []byte, name string) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+func verifySignature(publicKey string, msg, sig []byte) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid release public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), msg, raw) {
+		return errors.New("checksums signature does not verify")
+	}
+	return nil
+}
+
+// replaceExecutable writes bin next to exe and renames it into place. The
+// old binary is moved aside first because Windows cannot overwrite a running
+// executable; it is removed afterwards where the platform allows.
+func replaceExecutable(exe string, bin []byte) error {
+	dir := filepath.Dir(exe)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(exe)+".*.new")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(bin); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		os.Rename(old, exe)
+		os.Remove(tmp)
+		return err
+	}
+	os.Remove(old)
+	return nil
+}
+
+// Newer reports whether release tag latest is a later version than current.
+// Development builds ("devel-…") and other non-release versions always
+// count as older.
+func Newer(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion reads "v1.2.3" (the "v" and trailing components optional),
+// ignoring any pre-release or build suffix.
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
@@ -0,0 +1,121 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRelease serves a latest release with the given assets.
+func fakeRelease(t *testing.T, tag string, assets map[string][]byte) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	var list []string
+	for name, body := range assets {
+		body := body
+		mux.HandleFunc("/dl/"+name, func(w http.ResponseWriter, r *http.Request) { w.Write(body) })
+		list = append(list, fmt.Sprintf(`{"name":%q,"browser_download_url":%q}`, name, srv.URL+"/dl/"+name))
+	}
+	mux.HandleFunc("/repos/niraj8/things/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name":%q,"assets":[%s]}`, tag, strings.Join(list, ","))
+	})
+	old := apiBase
+	apiBase = srv.URL
+	t.Cleanup(func() { apiBase = old })
+}
+
+func TestApply(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	u := Updater{Binary: "tool", Current: "v1.0.0", PublicKey: base64.StdEncoding.EncodeToString(pub)}
+	bin := []byte("new binary")
+	sum := sha256.Sum256(bin)
+	sums := []byte(hex.EncodeToString(sum[:]) + "  " + u.AssetName() + "\n")
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sums)))
+
+	fakeRelease(t, "v1.1.0", map[string][]byte{u.AssetName(): bin, checksumsAsset: sums, signatureAsset: sig})
+	rel, err := u.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Newer(rel.Tag, u.Current) {
+		t.Fatalf("%s should be newer than %s", rel.Tag, u.Current)
+	}
+
+	exe := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(exe, []byte("old binary"), 0o755)
+	if err := u.Apply(context.Background(), rel, exe); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if b, _ := os.ReadFile(exe); string(b) != "new binary" {
+		t.Fatalf("exe = %q", b)
+	}
+	if fi, _ := os.Stat(exe); fi.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("exe not executable: %v", fi.Mode())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+		t.Fatalf("leftover files: %v", entries)
+	}
+}
+
+func TestApplyRejectsTampering(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	u := Updater{Binary: "tool", PublicKey: base64.StdEncoding.EncodeToString(pub)}
+	sum := sha256.Sum256([]byte("expected"))
+	sums := []byte(hex.EncodeToString(sum[:]) + "  " + u.AssetName() + "\n")
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sums)))
+
+	cases := map[string]map[string][]byte{
+		"checksum mismatch": {u.AssetName(): []byte("tampered"), checksumsAsset: sums, signatureAsset: sig},
+		"bad signature":     {u.AssetName(): []byte("expected"), checksumsAsset: append(sums, '\n'), signatureAsset: sig},
+		"unsigned":          {u.AssetName(): []byte("expected"), checksumsAsset: sums},
+		"no asset":          {checksumsAsset: sums, signatureAsset: sig},
+	}
+	for name, assets := range cases {
+		t.Run(name, func(t *testing.T) {
+			fakeRelease(t, "v2.0.0", assets)
+			rel, err := u.Latest(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			exe := filepath.Join(t.TempDir(), "tool")
+			os.WriteFile(exe, []byte("old binary"), 0o755)
+			if err := u.Apply(context.Background(), rel, exe); err == nil {
+				t.Fatal("Apply succeeded")
+			}
+			if b, _ := os.ReadFile(exe); string(b) != "old binary" {
+				t.Fatalf("exe replaced: %q", b)
+			}
+		})
+	}
+}
+
+func TestNewer(t *testing.T) {
+	cases := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.3", false},
+		{"v2.0.0", "devel-abc123", true},
+		{"nightly", "v1.0.0", false},
+		{"v1.2.1", "1.2.1-rc1", false},
+	}
+	for _, c := range cases {
+		if got := Newer(c.latest, c.current); got != c.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", c.latest, c.current, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Confirm is a yes/no modal. While it is active the caller sends it every key
+// press; "y" confirms and any other key cancels, ending with a ConfirmMsg.
+type Confirm struct {
+	id     string
+	prompt string
+	active bool
+}
+
+// ConfirmMsg reports the answer to the question asked with the given ID.
+type ConfirmMsg struct {
+	ID  string
+	Yes bool
+}
+
+// Ask opens the modal with prompt. id comes back in the ConfirmMsg so one
+// Confirm can serve several questions.
+func (c *Confirm) Ask(id, prompt string) {
+	c.id, c.prompt, c.active = id, prompt, true
+}
+
+// Active reports whether the modal is waiting for an answer.
+func (c *Confirm) Active() bool { return c.active }
+
+// HandleKey answers the open question with msg and closes the modal.
+func (c *Confirm) HandleKey(msg tea.KeyMsg) tea.Cmd {
+	if !c.active {
+		return nil
+	}
+	c.active = false
+	answer := ConfirmMsg{ID: c.id, Yes: msg.String() == "y" || msg.String() == "Y"}
+	return func() tea.Msg { return answer }
+}
+
+// View renders the modal box, or "" when inactive. Draw it with Overlay.
+func (c *Confirm) View(width int) string {
+	if !c.active {
+		return ""
+	}
+	body := lipgloss.NewStyle().Width(min(width-8, 60)).Render(c.prompt)
+	return ModalStyle.Render(body + "\n\n" + MutedStyle.Render("y: yes  any other key: no"))
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// HelpSection is a titled group of bindings in the help overlay.
+type HelpSection struct {
+	Title string
+	Keys  []Key
+}
+
+var (
+	helpTitleStyle = lipgloss.NewStyle().Bold(true).Foreground(Accent)
+	helpKeyStyle   = lipgloss.NewStyle().Foreground(Warn)
+)
+
+// HelpView renders sections as a boxed two-column key reference. Draw it with
+// Overlay.
+func HelpView(sections []HelpSection) string {
+	width := 0
+	for _, s := range sections {
+		for _, k := range s.Keys {
+			width = max(width, lipgloss.Width(k.Keys))
+		}
+	}
+	var b strings.Builder
+	for i, s := range sections {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(helpTitleStyle.Render(s.Title))
+		for _, k := range s.Keys {
+			b.WriteString("\n")
+			b.WriteString(helpKeyStyle.Render(k.Keys + strings.Repeat(" ", width-lipgloss.Width(k.Keys))))
+			b.WriteString("  ")
+			b.WriteString(k.Help)
+		}
+	}
+	b.WriteString("\n\n")
+	b.WriteString(MutedStyle.Render("press any key to close"))
+	return ModalStyle.Render(b.String())
+}
@@ -0,0 +1,19 @@
+package ui
+
+import "strings"
+
+// Key documents one binding, e.g. {"enter", "open"}. The same slices feed
+// footers and the help overlay so the two never disagree.
+type Key struct {
+	Keys string
+	Help string
+}
+
+// Hints renders keys on one line as "enter: open  q: quit".
+func Hints(keys []Key) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.Keys + ": " + k.Help
+	}
+	return strings.Join(parts, "  ")
+}
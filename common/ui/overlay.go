@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Overlay draws box centred over base, a screen of the given size, keeping
+// the base visible around it. Both may contain ANSI styling.
+func Overlay(base, box string, width, height int) string {
+	if box == "" {
+		return base
+	}
+	lines := strings.Split(base, "\n")
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	boxLines := strings.Split(box, "\n")
+	bw := lipgloss.Width(box)
+	x := max((width-bw)/2, 0)
+	y := max((len(lines)-len(boxLines))/2, 0)
+	for i, bl := range boxLines {
+		row := y + i
+		if row >= len(lines) {
+			lines = append(lines, "")
+		}
+		line := lines[row]
+		left := ansi.Truncate(line, x, "")
+		if w := ansi.StringWidth(left); w < x {
+			left += strings.Repeat(" ", x-w)
+		}
+		right := ansi.TruncateLeft(line, x+bw, "")
+		if ansi.StringWidth(line) <= x+bw {
+			right = ""
+		}
+		lines[row] = left + "\x1b[0m" + bl + strings.Repeat(" ", bw-ansi.StringWidth(bl)) + "\x1b[0m" + right
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,28 @@
+package ui
+
+import "strings"
+
+// sparks are the block characters of a sparkline, lowest first.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as one block character each, scaled so the
+// largest is a full block. Zero draws the lowest block and any other value
+// at least the next one, so a quiet stretch stays visible next to a busy
+// one.
+func Sparkline(values []int) string {
+	top := 0
+	for _, v := range values {
+		if v > top {
+			top = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if v > 0 {
+			level = 1 + v*(len(sparks)-2)/top
+		}
+		b.WriteRune(sparks[level])
+	}
+	return b.String()
+}
@@ -0,0 +1,30 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StatusBar is a single line with a message on the left and context (counts,
+// modes) on the right.
+type StatusBar struct {
+	Text  string
+	Right string
+}
+
+// View renders the bar at the given width, truncating the message before the
+// right-hand side.
+func (s StatusBar) View(width int) string {
+	right := MutedStyle.Render(s.Right)
+	rw := lipgloss.Width(right)
+	if width <= 0 {
+		return strings.TrimSpace(s.Text + "  " + right)
+	}
+	if rw >= width {
+		return lipgloss.NewStyle().MaxWidth(width).Render(s.Text)
+	}
+	left := lipgloss.NewStyle().MaxWidth(width - rw - 1).Render(s.Text)
+	gap := width - lipgloss.Width(left) - rw
+	return left + strings.Repeat(" ", gap) + right
+}
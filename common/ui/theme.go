@@ -0,0 +1,51 @@
+// Package ui holds the bubbletea building blocks shared by the terminal tools
+// in this repo, so they look and behave alike: a palette, list delegates, a
+// status bar, a toast queue, a confirmation modal and a help overlay.
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Palette colours (ANSI 256).
+var (
+	Muted  = lipgloss.Color("241")
+	Accent = lipgloss.Color("39")
+	Warn   = lipgloss.Color("214")
+	Bad    = lipgloss.Color("196")
+	Good   = lipgloss.Color("42")
+)
+
+var (
+	// MutedStyle is for secondary text such as hints and counts.
+	MutedStyle = lipgloss.NewStyle().Foreground(Muted)
+	// FooterStyle sets key hints off from the content above by a blank line.
+	FooterStyle = MutedStyle.PaddingTop(1)
+	// CompactFooterStyle is FooterStyle without the blank line, for short
+	// terminals.
+	CompactFooterStyle = MutedStyle
+	// PaneStyle frames a pane; FocusedPaneStyle marks the one taking keys.
+	PaneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(Muted)
+	FocusedPaneStyle = PaneStyle.BorderForeground(Accent)
+	// ModalStyle frames dialogs drawn over the screen.
+	ModalStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(Accent).
+			Padding(1, 2)
+)
+
+// NewDelegate returns a list delegate in the shared palette. Compact
+// delegates drop the description line and the spacing between items.
+func NewDelegate(compact bool) list.DefaultDelegate {
+	d := list.NewDefaultDelegate()
+	d.Styles.SelectedTitle = d.Styles.SelectedTitle.Foreground(Accent).BorderLeftForeground(Accent)
+	d.Styles.SelectedDesc = d.Styles.SelectedDesc.Foreground(Accent).BorderLeftForeground(Accent)
+	if compact {
+		d.ShowDescription = false
+		d.SetSpacing(0)
+	}
+	return d
+}
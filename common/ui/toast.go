@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DefaultToastDuration is how long a toast stays up when Toasts.Duration is
+// zero.
+const DefaultToastDuration = 3 * time.Second
+
+// Toasts is a queue of short-lived notices shown one at a time. Push returns
+// the command that times the notice out; route ToastExpiredMsg to Update.
+type Toasts struct {
+	Duration time.Duration
+	queue    []string
+	seq      int
+}
+
+// ToastExpiredMsg ends the toast with the matching sequence number.
+type ToastExpiredMsg struct{ seq int }
+
+// Push queues text. It returns a timer command when text is shown right
+// away, and nil when it waits behind another toast.
+func (t *Toasts) Push(text string) tea.Cmd {
+	t.queue = append(t.queue, text)
+	if len(t.queue) > 1 {
+		return nil
+	}
+	return t.timer()
+}
+
+// Update drops the current toast when its timer fires and starts the next.
+func (t *Toasts) Update(msg tea.Msg) tea.Cmd {
+	m, ok := msg.(ToastExpiredMsg)
+	if !ok || m.seq != t.seq || len(t.queue) == 0 {
+		return nil
+	}
+	t.queue = t.queue[1:]
+	if len(t.queue) == 0 {
+		return nil
+	}
+	return t.timer()
+}
+
+// Current returns the toast on screen, if any.
+func (t *Toasts) Current() (string, bool) {
+	if len(t.queue) == 0 {
+		return "", false
+	}
+	return t.queue[0], true
+}
+
+func (t *Toasts) timer() tea.Cmd {
+	t.seq++
+	seq := t.seq
+	d := t.Duration
+	if d <= 0 {
+		d = DefaultToastDuration
+	}
+	return tea.Tick(d, func(time.Time) tea.Msg { return ToastExpiredMsg{seq: seq} })
+}
@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestToastsQueue(t *testing.T) {
+	var ts Toasts
+	if cmd := ts.Push("one"); cmd == nil {
+		t.Fatal("first toast should start a timer")
+	}
+	if cmd := ts.Push("two"); cmd != nil {
+		t.Fatal("queued toast should wait")
+	}
+	if cur, _ := ts.Current(); cur != "one" {
+		t.Fatalf("current = %q", cur)
+	}
+
+	// A timer from an earlier toast must not end the current one.
+	if cmd := ts.Update(ToastExpiredMsg{seq: 0}); cmd != nil {
+		t.Fatal("stale expiry started a timer")
+	}
+	if cmd := ts.Update(ToastExpiredMsg{seq: ts.seq}); cmd == nil {
+		t.Fatal("next toast should start a timer")
+	}
+	if cur, _ := ts.Current(); cur != "two" {
+		t.Fatalf("current = %q", cur)
+	}
+	ts.Update(ToastExpiredMsg{seq: ts.seq})
+	if _, ok := ts.Current(); ok {
+		t.Fatal("queue should be empty")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	var c Confirm
+	c.Ask("wipe", "Wipe everything?")
+	if !c.Active() || !strings.Contains(c.View(80), "Wipe everything?") {
+		t.Fatal("modal not shown")
+	}
+	msg := c.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})()
+	if got := msg.(ConfirmMsg); got != (ConfirmMsg{ID: "wipe", Yes: true}) {
+		t.Fatalf("msg = %+v", got)
+	}
+	if c.Active() || c.View(80) != "" {
+		t.Fatal("modal still open")
+	}
+
+	c.Ask("wipe", "Again?")
+	if got := c.HandleKey(tea.KeyMsg{Type: tea.KeyEsc})().(ConfirmMsg); got.Yes {
+		t.Fatal("esc confirmed")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	base := strings.Repeat("..........\n", 4) + ".........."
+	out := strings.Split(Overlay(base, "ab\ncd", 10, 5), "\n")
+	if len(out) != 5 {
+		t.Fatalf("lines = %d", len(out))
+	}
+	want := []string{"..........", "....ab....", "....cd....", "..........", ".........."}
+	for i, w := range want {
+		if got := ansi.Strip(out[i]); got != w {
+			t.Errorf("line %d = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestStatusBar(t *testing.T) {
+	got := ansi.Strip(StatusBar{Text: "Syncing a very long message", Right: "12 groups"}.View(30))
+	if ansi.StringWidth(got) != 30 || !strings.HasSuffix(got, "12 groups") || !strings.HasPrefix(got, "Syncing") {
+		t.Fatalf("bar = %q", got)
+	}
+}
+
+func TestHints(t *testing.T) {
+	if got := Hints([]Key{{"enter", "open"}, {"q", "quit"}}); got != "enter: open  q: quit" {
+		t.Fatalf("hints = %q", got)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]int{0, 1, 50, 100}); got != "▁▂▅█" {
+		t.Fatalf("sparkline = %q", got)
+	}
+	if got := Sparkline([]int{0, 0}); got != "▁▁" {
+		t.Fatalf("empty sparkline = %q", got)
+	}
+}
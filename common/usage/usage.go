@@ -0,0 +1,176 @@
+// Package usage keeps opt-in, local-only counts of which commands and key
+// bindings get used, to show which features are worth investing in. Nothing
+// is ever sent anywhere: counts live in a JSON file under the XDG state
+// directory and are only read back by the tools' "stats --usage" commands.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"common/atomicfile"
+	"common/xdg"
+)
+
+// Stats is the content of a usage file.
+type Stats struct {
+	Since  time.Time      `json:"since"`
+	Counts map[string]int `json:"counts"`
+}
+
+// Counter accumulates counts in memory until Flush. A nil *Counter is valid
+// and records nothing, which is how disabled stats are represented.
+type Counter struct {
+	path   string
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// Path returns the usage file of app.
+func Path(app string) (string, error) {
+	dir, err := xdg.StateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, app, "usage.json"), nil
+}
+
+// New returns a counter for app, or nil when enabled is false.
+func New(app string, enabled bool) (*Counter, error) {
+	if !enabled {
+		return nil, nil
+	}
+	path, err := Path(app)
+	if err != nil {
+		return nil, err
+	}
+	return &Counter{path: path, counts: map[string]int{}}, nil
+}
+
+// Add counts one use of the feature called name.
+func (c *Counter) Add(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.counts[name]++
+	c.mu.Unlock()
+}
+
+// Flush merges the pending counts into the usage file.
+func (c *Counter) Flush() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.counts) == 0 {
+		return nil
+	}
+	s, err := Load(c.path)
+	if err != nil {
+		return err
+	}
+	for k, n := range c.counts {
+		s.Counts[k] += n
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(c.path, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	clear(c.counts)
+	return nil
+}
+
+// Load reads a usage file; a missing one yields empty stats starting now.
+func Load(path string) (Stats, error) {
+	s := Stats{Since: time.Now().Truncate(time.Second), Counts: map[string]int{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("read usage stats %s: %w", path, err)
+	}
+	if s.Counts == nil {
+		s.Counts = map[string]int{}
+	}
+	return s, nil
+}
+
+// Report writes the counts to w, most used first.
+func (s Stats) Report(w io.Writer) {
+	if len(s.Counts) == 0 {
+		fmt.Fprintln(w, "No usage recorded yet.")
+		return
+	}
+	names := make([]string, 0, len(s.Counts))
+	width := 0
+	for k := range s.Counts {
+		names = append(names, k)
+		width = max(width, len(k))
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.Counts[names[i]] != s.Counts[names[j]] {
+			return s.Counts[names[i]] > s.Counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintf(w, "Usage since %s:\n", s.Since.Local().Format(time.DateOnly))
+	for _, k := range names {
+		fmt.Fprintf(w, "  %-*s  %d\n", width, k, s.Counts[k])
+	}
+}
+
+// Command implements "stats --usage [--reset]" for app and returns the exit
+// code. enabled only changes the hint printed under the counts.
+func Command(app string, enabled bool, args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(w)
+	show := fs.Bool("usage", false, "print how often each command and key binding was used")
+	reset := fs.Bool("reset", false, "delete the recorded usage counts")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !*show && !*reset {
+		fs.Usage()
+		return 2
+	}
+	path, err := Path(app)
+	if err != nil {
+		fmt.Fprintf(w, "stats: %v\n", err)
+		return 1
+	}
+	if *reset {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "stats: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(w, "Usage stats cleared.")
+		return 0
+	}
+	s, err := Load(path)
+	if err != nil {
+		fmt.Fprintf(w, "stats: %v\n", err)
+		return 1
+	}
+	s.Report(w)
+	if !enabled {
+		fmt.Fprintln(w, "\nRecording is off; set usage_stats = true in the config file to turn it on.")
+	}
+	return 0
+}
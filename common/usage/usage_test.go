@@ -0,0 +1,61 @@
+package usage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterFlushAndReport(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	var off *Counter
+	off.Add("ignored")
+	if err := off.Flush(); err != nil {
+		t.Fatalf("nil Flush: %v", err)
+	}
+
+	for range 2 {
+		c, err := New("tool", true)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		c.Add("groups: archive")
+		c.Add("groups: archive")
+		c.Add("command: sync")
+		if err := c.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+
+	path, _ := Path("tool")
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if s.Counts["groups: archive"] != 4 || s.Counts["command: sync"] != 2 || s.Since.IsZero() {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	var out bytes.Buffer
+	if code := Command("tool", true, []string{"--usage"}, &out); code != 0 {
+		t.Fatalf("Command = %d: %s", code, out.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "groups: archive  4") || !strings.Contains(lines[2], "command: sync    2") {
+		t.Fatalf("report:\n%s", out.String())
+	}
+
+	out.Reset()
+	if code := Command("tool", false, []string{"--reset"}, &out); code != 0 {
+		t.Fatalf("reset = %d: %s", code, out.String())
+	}
+	out.Reset()
+	Command("tool", false, []string{"--usage"}, &out)
+	if !strings.Contains(out.String(), "No usage recorded yet.") || !strings.Contains(out.String(), "Recording is off") {
+		t.Fatalf("after reset:\n%s", out.String())
+	}
+	if code := Command("tool", true, nil, &out); code != 2 {
+		t.Fatalf("no flags = %d, want 2", code)
+	}
+}
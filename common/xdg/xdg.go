@@ -0,0 +1,106 @@
+// Package xdg resolves per-user directories following the XDG Base Directory
+// specification, with the same layout on every platform so paths in the docs
+// hold everywhere.
+package xdg
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigHome returns $XDG_CONFIG_HOME, or ~/.config.
+func ConfigHome() (string, error) { return base("XDG_CONFIG_HOME", ".config") }
+
+// DataHome returns $XDG_DATA_HOME, or ~/.local/share.
+func DataHome() (string, error) { return base("XDG_DATA_HOME", ".local", "share") }
+
+// StateHome returns $XDG_STATE_HOME, or ~/.local/state.
+func StateHome() (string, error) { return base("XDG_STATE_HOME", ".local", "state") }
+
+// CacheHome returns $XDG_CACHE_HOME, or ~/.cache.
+func CacheHome() (string, error) { return base("XDG_CACHE_HOME", ".cache") }
+
+// ConfigDir returns the configuration directory of app, e.g. ~/.config/app.
+func ConfigDir(app string) (string, error) {
+	dir, err := ConfigHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, app), nil
+}
+
+// DataDir returns the data directory of app, e.g. ~/.local/share/app.
+func DataDir(app string) (string, error) {
+	dir, err := DataHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, app), nil
+}
+
+// DownloadDir returns the user's download directory: $XDG_DOWNLOAD_DIR, the
+// XDG_DOWNLOAD_DIR entry of user-dirs.dirs, or ~/Downloads.
+func DownloadDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if dir := expandHome(os.Getenv("XDG_DOWNLOAD_DIR"), home); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	if cfg, err := ConfigHome(); err == nil {
+		if dir := userDir(filepath.Join(cfg, "user-dirs.dirs"), "XDG_DOWNLOAD_DIR", home); dir != "" {
+			return dir, nil
+		}
+	}
+	return filepath.Join(home, "Downloads"), nil
+}
+
+// base returns the directory named by env if it is absolute (relative values
+// are invalid per the spec and ignored), or home joined with fallback.
+func base(env string, fallback ...string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{home}, fallback...)...), nil
+}
+
+// userDir reads key from an xdg-user-dirs file of KEY="$HOME/path" lines.
+func userDir(path, key, home string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) != key {
+			continue
+		}
+		dir := expandHome(strings.Trim(strings.TrimSpace(v), `"`), home)
+		if filepath.IsAbs(dir) {
+			return dir
+		}
+	}
+	return ""
+}
+
+func expandHome(p, home string) string {
+	if p == "$HOME" || p == "~" {
+		return home
+	}
+	for _, prefix := range []string{"$HOME/", "~/"} {
+		if strings.HasPrefix(p, prefix) {
+			return filepath.Join(home, p[len(prefix):])
+		}
+	}
+	return p
+}
@@ -0,0 +1,51 @@
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "relative/ignored")
+	t.Setenv("XDG_CACHE_HOME", "/custom/cache")
+
+	if dir, _ := ConfigDir("app"); dir != filepath.Join(home, ".config", "app") {
+		t.Errorf("ConfigDir = %s", dir)
+	}
+	if dir, _ := DataHome(); dir != filepath.Join(home, ".local", "share") {
+		t.Errorf("DataHome = %s", dir)
+	}
+	if dir, _ := DataDir("app"); dir != filepath.Join(home, ".local", "share", "app") {
+		t.Errorf("DataDir = %s", dir)
+	}
+	if dir, _ := CacheHome(); dir != "/custom/cache" {
+		t.Errorf("CacheHome = %s", dir)
+	}
+}
+
+func TestDownloadDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DOWNLOAD_DIR", "")
+
+	if dir, _ := DownloadDir(); dir != filepath.Join(home, "Downloads") {
+		t.Errorf("default DownloadDir = %s", dir)
+	}
+
+	os.MkdirAll(filepath.Join(home, ".config"), 0o755)
+	os.WriteFile(filepath.Join(home, ".config", "user-dirs.dirs"),
+		[]byte("# comment\nXDG_DESKTOP_DIR=\"$HOME/Desktop\"\nXDG_DOWNLOAD_DIR=\"$HOME/Telechargements\"\n"), 0o644)
+	if dir, _ := DownloadDir(); dir != filepath.Join(home, "Telechargements") {
+		t.Errorf("user-dirs DownloadDir = %s", dir)
+	}
+
+	t.Setenv("XDG_DOWNLOAD_DIR", "/srv/dl")
+	if dir, _ := DownloadDir(); dir != "/srv/dl" {
+		t.Errorf("env DownloadDir = %s", dir)
+	}
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/store"
+	"chuckterm/internal/tui"
+)
+
+// accountSwitch is the account the TUI quit to open in its place.
+type accountSwitch struct {
+	dir      string            // its config directory
+	accounts map[string]string // the [accounts] the program started with
+	args     []string          // the flags that carry over
+}
+
+// nextAccount looks up the account named in the accounts view. Read-only
+// mode and dry runs carry over, so that switching never lifts them.
+func nextAccount(accounts map[string]string, configDir, name string, readOnly, dryRun bool) *accountSwitch {
+	next := &accountSwitch{dir: name, accounts: withCurrentAccount(accounts, configDir)}
+	if dir, ok := accounts[name]; ok {
+		next.dir = dir
+	}
+	if readOnly {
+		next.args = append(next.args, "--read-only")
+	}
+	if dryRun {
+		next.args = append(next.args, "--dry-run")
+	}
+	return next
+}
+
+// withCurrentAccount adds configDir, under its path, to accounts that do not
+// list it, so the accounts view can switch back to where the program
+// started.
+func withCurrentAccount(accounts map[string]string, configDir string) map[string]string {
+	for _, dir := range accounts {
+		if dir == configDir {
+			return accounts
+		}
+	}
+	out := maps.Clone(accounts)
+	out[configDir] = configDir
+	return out
+}
+
+// listAccounts sums up the cache of each of accounts for the accounts view,
+// in name order. The open account's cache is read through current, since a
+// bolt cache cannot be opened twice; the others are opened in turn.
+func listAccounts(ctx context.Context, accounts map[string]string, configDir string, current gmail.MessageStore) ([]tui.Account, error) {
+	accounts = withCurrentAccount(accounts, configDir)
+	out := make([]tui.Account, 0, len(accounts))
+	for _, name := range slices.Sorted(maps.Keys(accounts)) {
+		a := tui.Account{Name: name, Current: accounts[name] == configDir}
+		if a.Current {
+			a.Err = cacheSummary(ctx, current, &a)
+		} else {
+			a.Err = accountSummary(ctx, accounts[name], &a)
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// accountSummary fills in a from the cache of the account in dir, leaving
+// a cache that does not exist yet uncreated.
+func accountSummary(ctx context.Context, dir string, a *tui.Account) error {
+	cfg, err := readConfig(dir)
+	if err != nil {
+		return err
+	}
+	if cfg.Store == store.BackendMemory {
+		return nil
+	}
+	if _, err := os.Stat(cfg.Database); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	return cacheSummary(ctx, st, a)
+}
+
+// cacheSummary fills in a's counts and last sync from st.
+func cacheSummary(ctx context.Context, st gmail.MessageStore, a *tui.Account) error {
+	totals, err := gmail.CacheTotals(ctx, st)
+	if err != nil {
+		return err
+	}
+	a.Messages, a.Unread = totals.Messages, totals.Unread
+	a.LastSync, err = gmail.LastSync(ctx, st)
+	return err
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"chuckterm/internal/backup"
+)
+
+const passphraseEnv = "CHUCKTERM_BACKUP_PASSPHRASE"
+
+// runBackup implements `chuckterm backup --to TARGET [--every DURATION]`.
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	to := fs.String("to", "", "backup target: directory, s3://bucket/prefix, webdav+https://host/path, or rclone:remote:path")
+	every := fs.Duration("every", 0, "keep running and back up on this interval (e.g. 24h)")
+	passFile := fs.String("passphrase-file", "", "read the encryption passphrase from this file instead of $"+passphraseEnv)
+	fs.Parse(args)
+
+	target, passphrase, err := backupTargetAndPassphrase(*to, *passFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		return 2
+	}
+
+	configDir, cfg := loadConfig()
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	snap, ok := db.(backup.Snapshotter)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "backup: the %s store keeps nothing on disk\n", cfg.Store)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt)
+	defer stop()
+	opts := backup.Options{ConfigDir: configDir, DBPath: cfg.Database, Passphrase: passphrase, Target: target}
+	for {
+		name, err := backup.Create(ctx, snap, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+			if *every == 0 {
+				return 1
+			}
+		} else {
+			fmt.Printf("%s backup %s written to %s\n", time.Now().Format(time.DateTime), name, *to)
+		}
+		if *every == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(*every):
+		}
+	}
+}
+
+// runRestore implements `chuckterm restore --from TARGET [--name BACKUP]`.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	from := fs.String("from", "", "backup target to restore from (same forms as backup --to)")
+	name := fs.String("name", "", "backup to restore (default: the most recent)")
+	passFile := fs.String("passphrase-file", "", "read the encryption passphrase from this file instead of $"+passphraseEnv)
+	fs.Parse(args)
+
+	target, passphrase, err := backupTargetAndPassphrase(*from, *passFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 2
+	}
+	configDir, dbPath := defaultPaths()
+	restored, err := backup.Restore(context.Background(), *name, backup.Options{
+		ConfigDir: configDir, DBPath: dbPath, Passphrase: passphrase, Target: target,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Restored %s into %s\n", restored, configDir)
+	return 0
+}
+
+func backupTargetAndPassphrase(rawTarget, passFile string) (backup.Target, string, error) {
+	if rawTarget == "" {
+		return nil, "", fmt.Errorf("a target is required")
+	}
+	target, err := backup.ParseTarget(rawTarget)
+	if err != nil {
+		return nil, "", err
+	}
+	passphrase := os.Getenv(passphraseEnv)
+	if passFile != "" {
+		b, err := os.ReadFile(passFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("read passphrase file: %w", err)
+		}
+		passphrase = strings.TrimRight(string(b), "\r\n")
+	}
+	if passphrase == "" {
+		return nil, "", fmt.Errorf("set $%s or pass --passphrase-file", passphraseEnv)
+	}
+	return target, passphrase, nil
+}
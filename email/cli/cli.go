@@ -0,0 +1,330 @@
+// Package cli implements the chuckterm command line: the inbox TUI, the
+// headless sync, groups, messages, archive, trash, unsubscribe, export,
+// senders, rules and snooze commands, and the backup, restore, import, daemon, contacts
+// and db subcommands. It is shared by
+// cmd/chuckterm and the repository-wide things binary.
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/push"
+	"chuckterm/internal/store"
+	"chuckterm/internal/tui"
+	"common/notify"
+	"common/usage"
+)
+
+// Main runs chuckterm with the given arguments (without the program name)
+// and returns the process exit code.
+func Main(args []string) int {
+	defer gmail.LogUsage()
+	args, err := globalOptions(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "backup":
+			countCommand("backup")
+			return runBackup(args[1:])
+		case "restore":
+			countCommand("restore")
+			return runRestore(args[1:])
+		case "import":
+			countCommand("import")
+			return runImport(args[1:])
+		case "daemon":
+			countCommand("daemon")
+			return runDaemon(args[1:])
+		case "contacts":
+			countCommand("contacts")
+			return runContacts(args[1:])
+		case "sync":
+			countCommand("sync")
+			return runSync(args[1:])
+		case "groups":
+			countCommand("groups")
+			return runGroups(args[1:])
+		case "messages":
+			countCommand("messages")
+			return runMessages(args[1:])
+		case "archive":
+			countCommand("archive")
+			return runArchive(args[1:])
+		case "trash":
+			countCommand("trash")
+			return runTrash(args[1:])
+		case "unsubscribe":
+			countCommand("unsubscribe")
+			return runUnsubscribe(args[1:])
+		case "export":
+			countCommand("export")
+			return runExport(args[1:])
+		case "senders":
+			countCommand("senders")
+			return runSenders(args[1:])
+		case "rules":
+			countCommand("rules")
+			return runRules(args[1:])
+		case "snooze":
+			countCommand("snooze")
+			return runSnooze(args[1:])
+		case "db":
+			countCommand("db")
+			return runDB(args[1:])
+		case "stats":
+			_, cfg := loadConfig()
+			return usage.Command("chuckterm", cfg.UsageStats, args[1:], os.Stdout)
+		}
+	}
+
+	code, next := runTUI(args, nil)
+	for next != nil {
+		// The TUI quit to open another account in its place.
+		configDirOption, dbOption = next.dir, ""
+		code, next = runTUI(next.args, next.accounts)
+	}
+	return code
+}
+
+// runTUI runs the inbox TUI on the account loadConfig finds. accounts, when
+// set, stands in for its [accounts], so that switching keeps the list the
+// program started with. It returns the account the user switched to, if
+// any.
+func runTUI(args []string, accounts map[string]string) (int, *accountSwitch) {
+	configDir, cfg := loadConfig()
+	if accounts == nil {
+		accounts = cfg.Accounts
+	}
+	counter, err := usage.New("chuckterm", cfg.UsageStats)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot record usage stats: %v\n", err)
+	}
+	counter.Add("command: tui")
+	defer counter.Flush()
+	fs := flag.NewFlagSet("chuckterm", flag.ExitOnError)
+	lowMemory := fs.Bool("low-memory", false, "aggregate groups in SQLite and stream message IDs instead of holding the mailbox in RAM")
+	dbPath := fs.String("db", cfg.Database, "cache file; before a subcommand, the cache of that command")
+	configDirFlag := fs.String("config-dir", configDir, "directory of config.toml, the token and the other settings; must come first")
+	label := fs.String("label", cfg.Label, `Gmail label to sync: INBOX, ALL (all mail), a label ID like CATEGORY_PROMOTIONS, or a label name; for IMAP, the folder`)
+	query := fs.String("query", cfg.Query, `Gmail search that narrows the sync within the label, e.g. "category:promotions older_than:1y"`)
+	scanWindow := fs.String("scan-window", cfg.ScanWindow, "on a full scan, cache only mail this recent, such as 6mo or 2y; chuckterm sync --extend reaches further back later")
+	pushTopic := fs.String("push-topic", "", "Pub/Sub topic for Gmail push notifications (projects/P/topics/T); enables push-triggered sync")
+	pushWebhook := fs.String("push-webhook", "", "listen address for a Pub/Sub push subscription endpoint, e.g. :8085")
+	pushToken := fs.String("push-token", os.Getenv("CHUCKTERM_PUSH_TOKEN"), "required ?token= value on push requests")
+	pushSub := fs.String("push-subscription", "", "Pub/Sub pull subscription (projects/P/subscriptions/S), using Application Default Credentials")
+	notifyNew := fs.Bool("notify", cfg.Notify, "show a desktop notification when a sync finds unread mail")
+	calendarFile := fs.String("calendar-file", cfg.CalendarFile, "iCalendar file that c in the message view adds invitations to")
+	tuning := syncTuningFlags(fs, cfg)
+	sortOrder := fs.String("sort", cfg.Sort, "groups order: count, newest, oldest, sender or size")
+	subjects := fs.String("subjects", cfg.Subjects, "subject grouping: exact; normalized to merge Re:/Fwd: and dated or numbered issues; fuzzy to also merge near-identical subjects")
+	preview := fs.Bool("preview", cfg.Preview, "show the highlighted message's body beside the messages list (v toggles it)")
+	pageSize := fs.Int("page-size", cfg.PageSize, "groups loaded at a time while listed by count; more load as you scroll (0 loads all)")
+	bodyCacheMB := fs.Int64("body-cache-mb", cfg.BodyCacheMB, "megabytes of opened message bodies to keep for offline reading (0 disables)")
+	bodyMemory := fs.Int("body-memory", cfg.BodyMemory, "recently opened message bodies to also keep in memory (0 disables)")
+	demoMode := fs.Bool("demo", false, "explore a generated mailbox instead of your Gmail account; nothing is sent or saved")
+	dryRun := dryRunFlag(fs, cfg)
+	authFlow := fs.String("auth", cfg.Auth, "how to sign in when there is no valid token: browser (the default) or device, to enter a code on another device")
+	readOnly := fs.Bool("read-only", cfg.ReadOnly, "refuse every action that changes mail, and sign in with the gmail.readonly scope only")
+	credentials := fs.String("credentials", cfg.Credentials, "OAuth client credentials file, instead of client_secret.json in the config directory")
+	spam := fs.Bool("spam", false, "review the spam folder, in a cache of its own: archive moves mail back to the inbox and trash deletes it for good")
+	fs.Parse(args)
+	if *configDirFlag != configDir {
+		fmt.Fprintln(os.Stderr, "--config-dir must come before the other flags")
+		return 2, nil
+	}
+	gmail.SetCredentialsFile(*credentials)
+
+	pushCfg := push.Config{Topic: *pushTopic, WebhookAddr: *pushWebhook, WebhookToken: *pushToken, Subscription: *pushSub}
+	if err := pushCfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2, nil
+	}
+	order, err := gmail.ParseGroupOrder(*sortOrder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2, nil
+	}
+	flow, err := gmail.ParseAuthFlow(*authFlow)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2, nil
+	}
+	gmail.SetAuthFlow(flow)
+	subjectGrouping, err := gmail.ParseSubjectGrouping(*subjects)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2, nil
+	}
+	keys := tui.Keymap(cfg.Keys)
+	if err := keys.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "config.toml [keys]: %v\n", err)
+		return 2, nil
+	}
+	if err := tuning.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2, nil
+	}
+	if *pageSize < 0 {
+		fmt.Fprintln(os.Stderr, "--page-size must not be negative")
+		return 2, nil
+	}
+	if _, err := gmail.WindowStart(*scanWindow, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "--scan-window: %v\n", err)
+		return 2, nil
+	}
+	if *demoMode && (*lowMemory || pushCfg.Enabled()) {
+		fmt.Fprintln(os.Stderr, "--demo cannot be combined with --low-memory or push notifications")
+		return 2, nil
+	}
+	if *lowMemory && cfg.Store != store.BackendSQLite {
+		fmt.Fprintf(os.Stderr, "--low-memory needs the sqlite store, not %s\n", cfg.Store)
+		return 2, nil
+	}
+	if *lowMemory && *pageSize == 0 {
+		fmt.Fprintln(os.Stderr, "--low-memory pages the groups list, so --page-size cannot be 0")
+		return 2, nil
+	}
+	if cfg.Provider == providerIMAP && pushCfg.Enabled() {
+		fmt.Fprintln(os.Stderr, "push notifications need a Gmail account")
+		return 2, nil
+	}
+	if *spam {
+		if cfg.Provider == providerIMAP && !*demoMode {
+			fmt.Fprintln(os.Stderr, "--spam needs a Gmail account")
+			return 2, nil
+		}
+		dbSet := false
+		fs.Visit(func(f *flag.Flag) { dbSet = dbSet || f.Name == "db" })
+		if !dbSet {
+			*dbPath = spamDatabase(*dbPath)
+		}
+		*label = gmail.SpamLabel
+		// Deleting for good needs the full mail scope.
+		gmail.SetFullAccess(true)
+	}
+
+	autoLabels, err := gmail.LoadAutoLabelRules(filepath.Join(configDir, "autolabel.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load auto-label rules: %v\n", err)
+		return 1, nil
+	}
+	opts := tui.Options{
+		LowMemory:      *lowMemory,
+		AutoLabels:     autoLabels,
+		Label:          *label,
+		Query:          *query,
+		Window:         *scanWindow,
+		Push:           pushCfg,
+		BodyCacheBytes: *bodyCacheMB << 20,
+		BodyMemory:     *bodyMemory,
+		CalendarFile:   *calendarFile,
+		Signatures:     cfg.Signatures,
+		Workers:        *tuning.workers,
+		ListPageSize:   *tuning.listPageSize,
+		UpsertBatch:    *tuning.upsertBatch,
+		Retention:      cfg.retention(),
+		Sort:           order,
+		Subjects:       subjectGrouping,
+		Preview:        *preview,
+		PageSize:       *pageSize,
+		Usage:          counter,
+		Keys:           keys,
+		DryRun:         *dryRun,
+		ReadOnly:       *readOnly,
+		Spam:           *spam,
+		Confirm: tui.Confirmations{
+			Archive:         cfg.Confirm.Archive,
+			Trash:           cfg.Confirm.Trash,
+			BulkUnsubscribe: cfg.Confirm.BulkUnsubscribe,
+		},
+	}
+	if *notifyNew {
+		opts.Notifier = notify.New("chuckterm")
+	}
+	if *dryRun {
+		audit, closeAudit := openAuditLog("tui", nil)
+		defer closeAudit()
+		opts.Audit = audit
+	}
+	if cfg.Provider == providerIMAP && !*demoMode {
+		if opts.Mailbox, err = imapAccount(context.Background(), cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot open IMAP account: %v\n", err)
+			return 1, nil
+		}
+	}
+	var db gmail.MessageStore
+	if *demoMode {
+		mem, cleanup, err := startDemo(&opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot start demo: %v\n", err)
+			return 1, nil
+		}
+		defer cleanup()
+		db = mem
+	} else {
+		cfg.Database = *dbPath
+		st, err := openStore(context.Background(), cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+			return 1, nil
+		}
+		defer st.Close()
+		db = st
+	}
+	if len(accounts) > 0 && !*demoMode {
+		opts.Accounts = func(ctx context.Context) ([]tui.Account, error) {
+			return listAccounts(ctx, accounts, configDir, db)
+		}
+	}
+	appModel := tui.NewAppModel(db, configDir, opts)
+	p := tea.NewProgram(&appModel, tea.WithAltScreen())
+	appModel.SetProgram(p)
+	finalModel, err := p.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Alas, there's been an error: %v\n", err)
+		return 1, nil
+	}
+	if m, ok := finalModel.(*tui.AppModel); ok && m.Err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", m.Err)
+		return 1, nil
+	}
+	if m, ok := finalModel.(*tui.AppModel); ok {
+		if err := m.SaveSession(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot save the session: %v\n", err)
+		}
+		if m.SwitchAccount != "" {
+			return 0, nextAccount(accounts, configDir, m.SwitchAccount, *readOnly, *dryRun)
+		}
+	}
+	return 0, nil
+}
+
+// countCommand records a use of a subcommand when usage stats are enabled.
+func countCommand(name string) {
+	_, cfg := loadConfig()
+	c, err := usage.New("chuckterm", cfg.UsageStats)
+	if err != nil {
+		return
+	}
+	c.Add("command: " + name)
+	// Losing a count is not worth failing the command over.
+	c.Flush()
+}
+
+// defaultPaths returns the config directory and the database path from
+// config.toml, exiting if either cannot be determined.
+func defaultPaths() (configDir, dbPath string) {
+	configDir, cfg := loadConfig()
+	return configDir, cfg.Database
+}
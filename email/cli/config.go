@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/store"
+	"common/config"
+	"common/scheduler"
+	"common/xdg"
+)
+
+// Config is read from config.toml in the config directory. Most fields have
+// a matching command-line flag, and flags win.
+type Config struct {
+	// Database is the cache file; relative paths are resolved against the
+	// config directory. It defaults to chuckterm.db, or chuckterm.bolt for
+	// the bolt store, in the data directory (see defaultDatabase).
+	Database string `toml:"database" env:"CHUCKTERM_DB"`
+	// Store is the cache backend: sqlite, bolt or memory (see store.Open).
+	Store string `toml:"store" env:"CHUCKTERM_STORE"`
+	// DatabasePassphrase encrypts a bolt cache; DatabasePassphraseCommand
+	// prints it instead, for example from the system keychain (see
+	// openStore).
+	DatabasePassphrase        string `toml:"database_passphrase" env:"CHUCKTERM_DB_PASSPHRASE"`
+	DatabasePassphraseCommand string `toml:"database_passphrase_command"`
+	Label                     string `toml:"label"`
+	Query                     string `toml:"query"`
+	ScanWindow                string `toml:"scan_window"`
+	Workers                   int    `toml:"workers"`
+	ListPageSize              int    `toml:"list_page_size"`
+	UpsertBatch               int    `toml:"upsert_batch"`
+	Sort                      string `toml:"sort"`
+	Subjects                  string `toml:"subjects"`
+	BodyCacheMB               int64  `toml:"body_cache_mb"`
+	BodyMemory                int    `toml:"body_memory"`
+	Notify                    bool   `toml:"notify"`
+	CalendarFile              string `toml:"calendar_file"`
+	Preview                   bool   `toml:"preview"`
+	PageSize                  int    `toml:"page_size"`
+	// UsageStats turns on local usage counting (see "chuckterm stats").
+	UsageStats bool `toml:"usage_stats" env:"CHUCKTERM_USAGE_STATS"`
+	// Auth is how a missing token is obtained: browser or device (see
+	// gmail.AuthFlow).
+	Auth string `toml:"auth" env:"CHUCKTERM_AUTH"`
+	// DryRun makes every command that changes mail only log what it would
+	// do (see --dry-run).
+	DryRun bool `toml:"dry_run" env:"CHUCKTERM_DRY_RUN"`
+	// ReadOnly refuses every change to mail and signs in with the
+	// gmail.readonly scope only (see --read-only and gmail.WithReadOnly).
+	ReadOnly bool `toml:"read_only" env:"CHUCKTERM_READ_ONLY"`
+	// Proxy is the proxy for Gmail and unsubscribe links; unset, the
+	// HTTPS_PROXY and ALL_PROXY variables apply (see gmail.SetProxy).
+	Proxy string `toml:"proxy" env:"CHUCKTERM_PROXY"`
+	// Credentials is the OAuth client file to use instead of
+	// client_secret.json in the config directory (see
+	// gmail.SetCredentialsFile).
+	Credentials string `toml:"credentials" env:"CHUCKTERM_CREDENTIALS"`
+	// Accounts names the config directories of the accounts the TUI's
+	// accounts view switches between, each as --config-dir would take it.
+	Accounts map[string]string `toml:"accounts"`
+	// LogLevel is how much goes to chuckterm.log (see startLog).
+	LogLevel string `toml:"log_level" env:"CHUCKTERM_LOG_LEVEL"`
+	// Provider is the mail service: gmail, or imap for the [imap] account.
+	Provider string `toml:"provider" env:"CHUCKTERM_PROVIDER"`
+	// IMAP is the account used when Provider is imap; Label then names the
+	// folder to triage.
+	IMAP struct {
+		// Host is host or host:port; the port defaults to 993.
+		Host            string `toml:"host" env:"CHUCKTERM_IMAP_HOST"`
+		User            string `toml:"user" env:"CHUCKTERM_IMAP_USER"`
+		Password        string `toml:"password" env:"CHUCKTERM_IMAP_PASSWORD"`
+		PasswordCommand string `toml:"password_command"`
+		Archive         string `toml:"archive"`
+		Trash           string `toml:"trash"`
+	} `toml:"imap"`
+	// Retention bounds the cache (see gmail.Retention); unset, it keeps
+	// everything.
+	Retention struct {
+		MaxAge string `toml:"max_age"`
+		MaxMB  int64  `toml:"max_mb"`
+	} `toml:"retention"`
+	// Daemon holds the defaults of chuckterm daemon's schedule flags.
+	Daemon struct {
+		Every         string        `toml:"every"`
+		Jitter        time.Duration `toml:"jitter"`
+		MaintainEvery string        `toml:"maintain_every"`
+	} `toml:"daemon"`
+	// Signatures are added to new messages, by account address or
+	// "default" (see tui.Options.Signatures).
+	Signatures map[string]string `toml:"signatures"`
+	Confirm    struct {
+		Archive         bool `toml:"archive"`
+		Trash           bool `toml:"trash"`
+		BulkUnsubscribe bool `toml:"bulk_unsubscribe"`
+	} `toml:"confirm"`
+	// Keys remaps groups-view actions; unset entries keep their default key.
+	Keys struct {
+		Archive        string `toml:"archive"`
+		Trash          string `toml:"trash"`
+		Read           string `toml:"read"`
+		Unsubscribe    string `toml:"unsubscribe"`
+		UnsubscribeAll string `toml:"unsubscribe_all"`
+		Queue          string `toml:"unsubscribe_queue"`
+		Pin            string `toml:"pin"`
+		Sort           string `toml:"sort"`
+		Domains        string `toml:"domains"`
+		Dates          string `toml:"dates"`
+		Details        string `toml:"details"`
+		Sync           string `toml:"sync"`
+		Export         string `toml:"export"`
+		Stats          string `toml:"stats"`
+		Protect        string `toml:"protect"`
+		Block          string `toml:"block"`
+		Rules          string `toml:"rules"`
+		Filter         string `toml:"filter"`
+		Drafts         string `toml:"drafts"`
+		Accounts       string `toml:"accounts"`
+		Trashed        string `toml:"trashed"`
+		Sender         string `toml:"sender"`
+		Bulk           string `toml:"bulk"`
+		Profile        string `toml:"profile"`
+	} `toml:"keys"`
+}
+
+// configDirOption, dbOption and readOnlyOption are the --config-dir, --db
+// and --read-only options given before the subcommand (see globalOptions).
+var (
+	configDirOption, dbOption string
+	readOnlyOption            bool
+)
+
+// globalOptions takes --config-dir, --db and --read-only off the front of
+// args, where they apply to every command, and returns the rest.
+func globalOptions(args []string) ([]string, error) {
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		if !strings.HasPrefix(args[0], "-") || (name != "config-dir" && name != "db" && name != "read-only") {
+			return args, nil
+		}
+		args = args[1:]
+		if name == "read-only" {
+			on, err := strconv.ParseBool(cmp.Or(value, "true"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid boolean value %q for -read-only", value)
+			}
+			readOnlyOption = on
+			continue
+		}
+		if !hasValue {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("flag needs an argument: -%s", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		if name == "config-dir" {
+			configDirOption = value
+		} else {
+			dbOption = value
+		}
+	}
+	return args, nil
+}
+
+// configDirectory is --config-dir, else $CHUCKTERM_CONFIG_DIR, else
+// $XDG_CONFIG_HOME/chuckterm.
+func configDirectory() (string, error) {
+	dir := configDirOption
+	if dir == "" {
+		dir = os.Getenv("CHUCKTERM_CONFIG_DIR")
+	}
+	if dir == "" {
+		return xdg.ConfigDir("chuckterm")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return resolvePath(wd, dir), nil
+}
+
+// defaultDatabase is where the cache lives when config.toml does not say:
+// in a config directory other than the usual one, with everything else, or
+// else in $XDG_DATA_HOME/chuckterm. A cache from before chuckterm used the
+// data directory stays where it is.
+func defaultDatabase(configDir, name string) string {
+	if dir, err := xdg.ConfigDir("chuckterm"); err != nil || dir != configDir {
+		return filepath.Join(configDir, name)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, name)); err == nil {
+		return filepath.Join(configDir, name)
+	}
+	dataDir, err := xdg.DataDir("chuckterm")
+	if err != nil {
+		return filepath.Join(configDir, name)
+	}
+	return filepath.Join(dataDir, name)
+}
+
+// loadConfig returns the config directory and the settings in its
+// config.toml, on top of the built-in defaults, and applies those that hold
+// for the whole process. It exits if either cannot be read, like
+// defaultPaths.
+func loadConfig() (string, Config) {
+	configDir, err := configDirectory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot determine config directory: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := readConfig(configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load %v\n", err)
+		os.Exit(1)
+	}
+	if dbOption != "" {
+		wd, _ := os.Getwd()
+		cfg.Database = resolvePath(wd, dbOption)
+	}
+	path := filepath.Join(configDir, "config.toml")
+	gmail.SetCredentialsFile(cfg.Credentials)
+	flow, _ := gmail.ParseAuthFlow(cfg.Auth)
+	gmail.SetAuthFlow(flow)
+	cfg.ReadOnly = cfg.ReadOnly || readOnlyOption
+	if err := gmail.SetProxy(cfg.Proxy); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if err := startLog(configDir, cfg.LogLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return configDir, cfg
+}
+
+// readConfig reads config.toml in configDir over the built-in defaults and
+// checks it, resolving its paths, without applying anything. Its errors
+// start with the file's path.
+func readConfig(configDir string) (Config, error) {
+	cfg := Config{
+		Label:        "INBOX",
+		Sort:         "count",
+		BodyCacheMB:  64,
+		BodyMemory:   50,
+		PageSize:     500,
+		CalendarFile: "calendar.ics",
+	}
+	cfg.Confirm.BulkUnsubscribe = true
+	cfg.Daemon.Every, cfg.Daemon.Jitter, cfg.Daemon.MaintainEvery = "15m", time.Minute, "@weekly"
+	path := filepath.Join(configDir, "config.toml")
+	if err := config.Load(path, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	var err error
+	if cfg.Store, err = store.ParseBackend(cfg.Store); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	switch {
+	case cfg.Database == "" && cfg.Store == store.BackendBolt:
+		cfg.Database = defaultDatabase(configDir, "chuckterm.bolt")
+	case cfg.Database == "":
+		cfg.Database = defaultDatabase(configDir, "chuckterm.db")
+	}
+	cfg.Database = resolvePath(configDir, cfg.Database)
+	cfg.CalendarFile = resolvePath(configDir, cfg.CalendarFile)
+	cfg.Credentials = resolvePath(configDir, cfg.Credentials)
+	for name, dir := range cfg.Accounts {
+		cfg.Accounts[name] = resolvePath(configDir, dir)
+	}
+	if _, err := gmail.ParseAuthFlow(cfg.Auth); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.Provider, err = parseProvider(cfg.Provider); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.retention().Validate(); err != nil {
+		return cfg, fmt.Errorf("%s: [retention] %w", path, err)
+	}
+	if _, err := gmail.WindowStart(cfg.ScanWindow, time.Now()); err != nil {
+		return cfg, fmt.Errorf("%s: scan_window: %w", path, err)
+	}
+	if _, err := scheduler.ParseSchedule(cfg.Daemon.Every); err != nil {
+		return cfg, fmt.Errorf("%s: [daemon] every: %w", path, err)
+	}
+	if cfg.Daemon.MaintainEvery != "off" {
+		if _, err := scheduler.ParseSchedule(cfg.Daemon.MaintainEvery); err != nil {
+			return cfg, fmt.Errorf("%s: [daemon] maintain_every: %w", path, err)
+		}
+	}
+	tuning := gmail.SyncOptions{Workers: cfg.Workers, ListPageSize: cfg.ListPageSize, UpsertBatch: cfg.UpsertBatch}
+	if err := tuning.Validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// retention returns the [retention] settings for sync.
+func (c Config) retention() gmail.Retention {
+	return gmail.Retention{MaxAge: c.Retention.MaxAge, MaxBytes: c.Retention.MaxMB << 20}
+}
+
+// resolvePath expands a leading ~/ and makes p absolute relative to dir.
+func resolvePath(dir, p string) string {
+	if p == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets the CHUCKTERM_ variables for the test, so that only those
+// it sets apply.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "CHUCKTERM_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		file  string
+		env   map[string]string
+		check func(t *testing.T, dir string, cfg Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, dir string, cfg Config) {
+				if cfg.Label != "INBOX" || cfg.Sort != "count" || cfg.PageSize != 500 || cfg.Store != "sqlite" || cfg.Provider != "gmail" {
+					t.Errorf("defaults: %+v", cfg)
+				}
+				if cfg.Database != filepath.Join(dir, "chuckterm.db") {
+					t.Errorf("database %q", cfg.Database)
+				}
+				if cfg.CalendarFile != filepath.Join(dir, "calendar.ics") {
+					t.Errorf("calendar file %q", cfg.CalendarFile)
+				}
+				if !cfg.Confirm.BulkUnsubscribe || cfg.Daemon.Every != "15m" {
+					t.Errorf("confirm %+v, daemon %+v", cfg.Confirm, cfg.Daemon)
+				}
+			},
+		},
+		{
+			name: "file over defaults",
+			file: "label = \"Promotions\"\nstore = \"bolt\"\npage_size = 0\ndry_run = true\n[confirm]\nbulk_unsubscribe = false\n",
+			check: func(t *testing.T, dir string, cfg Config) {
+				if cfg.Label != "Promotions" || cfg.PageSize != 0 || !cfg.DryRun || cfg.Confirm.BulkUnsubscribe {
+					t.Errorf("file settings not applied: %+v", cfg)
+				}
+				if cfg.Database != filepath.Join(dir, "chuckterm.bolt") {
+					t.Errorf("bolt database %q", cfg.Database)
+				}
+				if cfg.Sort != "count" {
+					t.Errorf("unset sort %q", cfg.Sort)
+				}
+			},
+		},
+		{
+			name: "relative paths",
+			file: "database = \"cache/mail.db\"\ncredentials = \"secret.json\"\n[accounts]\nwork = \"../work\"\n",
+			check: func(t *testing.T, dir string, cfg Config) {
+				if cfg.Database != filepath.Join(dir, "cache/mail.db") || cfg.Credentials != filepath.Join(dir, "secret.json") {
+					t.Errorf("database %q, credentials %q", cfg.Database, cfg.Credentials)
+				}
+				if cfg.Accounts["work"] != filepath.Join(dir, "../work") {
+					t.Errorf("account %q", cfg.Accounts["work"])
+				}
+			},
+		},
+		{
+			name: "environment over file",
+			file: "dry_run = false\nproxy = \"http://file.example:3128\"\nstore = \"bolt\"\n",
+			env:  map[string]string{"CHUCKTERM_DRY_RUN": "1", "CHUCKTERM_PROXY": "http://env.example:3128", "CHUCKTERM_STORE": "memory", "CHUCKTERM_DB": "env.db"},
+			check: func(t *testing.T, dir string, cfg Config) {
+				if !cfg.DryRun || cfg.Proxy != "http://env.example:3128" || cfg.Store != "memory" {
+					t.Errorf("environment not applied: dry run %v, proxy %q, store %q", cfg.DryRun, cfg.Proxy, cfg.Store)
+				}
+				if cfg.Database != filepath.Join(dir, "env.db") {
+					t.Errorf("database %q", cfg.Database)
+				}
+			},
+		},
+		{
+			name: "environment without file",
+			env:  map[string]string{"CHUCKTERM_READ_ONLY": "true", "CHUCKTERM_PROVIDER": "IMAP"},
+			check: func(t *testing.T, dir string, cfg Config) {
+				if !cfg.ReadOnly || cfg.Provider != "imap" {
+					t.Errorf("read only %v, provider %q", cfg.ReadOnly, cfg.Provider)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			if tc.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(tc.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg, err := readConfig(dir)
+			if err != nil {
+				t.Fatalf("readConfig: %v", err)
+			}
+			tc.check(t, dir, cfg)
+		})
+	}
+}
+
+func TestReadConfigInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name, file string
+		env        map[string]string
+		want       string
+	}{
+		{"syntax", "label = \n", nil, "config.toml"},
+		{"type", "workers = \"many\"\n", nil, "config.toml"},
+		{"store", "store = \"mongo\"\n", nil, `unknown store "mongo"`},
+		{"store from environment", "", map[string]string{"CHUCKTERM_STORE": "mongo"}, `unknown store "mongo"`},
+		{"boolean from environment", "", map[string]string{"CHUCKTERM_DRY_RUN": "maybe"}, "CHUCKTERM_DRY_RUN"},
+		{"auth", "auth = \"pigeon\"\n", nil, "pigeon"},
+		{"provider", "provider = \"pop3\"\n", nil, `unknown provider "pop3"`},
+		{"scan window", "scan_window = \"soon\"\n", nil, "scan_window"},
+		{"retention age", "[retention]\nmax_age = \"forever\"\n", nil, "[retention]"},
+		{"retention size", "[retention]\nmax_mb = -1\n", nil, "[retention]"},
+		{"daemon every", "[daemon]\nevery = \"never\"\n", nil, "[daemon] every"},
+		{"daemon maintain", "[daemon]\nmaintain_every = \"sometimes\"\n", nil, "[daemon] maintain_every"},
+		{"workers", "workers = 10000\n", nil, "workers must be from 1"},
+		{"list page size", "list_page_size = -1\n", nil, "list page size"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.toml")
+			if err := os.WriteFile(path, []byte(tc.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := readConfig(dir)
+			if err == nil {
+				t.Fatal("readConfig succeeded")
+			}
+			if !strings.HasPrefix(err.Error(), path) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %q; want it to start with the path and mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestFlagsOverConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name, file, env string
+		args            []string
+		want            bool
+	}{
+		{"default", "", "", nil, false},
+		{"file", "dry_run = true\n", "", nil, true},
+		{"environment", "dry_run = false\n", "1", nil, true},
+		{"flag over file", "dry_run = true\n", "", []string{"--dry-run=false"}, false},
+		{"flag over environment", "", "true", []string{"--dry-run=false"}, false},
+		{"flag", "", "", []string{"--dry-run"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			if tc.env != "" {
+				t.Setenv("CHUCKTERM_DRY_RUN", tc.env)
+			}
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(tc.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := readConfig(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			dryRun := dryRunFlag(fs, cfg)
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			if *dryRun != tc.want {
+				t.Errorf("dry run %v; want %v", *dryRun, tc.want)
+			}
+		})
+	}
+}
+
+func TestGlobalOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		args          []string
+		rest          string
+		configDir, db string
+		readOnly      bool
+		err           string
+	}{
+		{"none", []string{"sync", "--db", "x"}, "sync --db x", "", "", false, ""},
+		{"separate values", []string{"--config-dir", "work", "--db", "w.db", "groups"}, "groups", "work", "w.db", false, ""},
+		{"joined values", []string{"-config-dir=work", "--read-only", "sync"}, "sync", "work", "", true, ""},
+		{"read-only false", []string{"--read-only=false", "sync"}, "sync", "", "", false, ""},
+		{"stops at other flags", []string{"--db=a.db", "--label", "X"}, "--label X", "", "a.db", false, ""},
+		{"bad boolean", []string{"--read-only=maybe"}, "", "", "", false, `invalid boolean value "maybe"`},
+		{"missing value", []string{"--config-dir"}, "", "", "", false, "flag needs an argument: -config-dir"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			configDirOption, dbOption, readOnlyOption = "", "", false
+			t.Cleanup(func() { configDirOption, dbOption, readOnlyOption = "", "", false })
+			rest, err := globalOptions(tc.args)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("error %v; want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(rest, " "); got != tc.rest {
+				t.Errorf("rest %q; want %q", got, tc.rest)
+			}
+			if configDirOption != tc.configDir || dbOption != tc.db || readOnlyOption != tc.readOnly {
+				t.Errorf("config dir %q, db %q, read-only %v", configDirOption, dbOption, readOnlyOption)
+			}
+		})
+	}
+}
+
+func TestLoadConfigOptionsOverEnvironment(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	t.Setenv("CHUCKTERM_CONFIG_DIR", filepath.Join(t.TempDir(), "elsewhere"))
+	t.Setenv("CHUCKTERM_DB", "env.db")
+	configDirOption, dbOption, readOnlyOption = dir, filepath.Join(dir, "option.db"), true
+	t.Cleanup(func() { configDirOption, dbOption, readOnlyOption = "", "", false })
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte("read_only = false\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	gotDir, cfg := loadConfig()
+	if gotDir != dir {
+		t.Errorf("config dir %q; want --config-dir's %q", gotDir, dir)
+	}
+	if cfg.Database != dbOption {
+		t.Errorf("database %q; want --db's %q", cfg.Database, dbOption)
+	}
+	if !cfg.ReadOnly {
+		t.Error("--read-only did not win over read_only = false")
+	}
+}
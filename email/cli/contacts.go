@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chuckterm/internal/report"
+	"common/atomicfile"
+)
+
+// runContacts implements `chuckterm contacts [--format csv|vcard] [--out FILE]`:
+// it exports everyone who has sent mail in the cache as an address book.
+func runContacts(args []string) int {
+	fs := flag.NewFlagSet("contacts", flag.ExitOnError)
+	format := fs.String("format", "", "csv or vcard (default: from the --out extension, else csv)")
+	out := fs.String("out", "", "file to write; - or empty writes to stdout")
+	minCount := fs.Int("min", 1, "only export senders with at least this many messages")
+	fs.Parse(args)
+
+	if *format == "" {
+		*format = "csv"
+		if ext := strings.ToLower(filepath.Ext(*out)); ext == ".vcf" || ext == ".vcard" {
+			*format = "vcard"
+		}
+	}
+	write := map[string]func(*bytes.Buffer, []report.Contact) error{
+		"csv":   func(b *bytes.Buffer, c []report.Contact) error { return report.WriteContactsCSV(b, c) },
+		"vcard": func(b *bytes.Buffer, c []report.Contact) error { return report.WriteVCards(b, c) },
+	}[*format]
+	if write == nil {
+		fmt.Fprintf(os.Stderr, "contacts: unknown format %q (want csv or vcard)\n", *format)
+		return 2
+	}
+
+	_, cfg := loadConfig()
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	msgs, err := db.LoadAllMessages(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load messages: %v\n", err)
+		return 1
+	}
+
+	var contacts []report.Contact
+	for _, c := range report.Contacts(msgs) {
+		if c.Count >= *minCount {
+			contacts = append(contacts, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := write(&buf, contacts); err != nil {
+		fmt.Fprintf(os.Stderr, "contacts: %v\n", err)
+		return 1
+	}
+	if *out == "" || *out == "-" {
+		os.Stdout.Write(buf.Bytes())
+		return 0
+	}
+	if err := atomicfile.WriteFile(*out, buf.Bytes(), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "contacts: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d contacts to %s\n", len(contacts), *out)
+	return 0
+}
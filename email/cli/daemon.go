@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/mailbox"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+	"common/notify"
+	"common/scheduler"
+	"common/xdg"
+)
+
+// runDaemon implements `chuckterm daemon [--every 15m] [--install]`: it keeps
+// the cache fresh in the background so the TUI opens against current mail.
+func runDaemon(args []string) int {
+	configDir, cfg := loadConfig()
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	every := fs.String("every", cfg.Daemon.Every, "sync schedule: a duration, @hourly, @daily, \"@every 10m\" or a cron expression such as \"*/15 7-22 * * *\"")
+	jitter := fs.Duration("jitter", cfg.Daemon.Jitter, "random delay added to each sync")
+	maintainEvery := fs.String("maintain-every", cfg.Daemon.MaintainEvery, "when to check and compact the cache, as chuckterm db maintain does, in --every's forms, or off")
+	label := fs.String("label", cfg.Label, "Gmail label or IMAP folder to sync (same values as the TUI's --label)")
+	query := fs.String("query", cfg.Query, "Gmail search that narrows the sync within the label (as the TUI's --query)")
+	scanWindow := fs.String("scan-window", cfg.ScanWindow, "on a full scan, cache only mail this recent, such as 6mo or 2y")
+	tuning := syncTuningFlags(fs, cfg)
+	notifyNew := fs.Bool("notify", cfg.Notify, "show a desktop notification when a sync finds unread mail")
+	install := fs.Bool("install", false, "write a systemd user service (Linux) or launchd agent (macOS) running this command, then exit")
+	dryRun := dryRunFlag(fs, cfg)
+	fs.Parse(args)
+
+	schedule, err := scheduler.ParseSchedule(*every)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+		return 2
+	}
+	if _, err := gmail.WindowStart(*scanWindow, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "daemon: --scan-window: %v\n", err)
+		return 2
+	}
+	if err := tuning.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+		return 2
+	}
+	var maintainSchedule scheduler.Schedule
+	if *maintainEvery != "off" {
+		if maintainSchedule, err = scheduler.ParseSchedule(*maintainEvery); err != nil {
+			fmt.Fprintf(os.Stderr, "daemon: --maintain-every: %v\n", err)
+			return 2
+		}
+	}
+	if *install {
+		return installUnit(scheduler.Unit{Name: "chuckterm-sync", Description: "chuckterm background sync"})
+	}
+
+	autoLabels, err := gmail.LoadAutoLabelRules(filepath.Join(configDir, "autolabel.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load auto-label rules: %v\n", err)
+		return 1
+	}
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, closeAudit := dryRunContext(ctx, *dryRun, "daemon")
+	defer closeAudit()
+	p, svc, err := openProvider(ctx, configDir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+		return 1
+	}
+
+	opts := gmail.SyncOptions{Triage: gmail.NewTriage(autoLabels), Retention: cfg.retention(), Query: *query, Window: *scanWindow}
+	tuning.apply(&opts)
+	if *notifyNew {
+		n := notify.New("chuckterm")
+		opts.NewMessages = func(msgs []model.MessageRef) {
+			if title, body, ok := gmail.NewMailNotice(msgs); ok {
+				nctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				defer cancel()
+				n.Notify(nctx, title, body)
+			}
+		}
+	}
+	stateFile, err := scheduleState("chuckterm")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+		return 1
+	}
+	s := &scheduler.Scheduler{
+		StateFile: stateFile,
+		Logf:      logLine,
+		Jobs: []scheduler.Job{{
+			Name:     "sync",
+			Schedule: schedule,
+			Jitter:   *jitter,
+			Run: func(ctx context.Context) error {
+				if err := syncOnce(ctx, p, db, *label, opts); err != nil {
+					return err
+				}
+				if svc == nil {
+					return nil
+				}
+				_, rulesErr := applyRules(ctx, svc, db, logLine)
+				_, err := wakeSnoozes(ctx, svc, db, logLine)
+				return errors.Join(rulesErr, err)
+			},
+		}, {
+			// Cleanup jobs keep their own schedule in the rule log, so
+			// ones added or edited while the daemon runs are picked up.
+			Name:  "cleanup jobs",
+			Every: time.Minute,
+			Quiet: true,
+			Run: func(ctx context.Context) error {
+				if svc == nil {
+					return nil
+				}
+				return applyDueRules(ctx, svc, db, logLine)
+			},
+		}},
+	}
+	if maintainSchedule != nil {
+		// Jobs run one at a time, so nothing uses the store meanwhile.
+		s.Jobs = append(s.Jobs, scheduler.Job{
+			Name:     "maintenance",
+			Schedule: maintainSchedule,
+			Run: func(ctx context.Context) error {
+				return maintain(ctx, db, logLine)
+			},
+		})
+	}
+	logLine("syncing %s %s (Ctrl+C to stop)", *label, schedule)
+	if *dryRun {
+		logLine("dry run: rules, auto-labels, blocked senders and snoozes only log what they would do")
+	}
+	if err := s.Run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// syncOnce brings the cache up to date: incrementally when it was synced
+// before, otherwise by (resuming) a full scan.
+func syncOnce(ctx context.Context, p mailbox.Provider, db store.Store, label string, opts gmail.SyncOptions) error {
+	return mailbox.Sync(ctx, p, db, label, opts, nil)
+}
+
+// installUnit writes a service file that runs the current command line
+// (without --install) and prints how to enable it.
+func installUnit(u scheduler.Unit) int {
+	cmd, err := scheduler.CurrentCommand()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "install: %v\n", err)
+		return 1
+	}
+	u.Command = cmd
+	path, enable, err := scheduler.Install(u)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "install: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\nEnable it with:\n  %s\n", path, enable)
+	return 0
+}
+
+// scheduleState is where app's daemon records when each job last ran.
+func scheduleState(app string) (string, error) {
+	dir, err := xdg.StateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, app, "schedule.json"), nil
+}
+
+func logLine(format string, args ...any) {
+	fmt.Printf("%s %s\n", time.Now().Format(time.DateTime), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"chuckterm/internal/store"
+)
+
+// openStore opens the configured cache, encrypted with the database
+// passphrase if one is set. database_passphrase_command wins over the
+// passphrase itself, like the IMAP password_command.
+func openStore(ctx context.Context, cfg Config) (store.Store, error) {
+	passphrase := cfg.DatabasePassphrase
+	if cfg.DatabasePassphraseCommand != "" {
+		out, err := exec.CommandContext(ctx, "sh", "-c", cfg.DatabasePassphraseCommand).Output()
+		if err != nil {
+			return nil, fmt.Errorf("database_passphrase_command: %w", err)
+		}
+		passphrase = strings.TrimRight(string(out), "\r\n")
+	}
+	return store.Open(cfg.Store, cfg.Database, passphrase)
+}
+
+// spamDatabase is the cache spam review keeps next to the cache at path,
+// so that switching to the spam folder does not reset the usual one.
+func spamDatabase(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-spam" + ext
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"chuckterm/internal/store"
+)
+
+const dbUsage = `usage: chuckterm db maintain`
+
+// runDB implements `chuckterm db maintain`: it checks the cache's integrity,
+// then compacts it and refreshes its statistics, reporting the size before
+// and after. The daemon does the same on its own schedule.
+func runDB(args []string) int {
+	_, cfg := loadConfig()
+	fs := flag.NewFlagSet("db", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), dbUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || fs.Arg(0) != "maintain" {
+		fs.Usage()
+		return 2
+	}
+
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := maintain(ctx, db, func(format string, args ...any) { fmt.Printf(format+"\n", args...) }); err != nil {
+		fmt.Fprintf(os.Stderr, "db: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// maintain runs db's upkeep, if it has any, and reports it through logf.
+func maintain(ctx context.Context, db store.Store, logf func(format string, args ...any)) error {
+	mt, ok := db.(store.Maintainer)
+	if !ok {
+		logf("this store keeps nothing on disk to maintain")
+		return nil
+	}
+	m, err := mt.Maintain(ctx)
+	for _, p := range m.Problems {
+		logf("integrity: %s", p)
+	}
+	if err != nil {
+		return err
+	}
+	logf("integrity ok; %s -> %s", formatSize(m.SizeBefore), formatSize(m.SizeAfter))
+	return nil
+}
+
+// formatSize formats a byte count with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"chuckterm/internal/demo"
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/store"
+	"chuckterm/internal/tui"
+
+	"google.golang.org/api/option"
+)
+
+// startDemo serves a generated mailbox locally and points opts at it.
+// Messages live in memory, and downloads and calendar entries go to a
+// temporary directory, so the real cache, token and files are never
+// touched. The returned function stops the server and removes that
+// directory.
+func startDemo(opts *tui.Options) (*store.MemoryStore, func(), error) {
+	srv, err := demo.Start(time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	// Metered like Gmail, so the stats view shows the demo's API use.
+	metered := &http.Client{Transport: gmail.MeterTransport(http.DefaultTransport)}
+	svc, err := srv.Service(context.Background(), option.WithHTTPClient(metered))
+	if err != nil {
+		srv.Close()
+		return nil, nil, err
+	}
+	gmail.EnableBatch(svc, metered)
+	dir, err := os.MkdirTemp("", "chuckterm-demo-")
+	if err != nil {
+		srv.Close()
+		return nil, nil, err
+	}
+	gmail.SetUnsubscribeClient(srv.Client())
+	opts.Service = svc
+	opts.Demo = true
+	opts.DownloadDir = dir
+	opts.CalendarFile = filepath.Join(dir, "calendar.ics")
+	cleanup := func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+	return store.NewMemoryStore(), cleanup, nil
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"common/xdg"
+)
+
+// dryRunFlag adds --dry-run to fs, defaulting to dry_run in config.toml.
+func dryRunFlag(fs *flag.FlagSet, cfg Config) *bool {
+	return fs.Bool("dry-run", cfg.DryRun, "only log the archives, trashes, label changes, unsubscribes and snoozes that would happen, to stderr and the audit log")
+}
+
+// auditLogPath is where dry runs record what they would have done.
+func auditLogPath() (string, error) {
+	dir, err := xdg.StateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chuckterm", "audit.log"), nil
+}
+
+// openAuditLog returns a function that appends a timestamped line, tagged
+// with command, to the audit log, and one that closes the log. echo, if set,
+// also gets each line. A log that cannot be opened only warns.
+func openAuditLog(command string, echo func(line string)) (audit func(line string), closeLog func()) {
+	var f *os.File
+	path, err := auditLogPath()
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(path), 0o700); err == nil {
+			f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: cannot open audit log: %v\n", command, err)
+	}
+	var mu sync.Mutex
+	audit = func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if f != nil {
+			fmt.Fprintf(f, "%s %s: dry run: would %s\n", time.Now().Format(time.DateTime), command, line)
+		}
+		if echo != nil {
+			echo(line)
+		}
+	}
+	return audit, func() {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// dryRunContext returns ctx unchanged unless on, and otherwise a dry-run
+// context whose skipped actions go to stderr and the audit log. The
+// returned function closes the log.
+func dryRunContext(ctx context.Context, on bool, command string) (context.Context, func()) {
+	if !on {
+		return ctx, func() {}
+	}
+	audit, closeLog := openAuditLog(command, func(line string) {
+		fmt.Fprintf(os.Stderr, "%s: dry run: would %s\n", command, line)
+	})
+	return gmail.WithDryRun(ctx, audit), closeLog
+}
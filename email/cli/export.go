@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"chuckterm/internal/export"
+	"chuckterm/internal/gmail"
+)
+
+// runExport implements `chuckterm export --sender ADDR --out PATH`: it
+// downloads the raw messages of a sender and writes them as an mbox file or
+// a directory of .eml files, e.g. to keep a copy before trashing them.
+func runExport(args []string) int {
+	configDir, cfg := loadConfig()
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	sender := fs.String("sender", "", "address whose mail to export, or a domain given as @example.com (required)")
+	subject := fs.String("subject", "", "only messages with exactly this subject")
+	out := fs.String("out", "", "mbox file or .eml directory to write (required)")
+	format := fs.String("format", "", "mbox or eml (default: mbox for an --out ending in .mbox, else eml)")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	fs.Parse(args)
+	if *sender == "" || *out == "" {
+		fmt.Fprintln(os.Stderr, "export: --sender and --out are required")
+		return 2
+	}
+	f, err := export.ParseFormat(*format, *out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 2
+	}
+
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	groups, err := selectGroups(ctx, db, *sender, *subject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	var ids []string
+	for _, g := range groups {
+		ids = append(ids, g.MessageIDs...)
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(os.Stderr, "export: no cached messages from %s\n", *sender)
+		return 1
+	}
+	svc, err := gmailService(ctx, configDir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	w, err := export.Create(*out, f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	n, err := gmail.ExportMessages(ctx, svc, w, ids, nil)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		return printJSON("export", exportJSON{Format: string(f), Path: *out, Messages: n})
+	}
+	fmt.Printf("Exported %s from %s to %s\n", plural(n, "message"), *sender, *out)
+	return 0
+}
@@ -0,0 +1,637 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/mailbox"
+	"chuckterm/internal/model"
+	"chuckterm/internal/report"
+	"chuckterm/internal/store"
+	"common/atomicfile"
+)
+
+// The headless commands work on the same cache and token as the TUI, without
+// a terminal, so they can be scripted or run from cron. The first run still
+// needs an authorised token.json.
+
+// runSync implements `chuckterm sync`: one sync, as the daemon runs on a
+// schedule.
+func runSync(args []string) int {
+	configDir, cfg := loadConfig()
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	label := fs.String("label", cfg.Label, "Gmail label or IMAP folder to sync (same values as the TUI's --label)")
+	query := fs.String("query", cfg.Query, "Gmail search that narrows the sync within the label (as the TUI's --query)")
+	scanWindow := fs.String("scan-window", cfg.ScanWindow, "on a full scan, cache only mail this recent, such as 6mo or 2y")
+	extend := fs.String("extend", "", "after syncing, cache older mail too, back this far (such as 2y) or all of it")
+	tuning := syncTuningFlags(fs, cfg)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	dryRun := dryRunFlag(fs, cfg)
+	fs.Parse(args)
+	for name, window := range map[string]string{"scan-window": *scanWindow, "extend": *extend} {
+		if _, err := gmail.WindowStart(window, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "sync: --%s: %v\n", name, err)
+			return 2
+		}
+	}
+	if err := tuning.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+		return 2
+	}
+
+	autoLabels, err := gmail.LoadAutoLabelRules(filepath.Join(configDir, "autolabel.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load auto-label rules: %v\n", err)
+		return 1
+	}
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, closeAudit := dryRunContext(ctx, *dryRun, "sync")
+	defer closeAudit()
+	p, svc, err := openProvider(ctx, configDir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+		return 1
+	}
+	opts := gmail.SyncOptions{Triage: gmail.NewTriage(autoLabels), Retention: cfg.retention(), Query: *query, Window: *scanWindow}
+	tuning.apply(&opts)
+	if err := syncOnce(ctx, p, db, *label, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+		return 1
+	}
+	var extended int
+	if *extend != "" {
+		since, _ := gmail.WindowStart(*extend, time.Now())
+		if opts.Label, err = gmail.LabelScope(ctx, db); err == nil {
+			extended, err = p.Extend(ctx, db, opts, since, nil)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sync: extend: %v\n", err)
+			return 1
+		}
+	}
+	since, err := gmail.ScanSince(ctx, db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+		return 1
+	}
+	sinceDay := ""
+	if !since.IsZero() {
+		sinceDay = since.Local().Format(time.DateOnly)
+	}
+	logf := func(format string, args ...any) {
+		if !*asJSON {
+			fmt.Printf(format+"\n", args...)
+		}
+	}
+	var results []gmail.RuleResult
+	var woken int
+	code := 0
+	// Rules and snoozes act through Gmail.
+	if svc != nil {
+		var rulesErr error
+		if results, rulesErr = applyRules(ctx, svc, db, logf); rulesErr != nil {
+			fmt.Fprintf(os.Stderr, "sync: %v\n", rulesErr)
+			code = 1
+		}
+		if woken, err = wakeSnoozes(ctx, svc, db, logf); err != nil {
+			fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+			code = 1
+		}
+	}
+	n, err := db.CountMessages(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		return max(code, printJSON("sync", syncJSON{Label: *label, Query: *query, Messages: n, Since: sinceDay, Extended: extended, Rules: toRuleRunsJSON(results), Unsnoozed: woken, DryRun: *dryRun}))
+	}
+	scope := *label
+	if *query != "" {
+		scope += " matching " + *query
+	}
+	if *extend != "" {
+		fmt.Printf("Extended the scan window: %s added\n", plural(extended, "older message"))
+	}
+	if sinceDay != "" {
+		fmt.Printf("Synced %s: %d messages since %s cached\n", scope, n, sinceDay)
+		return code
+	}
+	fmt.Printf("Synced %s: %d messages cached\n", scope, n)
+	return code
+}
+
+// runGroups implements `chuckterm groups`: the groups view as a table.
+func runGroups(args []string) int {
+	_, cfg := loadConfig()
+	fs := flag.NewFlagSet("groups", flag.ExitOnError)
+	sortOrder := fs.String("sort", cfg.Sort, "groups order: count, newest, oldest, sender or size")
+	subjects := fs.String("subjects", cfg.Subjects, "subject grouping: exact, normalized or fuzzy")
+	sender := fs.String("sender", "", "only groups from this address, or from a domain given as @example.com")
+	minCount := fs.Int("min", 1, "only groups with at least this many messages")
+	top := fs.Int("top", 0, "number of groups to print (0 = all)")
+	asJSON := fs.Bool("json", false, "print the groups as a JSON array")
+	asCSV := fs.Bool("csv", false, "print the groups as CSV, for spreadsheets")
+	out := fs.String("out", "", "file to write instead of stdout")
+	fs.Parse(args)
+
+	if *asJSON && *asCSV {
+		fmt.Fprintln(os.Stderr, "groups: --json and --csv cannot be combined")
+		return 2
+	}
+	order, err := gmail.ParseGroupOrder(*sortOrder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	subjectGrouping, err := gmail.ParseSubjectGrouping(*subjects)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	groups, err := loadGroups(context.Background(), db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load groups: %v\n", err)
+		return 1
+	}
+	groups = subjectGrouping.Apply(groups)
+	gmail.SortGroupsBy(groups, order)
+
+	var rows []model.SenderGroup
+	for _, g := range groups {
+		if g.Count >= *minCount && (*sender == "" || matchSender(g.Email, *sender)) {
+			rows = append(rows, g)
+		}
+	}
+	if *top > 0 && len(rows) > *top {
+		rows = rows[:*top]
+	}
+	var buf bytes.Buffer
+	switch {
+	case *asJSON:
+		list := make([]groupJSON, len(rows))
+		for i, g := range rows {
+			list[i] = toGroupJSON(g)
+		}
+		err = encodeJSON(&buf, list)
+	case *asCSV:
+		err = report.WriteGroupsCSV(&buf, rows)
+	default:
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MESSAGES\tUNREAD\tSIZE\tLAST\tUNSUBSCRIBE\tSENDER\tSUBJECT")
+		for _, g := range rows {
+			kind := report.UnsubscribeKind(g)
+			if g.Unsubscribed != nil {
+				kind += " ✓"
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n", g.Count, g.Unread, formatSize(g.Size), shortDate(g.LastDate), kind, g.Email, g.Subject)
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "groups: %v\n", err)
+		return 1
+	}
+	if *out == "" || *out == "-" {
+		os.Stdout.Write(buf.Bytes())
+		return 0
+	}
+	if err := atomicfile.WriteFile(*out, buf.Bytes(), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "groups: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s to %s\n", plural(len(rows), "group"), *out)
+	return 0
+}
+
+// runArchive implements `chuckterm archive --sender ADDR [--subject S]`.
+func runArchive(args []string) int {
+	return runRemove("archive", "Archived", mailbox.Provider.Archive, args)
+}
+
+// runTrash implements `chuckterm trash --sender ADDR [--subject S]`.
+func runTrash(args []string) int {
+	return runRemove("trash", "Trashed", mailbox.Provider.Trash, args)
+}
+
+// runRemove archives or trashes every cached message of the selected groups
+// and drops them from the cache, as the TUI's a and d do; archived mail the
+// cache's scope still holds stays.
+func runRemove(name, done string, remove func(mailbox.Provider, context.Context, []string) error, args []string) int {
+	configDir, cfg := loadConfig()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	sender := fs.String("sender", "", "address whose mail to "+name+", or a domain given as @example.com (required)")
+	subject := fs.String("subject", "", "only messages with exactly this subject")
+	includeProtected := fs.Bool("include-protected", false, "also "+name+" the mail of protected senders")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	dryRun := dryRunFlag(fs, cfg)
+	fs.Parse(args)
+	if *sender == "" {
+		fmt.Fprintf(os.Stderr, "%s: --sender is required\n", name)
+		return 2
+	}
+	if refuseReadOnly(cfg, *dryRun, name) {
+		return 1
+	}
+
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, closeAudit := dryRunContext(ctx, *dryRun, name)
+	defer closeAudit()
+	open := func(ctx context.Context) (mailbox.Provider, error) {
+		p, _, err := openProvider(ctx, configDir, cfg)
+		return p, err
+	}
+	return removeGroups(ctx, db, open, name, done, remove, removeRequest{
+		sender: *sender, subject: *subject, includeProtected: *includeProtected, asJSON: *asJSON,
+	})
+}
+
+// removeRequest is what runRemove was asked to remove.
+type removeRequest struct {
+	sender, subject  string
+	includeProtected bool
+	asJSON           bool
+}
+
+// removeGroups does the work of runRemove on db, signing in with open only
+// once there is cached mail to remove, and returns the exit code.
+func removeGroups(ctx context.Context, db store.Store, open func(context.Context) (mailbox.Provider, error), name, done string, remove func(mailbox.Provider, context.Context, []string) error, r removeRequest) int {
+	groups, err := selectGroups(ctx, db, r.sender, r.subject)
+	skipped := 0
+	if err == nil && !r.includeProtected {
+		groups, skipped, err = dropProtected(ctx, db, groups)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "%s: skipping %s of protected senders (see --include-protected)\n", name, plural(skipped, "group"))
+	}
+	var ids []string
+	for _, g := range groups {
+		ids = append(ids, g.MessageIDs...)
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: no cached messages from %s\n", name, r.sender)
+		return 1
+	}
+	p, err := open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	if err := remove(p, ctx, ids); errors.Is(err, gmail.ErrDryRun) {
+		if r.asJSON {
+			return printJSON(name, actionJSON{Action: name, Sender: r.sender, Subject: r.subject, Messages: len(ids), IDs: ids, Protected: skipped, DryRun: true})
+		}
+		fmt.Printf("Would %s %s from %s (dry run)\n", name, plural(len(ids), "message"), r.sender)
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	if name == "trash" {
+		// Remembered for the TUI's trash view, which can restore them.
+		msgs, err := db.GetMessagesByIDs(ctx, ids)
+		if err == nil {
+			err = gmail.RememberTrashed(ctx, db, msgs, time.Now())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: remember trashed mail: %v\n", name, err)
+		}
+	}
+	// Archived mail stays cached when the cache's scope still holds it.
+	if name == "archive" {
+		err = gmail.CacheLabelRemoved(ctx, db, ids, "INBOX")
+	} else {
+		err = db.DeleteMessages(ctx, ids)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: update cache: %v\n", name, err)
+		return 1
+	}
+	gmail.RecordAction(ctx, db, model.Action{Kind: name, Sender: r.sender, Subject: r.subject, Messages: len(ids)})
+	if r.asJSON {
+		return printJSON(name, actionJSON{Action: name, Sender: r.sender, Subject: r.subject, Messages: len(ids), IDs: ids, Protected: skipped})
+	}
+	fmt.Printf("%s %s from %s\n", done, plural(len(ids), "message"), r.sender)
+	return 0
+}
+
+// runUnsubscribe implements `chuckterm unsubscribe --sender ADDR`: one-click
+// unsubscribes are sent, and other links are printed, or opened with --open.
+// Senders with only a mailto: link are sent the email with --mailto.
+func runUnsubscribe(args []string) int {
+	configDir, cfg := loadConfig()
+	fs := flag.NewFlagSet("unsubscribe", flag.ExitOnError)
+	sender := fs.String("sender", "", "address to unsubscribe from, or a domain given as @example.com (required)")
+	subject := fs.String("subject", "", "only use the link from groups with exactly this subject")
+	open := fs.Bool("open", false, "open links without one-click support in the browser")
+	mailto := fs.Bool("mailto", false, "send the unsubscribe email to senders with only a mailto: link")
+	again := fs.Bool("again", false, "also unsubscribe from senders already unsubscribed from")
+	includeProtected := fs.Bool("include-protected", false, "also unsubscribe from protected senders")
+	asJSON := fs.Bool("json", false, "print the result for each link as a JSON array")
+	dryRun := dryRunFlag(fs, cfg)
+	fs.Parse(args)
+	if *sender == "" {
+		fmt.Fprintln(os.Stderr, "unsubscribe: --sender is required")
+		return 2
+	}
+	if refuseReadOnly(cfg, *dryRun, "unsubscribe") {
+		return 1
+	}
+
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, closeAudit := dryRunContext(ctx, *dryRun, "unsubscribe")
+	defer closeAudit()
+	groups, err := selectGroups(ctx, db, *sender, *subject)
+	skipped := 0
+	if err == nil && !*includeProtected {
+		groups, skipped, err = dropProtected(ctx, db, groups)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unsubscribe: %v\n", err)
+		return 1
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "unsubscribe: skipping %s of protected senders (see --include-protected)\n", plural(skipped, "group"))
+	}
+	if !*again {
+		var done []*model.Unsubscription
+		groups = slices.DeleteFunc(groups, func(g model.SenderGroup) bool {
+			if u := g.Unsubscribed; u != nil && !slices.ContainsFunc(done, func(d *model.Unsubscription) bool { return d.Sender == u.Sender }) {
+				done = append(done, u)
+			}
+			return g.Unsubscribed != nil
+		})
+		for _, u := range done {
+			fmt.Fprintf(os.Stderr, "unsubscribe: skipping %s, unsubscribed on %s (%s); see --again\n", u.Sender, u.Time.Format(time.DateOnly), u.Method)
+		}
+		if len(groups) == 0 && len(done) > 0 {
+			return 0
+		}
+	}
+	outcomes := gmail.BulkUnsubscribe(ctx, groups, nil)
+	emails := mailtoOnly(groups)
+	if len(outcomes)+len(emails) == 0 {
+		fmt.Fprintf(os.Stderr, "unsubscribe: no unsubscribe link cached for %s\n", *sender)
+		return 1
+	}
+	code := 0
+	results := make([]unsubscribeOutcomeJSON, 0, len(outcomes)+len(emails))
+	if len(emails) > 0 && *mailto {
+		svc, err := gmailService(ctx, configDir, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unsubscribe: %v\n", err)
+			return 1
+		}
+		for _, g := range emails {
+			res := unsubscribeOutcomeJSON{Sender: g.Email, URL: g.UnsubscribeMailto, Method: string(gmail.UnsubscribeMailto)}
+			if err := gmail.MailtoUnsubscribe(ctx, svc, g.UnsubscribeMailto); errors.Is(err, gmail.ErrDryRun) {
+				res.DryRun = true
+				if !*asJSON {
+					fmt.Printf("%s: would send an email to %s (dry run)\n", g.Email, strings.TrimPrefix(g.UnsubscribeMailto, "mailto:"))
+				}
+			} else if err != nil {
+				res.Error = err.Error()
+				code = 1
+				if !*asJSON {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", g.Email, err)
+				}
+			} else {
+				gmail.RecordUnsubscribe(ctx, db, g.Email, gmail.UnsubscribeMailto, g.UnsubscribeMailto)
+				if !*asJSON {
+					fmt.Printf("%s: unsubscribed (email sent to %s)\n", g.Email, strings.TrimPrefix(g.UnsubscribeMailto, "mailto:"))
+				}
+			}
+			results = append(results, res)
+		}
+	} else {
+		for _, g := range emails {
+			results = append(results, unsubscribeOutcomeJSON{Sender: g.Email, URL: g.UnsubscribeMailto, Method: "email"})
+			if !*asJSON {
+				fmt.Printf("%s: send an email to %s, or rerun with --mailto\n", g.Email, strings.TrimPrefix(g.UnsubscribeMailto, "mailto:"))
+			}
+		}
+	}
+	for _, o := range outcomes {
+		results = append(results, toUnsubscribeOutcomeJSON(o))
+		i := len(results) - 1
+		switch {
+		case results[i].DryRun:
+			if !*asJSON {
+				fmt.Printf("%s: would unsubscribe (one-click, dry run)\n", o.Sender)
+			}
+		case o.Method == gmail.UnsubscribeOneClick:
+			gmail.RecordUnsubscribe(ctx, db, o.Sender, o.Method, o.URL)
+			if !*asJSON {
+				fmt.Printf("%s: unsubscribed (one-click)\n", o.Sender)
+			}
+		case *open:
+			if err := gmail.BrowseUnsubscribe(ctx, o.URL); errors.Is(err, gmail.ErrDryRun) {
+				results[i].DryRun = true
+				if !*asJSON {
+					fmt.Printf("%s: would open %s (dry run)\n", o.Sender, o.URL)
+				}
+				continue
+			} else if err != nil {
+				results[i].Error = err.Error()
+				code = 1
+				if !*asJSON {
+					fmt.Fprintf(os.Stderr, "%s: open %s: %v\n", o.Sender, o.URL, err)
+				}
+				continue
+			}
+			results[i].Method = "opened"
+			gmail.RecordUnsubscribe(ctx, db, o.Sender, gmail.UnsubscribeBrowser, o.URL)
+			if !*asJSON {
+				fmt.Printf("%s: opened %s\n", o.Sender, o.URL)
+			}
+		case !*asJSON:
+			if o.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: one-click failed: %v\n", o.Sender, o.Err)
+			}
+			fmt.Printf("%s: open %s\n", o.Sender, o.URL)
+		}
+	}
+	if *asJSON && printJSON("unsubscribe", results) != 0 {
+		return 1
+	}
+	return code
+}
+
+// runMessages implements `chuckterm messages --sender ADDR`: the cached
+// messages from a sender, newest first.
+func runMessages(args []string) int {
+	_, cfg := loadConfig()
+	fs := flag.NewFlagSet("messages", flag.ExitOnError)
+	sender := fs.String("sender", "", "address whose messages to list, or a domain given as @example.com (required)")
+	subject := fs.String("subject", "", "only messages with exactly this subject")
+	top := fs.Int("top", 0, "number of messages to print (0 = all)")
+	asJSON := fs.Bool("json", false, "print the messages as a JSON array")
+	fs.Parse(args)
+	if *sender == "" {
+		fmt.Fprintln(os.Stderr, "messages: --sender is required")
+		return 2
+	}
+
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	all, err := db.LoadAllMessages(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load messages: %v\n", err)
+		return 1
+	}
+	var msgs []model.MessageRef
+	for _, m := range all {
+		if matchSender(m.From, *sender) && (*subject == "" || m.Subject == *subject) {
+			msgs = append(msgs, m)
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].DateRFC3339 > msgs[j].DateRFC3339 })
+	if *top > 0 && len(msgs) > *top {
+		msgs = msgs[:*top]
+	}
+	if *asJSON {
+		out := make([]messageJSON, len(msgs))
+		for i, m := range msgs {
+			out[i] = toMessageJSON(m)
+		}
+		return printJSON("messages", out)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tUNREAD\tFROM\tSUBJECT")
+	for _, m := range msgs {
+		unread := ""
+		if m.Unread() {
+			unread = "•"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shortDate(m.DateRFC3339), unread, m.From, m.Subject)
+	}
+	w.Flush()
+	return 0
+}
+
+// printJSON writes v with writeJSON and returns the exit code.
+func printJSON(name string, v any) int {
+	if err := writeJSON(v); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// loadGroups returns the cached sender+subject groups, marking the senders
+// unsubscribed from.
+func loadGroups(ctx context.Context, db store.Store) ([]model.SenderGroup, error) {
+	groups, err := gmail.LoadGroupsFromDB(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	unsubs, err := gmail.LoadUnsubscribes(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	gmail.ApplyUnsubscribes(groups, unsubs)
+	return groups, nil
+}
+
+// mailtoOnly returns the groups with a mailto: unsubscribe link but no HTTP
+// one, once per link.
+func mailtoOnly(groups []model.SenderGroup) []model.SenderGroup {
+	var out []model.SenderGroup
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		if g.UnsubscribeURL == "" && g.UnsubscribeMailto != "" && !seen[g.UnsubscribeMailto] {
+			seen[g.UnsubscribeMailto] = true
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// selectGroups returns the exact sender+subject groups from sender, matched
+// like matchSender, and with subject unless it is "".
+func selectGroups(ctx context.Context, db store.Store, sender, subject string) ([]model.SenderGroup, error) {
+	groups, err := loadGroups(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.SenderGroup
+	for _, g := range groups {
+		if matchSender(g.Email, sender) && (subject == "" || g.Subject == subject) {
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+// matchSender reports whether email is sender, ignoring case. A sender
+// starting with @ matches the whole domain.
+func matchSender(email, sender string) bool {
+	if strings.HasPrefix(sender, "@") {
+		return strings.HasSuffix(strings.ToLower(email), strings.ToLower(sender))
+	}
+	return strings.EqualFold(email, sender)
+}
+
+// shortDate returns the day of an RFC 3339 date.
+func shortDate(rfc3339 string) string {
+	if len(rfc3339) >= 10 {
+		return rfc3339[:10]
+	}
+	return rfc3339
+}
+
+// plural formats n with noun, adding an s unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
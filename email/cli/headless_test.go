@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/mailbox"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+)
+
+// captureStdout runs f and returns what it printed to stdout along with its
+// result.
+func captureStdout(t *testing.T, f func() int) (string, int) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	code := f()
+	w.Close()
+	return <-out, code
+}
+
+// testConfig points loadConfig at an empty config directory and returns
+// the path of the cache file it uses.
+func testConfig(t *testing.T) string {
+	t.Helper()
+	clearEnv(t)
+	dir := t.TempDir()
+	db := filepath.Join(dir, "chuckterm.db")
+	t.Setenv("CHUCKTERM_CONFIG_DIR", dir)
+	t.Setenv("CHUCKTERM_DB", db)
+	return db
+}
+
+func TestHeadlessFlagValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		run  func([]string) int
+		args []string
+		env  string
+		want int
+	}{
+		{"archive without --sender", runArchive, nil, "", 2},
+		{"trash without --sender", runTrash, []string{"--subject", "Sale"}, "", 2},
+		{"unsubscribe without --sender", runUnsubscribe, nil, "", 2},
+		{"messages without --sender", runMessages, nil, "", 2},
+		{"groups --json --csv", runGroups, []string{"--json", "--csv"}, "", 2},
+		{"groups unknown sort", runGroups, []string{"--sort", "loudest"}, "", 2},
+		{"sync bad scan window", runSync, []string{"--scan-window", "soon"}, "", 2},
+		{"sync bad extend", runSync, []string{"--extend", "6 parsecs"}, "", 2},
+		{"archive read-only", runArchive, []string{"--sender", "a@x.example"}, "CHUCKTERM_READ_ONLY", 1},
+		{"unsubscribe read-only", runUnsubscribe, []string{"--sender", "a@x.example"}, "CHUCKTERM_READ_ONLY", 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := testConfig(t)
+			if tc.env != "" {
+				t.Setenv(tc.env, "1")
+			}
+			if got := tc.run(tc.args); got != tc.want {
+				t.Errorf("exit code %d; want %d", got, tc.want)
+			}
+			// Each is refused before the cache is opened.
+			if _, err := os.Stat(db); !os.IsNotExist(err) {
+				t.Errorf("cache opened: %v", err)
+			}
+		})
+	}
+}
+
+// fakeProvider records what it was asked to archive or trash. Its other
+// methods are not called by removeGroups.
+type fakeProvider struct {
+	mailbox.Provider
+	archived, trashed []string
+}
+
+func (p *fakeProvider) Archive(ctx context.Context, ids []string) error {
+	if gmail.SkipDryRun(ctx, "archive %s", gmail.DescribeIDs(ids)) {
+		return gmail.ErrDryRun
+	}
+	p.archived = append(p.archived, ids...)
+	return nil
+}
+
+func (p *fakeProvider) Trash(ctx context.Context, ids []string) error {
+	if gmail.SkipDryRun(ctx, "trash %s", gmail.DescribeIDs(ids)) {
+		return gmail.ErrDryRun
+	}
+	p.trashed = append(p.trashed, ids...)
+	return nil
+}
+
+// removeTestStore is a cache of inbox mail from two shop senders and a
+// protected bank, synced from scope.
+func removeTestStore(t *testing.T, scope string) *store.MemoryStore {
+	t.Helper()
+	db := store.NewMemoryStore()
+	ctx := context.Background()
+	if _, err := gmail.EnsureLabelScope(ctx, db, scope); err != nil {
+		t.Fatal(err)
+	}
+	inbox := []string{"INBOX"}
+	if err := db.UpsertMessages(ctx, []model.MessageRef{
+		{ID: "n1", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-01-01T00:00:00Z", LabelIDs: inbox},
+		{ID: "n2", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-01-02T00:00:00Z", LabelIDs: inbox},
+		{ID: "d1", From: "deals@shop.example", Subject: "Deals", DateRFC3339: "2024-01-03T00:00:00Z", LabelIDs: inbox},
+		{ID: "b1", From: "bank@bank.example", Subject: "Statement", DateRFC3339: "2024-01-04T00:00:00Z", LabelIDs: inbox},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSenderStatus(ctx, "bank@bank.example", model.SenderProtected); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func cachedIDs(t *testing.T, db store.Store) string {
+	t.Helper()
+	msgs, err := db.LoadAllMessages(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	slices.Sort(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestRemoveGroups(t *testing.T) {
+	archive := mailbox.Provider.Archive
+	trash := mailbox.Provider.Trash
+	for _, tc := range []struct {
+		name      string
+		action    string
+		req       removeRequest
+		dryRun    bool
+		want      int
+		out       string
+		removed   string // IDs the provider was asked to remove
+		cached    string // IDs left in the cache
+		audit     string
+		signIn    bool
+		trashView string // IDs remembered for the trash view
+	}{
+		{
+			name: "archive", action: "archive", req: removeRequest{sender: "news@shop.example"},
+			want: 0, out: "Archived 2 messages from news@shop.example\n",
+			removed: "n1,n2", cached: "b1,d1", signIn: true,
+		},
+		{
+			name: "trash a domain", action: "trash", req: removeRequest{sender: "@shop.example"},
+			want: 0, out: "Trashed 3 messages from @shop.example\n",
+			removed: "d1,n1,n2", cached: "b1", signIn: true, trashView: "d1,n1,n2",
+		},
+		{
+			name: "dry run", action: "archive", req: removeRequest{sender: "deals@shop.example"}, dryRun: true,
+			want: 0, out: "Would archive 1 message from deals@shop.example (dry run)\n",
+			cached: "b1,d1,n1,n2", audit: "archive 1 message: d1", signIn: true,
+		},
+		{
+			name: "subject", action: "trash", req: removeRequest{sender: "@shop.example", subject: "Deals"},
+			want: 0, out: "Trashed 1 message from @shop.example\n",
+			removed: "d1", cached: "b1,n1,n2", signIn: true, trashView: "d1",
+		},
+		{
+			name: "protected", action: "trash", req: removeRequest{sender: "bank@bank.example"},
+			want: 1, cached: "b1,d1,n1,n2",
+		},
+		{
+			name: "include protected", action: "trash", req: removeRequest{sender: "bank@bank.example", includeProtected: true},
+			want: 0, out: "Trashed 1 message from bank@bank.example\n",
+			removed: "b1", cached: "d1,n1,n2", signIn: true, trashView: "b1",
+		},
+		{
+			name: "no mail", action: "archive", req: removeRequest{sender: "nobody@x.example"},
+			want: 1, cached: "b1,d1,n1,n2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := removeTestStore(t, "INBOX")
+			p := &fakeProvider{}
+			signedIn := false
+			open := func(context.Context) (mailbox.Provider, error) {
+				signedIn = true
+				return p, nil
+			}
+			ctx := context.Background()
+			var audit []string
+			if tc.dryRun {
+				ctx = gmail.WithDryRun(ctx, func(line string) { audit = append(audit, line) })
+			}
+			remove, done := archive, "Archived"
+			if tc.action == "trash" {
+				remove, done = trash, "Trashed"
+			}
+			out, code := captureStdout(t, func() int {
+				return removeGroups(ctx, db, open, tc.action, done, remove, tc.req)
+			})
+			if code != tc.want {
+				t.Errorf("exit code %d; want %d", code, tc.want)
+			}
+			if out != tc.out {
+				t.Errorf("printed %q; want %q", out, tc.out)
+			}
+			removed := append(p.archived, p.trashed...)
+			slices.Sort(removed)
+			if got := strings.Join(removed, ","); got != tc.removed {
+				t.Errorf("removed %q; want %q", got, tc.removed)
+			}
+			if got := cachedIDs(t, db); got != tc.cached {
+				t.Errorf("cache holds %q; want %q", got, tc.cached)
+			}
+			if got := strings.Join(audit, "\n"); got != tc.audit {
+				t.Errorf("audit %q; want %q", got, tc.audit)
+			}
+			if signedIn != tc.signIn {
+				t.Errorf("signed in %v; want %v", signedIn, tc.signIn)
+			}
+			trashed, err := db.LoadTrashed(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, m := range trashed {
+				ids = append(ids, m.ID)
+			}
+			slices.Sort(ids)
+			if got := strings.Join(ids, ","); got != tc.trashView {
+				t.Errorf("trash view holds %q; want %q", got, tc.trashView)
+			}
+		})
+	}
+}
+
+func TestRemoveGroupsKeepsArchivedMailInScope(t *testing.T) {
+	db := removeTestStore(t, gmail.AllMail)
+	ctx := context.Background()
+	open := func(context.Context) (mailbox.Provider, error) { return &fakeProvider{}, nil }
+	_, code := captureStdout(t, func() int {
+		return removeGroups(ctx, db, open, "archive", "Archived", mailbox.Provider.Archive, removeRequest{sender: "news@shop.example"})
+	})
+	if code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	if got := cachedIDs(t, db); got != "b1,d1,n1,n2" {
+		t.Errorf("cache holds %q; archived mail should stay cached under all mail", got)
+	}
+	msgs, err := db.GetMessagesByIDs(ctx, []string{"n1"})
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("GetMessagesByIDs: %v, %v", msgs, err)
+	}
+	if slices.Contains(msgs[0].LabelIDs, "INBOX") {
+		t.Errorf("n1 still labelled INBOX: %v", msgs[0].LabelIDs)
+	}
+}
+
+func TestGroupsTable(t *testing.T) {
+	path := testConfig(t)
+	db, err := store.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpsertMessages(context.Background(), []model.MessageRef{
+		{ID: "n1", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-01-01T00:00:00Z", SizeBytes: 2048},
+		{ID: "n2", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-01-02T00:00:00Z", SizeBytes: 1024},
+		{ID: "b1", From: "bank@bank.example", Subject: "Statement", DateRFC3339: "2024-01-04T00:00:00Z", SizeBytes: 300},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	out, code := captureStdout(t, func() int { return runGroups([]string{"--sort", "size"}) })
+	if code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("printed %q; want a header and two groups", out)
+	}
+	for i, want := range [][]string{
+		{"MESSAGES", "UNREAD", "SIZE", "LAST"},
+		{"2", "0", "3.0 KiB", "news@shop.example"},
+		{"1", "0", "300 B", "bank@bank.example"},
+	} {
+		for _, field := range want {
+			if !strings.Contains(lines[i], field) {
+				t.Errorf("line %d %q lacks %q", i, lines[i], field)
+			}
+		}
+	}
+}
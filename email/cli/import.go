@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"chuckterm/internal/emlimport"
+	"chuckterm/internal/gmail"
+)
+
+// runImport implements `chuckterm import --dir DIR [--watch] [--gmail]`.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := fs.String("dir", "", "directory to read .eml files from; processed files move to imported/, unparseable ones to failed/")
+	watch := fs.Bool("watch", false, "keep running and import files as they are dropped into --dir")
+	interval := fs.Duration("interval", 2*time.Second, "polling interval for --watch")
+	toGmail := fs.Bool("gmail", false, "also import each message into Gmail (Users.Messages.Import) with INBOX and UNREAD labels")
+	fs.Parse(args)
+
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "import: --dir is required")
+		return 2
+	}
+
+	configDir, cfg := loadConfig()
+	if *toGmail && refuseReadOnly(cfg, false, "import --gmail") {
+		return 1
+	}
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt)
+	defer stop()
+
+	var importer emlimport.Importer
+	if *toGmail {
+		svc, err := gmail.NewService(ctx, configDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+			return 1
+		}
+		importer = func(ctx context.Context, raw []byte) (string, error) {
+			return gmail.ImportMessage(ctx, svc, raw)
+		}
+	}
+
+	report := func(res emlimport.Result, err error) {
+		if res.Imported > 0 {
+			fmt.Printf("%s imported %d message(s)\n", time.Now().Format(time.DateTime), res.Imported)
+		}
+		for _, f := range res.Failed {
+			fmt.Fprintf(os.Stderr, "failed: %s\n", f)
+		}
+		for _, f := range res.Retry {
+			fmt.Fprintf(os.Stderr, "will retry: %s\n", f)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		}
+	}
+
+	if *watch {
+		fmt.Printf("Watching %s for .eml files (Ctrl+C to stop)\n", *dir)
+		emlimport.Watch(ctx, *dir, *interval, db, importer, report)
+		return 0
+	}
+	res, err := emlimport.ImportDir(ctx, *dir, db, importer)
+	report(res, err)
+	if err != nil || len(res.Failed) > 0 || len(res.Retry) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+)
+
+// The --json output of the headless commands. Field names are part of the
+// command line interface: add fields, but do not rename or remove them.
+
+type groupJSON struct {
+	Sender      string           `json:"sender"`
+	Name        string           `json:"name,omitempty"`
+	Subject     string           `json:"subject"`
+	Messages    int              `json:"messages"`
+	Unread      int              `json:"unread"`
+	SizeBytes   int64            `json:"size_bytes"`
+	FirstDate   string           `json:"first_date,omitempty"`
+	LastDate    string           `json:"last_date,omitempty"`
+	Unsubscribe *unsubscribeJSON `json:"unsubscribe,omitempty"`
+	// Unsubscribed is set once chuckterm unsubscribed from the sender.
+	Unsubscribed *unsubscribedJSON `json:"unsubscribed,omitempty"`
+}
+
+type unsubscribedJSON struct {
+	Time   string `json:"time"`
+	Method string `json:"method"`
+}
+
+type unsubscribeJSON struct {
+	URL      string `json:"url"`
+	OneClick bool   `json:"one_click"`
+}
+
+type messageJSON struct {
+	ID      string   `json:"id"`
+	From    string   `json:"from"`
+	Name    string   `json:"name,omitempty"`
+	Subject string   `json:"subject"`
+	Date    string   `json:"date"`
+	Unread  bool     `json:"unread"`
+	Labels  []string `json:"labels"`
+	Snippet string   `json:"snippet,omitempty"`
+}
+
+type syncJSON struct {
+	Label    string `json:"label"`
+	Query    string `json:"query,omitempty"`
+	Messages int    `json:"messages"`
+	// Since is the first day of the scan window, if the cache has one, and
+	// Extended counts the older messages --extend added.
+	Since    string `json:"since,omitempty"`
+	Extended int    `json:"extended,omitempty"`
+	// Rules lists the rules that changed messages or failed after the sync.
+	Rules []ruleRunJSON `json:"rules"`
+	// Unsnoozed counts the snoozed messages put back in the inbox.
+	Unsnoozed int `json:"unsnoozed"`
+	// DryRun is set when the rules and snoozes only logged their changes.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+type snoozeJSON struct {
+	ID      string `json:"id"`
+	Until   string `json:"until"`
+	Sender  string `json:"sender"`
+	Subject string `json:"subject"`
+}
+
+type ruleJSON struct {
+	ID      int64  `json:"id"`
+	Rule    string `json:"rule"`
+	Enabled bool   `json:"enabled"`
+	Every   string `json:"every,omitempty"`
+}
+
+type ruleLogJSON struct {
+	ID       int64  `json:"id"`
+	Time     string `json:"time"`
+	Rule     string `json:"rule"`
+	Messages int    `json:"messages"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ruleRunJSON struct {
+	ID       int64  `json:"id"`
+	Rule     string `json:"rule"`
+	Messages int    `json:"messages"`
+	Error    string `json:"error,omitempty"`
+	// DryRun is set when Messages would have been changed and were not.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// actionJSON is the result of archive and trash.
+type actionJSON struct {
+	Action   string   `json:"action"`
+	Sender   string   `json:"sender"`
+	Subject  string   `json:"subject,omitempty"`
+	Messages int      `json:"messages"`
+	IDs      []string `json:"ids"`
+	// Protected counts the groups of protected senders left alone.
+	Protected int `json:"skipped_protected"`
+	// DryRun is set when nothing was changed; Messages are those that
+	// would have been.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+type senderJSON struct {
+	Match  string `json:"match"`
+	Status string `json:"status"`
+}
+
+type exportJSON struct {
+	Format   string `json:"format"`
+	Path     string `json:"path"`
+	Messages int    `json:"messages"`
+}
+
+type unsubscribeOutcomeJSON struct {
+	Sender string `json:"sender"`
+	URL    string `json:"url"`
+	// Method is one-click when the POST was accepted, opened when --open
+	// opened the link, browser when the link still has to be visited,
+	// mailto when --mailto sent the email, and email when it still has to
+	// be sent.
+	Method string `json:"method"`
+	Error  string `json:"error,omitempty"`
+	// DryRun is set when Method is only what would have been done.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+func toGroupJSON(g model.SenderGroup) groupJSON {
+	out := groupJSON{
+		Sender:    g.Email,
+		Name:      g.DisplayName,
+		Subject:   g.Subject,
+		Messages:  g.Count,
+		Unread:    g.Unread,
+		SizeBytes: g.Size,
+		FirstDate: g.FirstDate,
+		LastDate:  g.LastDate,
+	}
+	switch {
+	case g.UnsubscribeURL != "":
+		out.Unsubscribe = &unsubscribeJSON{URL: g.UnsubscribeURL, OneClick: g.UnsubscribeOneClick}
+	case g.UnsubscribeMailto != "":
+		out.Unsubscribe = &unsubscribeJSON{URL: g.UnsubscribeMailto}
+	}
+	if u := g.Unsubscribed; u != nil {
+		out.Unsubscribed = &unsubscribedJSON{Time: u.Time.Format(time.RFC3339), Method: u.Method}
+	}
+	return out
+}
+
+func toMessageJSON(m model.MessageRef) messageJSON {
+	labels := m.LabelIDs
+	if labels == nil {
+		labels = []string{}
+	}
+	return messageJSON{
+		ID:      m.ID,
+		From:    m.From,
+		Name:    m.FromName,
+		Subject: m.Subject,
+		Date:    m.DateRFC3339,
+		Unread:  m.Unread(),
+		Labels:  labels,
+		Snippet: m.Snippet,
+	}
+}
+
+func toUnsubscribeOutcomeJSON(o gmail.UnsubscribeOutcome) unsubscribeOutcomeJSON {
+	out := unsubscribeOutcomeJSON{Sender: o.Sender, URL: o.URL, Method: string(o.Method)}
+	if errors.Is(o.Err, gmail.ErrDryRun) {
+		out.DryRun = true
+	} else if o.Err != nil {
+		out.Error = o.Err.Error()
+	}
+	return out
+}
+
+// writeJSON prints v to stdout as indented JSON.
+func writeJSON(v any) error {
+	return encodeJSON(os.Stdout, v)
+}
+
+// encodeJSON writes v to w as indented JSON.
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestJSONOutput pins the --json output of the headless commands, which
+// scripts depend on: a change to testdata/json.golden is a change to the
+// command line interface.
+func TestJSONOutput(t *testing.T) {
+	rule, err := gmail.ParseRule("from:@shop.example older:30d -> archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule.ID = 7
+	unsubscribed := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	out := map[string]any{
+		"groups": []groupJSON{
+			toGroupJSON(model.SenderGroup{
+				Email: "news@shop.example", DisplayName: "Shop News", Subject: "Weekly deals",
+				Count: 12, Unread: 3, Size: 40960,
+				FirstDate: "2024-01-05T08:00:00Z", LastDate: "2024-04-26T08:00:00Z",
+				UnsubscribeURL: "https://shop.example/u", UnsubscribeOneClick: true,
+				Unsubscribed: &model.Unsubscription{Sender: "news@shop.example", Time: unsubscribed, Method: "one-click"},
+			}),
+			toGroupJSON(model.SenderGroup{
+				Email: "list@club.example", Subject: "Minutes", Count: 1,
+				UnsubscribeMailto: "mailto:leave@club.example",
+			}),
+			toGroupJSON(model.SenderGroup{Email: "friend@mail.example", Subject: "Hi", Count: 2, Unread: 2}),
+		},
+		"messages": []messageJSON{
+			toMessageJSON(model.MessageRef{
+				ID: "m1", From: "news@shop.example", FromName: "Shop News", Subject: "Weekly deals",
+				DateRFC3339: "2024-04-26T08:00:00Z", LabelIDs: []string{"INBOX", "UNREAD"}, Snippet: "50% off",
+			}),
+			toMessageJSON(model.MessageRef{ID: "m2", From: "friend@mail.example", Subject: "Hi", DateRFC3339: "2024-04-01T12:00:00Z"}),
+		},
+		"sync": syncJSON{
+			Label: "INBOX", Query: "newer_than:1y", Messages: 1200, Since: "2023-05-01", Extended: 40,
+			Rules: toRuleRunsJSON([]gmail.RuleResult{
+				{Rule: rule, Messages: 5, DryRun: true},
+				{Rule: model.Rule{ID: 8}, Messages: 0},
+				{Rule: rule, Err: errors.New("quota exceeded\n")},
+			}),
+			Unsnoozed: 2, DryRun: true,
+		},
+		"sync_empty": syncJSON{Label: "INBOX", Rules: toRuleRunsJSON(nil)},
+		"archive": actionJSON{
+			Action: "archive", Sender: "@shop.example", Messages: 2, IDs: []string{"m1", "m3"}, Protected: 1,
+		},
+		"trash_dry_run": actionJSON{
+			Action: "trash", Sender: "news@shop.example", Subject: "Weekly deals", Messages: 1, IDs: []string{"m1"}, DryRun: true,
+		},
+		"unsubscribe": []unsubscribeOutcomeJSON{
+			toUnsubscribeOutcomeJSON(gmail.UnsubscribeOutcome{Sender: "news@shop.example", URL: "https://shop.example/u", Method: gmail.UnsubscribeOneClick}),
+			toUnsubscribeOutcomeJSON(gmail.UnsubscribeOutcome{Sender: "a@b.example", URL: "https://b.example/u?x=1&y=2", Method: gmail.UnsubscribeBrowser, Err: errors.New("405 Method Not Allowed")}),
+			toUnsubscribeOutcomeJSON(gmail.UnsubscribeOutcome{Sender: "c@d.example", URL: "https://d.example/u", Method: gmail.UnsubscribeOneClick, Err: gmail.ErrDryRun}),
+		},
+		"senders": []senderJSON{{Match: "@bank.example", Status: "protected"}},
+		"export":  exportJSON{Format: "mbox", Path: "/tmp/out.mbox", Messages: 12},
+		"snoozes": []snoozeJSON{{ID: "m4", Until: "2024-06-01T09:00:00Z", Sender: "boss@work.example", Subject: "Q3"}},
+		"rules":   []ruleJSON{{ID: 7, Rule: gmail.FormatRule(rule), Enabled: true}, {ID: 9, Rule: "from:x@y.example -> trash", Every: "@daily"}},
+		"rule_log": []ruleLogJSON{
+			{ID: 7, Time: "2024-05-01T09:30:00Z", Rule: gmail.FormatRule(rule), Messages: 5},
+			{ID: 9, Time: "2024-05-02T09:30:00Z", Rule: "from:x@y.example -> trash", Error: "quota exceeded"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "json.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("--json output changed; if that is intended, run go test -update and review the diff\ngot:\n%s", buf.Bytes())
+	}
+}
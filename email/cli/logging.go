@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// logMaxBytes is the size past which chuckterm.log is moved aside to
+// chuckterm.log.1 when a command starts.
+const logMaxBytes = 10 << 20
+
+// logStarted is set once startLog has run; commands that load the config
+// twice keep the first logger.
+var logStarted bool
+
+// parseLogLevel reads log_level: debug, info (the default), warn, error or
+// off. ok is false for off.
+func parseLogLevel(s string) (level slog.Level, ok bool, err error) {
+	switch strings.ToLower(s) {
+	case "off":
+		return 0, false, nil
+	case "":
+		return slog.LevelInfo, true, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, false, fmt.Errorf("unknown log_level %q (want debug, info, warn, error or off)", s)
+	}
+	return level, true, nil
+}
+
+// startLog points slog's default logger at chuckterm.log in configDir, the
+// only place a TUI session's errors can be read afterwards. The file is
+// left open until the process exits. A log that cannot be opened only
+// warns.
+func startLog(configDir, levelName string) error {
+	level, on, err := parseLogLevel(levelName)
+	if err != nil || logStarted {
+		return err
+	}
+	logStarted = true
+	if !on {
+		slog.SetDefault(slog.New(slog.DiscardHandler))
+		return err
+	}
+	path := filepath.Join(configDir, "chuckterm.log")
+	os.MkdirAll(configDir, 0o700)
+	if fi, err := os.Stat(path); err == nil && fi.Size() > logMaxBytes {
+		os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open %s: %v\n", path, err)
+		slog.SetDefault(slog.New(slog.DiscardHandler))
+		return nil
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: level})).With("pid", os.Getpid()))
+	slog.Info("start", "args", os.Args[1:])
+	return nil
+}
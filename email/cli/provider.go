@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/mailbox"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// Mail providers config.toml's provider can name.
+const (
+	providerGmail = "gmail"
+	providerIMAP  = "imap"
+)
+
+// errNeedsGmail is returned for features only Gmail has when the configured
+// provider is another.
+var errNeedsGmail = errors.New(`needs a Gmail account, but config.toml sets provider = "imap"`)
+
+// parseProvider checks config.toml's provider, where "" means Gmail.
+func parseProvider(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", providerGmail:
+		return providerGmail, nil
+	case providerIMAP:
+		return providerIMAP, nil
+	}
+	return "", fmt.Errorf("unknown provider %q (want gmail or imap)", name)
+}
+
+// openProvider returns the configured account, signing in to Gmail if need
+// be. svc is the Gmail service behind it, or nil for an IMAP account.
+func openProvider(ctx context.Context, configDir string, cfg Config) (p mailbox.Provider, svc *gmailv1.Service, err error) {
+	if cfg.Provider == providerIMAP {
+		p, err := imapAccount(ctx, cfg)
+		return p, nil, err
+	}
+	if svc, err = gmail.NewService(ctx, configDir); err != nil {
+		return nil, nil, err
+	}
+	return mailbox.Gmail(svc), svc, nil
+}
+
+// gmailService signs in to Gmail for the commands only Gmail supports.
+func gmailService(ctx context.Context, configDir string, cfg Config) (*gmailv1.Service, error) {
+	if cfg.Provider == providerIMAP {
+		return nil, errNeedsGmail
+	}
+	return gmail.NewService(ctx, configDir)
+}
+
+// imapAccount is the provider for config.toml's [imap] section. The
+// password comes from password_command's output when that is set.
+func imapAccount(ctx context.Context, cfg Config) (mailbox.Provider, error) {
+	c := cfg.IMAP
+	if c.Host == "" || c.User == "" {
+		return nil, errors.New("[imap] needs host and user in config.toml")
+	}
+	password := c.Password
+	if c.PasswordCommand != "" {
+		out, err := exec.CommandContext(ctx, "sh", "-c", c.PasswordCommand).Output()
+		if err != nil {
+			return nil, fmt.Errorf("[imap] password_command: %w", err)
+		}
+		password = strings.TrimRight(string(out), "\r\n")
+	}
+	if password == "" {
+		return nil, errors.New("[imap] needs password, password_command or CHUCKTERM_IMAP_PASSWORD")
+	}
+	return mailbox.IMAP(mailbox.IMAPConfig{
+		Addr:     c.Host,
+		User:     c.User,
+		Password: password,
+		Archive:  c.Archive,
+		Trash:    c.Trash,
+	}), nil
+}
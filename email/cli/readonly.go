@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"chuckterm/internal/gmail"
+)
+
+// rootContext is the context a command runs under: in read-only mode when
+// cfg.ReadOnly is set, so that it signs in with the gmail.readonly scope
+// and skips whatever would change mail.
+func rootContext(cfg Config) context.Context {
+	if cfg.ReadOnly {
+		return gmail.WithReadOnly(context.Background())
+	}
+	return context.Background()
+}
+
+// refuseReadOnly tells the user that command would change mail, and
+// reports true, when read-only mode is on. The commands that change mail
+// call it before they open anything; a dry run changes nothing, so it may
+// go ahead.
+func refuseReadOnly(cfg Config, dryRun bool, command string) bool {
+	if !cfg.ReadOnly || dryRun {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s: read-only mode is on, so chuckterm changes no mail (see --read-only)\n", command)
+	return true
+}
@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+const rulesUsage = `usage: chuckterm rules [list]
+       chuckterm rules add "from:@shop.example older:30d -> archive"
+       chuckterm rules edit ID "RULE"
+       chuckterm rules delete|enable|disable ID
+       chuckterm rules run [ID]
+       chuckterm rules log [--limit N]`
+
+// runRules implements `chuckterm rules`: it lists and edits the rules that
+// run after each sync and the scheduled cleanup jobs, as the TUI's rules
+// view does, runs them on demand and shows the log of their runs.
+func runRules(args []string) int {
+	configDir, cfg := loadConfig()
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the rules, what run did, or the log as a JSON array")
+	limit := fs.Int("limit", 20, "runs shown by log")
+	dryRun := dryRunFlag(fs, cfg)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), rulesUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	verb, rest := "list", fs.Args()
+	if len(rest) > 0 {
+		// Flags may also follow the verb, as in rules log --limit 5.
+		verb = rest[0]
+		fs.Parse(rest[1:])
+		rest = fs.Args()
+	}
+	want := map[string]int{"list": 0, "log": 0, "run": 0, "add": 1, "edit": 2, "delete": 1, "enable": 1, "disable": 1}
+	if n, ok := want[verb]; !ok || len(rest) != n && !(verb == "run" && len(rest) == 1) {
+		fs.Usage()
+		return 2
+	}
+	var id int64
+	if verb != "add" && len(rest) > 0 {
+		var err error
+		if id, err = strconv.ParseInt(rest[0], 10, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %q is not a rule ID\n", rest[0])
+			return 2
+		}
+	}
+	if verb == "run" && refuseReadOnly(cfg, *dryRun, "rules run") {
+		return 1
+	}
+	var rule model.Rule
+	if verb == "add" || verb == "edit" {
+		var err error
+		if rule, err = gmail.ParseRule(rest[len(rest)-1]); err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 2
+		}
+	}
+
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch verb {
+	case "run":
+		ctx, closeAudit := dryRunContext(ctx, *dryRun, "rules")
+		defer closeAudit()
+		var job model.Rule
+		if id != 0 {
+			if job, err = findRule(ctx, db, id); err != nil {
+				fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+				return 1
+			}
+		}
+		svc, err := gmailService(ctx, configDir, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 1
+		}
+		logf := func(format string, args ...any) {
+			if !*asJSON {
+				fmt.Printf(format+"\n", args...)
+			}
+		}
+		var results []gmail.RuleResult
+		if id != 0 {
+			var res gmail.RuleResult
+			if res, err = gmail.RunRule(ctx, svc, db, job, time.Now()); err == nil {
+				results = []gmail.RuleResult{res}
+				err = reportRules(results, logf)
+			}
+		} else {
+			results, err = applyRules(ctx, svc, db, logf)
+		}
+		if *asJSON && printJSON("rules", toRuleRunsJSON(results)) != 0 {
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 1
+		}
+		return 0
+	case "add":
+		if _, err := db.SaveRule(ctx, rule); err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 1
+		}
+		return 0
+	case "delete":
+		if _, err := findRule(ctx, db, id); err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 1
+		}
+		if err := db.DeleteRule(ctx, id); err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 1
+		}
+		return 0
+	case "edit", "enable", "disable":
+		old, err := findRule(ctx, db, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 1
+		}
+		if verb == "edit" {
+			rule.ID, rule.Disabled = id, old.Disabled
+		} else {
+			rule = old
+			rule.Disabled = verb == "disable"
+		}
+		if _, err := db.SaveRule(ctx, rule); err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			return 1
+		}
+		return 0
+	case "log":
+		return printRuleRuns(ctx, db, *limit, *asJSON)
+	}
+
+	rules, err := db.LoadRules(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		out := make([]ruleJSON, len(rules))
+		for i, r := range rules {
+			out[i] = ruleJSON{ID: r.ID, Rule: gmail.FormatRule(r), Enabled: !r.Disabled, Every: r.Every}
+		}
+		return printJSON("rules", out)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tENABLED\tRUNS\tRULE")
+	for _, r := range rules {
+		enabled := "yes"
+		if r.Disabled {
+			enabled = "no"
+		}
+		runs := "after sync"
+		if r.Every != "" {
+			runs = r.Every
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.ID, enabled, runs, gmail.FormatRule(r))
+	}
+	w.Flush()
+	return 0
+}
+
+// printRuleRuns prints the latest limit runs from the rule log.
+func printRuleRuns(ctx context.Context, db store.Store, limit int, asJSON bool) int {
+	runs, err := db.LoadRuleRuns(ctx, limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+		return 1
+	}
+	if asJSON {
+		out := make([]ruleLogJSON, len(runs))
+		for i, r := range runs {
+			out[i] = ruleLogJSON{ID: r.RuleID, Time: r.Time.Format(time.RFC3339), Rule: r.Rule, Messages: r.Messages, Error: r.Err}
+		}
+		return printJSON("rules", out)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tID\tMESSAGES\tRULE")
+	for _, r := range runs {
+		rule := r.Rule
+		if r.Err != "" {
+			rule += " (failed: " + r.Err + ")"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", r.Time.Local().Format(time.DateTime), r.RuleID, r.Messages, rule)
+	}
+	w.Flush()
+	return 0
+}
+
+// findRule returns the rule with the given ID.
+func findRule(ctx context.Context, db store.Store, id int64) (model.Rule, error) {
+	rules, err := db.LoadRules(ctx)
+	if err != nil {
+		return model.Rule{}, err
+	}
+	for _, r := range rules {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return model.Rule{}, fmt.Errorf("no rule %d (see chuckterm rules list)", id)
+}
+
+// applyRules runs the rules after a sync, logging each rule that changed
+// something, and returns the rule errors joined.
+func applyRules(ctx context.Context, svc *gmailv1.Service, db store.Store, logf func(format string, args ...any)) ([]gmail.RuleResult, error) {
+	results, err := gmail.RunRules(ctx, svc, db, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return results, reportRules(results, logf)
+}
+
+// applyDueRules runs the cleanup jobs that are due, as applyRules does.
+func applyDueRules(ctx context.Context, svc *gmailv1.Service, db store.Store, logf func(format string, args ...any)) error {
+	results, err := gmail.RunDueRules(ctx, svc, db, time.Now())
+	if err != nil {
+		return err
+	}
+	return reportRules(results, logf)
+}
+
+// reportRules logs each rule that changed something and returns the rule
+// errors joined.
+func reportRules(results []gmail.RuleResult, logf func(format string, args ...any)) error {
+	for _, r := range results {
+		if r.Messages > 0 && r.DryRun {
+			logf("rule %s: would change %s (dry run)", gmail.FormatRule(r.Rule), plural(r.Messages, "message"))
+		} else if r.Messages > 0 {
+			logf("rule %s: %s", gmail.FormatRule(r.Rule), plural(r.Messages, "message"))
+		}
+	}
+	_, err := gmail.RulesSummary(results)
+	return err
+}
+
+func toRuleRunsJSON(results []gmail.RuleResult) []ruleRunJSON {
+	out := []ruleRunJSON{}
+	for _, r := range results {
+		if r.Messages == 0 && r.Err == nil {
+			continue
+		}
+		run := ruleRunJSON{ID: r.Rule.ID, Rule: gmail.FormatRule(r.Rule), Messages: r.Messages, DryRun: r.DryRun}
+		if r.Err != nil {
+			run.Error = strings.TrimSpace(r.Err.Error())
+		}
+		out = append(out, run)
+	}
+	return out
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+const snoozeUsage = `usage: chuckterm snooze [list]
+       chuckterm snooze add --until WHEN ID...
+       chuckterm snooze cancel ID...
+       chuckterm snooze wake`
+
+// runSnooze implements `chuckterm snooze`: it lists the snoozed messages,
+// snoozes cached messages by ID, puts snoozed ones back early and wakes
+// those that are due, as each sync also does.
+func runSnooze(args []string) int {
+	configDir, cfg := loadConfig()
+	fs := flag.NewFlagSet("snooze", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the snoozed or woken messages as a JSON array")
+	until := fs.String("until", "", "when add brings the messages back: 2h, 3d, tomorrow, mon, next week, 2025-06-01 or \"2025-06-01 09:00\"")
+	dryRun := dryRunFlag(fs, cfg)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), snoozeUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	verb, rest := "list", fs.Args()
+	if len(rest) > 0 {
+		// Flags may also follow the verb, as in snooze add --until 2h ID.
+		verb = rest[0]
+		fs.Parse(rest[1:])
+		rest = fs.Args()
+	}
+	switch {
+	case verb == "list" || verb == "wake":
+		if len(rest) > 0 {
+			fs.Usage()
+			return 2
+		}
+	case verb == "add" || verb == "cancel":
+		if len(rest) == 0 {
+			fs.Usage()
+			return 2
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	if verb != "list" && refuseReadOnly(cfg, *dryRun, "snooze "+verb) {
+		return 1
+	}
+	var when time.Time
+	if verb == "add" {
+		var err error
+		if when, err = gmail.ParseSnoozeTime(*until, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+			return 2
+		}
+	}
+
+	db, err := openStore(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(rootContext(cfg), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if verb == "list" {
+		snoozes, err := db.LoadSnoozes(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+			return 1
+		}
+		return printSnoozes(snoozes, *asJSON)
+	}
+
+	svc, err := gmailService(ctx, configDir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+		return 1
+	}
+	ctx, closeAudit := dryRunContext(ctx, *dryRun, "snooze")
+	defer closeAudit()
+	switch verb {
+	case "add":
+		msgs, err := cachedMessages(ctx, db, rest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+			return 1
+		}
+		done := "Snoozed"
+		if err := gmail.SnoozeMessages(ctx, svc, db, msgs, when); errors.Is(err, gmail.ErrDryRun) {
+			done = "Would snooze"
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+			return 1
+		}
+		if !*asJSON {
+			fmt.Printf("%s %s until %s\n", done, plural(len(msgs), "message"), when.Format("Mon 2 Jan 15:04"))
+			return 0
+		}
+		snoozes := make([]model.Snooze, len(msgs))
+		for i, m := range msgs {
+			snoozes[i] = model.Snooze{ID: m.ID, Until: when, From: m.From, Subject: m.Subject}
+		}
+		return printJSON("snooze", toSnoozesJSON(snoozes))
+	case "cancel":
+		snoozes, err := db.LoadSnoozes(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+			return 1
+		}
+		byID := make(map[string]model.Snooze, len(snoozes))
+		for _, s := range snoozes {
+			byID[s.ID] = s
+		}
+		var back []model.Snooze
+		code := 0
+		for _, id := range rest {
+			s, ok := byID[id]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "snooze: message %s is not snoozed\n", id)
+				code = 1
+				continue
+			}
+			if err := gmail.Unsnooze(ctx, svc, db, id); errors.Is(err, gmail.ErrDryRun) {
+				continue
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+				code = 1
+				continue
+			}
+			back = append(back, s)
+		}
+		if *asJSON {
+			return max(code, printJSON("snooze", toSnoozesJSON(back)))
+		}
+		if len(back) > 0 {
+			fmt.Printf("Put %s back in the inbox\n", plural(len(back), "message"))
+		}
+		return code
+	}
+
+	woken, err := gmail.WakeSnoozes(ctx, svc, db, time.Now())
+	code := 0
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snooze: %v\n", err)
+		code = 1
+	}
+	if *asJSON {
+		return max(code, printJSON("snooze", toSnoozesJSON(woken)))
+	}
+	fmt.Printf("Put %s back in the inbox\n", plural(len(woken), "snoozed message"))
+	return code
+}
+
+// cachedMessages returns the cached messages with the given IDs, failing on
+// any that is not cached.
+func cachedMessages(ctx context.Context, db store.Store, ids []string) ([]model.MessageRef, error) {
+	all, err := db.LoadAllMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]model.MessageRef, len(all))
+	for _, m := range all {
+		byID[m.ID] = m
+	}
+	msgs := make([]model.MessageRef, 0, len(ids))
+	for _, id := range ids {
+		m, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("message %s is not in the cache (see chuckterm messages --json)", id)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+func printSnoozes(snoozes []model.Snooze, asJSON bool) int {
+	if asJSON {
+		return printJSON("snooze", toSnoozesJSON(snoozes))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UNTIL\tID\tSENDER\tSUBJECT")
+	for _, s := range snoozes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Until.Format("2006-01-02 15:04"), s.ID, s.From, s.Subject)
+	}
+	w.Flush()
+	return 0
+}
+
+func toSnoozesJSON(snoozes []model.Snooze) []snoozeJSON {
+	out := make([]snoozeJSON, len(snoozes))
+	for i, s := range snoozes {
+		out[i] = snoozeJSON{ID: s.ID, Until: s.Until.Format(time.RFC3339), Sender: s.From, Subject: s.Subject}
+	}
+	return out
+}
+
+// wakeSnoozes puts the snoozed messages that are due back in the inbox
+// after a sync, logging how many came back.
+func wakeSnoozes(ctx context.Context, svc *gmailv1.Service, db store.Store, logf func(format string, args ...any)) (int, error) {
+	woken, err := gmail.WakeSnoozes(ctx, svc, db, time.Now())
+	if len(woken) > 0 {
+		logf("%s back in the inbox", plural(len(woken), "snoozed message"))
+	}
+	return len(woken), err
+}
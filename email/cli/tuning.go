@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"chuckterm/internal/gmail"
+)
+
+// syncTuning holds the flags that tune how hard a Gmail sync works, for
+// slow connections or tight quotas.
+type syncTuning struct {
+	workers, listPageSize, upsertBatch *int
+}
+
+// syncTuningFlags adds --workers, --list-page-size and --upsert-batch to
+// fs, defaulting to config.toml.
+func syncTuningFlags(fs *flag.FlagSet, cfg Config) syncTuning {
+	return syncTuning{
+		workers:      fs.Int("workers", cfg.Workers, fmt.Sprintf("concurrent metadata requests during sync, up to %d (0 uses the defaults)", gmail.MaxWorkers)),
+		listPageSize: fs.Int("list-page-size", cfg.ListPageSize, fmt.Sprintf("messages listed per request during sync, up to %d (0 uses %[1]d)", gmail.MaxListPageSize)),
+		upsertBatch:  fs.Int("upsert-batch", cfg.UpsertBatch, fmt.Sprintf("messages written to the cache at a time during sync, up to %d (0 uses 500)", gmail.MaxUpsertBatch)),
+	}
+}
+
+// apply sets the tuning in opts.
+func (t syncTuning) apply(opts *gmail.SyncOptions) {
+	opts.Workers, opts.ListPageSize, opts.UpsertBatch = *t.workers, *t.listPageSize, *t.upsertBatch
+}
+
+// validate checks the tuning against the gmail package's bounds.
+func (t syncTuning) validate() error {
+	var opts gmail.SyncOptions
+	t.apply(&opts)
+	return opts.Validate()
+}
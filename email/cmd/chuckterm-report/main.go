@@ -0,0 +1,144 @@
+// Command chuckterm-report prints sender and volume reports from the chuckterm
+// cache. It only ever requests the gmail.readonly scope and never calls any
+// modify, trash, or unsubscribe helper, so those code paths are not linked
+// in; main_test.go fails the build if one is.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/report"
+	"chuckterm/internal/store"
+	"common/xdg"
+)
+
+func main() {
+	by := flag.String("by", "sender", "report to print: sender, domain, or month")
+	top := flag.Int("top", 25, "number of rows for sender/domain reports (0 = all)")
+	sync := flag.Bool("sync", false, "refresh the cache first using a gmail.readonly token")
+	configDirFlag := flag.String("config-dir", os.Getenv("CHUCKTERM_CONFIG_DIR"), "chuckterm config directory (default $XDG_CONFIG_HOME/chuckterm)")
+	dbPath := flag.String("db", os.Getenv("CHUCKTERM_DB"), "cache file (default chuckterm.db in the config directory given, else in $XDG_DATA_HOME/chuckterm)")
+	flag.Parse()
+
+	configDir, err := xdg.ConfigDir("chuckterm")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot determine config directory: %v\n", err)
+		os.Exit(1)
+	}
+	if *configDirFlag != "" {
+		configDir = *configDirFlag
+	}
+	if *dbPath == "" {
+		*dbPath = defaultDatabase(configDir, *configDirFlag != "")
+	}
+	db, err := store.NewSQLiteStore(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if *sync {
+		if err := readonlySync(ctx, configDir, db); err != nil {
+			fmt.Fprintf(os.Stderr, "Sync failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	msgs, err := db.LoadAllMessages(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load messages: %v\n", err)
+		os.Exit(1)
+	}
+
+	var rows []report.Row
+	switch *by {
+	case "sender":
+		rows = report.BySender(msgs)
+	case "domain":
+		rows = report.ByDomain(msgs)
+	case "month":
+		rows = report.ByMonth(msgs)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown report %q (want sender, domain, or month)\n", *by)
+		os.Exit(2)
+	}
+	if *by != "month" && *top > 0 && len(rows) > *top {
+		rows = rows[:*top]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tMESSAGES\tFIRST\tLAST\n", map[string]string{"sender": "SENDER", "domain": "DOMAIN", "month": "MONTH"}[*by])
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", r.Key, r.Count, shortDate(r.FirstDate), shortDate(r.LastDate))
+	}
+	w.Flush()
+	fmt.Printf("\n%d messages in cache\n", len(msgs))
+}
+
+// defaultDatabase finds the cache where chuckterm puts it: in a chosen config
+// directory, in the config directory for caches from before it moved, or in
+// $XDG_DATA_HOME/chuckterm.
+func defaultDatabase(configDir string, custom bool) string {
+	path := filepath.Join(configDir, "chuckterm.db")
+	if _, err := os.Stat(path); custom || err == nil {
+		return path
+	}
+	dataDir, err := xdg.DataDir("chuckterm")
+	if err != nil {
+		return path
+	}
+	return filepath.Join(dataDir, "chuckterm.db")
+}
+
+// readonlySync brings the cache up to date. Syncing only lists and reads
+// messages, so the readonly scope is sufficient.
+func readonlySync(ctx context.Context, configDir string, db *store.SQLiteStore) error {
+	// Should a change slip into sync, refuse it rather than fail with 403.
+	ctx = gmail.WithReadOnly(ctx)
+	svc, err := gmail.NewReadonlyService(ctx, configDir)
+	if err != nil {
+		return err
+	}
+	// Keep whatever label scope and search the main app built the cache with.
+	label, err := gmail.LabelScope(ctx, db)
+	if err != nil {
+		return err
+	}
+	query, err := gmail.SyncQuery(ctx, db)
+	if err != nil {
+		return err
+	}
+	opts := gmail.SyncOptions{Label: label, Query: query}
+	hid, err := db.GetLastHistoryID(ctx)
+	if err != nil {
+		return err
+	}
+	if hid != "" {
+		err = gmail.SyncSinceHistory(ctx, svc, db, hid, opts, nil)
+		if errors.Is(err, gmail.ErrHistoryExpired) {
+			err = gmail.Rescan(ctx, svc, db, opts, nil)
+		}
+	} else {
+		err = gmail.FullScan(ctx, svc, db, opts, nil)
+	}
+	if err != nil {
+		return err
+	}
+	return gmail.RefreshMetadata(ctx, svc, db, opts, nil)
+}
+
+func shortDate(rfc3339 string) string {
+	if len(rfc3339) >= 10 {
+		return rfc3339[:10]
+	}
+	return rfc3339
+}
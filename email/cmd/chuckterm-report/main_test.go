@@ -0,0 +1,176 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mutatingCalls are the Gmail Users API calls that change mail, labels,
+// filters, or settings.
+var mutatingCalls = map[string]bool{
+	"Trash": true, "Untrash": true, "Modify": true, "BatchModify": true,
+	"Delete": true, "BatchDelete": true, "Import": true, "Insert": true,
+	"Send": true, "Create": true, "Update": true, "Patch": true,
+	"Watch": true, "Stop": true,
+}
+
+// unsubscribeFuncs act on a sender's unsubscribe links without going
+// through the Users API.
+var unsubscribeFuncs = []string{
+	"OpenUnsubscribeURL", "BrowseUnsubscribe", "OneClickUnsubscribe",
+	"MailtoUnsubscribe", "BulkUnsubscribe", "UnsubscribeGroup",
+}
+
+// modifyFuncs returns the functions of package gmail that change mail,
+// directly or through another of its functions, keyed by name. Methods are
+// keyed by method name alone, which can only flag too many, except that a
+// call through one of the package's interfaces, such as SyncOptions.Triage,
+// only links what the caller plugs in.
+func modifyFuncs(t *testing.T) map[string]bool {
+	t.Helper()
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("../../internal/gmail/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := map[string][]*ast.BlockStmt{}
+	modify := map[string]bool{}
+	dynamic := map[string]bool{}
+	for _, name := range unsubscribeFuncs {
+		modify[name] = true
+	}
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if it, ok := n.(*ast.InterfaceType); ok {
+				for _, m := range it.Methods.List {
+					for _, name := range m.Names {
+						dynamic[name.Name] = true
+					}
+				}
+			}
+			return true
+		})
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			name := fn.Name.Name
+			bodies[name] = append(bodies[name], fn.Body)
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok && mutatingCalls[sel.Sel.Name] && onUsers(sel.X) {
+					modify[name] = true
+				}
+				return true
+			})
+		}
+	}
+	if len(modify) == len(unsubscribeFuncs) {
+		t.Fatal("found no Users API call that changes mail in package gmail")
+	}
+	for grew := true; grew; {
+		grew = false
+		for name, list := range bodies {
+			if modify[name] {
+				continue
+			}
+			var visit func(n ast.Node) bool
+			visit = func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.Ident:
+					if modify[n.Name] {
+						modify[name], grew = true, true
+					}
+				case *ast.SelectorExpr:
+					if modify[n.Sel.Name] && !dynamic[n.Sel.Name] {
+						modify[name], grew = true, true
+					}
+					// Sel is not a reference of its own.
+					ast.Inspect(n.X, visit)
+					return false
+				}
+				return !modify[name]
+			}
+			for _, body := range list {
+				ast.Inspect(body, visit)
+			}
+		}
+	}
+	return modify
+}
+
+// onUsers reports whether x is a selector chain through .Users, such as
+// svc.Users.Messages.
+func onUsers(x ast.Expr) bool {
+	for {
+		switch e := x.(type) {
+		case *ast.SelectorExpr:
+			if e.Sel.Name == "Users" {
+				return true
+			}
+			x = e.X
+		case *ast.CallExpr:
+			x = e.Fun
+		default:
+			return false
+		}
+	}
+}
+
+// TestNoModifyCodeLinked builds chuckterm-report and fails if any function
+// of package gmail that changes mail is linked into it.
+func TestNoModifyCodeLinked(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the binary")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not in PATH")
+	}
+	modify := modifyFuncs(t)
+
+	bin := filepath.Join(t.TempDir(), "chuckterm-report")
+	if out, err := exec.Command(goTool, "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	out, err := exec.Command(goTool, "tool", "nm", bin).Output()
+	if err != nil {
+		t.Fatalf("go tool nm: %v", err)
+	}
+	const pkg = "chuckterm/internal/gmail."
+	var synced bool
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.HasPrefix(fields[2], pkg) {
+			continue
+		}
+		sym := strings.TrimPrefix(fields[2], pkg)
+		if sym == "SyncSinceHistory" {
+			synced = true
+		}
+		// Methods appear as (*T).Name or T.Name, closures as Name.func1.
+		name, _, _ := strings.Cut(sym, ".func")
+		name, _, _ = strings.Cut(name, ".deferwrap")
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[i+1:]
+		}
+		if modify[name] {
+			t.Errorf("%s is linked in", fields[2])
+		}
+	}
+	if !synced {
+		t.Fatal("gmail.SyncSinceHistory is not linked in; is the symbol table stripped?")
+	}
+}
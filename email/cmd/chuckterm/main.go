@@ -3,40 +3,27 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
-	tea "github.com/charmbracelet/bubbletea"
-
-	"chuckterm/internal/store"
-	"chuckterm/internal/tui"
+	"chuckterm/cli"
+	"common/buildinfo"
+	"common/selfupdate"
 )
 
-func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Cannot determine home directory: %v\n", err)
-		os.Exit(1)
-	}
+// Set at release time with -ldflags "-X main.version=v1.2.3
+// -X main.updateKey=<base64 ed25519 public key>".
+var version, updateKey string
 
-	configDir := filepath.Join(home, ".config", "chuckterm")
-	dbPath := filepath.Join(configDir, "chuckterm.db")
-	db, err := store.NewSQLiteStore(dbPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Cannot open database: %v\n", err)
-		os.Exit(1)
-	}
-	defer db.Close()
-
-	appModel := tui.NewAppModel(db, configDir)
-	p := tea.NewProgram(&appModel, tea.WithAltScreen())
-	appModel.SetProgram(p)
-	finalModel, err := p.Run()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Alas, there's been an error: %v\n", err)
-		os.Exit(1)
-	}
-	if m, ok := finalModel.(*tui.AppModel); ok && m.Err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", m.Err)
-		os.Exit(1)
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "version", "--version", "-version":
+			fmt.Println(buildinfo.Version(version))
+			return
+		case "self-update":
+			u := selfupdate.Updater{Binary: "chuckterm", Current: buildinfo.Version(version), PublicKey: updateKey}
+			os.Exit(u.Run(args[1:]))
+		}
 	}
+	os.Exit(cli.Main(args))
 }
@@ -0,0 +1,285 @@
+// Package backup writes encrypted snapshots of the chuckterm cache and
+// settings to a local directory or remote target, and restores them.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshotter writes a transactionally consistent copy of the database to path.
+type Snapshotter interface {
+	BackupTo(ctx context.Context, path string) error
+}
+
+// Options describes what to back up and where.
+type Options struct {
+	ConfigDir  string // settings files are taken from (and restored to) here
+	DBPath     string // live database path, restored in place
+	Passphrase string
+	Target     Target
+}
+
+const (
+	latestName  = "LATEST"
+	dbEntry     = "chuckterm.db"
+	settingsDir = "settings/"
+)
+
+// Create snapshots the database and settings, encrypts them, and uploads the
+// result to the target. It returns the name of the uploaded backup.
+func Create(ctx context.Context, snap Snapshotter, opts Options) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "chuckterm-backup-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbCopy := filepath.Join(tmpDir, dbEntry)
+	if err := snap.BackupTo(ctx, dbCopy); err != nil {
+		return "", fmt.Errorf("snapshot database: %w", err)
+	}
+	settings, err := settingsFiles(opts.ConfigDir, opts.DBPath)
+	if err != nil {
+		return "", err
+	}
+
+	encPath := filepath.Join(tmpDir, "backup.enc")
+	enc, err := os.OpenFile(encPath, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, dbCopy, opts.ConfigDir, settings))
+	}()
+	err = Encrypt(enc, pr, opts.Passphrase)
+	pr.Close()
+	if cerr := enc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("encrypt backup: %w", err)
+	}
+
+	name := "chuckterm-" + time.Now().UTC().Format("20060102T150405Z") + ".chkbk"
+	f, err := os.Open(encPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if err := opts.Target.Put(ctx, name, f, info.Size()); err != nil {
+		return "", fmt.Errorf("upload backup: %w", err)
+	}
+	if err := opts.Target.Put(ctx, latestName, strings.NewReader(name), int64(len(name))); err != nil {
+		return "", fmt.Errorf("update %s pointer: %w", latestName, err)
+	}
+	return name, nil
+}
+
+// Restore downloads a backup (the most recent one when name is empty),
+// decrypts it, and writes the database and settings back in place. The
+// database must not be open while restoring.
+func Restore(ctx context.Context, name string, opts Options) (string, error) {
+	if name == "" {
+		rc, err := opts.Target.Get(ctx, latestName)
+		if err != nil {
+			return "", fmt.Errorf("find latest backup: %w", err)
+		}
+		b, err := io.ReadAll(io.LimitReader(rc, 1024))
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		name = strings.TrimSpace(string(b))
+	}
+	rc, err := opts.Target.Get(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("download backup %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(Decrypt(pw, rc, opts.Passphrase))
+	}()
+	defer pr.Close()
+	if err := extractArchive(pr, opts); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// settingsFiles lists the top-level files in configDir worth backing up. The
+// live database, the OAuth token, and temporary files are skipped.
+func settingsFiles(configDir, dbPath string) ([]string, error) {
+	entries, err := os.ReadDir(configDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	dbBase := filepath.Base(dbPath)
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, dbBase),
+			name == "token.json",
+			strings.HasSuffix(name, ".tmp"),
+			strings.HasSuffix(name, ".log"):
+			continue
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func writeArchive(w io.Writer, dbCopy, configDir string, settings []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := addFile(tw, dbCopy, dbEntry); err != nil {
+		return err
+	}
+	for _, name := range settings {
+		if err := addFile(tw, filepath.Join(configDir, name), settingsDir+name); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFile(tw *tar.Writer, path, entry string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    entry,
+		Mode:    0o600,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// staged is a restored file written next to its destination, waiting for
+// the whole archive to check out before it is renamed into place.
+type staged struct{ tmp, dst string }
+
+// extractArchive writes every entry of the archive to a synced temporary
+// file beside its destination and only moves them into place once the
+// archive has been read to the end, so a truncated or corrupt backup leaves
+// the live database and settings as they were.
+func extractArchive(r io.Reader, opts Options) (err error) {
+	var files []staged
+	defer func() {
+		if err != nil {
+			for _, f := range files {
+				os.Remove(f.tmp)
+			}
+		}
+	}()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("open backup archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read backup archive: %w", err)
+		}
+		var dst string
+		switch {
+		case hdr.Name == dbEntry:
+			dst = opts.DBPath
+		case strings.HasPrefix(hdr.Name, settingsDir):
+			name := strings.TrimPrefix(hdr.Name, settingsDir)
+			if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+				return fmt.Errorf("refusing to restore suspicious entry %q", hdr.Name)
+			}
+			dst = filepath.Join(opts.ConfigDir, name)
+		default:
+			continue
+		}
+		tmp, err := stageFile(dst, tr)
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", hdr.Name, err)
+		}
+		files = append(files, staged{tmp, dst})
+	}
+	// The gzip trailer's checksum is only checked once it is read.
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return fmt.Errorf("read backup archive: %w", err)
+	}
+	for i, f := range files {
+		if err := os.Rename(f.tmp, f.dst); err != nil {
+			files = files[i:]
+			return fmt.Errorf("restore %s: %w", f.dst, err)
+		}
+		if f.dst == opts.DBPath {
+			// Stale WAL files would be replayed over the restored database.
+			os.Remove(opts.DBPath + "-wal")
+			os.Remove(opts.DBPath + "-shm")
+		}
+	}
+	return nil
+}
+
+// stageFile copies r into a synced temporary file in dst's directory and
+// returns its path.
+func stageFile(dst string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Chmod(0o600)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copySnapshot stands in for the store, copying the database file as is.
+type copySnapshot struct{ db string }
+
+func (s copySnapshot) BackupTo(ctx context.Context, path string) error {
+	b, err := os.ReadFile(s.db)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[e.Name()] = string(b)
+	}
+	for name, data := range want {
+		if got[name] != data {
+			t.Errorf("%s = %q; want %q", name, got[name], data)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected file %s", name)
+		}
+	}
+}
+
+func newOptions(t *testing.T) Options {
+	t.Helper()
+	dir := t.TempDir()
+	return Options{
+		ConfigDir:  dir,
+		DBPath:     filepath.Join(dir, "chuckterm.db"),
+		Passphrase: "hunter2",
+		Target:     dirTarget{t.TempDir()},
+	}
+}
+
+func TestCreateRestoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	opts := newOptions(t)
+	writeFiles(t, opts.ConfigDir, map[string]string{
+		"chuckterm.db": "cache v1",
+		"config.json":  `{"theme":"dark"}`,
+		"token.json":   "secret",
+	})
+	name, err := Create(ctx, copySnapshot{opts.DBPath}, opts)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	writeFiles(t, opts.ConfigDir, map[string]string{
+		"chuckterm.db":     "cache v2",
+		"chuckterm.db-wal": "frames for v2",
+		"chuckterm.db-shm": "index for v2",
+		"config.json":      `{"theme":"light"}`,
+	})
+	got, err := Restore(ctx, "", opts)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got != name {
+		t.Errorf("restored %q; want the latest, %q", got, name)
+	}
+	checkFiles(t, opts.ConfigDir, map[string]string{
+		"chuckterm.db": "cache v1",
+		"config.json":  `{"theme":"dark"}`,
+		"token.json":   "secret",
+	})
+}
+
+func TestRestoreCorruptArchiveKeepsDatabase(t *testing.T) {
+	ctx := context.Background()
+	opts := newOptions(t)
+	// Random bytes don't compress, so the database spans several chunks
+	// and the first of them decrypt before the damage is found.
+	db := make([]byte, 3*chunkSize)
+	rand.Read(db)
+	writeFiles(t, opts.ConfigDir, map[string]string{
+		"chuckterm.db": string(db),
+		"config.json":  `{"theme":"dark"}`,
+	})
+	name, err := Create(ctx, copySnapshot{opts.DBPath}, opts)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	path := filepath.Join(opts.Target.(dirTarget).dir, name)
+	enc, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := map[string]string{
+		"chuckterm.db":     "cache v2",
+		"chuckterm.db-wal": "frames for v2",
+		"chuckterm.db-shm": "index for v2",
+		"config.json":      `{"theme":"light"}`,
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", enc[:len(enc)-20]},
+		{"corrupt", append(enc[:len(enc)-1:len(enc)-1], enc[len(enc)-1]^1)},
+	} {
+		writeFiles(t, opts.ConfigDir, live)
+		if err := os.WriteFile(path, tc.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Restore(ctx, name, opts); err == nil {
+			t.Errorf("%s: Restore succeeded", tc.name)
+		}
+		checkFiles(t, opts.ConfigDir, live)
+	}
+}
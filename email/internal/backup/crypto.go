@@ -0,0 +1,175 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted backups are a small header followed by a sequence of AES-256-GCM
+// sealed chunks so arbitrarily large databases never have to fit in memory:
+//
+//	magic(6) | salt(16) | nonce prefix(4) | { len(4) | sealed chunk }...
+//
+// Each chunk nonce is the prefix plus a 64-bit counter; the final chunk is
+// sealed with additional data "last" so truncation is detected.
+const (
+	magic     = "CHKBK1"
+	saltSize  = 16
+	chunkSize = 64 * 1024
+)
+
+var (
+	adChunk = []byte("chunk")
+	adLast  = []byte("last")
+)
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("backup passphrase is empty")
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, n uint64) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[4:], n)
+	return nonce
+}
+
+// Encrypt reads plaintext from r and writes the encrypted stream to w.
+func Encrypt(w io.Writer, r io.Reader, passphrase string) error {
+	salt := make([]byte, saltSize)
+	prefix := make([]byte, 4)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, magic); err != nil {
+		return err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(r, chunkSize)
+	buf := make([]byte, chunkSize)
+	var counter uint64
+	for {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if !last {
+			// A full chunk may still be the final one; peek to find out.
+			if _, perr := br.Peek(1); perr == io.EOF {
+				last = true
+			}
+		}
+		ad := adChunk
+		if last {
+			ad = adLast
+		}
+		sealed := aead.Seal(nil, chunkNonce(prefix, counter), buf[:n], ad)
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(sealed)))
+		if _, err := w.Write(lenBuf[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		counter++
+		if last {
+			return nil
+		}
+	}
+}
+
+// Decrypt reverses Encrypt, failing if the passphrase is wrong or the stream
+// was modified or truncated.
+func Decrypt(w io.Writer, r io.Reader, passphrase string) error {
+	header := make([]byte, len(magic)+saltSize+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("read backup header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return errors.New("not a chuckterm backup")
+	}
+	salt := header[len(magic) : len(magic)+saltSize]
+	prefix := header[len(magic)+saltSize:]
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	var counter uint64
+	for {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+			if err == io.EOF {
+				return errors.New("backup is truncated")
+			}
+			return err
+		}
+		size := binary.BigEndian.Uint32(lenBuf[:])
+		if size > chunkSize+uint32(aead.Overhead()) {
+			return errors.New("backup chunk too large")
+		}
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(r, sealed); err != nil {
+			return fmt.Errorf("read backup chunk: %w", err)
+		}
+		nonce := chunkNonce(prefix, counter)
+		plain, err := aead.Open(nil, nonce, sealed, adChunk)
+		last := false
+		if err != nil {
+			plain, err = aead.Open(nil, nonce, sealed, adLast)
+			if err != nil {
+				return errors.New("decrypt backup: wrong passphrase or corrupted data")
+			}
+			last = true
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		counter++
+		if last {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 10, chunkSize, chunkSize*2 + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		var enc bytes.Buffer
+		if err := Encrypt(&enc, bytes.NewReader(plain), "hunter2"); err != nil {
+			t.Fatalf("Encrypt(%d): %v", size, err)
+		}
+		var dec bytes.Buffer
+		if err := Decrypt(&dec, bytes.NewReader(enc.Bytes()), "hunter2"); err != nil {
+			t.Fatalf("Decrypt(%d): %v", size, err)
+		}
+		if !bytes.Equal(dec.Bytes(), plain) {
+			t.Fatalf("round trip mismatch for size %d", size)
+		}
+	}
+}
+
+func TestDecryptRejectsWrongPassphraseAndTruncation(t *testing.T) {
+	plain := bytes.Repeat([]byte("x"), chunkSize+100)
+	var enc bytes.Buffer
+	if err := Encrypt(&enc, bytes.NewReader(plain), "right"); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(enc.Bytes()), "wrong"); err == nil {
+		t.Fatal("expected error for wrong passphrase")
+	}
+
+	// Drop the final chunk: the remaining stream ends on a non-final chunk.
+	firstChunkEnd := len(magic) + saltSize + 4 + 4 + chunkSize + 16
+	if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(enc.Bytes()[:firstChunkEnd]), "right"); err == nil {
+		t.Fatal("expected error for truncated backup")
+	}
+}
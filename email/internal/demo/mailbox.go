@@ -0,0 +1,423 @@
+// Package demo runs chuckterm against a generated mailbox instead of a
+// Google account. A local server answers the Gmail REST calls chuckterm
+// makes, so every code path from sync to bulk unsubscribe runs unchanged;
+// nothing leaves the machine.
+package demo
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// Address is the demo account's email address.
+const Address = "you@demo.example"
+
+// unsubscribeKind says how a sender lets recipients leave its list.
+type unsubscribeKind int
+
+const (
+	unsubNone     unsubscribeKind = iota // transactional mail or a person
+	unsubOneClick                        // RFC 8058 POST to an HTTPS URL
+	unsubBrowser                         // HTTP page to open in a browser
+	unsubMailto                          // mailto: only
+)
+
+// sender is one entry of the synthetic address book.
+type sender struct {
+	name, addr string
+	category   string   // CATEGORY_* label, "" for none
+	subjects   []string // fixed subjects; %d is replaced by a running number
+	count      int
+	unread     float64 // share of messages left unread
+	unsub      unsubscribeKind
+	html       bool // send HTML bodies (with a text alternative for newsletters)
+}
+
+var senders = []sender{
+	{"Go Weekly", "newsletter@goweekly.example", "CATEGORY_UPDATES", []string{"Go Weekly"}, 60, 0.7, unsubOneClick, true},
+	{"The Rust Digest", "digest@rustdigest.example", "CATEGORY_UPDATES", []string{"This week in Rust"}, 45, 0.8, unsubOneClick, true},
+	{"Daily Brief", "hello@dailybrief.example", "CATEGORY_PROMOTIONS", []string{"Your Daily Brief", "Weekend Edition"}, 70, 0.9, unsubOneClick, true},
+	{"Product Radar", "top@productradar.example", "CATEGORY_PROMOTIONS", []string{"Today's top products"}, 40, 0.95, unsubOneClick, true},
+	{"Notes on Software", "notes@softwarenotes.example", "CATEGORY_UPDATES", []string{"Issue #%d: notes from the field"}, 12, 0.3, unsubOneClick, true},
+	{"Acme Outdoor", "deals@acme-outdoor.example", "CATEGORY_PROMOTIONS", []string{"Up to 40% off tents", "Last chance: summer sale", "New arrivals are here"}, 55, 0.9, unsubBrowser, true},
+	{"Northwind Books", "offers@northwind.example", "CATEGORY_PROMOTIONS", []string{"Books we think you'll love", "Your wishlist is on sale"}, 35, 0.85, unsubBrowser, true},
+	{"Lumen Coffee", "club@lumencoffee.example", "CATEGORY_PROMOTIONS", []string{"Your next bag ships Friday", "Meet this month's roaster"}, 24, 0.6, unsubBrowser, true},
+	{"Skyline Air", "fares@skylineair.example", "CATEGORY_PROMOTIONS", []string{"Fares from $49 this week"}, 30, 1, unsubBrowser, true},
+	{"Pixel Electronics", "news@pixel-electronics.example", "CATEGORY_PROMOTIONS", []string{"Flash sale ends tonight", "Order #%d has shipped"}, 28, 0.7, unsubBrowser, true},
+	{"dev-announce", "dev-announce@lists.example", "CATEGORY_FORUMS", []string{"[dev-announce] Release %d.0 is out", "[dev-announce] Maintenance window"}, 22, 0.5, unsubMailto, false},
+	{"Meetup Reminders", "reminders@meetups.example", "CATEGORY_SOCIAL", []string{"Reminder: Gophers Night is tomorrow"}, 14, 0.4, unsubMailto, false},
+	{"CodeHost", "notifications@codehost.example", "CATEGORY_UPDATES", []string{"[things] New issue opened", "[things] Pull request merged", "[things] CI failed on main"}, 80, 0.6, unsubNone, false},
+	{"First Bank", "alerts@firstbank.example", "CATEGORY_UPDATES", []string{"Your statement is ready", "Card payment received"}, 26, 0.2, unsubNone, true},
+	{"Parcel Tracker", "track@parcels.example", "CATEGORY_UPDATES", []string{"Your parcel is out for delivery", "Delivered: parcel %d"}, 32, 0.3, unsubNone, false},
+	{"Cloud Billing", "billing@cloud.example", "CATEGORY_UPDATES", []string{"Your invoice is available"}, 18, 0.1, unsubNone, false},
+	{"Alex Kim", "alex.kim@mail.example", "CATEGORY_PERSONAL", []string{"Lunch on Thursday?", "Photos from the trip"}, 8, 0.2, unsubNone, false},
+	{"Priya Raman", "priya@mail.example", "CATEGORY_PERSONAL", []string{"Re: draft review", "Quick question"}, 9, 0.1, unsubNone, false},
+	{"Jordan Ortiz", "jordan.ortiz@work.example", "CATEGORY_PERSONAL", []string{"Planning sync", "Re: Q3 roadmap"}, 11, 0.3, unsubNone, false},
+	{"Sam Taylor", "sam@mail.example", "CATEGORY_PERSONAL", []string{"Book club picks"}, 4, 0, unsubNone, false},
+}
+
+// bulkHeaders mark the automated mail of senders without an unsubscribe
+// link as bulk mail, each through a different header.
+var bulkHeaders = map[string]gmailv1.MessagePartHeader{
+	"notifications@codehost.example": {Name: "List-Id", Value: "niraj8/things <things.niraj8.codehost.example>"},
+	"alerts@firstbank.example":       {Name: "Precedence", Value: "bulk"},
+	"track@parcels.example":          {Name: "X-Mailer", Value: "SendGrid"},
+}
+
+// spamSenders fill the spam folder with a month of mail. The clinic is a
+// false positive, there to be rescued in spam review mode.
+var spamSenders = []sender{
+	{"Prize Center", "winner@prize-center.example", "", []string{"You have been selected!", "Claim your reward #%d"}, 18, 1, unsubNone, true},
+	{"Crypto Gains", "vip@cryptogains.example", "", []string{"Turn $100 into $10,000", "Last call for early investors"}, 14, 1, unsubNone, true},
+	{"Pharma Direct", "sales@pharma-direct.example", "", []string{"Lowest prices online"}, 10, 1, unsubNone, false},
+	{"Account Security", "security@acc0unt-verify.example", "", []string{"Verify your account now"}, 7, 1, unsubNone, false},
+	{"Riverside Clinic", "appointments@riversideclinic.example", "CATEGORY_UPDATES", []string{"Appointment reminder", "Your test results are ready"}, 3, 0.5, unsubNone, false},
+}
+
+// userLabels are the demo account's own labels.
+var userLabels = []*gmailv1.Label{
+	{Id: "Label_1", Name: "Receipts", Type: "user"},
+	{Id: "Label_2", Name: "Travel", Type: "user"},
+}
+
+var systemLabels = []string{
+	"INBOX", "SENT", "DRAFT", "SPAM", "TRASH", "UNREAD", "STARRED", "IMPORTANT",
+	"CATEGORY_PERSONAL", "CATEGORY_SOCIAL", "CATEGORY_PROMOTIONS", "CATEGORY_UPDATES", "CATEGORY_FORUMS",
+}
+
+// Mailbox is the synthetic account the server serves. Messages are kept in
+// Gmail's "full" format; history records every change after generation.
+type Mailbox struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	messages    []*gmailv1.Message // newest first
+	byID        map[string]*gmailv1.Message
+	attachments map[string]string // attachment ID -> base64url data
+	labels      []*gmailv1.Label
+	history     []*gmailv1.History
+	historyID   uint64
+	nextID      uint64
+	rng         *rand.Rand
+	links       links
+	delivered   int      // messages added by deliver
+	sent        [][]byte // raw messages sent, such as mailto: unsubscribes
+}
+
+// links are the base URLs unsubscribe and RSVP links point at.
+type links struct {
+	http, https string
+}
+
+// generate builds the mailbox. The content depends only on now, so tests
+// and screenshots are reproducible.
+func generate(now time.Time, l links) *Mailbox {
+	mb := &Mailbox{
+		now:         func() time.Time { return now },
+		byID:        map[string]*gmailv1.Message{},
+		attachments: map[string]string{},
+		historyID:   100000,
+		nextID:      0x18c2a4f000000000,
+		rng:         rand.New(rand.NewPCG(7, 11)),
+		links:       l,
+	}
+	for _, id := range systemLabels {
+		mb.labels = append(mb.labels, &gmailv1.Label{Id: id, Name: id, Type: "system"})
+	}
+	for _, l := range userLabels {
+		c := *l
+		mb.labels = append(mb.labels, &c)
+	}
+
+	for _, s := range senders {
+		for i := range s.count {
+			// Spread mail over two years, denser towards the present.
+			age := time.Duration(mb.rng.Float64()*mb.rng.Float64()*730*24) * time.Hour
+			mb.add(mb.message(s, i, now.Add(-age)))
+		}
+	}
+	mb.add(mb.invoice(now.Add(-50 * time.Hour)))
+	mb.add(mb.invite(now.Add(-3 * time.Hour)))
+	for _, s := range spamSenders {
+		for i := range s.count {
+			age := time.Duration(mb.rng.Float64()*30*24) * time.Hour
+			msg := mb.message(s, i, now.Add(-age))
+			msg.LabelIds = slices.DeleteFunc(msg.LabelIds, func(l string) bool { return l == "INBOX" })
+			msg.LabelIds = append(msg.LabelIds, "SPAM")
+			mb.add(msg)
+		}
+	}
+	mb.sort()
+	return mb
+}
+
+// add stores msg under a fresh ID and historyId.
+func (mb *Mailbox) add(msg *gmailv1.Message) {
+	mb.nextID++
+	mb.historyID++
+	msg.Id = fmt.Sprintf("%x", mb.nextID)
+	msg.ThreadId = msg.Id
+	msg.HistoryId = mb.historyID
+	mb.messages = append(mb.messages, msg)
+	mb.byID[msg.Id] = msg
+}
+
+func (mb *Mailbox) sort() {
+	slices.SortStableFunc(mb.messages, func(a, b *gmailv1.Message) int {
+		return cmp.Compare(b.InternalDate, a.InternalDate)
+	})
+}
+
+// message generates the i-th message of s.
+func (mb *Mailbox) message(s sender, i int, date time.Time) *gmailv1.Message {
+	subject := s.subjects[mb.rng.IntN(len(s.subjects))]
+	if strings.Contains(subject, "%d") {
+		subject = fmt.Sprintf(subject, 1000+i)
+	}
+	labels := []string{}
+	// A tenth of the older mail has been archived already.
+	if date.After(mb.now().AddDate(0, -3, 0)) || mb.rng.IntN(10) > 0 {
+		labels = append(labels, "INBOX")
+	}
+	if mb.rng.Float64() < s.unread {
+		labels = append(labels, "UNREAD")
+	}
+	if s.category != "" {
+		labels = append(labels, s.category)
+	}
+	if strings.Contains(subject, "Order #") || strings.Contains(subject, "invoice") {
+		labels = append(labels, "Label_1")
+	}
+	if s.addr == "fares@skylineair.example" && mb.rng.IntN(4) == 0 {
+		labels = append(labels, "Label_2")
+	}
+	if s.category == "CATEGORY_PERSONAL" && mb.rng.IntN(3) == 0 {
+		labels = append(labels, "IMPORTANT")
+	}
+
+	headers := mb.headers(s.name, s.addr, subject, date)
+	local, _, _ := strings.Cut(s.addr, "@")
+	site, _, _ := strings.Cut(domain(s.addr), ".")
+	list := local + "-" + site
+	unsubURL := ""
+	switch s.unsub {
+	case unsubOneClick:
+		unsubURL = mb.links.https + "/unsubscribe/" + list
+		headers = append(headers,
+			&gmailv1.MessagePartHeader{Name: "List-Unsubscribe", Value: fmt.Sprintf("<%s>, <mailto:unsubscribe@%s>", unsubURL, domain(s.addr))},
+			&gmailv1.MessagePartHeader{Name: "List-Unsubscribe-Post", Value: "List-Unsubscribe=One-Click"})
+	case unsubBrowser:
+		unsubURL = mb.links.http + "/unsubscribe/" + list
+		headers = append(headers, &gmailv1.MessagePartHeader{Name: "List-Unsubscribe", Value: "<" + unsubURL + ">"})
+	case unsubMailto:
+		headers = append(headers,
+			&gmailv1.MessagePartHeader{Name: "List-Unsubscribe", Value: "<mailto:leave-" + list + "@" + domain(s.addr) + ">"},
+			&gmailv1.MessagePartHeader{Name: "List-Id", Value: fmt.Sprintf("%s <%s.%s>", s.name, local, domain(s.addr))})
+	}
+	switch h, ok := bulkHeaders[s.addr]; {
+	case ok:
+		headers = append(headers, &h)
+	case s.unsub == unsubOneClick || s.unsub == unsubBrowser:
+		headers = append(headers, &gmailv1.MessagePartHeader{Name: "Precedence", Value: "bulk"})
+	case s.category == "CATEGORY_PERSONAL":
+		headers = append(headers, &gmailv1.MessagePartHeader{Name: "X-Mailer", Value: "Apple Mail (2.3774)"})
+	}
+
+	text := mb.bodyText(s, subject)
+	var payload *gmailv1.MessagePart
+	switch {
+	case s.html && unsubURL != "":
+		payload = multipart("multipart/alternative",
+			leaf("text/plain", text+"\n\nUnsubscribe: "+unsubURL+"\n"),
+			leaf("text/html", htmlBody(s.name, text, unsubURL)))
+	case s.html:
+		payload = leaf("text/html", htmlBody(s.name, text, ""))
+	default:
+		payload = leaf("text/plain", text)
+	}
+	return mb.finish(payload, headers, labels, date, text)
+}
+
+// invoice is a message with a PDF attachment served through the
+// attachments endpoint.
+func (mb *Mailbox) invoice(date time.Time) *gmailv1.Message {
+	text := "Hi,\n\nYour invoice for last month is attached. The amount will be charged to the card on file.\n\nThanks,\nCloud Billing"
+	aid := fmt.Sprintf("ANGjdJ_demo_%d", len(mb.attachments)+1)
+	mb.attachments[aid] = base64.URLEncoding.EncodeToString([]byte(samplePDF))
+	pdf := &gmailv1.MessagePart{
+		MimeType: "application/pdf",
+		Filename: "invoice-2026-09.pdf",
+		Headers:  []*gmailv1.MessagePartHeader{{Name: "Content-Type", Value: `application/pdf; name="invoice-2026-09.pdf"`}},
+		Body:     &gmailv1.MessagePartBody{AttachmentId: aid, Size: int64(len(samplePDF))},
+	}
+	payload := multipart("multipart/mixed", leaf("text/plain", text), pdf)
+	headers := mb.headers("Cloud Billing", "billing@cloud.example", "Your invoice is available", date)
+	return mb.finish(payload, headers, []string{"INBOX", "UNREAD", "CATEGORY_UPDATES", "Label_1"}, date, text)
+}
+
+// invite is a meeting invitation with an inline text/calendar part and
+// RSVP links.
+func (mb *Mailbox) invite(date time.Time) *gmailv1.Message {
+	start := date.Add(51 * time.Hour).Truncate(time.Hour).UTC()
+	text := "Jordan Ortiz has invited you to Design review.\n\nWhen: " + start.Format("Mon Jan 2, 2006 15:04 MST") + "\nWhere: Room 4B / video call"
+	rsvp := mb.links.http + "/rsvp/design-review?answer="
+	html := "<p>Jordan Ortiz has invited you to <b>Design review</b>.</p>" +
+		`<p>Going? <a href="` + rsvp + `yes">Yes</a> <a href="` + rsvp + `maybe">Maybe</a> <a href="` + rsvp + `no">No</a></p>`
+	cal := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//chuckterm//demo//EN",
+		"METHOD:REQUEST",
+		"BEGIN:VEVENT",
+		"UID:design-review@work.example",
+		"DTSTAMP:" + date.UTC().Format("20060102T150405Z"),
+		"DTSTART:" + start.Format("20060102T150405Z"),
+		"DTEND:" + start.Add(45*time.Minute).Format("20060102T150405Z"),
+		"SUMMARY:Design review",
+		"LOCATION:Room 4B / video call",
+		"ORGANIZER;CN=Jordan Ortiz:mailto:jordan.ortiz@work.example",
+		"STATUS:CONFIRMED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	payload := multipart("multipart/mixed",
+		multipart("multipart/alternative", leaf("text/plain", text), leaf("text/html", html)),
+		leaf("text/calendar", cal))
+	headers := mb.headers("Jordan Ortiz", "jordan.ortiz@work.example", "Invitation: Design review", date)
+	return mb.finish(payload, headers, []string{"INBOX", "UNREAD", "IMPORTANT", "CATEGORY_PERSONAL"}, date, text)
+}
+
+// deliver adds a new newsletter issue dated now, as if it had just arrived,
+// and records it in the history. It returns the new message.
+func (mb *Mailbox) deliver() *gmailv1.Message {
+	s := senders[mb.delivered%5]
+	mb.delivered++
+	msg := mb.message(s, s.count+mb.delivered, mb.now())
+	msg.LabelIds = slices.DeleteFunc(msg.LabelIds, func(l string) bool { return l == "INBOX" || l == "UNREAD" })
+	msg.LabelIds = append(msg.LabelIds, "INBOX", "UNREAD")
+	mb.add(msg)
+	mb.sort()
+	mb.history = append(mb.history, &gmailv1.History{
+		Id:            mb.historyID,
+		MessagesAdded: []*gmailv1.HistoryMessageAdded{{Message: &gmailv1.Message{Id: msg.Id, ThreadId: msg.ThreadId, LabelIds: msg.LabelIds}}},
+	})
+	return msg
+}
+
+func (mb *Mailbox) headers(name, addr, subject string, date time.Time) []*gmailv1.MessagePartHeader {
+	return []*gmailv1.MessagePartHeader{
+		{Name: "From", Value: fmt.Sprintf("%s <%s>", name, addr)},
+		{Name: "To", Value: "You <" + Address + ">"},
+		{Name: "Subject", Value: subject},
+		{Name: "Date", Value: date.Format(time.RFC1123Z)},
+		{Name: "Message-ID", Value: fmt.Sprintf("<%d.%s>", date.UnixNano(), addr)},
+	}
+}
+
+func (mb *Mailbox) finish(payload *gmailv1.MessagePart, headers []*gmailv1.MessagePartHeader, labels []string, date time.Time, text string) *gmailv1.Message {
+	payload.Headers = append(headers, payload.Headers...)
+	numberParts(payload, "")
+	snippet := strings.Join(strings.Fields(text), " ")
+	if len(snippet) > 100 {
+		snippet = snippet[:100]
+	}
+	return &gmailv1.Message{
+		LabelIds:     labels,
+		Snippet:      snippet,
+		InternalDate: date.UnixMilli(),
+		Payload:      payload,
+		SizeEstimate: int64(len(text)) + 2048,
+	}
+}
+
+var paragraphs = []string{
+	"Here is what caught our eye this week, from new releases to the talks everyone is sharing.",
+	"We picked a few things we think you will like, based on what you looked at recently.",
+	"Thanks for being with us. As always, reply to this email if you have any questions.",
+	"The team shipped several improvements, and a couple of long-standing bugs are finally fixed.",
+	"Prices shown are valid while supplies last. See the website for full terms and conditions.",
+	"Let me know what works for you and I will send an invite.",
+}
+
+func (mb *Mailbox) bodyText(s sender, subject string) string {
+	var b strings.Builder
+	if s.category == "CATEGORY_PERSONAL" {
+		b.WriteString("Hi,\n\n")
+	}
+	b.WriteString(subject + "\n\n")
+	for range 2 + mb.rng.IntN(3) {
+		b.WriteString(paragraphs[mb.rng.IntN(len(paragraphs))] + "\n\n")
+	}
+	b.WriteString("— " + s.name)
+	return b.String()
+}
+
+func htmlBody(name, text, unsubURL string) string {
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	for _, p := range strings.Split(text, "\n\n") {
+		b.WriteString("<p>" + strings.ReplaceAll(p, "\n", "<br>") + "</p>")
+	}
+	if unsubURL != "" {
+		b.WriteString(`<p style="font-size:small">You are receiving this because you subscribed to ` + name +
+			`. <a href="` + unsubURL + `">Unsubscribe</a></p>`)
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+func leaf(mime, body string) *gmailv1.MessagePart {
+	return &gmailv1.MessagePart{
+		MimeType: mime,
+		Headers:  []*gmailv1.MessagePartHeader{{Name: "Content-Type", Value: mime + "; charset=UTF-8"}},
+		Body:     &gmailv1.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(body)), Size: int64(len(body))},
+	}
+}
+
+func multipart(mime string, parts ...*gmailv1.MessagePart) *gmailv1.MessagePart {
+	return &gmailv1.MessagePart{
+		MimeType: mime,
+		Headers:  []*gmailv1.MessagePartHeader{{Name: "Content-Type", Value: mime + `; boundary="demo"`}},
+		Body:     &gmailv1.MessagePartBody{},
+		Parts:    parts,
+	}
+}
+
+// numberParts assigns part IDs the way Gmail does: "" for the root, then
+// "0", "1", ... and "1.0" for nested parts.
+func numberParts(p *gmailv1.MessagePart, id string) {
+	p.PartId = id
+	for i, sub := range p.Parts {
+		if id == "" {
+			numberParts(sub, fmt.Sprint(i))
+		} else {
+			numberParts(sub, fmt.Sprintf("%s.%d", id, i))
+		}
+	}
+}
+
+func domain(addr string) string {
+	_, d, _ := strings.Cut(addr, "@")
+	return d
+}
+
+// samplePDF is a one-page PDF saying "Invoice".
+const samplePDF = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >> endobj
+4 0 obj << /Length 44 >> stream
+BT /F1 24 Tf 72 700 Td (Invoice) Tj ET
+endstream endobj
+5 0 obj << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
@@ -0,0 +1,555 @@
+package demo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"mime"
+	mimemultipart "mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// Server serves a synthetic mailbox over the subset of the Gmail API that
+// chuckterm uses, plus the unsubscribe and RSVP pages its messages link
+// to. Both listeners are bound to 127.0.0.1.
+type Server struct {
+	// URL is the plain HTTP base URL the Gmail API is served on.
+	URL string
+	// TLSURL serves the same handler over HTTPS with a self-signed
+	// certificate, because one-click unsubscribe only POSTs to HTTPS links.
+	// Client trusts it.
+	TLSURL string
+
+	mailbox *Mailbox
+	plain   *http.Server
+	secure  *http.Server
+	certs   *x509.CertPool
+}
+
+// Start generates the mailbox as of now and starts serving it.
+func Start(now time.Time) (*Server, error) {
+	cert, pool, err := selfSigned()
+	if err != nil {
+		return nil, fmt.Errorf("demo certificate: %w", err)
+	}
+	pl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		pl.Close()
+		return nil, err
+	}
+	s := &Server{
+		URL:    "http://" + pl.Addr().String(),
+		TLSURL: "https://" + tl.Addr().String(),
+		certs:  pool,
+	}
+	s.mailbox = generate(now, links{http: s.URL, https: s.TLSURL})
+	s.mailbox.now = time.Now
+
+	h := s.handler()
+	s.plain = &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	s.secure = &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	go s.plain.Serve(pl)
+	go s.secure.Serve(tls.NewListener(tl, &tls.Config{Certificates: []tls.Certificate{cert}}))
+	return s, nil
+}
+
+// Close stops both listeners.
+func (s *Server) Close() error {
+	return errors.Join(s.plain.Close(), s.secure.Close())
+}
+
+// Service returns a Gmail client for the demo mailbox; it needs no
+// credentials. opts are added to the client's, such as an HTTP client.
+func (s *Server) Service(ctx context.Context, opts ...option.ClientOption) (*gmailv1.Service, error) {
+	opts = append([]option.ClientOption{option.WithEndpoint(s.URL + "/"), option.WithoutAuthentication()}, opts...)
+	return gmailv1.NewService(ctx, opts...)
+}
+
+// Client returns an HTTP client that trusts the server's certificate, for
+// sending one-click unsubscribe requests to TLSURL.
+func (s *Server) Client() *http.Client {
+	return &http.Client{
+		Timeout:   20 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: s.certs}},
+	}
+}
+
+func (s *Server) handler() http.Handler {
+	mb := s.mailbox
+	mux := http.NewServeMux()
+	api := "/gmail/v1/users/{user}"
+	mux.HandleFunc("GET "+api+"/profile", mb.profile)
+	mux.HandleFunc("GET "+api+"/labels", mb.listLabels)
+	mux.HandleFunc("POST "+api+"/labels", mb.createLabel)
+	mux.HandleFunc("GET "+api+"/messages", mb.listMessages)
+	mux.HandleFunc("GET "+api+"/messages/{id}", mb.getMessage)
+	mux.HandleFunc("POST "+api+"/messages/batchModify", mb.batchModify)
+	mux.HandleFunc("POST "+api+"/messages/batchDelete", mb.batchDelete)
+	mux.HandleFunc("POST "+api+"/messages/{id}/modify", mb.modifyMessage)
+	mux.HandleFunc("POST "+api+"/messages/{id}/trash", mb.trash)
+	mux.HandleFunc("POST "+api+"/messages/{id}/untrash", mb.untrash)
+	mux.HandleFunc("POST "+api+"/messages/send", mb.send)
+	mux.HandleFunc("GET "+api+"/messages/{msg}/attachments/{id}", mb.getAttachment)
+	mux.HandleFunc("GET "+api+"/history", mb.listHistory)
+	mux.HandleFunc("POST /batch/gmail/v1", func(w http.ResponseWriter, r *http.Request) { batch(mux, w, r) })
+	mux.HandleFunc("/unsubscribe/{list}", func(w http.ResponseWriter, r *http.Request) {
+		page(w, "Unsubscribed", "You will no longer receive "+r.PathValue("list")+" (demo).")
+	})
+	mux.HandleFunc("GET /rsvp/{event}", func(w http.ResponseWriter, r *http.Request) {
+		page(w, "Response sent", "You answered "+r.URL.Query().Get("answer")+" to "+r.PathValue("event")+" (demo).")
+	})
+	return mux
+}
+
+func (mb *Mailbox) profile(w http.ResponseWriter, r *http.Request) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	writeJSON(w, &gmailv1.Profile{
+		EmailAddress:  Address,
+		MessagesTotal: int64(len(mb.messages)),
+		ThreadsTotal:  int64(len(mb.messages)),
+		HistoryId:     mb.historyID,
+	})
+}
+
+func (mb *Mailbox) listLabels(w http.ResponseWriter, r *http.Request) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	writeJSON(w, &gmailv1.ListLabelsResponse{Labels: mb.labels})
+}
+
+func (mb *Mailbox) createLabel(w http.ResponseWriter, r *http.Request) {
+	var l gmailv1.Label
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil || l.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid label")
+		return
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for _, have := range mb.labels {
+		if have.Name == l.Name {
+			writeError(w, http.StatusConflict, "Label name exists or conflicts")
+			return
+		}
+	}
+	l.Id = fmt.Sprintf("Label_%d", len(mb.labels)-len(systemLabels)+1)
+	l.Type = "user"
+	mb.labels = append(mb.labels, &l)
+	writeJSON(w, &l)
+}
+
+// listMessages pages through the messages carrying every requested label,
+// newest first. The page token is the offset of the next page.
+func (mb *Mailbox) listMessages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	want := q["labelIds"]
+	spamTrash := q.Get("includeSpamTrash") == "true"
+	size, _ := strconv.Atoi(q.Get("maxResults"))
+	if size <= 0 || size > 500 {
+		size = 100
+	}
+	offset, _ := strconv.Atoi(q.Get("pageToken"))
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	var matched []*gmailv1.Message
+	for _, m := range mb.messages {
+		if !spamTrash && (slices.Contains(m.LabelIds, "SPAM") || slices.Contains(m.LabelIds, "TRASH")) {
+			continue
+		}
+		if !containsAll(m.LabelIds, want) {
+			continue
+		}
+		matched = append(matched, &gmailv1.Message{Id: m.Id, ThreadId: m.ThreadId})
+	}
+	resp := &gmailv1.ListMessagesResponse{ResultSizeEstimate: int64(len(matched))}
+	if offset < len(matched) {
+		end := min(offset+size, len(matched))
+		resp.Messages = matched[offset:end]
+		if end < len(matched) {
+			resp.NextPageToken = strconv.Itoa(end)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func (mb *Mailbox) getMessage(w http.ResponseWriter, r *http.Request) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	m, ok := mb.byID[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	out := *m
+	out.LabelIds = slices.Clone(m.LabelIds)
+	q := r.URL.Query()
+	switch q.Get("format") {
+	case "minimal":
+		out.Payload = nil
+	case "metadata":
+		names := q["metadataHeaders"]
+		var headers []*gmailv1.MessagePartHeader
+		for _, h := range m.Payload.Headers {
+			if len(names) == 0 || slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, h.Name) }) {
+				headers = append(headers, h)
+			}
+		}
+		out.Payload = &gmailv1.MessagePart{MimeType: m.Payload.MimeType, Headers: headers}
+	case "raw":
+		var raw strings.Builder
+		mb.writeRaw(&raw, m.Payload)
+		out.Raw = base64.URLEncoding.EncodeToString([]byte(raw.String()))
+		out.Payload = nil
+	}
+	writeJSON(w, &out)
+}
+
+// writeRaw renders a message part as MIME. Each multipart gets a boundary
+// of its own, and parts other than text are base64 encoded.
+func (mb *Mailbox) writeRaw(b *strings.Builder, p *gmailv1.MessagePart) {
+	boundary := "demo" + strings.ReplaceAll(p.PartId, ".", "-")
+	text := strings.HasPrefix(p.MimeType, "text/")
+	if p.PartId == "" {
+		b.WriteString("MIME-Version: 1.0\r\n")
+	}
+	for _, h := range p.Headers {
+		v := h.Value
+		if len(p.Parts) > 0 && h.Name == "Content-Type" {
+			v = fmt.Sprintf("%s; boundary=%q", p.MimeType, boundary)
+		}
+		fmt.Fprintf(b, "%s: %s\r\n", h.Name, v)
+	}
+	if len(p.Parts) == 0 && !text {
+		b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	}
+	b.WriteString("\r\n")
+	if len(p.Parts) == 0 {
+		data := p.Body.Data
+		if p.Body.AttachmentId != "" {
+			data = mb.attachments[p.Body.AttachmentId]
+		}
+		body, _ := base64.URLEncoding.DecodeString(data)
+		if text {
+			b.Write(body)
+			return
+		}
+		enc := base64.StdEncoding.EncodeToString(body)
+		for len(enc) > 76 {
+			b.WriteString(enc[:76] + "\r\n")
+			enc = enc[76:]
+		}
+		b.WriteString(enc)
+		return
+	}
+	for _, sub := range p.Parts {
+		fmt.Fprintf(b, "--%s\r\n", boundary)
+		mb.writeRaw(b, sub)
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(b, "--%s--\r\n", boundary)
+}
+
+func (mb *Mailbox) getAttachment(w http.ResponseWriter, r *http.Request) {
+	mb.mu.Lock()
+	data, ok := mb.attachments[r.PathValue("id")]
+	mb.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	writeJSON(w, &gmailv1.MessagePartBody{AttachmentId: r.PathValue("id"), Data: data, Size: int64(len(data)) * 3 / 4})
+}
+
+func (mb *Mailbox) batchModify(w http.ResponseWriter, r *http.Request) {
+	var req gmailv1.BatchModifyMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for _, id := range req.Ids {
+		mb.modify(id, req.AddLabelIds, req.RemoveLabelIds)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// batchDelete deletes messages for good and records it in the history.
+func (mb *Mailbox) batchDelete(w http.ResponseWriter, r *http.Request) {
+	var req gmailv1.BatchDeleteMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	h := &gmailv1.History{}
+	for _, id := range req.Ids {
+		m, ok := mb.byID[id]
+		if !ok {
+			continue
+		}
+		delete(mb.byID, id)
+		mb.messages = slices.DeleteFunc(mb.messages, func(x *gmailv1.Message) bool { return x == m })
+		h.MessagesDeleted = append(h.MessagesDeleted, &gmailv1.HistoryMessageDeleted{Message: &gmailv1.Message{Id: m.Id, ThreadId: m.ThreadId}})
+	}
+	if len(h.MessagesDeleted) > 0 {
+		mb.historyID++
+		h.Id = mb.historyID
+		mb.history = append(mb.history, h)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (mb *Mailbox) modifyMessage(w http.ResponseWriter, r *http.Request) {
+	var req gmailv1.ModifyMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	id := r.PathValue("id")
+	if _, ok := mb.byID[id]; !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	mb.modify(id, req.AddLabelIds, req.RemoveLabelIds)
+	writeJSON(w, mb.byID[id])
+}
+
+func (mb *Mailbox) trash(w http.ResponseWriter, r *http.Request) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	id := r.PathValue("id")
+	if _, ok := mb.byID[id]; !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	mb.modify(id, []string{"TRASH"}, []string{"INBOX", "UNREAD"})
+	writeJSON(w, mb.byID[id])
+}
+
+// untrash puts a trashed message back in the inbox.
+func (mb *Mailbox) untrash(w http.ResponseWriter, r *http.Request) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	id := r.PathValue("id")
+	if _, ok := mb.byID[id]; !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	mb.modify(id, []string{"INBOX"}, []string{"TRASH"})
+	writeJSON(w, mb.byID[id])
+}
+
+// send accepts a message and keeps it for Sent; it is not added to the
+// mailbox.
+func (mb *Mailbox) send(w http.ResponseWriter, r *http.Request) {
+	var req gmailv1.Message
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	raw, err := base64.URLEncoding.DecodeString(req.Raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid raw message")
+		return
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.sent = append(mb.sent, raw)
+	mb.nextID++
+	writeJSON(w, &gmailv1.Message{Id: fmt.Sprintf("%x", mb.nextID), LabelIds: []string{"SENT"}})
+}
+
+// Sent returns the raw messages sent through the server, oldest first.
+func (s *Server) Sent() [][]byte {
+	s.mailbox.mu.Lock()
+	defer s.mailbox.mu.Unlock()
+	return slices.Clone(s.mailbox.sent)
+}
+
+// modify changes the labels of message id and records the change in the
+// history. The caller holds mb.mu.
+func (mb *Mailbox) modify(id string, add, remove []string) {
+	m, ok := mb.byID[id]
+	if !ok {
+		return
+	}
+	var added, removed []string
+	for _, l := range remove {
+		if i := slices.Index(m.LabelIds, l); i >= 0 {
+			m.LabelIds = slices.Delete(m.LabelIds, i, i+1)
+			removed = append(removed, l)
+		}
+	}
+	for _, l := range add {
+		if !slices.Contains(m.LabelIds, l) {
+			m.LabelIds = append(m.LabelIds, l)
+			added = append(added, l)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	mb.historyID++
+	m.HistoryId = mb.historyID
+	ref := &gmailv1.Message{Id: m.Id, ThreadId: m.ThreadId, LabelIds: slices.Clone(m.LabelIds)}
+	h := &gmailv1.History{Id: mb.historyID}
+	if len(added) > 0 {
+		h.LabelsAdded = []*gmailv1.HistoryLabelAdded{{LabelIds: added, Message: ref}}
+	}
+	if len(removed) > 0 {
+		h.LabelsRemoved = []*gmailv1.HistoryLabelRemoved{{LabelIds: removed, Message: ref}}
+	}
+	mb.history = append(mb.history, h)
+}
+
+// listHistory returns the changes after startHistoryId. Every call first
+// delivers one new message so that syncing in the demo has something to
+// show.
+func (mb *Mailbox) listHistory(w http.ResponseWriter, r *http.Request) {
+	start, err := strconv.ParseUint(r.URL.Query().Get("startHistoryId"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid startHistoryId")
+		return
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.deliver()
+	resp := &gmailv1.ListHistoryResponse{HistoryId: mb.historyID}
+	for _, h := range mb.history {
+		if h.Id > start {
+			resp.History = append(resp.History, h)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func containsAll(have, want []string) bool {
+	for _, l := range want {
+		if !slices.Contains(have, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// batch answers a batch request by serving each request it carries with h,
+// in order, as Gmail's batch endpoint does.
+func batch(h http.Handler, w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid batch request.")
+		return
+	}
+	var body bytes.Buffer
+	mw := mimemultipart.NewWriter(&body)
+	mr := mimemultipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid batch request.")
+			return
+		}
+		inner, err := http.ReadRequest(bufio.NewReader(part))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid batch request.")
+			return
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, inner.WithContext(r.Context()))
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {"<response-" + strings.Trim(part.Header.Get("Content-Id"), "<>") + ">"},
+		})
+		fmt.Fprintf(pw, "HTTP/1.1 %d %s\r\nContent-Type: %s\r\n\r\n", rec.Code, http.StatusText(rec.Code), rec.Header().Get("Content-Type"))
+		pw.Write(rec.Body.Bytes())
+	}
+	mw.Close()
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.Write(body.Bytes())
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers in the Gmail API's error format, which the client
+// library turns into a *googleapi.Error.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": code, "message": msg},
+	})
+}
+
+func page(w http.ResponseWriter, title, text string) {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	fmt.Fprintf(w, "<!doctype html><title>%s</title><h1>%[1]s</h1><p>%s</p>\n", html.EscapeString(title), html.EscapeString(text))
+}
+
+// selfSigned creates a short-lived certificate for 127.0.0.1 and a pool
+// that trusts it.
+func selfSigned() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "chuckterm demo"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(7 * 24 * time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
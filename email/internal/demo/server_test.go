@@ -0,0 +1,611 @@
+package demo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	mimemultipart "mime/multipart"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+
+	"google.golang.org/api/option"
+)
+
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	srv, err := Start(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	a := generate(now, links{http: "http://a", https: "https://a"})
+	b := generate(now, links{http: "http://a", https: "https://a"})
+	if len(a.messages) != len(b.messages) {
+		t.Fatalf("message counts differ: %d vs %d", len(a.messages), len(b.messages))
+	}
+	for i := range a.messages {
+		if a.messages[i].Id != b.messages[i].Id || a.messages[i].Snippet != b.messages[i].Snippet {
+			t.Fatalf("message %d differs", i)
+		}
+	}
+}
+
+func TestSyncAndActions(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := db.LoadAllMessages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) < 500 {
+		t.Fatalf("cached %d inbox messages, want a full mailbox", len(msgs))
+	}
+
+	groups := gmail.SortGroups(gmail.AggregateBySenderSubject(msgs))
+	var oneClick, invite string
+	var archive []string
+	for _, g := range groups {
+		if g.UnsubscribeOneClick && oneClick == "" {
+			oneClick = g.UnsubscribeURL
+		}
+		if g.Subject == "Invitation: Design review" {
+			invite = g.MessageIDs[0]
+		}
+		if g.Email == "fares@skylineair.example" {
+			archive = g.MessageIDs
+		}
+	}
+	if oneClick == "" || invite == "" || len(archive) == 0 {
+		t.Fatalf("missing demo senders: oneClick=%q invite=%q archive=%d", oneClick, invite, len(archive))
+	}
+	// Precedence, X-Mailer and List-Id mark automated mail as bulk.
+	bulk := map[string]bool{}
+	for _, g := range gmail.BySender(groups) {
+		bulk[g.Email] = g.Bulk
+	}
+	for addr, want := range map[string]bool{
+		"notifications@codehost.example": true,
+		"alerts@firstbank.example":       true,
+		"track@parcels.example":          true,
+		"billing@cloud.example":          false,
+		"alex.kim@mail.example":          false,
+	} {
+		if bulk[addr] != want {
+			t.Fatalf("%s: Bulk = %v, want %v", addr, bulk[addr], want)
+		}
+	}
+
+	body, err := gmail.GetMessageBody(ctx, svc, invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Invites) != 1 || body.Invites[0].Summary != "Design review" || len(body.Invites[0].RSVP) != 3 {
+		t.Fatalf("invite = %+v", body.Invites)
+	}
+
+	gmail.SetUnsubscribeClient(srv.Client())
+	if err := gmail.OneClickUnsubscribe(ctx, oneClick); err != nil {
+		t.Fatalf("one-click unsubscribe: %v", err)
+	}
+
+	if err := gmail.ArchiveMessages(ctx, svc, archive); err != nil {
+		t.Fatal(err)
+	}
+	hid, err := db.GetLastHistoryID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gmail.SyncSinceHistory(ctx, svc, db, hid, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	after, err := db.CountMessages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The archived group leaves the inbox and one new message arrives.
+	if want := len(msgs) - len(archive) + 1; after != want {
+		t.Fatalf("after sync: %d messages, want %d", after, want)
+	}
+}
+
+func TestBatchSync(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	scan := func(batch bool) []model.MessageRef {
+		hc := &http.Client{}
+		svc, err := srv.Service(ctx, option.WithHTTPClient(hc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if batch {
+			gmail.EnableBatch(svc, hc)
+		}
+		db := store.NewMemoryStore()
+		if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		msgs, err := db.LoadAllMessages(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msgs
+	}
+	single, batched := scan(false), scan(true)
+	if len(batched) != len(single) {
+		t.Fatalf("batched scan cached %d messages; one get per message cached %d", len(batched), len(single))
+	}
+	byID := make(map[string]model.MessageRef, len(single))
+	for _, m := range single {
+		byID[m.ID] = m
+	}
+	for _, m := range batched {
+		if s := byID[m.ID]; s.From != m.From || s.Subject != m.Subject || s.DateRFC3339 != m.DateRFC3339 {
+			t.Fatalf("message %s differs: %+v vs %+v", m.ID, m, s)
+		}
+	}
+}
+
+func TestSyncTrashesBlockedSenders(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := db.CountMessages(ctx)
+	// The next sync delivers an issue from the first sender.
+	domain := senders[0].addr[strings.IndexByte(senders[0].addr, '@'):]
+	if err := db.SetSenderStatus(ctx, domain, model.SenderBlocked); err != nil {
+		t.Fatal(err)
+	}
+	var delivered []model.MessageRef
+	hid, _ := db.GetLastHistoryID(ctx)
+	opts := gmail.SyncOptions{Label: "INBOX", Triage: gmail.NewTriage(nil), NewMessages: func(m []model.MessageRef) { delivered = append(delivered, m...) }}
+	if err := gmail.SyncSinceHistory(ctx, svc, db, hid, opts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := db.CountMessages(ctx); after != before || len(delivered) != 0 {
+		t.Fatalf("after sync: %d messages (was %d), %d reported new", after, before, len(delivered))
+	}
+	actions, _ := db.LoadActions(ctx, time.Time{})
+	if len(actions) != 1 || actions[0].Kind != "trash" || actions[0].Sender != senders[0].addr {
+		t.Fatalf("actions = %+v", actions)
+	}
+	last := srv.mailbox.messages[0]
+	for _, m := range srv.mailbox.messages {
+		if m.HistoryId > last.HistoryId {
+			last = m
+		}
+	}
+	if !slices.Contains(last.LabelIds, "TRASH") {
+		t.Fatalf("delivered message labels = %v, want TRASH", last.LabelIds)
+	}
+}
+
+// countingTransport counts the requests that would change mail.
+type countingTransport struct{ changes int }
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method == http.MethodPost {
+		c.changes++
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestReadonlySyncLeavesBlockedSenders(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	ct := &countingTransport{}
+	svc, err := srv.Service(ctx, option.WithHTTPClient(&http.Client{Transport: ct}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := db.CountMessages(ctx)
+	domain := senders[0].addr[strings.IndexByte(senders[0].addr, '@'):]
+	if err := db.SetSenderStatus(ctx, domain, model.SenderBlocked); err != nil {
+		t.Fatal(err)
+	}
+	// chuckterm-report syncs without Triage, holding only the readonly
+	// scope.
+	hid, _ := db.GetLastHistoryID(ctx)
+	if err := gmail.SyncSinceHistory(ctx, svc, db, hid, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ct.changes != 0 {
+		t.Fatalf("read-only sync sent %d POST requests", ct.changes)
+	}
+	if after, _ := db.CountMessages(ctx); after != before+1 {
+		t.Fatalf("after sync: %d messages, want %d", after, before+1)
+	}
+}
+
+func TestRunRules(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := db.LoadAllMessages(ctx)
+	count := func(msgs []model.MessageRef, from string, unread bool) int {
+		n := 0
+		for _, m := range msgs {
+			if m.From == from && (!unread || m.Unread()) {
+				n++
+			}
+		}
+		return n
+	}
+	archived, protected, read := senders[0].addr, senders[1].addr, senders[2].addr
+	for _, text := range []string{
+		"from:" + archived + " -> archive",
+		"from:" + protected + " -> archive",
+		"from:" + read + " -> read",
+	} {
+		r, err := gmail.ParseRule(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.SaveRule(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.SetSenderStatus(ctx, protected, model.SenderProtected)
+
+	results, err := gmail.RunRules(ctx, svc, db, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := gmail.RulesSummary(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("rules archived %d and marked %d read", count(before, archived, false), count(before, read, true))
+	if summary != want {
+		t.Fatalf("summary = %q, want %q", summary, want)
+	}
+	after, _ := db.LoadAllMessages(ctx)
+	if count(after, archived, false) != 0 || count(after, protected, false) != count(before, protected, false) || count(after, read, true) != 0 {
+		t.Fatal("the cache does not reflect the rules")
+	}
+	// A second pass finds nothing left to do.
+	results, _ = gmail.RunRules(ctx, svc, db, time.Now())
+	if summary, _ := gmail.RulesSummary(results); summary != "" {
+		t.Fatalf("second pass: %q", summary)
+	}
+}
+
+func TestRunDueRules(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	job, err := gmail.ParseRule("has:unsubscribe every:@daily -> archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID, err = db.SaveRule(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	// Scheduled rules do not run after a sync.
+	if results, _ := gmail.RunRules(ctx, svc, db, time.Now()); len(results) != 0 {
+		t.Fatalf("RunRules ran %+v", results)
+	}
+
+	now := time.Now()
+	results, err := gmail.RunDueRules(ctx, svc, db, now)
+	if err != nil || len(results) != 1 || results[0].Messages == 0 || results[0].Err != nil {
+		t.Fatalf("RunDueRules = %+v, %v", results, err)
+	}
+	archived := results[0].Messages
+	msgs, _ := db.LoadAllMessages(ctx)
+	for _, m := range msgs {
+		if m.ListUnsubscribe != "" {
+			t.Fatalf("%s from %s was not archived", m.ID, m.From)
+		}
+	}
+	if results, _ := gmail.RunDueRules(ctx, svc, db, now.Add(time.Hour)); len(results) != 0 {
+		t.Fatalf("ran again within the day: %+v", results)
+	}
+	if results, _ := gmail.RunDueRules(ctx, svc, db, now.Add(25*time.Hour)); len(results) != 1 || results[0].Messages != 0 {
+		t.Fatalf("next day = %+v", results)
+	}
+	runs, err := db.LoadRuleRuns(ctx, 10)
+	if err != nil || len(runs) != 2 || runs[0].RuleID != job.ID || runs[0].Messages != 0 || runs[1].Messages != archived {
+		t.Fatalf("logged runs = %+v, %v", runs, err)
+	}
+}
+
+func TestMailtoUnsubscribe(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := gmail.LoadGroupsFromDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(groups, func(g model.SenderGroup) bool { return g.UnsubscribeURL == "" && g.UnsubscribeMailto != "" })
+	if i < 0 {
+		t.Fatal("no mailto-only group")
+	}
+	g := groups[i]
+	if err := gmail.MailtoUnsubscribe(ctx, svc, g.UnsubscribeMailto); err != nil {
+		t.Fatal(err)
+	}
+	sent := srv.Sent()
+	to := "To: " + strings.TrimPrefix(g.UnsubscribeMailto, "mailto:")
+	if len(sent) != 1 || !strings.Contains(string(sent[0]), to) || !strings.Contains(string(sent[0]), "Subject: unsubscribe") {
+		t.Fatalf("sent %q, want a message %s", sent, to)
+	}
+
+	if err := gmail.RecordUnsubscribe(ctx, db, g.Email, gmail.UnsubscribeMailto, g.UnsubscribeMailto); err != nil {
+		t.Fatal(err)
+	}
+	unsubs, err := gmail.LoadUnsubscribes(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gmail.ApplyUnsubscribes(groups, unsubs)
+	for _, other := range groups {
+		if done := other.Unsubscribed != nil; done != (other.Email == g.Email) {
+			t.Fatalf("%s unsubscribed = %v", other.Email, done)
+		}
+	}
+	if u := groups[i].Unsubscribed; u.Method != "mailto" || u.URL != g.UnsubscribeMailto {
+		t.Fatalf("Unsubscribed = %+v", u)
+	}
+}
+
+func TestRawMessage(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id string
+	for _, m := range srv.mailbox.messages {
+		if m.Payload.MimeType == "multipart/mixed" && m.Payload.Parts[1].Body.AttachmentId != "" {
+			id = m.Id
+		}
+	}
+	if id == "" {
+		t.Fatal("no message with an attachment")
+	}
+	raw, err := gmail.GetRawMessage(ctx, svc, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+	parts := 0
+	mr := mimemultipart.NewReader(msg.Body, params["boundary"])
+	for {
+		if _, err := mr.NextPart(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("part %d: %v", parts, err)
+		}
+		parts++
+	}
+	if parts < 2 {
+		t.Errorf("raw message has %d parts, want the body and the attachment", parts)
+	}
+}
+
+func TestSnooze(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := db.LoadAllMessages(ctx)
+	if err != nil || len(msgs) < 2 {
+		t.Fatalf("cached %d messages, %v", len(msgs), err)
+	}
+	now := time.Now()
+	until := now.Add(2 * time.Hour)
+	if err := gmail.SnoozeMessages(ctx, svc, db, msgs[:2], until); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := db.LoadAllMessages(ctx); len(left) != len(msgs)-2 {
+		t.Fatalf("cache has %d messages after snoozing 2 of %d", len(left), len(msgs))
+	}
+	got, err := svc.Users.Messages.Get("me", msgs[0].ID).Format("minimal").Do()
+	if err != nil || slices.Contains(got.LabelIds, "INBOX") {
+		t.Fatalf("snoozed message labels = %v, %v", got.LabelIds, err)
+	}
+
+	if woken, err := gmail.WakeSnoozes(ctx, svc, db, now.Add(time.Hour)); err != nil || len(woken) != 0 {
+		t.Fatalf("woke %+v early, %v", woken, err)
+	}
+	woken, err := gmail.WakeSnoozes(ctx, svc, db, until)
+	if err != nil || len(woken) != 2 {
+		t.Fatalf("woke %+v, %v", woken, err)
+	}
+	back, _ := db.LoadAllMessages(ctx)
+	i := slices.IndexFunc(back, func(m model.MessageRef) bool { return m.ID == msgs[0].ID })
+	if len(back) != len(msgs) || i < 0 || !slices.Contains(back[i].LabelIDs, "INBOX") || back[i].From != msgs[0].From {
+		t.Fatalf("after waking: %d cached, message %+v", len(back), back[max(i, 0)])
+	}
+	if left, err := gmail.LoadSnoozes(ctx, db); err != nil || len(left) != 0 {
+		t.Fatalf("snoozes left = %+v, %v", left, err)
+	}
+}
+
+func TestTrashRestore(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, db, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := db.LoadAllMessages(ctx)
+	if err != nil || len(msgs) < 2 {
+		t.Fatalf("cached %d messages, %v", len(msgs), err)
+	}
+	now := time.Now()
+	trashed := msgs[:2]
+	ids := []string{trashed[0].ID, trashed[1].ID}
+	if err := gmail.TrashMessages(ctx, svc, ids); err != nil {
+		t.Fatal(err)
+	}
+	if err := gmail.RememberTrashed(ctx, db, trashed, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteMessages(ctx, ids); err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := gmail.ListTrash(ctx, svc)
+	if err != nil || !slices.ContainsFunc(live, func(m model.Trashed) bool { return m.ID == ids[0] }) {
+		t.Fatalf("ListTrash = %d messages, %v; want %s among them", len(live), err, ids[0])
+	}
+	logged, err := gmail.LoadTrashed(ctx, db, now)
+	if err != nil || len(logged) != 2 || !slices.Contains(ids, logged[0].ID) || logged[0].Subject == "" {
+		t.Fatalf("LoadTrashed = %+v, %v", logged, err)
+	}
+	if later, _ := gmail.LoadTrashed(ctx, db, now.Add(gmail.TrashRetention+time.Hour)); len(later) != 0 {
+		t.Fatalf("LoadTrashed after Gmail empties the trash = %+v", later)
+	}
+	if err := gmail.RememberTrashed(ctx, db, trashed, now); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := gmail.UntrashMessages(ctx, svc, db, ids)
+	if err != nil || n != 2 {
+		t.Fatalf("UntrashMessages = %d, %v", n, err)
+	}
+	back, _ := db.LoadAllMessages(ctx)
+	i := slices.IndexFunc(back, func(m model.MessageRef) bool { return m.ID == ids[0] })
+	if len(back) != len(msgs) || i < 0 || slices.Contains(back[i].LabelIDs, "TRASH") || back[i].From != trashed[0].From {
+		t.Fatalf("after restoring: %d cached, message %+v", len(back), back[max(i, 0)])
+	}
+	if left, err := gmail.LoadTrashed(ctx, db, now); err != nil || len(left) != 0 {
+		t.Fatalf("trash log left = %+v, %v", left, err)
+	}
+}
+
+func TestSpamReview(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	svc, err := srv.Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := store.NewMemoryStore()
+	opts := gmail.SyncOptions{Label: gmail.SpamLabel}
+	if err := gmail.FullScan(ctx, svc, db, opts, nil); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := db.LoadAllMessages(ctx)
+	if err != nil || len(msgs) == 0 {
+		t.Fatalf("cached %d spam messages, %v", len(msgs), err)
+	}
+	var rescue, junk []string
+	for _, m := range msgs {
+		if !slices.Contains(m.LabelIDs, "SPAM") {
+			t.Fatalf("cached %s without the SPAM label: %v", m.ID, m.LabelIDs)
+		}
+		if m.From == "appointments@riversideclinic.example" {
+			rescue = append(rescue, m.ID)
+		} else {
+			junk = append(junk, m.ID)
+		}
+	}
+	if len(rescue) == 0 {
+		t.Fatal("the false positive is not in spam")
+	}
+
+	if err := gmail.NotSpam(ctx, svc, rescue); err != nil {
+		t.Fatal(err)
+	}
+	if err := gmail.DeleteForever(ctx, svc, junk); err != nil {
+		t.Fatal(err)
+	}
+	hid, err := db.GetLastHistoryID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gmail.SyncSinceHistory(ctx, svc, db, hid, opts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := db.LoadAllMessages(ctx); len(left) != 0 {
+		t.Fatalf("spam cache keeps %d messages after the review", len(left))
+	}
+
+	inbox := store.NewMemoryStore()
+	if err := gmail.FullScan(ctx, svc, inbox, gmail.SyncOptions{Label: "INBOX"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := inbox.LoadAllMessages(ctx)
+	if !slices.ContainsFunc(all, func(m model.MessageRef) bool { return m.ID == rescue[0] }) {
+		t.Fatalf("rescued message %s is not in the inbox", rescue[0])
+	}
+	if _, err := svc.Users.Messages.Get("me", junk[0]).Do(); err == nil {
+		t.Fatalf("deleted message %s is still there", junk[0])
+	}
+}
@@ -0,0 +1,196 @@
+// Package emlimport ingests RFC 822 .eml files dropped into a directory into
+// the message cache, optionally importing them into Gmail first.
+package emlimport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/util"
+)
+
+// Store is the subset of the message store the importer writes to.
+type Store interface {
+	UpsertMessages(ctx context.Context, msgs []model.MessageRef) error
+}
+
+// Importer uploads a raw message to the mail provider and returns its ID.
+type Importer func(ctx context.Context, raw []byte) (string, error)
+
+// Result summarises one pass over the watch directory.
+type Result struct {
+	Imported int
+	Failed   []string // file names moved to failed/, with why
+	Retry    []string // file names left in place after an error the next pass may not hit, with the error
+}
+
+// settleTime is how long a file must be left untouched before it is picked up,
+// so half-copied files are not ingested.
+var settleTime = time.Second
+
+// ImportDir ingests every settled .eml file in dir. Processed files move to
+// dir/imported and unparseable ones to dir/failed; files that hit any other
+// error, such as a rate-limited import, stay to be tried again. A file never
+// replaces one of the same name already moved: it gets a numbered name.
+// When importer is nil the messages are cached locally under a
+// content-derived model.LocalIDPrefix ID.
+func ImportDir(ctx context.Context, dir string, store Store, importer Importer) (Result, error) {
+	var res Result
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return res, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".eml") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < settleTime {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		path := filepath.Join(dir, e.Name())
+		if err := importFile(ctx, path, store, importer); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return res, ctxErr
+			}
+			var perr parseError
+			if !errors.As(err, &perr) {
+				res.Retry = append(res.Retry, fmt.Sprintf("%s: %v", e.Name(), err))
+				continue
+			}
+			res.Failed = append(res.Failed, fmt.Sprintf("%s: %v", e.Name(), err))
+			if merr := moveTo(dir, "failed", e.Name()); merr != nil {
+				return res, merr
+			}
+			continue
+		}
+		if err := moveTo(dir, "imported", e.Name()); err != nil {
+			return res, err
+		}
+		res.Imported++
+	}
+	return res, nil
+}
+
+// Watch polls dir every interval until ctx is cancelled, reporting each pass
+// that did something through report.
+func Watch(ctx context.Context, dir string, interval time.Duration, store Store, importer Importer, report func(Result, error)) error {
+	for {
+		res, err := ImportDir(ctx, dir, store, importer)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if report != nil && (err != nil || res.Imported > 0 || len(res.Failed) > 0 || len(res.Retry) > 0) {
+			report(res, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// parseError is a file that is no message chuckterm can cache, which no
+// retry will change.
+type parseError struct{ error }
+
+func (e parseError) Unwrap() error { return e.error }
+
+func importFile(ctx context.Context, path string, store Store, importer Importer) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ref, err := Parse(raw)
+	if err != nil {
+		return parseError{err}
+	}
+	// Dropped mail is treated as newly delivered, matching what the Gmail
+	// importer applies.
+	ref.LabelIDs = []string{"INBOX", "UNREAD"}
+	if importer != nil {
+		id, err := importer(ctx, raw)
+		if err != nil {
+			return fmt.Errorf("import to mailbox: %w", err)
+		}
+		ref.ID = id
+	}
+	return store.UpsertMessages(ctx, []model.MessageRef{ref})
+}
+
+// Parse extracts cache metadata from a raw RFC 822 message. The ID is derived
+// from the content so re-importing the same file is idempotent.
+func Parse(raw []byte) (model.MessageRef, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return model.MessageRef{}, fmt.Errorf("parse message: %w", err)
+	}
+	rawFrom := decodeHeader(msg.Header.Get("From"))
+	from := util.NormalizeSender(rawFrom)
+	if from == "" {
+		return model.MessageRef{}, fmt.Errorf("message has no parseable From header")
+	}
+	sum := sha256.Sum256(raw)
+	ref := model.MessageRef{
+		ID:                  model.LocalIDPrefix + hex.EncodeToString(sum[:12]),
+		From:                from,
+		FromName:            util.SenderName(rawFrom),
+		Subject:             decodeHeader(msg.Header.Get("Subject")),
+		ListUnsubscribe:     msg.Header.Get("List-Unsubscribe"),
+		ListUnsubscribePost: msg.Header.Get("List-Unsubscribe-Post"),
+		Precedence:          msg.Header.Get("Precedence"),
+		XMailer:             msg.Header.Get("X-Mailer"),
+		ListID:              msg.Header.Get("List-Id"),
+	}
+	if t, err := msg.Header.Date(); err == nil {
+		ref.DateRFC3339 = t.UTC().Format(time.RFC3339)
+	}
+	return ref, nil
+}
+
+func decodeHeader(v string) string {
+	dec := mime.WordDecoder{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		// Unknown charsets are passed through undecoded rather than failing.
+		return input, nil
+	}}
+	if out, err := dec.DecodeHeader(v); err == nil {
+		return out
+	}
+	return v
+}
+
+// moveTo moves dir/name into dir/sub, numbering it "name-2.eml" and so on
+// when sub already holds a file of that name.
+func moveTo(dir, sub, name string) error {
+	dst := filepath.Join(dir, sub)
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	ext := filepath.Ext(name)
+	target := filepath.Join(dst, name)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(target); errors.Is(err, os.ErrNotExist) {
+			break
+		} else if err != nil {
+			return err
+		}
+		target = filepath.Join(dst, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext))
+	}
+	return os.Rename(filepath.Join(dir, name), target)
+}
@@ -0,0 +1,124 @@
+package emlimport
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+const sample = "From: =?UTF-8?Q?Caf=C3=A9?= <News+weekly@Example.com>\r\n" +
+	"Subject: =?UTF-8?Q?Caf=C3=A9_digest?=\r\n" +
+	"Date: Mon, 2 Jan 2006 15:04:05 -0700\r\n" +
+	"List-Unsubscribe: <https://example.com/u>\r\n" +
+	"\r\n" +
+	"Hello\r\n"
+
+type memStore struct{ msgs []model.MessageRef }
+
+func (s *memStore) UpsertMessages(ctx context.Context, msgs []model.MessageRef) error {
+	s.msgs = append(s.msgs, msgs...)
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	ref, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if ref.From != "news@example.com" {
+		t.Errorf("From = %q", ref.From)
+	}
+	if ref.Subject != "Café digest" {
+		t.Errorf("Subject = %q", ref.Subject)
+	}
+	if ref.DateRFC3339 != "2006-01-02T22:04:05Z" {
+		t.Errorf("Date = %q", ref.DateRFC3339)
+	}
+	if !strings.HasPrefix(ref.ID, model.LocalIDPrefix) {
+		t.Errorf("ID = %q", ref.ID)
+	}
+	if _, err := Parse([]byte("Subject: no sender\r\n\r\nx")); err == nil {
+		t.Error("expected error for message without From")
+	}
+}
+
+func TestImportDir(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Minute)
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0o600)
+		os.Chtimes(p, old, old)
+	}
+	write("good.eml", sample)
+	write("bad.eml", "not a message")
+	write("notes.txt", "ignored")
+
+	store := &memStore{}
+	res, err := ImportDir(context.Background(), dir, store, func(ctx context.Context, raw []byte) (string, error) {
+		return "gmail-id-1", nil
+	})
+	if err != nil {
+		t.Fatalf("ImportDir: %v", err)
+	}
+	if res.Imported != 1 || len(res.Failed) != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+	if len(store.msgs) != 1 || store.msgs[0].ID != "gmail-id-1" {
+		t.Fatalf("stored = %+v", store.msgs)
+	}
+	for _, p := range []string{"imported/good.eml", "failed/bad.eml", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+			t.Errorf("expected %s: %v", p, err)
+		}
+	}
+}
+
+func TestImportDirRetriesAndKeepsNames(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Minute)
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0o600)
+		os.Chtimes(p, old, old)
+	}
+	// Files of these names were moved by an earlier pass.
+	for _, p := range []string{"imported/good.eml", "failed/bad.eml"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0o755)
+		os.WriteFile(filepath.Join(dir, p), []byte("earlier"), 0o600)
+	}
+	write("good.eml", sample)
+	write("bad.eml", "not a message")
+	write("limited.eml", strings.Replace(sample, "Hello", "Later", 1))
+
+	store := &memStore{}
+	res, err := ImportDir(context.Background(), dir, store, func(ctx context.Context, raw []byte) (string, error) {
+		if strings.Contains(string(raw), "Later") {
+			return "", errors.New("googleapi: Error 429: Too many requests")
+		}
+		return "gmail-id-1", nil
+	})
+	if err != nil {
+		t.Fatalf("ImportDir: %v", err)
+	}
+	if res.Imported != 1 || len(res.Failed) != 1 || len(res.Retry) != 1 || !strings.HasPrefix(res.Retry[0], "limited.eml: ") {
+		t.Fatalf("result = %+v", res)
+	}
+	for p, want := range map[string]string{
+		"imported/good.eml":   "earlier",
+		"imported/good-2.eml": sample,
+		"failed/bad.eml":      "earlier",
+		"failed/bad-2.eml":    "not a message",
+		"limited.eml":         strings.Replace(sample, "Hello", "Later", 1),
+	} {
+		if b, err := os.ReadFile(filepath.Join(dir, p)); err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v; want %q", p, b, err, want)
+		}
+	}
+}
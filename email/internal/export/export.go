@@ -0,0 +1,167 @@
+// Package export writes raw RFC 822 messages to an mbox file or to a
+// directory of .eml files, for keeping a copy of mail before it is trashed.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Format is the on-disk layout of an export.
+type Format string
+
+const (
+	Mbox Format = "mbox" // one mboxrd file
+	EML  Format = "eml"  // a directory with one .eml file per message
+)
+
+// ParseFormat reads a format name. "" picks the format from path: mbox for
+// a .mbox or .mbx file, otherwise eml.
+func ParseFormat(s, path string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case Mbox, EML:
+		return f, nil
+	case "":
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".mbox" || ext == ".mbx" {
+			return Mbox, nil
+		}
+		return EML, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (want mbox or eml)", s)
+}
+
+// Message is one message to export.
+type Message struct {
+	ID   string
+	Raw  []byte    // the RFC 822 source, as Gmail returns it with format=raw
+	Date time.Time // when it was received; zero to read the Date header
+}
+
+// Writer adds messages to an export. Close must be called to finish it.
+type Writer interface {
+	Write(m Message) error
+	Close() error
+}
+
+// Create starts an export at path: an mbox file, which must not exist yet,
+// or a directory of .eml files, which is created if needed.
+func Create(path string, f Format) (Writer, error) {
+	switch f {
+	case Mbox:
+		return createMbox(path)
+	case EML:
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			return nil, err
+		}
+		return &emlWriter{dir: path}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", f)
+}
+
+// mboxWriter writes the mboxrd variant: lines of the message that start with
+// "From " after any number of '>' get one more '>', so readers can undo the
+// quoting exactly. The file is written under a temporary name and renamed
+// into place by Close, so an interrupted export leaves no partial mbox.
+type mboxWriter struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+}
+
+func createMbox(path string) (*mboxWriter, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".part", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &mboxWriter{path: path, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+var fromLine = regexp.MustCompile(`^>*From `)
+
+func (mw *mboxWriter) Write(m Message) error {
+	raw := bytes.ReplaceAll(m.Raw, []byte("\r\n"), []byte("\n"))
+	fmt.Fprintf(mw.w, "From %s %s\n", envelopeSender(raw), messageDate(m, raw).UTC().Format(time.ANSIC))
+	for line := range bytes.Lines(raw) {
+		if fromLine.Match(line) {
+			mw.w.WriteByte('>')
+		}
+		mw.w.Write(line)
+	}
+	if !bytes.HasSuffix(raw, []byte("\n")) {
+		mw.w.WriteByte('\n')
+	}
+	_, err := mw.w.WriteString("\n")
+	return err
+}
+
+func (mw *mboxWriter) Close() error {
+	err := mw.w.Flush()
+	if cerr := mw.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(mw.f.Name())
+		return err
+	}
+	return os.Rename(mw.f.Name(), mw.path)
+}
+
+// emlWriter writes each message to DATE-ID.eml, unchanged.
+type emlWriter struct {
+	dir string
+}
+
+func (ew *emlWriter) Write(m Message) error {
+	name := messageDate(m, m.Raw).UTC().Format("2006-01-02") + "-" + safeName(m.ID) + ".eml"
+	return os.WriteFile(filepath.Join(ew.dir, name), m.Raw, 0o600)
+}
+
+func (ew *emlWriter) Close() error { return nil }
+
+// envelopeSender returns the address of the From header, or MAILER-DAEMON
+// as mbox readers expect when there is none.
+func envelopeSender(raw []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err == nil {
+		if addr, err := mail.ParseAddress(msg.Header.Get("From")); err == nil && !strings.ContainsAny(addr.Address, " \t") {
+			return addr.Address
+		}
+	}
+	return "MAILER-DAEMON"
+}
+
+// messageDate returns m.Date, else the Date header, else the Unix epoch.
+func messageDate(m Message, raw []byte) time.Time {
+	if !m.Date.IsZero() {
+		return m.Date
+	}
+	if msg, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		if t, err := msg.Header.Date(); err == nil {
+			return t
+		}
+	}
+	return time.Unix(0, 0)
+}
+
+// safeName keeps a message ID from naming a path outside the directory.
+func safeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, id)
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const sample = "From: News <news@shop.example>\r\nSubject: Sale\r\nDate: Mon, 02 Jan 2006 15:04:05 +0000\r\n\r\nHello\r\nFrom the team\r\n>From before\r\n"
+
+func TestMbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.mbox")
+	w, err := Create(path, Mbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Message{ID: "a", Raw: []byte(sample)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Message{ID: "b", Raw: []byte("Subject: bare\r\n\r\nno newline"), Date: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("mbox in place before Close")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "From news@shop.example Mon Jan  2 15:04:05 2006\n" +
+		"From: News <news@shop.example>\nSubject: Sale\nDate: Mon, 02 Jan 2006 15:04:05 +0000\n\nHello\n>From the team\n>>From before\n\n" +
+		"From MAILER-DAEMON Wed May  1 08:00:00 2024\n" +
+		"Subject: bare\n\nno newline\n\n"
+	if string(got) != want {
+		t.Errorf("mbox =\n%s\nwant\n%s", got, want)
+	}
+	if _, err := Create(path, Mbox); err == nil {
+		t.Error("Create overwrote an existing mbox")
+	}
+}
+
+func TestEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "eml")
+	w, err := Create(dir, EML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Message{ID: "../18c", Raw: []byte(sample)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "2006-01-02-.._18c.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != sample {
+		t.Errorf("eml = %q, want the raw message", got)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name, path string
+		want       Format
+	}{
+		{"", "backup.mbox", Mbox},
+		{"", "backup", EML},
+		{"MBOX", "backup", Mbox},
+		{"eml", "backup.mbox", EML},
+	} {
+		if got, err := ParseFormat(tc.name, tc.path); err != nil || got != tc.want {
+			t.Errorf("ParseFormat(%q, %q) = %q, %v; want %q", tc.name, tc.path, got, err, tc.want)
+		}
+	}
+	if _, err := ParseFormat("maildir", ""); err == nil {
+		t.Error("ParseFormat accepted maildir")
+	}
+}
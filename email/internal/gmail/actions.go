@@ -1,67 +1,191 @@
 package gmail
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"strings"
 
+	"chuckterm/internal/model"
 	gmailv1 "google.golang.org/api/gmail/v1"
 )
 
-// ArchiveMessages removes the INBOX label from the given messages (batch).
+// batchModifyLimit is the maximum number of IDs Users.Messages.BatchModify accepts.
+const batchModifyLimit = 1000
+
+// ArchiveMessages removes the INBOX label from the given messages using
+// BatchModify in chunks of batchModifyLimit IDs.
 func ArchiveMessages(ctx context.Context, svc *gmailv1.Service, messageIDs []string) error {
-	user := "me"
-	req := &gmailv1.ModifyMessageRequest{
-		RemoveLabelIds: []string{"INBOX"},
+	if SkipDryRun(ctx, "archive %s", DescribeIDs(messageIDs)) {
+		return ErrDryRun
+	}
+	err := modifyLabels(ctx, svc, remoteIDs(messageIDs), nil, []string{"INBOX"})
+	logChange("archive", len(messageIDs), err)
+	if err != nil {
+		return fmt.Errorf("archive %w", err)
+	}
+	return nil
+}
+
+// MarkRead removes the UNREAD label from the given messages, leaving them
+// where they are.
+func MarkRead(ctx context.Context, svc *gmailv1.Service, messageIDs []string) error {
+	if SkipDryRun(ctx, "mark read %s", DescribeIDs(messageIDs)) {
+		return ErrDryRun
+	}
+	err := modifyLabels(ctx, svc, remoteIDs(messageIDs), nil, []string{"UNREAD"})
+	logChange("mark read", len(messageIDs), err)
+	if err != nil {
+		return fmt.Errorf("mark read %w", err)
 	}
-	for _, id := range messageIDs {
+	return nil
+}
+
+// modifyLabels adds and removes labels on the given messages using
+// BatchModify in chunks of batchModifyLimit IDs.
+func modifyLabels(ctx context.Context, svc *gmailv1.Service, messageIDs, add, remove []string) error {
+	if IsDryRun(ctx) {
+		var change []string
+		if len(add) > 0 {
+			change = append(change, "add label "+strings.Join(add, ", "))
+		}
+		if len(remove) > 0 {
+			change = append(change, "remove label "+strings.Join(remove, ", "))
+		}
+		SkipDryRun(ctx, "%s on %s", strings.Join(change, " and "), DescribeIDs(messageIDs))
+		return ErrDryRun
+	}
+	user := "me"
+	for start := 0; start < len(messageIDs); start += batchModifyLimit {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
-		if _, err := svc.Users.Messages.Modify(user, id, req).Do(); err != nil {
-			return fmt.Errorf("archive message %s: %w", id, err)
+		end := min(start+batchModifyLimit, len(messageIDs))
+		req := &gmailv1.BatchModifyMessagesRequest{
+			Ids:            messageIDs[start:end],
+			AddLabelIds:    add,
+			RemoveLabelIds: remove,
+		}
+		err := retryDo(ctx, func() error {
+			return svc.Users.Messages.BatchModify(user, req).Context(ctx).Do()
+		})
+		if err != nil {
+			return fmt.Errorf("messages %d-%d: %w", start, end-1, err)
 		}
 	}
 	return nil
 }
 
+// remoteIDs drops local-only IDs, which Gmail does not know about.
+func remoteIDs(ids []string) []string {
+	out := ids[:0:0]
+	for _, id := range ids {
+		if !model.IsLocalID(id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// ImportMessage uploads a raw RFC 822 message into the mailbox with the INBOX
+// and UNREAD labels, as if it had been delivered, and returns its Gmail ID.
+func ImportMessage(ctx context.Context, svc *gmailv1.Service, raw []byte) (string, error) {
+	if SkipDryRun(ctx, "import a %d-byte message", len(raw)) {
+		return "", ErrDryRun
+	}
+	msg, err := retry(ctx, func() (*gmailv1.Message, error) {
+		return svc.Users.Messages.Import("me", &gmailv1.Message{LabelIds: []string{"INBOX", "UNREAD"}}).
+			Media(bytes.NewReader(raw)).Context(ctx).Do()
+	})
+	if err != nil {
+		return "", fmt.Errorf("import message: %w", err)
+	}
+	return msg.Id, nil
+}
+
 // TrashMessages moves the given messages to trash.
 func TrashMessages(ctx context.Context, svc *gmailv1.Service, messageIDs []string) error {
+	if SkipDryRun(ctx, "trash %s", DescribeIDs(messageIDs)) {
+		return ErrDryRun
+	}
 	user := "me"
-	for _, id := range messageIDs {
+	for _, id := range remoteIDs(messageIDs) {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
-		if _, err := svc.Users.Messages.Trash(user, id).Do(); err != nil {
-			return fmt.Errorf("trash message %s: %w", id, err)
+		_, err := retry(ctx, func() (*gmailv1.Message, error) {
+			return svc.Users.Messages.Trash(user, id).Context(ctx).Do()
+		})
+		if err != nil {
+			err = fmt.Errorf("trash message %s: %w", id, err)
+			logChange("trash", len(messageIDs), err)
+			return err
 		}
 	}
+	logChange("trash", len(messageIDs), nil)
 	return nil
 }
 
-// GetMessageBody fetches the full message and extracts the body as plain text.
+// GetMessageBody fetches the full message, extracts the body as plain text
+// and lists its attachments.
 // It prefers text/plain, falls back to stripped HTML, then the message snippet.
-func GetMessageBody(ctx context.Context, svc *gmailv1.Service, messageID string) (string, error) {
+func GetMessageBody(ctx context.Context, svc *gmailv1.Service, messageID string) (model.MessageBody, error) {
+	if model.IsLocalID(messageID) {
+		return model.MessageBody{}, fmt.Errorf("message %s was imported locally and has no body in Gmail", messageID)
+	}
 	user := "me"
-	msg, err := svc.Users.Messages.Get(user, messageID).Format("full").Do()
+	msg, err := retry(ctx, func() (*gmailv1.Message, error) {
+		return svc.Users.Messages.Get(user, messageID).Format("full").Context(ctx).Do()
+	})
 	if err != nil {
-		return "", fmt.Errorf("get message %s: %w", messageID, err)
+		return model.MessageBody{}, fmt.Errorf("get message %s: %w", messageID, err)
 	}
+	return model.MessageBody{
+		Text:        bodyText(msg),
+		Attachments: extractAttachments(msg.Payload),
+		Invites:     extractInvites(ctx, svc, messageID, msg),
+	}, nil
+}
+
+// GetRawMessage downloads the RFC 822 source of a message.
+func GetRawMessage(ctx context.Context, svc *gmailv1.Service, messageID string) ([]byte, error) {
+	if model.IsLocalID(messageID) {
+		return nil, fmt.Errorf("message %s was imported locally and has no source in Gmail", messageID)
+	}
+	msg, err := retry(ctx, func() (*gmailv1.Message, error) {
+		return svc.Users.Messages.Get("me", messageID).Format("raw").Context(ctx).Do()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get message %s: %w", messageID, err)
+	}
+	raw, err := base64.URLEncoding.DecodeString(msg.Raw)
+	if err != nil {
+		if raw, err = base64.RawURLEncoding.DecodeString(msg.Raw); err != nil {
+			return nil, fmt.Errorf("decode message %s: %w", messageID, err)
+		}
+	}
+	return raw, nil
+}
+
+// bodyText prefers text/plain, falls back to rendered HTML, then the snippet.
+func bodyText(msg *gmailv1.Message) string {
 	if msg.Payload != nil {
 		if body := extractPlainText(msg.Payload); body != "" {
-			return body, nil
+			return body
 		}
 		if html := extractHTML(msg.Payload); html != "" {
-			if text := stripHTMLTags(html); text != "" {
-				return text, nil
+			if text := htmlToText(html); text != "" {
+				return text
 			}
 		}
 	}
 	if msg.Snippet != "" {
-		return msg.Snippet, nil
+		return msg.Snippet
 	}
-	return "(no content)", nil
+	return "(no content)"
 }
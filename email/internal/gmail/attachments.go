@@ -0,0 +1,195 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chuckterm/internal/model"
+	"common/xdg"
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// extractAttachments walks a MIME part tree and lists every part that
+// carries a filename.
+func extractAttachments(part *gmailv1.MessagePart) []model.Attachment {
+	if part == nil {
+		return nil
+	}
+	var out []model.Attachment
+	if part.Filename != "" && part.Body != nil {
+		out = append(out, model.Attachment{
+			PartID:       part.PartId,
+			Filename:     part.Filename,
+			MimeType:     part.MimeType,
+			Size:         part.Body.Size,
+			AttachmentID: part.Body.AttachmentId,
+		})
+	}
+	for _, sub := range part.Parts {
+		out = append(out, extractAttachments(sub)...)
+	}
+	return out
+}
+
+// findPart returns the part with the given ID.
+func findPart(part *gmailv1.MessagePart, id string) *gmailv1.MessagePart {
+	if part == nil {
+		return nil
+	}
+	if part.PartId == id {
+		return part
+	}
+	for _, sub := range part.Parts {
+		if p := findPart(sub, id); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// DownloadAttachment saves an attachment of the message into dir, adding a
+// numeric suffix rather than overwriting an existing file, and returns the
+// path written.
+func DownloadAttachment(ctx context.Context, svc *gmailv1.Service, messageID string, att model.Attachment, dir string) (string, error) {
+	var encoded string
+	if att.AttachmentID != "" {
+		body, err := retry(ctx, func() (*gmailv1.MessagePartBody, error) {
+			return svc.Users.Messages.Attachments.Get("me", messageID, att.AttachmentID).Context(ctx).Do()
+		})
+		if err != nil {
+			return "", fmt.Errorf("get attachment %s: %w", att.Filename, err)
+		}
+		encoded = body.Data
+	} else {
+		// Small attachments are inline in the message itself.
+		msg, err := retry(ctx, func() (*gmailv1.Message, error) {
+			return svc.Users.Messages.Get("me", messageID).Format("full").Context(ctx).Do()
+		})
+		if err != nil {
+			return "", fmt.Errorf("get message %s: %w", messageID, err)
+		}
+		part := findPart(msg.Payload, att.PartID)
+		if part == nil || part.Body == nil {
+			return "", fmt.Errorf("attachment %s not found in message", att.Filename)
+		}
+		encoded = part.Body.Data
+	}
+	data, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		if data, err = base64.RawURLEncoding.DecodeString(encoded); err != nil {
+			return "", fmt.Errorf("decode attachment %s: %w", att.Filename, err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return writeUnique(dir, safeFilename(att.Filename), data)
+}
+
+// safeFilename strips directory components and separators so a sender
+// cannot write outside the download directory.
+func safeFilename(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	name = strings.TrimLeft(filepath.Base(name), ".")
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+// writeUnique creates dir/name, or "name (n).ext" if it already exists.
+func writeUnique(dir, name string, data []byte) (string, error) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 0; n < 1000; n++ {
+		candidate := name
+		if n > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+		}
+		path := filepath.Join(dir, candidate)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			os.Remove(path)
+			return "", err
+		}
+		return path, f.Close()
+	}
+	return "", fmt.Errorf("too many files named %s in %s", name, dir)
+}
+
+// executableExts are the file types that run code, or install software,
+// when the system opener opens them.
+var executableExts = map[string]bool{
+	// Windows
+	".exe": true, ".com": true, ".bat": true, ".cmd": true, ".msi": true, ".msix": true,
+	".scr": true, ".pif": true, ".cpl": true, ".dll": true, ".lnk": true, ".reg": true,
+	".hta": true, ".js": true, ".jse": true, ".vbs": true, ".vbe": true, ".wsf": true,
+	".ps1": true, ".psm1": true, ".appx": true, ".appref-ms": true,
+	// macOS
+	".app": true, ".command": true, ".pkg": true, ".mpkg": true, ".dmg": true, ".workflow": true,
+	// Linux and the rest
+	".sh": true, ".bash": true, ".run": true, ".bin": true, ".desktop": true, ".appimage": true,
+	".deb": true, ".rpm": true, ".jar": true, ".py": true, ".pl": true, ".rb": true,
+	".apk": true,
+}
+
+// executableTypes are MIME types of programs, for attachments whose name
+// hides what they are.
+var executableTypes = map[string]bool{
+	"application/x-msdownload":                      true,
+	"application/x-msdos-program":                   true,
+	"application/x-ms-installer":                    true,
+	"application/x-msi":                             true,
+	"application/x-executable":                      true,
+	"application/x-sh":                              true,
+	"application/x-shellscript":                     true,
+	"application/java-archive":                      true,
+	"application/vnd.microsoft.portable-executable": true,
+	"application/x-apple-diskimage":                 true,
+	"application/vnd.android.package-archive":       true,
+}
+
+// IsExecutable reports whether opening an attachment named filename, sent
+// as mimeType, would run a program or install one rather than show a
+// document.
+func IsExecutable(filename, mimeType string) bool {
+	// Windows drops trailing dots and spaces, so "setup.exe." runs too.
+	name := strings.TrimRight(filename, ". ")
+	if executableExts[strings.ToLower(filepath.Ext(name))] {
+		return true
+	}
+	mt, _, _ := strings.Cut(strings.ToLower(mimeType), ";")
+	return executableTypes[strings.TrimSpace(mt)]
+}
+
+// OpenFile opens a downloaded file with the application the system uses
+// for its type. Check IsExecutable first: for programs that means running
+// them.
+func OpenFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return err
+	}
+	return systemOpen(abs)
+}
+
+// DownloadsDir returns the user's download directory (XDG_DOWNLOAD_DIR,
+// falling back to ~/Downloads).
+func DownloadsDir() (string, error) {
+	return xdg.DownloadDir()
+}
@@ -0,0 +1,83 @@
+package gmail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+func TestExtractAttachments(t *testing.T) {
+	payload := &gmailv1.MessagePart{
+		PartId:   "",
+		MimeType: "multipart/mixed",
+		Parts: []*gmailv1.MessagePart{
+			{PartId: "0", MimeType: "text/plain", Body: &gmailv1.MessagePartBody{Data: "aGk"}},
+			{PartId: "1", MimeType: "application/pdf", Filename: "report.pdf", Body: &gmailv1.MessagePartBody{Size: 1234, AttachmentId: "att-1"}},
+			{PartId: "2", MimeType: "multipart/related", Parts: []*gmailv1.MessagePart{
+				{PartId: "2.1", MimeType: "image/png", Filename: "logo.png", Body: &gmailv1.MessagePartBody{Size: 10, Data: "aGk"}},
+			}},
+		},
+	}
+	got := extractAttachments(payload)
+	if len(got) != 2 || got[0].Filename != "report.pdf" || got[0].AttachmentID != "att-1" || got[1].PartID != "2.1" {
+		t.Fatalf("extractAttachments = %+v", got)
+	}
+	if p := findPart(payload, "2.1"); p == nil || p.Filename != "logo.png" {
+		t.Fatalf("findPart = %+v", p)
+	}
+}
+
+func TestIsExecutable(t *testing.T) {
+	tests := []struct {
+		name, mimeType string
+		want           bool
+	}{
+		{"report.pdf", "application/pdf", false},
+		{"photo.JPG", "image/jpeg", false},
+		{"notes", "text/plain", false},
+		{"setup.exe", "application/octet-stream", true},
+		{"invoice.pdf.exe", "application/pdf", true},
+		{"Invoice.PDF.Bat", "", true},
+		{"setup.exe. ", "", true},
+		{"install.command", "", true},
+		{"tool.jar", "", true},
+		{"download", "application/x-msdownload", true},
+		{"run", "application/x-sh; charset=utf-8", true},
+	}
+	for _, tc := range tests {
+		if got := IsExecutable(tc.name, tc.mimeType); got != tc.want {
+			t.Errorf("IsExecutable(%q, %q) = %v; want %v", tc.name, tc.mimeType, got, tc.want)
+		}
+	}
+}
+
+func TestSafeFilenameAndWriteUnique(t *testing.T) {
+	for in, want := range map[string]string{
+		"../../etc/passwd": "_.._etc_passwd",
+		"a/b.txt":          "a_b.txt",
+		"..":               "attachment",
+		"report.pdf":       "report.pdf",
+	} {
+		if got := safeFilename(in); got != want {
+			t.Errorf("safeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	dir := t.TempDir()
+	first, err := writeUnique(dir, "r.pdf", []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := writeUnique(dir, "r.pdf", []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(first) != "r.pdf" || filepath.Base(second) != "r (1).pdf" {
+		t.Fatalf("paths = %s, %s", first, second)
+	}
+	if b, _ := os.ReadFile(first); string(b) != "1" {
+		t.Fatalf("first file overwritten: %q", b)
+	}
+}
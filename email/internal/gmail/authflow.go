@@ -0,0 +1,101 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// AuthFlow selects how a missing or expired token is replaced.
+type AuthFlow string
+
+const (
+	// AuthBrowser opens the consent page and catches its redirect on a
+	// loopback port, falling back to pasting the code or redirect URL.
+	AuthBrowser AuthFlow = "browser"
+	// AuthDevice shows a short code to enter at Google's device page from
+	// any other device, for machines without a browser, such as over SSH.
+	AuthDevice AuthFlow = "device"
+)
+
+// ParseAuthFlow reads an AuthFlow; "" means AuthBrowser.
+func ParseAuthFlow(s string) (AuthFlow, error) {
+	switch f := AuthFlow(strings.ToLower(s)); f {
+	case "", AuthBrowser:
+		return AuthBrowser, nil
+	case AuthDevice:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown auth flow %q (want browser or device)", s)
+}
+
+// authFlow is the flow NewService and its variants authenticate with.
+var authFlow = AuthBrowser
+
+// SetAuthFlow selects the flow used when a token has to be obtained.
+func SetAuthFlow(f AuthFlow) { authFlow = f }
+
+// DeviceCode is sent to the UI during the device flow: the user opens URL
+// on another device and enters Code there before Expires.
+type DeviceCode struct {
+	URL     string
+	Code    string
+	Expires time.Time
+}
+
+// getTokenByDevice runs the OAuth device authorization flow (RFC 8628). The
+// code to enter is sent on uiEvents when it is set and printed to stderr
+// otherwise; the token endpoint is then polled until the user has approved
+// or the code expires.
+func getTokenByDevice(ctx context.Context, cfg *oauth2.Config, uiEvents chan<- interface{}) (*oauth2.Token, error) {
+	if cfg.Endpoint.DeviceAuthURL == "" {
+		cfg.Endpoint.DeviceAuthURL = google.Endpoint.DeviceAuthURL
+	}
+	da, err := cfg.DeviceAuth(ctx, oauth2.AccessTypeOffline)
+	if err != nil {
+		return nil, deviceAuthError(err)
+	}
+	code := DeviceCode{URL: da.VerificationURI, Code: da.UserCode, Expires: da.Expiry}
+	if uiEvents != nil {
+		uiEvents <- code
+	} else {
+		fmt.Fprintf(os.Stderr, "On any device, open %s and enter the code %s\n", code.URL, code.Code)
+		fmt.Fprintln(os.Stderr, "Waiting for approval…")
+	}
+	tok, err := cfg.DeviceAccessToken(ctx, da)
+	if err != nil {
+		return nil, deviceAuthError(err)
+	}
+	if uiEvents == nil {
+		fmt.Fprintln(os.Stderr, "Authentication successful.")
+	}
+	return tok, nil
+}
+
+// deviceAuthError explains the refusals the device flow commonly runs into.
+func deviceAuthError(err error) error {
+	var rerr *oauth2.RetrieveError
+	if !errors.As(err, &rerr) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errors.New("device code expired before it was approved")
+		}
+		return fmt.Errorf("device authorization: %w", err)
+	}
+	switch rerr.ErrorCode {
+	case "invalid_client", "unauthorized_client":
+		return fmt.Errorf("device authorization: %w (the device flow needs an OAuth client of type \"TVs and Limited Input devices\" in client_secret.json)", err)
+	case "invalid_scope":
+		return fmt.Errorf("device authorization: %w (the provider does not allow the Gmail scopes with the device flow; use auth = \"browser\" and paste the redirect URL instead)", err)
+	case "access_denied":
+		return errors.New("device authorization was denied")
+	case "expired_token":
+		return errors.New("device code expired before it was approved")
+	}
+	return fmt.Errorf("device authorization: %w", err)
+}
@@ -0,0 +1,64 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestParseAuthFlow(t *testing.T) {
+	for in, want := range map[string]AuthFlow{"": AuthBrowser, "browser": AuthBrowser, "Device": AuthDevice} {
+		if got, err := ParseAuthFlow(in); err != nil || got != want {
+			t.Errorf("ParseAuthFlow(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseAuthFlow("paste"); err == nil {
+		t.Error("ParseAuthFlow(paste): want an error")
+	}
+}
+
+func TestGetTokenByDevice(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device":
+			if r.Form.Get("client_id") != "id" || !strings.Contains(r.Form.Get("scope"), "gmail") {
+				t.Errorf("device request form = %v", r.Form)
+			}
+			fmt.Fprint(w, `{"device_code":"dev","user_code":"ABCD-EFGH","verification_uri":"https://example.com/device","expires_in":60,"interval":1}`)
+		case "/token":
+			if r.Form.Get("device_code") != "dev" {
+				t.Errorf("token request form = %v", r.Form)
+			}
+			polls++
+			fmt.Fprint(w, `{"access_token":"tok","token_type":"Bearer","refresh_token":"ref","expires_in":3600}`)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"https://www.googleapis.com/auth/gmail.modify"},
+		Endpoint:     oauth2.Endpoint{DeviceAuthURL: srv.URL + "/device", TokenURL: srv.URL + "/token"},
+	}
+	events := make(chan interface{}, 1)
+	tok, err := getTokenByDevice(context.Background(), cfg, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := (<-events).(DeviceCode)
+	if code.Code != "ABCD-EFGH" || code.URL != "https://example.com/device" || code.Expires.IsZero() {
+		t.Errorf("device code = %+v", code)
+	}
+	if tok.AccessToken != "tok" || tok.RefreshToken != "ref" || polls != 1 {
+		t.Errorf("token = %+v after %d polls", tok, polls)
+	}
+}
@@ -0,0 +1,129 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// AutoLabelRule maps a sender to a Gmail label applied to newly synced mail.
+// Match is either a full address ("news@example.com") or a domain prefixed
+// with "@" ("@example.com"), which also covers its subdomains.
+type AutoLabelRule struct {
+	Match string `json:"match"`
+	Label string `json:"label"`
+}
+
+// Matches reports whether the normalized sender email is covered by the rule.
+func (r AutoLabelRule) Matches(email string) bool {
+	return senderMatches(r.Match, email)
+}
+
+// LoadAutoLabelRules reads rules from a JSON array file. A missing file yields
+// no rules.
+func LoadAutoLabelRules(path string) ([]AutoLabelRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var rules []AutoLabelRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, r := range rules {
+		if strings.TrimSpace(r.Match) == "" || strings.TrimSpace(r.Label) == "" {
+			return nil, fmt.Errorf("parse %s: rule %d needs both match and label", path, i+1)
+		}
+	}
+	return rules, nil
+}
+
+// ApplyAutoLabels adds the label of every matching rule to msgs, creating
+// labels that do not exist yet. msgs must carry normalized From addresses.
+// It returns the number of messages that received at least one label.
+func ApplyAutoLabels(ctx context.Context, svc *gmailv1.Service, rules []AutoLabelRule, msgs []model.MessageRef) (int, error) {
+	if len(rules) == 0 || len(msgs) == 0 {
+		return 0, nil
+	}
+	byLabel := make(map[string][]string)
+	labelled := make(map[string]struct{})
+	for _, m := range msgs {
+		for _, r := range rules {
+			if r.Matches(m.From) {
+				byLabel[r.Label] = append(byLabel[r.Label], m.ID)
+				labelled[m.ID] = struct{}{}
+			}
+		}
+	}
+	if len(byLabel) == 0 {
+		return 0, nil
+	}
+
+	names := make([]string, 0, len(byLabel))
+	for name := range byLabel {
+		names = append(names, name)
+	}
+	ids, err := ensureLabels(ctx, svc, names)
+	if err != nil {
+		return 0, err
+	}
+	for name, msgIDs := range byLabel {
+		if err := modifyLabels(ctx, svc, msgIDs, []string{ids[name]}, nil); err != nil && !errors.Is(err, ErrDryRun) {
+			return 0, fmt.Errorf("apply label %q: %w", name, err)
+		} else if err == nil {
+			slog.Info("auto-label", "label", name, "messages", len(msgIDs))
+		}
+	}
+	return len(labelled), nil
+}
+
+// ensureLabels resolves label names (case-insensitively) to IDs, creating any
+// user labels that are missing.
+func ensureLabels(ctx context.Context, svc *gmailv1.Service, names []string) (map[string]string, error) {
+	resp, err := retry(ctx, func() (*gmailv1.ListLabelsResponse, error) {
+		return svc.Users.Labels.List("me").Context(ctx).Do()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list labels: %w", err)
+	}
+	existing := make(map[string]string, len(resp.Labels))
+	for _, l := range resp.Labels {
+		existing[strings.ToLower(l.Name)] = l.Id
+	}
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		if id, ok := existing[strings.ToLower(name)]; ok {
+			out[name] = id
+			continue
+		}
+		if SkipDryRun(ctx, "create label %q", name) {
+			// Later dry-run steps only need something to name it by.
+			out[name] = name
+			continue
+		}
+		created, err := retry(ctx, func() (*gmailv1.Label, error) {
+			return svc.Users.Labels.Create("me", &gmailv1.Label{
+				Name:                  name,
+				LabelListVisibility:   "labelShow",
+				MessageListVisibility: "show",
+			}).Context(ctx).Do()
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create label %q: %w", name, err)
+		}
+		existing[strings.ToLower(name)] = created.Id
+		out[name] = created.Id
+	}
+	return out, nil
+}
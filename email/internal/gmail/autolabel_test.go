@@ -0,0 +1,48 @@
+package gmail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAutoLabelRuleMatches(t *testing.T) {
+	tests := []struct {
+		match, email string
+		want         bool
+	}{
+		{"news@example.com", "news@example.com", true},
+		{"News@Example.com", "news@example.com", true},
+		{"news@example.com", "other@example.com", false},
+		{"@example.com", "anyone@example.com", true},
+		{"@example.com", "noreply@mail.example.com", true},
+		{"@example.com", "x@notexample.com", false},
+		{"", "x@example.com", false},
+	}
+	for _, tc := range tests {
+		r := AutoLabelRule{Match: tc.match, Label: "L"}
+		if got := r.Matches(tc.email); got != tc.want {
+			t.Errorf("Rule{%q}.Matches(%q) = %v; want %v", tc.match, tc.email, got, tc.want)
+		}
+	}
+}
+
+func TestLoadAutoLabelRules(t *testing.T) {
+	dir := t.TempDir()
+	rules, err := LoadAutoLabelRules(filepath.Join(dir, "missing.json"))
+	if err != nil || rules != nil {
+		t.Fatalf("missing file: rules=%v err=%v", rules, err)
+	}
+
+	path := filepath.Join(dir, "autolabel.json")
+	os.WriteFile(path, []byte(`[{"match":"@github.com","label":"GitHub"}]`), 0o600)
+	rules, err = LoadAutoLabelRules(path)
+	if err != nil || len(rules) != 1 || rules[0].Label != "GitHub" {
+		t.Fatalf("rules=%v err=%v", rules, err)
+	}
+
+	os.WriteFile(path, []byte(`[{"match":"@github.com"}]`), 0o600)
+	if _, err := LoadAutoLabelRules(path); err == nil {
+		t.Fatal("expected error for rule without label")
+	}
+}
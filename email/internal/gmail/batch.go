@@ -0,0 +1,169 @@
+package gmail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// metadataBatchSize is how many message gets one batch request carries,
+// the most Gmail accepts.
+const metadataBatchSize = 100
+
+// batchClients maps services to the HTTP client they were built with, for
+// sending batch requests, which gmailv1.Service has no call for.
+var batchClients sync.Map // *gmailv1.Service -> *http.Client
+
+// EnableBatch lets metadata fetches through svc go to Gmail's batch
+// endpoint, sent with hc, which should be the client svc was built with.
+// Services from NewService and its variants are already set up; others
+// fetch one message per request.
+func EnableBatch(svc *gmailv1.Service, hc *http.Client) {
+	batchClients.Store(svc, hc)
+}
+
+// batchClient returns the client EnableBatch stored for svc, or nil.
+func batchClient(svc *gmailv1.Service) *http.Client {
+	hc, _ := batchClients.Load(svc)
+	c, _ := hc.(*http.Client)
+	return c
+}
+
+// batchGetMetadata fetches the metadata of ids, at most metadataBatchSize
+// of them, in one batch request. Gets that fail with a transient error are
+// sent again in a smaller batch, as retry would for a single get. Gets that
+// fail for good, such as for a message deleted since it was listed, are
+// left out like a failed single get; the first of their errors is returned
+// with the messages fetched, after the transient failures are retried.
+func batchGetMetadata(ctx context.Context, svc *gmailv1.Service, hc *http.Client, ids []string) ([]*gmailv1.Message, error) {
+	var out []*gmailv1.Message
+	var dropped error
+	pending := ids
+	err := retryDo(ctx, func() error {
+		msgs, failed, permanent, err := sendBatch(ctx, svc, hc, pending)
+		out = append(out, msgs...)
+		pending = failed
+		if dropped == nil {
+			dropped = permanent
+		}
+		return err
+	})
+	return out, errors.Join(err, dropped)
+}
+
+// sendBatch sends one batch request getting the metadata of ids. It returns
+// the messages fetched, the IDs to fetch again and the error that made them
+// fail, and the first permanent error of a get not worth sending again.
+func sendBatch(ctx context.Context, svc *gmailv1.Service, hc *http.Client, ids []string) (msgs []*gmailv1.Message, failed []string, permanent, err error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	query := url.Values{"format": {"metadata"}, "metadataHeaders": metadataHeaders}.Encode()
+	for i, id := range ids {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {"<" + strconv.Itoa(i) + ">"},
+		})
+		if err != nil {
+			return nil, ids, nil, err
+		}
+		fmt.Fprintf(pw, "GET /gmail/v1/users/me/messages/%s?%s HTTP/1.1\r\n\r\n", url.PathEscape(id), query)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, ids, nil, err
+	}
+
+	endpoint := strings.TrimSuffix(svc.BasePath, "/") + "/batch/gmail/v1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return nil, ids, nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, ids, nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, ids, nil, err
+	}
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, ids, nil, fmt.Errorf("gmail batch response: %w", err)
+	}
+
+	answered := make([]bool, len(ids))
+	var transient error
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return msgs, ids, permanent, fmt.Errorf("gmail batch response: %w", err)
+			}
+			break
+		}
+		cid := strings.TrimSuffix(strings.TrimPrefix(part.Header.Get("Content-Id"), "<response-"), ">")
+		i, err := strconv.Atoi(cid)
+		if err != nil || i < 0 || i >= len(ids) {
+			continue
+		}
+		answered[i] = true
+		msg, err := readBatchPart(part)
+		switch {
+		case err == nil:
+			msgs = append(msgs, msg)
+		case isRetryable(err):
+			failed = append(failed, ids[i])
+			transient = err
+		default:
+			slog.Warn("gmail request failed", "id", ids[i], "err", err)
+			if permanent == nil {
+				permanent = err
+			}
+		}
+	}
+	for i, ok := range answered {
+		if !ok {
+			err := fmt.Errorf("gmail batch response has no answer for message %s", ids[i])
+			slog.Warn("gmail request failed", "id", ids[i], "err", err)
+			if permanent == nil {
+				permanent = err
+			}
+		}
+	}
+	return msgs, failed, permanent, transient
+}
+
+// readBatchPart decodes the message in one part of a batch response, or
+// the error Gmail answered that get with.
+func readBatchPart(part *multipart.Part) (*gmailv1.Message, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(part), nil)
+	if err != nil {
+		return nil, fmt.Errorf("gmail batch response: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	var msg gmailv1.Message
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("gmail batch response: %w", err)
+	}
+	return &msg, nil
+}
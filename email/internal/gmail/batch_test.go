@@ -0,0 +1,147 @@
+package gmail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// batchServer answers batch requests for message metadata. The first get
+// of "busy" is turned down as rate limited, and every get of "gone" as not
+// found.
+func batchServer(t *testing.T, batches *int) *httptest.Server {
+	busy := true
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/batch/gmail/v1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		*batches++
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		// Buffered, as the request body may be gone once the reply starts.
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			inner, err := http.ReadRequest(bufio.NewReader(part))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := inner.URL.Query().Get("format"); got != "metadata" {
+				t.Errorf("format = %q; want metadata", got)
+			}
+			pw, _ := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type": {"application/http"},
+				"Content-Id":   {"<response-" + strings.Trim(part.Header.Get("Content-Id"), "<>") + ">"},
+			})
+			id := path.Base(inner.URL.Path)
+			if id == "busy" && busy {
+				busy = false
+				fmt.Fprint(pw, "HTTP/1.1 429 Too Many Requests\r\nContent-Type: application/json\r\n\r\n{\"error\":{\"code\":429,\"message\":\"slow down\"}}")
+				continue
+			}
+			if id == "gone" {
+				fmt.Fprint(pw, "HTTP/1.1 404 Not Found\r\nContent-Type: application/json\r\n\r\n{\"error\":{\"code\":404,\"message\":\"Requested entity was not found.\"}}")
+				continue
+			}
+			fmt.Fprintf(pw, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n"+`{"id":%q,"payload":{"headers":[{"name":"From","value":"News <news@example.com>"}]}}`, id)
+		}
+		mw.Close()
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		w.Write(body.Bytes())
+	}))
+}
+
+func TestFetchMetadataBatchUsesBatchEndpoint(t *testing.T) {
+	oldBase := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = oldBase })
+
+	var batches int
+	srv := batchServer(t, &batches)
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	EnableBatch(svc, srv.Client())
+
+	ids := []string{"busy"}
+	for i := range 149 {
+		ids = append(ids, fmt.Sprintf("m%d", i))
+	}
+	var fetched int
+	msgs, err := fetchMetadataBatch(ctx, svc, ids, 1, func(n int) { fetched = n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != len(ids) || fetched != len(ids) {
+		t.Fatalf("got %d messages, %d reported; want %d", len(msgs), fetched, len(ids))
+	}
+	// Two batches of 100 and 50, plus one to fetch the rate-limited message again.
+	if batches != 3 {
+		t.Errorf("sent %d batch requests; want 3", batches)
+	}
+	for _, m := range msgs {
+		if m.From != "news@example.com" || m.FromName != "News" {
+			t.Fatalf("message %s from %q (%q)", m.ID, m.From, m.FromName)
+		}
+	}
+}
+
+func TestBatchRetriesTransientDespiteMissingMessage(t *testing.T) {
+	oldBase := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = oldBase })
+
+	var batches int
+	srv := batchServer(t, &batches)
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	EnableBatch(svc, srv.Client())
+
+	msgs, err := fetchMetadataBatch(ctx, svc, []string{"gone", "busy", "m1"}, 1, nil)
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) || gerr.Code != 404 {
+		t.Errorf("err = %v; want the 404 for gone", err)
+	}
+	var ids []string
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	slices.Sort(ids)
+	if strings.Join(ids, ",") != "busy,m1" {
+		t.Errorf("fetched %v; want busy and m1", ids)
+	}
+	// The rate-limited get is sent again, the missing message is not.
+	if batches != 2 {
+		t.Errorf("sent %d batch requests; want 2", batches)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
@@ -14,16 +15,17 @@ import (
 	"strings"
 	"time"
 
+	"common/atomicfile"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	gmailv1 "google.golang.org/api/gmail/v1"
-	"google.golang.org/api/option"
 )
 
 // NewService(ctx, configDir) initializes an OAuth-backed Gmail service using:
 // - Client credentials at ~/.config/chuckterm/client_secret.json
 // - Token cache at ~/.config/chuckterm/token.json
-// Scopes: gmail.readonly and gmail.modify (for trash/untrash).
+// Scopes: gmail.readonly, gmail.modify (for archive, trash, labels, drafts
+// and sending mail, including mailto: unsubscribes) and gmail.settings.basic (for filters).
 // NewService is a convenience wrapper for non-interactive authentication.
 func NewService(ctx context.Context, configDir string) (*gmailv1.Service, error) {
 	return NewServiceInteractive(ctx, configDir, nil, nil)
@@ -32,26 +34,39 @@ func NewService(ctx context.Context, configDir string) (*gmailv1.Service, error)
 // NewServiceInteractive initializes a Gmail service, using the provided channels
 // for interactive authentication if needed.
 func NewServiceInteractive(ctx context.Context, configDir string, uiEvents chan<- interface{}, userResponses <-chan string) (*gmailv1.Service, error) {
-	credPath := filepath.Join(configDir, "client_secret.json")
-	b, err := os.ReadFile(credPath)
+	if IsReadOnly(ctx) {
+		return newService(ctx, configDir, "token-readonly.json", []string{gmailv1.GmailReadonlyScope}, uiEvents, userResponses)
+	}
+	if fullAccess {
+		return newService(ctx, configDir, "token-full.json", []string{gmailv1.MailGoogleComScope, gmailv1.GmailSettingsBasicScope}, uiEvents, userResponses)
+	}
+	return newService(ctx, configDir, "token.json", []string{gmailv1.GmailReadonlyScope, gmailv1.GmailModifyScope, gmailv1.GmailSettingsBasicScope}, uiEvents, userResponses)
+}
+
+// NewReadonlyService initializes a Gmail service that is only granted the
+// gmail.readonly scope. Its token is cached separately in token-readonly.json
+// so it never reuses a token carrying modify rights.
+func NewReadonlyService(ctx context.Context, configDir string) (*gmailv1.Service, error) {
+	return newService(ctx, configDir, "token-readonly.json", []string{gmailv1.GmailReadonlyScope}, nil, nil)
+}
+
+func newService(ctx context.Context, configDir, tokenName string, scopes []string, uiEvents chan<- interface{}, userResponses <-chan string) (*gmailv1.Service, error) {
+	ctx = withProxy(ctx)
+	b, err := readCredentials(configDir)
 	if err != nil {
-		return nil, fmt.Errorf("read credentials at %s: %w", credPath, err)
+		return nil, err
 	}
 
-	cfg, err := google.ConfigFromJSON(b,
-		gmailv1.GmailReadonlyScope,
-		gmailv1.GmailModifyScope,
-	)
+	cfg, err := google.ConfigFromJSON(b, scopes...)
 	if err != nil {
 		return nil, fmt.Errorf("parse oauth config: %w", err)
 	}
 
-	tokFile := filepath.Join(configDir, "token.json")
+	tokFile := filepath.Join(configDir, tokenName)
 	tok, err := readToken(tokFile)
 	if err == nil {
 		// Validate the cached token by making a lightweight API call.
-		client := cfg.Client(ctx, tok)
-		svc, err := gmailv1.NewService(ctx, option.WithHTTPClient(client))
+		svc, err := newSwitchedService(ctx, cfg, tokFile, tok)
 		if err == nil {
 			_, err = svc.Users.GetProfile("me").Do()
 		}
@@ -59,6 +74,7 @@ func NewServiceInteractive(ctx context.Context, configDir string, uiEvents chan<
 			return svc, nil
 		}
 		// Token is invalid/expired — remove it and fall through to re-auth.
+		slog.Warn("cached token rejected", "token", tokenName, "err", err)
 		os.Remove(tokFile)
 	}
 
@@ -71,12 +87,7 @@ func NewServiceInteractive(ctx context.Context, configDir string, uiEvents chan<
 		return nil, err
 	}
 
-	client := cfg.Client(ctx, tok)
-	svc, err := gmailv1.NewService(ctx, option.WithHTTPClient(client))
-	if err != nil {
-		return nil, fmt.Errorf("create gmail service: %w", err)
-	}
-	return svc, nil
+	return newSwitchedService(ctx, cfg, tokFile, tok)
 }
 
 func readToken(path string) (*oauth2.Token, error) {
@@ -93,25 +104,21 @@ func readToken(path string) (*oauth2.Token, error) {
 }
 
 func saveToken(path string, tok *oauth2.Token) error {
-	tmp := path + ".tmp"
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
-	f, err := os.Create(tmp)
+	b, err := json.Marshal(tok)
 	if err != nil {
 		return err
 	}
-	if err := json.NewEncoder(f).Encode(tok); err != nil {
-		f.Close()
-		return err
-	}
-	f.Close()
-	return os.Rename(tmp, path)
+	return atomicfile.WriteFile(path, b, 0o600)
 }
 
 // getTokenFromWeb runs a loopback HTTP server to capture the auth code.
 // If that fails or times out, it falls back to manual paste (code or URL).
+// With AuthDevice selected it runs the device flow instead.
 func getTokenFromWeb(ctx context.Context, cfg *oauth2.Config, uiEvents chan<- interface{}, userResponses <-chan string) (*oauth2.Token, error) {
+	slog.Info("sign-in start", "flow", authFlow)
+	if authFlow == AuthDevice {
+		return getTokenByDevice(ctx, cfg, uiEvents)
+	}
 	// If we have channels, use the interactive flow.
 	if uiEvents != nil && userResponses != nil {
 		return getTokenFromWebInteractive(ctx, cfg, uiEvents, userResponses)
@@ -0,0 +1,57 @@
+package gmail
+
+import (
+	"context"
+	"strings"
+
+	"chuckterm/internal/model"
+)
+
+// ContactStore is implemented by stores that remember the senders of the
+// messages they cache, to complete addresses when writing mail. Contacts
+// stay when their messages leave the cache.
+type ContactStore interface {
+	// LoadContacts returns every contact, most recently seen first.
+	LoadContacts(ctx context.Context) ([]model.Contact, error)
+}
+
+// automatedLocalParts mark addresses that do not take replies.
+var automatedLocalParts = []string{"noreply", "no-reply", "no_reply", "donotreply", "do-not-reply", "mailer-daemon", "bounce"}
+
+// MatchContacts returns up to limit of contacts, in their order, whose
+// address or a word of whose name starts with query, ignoring case.
+// Addresses that do not take replies, such as noreply@, are left out.
+func MatchContacts(contacts []model.Contact, query string, limit int) []model.Contact {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	var out []model.Contact
+	for _, c := range contacts {
+		if len(out) == limit {
+			break
+		}
+		email := strings.ToLower(c.Email)
+		local, _, _ := strings.Cut(email, "@")
+		if automated(local) {
+			continue
+		}
+		match := strings.HasPrefix(email, query)
+		for _, w := range strings.Fields(strings.ToLower(c.Name)) {
+			match = match || strings.HasPrefix(strings.Trim(w, `"'(),`), query)
+		}
+		if match {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func automated(local string) bool {
+	for _, a := range automatedLocalParts {
+		if strings.Contains(local, a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package gmail
+
+import (
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestMatchContacts(t *testing.T) {
+	contacts := []model.Contact{
+		{Email: "ada@engines.example", Name: "Ada Lovelace"},
+		{Email: "noreply@engines.example", Name: "Analytical Engines"},
+		{Email: "charles@engines.example", Name: `"Babbage, Charles"`},
+		{Email: "lovelace.fan@example.com"},
+		{Email: "alan@example.com", Name: "Alan"},
+	}
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		{"a", 10, []string{"ada@engines.example", "alan@example.com"}},
+		{"a", 1, []string{"ada@engines.example"}},
+		{"LOVE", 10, []string{"ada@engines.example", "lovelace.fan@example.com"}},
+		{"babb", 10, []string{"charles@engines.example"}},
+		{"analytical", 10, nil},
+		{"engines", 10, nil},
+		{" ", 10, nil},
+	}
+	for _, tc := range tests {
+		var got []string
+		for _, c := range MatchContacts(contacts, tc.query, tc.limit) {
+			got = append(got, c.Email)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("MatchContacts(%q, %d) = %v; want %v", tc.query, tc.limit, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("MatchContacts(%q, %d) = %v; want %v", tc.query, tc.limit, got, tc.want)
+				break
+			}
+		}
+	}
+}
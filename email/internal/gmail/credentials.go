@@ -0,0 +1,116 @@
+package gmail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"common/atomicfile"
+	"golang.org/x/oauth2/google"
+)
+
+// ErrNoCredentials means the OAuth client credentials have not been set up
+// yet; the TUI then offers to set them up.
+var ErrNoCredentials = errors.New("no OAuth client credentials")
+
+// credentialsFile is the credentials file SetCredentialsFile chose.
+var credentialsFile string
+
+// SetCredentialsFile reads the OAuth client credentials from path instead
+// of client_secret.json in the config directory. "" restores the default.
+func SetCredentialsFile(path string) { credentialsFile = path }
+
+// CredentialsPath is where the OAuth client credentials are read from: the
+// file SetCredentialsFile chose, else $CHUCKTERM_CREDENTIALS, else
+// client_secret.json in configDir.
+func CredentialsPath(configDir string) string {
+	if credentialsFile != "" {
+		return credentialsFile
+	}
+	if path := os.Getenv("CHUCKTERM_CREDENTIALS"); path != "" {
+		return path
+	}
+	return filepath.Join(configDir, "client_secret.json")
+}
+
+// readCredentials returns the OAuth client JSON, wrapping ErrNoCredentials
+// when there is none. Without a file chosen, CHUCKTERM_CLIENT_ID and
+// CHUCKTERM_CLIENT_SECRET take the place of the default file.
+func readCredentials(configDir string) ([]byte, error) {
+	if credentialsFile == "" && os.Getenv("CHUCKTERM_CREDENTIALS") == "" {
+		if b, ok, err := envCredentials(); ok || err != nil {
+			return b, err
+		}
+	}
+	credPath := CredentialsPath(configDir)
+	b, err := os.ReadFile(credPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read credentials at %s: %w", credPath, ErrNoCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read credentials at %s: %w", credPath, err)
+	}
+	return b, nil
+}
+
+// envCredentials builds a desktop OAuth client from CHUCKTERM_CLIENT_ID and
+// CHUCKTERM_CLIENT_SECRET, reporting whether they are set.
+func envCredentials() ([]byte, bool, error) {
+	id, secret := os.Getenv("CHUCKTERM_CLIENT_ID"), os.Getenv("CHUCKTERM_CLIENT_SECRET")
+	switch {
+	case id == "" && secret == "":
+		return nil, false, nil
+	case id == "":
+		return nil, true, errors.New("CHUCKTERM_CLIENT_SECRET is set but CHUCKTERM_CLIENT_ID is not")
+	case secret == "":
+		return nil, true, errors.New("CHUCKTERM_CLIENT_ID is set but CHUCKTERM_CLIENT_SECRET is not")
+	}
+	b, err := json.Marshal(map[string]any{"installed": map[string]any{
+		"client_id":     id,
+		"client_secret": secret,
+		"auth_uri":      google.Endpoint.AuthURL,
+		"token_uri":     google.Endpoint.TokenURL,
+		"redirect_uris": []string{"http://localhost"},
+	}})
+	return b, true, err
+}
+
+// InstallCredentials checks OAuth client credentials and saves them where
+// CredentialsPath reads them, returning that path. input is either the JSON
+// itself, as pasted, or the path of the downloaded file.
+func InstallCredentials(configDir, input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("enter the path of the downloaded JSON file, or paste its contents")
+	}
+	b := []byte(input)
+	if !strings.HasPrefix(input, "{") {
+		path := strings.Trim(input, `"'`)
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			path = filepath.Join(home, rest)
+		}
+		var err error
+		if b, err = os.ReadFile(path); err != nil {
+			return "", fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	if _, err := google.ConfigFromJSON(b); err != nil {
+		return "", fmt.Errorf("not an OAuth client JSON file (download it from Credentials > OAuth 2.0 Client IDs, type Desktop app): %w", err)
+	}
+	if err := os.MkdirAll(configDir, 0o700); err != nil {
+		return "", err
+	}
+	dst := CredentialsPath(configDir)
+	if err := atomicfile.WriteFile(dst, b, 0o600); err != nil {
+		return "", fmt.Errorf("save credentials: %w", err)
+	}
+	return dst, nil
+}
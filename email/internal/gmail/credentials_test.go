@@ -0,0 +1,87 @@
+package gmail
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/oauth2/google"
+)
+
+const testClientJSON = `{"installed":{"client_id":"id.apps.googleusercontent.com","client_secret":"secret","auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token","redirect_uris":["http://localhost"]}}`
+
+func TestInstallCredentials(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "chuckterm")
+	if _, err := readCredentials(configDir); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("readCredentials before setup = %v; want ErrNoCredentials", err)
+	}
+
+	download := filepath.Join(dir, "client_secret_123.json")
+	if err := os.WriteFile(download, []byte(testClientJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{download, `"` + download + `"`, "  " + testClientJSON + "\n"} {
+		path, err := InstallCredentials(configDir, input)
+		if err != nil {
+			t.Fatalf("InstallCredentials(%q): %v", input, err)
+		}
+		b, err := readCredentials(configDir)
+		if err != nil || string(b) != testClientJSON || path != CredentialsPath(configDir) {
+			t.Errorf("after InstallCredentials(%q): %s at %s, %v", input, b, path, err)
+		}
+		if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+			t.Errorf("credentials file mode = %v, %v; want 0600", fi.Mode(), err)
+		}
+	}
+
+	for _, input := range []string{"", filepath.Join(dir, "missing.json"), "{not json", `{"type":"service_account"}`} {
+		if _, err := InstallCredentials(configDir, input); err == nil {
+			t.Errorf("InstallCredentials(%q) succeeded", input)
+		}
+	}
+}
+
+func TestCredentialSources(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CHUCKTERM_CREDENTIALS", "")
+	t.Setenv("CHUCKTERM_CLIENT_ID", "")
+	t.Setenv("CHUCKTERM_CLIENT_SECRET", "")
+	defer SetCredentialsFile("")
+
+	if got, want := CredentialsPath(dir), filepath.Join(dir, "client_secret.json"); got != want {
+		t.Errorf("default CredentialsPath = %s; want %s", got, want)
+	}
+
+	t.Setenv("CHUCKTERM_CLIENT_ID", "env-id")
+	if _, err := readCredentials(dir); err == nil {
+		t.Error("readCredentials with only CHUCKTERM_CLIENT_ID succeeded")
+	}
+	t.Setenv("CHUCKTERM_CLIENT_SECRET", "env-secret")
+	b, err := readCredentials(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := google.ConfigFromJSON(b)
+	if err != nil || cfg.ClientID != "env-id" || cfg.ClientSecret != "env-secret" {
+		t.Errorf("credentials from the environment = %+v, %v", cfg, err)
+	}
+
+	// A chosen file wins over the client ID and secret.
+	profile := filepath.Join(dir, "work.json")
+	if err := os.WriteFile(profile, []byte(testClientJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CHUCKTERM_CREDENTIALS", profile)
+	if b, err := readCredentials(dir); err != nil || string(b) != testClientJSON {
+		t.Errorf("credentials from $CHUCKTERM_CREDENTIALS = %s, %v", b, err)
+	}
+	SetCredentialsFile(filepath.Join(dir, "missing.json"))
+	if got := CredentialsPath(dir); got != filepath.Join(dir, "missing.json") {
+		t.Errorf("CredentialsPath after SetCredentialsFile = %s", got)
+	}
+	if _, err := readCredentials(dir); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("readCredentials of a missing chosen file = %v; want ErrNoCredentials", err)
+	}
+}
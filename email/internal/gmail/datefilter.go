@@ -0,0 +1,151 @@
+package gmail
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+// DateFilter keeps the groups whose mail all falls inside a window, so a
+// bulk action on a matching group touches nothing outside it. The zero
+// value keeps every group.
+type DateFilter struct {
+	From, To time.Time // zero for an open end
+	Label    string    // the window in words, e.g. "older than 6 months"
+}
+
+// Active reports whether f filters anything.
+func (f DateFilter) Active() bool { return !f.From.IsZero() || !f.To.IsZero() }
+
+// Match reports whether every message of g is dated inside the window.
+// Groups without dates only match an inactive filter.
+func (f DateFilter) Match(g model.SenderGroup) bool {
+	if !f.Active() {
+		return true
+	}
+	first, err := time.Parse(time.RFC3339, g.FirstDate)
+	if err != nil {
+		return false
+	}
+	last, err := time.Parse(time.RFC3339, g.LastDate)
+	if err != nil {
+		return false
+	}
+	return (f.From.IsZero() || !first.Before(f.From)) && (f.To.IsZero() || !last.After(f.To))
+}
+
+// Apply returns the groups f matches, in order.
+func (f DateFilter) Apply(groups []model.SenderGroup) []model.SenderGroup {
+	if !f.Active() {
+		return groups
+	}
+	var out []model.SenderGroup
+	for _, g := range groups {
+		if f.Match(g) {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+var ageRe = regexp.MustCompile(`^(\d+)\s*(d|days?|w|weeks?|m|mo|months?|y|years?)$`)
+
+// ParseDateFilter reads a window relative to now:
+//
+//	older than 6 months, older 6mo, >6mo   nothing newer than 6 months ago
+//	last 30 days, last 30d, <30d           nothing older than 30 days ago
+//	2024-01-01..2024-06-30                 inside those days (either end may be left out)
+//
+// "" and "all" clear the filter.
+func ParseDateFilter(s string, now time.Time) (DateFilter, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "all":
+		return DateFilter{}, nil
+	}
+	if from, to, ok := strings.Cut(s, ".."); ok {
+		return parseDateRange(strings.TrimSpace(from), strings.TrimSpace(to), now.Location())
+	}
+	for _, p := range []struct {
+		prefix string
+		older  bool
+	}{
+		{"older than", true}, {"older", true}, {">", true},
+		{"newer than", false}, {"last", false}, {"<", false},
+	} {
+		rest, ok := strings.CutPrefix(s, p.prefix)
+		if !ok {
+			continue
+		}
+		cutoff, words, err := ago(strings.TrimSpace(rest), now)
+		if err != nil {
+			return DateFilter{}, err
+		}
+		if p.older {
+			return DateFilter{To: cutoff, Label: "older than " + words}, nil
+		}
+		return DateFilter{From: cutoff, Label: "last " + words}, nil
+	}
+	return DateFilter{}, fmt.Errorf("unknown date filter %q (try \"older than 6 months\", \"last 30 days\" or 2024-01-01..2024-06-30)", s)
+}
+
+// ago returns the time an age such as "6mo" or "30 days" before now, and
+// the age in words.
+func ago(age string, now time.Time) (time.Time, string, error) {
+	m := ageRe.FindStringSubmatch(age)
+	if m == nil {
+		return time.Time{}, "", fmt.Errorf("cannot read age %q (want e.g. 30d, 8w, 6mo or 1y)", age)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n == 0 {
+		return time.Time{}, "", fmt.Errorf("cannot read age %q", age)
+	}
+	var t time.Time
+	var unit string
+	switch m[2][0] {
+	case 'd':
+		t, unit = now.AddDate(0, 0, -n), "day"
+	case 'w':
+		t, unit = now.AddDate(0, 0, -7*n), "week"
+	case 'm':
+		t, unit = now.AddDate(0, -n, 0), "month"
+	default:
+		t, unit = now.AddDate(-n, 0, 0), "year"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return t, fmt.Sprintf("%d %s", n, unit), nil
+}
+
+// parseDateRange reads the two days of FROM..TO; the range includes all of
+// the TO day.
+func parseDateRange(from, to string, loc *time.Location) (DateFilter, error) {
+	var f DateFilter
+	if from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return DateFilter{}, fmt.Errorf("cannot read date %q (want YYYY-MM-DD)", from)
+		}
+		f.From = t
+	}
+	if to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return DateFilter{}, fmt.Errorf("cannot read date %q (want YYYY-MM-DD)", to)
+		}
+		f.To = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	if !f.Active() {
+		return DateFilter{}, fmt.Errorf("a date range needs at least one date")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		return DateFilter{}, fmt.Errorf("date range %s..%s ends before it starts", from, to)
+	}
+	f.Label = from + ".." + to
+	return f, nil
+}
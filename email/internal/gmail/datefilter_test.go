@@ -0,0 +1,72 @@
+package gmail
+
+import (
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+func TestParseDateFilter(t *testing.T) {
+	now := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in       string
+		from, to string
+		label    string
+	}{
+		{"older than 6 months", "", "2025-01-15T12:00:00Z", "older than 6 months"},
+		{">6mo", "", "2025-01-15T12:00:00Z", "older than 6 months"},
+		{"last 30 days", "2025-06-15T12:00:00Z", "", "last 30 days"},
+		{"<1w", "2025-07-08T12:00:00Z", "", "last 1 week"},
+		{"Older 1y", "", "2024-07-15T12:00:00Z", "older than 1 year"},
+		{"2024-01-01..2024-06-30", "2024-01-01T00:00:00Z", "2024-06-30T23:59:59.999999999Z", "2024-01-01..2024-06-30"},
+		{"..2024-06-30", "", "2024-06-30T23:59:59.999999999Z", "..2024-06-30"},
+	}
+	for _, tc := range tests {
+		f, err := ParseDateFilter(tc.in, now)
+		if err != nil {
+			t.Errorf("ParseDateFilter(%q): %v", tc.in, err)
+			continue
+		}
+		if got := formatBound(f.From); got != tc.from {
+			t.Errorf("ParseDateFilter(%q).From = %s, want %s", tc.in, got, tc.from)
+		}
+		if got := formatBound(f.To); got != tc.to {
+			t.Errorf("ParseDateFilter(%q).To = %s, want %s", tc.in, got, tc.to)
+		}
+		if f.Label != tc.label {
+			t.Errorf("ParseDateFilter(%q).Label = %q, want %q", tc.in, f.Label, tc.label)
+		}
+	}
+	for _, bad := range []string{"older than soon", "last 0d", "2024-13-01..", "2024-06-01..2024-01-01", "..", "yesterday"} {
+		if _, err := ParseDateFilter(bad, now); err == nil {
+			t.Errorf("ParseDateFilter(%q) accepted", bad)
+		}
+	}
+	if f, err := ParseDateFilter(" all ", now); err != nil || f.Active() {
+		t.Errorf("ParseDateFilter(all) = %+v, %v", f, err)
+	}
+}
+
+func formatBound(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func TestDateFilterApply(t *testing.T) {
+	f := DateFilter{To: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	groups := []model.SenderGroup{
+		{Email: "stale", FirstDate: "2023-01-01T00:00:00Z", LastDate: "2024-06-01T00:00:00Z"},
+		{Email: "mixed", FirstDate: "2023-01-01T00:00:00Z", LastDate: "2025-03-01T00:00:00Z"},
+		{Email: "undated"},
+	}
+	got := f.Apply(groups)
+	if len(got) != 1 || got[0].Email != "stale" {
+		t.Fatalf("Apply = %+v", got)
+	}
+	if len(DateFilter{}.Apply(groups)) != 3 {
+		t.Fatal("the zero filter dropped groups")
+	}
+}
@@ -0,0 +1,222 @@
+package gmail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// Draft is a plain-text message being written, kept in Gmail's Drafts
+// between sessions.
+type Draft struct {
+	// ID is the Gmail draft ID, empty until the draft is first saved.
+	ID string
+	// To and Cc are comma-separated addresses as typed.
+	To      string
+	Cc      string
+	Subject string
+	Body    string
+	// Date is when the draft was last saved, as RFC 3339.
+	Date string
+}
+
+// Empty reports whether d has nothing worth keeping.
+func (d Draft) Empty() bool {
+	return strings.TrimSpace(d.To+d.Cc+d.Subject+d.Body) == ""
+}
+
+// ListDrafts returns the account's drafts, newest first.
+func ListDrafts(ctx context.Context, svc *gmailv1.Service) ([]Draft, error) {
+	var ids []string
+	err := svc.Users.Drafts.List("me").Context(ctx).Pages(ctx, func(resp *gmailv1.ListDraftsResponse) error {
+		for _, d := range resp.Drafts {
+			ids = append(ids, d.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list drafts: %w", err)
+	}
+	out := make([]Draft, 0, len(ids))
+	for _, id := range ids {
+		d, err := retry(ctx, func() (*gmailv1.Draft, error) {
+			return svc.Users.Drafts.Get("me", id).Format("full").Context(ctx).Do()
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get draft %s: %w", id, err)
+		}
+		out = append(out, draftFromGmail(d))
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Date > out[j].Date })
+	return out, nil
+}
+
+func draftFromGmail(d *gmailv1.Draft) Draft {
+	out := Draft{ID: d.Id}
+	msg := d.Message
+	if msg == nil {
+		return out
+	}
+	if msg.InternalDate > 0 {
+		out.Date = time.UnixMilli(msg.InternalDate).UTC().Format(time.RFC3339)
+	}
+	if msg.Payload == nil {
+		return out
+	}
+	dec := new(mime.WordDecoder)
+	header := func(name string) string {
+		v := partHeader(msg.Payload, name)
+		if s, err := dec.DecodeHeader(v); err == nil {
+			return s
+		}
+		return v
+	}
+	out.To, out.Cc, out.Subject = header("To"), header("Cc"), header("Subject")
+	body := extractPlainText(msg.Payload)
+	if body == "" {
+		// Drafts written in Gmail may only have HTML.
+		body = htmlToText(extractHTML(msg.Payload))
+	}
+	out.Body = strings.ReplaceAll(body, "\r\n", "\n")
+	return out
+}
+
+// SaveDraft creates d in Gmail's Drafts, or replaces the draft with d.ID,
+// and returns the draft ID.
+func SaveDraft(ctx context.Context, svc *gmailv1.Service, d Draft) (string, error) {
+	if SkipDryRun(ctx, "save draft %q", d.Subject) {
+		return d.ID, ErrDryRun
+	}
+	draft := &gmailv1.Draft{Id: d.ID, Message: &gmailv1.Message{Raw: base64.URLEncoding.EncodeToString(d.raw())}}
+	var saved *gmailv1.Draft
+	var err error
+	if d.ID == "" {
+		// Not retried: a retry after a lost response would save it twice.
+		saved, err = svc.Users.Drafts.Create("me", draft).Context(ctx).Do()
+	} else {
+		saved, err = retry(ctx, func() (*gmailv1.Draft, error) {
+			return svc.Users.Drafts.Update("me", d.ID, draft).Context(ctx).Do()
+		})
+	}
+	if err != nil {
+		return d.ID, fmt.Errorf("save draft: %w", err)
+	}
+	slog.Info("saved draft", "id", saved.Id)
+	return saved.Id, nil
+}
+
+// SendDraft sends d, and removes it from Drafts when it was saved there.
+func SendDraft(ctx context.Context, svc *gmailv1.Service, d Draft) error {
+	if strings.TrimSpace(d.To) == "" {
+		return errors.New("add a recipient first")
+	}
+	for _, list := range []string{d.To, d.Cc} {
+		if strings.TrimSpace(list) == "" {
+			continue
+		}
+		if _, err := mail.ParseAddressList(list); err != nil {
+			return fmt.Errorf("cannot read the addresses %q: %w", list, err)
+		}
+	}
+	if SkipDryRun(ctx, "send %q to %s", d.Subject, d.To) {
+		return ErrDryRun
+	}
+	msg := &gmailv1.Message{Raw: base64.URLEncoding.EncodeToString(d.raw())}
+	// Not retried: a retry after a lost response would send it twice.
+	var err error
+	if d.ID == "" {
+		_, err = svc.Users.Messages.Send("me", msg).Context(ctx).Do()
+	} else {
+		_, err = svc.Users.Drafts.Send("me", &gmailv1.Draft{Id: d.ID, Message: msg}).Context(ctx).Do()
+	}
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	slog.Info("sent message", "draft", d.ID)
+	return nil
+}
+
+// DeleteDraft removes a draft for good; Gmail does not keep deleted drafts
+// in the trash.
+func DeleteDraft(ctx context.Context, svc *gmailv1.Service, id string) error {
+	if SkipDryRun(ctx, "delete draft %s", id) {
+		return ErrDryRun
+	}
+	err := retryDo(ctx, func() error {
+		return svc.Users.Drafts.Delete("me", id).Context(ctx).Do()
+	})
+	if err != nil {
+		return fmt.Errorf("delete draft: %w", err)
+	}
+	slog.Info("deleted draft", "id", id)
+	return nil
+}
+
+// Signature returns the signature set in Gmail's settings for the default
+// send-as address, as plain text; "" when there is none.
+func Signature(ctx context.Context, svc *gmailv1.Service) (string, error) {
+	resp, err := retry(ctx, func() (*gmailv1.ListSendAsResponse, error) {
+		return svc.Users.Settings.SendAs.List("me").Context(ctx).Do()
+	})
+	if err != nil {
+		return "", fmt.Errorf("list send-as addresses: %w", err)
+	}
+	for _, sa := range resp.SendAs {
+		if sa.IsDefault {
+			return strings.TrimSpace(htmlToText(sa.Signature)), nil
+		}
+	}
+	return "", nil
+}
+
+// AppendSignature adds sig to body under the conventional "-- " line.
+func AppendSignature(body, sig string) string {
+	sig = strings.TrimSpace(sig)
+	if sig == "" {
+		return body
+	}
+	return strings.TrimRight(body, "\n") + "\n\n-- \n" + sig
+}
+
+// raw renders d as an RFC 822 message. Gmail adds From, Date and
+// Message-ID when it saves or sends it.
+func (d Draft) raw() []byte {
+	var raw bytes.Buffer
+	for _, h := range []struct{ name, value string }{{"To", d.To}, {"Cc", d.Cc}} {
+		if v := strings.TrimSpace(h.value); v != "" {
+			fmt.Fprintf(&raw, "%s: %s\r\n", h.name, formatAddresses(v))
+		}
+	}
+	fmt.Fprintf(&raw, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", d.Subject))
+	raw.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+	// Quoted-printable keeps long lines and non-ASCII text intact.
+	qp := quotedprintable.NewWriter(&raw)
+	qp.Write([]byte(d.Body))
+	qp.Close()
+	return raw.Bytes()
+}
+
+// formatAddresses encodes the display names of an address list. A list that
+// does not parse yet, as in a draft, is kept as typed.
+func formatAddresses(list string) string {
+	addrs, err := mail.ParseAddressList(list)
+	if err != nil {
+		return strings.NewReplacer("\r", " ", "\n", " ").Replace(list)
+	}
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, ", ")
+}
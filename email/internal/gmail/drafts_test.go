@@ -0,0 +1,186 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestDraftRaw(t *testing.T) {
+	d := Draft{
+		To:      "Zoë Example <zoe@example.com>, bob@example.com",
+		Cc:      "not an address yet",
+		Subject: "Café plans",
+		Body:    "Hi Zoë,\n\n" + strings.Repeat("long line ", 120) + "\nBye",
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(d.raw())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 2 || to[0].Name != "Zoë Example" || to[1].Address != "bob@example.com" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	if cc := msg.Header.Get("Cc"); cc != "not an address yet" {
+		t.Errorf("Cc = %q", cc)
+	}
+	dec := new(mime.WordDecoder)
+	if subject, _ := dec.DecodeHeader(msg.Header.Get("Subject")); subject != "Café plans" {
+		t.Errorf("Subject = %q", subject)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.ReplaceAll(d.Body, "\n", "\r\n"); string(body) != want {
+		t.Errorf("body = %q; want %q", body, want)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	type call struct{ method, path, raw string }
+	var calls []call
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		c := call{method: r.Method, path: strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/")}
+		var body struct {
+			ID      string          `json:"id"`
+			Raw     string          `json:"raw"`
+			Message gmailv1.Message `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		c.raw = body.Message.Raw + body.Raw
+		calls = append(calls, c)
+		switch c.method + " " + c.path {
+		case "GET drafts":
+			fmt.Fprint(w, `{"drafts":[{"id":"d1"},{"id":"d2"}]}`)
+		case "GET drafts/d1":
+			fmt.Fprint(w, `{"id":"d1","message":{"internalDate":"1700000000000","payload":{"mimeType":"text/plain","headers":[{"name":"To","value":"a@example.com"},{"name":"Subject","value":"=?utf-8?q?Caf=C3=A9?="}],"body":{"data":"`+
+				base64.URLEncoding.EncodeToString([]byte("old\r\ntext"))+`"}}}}`)
+		case "GET drafts/d2":
+			fmt.Fprint(w, `{"id":"d2","message":{"internalDate":"1800000000000","payload":{"mimeType":"text/html","body":{"data":"`+
+				base64.URLEncoding.EncodeToString([]byte("<p>rich</p>"))+`"}}}}`)
+		case "POST drafts":
+			fmt.Fprint(w, `{"id":"new"}`)
+		case "PUT drafts/d1":
+			fmt.Fprint(w, `{"id":"d1"}`)
+		case "POST drafts/send", "POST messages/send":
+			fmt.Fprint(w, `{"id":"m1"}`)
+		case "DELETE drafts/d1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drafts, err := ListDrafts(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 2 || drafts[0].ID != "d2" || strings.TrimSpace(drafts[0].Body) != "rich" {
+		t.Fatalf("ListDrafts = %+v; want d2 (newer, HTML only) first", drafts)
+	}
+	if d := drafts[1]; d.To != "a@example.com" || d.Subject != "Café" || d.Body != "old\ntext" {
+		t.Errorf("draft d1 = %+v", d)
+	}
+
+	calls = nil
+	if id, err := SaveDraft(ctx, svc, Draft{Subject: "hi"}); err != nil || id != "new" {
+		t.Errorf("SaveDraft(new) = %q, %v", id, err)
+	}
+	if id, err := SaveDraft(ctx, svc, Draft{ID: "d1", Subject: "hi"}); err != nil || id != "d1" {
+		t.Errorf("SaveDraft(d1) = %q, %v", id, err)
+	}
+	if err := SendDraft(ctx, svc, Draft{ID: "d1", To: "a@example.com"}); err != nil {
+		t.Errorf("SendDraft(d1): %v", err)
+	}
+	if err := SendDraft(ctx, svc, Draft{To: "a@example.com"}); err != nil {
+		t.Errorf("SendDraft(unsaved): %v", err)
+	}
+	if err := DeleteDraft(ctx, svc, "d1"); err != nil {
+		t.Errorf("DeleteDraft: %v", err)
+	}
+	var got []string
+	for _, c := range calls {
+		got = append(got, c.method+" "+c.path)
+		if c.method != "DELETE" && c.raw == "" {
+			t.Errorf("%s %s sent no message", c.method, c.path)
+		}
+	}
+	want := "POST drafts, PUT drafts/d1, POST drafts/send, POST messages/send, DELETE drafts/d1"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("calls = %s; want %s", strings.Join(got, ", "), want)
+	}
+
+	calls = nil
+	for _, d := range []Draft{{}, {To: "a@example.com", Cc: "<broken"}} {
+		if err := SendDraft(ctx, svc, d); err == nil {
+			t.Errorf("SendDraft(%+v) succeeded", d)
+		}
+	}
+	dry := WithDryRun(ctx, nil)
+	if err := SendDraft(dry, svc, Draft{To: "a@example.com"}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("dry-run SendDraft = %v", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("invalid and dry-run sends made calls: %v", calls)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/gmail/v1/users/me/settings/sendAs" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"sendAs":[
+			{"sendAsEmail":"alias@example.com","signature":"<b>Alias</b>"},
+			{"sendAsEmail":"me@example.com","isDefault":true,"signature":"<div>Ada<br>Engines Ltd</div>"}
+		]}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := Signature(ctx, svc)
+	if err != nil || sig != "Ada\nEngines Ltd" {
+		t.Errorf("Signature = %q, %v", sig, err)
+	}
+}
+
+func TestAppendSignature(t *testing.T) {
+	tests := []struct{ body, sig, want string }{
+		{"", "Ada", "\n\n-- \nAda"},
+		{"Hi\n\n", " Ada\n", "Hi\n\n-- \nAda"},
+		{"Hi", "  ", "Hi"},
+	}
+	for _, tc := range tests {
+		if got := AppendSignature(tc.body, tc.sig); got != tc.want {
+			t.Errorf("AppendSignature(%q, %q) = %q; want %q", tc.body, tc.sig, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ErrDryRun is returned, possibly wrapped, by the actions a dry-run context
+// skipped. Nothing changed in Gmail, so callers leave the cache alone too.
+var ErrDryRun = errors.New("dry run: nothing was changed")
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context under which the actions that change mail
+// (archiving, trashing, labelling, unsubscribing and snoozing) are not
+// carried out. Each is described to audit instead and returns ErrDryRun.
+func WithDryRun(ctx context.Context, audit func(line string)) context.Context {
+	if audit == nil {
+		audit = func(string) {}
+	}
+	return context.WithValue(ctx, dryRunKey{}, audit)
+}
+
+// IsDryRun reports whether ctx came from WithDryRun or WithReadOnly.
+func IsDryRun(ctx context.Context) bool {
+	if IsReadOnly(ctx) {
+		return true
+	}
+	_, ok := ctx.Value(dryRunKey{}).(func(string))
+	return ok
+}
+
+// SkipDryRun describes an action to the audit function of a dry-run context
+// and reports whether the caller should skip it. Every provider's actions
+// that change mail go through it. Under WithReadOnly every action is
+// skipped, and only logged unless ctx is a dry run too.
+func SkipDryRun(ctx context.Context, format string, args ...any) bool {
+	audit, ok := ctx.Value(dryRunKey{}).(func(string))
+	if !ok && IsReadOnly(ctx) {
+		slog.Info("read-only: skipped " + fmt.Sprintf(format, args...))
+		return true
+	}
+	if ok {
+		line := fmt.Sprintf(format, args...)
+		slog.Info("dry run: would " + line)
+		audit(line)
+	}
+	return ok
+}
+
+// DescribeIDs counts and lists message IDs for the audit log, as in
+// "2 messages: id1 id2".
+func DescribeIDs(ids []string) string {
+	noun := "messages"
+	if len(ids) == 1 {
+		noun = "message"
+	}
+	return fmt.Sprintf("%d %s: %s", len(ids), noun, strings.Join(ids, " "))
+}
@@ -0,0 +1,100 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestDryRun(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run sent %s %s", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+	old := unsubscribeClient
+	unsubscribeClient = srv.Client()
+	defer func() { unsubscribeClient = old }()
+
+	if IsDryRun(context.Background()) {
+		t.Fatal("IsDryRun(Background) = true")
+	}
+	var lines []string
+	ctx := WithDryRun(context.Background(), func(line string) { lines = append(lines, line) })
+	if !IsDryRun(ctx) {
+		t.Fatal("IsDryRun = false")
+	}
+
+	// A nil service would panic if any of these reached the API.
+	if err := ArchiveMessages(ctx, nil, []string{"m1", "m2"}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("ArchiveMessages = %v", err)
+	}
+	if err := TrashMessages(ctx, nil, []string{"m3"}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("TrashMessages = %v", err)
+	}
+	if err := MarkRead(ctx, nil, []string{"m4"}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("MarkRead = %v", err)
+	}
+	if id, err := ImportMessage(ctx, nil, []byte("From: a@x.com\r\n\r\nhi")); !errors.Is(err, ErrDryRun) || id != "" {
+		t.Errorf("ImportMessage = %q, %v", id, err)
+	}
+	if err := MailtoUnsubscribe(ctx, nil, "mailto:leave@list.example?subject=stop"); !errors.Is(err, ErrDryRun) {
+		t.Errorf("MailtoUnsubscribe = %v", err)
+	}
+	opened := false
+	out, err := UnsubscribeGroup(ctx, nil, model.SenderGroup{Email: "a@x.com", UnsubscribeURL: srv.URL + "/a", UnsubscribeOneClick: true}, func(string) error {
+		opened = true
+		return nil
+	})
+	if !errors.Is(err, ErrDryRun) || out.Method != UnsubscribeOneClick || opened {
+		t.Errorf("one-click UnsubscribeGroup = %+v, %v, opened %v", out, err, opened)
+	}
+	out, err = UnsubscribeGroup(ctx, nil, model.SenderGroup{Email: "b@x.com", UnsubscribeURL: "https://b.example.com/u"}, func(string) error {
+		opened = true
+		return nil
+	})
+	if !errors.Is(err, ErrDryRun) || out.Method != UnsubscribeBrowser || opened {
+		t.Errorf("browser UnsubscribeGroup = %+v, %v, opened %v", out, err, opened)
+	}
+
+	want := []string{
+		"archive 2 messages: m1 m2",
+		"trash 1 message: m3",
+		"mark read 1 message: m4",
+		"import a 19-byte message",
+		"send unsubscribe email to leave@list.example",
+		"one-click unsubscribe from " + srv.URL + "/a",
+		"open unsubscribe page https://b.example.com/u",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("audit lines = %q, want %q", lines, want)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	if IsDryRun(context.Background()) {
+		t.Error("IsDryRun without WithReadOnly = true")
+	}
+	ctx := WithReadOnly(context.Background())
+	if !IsDryRun(ctx) {
+		t.Error("IsDryRun in read-only mode = false")
+	}
+	// A nil service would panic if any of these reached the API.
+	if err := ArchiveMessages(ctx, nil, []string{"m1"}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("ArchiveMessages = %v", err)
+	}
+	if err := TrashMessages(ctx, nil, []string{"m2"}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("TrashMessages = %v", err)
+	}
+
+	// A dry run still hears of what it skipped.
+	var lines []string
+	ctx = WithDryRun(ctx, func(line string) { lines = append(lines, line) })
+	if err := MarkRead(ctx, nil, []string{"m3"}); !errors.Is(err, ErrDryRun) || len(lines) != 1 {
+		t.Errorf("MarkRead = %v, audit lines %q", err, lines)
+	}
+}
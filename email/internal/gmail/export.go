@@ -0,0 +1,34 @@
+package gmail
+
+import (
+	"context"
+
+	"chuckterm/internal/export"
+	"chuckterm/internal/model"
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// ExportMessages downloads the source of each message and writes it to w,
+// in order. Local-only messages have no source in Gmail and are skipped.
+// It returns how many messages were written; w is left for the caller to
+// close.
+func ExportMessages(ctx context.Context, svc *gmailv1.Service, w export.Writer, messageIDs []string, progress func(done, total int)) (int, error) {
+	written := 0
+	for i, id := range messageIDs {
+		if model.IsLocalID(id) {
+			continue
+		}
+		raw, err := GetRawMessage(ctx, svc, id)
+		if err != nil {
+			return written, err
+		}
+		if err := w.Write(export.Message{ID: id, Raw: raw}); err != nil {
+			return written, err
+		}
+		written++
+		if progress != nil {
+			progress(i+1, len(messageIDs))
+		}
+	}
+	return written, nil
+}
@@ -3,6 +3,7 @@ package gmail
 import (
 	"context"
 	"fmt"
+	"html"
 	"sort"
 	"strings"
 	"sync"
@@ -37,10 +38,8 @@ func FetchGroups(ctx context.Context, svc *gmailv1.Service, includeSpamTrash boo
 		id string
 	}
 	type result struct {
-		from, subject, date    string
-		listUnsub, listUnsubPost string
-		id                     string
-		err                    error
+		ref model.MessageRef
+		err error
 	}
 
 	jobs := make(chan job, 1000)
@@ -59,30 +58,12 @@ func FetchGroups(ctx context.Context, svc *gmailv1.Service, includeSpamTrash boo
 					return
 				default:
 				}
-				msg, err := svc.Users.Messages.Get(user, j.id).
-					Format("metadata").
-					MetadataHeaders("From", "Subject", "Date", "List-Unsubscribe", "List-Unsubscribe-Post").
-					Do()
+				msg, err := getMetadata(ctx, svc, j.id)
 				if err != nil {
 					results <- result{err: err}
 					continue
 				}
-				var from, subject, date, listUnsub, listUnsubPost string
-				for _, h := range msg.Payload.Headers {
-					switch strings.ToLower(h.Name) {
-					case "from":
-						from = h.Value
-					case "subject":
-						subject = h.Value
-					case "date":
-						date = h.Value
-					case "list-unsubscribe":
-						listUnsub = h.Value
-					case "list-unsubscribe-post":
-						listUnsubPost = h.Value
-					}
-				}
-				results <- result{from: from, subject: subject, date: date, listUnsub: listUnsub, listUnsubPost: listUnsubPost, id: msg.Id}
+				results <- result{ref: refFromMetadata(msg)}
 			}
 		}()
 	}
@@ -102,11 +83,11 @@ func FetchGroups(ctx context.Context, svc *gmailv1.Service, includeSpamTrash boo
 				}
 				continue
 			}
-			email := util.NormalizeSender(r.from)
+			email := util.NormalizeSender(r.ref.From)
 			if email == "" {
 				continue
 			}
-			subject := r.subject
+			subject := r.ref.Subject
 			key := email + "||" + subject
 			g, ok := groups[key]
 			if !ok {
@@ -115,15 +96,15 @@ func FetchGroups(ctx context.Context, svc *gmailv1.Service, includeSpamTrash boo
 					Subject: subject,
 				}
 				// Try to preserve display name (prefix before <email>) best-effort.
-				g.DisplayName = displayNameFromFrom(r.from, email)
+				g.DisplayName = displayNameFromFrom(r.ref.From, email)
 				groups[key] = g
 			}
 			g.Count++
+			g.Bulk = g.Bulk || r.ref.Bulk()
 			if g.Sample == "" && subject != "" {
 				g.Sample = subject
 			}
-			// Normalize date into RFC3339 where possible.
-			if ts := parseDateRFC3339(r.date); ts != "" {
+			if ts := r.ref.DateRFC3339; ts != "" {
 				if g.FirstDate == "" || ts < g.FirstDate {
 					g.FirstDate = ts
 				}
@@ -131,9 +112,12 @@ func FetchGroups(ctx context.Context, svc *gmailv1.Service, includeSpamTrash boo
 					g.LastDate = ts
 				}
 			}
-			g.MessageIDs = append(g.MessageIDs, r.id)
-			if g.UnsubscribeURL == "" && r.listUnsub != "" {
-				g.UnsubscribeURL = extractHTTPUnsubscribeURL(r.listUnsub)
+			g.MessageIDs = append(g.MessageIDs, r.ref.ID)
+			if g.UnsubscribeURL == "" && r.ref.ListUnsubscribe != "" {
+				g.UnsubscribeURL = extractHTTPUnsubscribeURL(r.ref.ListUnsubscribe)
+			}
+			if g.UnsubscribeMailto == "" && r.ref.ListUnsubscribe != "" {
+				g.UnsubscribeMailto = extractMailtoUnsubscribe(r.ref.ListUnsubscribe)
 			}
 		}
 	}()
@@ -151,11 +135,7 @@ func FetchGroups(ctx context.Context, svc *gmailv1.Service, includeSpamTrash boo
 		default:
 		}
 
-		call := list
-		if pageToken != "" {
-			call = call.PageToken(pageToken)
-		}
-		resp, err := call.Do()
+		resp, err := listPage(ctx, list, pageToken)
 		if err != nil {
 			// Stop on list error.
 			close(jobs)
@@ -191,46 +171,18 @@ func FetchGroups(ctx context.Context, svc *gmailv1.Service, includeSpamTrash boo
 // FetchInitialEmails retrieves the first N messages from the user's inbox.
 func FetchInitialEmails(ctx context.Context, svc *gmailv1.Service, n int64) ([]model.MessageRef, error) {
 	user := "me"
-	list, err := svc.Users.Messages.List(user).
-		LabelIds("INBOX").
-		MaxResults(n).
-		Do()
+	list, err := listPage(ctx, svc.Users.Messages.List(user).LabelIds("INBOX").MaxResults(n), "")
 	if err != nil {
 		return nil, fmt.Errorf("list messages: %w", err)
 	}
 
 	var refs []model.MessageRef
 	for _, m := range list.Messages {
-		msg, err := svc.Users.Messages.Get(user, m.Id).
-			Format("metadata").
-			MetadataHeaders("From", "Subject", "Date", "List-Unsubscribe", "List-Unsubscribe-Post").
-			Do()
+		msg, err := getMetadata(ctx, svc, m.Id)
 		if err != nil {
 			continue
 		}
-		var from, subject, date, listUnsub, listUnsubPost string
-		for _, h := range msg.Payload.Headers {
-			switch strings.ToLower(h.Name) {
-			case "from":
-				from = h.Value
-			case "subject":
-				subject = h.Value
-			case "date":
-				date = h.Value
-			case "list-unsubscribe":
-				listUnsub = h.Value
-			case "list-unsubscribe-post":
-				listUnsubPost = h.Value
-			}
-		}
-		refs = append(refs, model.MessageRef{
-			ID:                  msg.Id,
-			From:                from,
-			Subject:             subject,
-			DateRFC3339:         parseDateRFC3339(date),
-			ListUnsubscribe:     listUnsub,
-			ListUnsubscribePost: listUnsubPost,
-		})
+		refs = append(refs, refFromMetadata(msg))
 	}
 	return refs, nil
 }
@@ -238,8 +190,10 @@ func FetchInitialEmails(ctx context.Context, svc *gmailv1.Service, n int64) ([]m
 // AggregateBySenderSubject builds groups from a provided slice of MessageRef using
 // NormalizeSender(msg.From) + exact, case-sensitive msg.Subject as the key.
 // DateRFC3339 is expected to already be RFC3339; comparisons are string-based.
+// A group is named after the newest of its messages with a display name.
 func AggregateBySenderSubject(msgs []model.MessageRef) map[string]*model.SenderGroup {
 	groups := make(map[string]*model.SenderGroup)
+	named := make(map[string]string) // group key -> date of the message named after
 	for _, m := range msgs {
 		email := util.NormalizeSender(m.From)
 		if email == "" {
@@ -257,10 +211,19 @@ func AggregateBySenderSubject(msgs []model.MessageRef) map[string]*model.SenderG
 			groups[key] = g
 		}
 		g.Count++
+		g.Size += m.SizeBytes
+		g.Bulk = g.Bulk || m.Bulk()
+		if m.Unread() {
+			g.Unread++
+		}
 		if g.Sample == "" && subject != "" {
 			g.Sample = subject
 		}
 		ts := strings.TrimSpace(m.DateRFC3339)
+		if date, ok := named[key]; m.FromName != "" && (!ok || ts > date) {
+			g.DisplayName = m.FromName
+			named[key] = ts
+		}
 		if ts != "" {
 			if g.FirstDate == "" || ts < g.FirstDate {
 				g.FirstDate = ts
@@ -268,6 +231,12 @@ func AggregateBySenderSubject(msgs []model.MessageRef) map[string]*model.SenderG
 			if g.LastDate == "" || ts > g.LastDate {
 				g.LastDate = ts
 			}
+			if len(ts) >= 7 {
+				if g.Months == nil {
+					g.Months = make(map[string]int)
+				}
+				g.Months[ts[:7]]++
+			}
 		}
 		if m.ID != "" {
 			g.MessageIDs = append(g.MessageIDs, m.ID)
@@ -275,11 +244,81 @@ func AggregateBySenderSubject(msgs []model.MessageRef) map[string]*model.SenderG
 		// Propagate unsubscribe URL (prefer HTTP over mailto)
 		if g.UnsubscribeURL == "" && m.ListUnsubscribe != "" {
 			g.UnsubscribeURL = extractHTTPUnsubscribeURL(m.ListUnsubscribe)
+			g.UnsubscribeOneClick = g.UnsubscribeURL != "" && IsOneClick(m.ListUnsubscribePost)
+		}
+		if g.UnsubscribeMailto == "" && m.ListUnsubscribe != "" {
+			g.UnsubscribeMailto = extractMailtoUnsubscribe(m.ListUnsubscribe)
 		}
 	}
 	return groups
 }
 
+// ByDomain merges sender+subject groups into one group per registrable
+// sender domain, keyed "@domain" with an empty subject. Domain groups carry
+// the merged MessageIDs plus the keys of the exact sender+subject groups
+// they contain, which is what low-memory mode resolves IDs through. They offer no unsubscribe link: the
+// senders of a domain usually run several lists.
+func ByDomain(groups []model.SenderGroup) []model.SenderGroup {
+	byDomain := make(map[string]*model.SenderGroup)
+	senders := make(map[string]map[string]bool)
+	sampleDate := make(map[string]string)
+	for _, g := range groups {
+		domain := util.SenderDomain(g.Email)
+		if domain == "" {
+			continue
+		}
+		key := "@" + domain
+		d, ok := byDomain[key]
+		if !ok {
+			d = &model.SenderGroup{Email: key, DisplayName: domain}
+			byDomain[key] = d
+			senders[key] = make(map[string]bool)
+		}
+		d.Count += g.Count
+		d.Unread += g.Unread
+		d.Size += g.Size
+		if g.FirstDate != "" && (d.FirstDate == "" || g.FirstDate < d.FirstDate) {
+			d.FirstDate = g.FirstDate
+		}
+		if g.LastDate > d.LastDate {
+			d.LastDate = g.LastDate
+		}
+		d.Months = addMonths(d.Months, g.Months)
+		d.Bulk = d.Bulk || g.Bulk
+		// Show the subject of the domain's newest mail.
+		sample := g.Subject
+		if sample == "" {
+			sample = g.Sample
+		}
+		if sample != "" && (d.Sample == "" || g.LastDate > sampleDate[key]) {
+			d.Sample = sample
+			sampleDate[key] = g.LastDate
+		}
+		d.MessageIDs = append(d.MessageIDs, g.MessageIDs...)
+		d.Members = append(d.Members, memberKeys(g)...)
+		if !senders[key][g.Email] {
+			senders[key][g.Email] = true
+			d.Senders++
+		}
+	}
+	return SortGroups(byDomain)
+}
+
+// addMonths adds the monthly counts of src to dst, allocating dst if
+// needed, and returns it.
+func addMonths(dst, src map[string]int) map[string]int {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]int, len(src))
+	}
+	for month, n := range src {
+		dst[month] += n
+	}
+	return dst
+}
+
 // extractHTTPUnsubscribeURL finds the first HTTP(S) URL in a List-Unsubscribe header value.
 // The header typically contains comma-separated angle-bracketed URLs like:
 // <https://example.com/unsub>, <mailto:unsub@example.com>
@@ -297,8 +336,74 @@ func extractHTTPUnsubscribeURL(header string) string {
 	return ""
 }
 
+// extractMailtoUnsubscribe finds the first mailto: link in a
+// List-Unsubscribe header value.
+func extractMailtoUnsubscribe(header string) string {
+	for _, p := range strings.Split(header, ",") {
+		p = strings.TrimSpace(strings.Trim(strings.TrimSpace(p), "<>"))
+		if strings.HasPrefix(strings.ToLower(p), "mailto:") {
+			return p
+		}
+	}
+	return ""
+}
+
 // Helpers
 
+// metadataHeaders are the headers requested for every cached message.
+var metadataHeaders = []string{"From", "Subject", "Date", "List-Unsubscribe", "List-Unsubscribe-Post", "Precedence", "X-Mailer", "List-Id"}
+
+// getMetadata fetches a message in metadata format, retrying transient errors.
+func getMetadata(ctx context.Context, svc *gmailv1.Service, id string) (*gmailv1.Message, error) {
+	return retry(ctx, func() (*gmailv1.Message, error) {
+		return svc.Users.Messages.Get("me", id).
+			Format("metadata").
+			MetadataHeaders(metadataHeaders...).
+			Context(ctx).
+			Do()
+	})
+}
+
+// refFromMetadata converts a metadata-format message into a MessageRef. From
+// keeps the raw header value; callers normalize it where needed.
+func refFromMetadata(msg *gmailv1.Message) model.MessageRef {
+	ref := model.MessageRef{ID: msg.Id, LabelIDs: msg.LabelIds, SizeBytes: msg.SizeEstimate, Snippet: html.UnescapeString(msg.Snippet)}
+	if msg.Payload == nil {
+		return ref
+	}
+	for _, h := range msg.Payload.Headers {
+		switch strings.ToLower(h.Name) {
+		case "from":
+			ref.From = h.Value
+		case "subject":
+			ref.Subject = h.Value
+		case "date":
+			ref.DateRFC3339 = parseDateRFC3339(h.Value)
+		case "list-unsubscribe":
+			ref.ListUnsubscribe = h.Value
+		case "list-unsubscribe-post":
+			ref.ListUnsubscribePost = h.Value
+		case "precedence":
+			ref.Precedence = h.Value
+		case "x-mailer":
+			ref.XMailer = h.Value
+		case "list-id":
+			ref.ListID = h.Value
+		}
+	}
+	return ref
+}
+
+// listPage fetches one page of a message listing, retrying transient errors.
+func listPage(ctx context.Context, call *gmailv1.UsersMessagesListCall, pageToken string) (*gmailv1.ListMessagesResponse, error) {
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	return retry(ctx, func() (*gmailv1.ListMessagesResponse, error) {
+		return call.Context(ctx).Do()
+	})
+}
+
 func parseDateRFC3339(h string) string {
 	if h == "" {
 		return ""
@@ -352,6 +457,24 @@ func SortGroups(m map[string]*model.SenderGroup) []model.SenderGroup {
 	for _, g := range m {
 		out = append(out, *g)
 	}
+	sortGroupSlice(out)
+	return out
+}
+
+// ApplyPins marks the pinned groups and sorts them ahead of the rest; within
+// each section groups are sorted by order.
+func ApplyPins(groups []model.SenderGroup, pinned []model.GroupKey, order GroupOrder) {
+	set := make(map[model.GroupKey]bool, len(pinned))
+	for _, k := range pinned {
+		set[k] = true
+	}
+	for i := range groups {
+		groups[i].Pinned = set[model.GroupKey{Email: groups[i].Email, Subject: groups[i].Subject}]
+	}
+	SortGroupsBy(groups, order)
+}
+
+func sortGroupSlice(out []model.SenderGroup) {
 	sort.SliceStable(out, func(i, j int) bool {
 		if out[i].Count == out[j].Count {
 			if out[i].Email == out[j].Email {
@@ -361,5 +484,4 @@ func SortGroups(m map[string]*model.SenderGroup) []model.SenderGroup {
 		}
 		return out[i].Count > out[j].Count
 	})
-	return out
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package gmail
 
 import (
+	"strings"
 	"testing"
 
 	"chuckterm/internal/model"
@@ -53,6 +54,9 @@ func TestAggregateBySenderSubject_BasicGrouping(t *testing.T) {
 	if !(hasString(gAlice.MessageIDs, "1") && hasString(gAlice.MessageIDs, "2")) {
 		t.Fatalf("alice ids missing: %v", gAlice.MessageIDs)
 	}
+	if len(gAlice.Months) != 1 || gAlice.Months["2024-01"] != 2 {
+		t.Fatalf("alice months want 2024-01:2 got %v", gAlice.Months)
+	}
 
 	keyBobPromo := "bob@example.com||Promo"
 	if g, ok := groups[keyBobPromo]; !ok || g.Count != 1 {
@@ -93,4 +97,121 @@ func TestSortGroups_TieBreakers(t *testing.T) {
 			t.Fatalf("idx %d want %s|%s got %s|%s", i, e.Email, e.Subject, out[i].Email, out[i].Subject)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestApplyPins(t *testing.T) {
+	groups := []model.SenderGroup{
+		{Email: "a@example.com", Subject: "A", Count: 9},
+		{Email: "b@example.com", Subject: "B", Count: 1, Pinned: true}, // stale pin
+		{Email: "c@example.com", Subject: "C", Count: 2},
+		{Email: "d@example.com", Subject: "D", Count: 5},
+	}
+	ApplyPins(groups, []model.GroupKey{
+		{Email: "c@example.com", Subject: "C"},
+		{Email: "d@example.com", Subject: "D"},
+		{Email: "gone@example.com", Subject: "X"},
+	}, OrderCount)
+	exp := []string{"d@example.com", "c@example.com", "a@example.com", "b@example.com"}
+	for i, e := range exp {
+		if groups[i].Email != e {
+			t.Fatalf("idx %d want %s got %s", i, e, groups[i].Email)
+		}
+		if want := i < 2; groups[i].Pinned != want {
+			t.Fatalf("idx %d pinned=%v want %v", i, groups[i].Pinned, want)
+		}
+	}
+}
+
+func TestSortGroupsBy(t *testing.T) {
+	base := []model.SenderGroup{
+		{Email: "a@example.com", DisplayName: "zed", Count: 9, FirstDate: "2024-01-05T00:00:00Z", LastDate: "2024-03-01T00:00:00Z", Size: 90_000},
+		{Email: "b@example.com", DisplayName: "Amy", Count: 1, FirstDate: "2023-06-01T00:00:00Z", LastDate: "2023-06-01T00:00:00Z", Size: 4_000_000},
+		{Email: "c@example.com", DisplayName: "bob", Count: 5, FirstDate: "2024-02-01T00:00:00Z", LastDate: "2024-05-01T00:00:00Z", Size: 250_000},
+		{Email: "d@example.com", DisplayName: "Cat", Count: 2, FirstDate: "2022-01-01T00:00:00Z", LastDate: "2024-04-01T00:00:00Z", Pinned: true},
+	}
+	for order, want := range map[GroupOrder]string{
+		OrderCount:  "d a c b",
+		OrderNewest: "d c a b",
+		OrderOldest: "d b a c",
+		OrderSender: "d b c a",
+		OrderSize:   "d b c a",
+	} {
+		groups := append([]model.SenderGroup(nil), base...)
+		SortGroupsBy(groups, order)
+		var got []string
+		for _, g := range groups {
+			got = append(got, g.Email[:1])
+		}
+		if s := strings.Join(got, " "); s != want {
+			t.Errorf("%s: got %s, want %s", order, s, want)
+		}
+	}
+	if o, err := ParseGroupOrder("Newest"); err != nil || o != OrderNewest {
+		t.Fatalf("ParseGroupOrder = %q, %v", o, err)
+	}
+	if _, err := ParseGroupOrder("biggest"); err == nil {
+		t.Fatal("ParseGroupOrder accepted an unknown order")
+	}
+	if GroupOrder("").Next() != OrderNewest || OrderSender.Next() != OrderSize || OrderSize.Next() != OrderCount {
+		t.Fatal("Next does not cycle through GroupOrders")
+	}
+}
+
+func TestByDomain(t *testing.T) {
+	groups := []model.SenderGroup{
+		{Email: "no-reply@amazon.com", Subject: "Your order", Count: 3, Unread: 1, Size: 300, FirstDate: "2024-01-01T00:00:00Z", LastDate: "2024-02-01T00:00:00Z", Months: map[string]int{"2024-01": 2, "2024-02": 1}, MessageIDs: []string{"1", "2", "3"}},
+		{Email: "ship@email.amazon.com", Subject: "Shipped", Count: 2, Size: 200, FirstDate: "2023-12-01T00:00:00Z", LastDate: "2024-03-01T00:00:00Z", Months: map[string]int{"2023-12": 1, "2024-03": 1}, MessageIDs: []string{"4", "5"}},
+		{Email: "no-reply@amazon.com", Subject: "Deals", Count: 1, LastDate: "2023-01-01T00:00:00Z", MessageIDs: []string{"6"}},
+		{Email: "a@b.org", Subject: "hi", Count: 1, UnsubscribeURL: "https://b.org/u", MessageIDs: []string{"7"}},
+	}
+	got := ByDomain(groups)
+	if len(got) != 2 {
+		t.Fatalf("ByDomain = %+v", got)
+	}
+	d := got[0]
+	if d.Email != "@amazon.com" || d.DisplayName != "amazon.com" || !d.IsDomain() ||
+		d.Count != 6 || d.Unread != 1 || d.Size != 500 || d.Senders != 2 || len(d.Members) != 3 ||
+		d.FirstDate != "2023-12-01T00:00:00Z" || d.LastDate != "2024-03-01T00:00:00Z" || d.Sample != "Shipped" {
+		t.Fatalf("amazon.com group = %+v", d)
+	}
+	if len(d.Months) != 4 || d.Months["2024-01"] != 2 || d.Months["2024-03"] != 1 {
+		t.Fatalf("Months = %v", d.Months)
+	}
+	if strings.Join(d.MessageIDs, ",") != "1,2,3,4,5,6" {
+		t.Fatalf("MessageIDs = %v", d.MessageIDs)
+	}
+	if got[1].Email != "@b.org" || got[1].UnsubscribeURL != "" {
+		t.Fatalf("b.org group = %+v", got[1])
+	}
+}
+
+func TestGroupDisplayName(t *testing.T) {
+	msgs := []model.MessageRef{
+		{ID: "1", From: "news@shop.example", FromName: "Shop", Subject: "Sale", DateRFC3339: "2024-01-01T00:00:00Z"},
+		{ID: "2", From: "news@shop.example", FromName: "Shop Deals", Subject: "Sale", DateRFC3339: "2024-02-01T00:00:00Z"},
+		{ID: "3", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-03-01T00:00:00Z"},
+		{ID: "4", From: "jane.doe@x.example", Subject: "Hi"},
+	}
+	groups := AggregateBySenderSubject(msgs)
+	for _, tc := range []struct {
+		key, want string
+	}{
+		{"news@shop.example||Sale", "Shop Deals"},
+		{"jane.doe@x.example||Hi", "Jane Doe"},
+	} {
+		if got := groups[tc.key].DisplayName; got != tc.want {
+			t.Errorf("%s: DisplayName = %q; want %q", tc.key, got, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		sum  model.GroupSummary
+		want string
+	}{
+		{model.GroupSummary{Email: "news@shop.example", FromName: "Shop Deals"}, "Shop Deals"},
+		{model.GroupSummary{Email: "jane.doe@x.example"}, "Jane Doe"},
+	} {
+		if got := groupFromSummary(tc.sum).DisplayName; got != tc.want {
+			t.Errorf("summary %s: DisplayName = %q; want %q", tc.sum.Email, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// FilterAction is what a Gmail filter made by CreateSenderFilter does with
+// new mail from its sender.
+type FilterAction struct {
+	// Kind is FilterArchive, FilterTrash or FilterLabel.
+	Kind string
+	// Label is the label FilterLabel applies, by name.
+	Label string
+}
+
+// Filter action kinds.
+const (
+	FilterArchive = "archive" // skip the inbox
+	FilterTrash   = "trash"   // delete it
+	FilterLabel   = "label"   // apply Label
+)
+
+// ParseFilterAction reads "archive", "trash" (or "delete") or "label NAME".
+func ParseFilterAction(s string) (FilterAction, error) {
+	s = strings.TrimSpace(s)
+	verb, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	switch strings.ToLower(verb) {
+	case "archive":
+		if rest == "" {
+			return FilterAction{Kind: FilterArchive}, nil
+		}
+	case "trash", "delete":
+		if rest == "" {
+			return FilterAction{Kind: FilterTrash}, nil
+		}
+	case "label":
+		if rest == "" {
+			return FilterAction{}, errors.New("label needs a label name, as in: label Receipts")
+		}
+		return FilterAction{Kind: FilterLabel, Label: strings.Trim(rest, `"`)}, nil
+	}
+	return FilterAction{}, fmt.Errorf("unknown filter action %q: use archive, trash or label NAME", s)
+}
+
+func (a FilterAction) String() string {
+	if a.Kind == FilterLabel {
+		return "label " + a.Label
+	}
+	return a.Kind
+}
+
+// CreateSenderFilter adds a Gmail filter that applies a to all future mail
+// from sender, an address or "@domain", and returns the filter's ID. Gmail
+// runs the filter on arrival, so it works without chuckterm running; mail
+// already received is left alone. Label filters create a missing label.
+// It needs the gmail.settings.basic scope.
+func CreateSenderFilter(ctx context.Context, svc *gmailv1.Service, sender string, a FilterAction) (string, error) {
+	if sender == "" {
+		return "", errors.New("a filter needs a sender")
+	}
+	if SkipDryRun(ctx, "create a Gmail filter from:%s -> %s", sender, a) {
+		return "", ErrDryRun
+	}
+	action, err := filterAction(a, func(name string) (string, error) {
+		ids, err := ensureLabels(ctx, svc, []string{name})
+		return ids[name], err
+	})
+	if err != nil {
+		return "", err
+	}
+	f, err := retry(ctx, func() (*gmailv1.Filter, error) {
+		return svc.Users.Settings.Filters.Create("me", &gmailv1.Filter{
+			Criteria: &gmailv1.FilterCriteria{From: sender},
+			Action:   action,
+		}).Context(ctx).Do()
+	})
+	if err != nil {
+		return "", fmt.Errorf("create filter: %w", err)
+	}
+	slog.Info("created filter", "id", f.Id, "from", sender, "action", a.String())
+	return f.Id, nil
+}
+
+// RemoveSenderFilters deletes the Gmail filters that apply a to mail from
+// sender and do nothing else, such as those CreateSenderFilter made, and
+// returns how many it deleted. Filters matching more than the sender are
+// left alone.
+func RemoveSenderFilters(ctx context.Context, svc *gmailv1.Service, sender string, a FilterAction) (int, error) {
+	resp, err := retry(ctx, func() (*gmailv1.ListFiltersResponse, error) {
+		return svc.Users.Settings.Filters.List("me").Context(ctx).Do()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("list filters: %w", err)
+	}
+	want, err := filterAction(a, func(name string) (string, error) {
+		return ResolveLabel(ctx, svc, name)
+	})
+	if err != nil {
+		return 0, err
+	}
+	removed, skipped := 0, false
+	for _, f := range resp.Filter {
+		if !senderFilterMatches(f, sender, want) {
+			continue
+		}
+		if SkipDryRun(ctx, "delete the Gmail filter from:%s -> %s", sender, a) {
+			skipped = true
+			continue
+		}
+		if err := retryDo(ctx, func() error {
+			return svc.Users.Settings.Filters.Delete("me", f.Id).Context(ctx).Do()
+		}); err != nil {
+			return removed, fmt.Errorf("delete filter: %w", err)
+		}
+		slog.Info("deleted filter", "id", f.Id, "from", sender, "action", a.String())
+		removed++
+	}
+	if skipped {
+		return removed, ErrDryRun
+	}
+	return removed, nil
+}
+
+// filterAction is the Gmail form of a, looking labels up with labelID.
+func filterAction(a FilterAction, labelID func(name string) (string, error)) (*gmailv1.FilterAction, error) {
+	switch a.Kind {
+	case FilterArchive:
+		return &gmailv1.FilterAction{RemoveLabelIds: []string{"INBOX"}}, nil
+	case FilterTrash:
+		return &gmailv1.FilterAction{AddLabelIds: []string{"TRASH"}}, nil
+	case FilterLabel:
+		id, err := labelID(a.Label)
+		if err != nil {
+			return nil, err
+		}
+		return &gmailv1.FilterAction{AddLabelIds: []string{id}}, nil
+	}
+	return nil, fmt.Errorf("unknown filter action %q", a.Kind)
+}
+
+// senderFilterMatches reports whether f matches only mail from sender and
+// does exactly want with it.
+func senderFilterMatches(f *gmailv1.Filter, sender string, want *gmailv1.FilterAction) bool {
+	c, a := f.Criteria, f.Action
+	if c == nil || a == nil || !strings.EqualFold(c.From, sender) {
+		return false
+	}
+	if c.To != "" || c.Subject != "" || c.Query != "" || c.NegatedQuery != "" || c.HasAttachment || c.Size != 0 || c.ExcludeChats {
+		return false
+	}
+	return a.Forward == "" && slices.Equal(a.AddLabelIds, want.AddLabelIds) && slices.Equal(a.RemoveLabelIds, want.RemoveLabelIds)
+}
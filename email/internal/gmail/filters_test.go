@@ -0,0 +1,137 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"testing"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestParseFilterAction(t *testing.T) {
+	tests := []struct {
+		in   string
+		want FilterAction
+	}{
+		{"archive", FilterAction{Kind: FilterArchive}},
+		{" Trash ", FilterAction{Kind: FilterTrash}},
+		{"delete", FilterAction{Kind: FilterTrash}},
+		{"label Receipts", FilterAction{Kind: FilterLabel, Label: "Receipts"}},
+		{`label "Shop/Old orders"`, FilterAction{Kind: FilterLabel, Label: "Shop/Old orders"}},
+	}
+	for _, tc := range tests {
+		got, err := ParseFilterAction(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseFilterAction(%q) = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{"", "label", "archive now", "read"} {
+		if _, err := ParseFilterAction(in); err == nil {
+			t.Errorf("ParseFilterAction(%q) succeeded", in)
+		}
+	}
+}
+
+func TestCreateSenderFilter(t *testing.T) {
+	var got []gmailv1.Filter
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /gmail/v1/users/me/labels":
+			fmt.Fprint(w, `{"labels":[{"id":"INBOX","name":"INBOX"},{"id":"Label_7","name":"Receipts"}]}`)
+		case "POST /gmail/v1/users/me/settings/filters":
+			var f gmailv1.Filter
+			json.NewDecoder(r.Body).Decode(&f)
+			got = append(got, f)
+			fmt.Fprintf(w, `{"id":"f%d"}`, len(got))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range []FilterAction{{Kind: FilterArchive}, {Kind: FilterTrash}, {Kind: FilterLabel, Label: "receipts"}} {
+		if _, err := CreateSenderFilter(ctx, svc, "@shop.example", a); err != nil {
+			t.Fatalf("CreateSenderFilter(%v): %v", a, err)
+		}
+	}
+	want := []struct{ add, remove []string }{
+		{nil, []string{"INBOX"}},
+		{[]string{"TRASH"}, nil},
+		{[]string{"Label_7"}, nil},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("created %d filters; want %d", len(got), len(want))
+	}
+	for i, f := range got {
+		if f.Criteria == nil || f.Criteria.From != "@shop.example" {
+			t.Errorf("filter %d criteria = %+v", i, f.Criteria)
+		}
+		if f.Action == nil || !reflect.DeepEqual(f.Action.AddLabelIds, want[i].add) || !reflect.DeepEqual(f.Action.RemoveLabelIds, want[i].remove) {
+			t.Errorf("filter %d action = %+v; want add %v remove %v", i, f.Action, want[i].add, want[i].remove)
+		}
+	}
+
+	var lines []string
+	dry := WithDryRun(ctx, func(line string) { lines = append(lines, line) })
+	if _, err := CreateSenderFilter(dry, nil, "deals@shop.example", FilterAction{Kind: FilterArchive}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("dry-run CreateSenderFilter = %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "create a Gmail filter from:deals@shop.example -> archive" {
+		t.Errorf("audit lines = %q", lines)
+	}
+}
+
+func TestRemoveSenderFilters(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/gmail/v1/users/me/settings/filters":
+			fmt.Fprint(w, `{"filter":[
+				{"id":"a","criteria":{"from":"Deals@Shop.example"},"action":{"addLabelIds":["TRASH"]}},
+				{"id":"b","criteria":{"from":"deals@shop.example","subject":"sale"},"action":{"addLabelIds":["TRASH"]}},
+				{"id":"c","criteria":{"from":"deals@shop.example"},"action":{"removeLabelIds":["INBOX"]}},
+				{"id":"d","criteria":{"from":"news@shop.example"},"action":{"addLabelIds":["TRASH"]}}
+			]}`)
+		case r.Method == "DELETE":
+			deleted = append(deleted, path.Base(r.URL.Path))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trash := FilterAction{Kind: FilterTrash}
+	dry := WithDryRun(ctx, nil)
+	if n, err := RemoveSenderFilters(dry, svc, "deals@shop.example", trash); n != 0 || !errors.Is(err, ErrDryRun) || len(deleted) != 0 {
+		t.Errorf("dry-run RemoveSenderFilters = %d, %v; deleted %v", n, err, deleted)
+	}
+	n, err := RemoveSenderFilters(ctx, svc, "deals@shop.example", trash)
+	if err != nil || n != 1 {
+		t.Fatalf("RemoveSenderFilters = %d, %v", n, err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"a"}) {
+		t.Errorf("deleted %v; want [a]", deleted)
+	}
+}
@@ -0,0 +1,177 @@
+package gmail
+
+import (
+	"fmt"
+	"strings"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/util"
+)
+
+// SubjectGrouping is how subjects split a sender's mail into groups.
+type SubjectGrouping string
+
+const (
+	SubjectsExact      SubjectGrouping = "exact"      // one group per distinct subject (the default)
+	SubjectsNormalized SubjectGrouping = "normalized" // subjects compared after util.NormalizeSubject
+	SubjectsFuzzy      SubjectGrouping = "fuzzy"      // normalized, then near-identical subjects merged
+)
+
+// fuzzyThreshold is the util.SubjectSimilarity from which SubjectsFuzzy
+// treats two subjects as the same. It merges "Your order #1021 has shipped"
+// with "Your order #1187 has shipped" but keeps "CI failed on main" and "CI
+// passed on main" apart.
+const fuzzyThreshold = 0.6
+
+// ParseSubjectGrouping reads a grouping name; "" means SubjectsExact.
+func ParseSubjectGrouping(s string) (SubjectGrouping, error) {
+	switch g := SubjectGrouping(strings.ToLower(s)); g {
+	case "":
+		return SubjectsExact, nil
+	case SubjectsExact, SubjectsNormalized, SubjectsFuzzy:
+		return g, nil
+	}
+	return "", fmt.Errorf("unknown subject grouping %q (want exact, normalized or fuzzy)", s)
+}
+
+// Apply regroups exact sender+subject groups under the strategy. Merged
+// groups take the shared subject as their Subject, concatenate their
+// MessageIDs and list the original groups in Members, which is what
+// low-memory mode resolves messages through.
+//
+// SubjectsFuzzy clusters each sender's normalized subjects greedily, largest
+// group first: a subject joins the first cluster whose subject is at least
+// fuzzyThreshold similar, and otherwise starts its own.
+func (sg SubjectGrouping) Apply(groups []model.SenderGroup) []model.SenderGroup {
+	switch sg {
+	case SubjectsNormalized:
+		return mergeSubjects(groups, func(g model.SenderGroup) string { return util.NormalizeSubject(g.Subject) })
+	case SubjectsFuzzy:
+		normalized := SubjectsNormalized.Apply(groups)
+		clusters := make(map[string][]string) // sender -> cluster subjects
+		return mergeSubjects(normalized, func(g model.SenderGroup) string {
+			for _, c := range clusters[g.Email] {
+				if util.SubjectSimilarity(c, g.Subject) >= fuzzyThreshold {
+					return c
+				}
+			}
+			clusters[g.Email] = append(clusters[g.Email], g.Subject)
+			return g.Subject
+		})
+	}
+	return groups
+}
+
+// mergeSubjects merges the groups of each sender that subjectOf maps to the
+// same subject, visiting groups in order.
+func mergeSubjects(groups []model.SenderGroup, subjectOf func(model.SenderGroup) string) []model.SenderGroup {
+	merged := make(map[string]*model.SenderGroup)
+	newest := make(map[string]string) // LastDate of the group Sample came from
+	for _, g := range groups {
+		subject := subjectOf(g)
+		key := g.Email + "||" + subject
+		m, ok := merged[key]
+		if !ok {
+			m = &model.SenderGroup{Email: g.Email, Subject: subject, DisplayName: g.DisplayName}
+			merged[key] = m
+		}
+		m.Count += g.Count
+		m.Unread += g.Unread
+		m.Size += g.Size
+		if g.FirstDate != "" && (m.FirstDate == "" || g.FirstDate < m.FirstDate) {
+			m.FirstDate = g.FirstDate
+		}
+		if g.LastDate > m.LastDate {
+			m.LastDate = g.LastDate
+		}
+		m.Months = addMonths(m.Months, g.Months)
+		m.Bulk = m.Bulk || g.Bulk
+		if m.Sample == "" || g.LastDate > newest[key] {
+			m.Sample = g.Sample
+			newest[key] = g.LastDate
+		}
+		if m.UnsubscribeURL == "" && g.UnsubscribeURL != "" {
+			m.UnsubscribeURL = g.UnsubscribeURL
+			m.UnsubscribeOneClick = g.UnsubscribeOneClick
+		}
+		if m.UnsubscribeMailto == "" {
+			m.UnsubscribeMailto = g.UnsubscribeMailto
+		}
+		m.MessageIDs = append(m.MessageIDs, g.MessageIDs...)
+		m.Members = append(m.Members, memberKeys(g)...)
+	}
+	return SortGroups(merged)
+}
+
+// memberKeys returns the exact sender+subject groups g was built from.
+func memberKeys(g model.SenderGroup) []model.GroupKey {
+	if len(g.Members) > 0 {
+		return g.Members
+	}
+	return []model.GroupKey{{Email: g.Email, Subject: g.Subject}}
+}
+
+// GroupMode is what the groups gather: one sender's mail with one subject,
+// all of one sender's mail, or all mail from one sender domain.
+type GroupMode string
+
+const (
+	GroupBySubject GroupMode = "subject" // one group per sender and subject (the default)
+	GroupBySender  GroupMode = "sender"  // one group per sender (see BySender)
+	GroupByDomain  GroupMode = "domain"  // one group per sender domain (see ByDomain)
+)
+
+// GroupModes lists the grouping modes, in the order the groups view cycles
+// through them.
+var GroupModes = []GroupMode{GroupBySubject, GroupBySender, GroupByDomain}
+
+// ParseGroupMode reads a grouping mode name; "" means GroupBySubject.
+func ParseGroupMode(s string) (GroupMode, error) {
+	if s == "" {
+		return GroupBySubject, nil
+	}
+	for _, gm := range GroupModes {
+		if strings.EqualFold(s, string(gm)) {
+			return gm, nil
+		}
+	}
+	return "", fmt.Errorf("unknown grouping %q (want subject, sender or domain)", s)
+}
+
+// Next returns the mode after gm in GroupModes, wrapping around; "" counts
+// as GroupBySubject.
+func (gm GroupMode) Next() GroupMode {
+	for i, m := range GroupModes {
+		if m == gm || (gm == "" && m == GroupBySubject) {
+			return GroupModes[(i+1)%len(GroupModes)]
+		}
+	}
+	return GroupBySubject
+}
+
+// Apply regroups sender+subject groups under the mode. The result is
+// sorted by count; callers that order otherwise sort it again.
+func (gm GroupMode) Apply(groups []model.SenderGroup) []model.SenderGroup {
+	switch gm {
+	case GroupBySender:
+		return BySender(groups)
+	case GroupByDomain:
+		return ByDomain(groups)
+	}
+	return groups
+}
+
+// BySender merges the subject groups of each sender into one group with an
+// empty subject, whose Sample is the subject of the sender's newest mail.
+// Like the merged subject groups, it lists the exact groups in Members and
+// keeps the sender's unsubscribe link.
+func BySender(groups []model.SenderGroup) []model.SenderGroup {
+	subjects := make([]model.SenderGroup, len(groups))
+	for i, g := range groups {
+		if g.Subject != "" {
+			g.Sample = g.Subject
+		}
+		subjects[i] = g
+	}
+	return mergeSubjects(subjects, func(model.SenderGroup) string { return "" })
+}
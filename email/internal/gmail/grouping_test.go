@@ -0,0 +1,117 @@
+package gmail
+
+import (
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestSubjectGroupingApply(t *testing.T) {
+	groups := []model.SenderGroup{
+		{Email: "digest@x.com", Subject: "Digest — March 3", Sample: "Digest — March 3", Count: 1, LastDate: "2025-03-03T00:00:00Z", MessageIDs: []string{"1"}},
+		{Email: "digest@x.com", Subject: "Digest — March 10", Sample: "Digest — March 10", Count: 2, Unread: 1, LastDate: "2025-03-10T00:00:00Z", MessageIDs: []string{"2", "3"}, UnsubscribeURL: "https://x.com/u"},
+		{Email: "other@x.com", Subject: "Digest — March 3", Count: 1, MessageIDs: []string{"4"}},
+	}
+	if got := SubjectsExact.Apply(groups); len(got) != 3 {
+		t.Fatalf("exact grouping changed the groups: %+v", got)
+	}
+	got := SubjectsNormalized.Apply(groups)
+	if len(got) != 2 {
+		t.Fatalf("normalized = %+v", got)
+	}
+	d := got[0]
+	if d.Email != "digest@x.com" || d.Subject != "Digest" || d.Count != 3 || d.Unread != 1 ||
+		d.Sample != "Digest — March 10" || d.UnsubscribeURL != "https://x.com/u" || len(d.MessageIDs) != 3 || len(d.Members) != 2 {
+		t.Fatalf("merged group = %+v", d)
+	}
+
+	// Domain groups list the exact groups, not the normalized ones.
+	byDomain := ByDomain(got)
+	if len(byDomain) != 1 || len(byDomain[0].Members) != 3 || byDomain[0].Members[0].Subject != "Digest — March 3" {
+		t.Fatalf("ByDomain members = %+v", byDomain)
+	}
+
+	if g, err := ParseSubjectGrouping("Normalized"); err != nil || g != SubjectsNormalized {
+		t.Fatalf("ParseSubjectGrouping = %q, %v", g, err)
+	}
+	if _, err := ParseSubjectGrouping("similar"); err == nil {
+		t.Fatal("ParseSubjectGrouping accepted an unknown grouping")
+	}
+}
+
+func TestSubjectGroupingFuzzy(t *testing.T) {
+	groups := []model.SenderGroup{
+		{Email: "shop@x.com", Subject: "Your order #1021 has shipped", Count: 3, MessageIDs: []string{"1", "2", "3"}},
+		{Email: "shop@x.com", Subject: "Your order #1187 has shipped", Count: 1, MessageIDs: []string{"4"}},
+		{Email: "shop@x.com", Subject: "Re: Your order #1187 has shipped", Count: 1, MessageIDs: []string{"5"}},
+		{Email: "ci@x.com", Subject: "CI failed on main", Count: 2, MessageIDs: []string{"6", "7"}},
+		{Email: "ci@x.com", Subject: "CI passed on main", Count: 1, MessageIDs: []string{"8"}},
+		{Email: "other@x.com", Subject: "Your order #1021 has shipped", Count: 1, MessageIDs: []string{"9"}},
+	}
+	got := SubjectsFuzzy.Apply(groups)
+	if len(got) != 4 {
+		t.Fatalf("fuzzy = %+v", got)
+	}
+	if g := got[0]; g.Email != "shop@x.com" || g.Subject != "Your order #1021 has shipped" || g.Count != 5 || len(g.Members) != 3 {
+		t.Fatalf("shop cluster = %+v", g)
+	}
+}
+
+func TestGroupMode(t *testing.T) {
+	groups := []model.SenderGroup{
+		{Email: "news@x.com", DisplayName: "X News", Subject: "Weekly", Count: 3, Unread: 2, LastDate: "2025-03-01T00:00:00Z", MessageIDs: []string{"1", "2", "3"}},
+		{Email: "news@x.com", DisplayName: "X News", Subject: "Sale ends today", Count: 1, LastDate: "2025-04-01T00:00:00Z", MessageIDs: []string{"4"}, UnsubscribeURL: "https://x.com/u"},
+		{Email: "shop@mail.x.com", Subject: "Receipt", Count: 1, MessageIDs: []string{"5"}},
+	}
+	if got := GroupBySubject.Apply(groups); len(got) != 3 {
+		t.Fatalf("subject grouping changed the groups: %+v", got)
+	}
+	got := GroupBySender.Apply(groups)
+	if len(got) != 2 {
+		t.Fatalf("by sender = %+v", got)
+	}
+	s := got[0]
+	if s.Email != "news@x.com" || s.Subject != "" || s.DisplayName != "X News" || s.Count != 4 || s.Unread != 2 ||
+		s.Sample != "Sale ends today" || s.UnsubscribeURL != "https://x.com/u" || len(s.MessageIDs) != 4 || len(s.Members) != 2 || s.IsDomain() {
+		t.Fatalf("sender group = %+v", s)
+	}
+	if got := GroupByDomain.Apply(groups); len(got) != 1 || got[0].Count != 5 || got[0].Senders != 2 {
+		t.Fatalf("by domain = %+v", got)
+	}
+
+	if GroupMode("").Next() != GroupBySender || GroupBySender.Next() != GroupByDomain || GroupByDomain.Next() != GroupBySubject {
+		t.Fatal("Next does not cycle through GroupModes")
+	}
+	if gm, err := ParseGroupMode("Domain"); err != nil || gm != GroupByDomain {
+		t.Fatalf("ParseGroupMode = %q, %v", gm, err)
+	}
+	if _, err := ParseGroupMode("thread"); err == nil {
+		t.Fatal("ParseGroupMode accepted an unknown mode")
+	}
+}
+
+func TestBulkGroups(t *testing.T) {
+	msgs := []model.MessageRef{
+		{ID: "1", From: "news@x.com", Subject: "Weekly", Precedence: "bulk"},
+		{ID: "2", From: "news@x.com", Subject: "Hello"},
+		{ID: "3", From: "ada@y.com", Subject: "Lunch?", XMailer: "Thunderbird 115"},
+	}
+	groups := SortGroups(AggregateBySenderSubject(msgs))
+	bulk := map[string]bool{}
+	for _, g := range groups {
+		bulk[g.Subject] = g.Bulk
+	}
+	if !bulk["Weekly"] || bulk["Hello"] || bulk["Lunch?"] {
+		t.Fatalf("Bulk by subject = %v", bulk)
+	}
+	for _, g := range BySender(groups) {
+		if g.Bulk != (g.Email == "news@x.com") {
+			t.Fatalf("sender group %s: Bulk = %v", g.Email, g.Bulk)
+		}
+	}
+	for _, g := range ByDomain(groups) {
+		if g.Bulk != (g.Email == "@x.com") {
+			t.Fatalf("domain group %s: Bulk = %v", g.Email, g.Bulk)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package gmail
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlToText renders an HTML body as plain text for the terminal. Paragraphs
+// and headings become blank-line separated blocks, lists get bullets or
+// numbers, simple data tables are laid out in aligned columns, and links are
+// replaced by numbered references listed at the end. Entities are decoded by
+// the parser, so every named and numeric entity is handled.
+func htmlToText(src string) string {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return ""
+	}
+	links := &linkTable{index: map[string]int{}}
+	r := &textRenderer{links: links}
+	r.walk(doc)
+	text := r.String()
+	if len(links.urls) > 0 {
+		var b strings.Builder
+		b.WriteString(text)
+		b.WriteString("\n\nLinks:\n")
+		for i, u := range links.urls {
+			fmt.Fprintf(&b, "[%d] %s\n", i+1, u)
+		}
+		text = b.String()
+	}
+	return strings.TrimSpace(text)
+}
+
+// linkTable numbers link targets in order of first appearance, shared by all
+// renderers of one document so references stay unique.
+type linkTable struct {
+	urls  []string
+	index map[string]int
+}
+
+func (t *linkTable) ref(u string) int {
+	if n, ok := t.index[u]; ok {
+		return n
+	}
+	t.urls = append(t.urls, u)
+	t.index[u] = len(t.urls)
+	return len(t.urls)
+}
+
+// listState is one level of <ul>/<ol> nesting.
+type listState struct {
+	ordered bool
+	n       int
+}
+
+type textRenderer struct {
+	b       strings.Builder
+	links   *linkTable
+	lists   []listState
+	pre     int
+	newline int  // newlines owed before the next text
+	space   bool // a space is owed before the next word
+}
+
+// String returns the rendered text with trailing spaces and runs of blank
+// lines removed.
+func (r *textRenderer) String() string {
+	lines := strings.Split(r.b.String(), "\n")
+	out := lines[:0]
+	blank := 0
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t")
+		if l == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, l)
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
+
+// block asks for the next text to start n lines down (1 = new line,
+// 2 = after a blank line).
+func (r *textRenderer) block(n int) {
+	if r.b.Len() > 0 && n > r.newline {
+		r.newline = n
+	}
+	r.space = false
+}
+
+// write emits s, first paying any owed line breaks or space.
+func (r *textRenderer) write(s string) {
+	if s == "" {
+		return
+	}
+	if r.newline > 0 {
+		r.b.WriteString(strings.Repeat("\n", r.newline))
+		r.newline = 0
+		r.space = false
+	} else if r.space && r.b.Len() > 0 {
+		r.b.WriteByte(' ')
+	}
+	r.space = false
+	r.b.WriteString(s)
+}
+
+// text writes a text node, collapsing whitespace outside <pre>.
+func (r *textRenderer) text(s string) {
+	s = stripInvisible(s)
+	if r.pre > 0 {
+		for i, line := range strings.Split(s, "\n") {
+			if i > 0 {
+				r.b.WriteByte('\n')
+			}
+			r.write(line)
+		}
+		return
+	}
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			r.space = true
+		}
+		return
+	}
+	if startsWithSpace(s) {
+		r.space = true
+	}
+	for i, w := range words {
+		if i > 0 {
+			r.space = true
+		}
+		r.write(w)
+	}
+	if endsWithSpace(s) {
+		r.space = true
+	}
+}
+
+func (r *textRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+func (r *textRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.DocumentNode:
+		r.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Noscript, atom.Template:
+		return
+	case atom.Br:
+		if r.newline == 0 {
+			r.b.WriteByte('\n')
+		}
+		r.space = false
+	case atom.Hr:
+		r.block(2)
+		r.write("────────")
+		r.block(2)
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.block(2)
+		r.children(n)
+		r.block(2)
+	case atom.Pre:
+		r.block(2)
+		r.pre++
+		r.children(n)
+		r.pre--
+		r.block(2)
+	case atom.Ul, atom.Ol:
+		r.block(1)
+		r.lists = append(r.lists, listState{ordered: n.DataAtom == atom.Ol})
+		r.children(n)
+		r.lists = r.lists[:len(r.lists)-1]
+		r.block(1)
+	case atom.Li:
+		r.listItem(n)
+	case atom.Blockquote:
+		r.blockquote(n)
+	case atom.Table:
+		if isDataTable(n) {
+			r.block(2)
+			r.table(n)
+			r.block(2)
+		} else {
+			r.block(1)
+			r.children(n)
+			r.block(1)
+		}
+	case atom.Tr, atom.Td, atom.Th, atom.Div, atom.Section, atom.Article,
+		atom.Header, atom.Footer, atom.Center, atom.Address, atom.Form,
+		atom.Dl, atom.Dt, atom.Dd, atom.Figure, atom.Figcaption, atom.Main, atom.Nav:
+		r.block(1)
+		r.children(n)
+		r.block(1)
+	case atom.A:
+		r.link(n)
+	case atom.Img:
+		if alt := strings.TrimSpace(stripInvisible(attr(n, "alt"))); alt != "" {
+			r.write("[" + strings.Join(strings.Fields(alt), " ") + "]")
+		}
+	default:
+		r.children(n)
+	}
+}
+
+func (r *textRenderer) listItem(n *html.Node) {
+	r.block(1)
+	depth := len(r.lists)
+	marker := "•"
+	if depth > 0 {
+		l := &r.lists[depth-1]
+		l.n++
+		if l.ordered {
+			marker = fmt.Sprintf("%d.", l.n)
+		}
+	} else {
+		depth = 1
+	}
+	r.write(strings.Repeat("  ", depth-1) + marker)
+	r.space = true
+	r.children(n)
+	r.block(1)
+}
+
+// link renders the anchor text followed by a reference number, unless the
+// target is not worth listing or the text already shows it.
+func (r *textRenderer) link(n *html.Node) {
+	start := r.b.Len()
+	r.children(n)
+	href := strings.TrimSpace(attr(n, "href"))
+	lower := strings.ToLower(href)
+	if !(strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")) {
+		return
+	}
+	shown := strings.TrimSpace(r.b.String()[start:])
+	if shown == href || "mailto:"+shown == href {
+		return
+	}
+	r.space = shown != ""
+	r.write(fmt.Sprintf("[%d]", r.links.ref(href)))
+}
+
+// blockquote renders n separately and prefixes each line with "> ".
+func (r *textRenderer) blockquote(n *html.Node) {
+	sub := &textRenderer{links: r.links}
+	sub.children(n)
+	body := sub.String()
+	if body == "" {
+		return
+	}
+	r.block(2)
+	lines := strings.Split(body, "\n")
+	for i, l := range lines {
+		if i > 0 {
+			r.b.WriteByte('\n')
+		}
+		r.write(strings.TrimRight("> "+l, " "))
+	}
+	r.block(2)
+}
+
+// table lays out a data table in aligned columns, underlining a header row
+// made of <th> cells.
+func (r *textRenderer) table(n *html.Node) {
+	var rows [][]string
+	header := false
+	for i, tr := range tableRows(n) {
+		var cells []string
+		allTH := true
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+				continue
+			}
+			allTH = allTH && c.DataAtom == atom.Th
+			sub := &textRenderer{links: r.links}
+			sub.children(c)
+			cells = append(cells, strings.Join(strings.Fields(sub.String()), " "))
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		if i == 0 && allTH {
+			header = true
+		}
+		rows = append(rows, cells)
+	}
+
+	var widths []int
+	for _, row := range rows {
+		for i, c := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(c))
+		}
+	}
+	for i, row := range rows {
+		var line strings.Builder
+		for j, c := range row {
+			if j > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(c)
+			if j < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(c)))
+			}
+		}
+		r.block(1)
+		r.write(line.String())
+		if i == 0 && header {
+			total := 2 * (len(widths) - 1)
+			for _, w := range widths {
+				total += w
+			}
+			r.block(1)
+			r.write(strings.Repeat("─", total))
+		}
+	}
+}
+
+// isDataTable reports whether a table holds tabular data rather than page
+// layout: several columns, no nested tables, and only inline content in the
+// cells. Layout tables, which newsletters are built from, are rendered as
+// plain blocks instead.
+func isDataTable(n *html.Node) bool {
+	multiColumn := false
+	for _, tr := range tableRows(n) {
+		cells := 0
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+				continue
+			}
+			cells++
+			if hasBlockContent(c) {
+				return false
+			}
+		}
+		if cells > 1 {
+			multiColumn = true
+		}
+	}
+	return multiColumn
+}
+
+// tableRows returns the rows of n, looking through thead/tbody/tfoot but not
+// into nested tables.
+func tableRows(n *html.Node) []*html.Node {
+	var rows []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Tr:
+			rows = append(rows, c)
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			rows = append(rows, tableRows(c)...)
+		}
+	}
+	return rows
+}
+
+func hasBlockContent(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Table, atom.Div, atom.P, atom.Ul, atom.Ol, atom.Img, atom.Blockquote, atom.Pre,
+			atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			return true
+		}
+		if hasBlockContent(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// stripInvisible drops zero-width and soft-hyphen characters that
+// newsletters use to pad preview text.
+func stripInvisible(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\u00ad', '\u034f', '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func startsWithSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+func endsWithSpace(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return unicode.IsSpace(r)
+}
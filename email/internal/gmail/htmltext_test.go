@@ -0,0 +1,47 @@
+package gmail
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{
+			name: "paragraphs and entities",
+			in:   "<html><head><title>x</title><style>p{}</style></head><body><h1>Hi&nbsp;there</h1><p>Tom &amp; Jerry &mdash; &#8220;friends&#x201D; &hellip;</p><p>line<br>break</p></body></html>",
+			want: "Hi there\n\nTom & Jerry — “friends” …\n\nline\nbreak",
+		},
+		{
+			name: "links become footnotes",
+			in:   `<p>Read <a href="https://example.com/a">the post</a> or <a href="https://example.com/b">this</a>, again <a href="https://example.com/a">here</a>. Mail <a href="mailto:me@example.com">me@example.com</a>, <a href="#top">top</a>.</p>`,
+			want: "Read the post [1] or this [2], again here [1]. Mail me@example.com, top.\n\nLinks:\n[1] https://example.com/a\n[2] https://example.com/b",
+		},
+		{
+			name: "nested lists",
+			in:   "<ul><li>one</li><li>two<ol><li>a</li><li>b</li></ol></li></ul><p>after</p>",
+			want: "• one\n• two\n  1. a\n  2. b\n\nafter",
+		},
+		{
+			name: "data table",
+			in:   "<table><tr><th>Item</th><th>Qty</th></tr><tr><td>Apples</td><td>3</td></tr><tr><td>Kiwi</td><td>12</td></tr></table>",
+			want: "Item    Qty\n───────────\nApples  3\nKiwi    12",
+		},
+		{
+			name: "layout table",
+			in:   `<table><tr><td><table><tr><td><img src="x.png" alt="Logo"></td></tr></table></td></tr><tr><td><p>Body text</p></td><td>Sidebar</td></tr></table>`,
+			want: "[Logo]\n\nBody text\n\nSidebar",
+		},
+		{
+			name: "blockquote and invisible padding",
+			in:   "<div>Preview\u200c\u034f text</div><blockquote><p>quoted</p><p>twice</p></blockquote>",
+			want: "Preview text\n\n> quoted\n>\n> twice",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := htmlToText(c.in); got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
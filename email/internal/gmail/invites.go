@@ -0,0 +1,118 @@
+package gmail
+
+import (
+	"context"
+	"strings"
+
+	"chuckterm/internal/ics"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// extractInvites parses the first calendar part of a message that holds
+// events, fetching it if Gmail stored it as an attachment, and attaches the
+// message's RSVP links. Invitations are a convenience: any failure just
+// yields none.
+func extractInvites(ctx context.Context, svc *gmailv1.Service, messageID string, msg *gmailv1.Message) []ics.Event {
+	for _, part := range calendarParts(msg.Payload) {
+		data := ""
+		switch {
+		case part.Body.Data != "":
+			data = decodePartBody(part)
+		case part.Body.AttachmentId != "" && svc != nil:
+			body, err := retry(ctx, func() (*gmailv1.MessagePartBody, error) {
+				return svc.Users.Messages.Attachments.Get("me", messageID, part.Body.AttachmentId).Context(ctx).Do()
+			})
+			if err != nil {
+				continue
+			}
+			data = decodeBase64URL(body.Data)
+		}
+		events, err := ics.Parse(data)
+		if err != nil || len(events) == 0 {
+			continue
+		}
+		links := rsvpLinks(extractHTML(msg.Payload))
+		for i := range events {
+			events[i].RSVP = links
+		}
+		return events
+	}
+	return nil
+}
+
+// calendarParts lists text/calendar and .ics parts, inline ones first since
+// they need no extra request.
+func calendarParts(part *gmailv1.MessagePart) []*gmailv1.MessagePart {
+	var inline, attached []*gmailv1.MessagePart
+	var walk func(p *gmailv1.MessagePart)
+	walk = func(p *gmailv1.MessagePart) {
+		if p == nil {
+			return
+		}
+		mime := strings.ToLower(p.MimeType)
+		isCal := mime == "text/calendar" || mime == "application/ics" || strings.HasSuffix(strings.ToLower(p.Filename), ".ics")
+		if isCal && p.Body != nil {
+			if p.Body.Data != "" {
+				inline = append(inline, p)
+			} else if p.Body.AttachmentId != "" {
+				attached = append(attached, p)
+			}
+		}
+		for _, sub := range p.Parts {
+			walk(sub)
+		}
+	}
+	walk(part)
+	return append(inline, attached...)
+}
+
+// rsvpLabels maps the anchor texts invitation emails use for responses to
+// the label shown in chuckterm.
+var rsvpLabels = map[string]string{
+	"yes": "Yes", "accept": "Yes",
+	"no": "No", "decline": "No",
+	"maybe": "Maybe", "tentative": "Maybe",
+}
+
+// rsvpLinks finds the Yes/No/Maybe response links of an invitation email.
+func rsvpLinks(src string) []ics.Link {
+	if src == "" {
+		return nil
+	}
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil
+	}
+	var links []ics.Link
+	seen := make(map[string]bool)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			label := rsvpLabels[strings.ToLower(strings.TrimSpace(nodeText(n)))]
+			href := attr(n, "href")
+			if label != "" && !seen[label] && (strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "http://")) {
+				seen[label] = true
+				links = append(links, ics.Link{Label: label, URL: href})
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return links
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
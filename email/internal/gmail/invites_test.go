@@ -0,0 +1,44 @@
+package gmail
+
+import (
+	"testing"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+func TestCalendarParts(t *testing.T) {
+	payload := &gmailv1.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts: []*gmailv1.MessagePart{
+			{PartId: "0", MimeType: "text/plain", Body: &gmailv1.MessagePartBody{Data: "aGk"}},
+			{PartId: "1", MimeType: "application/octet-stream", Filename: "invite.ics", Body: &gmailv1.MessagePartBody{AttachmentId: "att-1"}},
+			{PartId: "2", MimeType: "multipart/alternative", Parts: []*gmailv1.MessagePart{
+				{PartId: "2.1", MimeType: "text/calendar", Body: &gmailv1.MessagePartBody{Data: "QkVHSU4"}},
+			}},
+		},
+	}
+	got := calendarParts(payload)
+	if len(got) != 2 || got[0].PartId != "2.1" || got[1].PartId != "1" {
+		t.Fatalf("calendarParts = %+v", got)
+	}
+}
+
+func TestRSVPLinks(t *testing.T) {
+	src := `<p>Going?
+		<a href="https://cal.example.com/r?a=yes">Yes</a>
+		<a href="https://cal.example.com/r?a=maybe"> Maybe </a>
+		<a href="https://cal.example.com/r?a=no">No</a>
+		<a href="https://cal.example.com/r?a=yes2">Accept</a>
+		<a href="javascript:void(0)">Decline</a>
+		<a href="https://cal.example.com/more">More options</a></p>`
+	got := rsvpLinks(src)
+	want := []string{"Yes https://cal.example.com/r?a=yes", "Maybe https://cal.example.com/r?a=maybe", "No https://cal.example.com/r?a=no"}
+	if len(got) != len(want) {
+		t.Fatalf("rsvpLinks = %+v", got)
+	}
+	for i, l := range got {
+		if l.Label+" "+l.URL != want[i] {
+			t.Errorf("link %d = %+v, want %s", i, l, want[i])
+		}
+	}
+}
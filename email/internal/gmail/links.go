@@ -0,0 +1,100 @@
+package gmail
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// linkPattern finds http(s) and mailto: URLs in plain text. Brackets are
+// left out so the [n] references htmlToText adds do not stick to a URL.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|mailto:)[^\s<>"'\x60\[\]]+`)
+
+// ExtractLinks returns the http(s) and mailto: links of a message body in
+// order, each once. The first listed of them are the numbered "Links:" list
+// htmlToText ends an HTML body with, so numbers match its [n] references;
+// the rest were found in the text and are not numbered in it yet.
+func ExtractLinks(text string) (links []string, listed int) {
+	seen := map[string]bool{}
+	if i := strings.LastIndex(text, "\n\nLinks:\n"); i >= 0 {
+		for _, line := range strings.Split(text[i+len("\n\nLinks:\n"):], "\n") {
+			ref, u, ok := strings.Cut(strings.TrimSpace(line), " ")
+			if !ok || ref != fmt.Sprintf("[%d]", len(links)+1) {
+				break
+			}
+			links = append(links, u)
+			seen[u] = true
+		}
+	}
+	listed = len(links)
+	for _, u := range linkPattern.FindAllString(text, -1) {
+		u = trimLink(u)
+		if !seen[u] {
+			links = append(links, u)
+			seen[u] = true
+		}
+	}
+	return links, listed
+}
+
+// trimLink drops the punctuation that ends the sentence a URL sits in, and
+// a closing parenthesis it does not open, as in "(see https://x.example)".
+func trimLink(u string) string {
+	for {
+		trimmed := strings.TrimRight(u, ".,;:!?")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == u {
+			return u
+		}
+		u = trimmed
+	}
+}
+
+// MailtoDraft turns a mailto: link into a draft addressed as it asks, with
+// its subject and body.
+func MailtoDraft(link string) (Draft, error) {
+	u, err := url.Parse(link)
+	if err != nil || !strings.EqualFold(u.Scheme, "mailto") {
+		return Draft{}, fmt.Errorf("%q is not a mailto: link", link)
+	}
+	to, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return Draft{}, fmt.Errorf("mailto address %q: %w", u.Opaque, err)
+	}
+	query := u.Query()
+	var recipients []string
+	for _, list := range append([]string{to}, query["to"]...) {
+		if list = strings.TrimSpace(list); list != "" {
+			recipients = append(recipients, list)
+		}
+	}
+	d := Draft{
+		To:      strings.Join(recipients, ", "),
+		Cc:      strings.Join(query["cc"], ", "),
+		Subject: query.Get("subject"),
+		Body:    strings.ReplaceAll(query.Get("body"), "\r\n", "\n"),
+	}
+	if d.To != "" {
+		if _, err := mail.ParseAddressList(d.To); err != nil {
+			return Draft{}, fmt.Errorf("mailto address %q: %w", d.To, err)
+		}
+	}
+	return d, nil
+}
+
+// LinkNumber reads the number of a link typed at a prompt, 1 to n.
+func LinkNumber(s string, n int) (int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "#")))
+	if err != nil || i < 1 || i > n {
+		if n == 1 {
+			return 0, fmt.Errorf("%q is not a link number; the only link is 1", s)
+		}
+		return 0, fmt.Errorf("%q is not a link number; pick 1 to %d", s, n)
+	}
+	return i, nil
+}
@@ -0,0 +1,62 @@
+package gmail
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	text := htmlToText(`<p>Read <a href="https://shop.example/post">the post</a> or
+		<a href="mailto:help@shop.example">write to us</a>.</p>
+		<p>Plain: https://shop.example/post and (see https://docs.example/a_(b)).</p>`)
+	text += "\nAlso https://shop.example/track?id=7, then mailto:ann@x.example?subject=Hi."
+	links, listed := ExtractLinks(text)
+	want := []string{
+		"https://shop.example/post",
+		"mailto:help@shop.example",
+		"https://docs.example/a_(b)",
+		"https://shop.example/track?id=7",
+		"mailto:ann@x.example?subject=Hi",
+	}
+	if !reflect.DeepEqual(links, want) || listed != 2 {
+		t.Errorf("ExtractLinks = %q, %d; want %q, 2", links, listed, want)
+	}
+
+	links, listed = ExtractLinks("Visit https://a.example/x.\nLinks:\n[1] https://b.example")
+	if !reflect.DeepEqual(links, []string{"https://a.example/x", "https://b.example"}) || listed != 0 {
+		t.Errorf("ExtractLinks of plain text = %q, %d", links, listed)
+	}
+	if links, listed := ExtractLinks("no links here"); links != nil || listed != 0 {
+		t.Errorf("ExtractLinks without links = %q, %d", links, listed)
+	}
+}
+
+func TestMailtoDraft(t *testing.T) {
+	d, err := MailtoDraft("mailto:ann@x.example?cc=bob@x.example&subject=Hello%20there&body=Line%201%0D%0ALine%202")
+	want := Draft{To: "ann@x.example", Cc: "bob@x.example", Subject: "Hello there", Body: "Line 1\nLine 2"}
+	if err != nil || d != want {
+		t.Errorf("MailtoDraft = %+v, %v; want %+v", d, err, want)
+	}
+	d, err = MailtoDraft("MAILTO:?to=ann@x.example&to=bob@x.example")
+	if err != nil || d.To != "ann@x.example, bob@x.example" {
+		t.Errorf("MailtoDraft with to= = %+v, %v", d, err)
+	}
+	for _, link := range []string{"https://x.example", "mailto:not an address"} {
+		if _, err := MailtoDraft(link); err == nil {
+			t.Errorf("MailtoDraft(%q) succeeded", link)
+		}
+	}
+}
+
+func TestLinkNumber(t *testing.T) {
+	for in, want := range map[string]int{"1": 1, " 3 ": 3, "#2": 2} {
+		if got, err := LinkNumber(in, 3); err != nil || got != want {
+			t.Errorf("LinkNumber(%q, 3) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0", "4", "two"} {
+		if _, err := LinkNumber(in, 3); err == nil {
+			t.Errorf("LinkNumber(%q, 3) succeeded", in)
+		}
+	}
+}
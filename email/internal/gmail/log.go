@@ -0,0 +1,79 @@
+package gmail
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// The package logs to slog's default logger: syncs and their phases,
+// retried and failed API calls, and the changes made to mail. The command
+// line points that logger at chuckterm.log.
+
+// logPhases wraps progress, which may be nil, so each new sync phase is
+// logged once rather than on every update.
+func logPhases(progress func(SyncProgress)) func(SyncProgress) {
+	last := ""
+	return func(sp SyncProgress) {
+		if sp.Phase != last {
+			last = sp.Phase
+			slog.Debug("sync phase", "phase", sp.Phase, "done", sp.Done, "total", sp.Total)
+		}
+		if progress != nil {
+			progress(sp)
+		}
+	}
+}
+
+// logSync logs how the sync named op, started at start with the API usage
+// before, ended and what it cost; it is deferred with a pointer to the
+// sync's error.
+func logSync(op string, start time.Time, before QuotaUsage, err *error) {
+	after := Usage()
+	attrs := []any{
+		"duration", time.Since(start),
+		"api_calls", after.Calls - before.Calls,
+		"quota_units", after.Units - before.Units,
+		"throttled", after.Throttled - before.Throttled,
+	}
+	if *err != nil {
+		slog.Error(op+" failed", append(attrs, "err", *err)...)
+		return
+	}
+	slog.Info(op+" done", attrs...)
+}
+
+// logUnsubscribe logs an unsubscribe through link by method, or why it
+// failed.
+func logUnsubscribe(method UnsubscribeMethod, link string, err error) {
+	if err != nil {
+		slog.Error("unsubscribe failed", "method", method, "url", link, "err", err)
+		return
+	}
+	slog.Info("unsubscribe", "method", method, "url", link)
+}
+
+// LogUsage logs the Gmail API calls and quota units of the process so far,
+// one line per method, unless it made none.
+func LogUsage() {
+	u := Usage()
+	if u.Calls == 0 {
+		return
+	}
+	for _, op := range u.Operations {
+		slog.Info("api usage", "operation", op.Operation, "calls", op.Calls, "quota_units", op.Units)
+	}
+	slog.Info("api usage total", "calls", u.Calls, "quota_units", u.Units, "peak_units_per_second", u.PeakUnits, "throttled", u.Throttled)
+}
+
+// logChange logs a change to mail, or why it failed. Dry runs are logged
+// when they are skipped.
+func logChange(action string, messages int, err error) {
+	switch {
+	case errors.Is(err, ErrDryRun):
+	case err != nil:
+		slog.Error(action+" failed", "messages", messages, "err", err)
+	default:
+		slog.Info(action, "messages", messages)
+	}
+}
@@ -0,0 +1,55 @@
+package gmail
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLog sends slog's default logger to a buffer for the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+func TestLogPhases(t *testing.T) {
+	buf := captureLog(t)
+	var seen int
+	progress := logPhases(func(SyncProgress) { seen++ })
+	for _, sp := range []SyncProgress{
+		{Phase: "history-start", Total: 3},
+		{Phase: "history", Total: 3, Done: 1},
+		{Phase: "history", Total: 3, Done: 2},
+		{Phase: "history-done", Total: 3, Done: 3},
+	} {
+		progress(sp)
+	}
+	if seen != 4 {
+		t.Errorf("wrapped progress saw %d updates; want 4", seen)
+	}
+	if n := strings.Count(buf.String(), "sync phase"); n != 3 {
+		t.Errorf("logged %d phases; want 3:\n%s", n, buf)
+	}
+	logPhases(nil)(SyncProgress{Phase: "fullscan-start"})
+}
+
+func TestLogChange(t *testing.T) {
+	buf := captureLog(t)
+	logChange("archive", 2, nil)
+	logChange("trash", 1, errors.New("boom"))
+	logChange("archive", 5, ErrDryRun)
+	got := buf.String()
+	for _, want := range []string{"level=INFO msg=archive messages=2", `level=ERROR msg="trash failed" messages=1 err=boom`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "messages=5") {
+		t.Errorf("a skipped dry run was logged as a change:\n%s", got)
+	}
+}
@@ -2,6 +2,8 @@ package gmail
 
 import (
 	"encoding/base64"
+	"io"
+	"mime/quotedprintable"
 	"strings"
 
 	gmailv1 "google.golang.org/api/gmail/v1"
@@ -17,9 +19,9 @@ func extractPlainText(part *gmailv1.MessagePart) string {
 
 	mime := strings.ToLower(part.MimeType)
 
-	// Leaf node with text/plain body data
-	if mime == "text/plain" && part.Body != nil && part.Body.Data != "" {
-		return decodeBase64URL(part.Body.Data)
+	// Leaf node with text/plain body data; named parts are attachments.
+	if mime == "text/plain" && part.Filename == "" && part.Body != nil && part.Body.Data != "" {
+		return decodePartBody(part)
 	}
 
 	// Recurse into sub-parts (multipart/*)
@@ -52,8 +54,8 @@ func extractHTML(part *gmailv1.MessagePart) string {
 
 	mime := strings.ToLower(part.MimeType)
 
-	if mime == "text/html" && part.Body != nil && part.Body.Data != "" {
-		return decodeBase64URL(part.Body.Data)
+	if mime == "text/html" && part.Filename == "" && part.Body != nil && part.Body.Data != "" {
+		return decodePartBody(part)
 	}
 
 	for _, sub := range part.Parts {
@@ -65,47 +67,35 @@ func extractHTML(part *gmailv1.MessagePart) string {
 	return ""
 }
 
-// stripHTMLTags removes HTML tags and decodes common entities to produce readable text.
-func stripHTMLTags(html string) string {
-	// Replace block-level elements with newlines
-	for _, tag := range []string{"<br>", "<br/>", "<br />", "</p>", "</div>", "</tr>", "</li>", "</h1>", "</h2>", "</h3>", "</h4>", "</h5>", "</h6>"} {
-		html = strings.ReplaceAll(html, tag, "\n")
-		html = strings.ReplaceAll(html, strings.ToUpper(tag), "\n")
+// decodePartBody returns the text of a leaf part, undoing a
+// quoted-printable Content-Transfer-Encoding that survived Gmail's own
+// decoding (seen in nested parts of some forwarded and bulk messages).
+func decodePartBody(part *gmailv1.MessagePart) string {
+	body := decodeBase64URL(part.Body.Data)
+	if strings.EqualFold(partHeader(part, "Content-Transfer-Encoding"), "quoted-printable") {
+		return decodeQuotedPrintable(body)
 	}
+	return body
+}
 
-	// Strip all remaining tags
-	var b strings.Builder
-	inTag := false
-	for _, r := range html {
-		switch {
-		case r == '<':
-			inTag = true
-		case r == '>':
-			inTag = false
-		case !inTag:
-			b.WriteRune(r)
-		}
-	}
-	result := b.String()
-
-	// Decode common HTML entities
-	replacer := strings.NewReplacer(
-		"&amp;", "&",
-		"&lt;", "<",
-		"&gt;", ">",
-		"&quot;", "\"",
-		"&#39;", "'",
-		"&apos;", "'",
-		"&nbsp;", " ",
-	)
-	result = replacer.Replace(result)
-
-	// Collapse multiple blank lines
-	for strings.Contains(result, "\n\n\n") {
-		result = strings.ReplaceAll(result, "\n\n\n", "\n\n")
+// decodeQuotedPrintable decodes s, returning it unchanged if it is not valid
+// quoted-printable (e.g. Gmail already decoded it and the text contains a
+// literal "=").
+func decodeQuotedPrintable(s string) string {
+	b, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(s)))
+	if err != nil {
+		return s
 	}
+	return string(b)
+}
 
-	return strings.TrimSpace(result)
+func partHeader(part *gmailv1.MessagePart, name string) string {
+	for _, h := range part.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return strings.TrimSpace(h.Value)
+		}
+	}
+	return ""
 }
 
 func decodeBase64URL(data string) string {
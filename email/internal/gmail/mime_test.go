@@ -0,0 +1,48 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"testing"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+func textPart(mime, body string, headers ...*gmailv1.MessagePartHeader) *gmailv1.MessagePart {
+	return &gmailv1.MessagePart{
+		MimeType: mime,
+		Headers:  headers,
+		Body:     &gmailv1.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(body))},
+	}
+}
+
+func TestExtractPlainTextQuotedPrintable(t *testing.T) {
+	qp := &gmailv1.MessagePartHeader{Name: "content-transfer-encoding", Value: "Quoted-Printable"}
+	msg := &gmailv1.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts: []*gmailv1.MessagePart{{
+			MimeType: "multipart/alternative",
+			Parts: []*gmailv1.MessagePart{
+				textPart("text/plain", "It=E2=80=99s a long line that was soft=\r\n-wrapped.", qp),
+				textPart("text/html", "<p>caf=C3=A9</p>", qp),
+			},
+		}},
+	}
+	if got, want := extractPlainText(msg), "It’s a long line that was soft-wrapped."; got != want {
+		t.Errorf("plain = %q, want %q", got, want)
+	}
+	if got, want := extractHTML(msg), "<p>café</p>"; got != want {
+		t.Errorf("html = %q, want %q", got, want)
+	}
+}
+
+func TestExtractPlainTextLeavesDecodedText(t *testing.T) {
+	qp := &gmailv1.MessagePartHeader{Name: "Content-Transfer-Encoding", Value: "quoted-printable"}
+	for _, body := range []string{"x=y and 100% =ZZ", "plain text"} {
+		if got := extractPlainText(textPart("text/plain", body, qp)); got != body {
+			t.Errorf("got %q, want %q unchanged", got, body)
+		}
+	}
+	if got := extractPlainText(textPart("text/plain", "a=E2=80=99b")); got != "a=E2=80=99b" {
+		t.Errorf("part without the header was decoded: %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package gmail
+
+import (
+	"fmt"
+	"strings"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/util"
+)
+
+// NewMailNotice summarises newly synced messages for a desktop notification.
+// Only unread messages count; ok is false when there are none.
+func NewMailNotice(msgs []model.MessageRef) (title, body string, ok bool) {
+	var unread []model.MessageRef
+	for _, m := range msgs {
+		if m.Unread() {
+			unread = append(unread, m)
+		}
+	}
+	switch len(unread) {
+	case 0:
+		return "", "", false
+	case 1:
+		m := unread[0]
+		return "New mail from " + displayNameFromFrom(m.From, util.NormalizeSender(m.From)), m.Subject, true
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range unread {
+		email := util.NormalizeSender(m.From)
+		if seen[email] {
+			continue
+		}
+		seen[email] = true
+		names = append(names, displayNameFromFrom(m.From, email))
+	}
+	const shown = 3
+	body = strings.Join(names[:min(len(names), shown)], ", ")
+	if rest := len(names) - shown; rest > 0 {
+		body += fmt.Sprintf(" and %d more", rest)
+	}
+	return fmt.Sprintf("%d new messages", len(unread)), "From " + body, true
+}
@@ -0,0 +1,33 @@
+package gmail
+
+import (
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestNewMailNotice(t *testing.T) {
+	unread := []string{"INBOX", "UNREAD"}
+	if _, _, ok := NewMailNotice([]model.MessageRef{{From: "a@example.com", LabelIDs: []string{"INBOX"}}}); ok {
+		t.Fatal("read messages should not notify")
+	}
+
+	title, body, ok := NewMailNotice([]model.MessageRef{{From: "Alice <alice@example.com>", Subject: "Lunch?", LabelIDs: unread}})
+	if !ok || title != "New mail from Alice" || body != "Lunch?" {
+		t.Fatalf("single: %q %q %v", title, body, ok)
+	}
+
+	msgs := []model.MessageRef{
+		{From: "Alice <alice@example.com>", LabelIDs: unread},
+		{From: "Alice <alice@example.com>", LabelIDs: unread},
+		{From: "Bob <bob@example.com>", LabelIDs: unread},
+		{From: "Carol <carol@example.com>", LabelIDs: unread},
+		{From: "Dan <dan@example.com>", LabelIDs: unread},
+		{From: "Eve <eve@example.com>", LabelIDs: unread},
+		{From: "Read <read@example.com>"},
+	}
+	title, body, _ = NewMailNotice(msgs)
+	if title != "6 new messages" || body != "From Alice, Bob, Carol and 2 more" {
+		t.Fatalf("several: %q %q", title, body)
+	}
+}
@@ -0,0 +1,78 @@
+package gmail
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"chuckterm/internal/model"
+)
+
+// GroupOrder is a sort order of the groups list.
+type GroupOrder string
+
+const (
+	OrderCount  GroupOrder = "count"  // most messages first (the default)
+	OrderNewest GroupOrder = "newest" // most recent message first
+	OrderOldest GroupOrder = "oldest" // oldest message first
+	OrderSender GroupOrder = "sender" // alphabetical by sender
+	OrderSize   GroupOrder = "size"   // most bytes first
+)
+
+// GroupOrders lists the supported orders, in the order the groups view
+// cycles through them.
+var GroupOrders = []GroupOrder{OrderCount, OrderNewest, OrderOldest, OrderSender, OrderSize}
+
+// ParseGroupOrder reads an order name; "" means OrderCount.
+func ParseGroupOrder(s string) (GroupOrder, error) {
+	if s == "" {
+		return OrderCount, nil
+	}
+	for _, o := range GroupOrders {
+		if strings.EqualFold(s, string(o)) {
+			return o, nil
+		}
+	}
+	return "", fmt.Errorf("unknown sort order %q (want count, newest, oldest, sender or size)", s)
+}
+
+// Next returns the order after o in GroupOrders, wrapping around; "" counts
+// as OrderCount.
+func (o GroupOrder) Next() GroupOrder {
+	if o == "" {
+		o = OrderCount
+	}
+	for i, g := range GroupOrders {
+		if g == o {
+			return GroupOrders[(i+1)%len(GroupOrders)]
+		}
+	}
+	return OrderCount
+}
+
+// SortGroupsBy sorts groups in place by order, keeping pinned groups first.
+// Ties fall back to the SortGroups order so the result is deterministic.
+func SortGroupsBy(groups []model.SenderGroup, order GroupOrder) {
+	sortGroupSlice(groups)
+	var less func(a, b model.SenderGroup) bool
+	switch order {
+	case OrderNewest:
+		less = func(a, b model.SenderGroup) bool { return a.LastDate > b.LastDate }
+	case OrderOldest:
+		less = func(a, b model.SenderGroup) bool {
+			return a.FirstDate != "" && (b.FirstDate == "" || a.FirstDate < b.FirstDate)
+		}
+	case OrderSender:
+		less = func(a, b model.SenderGroup) bool {
+			return strings.ToLower(a.DisplayName) < strings.ToLower(b.DisplayName)
+		}
+	case OrderSize:
+		less = func(a, b model.SenderGroup) bool { return a.Size > b.Size }
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Pinned != groups[j].Pinned {
+			return groups[i].Pinned
+		}
+		return less != nil && less(groups[i], groups[j])
+	})
+}
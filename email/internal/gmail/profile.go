@@ -0,0 +1,87 @@
+package gmail
+
+import (
+	"context"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/util"
+)
+
+// SenderProfile sums up the cached mail of one sender across all of its
+// subject groups.
+type SenderProfile struct {
+	Email       string
+	DisplayName string
+	Groups      []model.SenderGroup // largest first
+	Count       int
+	Unread      int
+	Size        int64
+	FirstDate   string // oldest RFC3339 among the groups
+	LastDate    string // newest RFC3339 among the groups
+	// Unsubscribe is a group carrying the sender's unsubscribe link, one
+	// with an HTTP link if any has; nil when no group has a link.
+	Unsubscribe  *model.SenderGroup
+	Unsubscribed *model.Unsubscription
+	Status       model.SenderStatus
+}
+
+// NewSenderProfile sums up groups, the sender+subject groups of one sender.
+func NewSenderProfile(email string, groups []model.SenderGroup) SenderProfile {
+	p := SenderProfile{Email: email, DisplayName: email, Groups: groups}
+	for i, g := range groups {
+		p.Count += g.Count
+		p.Unread += g.Unread
+		p.Size += g.Size
+		if g.FirstDate != "" && (p.FirstDate == "" || g.FirstDate < p.FirstDate) {
+			p.FirstDate = g.FirstDate
+		}
+		if g.LastDate > p.LastDate {
+			p.LastDate = g.LastDate
+		}
+		if p.DisplayName == email && g.DisplayName != "" {
+			p.DisplayName = g.DisplayName
+		}
+		if g.UnsubscribeURL != "" && (p.Unsubscribe == nil || p.Unsubscribe.UnsubscribeURL == "") {
+			p.Unsubscribe = &groups[i]
+		} else if g.UnsubscribeMailto != "" && p.Unsubscribe == nil {
+			p.Unsubscribe = &groups[i]
+		}
+		if g.Unsubscribed != nil {
+			p.Unsubscribed = g.Unsubscribed
+		}
+		if g.Status != "" {
+			p.Status = g.Status
+		}
+	}
+	return p
+}
+
+// LoadSenderGroups returns the cached sender+subject groups of email,
+// largest first. Stores that aggregate groups themselves do so, as in
+// low-memory mode, and their groups carry no MessageIDs.
+func LoadSenderGroups(ctx context.Context, store MessageStore, email string) ([]model.SenderGroup, error) {
+	if _, ok := store.(GroupSummaryStore); ok {
+		all, err := LoadGroupSummariesFromDB(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		var groups []model.SenderGroup
+		for _, g := range all {
+			if g.Email == email {
+				groups = append(groups, g)
+			}
+		}
+		return groups, nil
+	}
+	msgs, err := store.LoadAllMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var own []model.MessageRef
+	for _, m := range msgs {
+		if util.NormalizeSender(m.From) == email {
+			own = append(own, m)
+		}
+	}
+	return SortGroups(AggregateBySenderSubject(own)), nil
+}
@@ -0,0 +1,38 @@
+package gmail
+
+import (
+	"context"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestSenderProfile(t *testing.T) {
+	st := &messagesStore{msgs: []model.MessageRef{
+		{ID: "1", From: "Shop <deals@shop.example>", Subject: "Sale", DateRFC3339: "2026-01-05T10:00:00Z", SizeBytes: 100, LabelIDs: []string{"UNREAD"}},
+		{ID: "2", From: "deals@shop.example", Subject: "Sale", DateRFC3339: "2026-03-01T10:00:00Z", SizeBytes: 200},
+		{ID: "3", From: "Shop <deals@shop.example>", Subject: "Your order", DateRFC3339: "2025-12-24T10:00:00Z", SizeBytes: 50,
+			ListUnsubscribe: "<mailto:leave@shop.example>"},
+		{ID: "4", From: "Shop <deals@shop.example>", Subject: "New arrivals", DateRFC3339: "2026-02-01T10:00:00Z",
+			ListUnsubscribe: "<https://shop.example/unsubscribe>"},
+		{ID: "5", From: "friend@mail.example", Subject: "Sale", DateRFC3339: "2026-04-01T10:00:00Z"},
+	}}
+	groups, err := LoadSenderGroups(context.Background(), st, "deals@shop.example")
+	if err != nil || len(groups) != 3 || groups[0].Subject != "Sale" {
+		t.Fatalf("LoadSenderGroups = %+v, %v; want 3 groups, Sale first", groups, err)
+	}
+	groups[1].Status = model.SenderProtected
+	p := NewSenderProfile("deals@shop.example", groups)
+	if p.Count != 4 || p.Unread != 1 || p.Size != 350 || p.DisplayName != "Shop" {
+		t.Errorf("profile totals = %d messages, %d unread, %d bytes, name %q", p.Count, p.Unread, p.Size, p.DisplayName)
+	}
+	if p.FirstDate != "2025-12-24T10:00:00Z" || p.LastDate != "2026-03-01T10:00:00Z" {
+		t.Errorf("profile dates = %s..%s", p.FirstDate, p.LastDate)
+	}
+	if p.Unsubscribe == nil || p.Unsubscribe.UnsubscribeURL != "https://shop.example/unsubscribe" {
+		t.Errorf("profile unsubscribe = %+v; want the HTTP link over the mailto: one", p.Unsubscribe)
+	}
+	if p.Status != model.SenderProtected {
+		t.Errorf("profile status = %q", p.Status)
+	}
+}
@@ -0,0 +1,95 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/oauth2"
+)
+
+// proxyTransport carries Gmail API requests, sign-ins and one-click
+// unsubscribe POSTs, through the proxy SetProxy chose.
+var proxyTransport = newProxyTransport(environmentProxy())
+
+// apiClient is the base of the OAuth clients that talk to Google.
+var apiClient = &http.Client{Transport: MeterTransport(proxyTransport)}
+
+// SetProxy routes all traffic to Google and to unsubscribe links through
+// the proxy at raw, an http, https, socks5 or socks5h URL. An empty raw
+// falls back to HTTPS_PROXY, HTTP_PROXY and ALL_PROXY, minus NO_PROXY.
+// Call it before the first request.
+func SetProxy(raw string) error {
+	proxy := environmentProxy()
+	if raw != "" {
+		u, err := ParseProxy(raw)
+		if err != nil {
+			return err
+		}
+		proxy = func(*http.Request) (*url.URL, error) { return u, nil }
+	}
+	proxyTransport.Proxy = proxy
+	return nil
+}
+
+// ParseProxy checks that raw is a proxy URL net/http can dial.
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %q: %w", raw, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy %q: want an http, https, socks5 or socks5h URL", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %q: no host", raw)
+	}
+	return u, nil
+}
+
+// environmentProxy reads the proxy variables like http.ProxyFromEnvironment,
+// with ALL_PROXY, as curl knows it, for the schemes that have none of
+// their own.
+func environmentProxy() func(*http.Request) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	if all := getenvEither("ALL_PROXY", "all_proxy"); all != "" {
+		if cfg.HTTPSProxy == "" {
+			cfg.HTTPSProxy = all
+		}
+		if cfg.HTTPProxy == "" {
+			cfg.HTTPProxy = all
+		}
+	}
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) { return proxy(req.URL) }
+}
+
+func getenvEither(upper, lower string) string {
+	if v := os.Getenv(upper); v != "" {
+		return v
+	}
+	return os.Getenv(lower)
+}
+
+// newProxyTransport is http.DefaultTransport with proxy in place of
+// http.ProxyFromEnvironment.
+func newProxyTransport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxy
+	return t
+}
+
+// withProxy makes the OAuth calls made with ctx, and the clients built
+// from it, use the proxy.
+func withProxy(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, apiClient)
+}
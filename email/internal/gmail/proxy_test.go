@@ -0,0 +1,87 @@
+package gmail
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseProxy(t *testing.T) {
+	for _, raw := range []string{"http://proxy:3128", "https://proxy.corp", "socks5://127.0.0.1:1080", "SOCKS5H://user:pw@proxy:1080"} {
+		if _, err := ParseProxy(raw); err != nil {
+			t.Errorf("ParseProxy(%q): %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"proxy:3128", "ftp://proxy", "http://", "://"} {
+		if _, err := ParseProxy(raw); err == nil {
+			t.Errorf("ParseProxy(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestEnvironmentProxy(t *testing.T) {
+	for _, v := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(v, "")
+	}
+	t.Setenv("ALL_PROXY", "socks5://127.0.0.1:1080")
+	t.Setenv("NO_PROXY", "example.org")
+
+	tests := []struct {
+		url, want string
+	}{
+		{"https://gmail.googleapis.com/gmail/v1/users/me/profile", "socks5://127.0.0.1:1080"},
+		{"http://news.example.com/unsubscribe", "socks5://127.0.0.1:1080"},
+		{"https://example.org/unsubscribe", ""},
+	}
+	proxy := environmentProxy()
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, tc.url, nil)
+		u, err := proxy(req)
+		if err != nil {
+			t.Fatalf("proxy(%s): %v", tc.url, err)
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != tc.want {
+			t.Errorf("proxy(%s) = %q; want %q", tc.url, got, tc.want)
+		}
+	}
+
+	// HTTPS_PROXY wins over ALL_PROXY.
+	t.Setenv("HTTPS_PROXY", "http://proxy.corp:3128")
+	u, _ := environmentProxy()(httptest.NewRequest(http.MethodGet, "https://oauth2.googleapis.com/token", nil))
+	if u == nil || u.String() != "http://proxy.corp:3128" {
+		t.Errorf("with HTTPS_PROXY, proxy = %v", u)
+	}
+}
+
+func TestSetProxyRoutesUnsubscribe(t *testing.T) {
+	connects := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			connects <- r.Host
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	oldProxy := proxyTransport.Proxy
+	t.Cleanup(func() { proxyTransport.Proxy = oldProxy })
+	if err := SetProxy(proxy.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := OneClickUnsubscribe(context.Background(), "https://news.example.com/unsub?u=1"); err == nil {
+		t.Fatal("unsubscribe through a failing proxy succeeded")
+	}
+	select {
+	case host := <-connects:
+		if host != "news.example.com:443" {
+			t.Errorf("proxy was asked to connect to %s", host)
+		}
+	default:
+		t.Error("the unsubscribe POST did not go through the proxy")
+	}
+}
@@ -0,0 +1,257 @@
+package gmail
+
+import (
+	"bufio"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quotaUnits is what each Gmail API method costs against the per-user
+// quota of 250 units a second, from Google's usage limits page. Methods
+// missing here are counted with no units.
+var quotaUnits = map[string]int{
+	"getProfile":               1,
+	"watch":                    100,
+	"stop":                     50,
+	"history.list":             2,
+	"labels.list":              1,
+	"labels.get":               1,
+	"labels.create":            5,
+	"labels.update":            5,
+	"labels.patch":             5,
+	"labels.delete":            5,
+	"messages.list":            5,
+	"messages.get":             5,
+	"messages.insert":          25,
+	"messages.import":          25,
+	"messages.send":            100,
+	"messages.modify":          5,
+	"messages.batchModify":     50,
+	"messages.batchDelete":     50,
+	"messages.trash":           5,
+	"messages.untrash":         5,
+	"messages.delete":          10,
+	"messages.attachments.get": 5,
+	"threads.list":             10,
+	"threads.get":              10,
+	"drafts.list":              5,
+	"drafts.get":               5,
+	"drafts.create":            10,
+	"drafts.update":            15,
+	"drafts.send":              100,
+	"drafts.delete":            10,
+	"settings.filters.list":    1,
+	"settings.filters.create":  5,
+	"settings.filters.delete":  5,
+}
+
+// OperationUsage is how often one Gmail API method was called, and the
+// quota units those calls cost.
+type OperationUsage struct {
+	Operation string
+	Calls     int
+	Units     int
+}
+
+// QuotaUsage is the Gmail API use of this process.
+type QuotaUsage struct {
+	// Operations is sorted by units, then calls, highest first.
+	Operations []OperationUsage
+	Calls      int
+	Units      int
+	// PeakUnits is the most units spent within one second, to set against
+	// the per-user limit of 250.
+	PeakUnits int
+	// Throttled counts the requests Gmail turned down for going over a
+	// rate limit.
+	Throttled int
+}
+
+// quotaMeter counts the requests MeterTransport sees.
+type quotaMeter struct {
+	mu        sync.Mutex
+	ops       map[string]*OperationUsage
+	second    time.Time
+	inSecond  int
+	peak      int
+	throttled int
+}
+
+var meter = &quotaMeter{ops: make(map[string]*OperationUsage)}
+
+func (q *quotaMeter) record(op string, now time.Time) {
+	units := quotaUnits[op]
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.ops[op]
+	if u == nil {
+		u = &OperationUsage{Operation: op}
+		q.ops[op] = u
+	}
+	u.Calls++
+	u.Units += units
+	if s := now.Truncate(time.Second); !s.Equal(q.second) {
+		q.second, q.inSecond = s, 0
+	}
+	q.inSecond += units
+	q.peak = max(q.peak, q.inSecond)
+}
+
+func (q *quotaMeter) throttle() {
+	q.mu.Lock()
+	q.throttled++
+	q.mu.Unlock()
+}
+
+func (q *quotaMeter) usage() QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := QuotaUsage{PeakUnits: q.peak, Throttled: q.throttled}
+	for _, u := range q.ops {
+		out.Operations = append(out.Operations, *u)
+		out.Calls += u.Calls
+		out.Units += u.Units
+	}
+	slices.SortFunc(out.Operations, func(a, b OperationUsage) int {
+		if a.Units != b.Units {
+			return b.Units - a.Units
+		}
+		if a.Calls != b.Calls {
+			return b.Calls - a.Calls
+		}
+		return strings.Compare(a.Operation, b.Operation)
+	})
+	return out
+}
+
+// Usage returns the Gmail API calls made so far by this process.
+func Usage() QuotaUsage { return meter.usage() }
+
+// MeterTransport counts the Gmail API requests sent through base towards
+// Usage. Other requests pass through uncounted.
+func MeterTransport(base http.RoundTripper) http.RoundTripper {
+	return meteredTransport{base}
+}
+
+type meteredTransport struct{ base http.RoundTripper }
+
+func (t meteredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	now := time.Now()
+	if strings.HasSuffix(req.URL.Path, "/batch/gmail/v1") {
+		// Gmail bills a batch as the requests it carries.
+		for _, op := range batchOperations(req) {
+			meter.record(op, now)
+		}
+	} else if op := apiOperation(req.Method, req.URL.Path); op != "" {
+		meter.record(op, now)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// batchOperations names the Gmail API methods the parts of a batch request
+// call, reading a copy of its body.
+func batchOperations(req *http.Request) []string {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	var ops []string
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return ops
+		}
+		inner, err := http.ReadRequest(bufio.NewReader(part))
+		if err != nil {
+			continue
+		}
+		if op := apiOperation(inner.Method, inner.URL.Path); op != "" {
+			ops = append(ops, op)
+		}
+	}
+}
+
+// apiCollections are the path segments of the Gmail API that name
+// resources rather than IDs or custom methods.
+var apiCollections = map[string]bool{
+	"messages": true, "attachments": true, "labels": true, "history": true,
+	"threads": true, "drafts": true, "settings": true, "filters": true,
+}
+
+// apiOperation names the Gmail API method a request calls, as in
+// "messages.get", or returns "" for a path outside the API.
+func apiOperation(method, path string) string {
+	path = strings.TrimPrefix(path, "/upload")
+	rest, ok := strings.CutPrefix(path, "/gmail/v1/users/")
+	if !ok {
+		return ""
+	}
+	segs := strings.Split(rest, "/")[1:] // drop the user
+	if len(segs) == 0 || segs[0] == "" {
+		return ""
+	}
+	var name []string
+	withID, last := false, ""
+	for _, s := range segs {
+		switch {
+		case apiCollections[s]:
+			name = append(name, s)
+			withID, last = false, "collection"
+		case last == "collection" && !withID && !isCustomMethod(s):
+			withID = true
+		default:
+			name = append(name, s)
+			last = "method"
+		}
+	}
+	if len(name) == 1 && name[0] == "profile" {
+		return "getProfile"
+	}
+	if last == "collection" {
+		verb := ""
+		switch {
+		case !withID && method == http.MethodGet:
+			verb = "list"
+		case !withID && method == http.MethodPost && name[len(name)-1] == "messages":
+			verb = "insert"
+		case !withID && method == http.MethodPost:
+			verb = "create"
+		case method == http.MethodGet:
+			verb = "get"
+		case method == http.MethodPut:
+			verb = "update"
+		case method == http.MethodPatch:
+			verb = "patch"
+		case method == http.MethodDelete:
+			verb = "delete"
+		}
+		if verb != "" {
+			name = append(name, verb)
+		}
+	}
+	return strings.Join(name, ".")
+}
+
+// isCustomMethod reports whether s, after a collection, is one of the
+// Gmail API's custom methods rather than a resource ID.
+func isCustomMethod(s string) bool {
+	switch s {
+	case "batchModify", "batchDelete", "import", "send":
+		return true
+	}
+	return false
+}
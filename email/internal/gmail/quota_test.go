@@ -0,0 +1,113 @@
+package gmail
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestAPIOperation(t *testing.T) {
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", "/gmail/v1/users/me/profile", "getProfile"},
+		{"GET", "/gmail/v1/users/me/messages", "messages.list"},
+		{"GET", "/gmail/v1/users/me/messages/18c2f", "messages.get"},
+		{"POST", "/gmail/v1/users/me/messages/batchModify", "messages.batchModify"},
+		{"POST", "/gmail/v1/users/me/messages/18c2f/modify", "messages.modify"},
+		{"POST", "/gmail/v1/users/me/messages/18c2f/trash", "messages.trash"},
+		{"POST", "/gmail/v1/users/me/messages/send", "messages.send"},
+		{"POST", "/upload/gmail/v1/users/me/messages/import", "messages.import"},
+		{"GET", "/gmail/v1/users/me/messages/18c2f/attachments/ANGj", "messages.attachments.get"},
+		{"GET", "/gmail/v1/users/me/history", "history.list"},
+		{"GET", "/gmail/v1/users/me/labels", "labels.list"},
+		{"POST", "/gmail/v1/users/me/labels", "labels.create"},
+		{"POST", "/gmail/v1/users/me/watch", "watch"},
+		{"GET", "/gmail/v1/users/me/settings/filters", "settings.filters.list"},
+		{"POST", "/gmail/v1/users/me/drafts/send", "drafts.send"},
+		{"POST", "/token", ""},
+		{"GET", "/gmail/v1/users/me", ""},
+	}
+	for _, tc := range tests {
+		if got := apiOperation(tc.method, tc.path); got != tc.want {
+			t.Errorf("apiOperation(%s %s) = %q; want %q", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestQuotaMeter(t *testing.T) {
+	q := &quotaMeter{ops: make(map[string]*OperationUsage)}
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	q.record("messages.list", start)
+	q.record("messages.get", start.Add(100*time.Millisecond))
+	q.record("messages.get", start.Add(200*time.Millisecond))
+	q.record("messages.batchModify", start.Add(1500*time.Millisecond))
+	q.record("unknown.method", start.Add(1600*time.Millisecond))
+	q.throttle()
+
+	u := q.usage()
+	if u.Calls != 5 || u.Units != 65 {
+		t.Errorf("Calls, Units = %d, %d; want 5, 65", u.Calls, u.Units)
+	}
+	if u.PeakUnits != 50 {
+		t.Errorf("PeakUnits = %d; want 50", u.PeakUnits)
+	}
+	if u.Throttled != 1 {
+		t.Errorf("Throttled = %d; want 1", u.Throttled)
+	}
+	want := []OperationUsage{
+		{"messages.batchModify", 1, 50},
+		{"messages.get", 2, 10},
+		{"messages.list", 1, 5},
+		{"unknown.method", 1, 0},
+	}
+	if len(u.Operations) != len(want) {
+		t.Fatalf("Operations = %+v; want %+v", u.Operations, want)
+	}
+	for i := range want {
+		if u.Operations[i] != want[i] {
+			t.Errorf("Operations[%d] = %+v; want %+v", i, u.Operations[i], want[i])
+		}
+	}
+}
+
+func TestMeterTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := &http.Client{Transport: MeterTransport(http.DefaultTransport)}
+
+	before := Usage()
+	for _, path := range []string{"/gmail/v1/users/me/messages", "/gmail/v1/users/me/messages/abc", "/unsubscribe"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	after := Usage()
+	if calls, units := after.Calls-before.Calls, after.Units-before.Units; calls != 2 || units != 10 {
+		t.Errorf("counted %d calls and %d units; want 2 and 10", calls, units)
+	}
+
+	// A batch counts as the gets it carries.
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, id := range []string{"a", "b", "c"} {
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/http"}})
+		fmt.Fprintf(pw, "GET /gmail/v1/users/me/messages/%s?format=metadata HTTP/1.1\r\n\r\n", id)
+	}
+	mw.Close()
+	resp, err := client.Post(srv.URL+"/batch/gmail/v1", "multipart/mixed; boundary="+mw.Boundary(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls, units := Usage().Calls-after.Calls, Usage().Units-after.Units; calls != 3 || units != 15 {
+		t.Errorf("counted %d calls and %d units for a batch; want 3 and 15", calls, units)
+	}
+}
@@ -0,0 +1,164 @@
+package gmail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"chuckterm/internal/model"
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// maxRawDepth bounds how deeply multipart messages may nest.
+const maxRawDepth = 16
+
+// BodyFromRaw reads the body, attachments and invitations of a raw RFC 822
+// message, as GetMessageBody does for Gmail, for mail from other providers.
+func BodyFromRaw(ctx context.Context, raw []byte) (model.MessageBody, error) {
+	payload, err := payloadFromRaw(raw)
+	if err != nil {
+		return model.MessageBody{}, err
+	}
+	msg := &gmailv1.Message{Payload: payload}
+	return model.MessageBody{
+		Text:        bodyText(msg),
+		Attachments: extractAttachments(payload),
+		Invites:     extractInvites(ctx, nil, "", msg),
+	}, nil
+}
+
+// SaveAttachmentFromRaw saves an attachment BodyFromRaw listed into dir,
+// like DownloadAttachment.
+func SaveAttachmentFromRaw(raw []byte, att model.Attachment, dir string) (string, error) {
+	payload, err := payloadFromRaw(raw)
+	if err != nil {
+		return "", err
+	}
+	part := findPart(payload, att.PartID)
+	if part == nil || part.Body == nil {
+		return "", fmt.Errorf("attachment %s not found in message", att.Filename)
+	}
+	data, err := base64.URLEncoding.DecodeString(part.Body.Data)
+	if err != nil {
+		return "", fmt.Errorf("decode attachment %s: %w", att.Filename, err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return writeUnique(dir, safeFilename(att.Filename), data)
+}
+
+// payloadFromRaw parses a raw message into the part tree Gmail returns in
+// "full" format: bodies with their transfer encoding undone, then
+// base64url-encoded, and part IDs "" for the message, "0", "1" for its
+// parts and "1.0" for theirs.
+func payloadFromRaw(raw []byte) (*gmailv1.MessagePart, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse message: %w", err)
+	}
+	return rawPart(textproto.MIMEHeader(msg.Header), msg.Body, "", 0)
+}
+
+func rawPart(h textproto.MIMEHeader, body io.Reader, id string, depth int) (*gmailv1.MessagePart, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{"charset": "us-ascii"}
+	}
+	part := &gmailv1.MessagePart{PartId: id, MimeType: mediaType, Filename: rawFilename(h, params)}
+	for name, values := range h {
+		if strings.EqualFold(name, "Content-Transfer-Encoding") {
+			// Undone below, so decodePartBody must not undo it again.
+			continue
+		}
+		for _, v := range values {
+			part.Headers = append(part.Headers, &gmailv1.MessagePartHeader{Name: name, Value: v})
+		}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" && depth < maxRawDepth {
+		mr := multipart.NewReader(body, params["boundary"])
+		for i := 0; ; i++ {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				// Keep the parts read before a malformed one.
+				break
+			}
+			subID := strconv.Itoa(i)
+			if id != "" {
+				subID = id + "." + subID
+			}
+			sub, err := rawPart(p.Header, p, subID, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			part.Parts = append(part.Parts, sub)
+		}
+		part.Body = &gmailv1.MessagePartBody{}
+		return part, nil
+	}
+
+	var r io.Reader = body
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil && len(data) == 0 {
+		return nil, fmt.Errorf("read part %q: %w", id, err)
+	}
+	if strings.HasPrefix(mediaType, "text/") && part.Filename == "" {
+		data = toUTF8(data, params["charset"])
+	}
+	part.Body = &gmailv1.MessagePartBody{Data: base64.URLEncoding.EncodeToString(data), Size: int64(len(data))}
+	return part, nil
+}
+
+// rawFilename is the attachment name of a part, from Content-Disposition or
+// else the Content-Type name parameter.
+func rawFilename(h textproto.MIMEHeader, typeParams map[string]string) string {
+	name := typeParams["name"]
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+	dec := mime.WordDecoder{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}}
+	if decoded, err := dec.DecodeHeader(name); err == nil {
+		return decoded
+	}
+	return name
+}
+
+// toUTF8 converts Latin-1 text, the most common charset besides UTF-8 and
+// ASCII, and leaves any other as it is.
+func toUTF8(data []byte, charset string) []byte {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		if !bytes.ContainsFunc(data, func(r rune) bool { return r >= utf8.RuneSelf }) {
+			return data
+		}
+		out := make([]rune, len(data))
+		for i, b := range data {
+			out[i] = rune(b)
+		}
+		return []byte(string(out))
+	}
+	return data
+}
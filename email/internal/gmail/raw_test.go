@@ -0,0 +1,69 @@
+package gmail
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+const rawMultipart = "From: shop@example.com\r\n" +
+	"Subject: Receipt\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Thanks for visiting the caf=E9, see you so=\r\n" +
+	"on.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Thanks for visiting</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"receipt.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"receipt.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQKJeLjz9M=\r\n" +
+	"--outer--\r\n"
+
+func TestBodyFromRaw(t *testing.T) {
+	body, err := BodyFromRaw(context.Background(), []byte(rawMultipart))
+	if err != nil {
+		t.Fatalf("BodyFromRaw: %v", err)
+	}
+	if !strings.Contains(body.Text, "Thanks for visiting the café, see you soon.") {
+		t.Errorf("Text = %q", body.Text)
+	}
+	if len(body.Attachments) != 1 || body.Attachments[0].Filename != "receipt.pdf" {
+		t.Fatalf("Attachments = %+v", body.Attachments)
+	}
+
+	dir := t.TempDir()
+	path, err := SaveAttachmentFromRaw([]byte(rawMultipart), body.Attachments[0], dir)
+	if err != nil {
+		t.Fatalf("SaveAttachmentFromRaw: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "%PDF-1.4\n%\xe2\xe3\xcf\xd3" {
+		t.Errorf("saved %q, %v", data, err)
+	}
+}
+
+func TestBodyFromRawSinglePart(t *testing.T) {
+	raw := "From: a@example.com\r\nContent-Type: text/html\r\nContent-Transfer-Encoding: base64\r\n\r\nPGI+SGk8L2I+IHRoZXJl\r\n"
+	body, err := BodyFromRaw(context.Background(), []byte(raw))
+	if err != nil {
+		t.Fatalf("BodyFromRaw: %v", err)
+	}
+	if !strings.Contains(body.Text, "Hi there") || strings.Contains(body.Text, "<b>") {
+		t.Errorf("Text = %q", body.Text)
+	}
+}
@@ -0,0 +1,20 @@
+package gmail
+
+import "context"
+
+type readOnlyKey struct{}
+
+// WithReadOnly returns a context in read-only mode. Under it NewService and
+// NewServiceInteractive ask only for the gmail.readonly scope, with a token
+// kept apart in token-readonly.json as NewReadonlyService does, and every
+// action that changes mail is skipped as under WithDryRun, so neither
+// chuckterm nor Gmail lets anything change.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// IsReadOnly reports whether ctx came from WithReadOnly.
+func IsReadOnly(ctx context.Context) bool {
+	on, _ := ctx.Value(readOnlyKey{}).(bool)
+	return on
+}
@@ -0,0 +1,100 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"golang.org/x/oauth2"
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// tokenSwitch is the token source of a service built by newService. When
+// the user signs in again, Reauthenticate swaps the source behind it, so the
+// service and everything holding it keep working with the new token.
+type tokenSwitch struct {
+	cfg     *oauth2.Config
+	tokFile string
+
+	mu  sync.Mutex
+	src oauth2.TokenSource
+}
+
+func (s *tokenSwitch) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	src := s.src
+	s.mu.Unlock()
+	return src.Token()
+}
+
+// tokenSwitches maps the services newService built to their token source.
+var tokenSwitches sync.Map // *gmailv1.Service -> *tokenSwitch
+
+// newSwitchedService returns a service authorised with tok whose token can
+// later be replaced by Reauthenticate.
+func newSwitchedService(ctx context.Context, cfg *oauth2.Config, tokFile string, tok *oauth2.Token) (*gmailv1.Service, error) {
+	sw := &tokenSwitch{cfg: cfg, tokFile: tokFile, src: cfg.TokenSource(ctx, tok)}
+	hc := oauth2.NewClient(ctx, sw)
+	svc, err := gmailv1.NewService(ctx, option.WithHTTPClient(hc))
+	if err != nil {
+		return nil, fmt.Errorf("create gmail service: %w", err)
+	}
+	tokenSwitches.Store(svc, sw)
+	EnableBatch(svc, hc)
+	return svc, nil
+}
+
+// IsAuthError reports whether err means the sign-in behind a request has
+// expired or been revoked, or was granted before chuckterm asked for the
+// scope the request needs, so the user has to sign in again.
+func IsAuthError(err error) bool {
+	var rerr *oauth2.RetrieveError
+	if errors.As(err, &rerr) {
+		return true
+	}
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	if gerr.Code == 403 {
+		for _, e := range gerr.Errors {
+			if e.Reason == "insufficientPermissions" {
+				return true
+			}
+		}
+	}
+	return gerr.Code == 401
+}
+
+// Reauthenticate runs the sign-in flow again for svc, which must come from
+// NewService or NewServiceInteractive, and switches svc to the new token.
+// The flow talks to the UI over uiEvents and userResponses as
+// NewServiceInteractive does.
+func Reauthenticate(ctx context.Context, svc *gmailv1.Service, uiEvents chan<- interface{}, userResponses <-chan string) error {
+	v, ok := tokenSwitches.Load(svc)
+	if !ok {
+		return errors.New("this session cannot sign in again")
+	}
+	sw := v.(*tokenSwitch)
+	ctx = withProxy(ctx)
+	// The cached token is the one that stopped working.
+	os.Remove(sw.tokFile)
+	tok, err := getTokenFromWeb(ctx, sw.cfg, uiEvents, userResponses)
+	if err != nil {
+		slog.Error("sign-in failed", "err", err)
+		return err
+	}
+	slog.Info("signed in again")
+	if err := saveToken(sw.tokFile, tok); err != nil {
+		return err
+	}
+	sw.mu.Lock()
+	sw.src = sw.cfg.TokenSource(ctx, tok)
+	sw.mu.Unlock()
+	return nil
+}
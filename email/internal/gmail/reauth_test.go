@@ -0,0 +1,46 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsAuthError(t *testing.T) {
+	revoked := &oauth2.RetrieveError{ErrorCode: "invalid_grant"}
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{revoked, true},
+		// The HTTP client wraps token errors in a *url.Error.
+		{fmt.Errorf("list messages: %w", &url.Error{Op: "Get", URL: "https://gmail.googleapis.com", Err: revoked}), true},
+		{&googleapi.Error{Code: 401}, true},
+		{fmt.Errorf("archive messages 0-9: %w", &googleapi.Error{Code: 401}), true},
+		{&googleapi.Error{Code: 403}, false},
+		// A token from before a scope was added, as for filters.
+		{fmt.Errorf("create filter: %w", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}), true},
+		{&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, false},
+		{&googleapi.Error{Code: 404}, false},
+		{errors.New("boom"), false},
+		{nil, false},
+	}
+	for _, tc := range tests {
+		if got := IsAuthError(tc.err); got != tc.want {
+			t.Errorf("IsAuthError(%v) = %v; want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestReauthenticateNeedsSwitchedService(t *testing.T) {
+	err := Reauthenticate(context.Background(), &gmailv1.Service{}, nil, nil)
+	if err == nil {
+		t.Fatal("Reauthenticate of a service newService did not build succeeded")
+	}
+}
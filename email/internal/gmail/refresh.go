@@ -0,0 +1,88 @@
+package gmail
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// metaRefreshMetadata is set when a cache upgrade adds message fields the
+// cached rows lack, so the next sync fetches their metadata again: "*"
+// until RefreshMetadata starts, then the last message ID it refreshed, so
+// an interrupted refresh resumes. The stores set it by this name when they
+// upgrade a cache that holds messages.
+const metaRefreshMetadata = "refresh_metadata"
+
+// refreshChunk is how many messages RefreshMetadata fetches between
+// checkpoints.
+const refreshChunk = 500
+
+// MetadataRefreshPending reports whether the cached messages are due to
+// have their metadata fetched again after a cache upgrade.
+func MetadataRefreshPending(ctx context.Context, store MessageStore) (bool, error) {
+	v, err := store.GetMetadata(ctx, metaRefreshMetadata)
+	return v != "", err
+}
+
+// MetadataRefreshed records that every cached message has its metadata
+// fetched since the last cache upgrade.
+func MetadataRefreshed(ctx context.Context, store MessageStore) error {
+	return store.SetMetadata(ctx, metaRefreshMetadata, "")
+}
+
+// RefreshMetadata fetches the metadata of every cached message again when a
+// cache upgrade asked for it, and is a no-op otherwise. It goes through the
+// messages in ID order and checkpoints after each chunk. Messages whose
+// fetch fails keep what was cached; the first such error is returned once
+// the rest are refreshed.
+func RefreshMetadata(ctx context.Context, svc *gmailv1.Service, store MessageStore, opts SyncOptions, progress func(SyncProgress)) (err error) {
+	after, err := store.GetMetadata(ctx, metaRefreshMetadata)
+	if err != nil || after == "" {
+		return err
+	}
+	cached, err := store.LoadAllMessages(ctx)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for _, m := range cached {
+		if !model.IsLocalID(m.ID) && (after == "*" || m.ID > after) {
+			ids = append(ids, m.ID)
+		}
+	}
+	slices.Sort(ids)
+	slog.Info("metadata refresh start", "messages", len(ids))
+	defer logSync("metadata refresh", time.Now(), Usage(), &err)
+
+	var fetchErr error
+	for start := 0; start < len(ids); start += refreshChunk {
+		if progress != nil {
+			progress(SyncProgress{Phase: "metadata", Total: len(ids), Done: start})
+		}
+		chunk := ids[start:min(start+refreshChunk, len(ids))]
+		msgs, err := fetchMetadataBatch(ctx, svc, chunk, opts.workers(16), nil)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// The checkpoint stays before this chunk, which is fetched
+			// again on resuming.
+			return ctxErr
+		}
+		if err != nil && fetchErr == nil {
+			fetchErr = err
+		}
+		if err := upsertBatches(ctx, store, opts, msgs, nil); err != nil {
+			return err
+		}
+		if err := store.SetMetadata(ctx, metaRefreshMetadata, chunk[len(chunk)-1]); err != nil {
+			return err
+		}
+	}
+	if err := MetadataRefreshed(ctx, store); err != nil {
+		return err
+	}
+	return fetchErr
+}
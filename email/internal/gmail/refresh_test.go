@@ -0,0 +1,72 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestRefreshMetadata(t *testing.T) {
+	var mu sync.Mutex
+	var fetched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		mu.Lock()
+		fetched = append(fetched, id)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q,"labelIds":["INBOX"],"sizeEstimate":2048,"payload":{"headers":[
+			{"name":"From","value":"News <news@x.example>"},
+			{"name":"Precedence","value":"bulk"}]}}`, id)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		flag, want string
+	}{
+		{"", ""},
+		{"*", "a,b,c"},
+		{"a", "b,c"}, // resuming after a
+	} {
+		fetched = nil
+		st := &cacheStore{scanStore: scanStore{
+			metadataStore: metadataStore{meta: map[string]string{metaRefreshMetadata: tc.flag}},
+			msgs: map[string]model.MessageRef{
+				"a":     {ID: "a", From: "news@x.example"},
+				"b":     {ID: "b", From: "news@x.example"},
+				"c":     {ID: "c", From: "news@x.example"},
+				"eml:1": {ID: "eml:1"},
+			},
+		}}
+		if err := RefreshMetadata(ctx, svc, st, SyncOptions{Workers: 1}, nil); err != nil {
+			t.Fatalf("flag %q: RefreshMetadata: %v", tc.flag, err)
+		}
+		slices.Sort(fetched)
+		if got := strings.Join(fetched, ","); got != tc.want {
+			t.Errorf("flag %q: fetched %q; want %q", tc.flag, got, tc.want)
+		}
+		if st.meta[metaRefreshMetadata] != "" {
+			t.Errorf("flag %q: still set to %q", tc.flag, st.meta[metaRefreshMetadata])
+		}
+		if tc.want != "" {
+			if m := st.msgs["c"]; m.SizeBytes != 2048 || m.Precedence != "bulk" || m.FromName != "News" {
+				t.Errorf("flag %q: c refreshed to %+v", tc.flag, m)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrHistoryExpired is returned by SyncSinceHistory when Gmail no longer
+// keeps the history since the cache's historyId, which happens after
+// about a week without a sync. Rescan recovers from it.
+var ErrHistoryExpired = errors.New("gmail history since the last sync has expired")
+
+// metaRescan is set while a FullScan rebuilds a cache it keeps, so the scan
+// reconciles the cache when it ends, even after being resumed.
+const metaRescan = "rescan_pending"
+
+// isHistoryExpired reports whether Gmail turned down a history request
+// because its start historyId is too old.
+func isHistoryExpired(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	if gerr.Code == 404 {
+		return true
+	}
+	for _, e := range gerr.Errors {
+		if e.Reason == "failedPrecondition" {
+			return true
+		}
+	}
+	return false
+}
+
+// Rescan brings a cache whose history expired up to date. It runs a
+// FullScan that keeps the cached messages and fetches only the missing
+// ones, then drops the cached messages Gmail no longer lists in the scope
+// and refreshes which of the rest are unread. Like FullScan, it checkpoints
+// as it goes, and the next sync resumes an interrupted rescan.
+func Rescan(ctx context.Context, svc *gmailv1.Service, store MessageStore, opts SyncOptions, progress func(SyncProgress)) error {
+	slog.Warn("history expired; rescanning", "label", opts.Label)
+	hid, err := currentHistoryID(ctx, svc)
+	if err != nil {
+		return fmt.Errorf("get current historyId: %w", err)
+	}
+	// With a scan historyId set, FullScan resumes: it skips cached messages
+	// and keeps the scan window.
+	for _, kv := range [][2]string{{metaRescan, "1"}, {metaScanHistoryID, hid}, {metaScanPageToken, ""}} {
+		if err := store.SetMetadata(ctx, kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	if err := store.SetLastHistoryID(ctx, ""); err != nil {
+		return err
+	}
+	return FullScan(ctx, svc, store, opts, progress)
+}
+
+// finishRescan reconciles the cache at the end of a FullScan started by
+// Rescan, which lists messages with the search q, and is a no-op after any
+// other scan.
+func finishRescan(ctx context.Context, svc *gmailv1.Service, store MessageStore, opts SyncOptions, q string) error {
+	pending, err := store.GetMetadata(ctx, metaRescan)
+	if err != nil || pending == "" {
+		return err
+	}
+	listed, err := listIDs(ctx, listCall(svc, opts, q))
+	if err != nil {
+		return err
+	}
+	unread, err := listIDs(ctx, listCall(svc, opts, strings.TrimSpace(q+" is:unread")))
+	if err != nil {
+		return err
+	}
+	cached, err := store.LoadAllMessages(ctx)
+	if err != nil {
+		return err
+	}
+	var gone []string
+	labels := make(map[string][]string)
+	for _, m := range cached {
+		switch {
+		case model.IsLocalID(m.ID):
+		case !listed[m.ID]:
+			gone = append(gone, m.ID)
+		case m.Unread() && !unread[m.ID]:
+			labels[m.ID] = slices.DeleteFunc(slices.Clone(m.LabelIDs), func(l string) bool { return l == "UNREAD" })
+		case !m.Unread() && unread[m.ID]:
+			labels[m.ID] = append(slices.Clone(m.LabelIDs), "UNREAD")
+		}
+	}
+	if len(gone) > 0 {
+		if err := store.DeleteMessages(ctx, gone); err != nil {
+			return err
+		}
+	}
+	if ls, ok := store.(LabelStore); ok && len(labels) > 0 {
+		if err := ls.UpdateLabels(ctx, labels); err != nil {
+			return err
+		}
+	}
+	slog.Info("rescan reconciled", "dropped", len(gone), "relabeled", len(labels))
+	return store.SetMetadata(ctx, metaRescan, "")
+}
+
+// listIDs returns the IDs of every message list lists.
+func listIDs(ctx context.Context, list *gmailv1.UsersMessagesListCall) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	pageToken := ""
+	for {
+		resp, err := listPage(ctx, list, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("list messages: %w", err)
+		}
+		for _, m := range resp.Messages {
+			ids[m.Id] = true
+		}
+		if resp.NextPageToken == "" {
+			return ids, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
@@ -0,0 +1,109 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// cacheStore adds what a sync needs to scanStore.
+type cacheStore struct {
+	scanStore
+	historyID string
+}
+
+func (s *cacheStore) GetLastHistoryID(context.Context) (string, error) { return s.historyID, nil }
+
+func (s *cacheStore) SetLastHistoryID(_ context.Context, id string) error {
+	s.historyID = id
+	return nil
+}
+
+func (s *cacheStore) CountMessages(context.Context) (int, error) { return len(s.msgs), nil }
+
+func (s *cacheStore) LoadAllMessages(context.Context) ([]model.MessageRef, error) {
+	var out []model.MessageRef
+	for _, m := range s.msgs {
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func (s *cacheStore) DeleteMessages(_ context.Context, ids []string) error {
+	for _, id := range ids {
+		delete(s.msgs, id)
+	}
+	return nil
+}
+
+func (s *cacheStore) UpdateLabels(_ context.Context, labels map[string][]string) error {
+	for id, l := range labels {
+		m := s.msgs[id]
+		m.LabelIDs = l
+		s.msgs[id] = m
+	}
+	return nil
+}
+
+func TestRescanAfterExpiredHistory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch p := r.URL.Path; {
+		case p == "/gmail/v1/users/me/history":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":404,"message":"Requested entity was not found.","errors":[{"reason":"notFound"}]}}`)
+		case p == "/gmail/v1/users/me/profile":
+			fmt.Fprint(w, `{"historyId":"900"}`)
+		case p == "/gmail/v1/users/me/messages" && strings.Contains(r.URL.Query().Get("q"), "is:unread"):
+			fmt.Fprint(w, `{"messages":[{"id":"kept"}]}`)
+		case p == "/gmail/v1/users/me/messages":
+			fmt.Fprint(w, `{"messages":[{"id":"kept"},{"id":"new"}]}`)
+		default:
+			id := p[strings.LastIndex(p, "/")+1:]
+			fmt.Fprintf(w, `{"id":%q,"labelIds":["INBOX","UNREAD"],"payload":{"headers":[{"name":"From","value":"a@b.example"}]}}`, id)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &cacheStore{scanStore: scanStore{
+		metadataStore: metadataStore{meta: map[string]string{}},
+		msgs: map[string]model.MessageRef{
+			"kept":  {ID: "kept", LabelIDs: []string{"INBOX"}},
+			"gone":  {ID: "gone", LabelIDs: []string{"INBOX"}},
+			"eml:1": {ID: "eml:1"},
+		},
+	}, historyID: "100"}
+
+	err = SyncSinceHistory(ctx, svc, st, st.historyID, SyncOptions{}, nil)
+	if !errors.Is(err, ErrHistoryExpired) {
+		t.Fatalf("SyncSinceHistory = %v; want ErrHistoryExpired", err)
+	}
+	if err := Rescan(ctx, svc, st, SyncOptions{}, nil); err != nil {
+		t.Fatalf("Rescan: %v", err)
+	}
+	ids := slices.Sorted(maps.Keys(st.msgs))
+	if strings.Join(ids, ",") != "eml:1,kept,new" {
+		t.Errorf("cached after rescan: %v", ids)
+	}
+	if !st.msgs["kept"].Unread() {
+		t.Error("kept was not marked unread")
+	}
+	if st.historyID != "900" || st.meta[metaRescan] != "" || st.meta[metaScanHistoryID] != "" {
+		t.Errorf("historyId %q, metadata %v after rescan", st.historyID, st.meta)
+	}
+}
@@ -0,0 +1,198 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+// Retention bounds how much mail the cache keeps. The zero value keeps
+// everything. Pruned messages stay in the mailbox; they only leave the
+// cache, and the groups built from it.
+type Retention struct {
+	// MaxAge, such as "6mo" or "1y", drops cached messages dated longer
+	// ago than that.
+	MaxAge string
+	// MaxBytes drops the oldest cached messages while the store uses more
+	// than this many bytes. Only a SizedStore can be held to it.
+	MaxBytes int64
+}
+
+// Enabled reports whether r bounds anything.
+func (r Retention) Enabled() bool { return r.MaxAge != "" || r.MaxBytes > 0 }
+
+// Validate reports a MaxAge that cannot be read or a negative MaxBytes.
+func (r Retention) Validate() error {
+	if r.MaxBytes < 0 {
+		return errors.New("retention size must not be negative")
+	}
+	if r.MaxAge != "" {
+		if _, _, err := ago(r.MaxAge, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SizedStore is implemented by stores that know how much of their file
+// holds data, so Retention.MaxBytes can be applied.
+type SizedStore interface {
+	UsedBytes(ctx context.Context) (int64, error)
+}
+
+// OldestMessagesStore is implemented by stores that can find their oldest
+// messages without loading every one.
+type OldestMessagesStore interface {
+	// OldestMessages returns up to limit cached messages dated before
+	// before (any date when before is zero), oldest first. Messages without
+	// a date are never returned.
+	OldestMessages(ctx context.Context, before time.Time, limit int) ([]model.MessageRef, error)
+}
+
+// metaRetentionCutoff is the date of the newest message dropped to keep
+// under Retention.MaxBytes; sync leaves older mail out of the cache so it
+// is not fetched again just to be dropped.
+const metaRetentionCutoff = "retention_cutoff"
+
+// pruneBatch is how many messages Prune deletes at a time.
+const pruneBatch = 1000
+
+// RetentionCutoff returns the date before which r keeps no mail, or the
+// zero time if it keeps mail of any age. Providers skip older mail when
+// they sync.
+func RetentionCutoff(ctx context.Context, store MessageStore, r Retention, now time.Time) (time.Time, error) {
+	var cutoff time.Time
+	if r.MaxAge != "" {
+		t, _, err := ago(r.MaxAge, now)
+		if err != nil {
+			return time.Time{}, err
+		}
+		cutoff = t
+	}
+	if r.MaxBytes > 0 {
+		v, err := store.GetMetadata(ctx, metaRetentionCutoff)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(cutoff) {
+			cutoff = t
+		}
+	}
+	return cutoff, nil
+}
+
+// Prune drops the cached messages r does not keep and returns how many it
+// dropped: those older than MaxAge, then the oldest ones until the store
+// uses at most MaxBytes. Cached bodies are left to their own limit.
+func Prune(ctx context.Context, store MessageStore, r Retention) (int, error) {
+	if !r.Enabled() {
+		return 0, nil
+	}
+	dropped := 0
+	cutoff, err := RetentionCutoff(ctx, store, r, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if !cutoff.IsZero() {
+		for {
+			old, err := oldestMessages(ctx, store, cutoff, pruneBatch)
+			if err != nil {
+				return dropped, err
+			}
+			if len(old) == 0 {
+				break
+			}
+			if err := store.DeleteMessages(ctx, messageIDs(old)); err != nil {
+				return dropped, err
+			}
+			dropped += len(old)
+			if len(old) < pruneBatch {
+				break
+			}
+		}
+	}
+
+	ss, ok := store.(SizedStore)
+	if r.MaxBytes <= 0 || !ok {
+		logPrune(dropped, r)
+		return dropped, nil
+	}
+	var newest string
+	prev := int64(-1)
+	for {
+		used, err := ss.UsedBytes(ctx)
+		if err != nil {
+			return dropped, err
+		}
+		// Stop at the cap, or when dropping messages no longer frees
+		// space because the rest is bodies and settings.
+		if used <= r.MaxBytes || used == prev {
+			break
+		}
+		prev = used
+		n, err := store.CountMessages(ctx)
+		if err != nil || n == 0 {
+			return dropped, err
+		}
+		// Drop the share of the messages that the excess is of the whole,
+		// and a little more, so a round or two reaches the cap.
+		want := int(int64(n)*(used-r.MaxBytes)/used) + n/100 + 1
+		old, err := oldestMessages(ctx, store, time.Time{}, want)
+		if err != nil || len(old) == 0 {
+			return dropped, err
+		}
+		if err := store.DeleteMessages(ctx, messageIDs(old)); err != nil {
+			return dropped, err
+		}
+		dropped += len(old)
+		newest = old[len(old)-1].DateRFC3339
+	}
+	if newest != "" {
+		if err := store.SetMetadata(ctx, metaRetentionCutoff, newest); err != nil {
+			return dropped, err
+		}
+	}
+	logPrune(dropped, r)
+	return dropped, nil
+}
+
+func logPrune(dropped int, r Retention) {
+	if dropped > 0 {
+		slog.Info("pruned cache", "messages", dropped, "max_age", r.MaxAge, "max_bytes", r.MaxBytes)
+	}
+}
+
+// oldestMessages is OldestMessagesStore.OldestMessages for any store.
+func oldestMessages(ctx context.Context, store MessageStore, before time.Time, limit int) ([]model.MessageRef, error) {
+	if oms, ok := store.(OldestMessagesStore); ok {
+		return oms.OldestMessages(ctx, before, limit)
+	}
+	all, err := store.LoadAllMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bound := ""
+	if !before.IsZero() {
+		bound = before.UTC().Format(time.RFC3339)
+	}
+	var out []model.MessageRef
+	for _, m := range all {
+		if m.DateRFC3339 != "" && (bound == "" || m.DateRFC3339 < bound) {
+			out = append(out, m)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].DateRFC3339 < out[j].DateRFC3339 })
+	return out[:min(limit, len(out))], nil
+}
+
+func messageIDs(msgs []model.MessageRef) []string {
+	ids := make([]string, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+	}
+	return ids
+}
@@ -0,0 +1,96 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Retry policy for transient Gmail API failures.
+var (
+	retryAttempts  = 6
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 32 * time.Second
+)
+
+// retry runs fn until it succeeds, fails with a non-retryable error, the
+// attempts are exhausted, or ctx is cancelled. Waits use full-jitter
+// exponential backoff.
+func retry[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+	for attempt := 0; ; attempt++ {
+		v, err := fn()
+		if err == nil {
+			return v, nil
+		}
+		if isRateLimited(err) {
+			meter.throttle()
+		}
+		if !isRetryable(err) || attempt+1 >= retryAttempts {
+			if !errors.Is(err, context.Canceled) {
+				slog.Warn("gmail request failed", "attempts", attempt+1, "err", err)
+			}
+			return zero, err
+		}
+		wait := backoff(attempt)
+		slog.Debug("gmail request failed, retrying", "attempt", attempt+1, "wait", wait, "err", err)
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryDo is retry for calls that only return an error.
+func retryDo(ctx context.Context, fn func() error) error {
+	_, err := retry(ctx, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	if d > retryMaxDelay || d <= 0 {
+		d = retryMaxDelay
+	}
+	return rand.N(d) + 1
+}
+
+// isRateLimited reports whether Gmail turned a request down for going over
+// a rate limit.
+func isRateLimited(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	switch gerr.Code {
+	case 429:
+		return true
+	case 403:
+		for _, e := range gerr.Errors {
+			if e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isRetryable reports whether err is a rate-limit, server-side, or network
+// timeout error worth retrying.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code >= 500 || isRateLimited(err)
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
@@ -0,0 +1,59 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&googleapi.Error{Code: 429}, true},
+		{&googleapi.Error{Code: 503}, true},
+		{&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, true},
+		{&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}, false},
+		{&googleapi.Error{Code: 404}, false},
+		{fmt.Errorf("wrapped: %w", &googleapi.Error{Code: 500}), true},
+		{context.Canceled, false},
+		{errors.New("boom"), false},
+	}
+	for _, tc := range tests {
+		if got := isRetryable(tc.err); got != tc.want {
+			t.Errorf("isRetryable(%v) = %v; want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryStopsOnSuccessAndPermanentErrors(t *testing.T) {
+	oldBase := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = oldBase })
+
+	calls := 0
+	v, err := retry(context.Background(), func() (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, &googleapi.Error{Code: 500}
+		}
+		return 42, nil
+	})
+	if err != nil || v != 42 || calls != 3 {
+		t.Fatalf("got v=%d err=%v calls=%d", v, err, calls)
+	}
+
+	calls = 0
+	err = retryDo(context.Background(), func() error {
+		calls++
+		return &googleapi.Error{Code: 400}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("permanent error retried: err=%v calls=%d", err, calls)
+	}
+}
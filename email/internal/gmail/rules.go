@@ -0,0 +1,517 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chuckterm/internal/model"
+	"common/scheduler"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// RuleStore is implemented by stores that persist rules.
+type RuleStore interface {
+	// SaveRule adds a rule with ID 0 and replaces it otherwise, returning its ID.
+	SaveRule(ctx context.Context, r model.Rule) (int64, error)
+	DeleteRule(ctx context.Context, id int64) error
+	LoadRules(ctx context.Context) ([]model.Rule, error)
+	LogRuleRun(ctx context.Context, run model.RuleRun) error
+	// LoadRuleRuns returns up to limit logged runs, newest first.
+	LoadRuleRuns(ctx context.Context, limit int) ([]model.RuleRun, error)
+	// LastRuleRuns returns the latest logged run of each rule by ID.
+	LastRuleRuns(ctx context.Context) (map[int64]model.RuleRun, error)
+}
+
+// ParseRule reads a rule written as conditions, an arrow and an action:
+//
+//	from:@shop.example older:30d -> archive
+//	subject:"(receipt|invoice)" label:INBOX -> label Receipts
+//	from:alerts@bank.example -> read
+//	has:unsubscribe older:90d every:@daily -> archive
+//
+// The conditions are from: (an address or @domain), subject: (a regular
+// expression, matched ignoring case), older: (an age such as 30d, 8w, 6mo
+// or 1y), label: (a label name or ID) and has:unsubscribe (a
+// List-Unsubscribe header); values with spaces are quoted. every: is not a
+// condition but a schedule (see scheduler.ParseSchedule) that makes the rule a
+// cleanup job. The actions are archive, trash, read and label NAME. At least
+// one condition is required, so a rule cannot act on the whole mailbox.
+func ParseRule(s string) (model.Rule, error) {
+	cond, action, ok := strings.Cut(s, "->")
+	if !ok {
+		return model.Rule{}, fmt.Errorf("rule %q has no action (write conditions -> action)", strings.TrimSpace(s))
+	}
+	var r model.Rule
+	terms, err := ruleTerms(cond)
+	if err != nil {
+		return model.Rule{}, err
+	}
+	for _, t := range terms {
+		key, val, _ := strings.Cut(t, ":")
+		if val == "" {
+			return model.Rule{}, fmt.Errorf("condition %q has no value", t)
+		}
+		switch strings.ToLower(key) {
+		case "from":
+			if r.Sender, err = ParseSenderMatch(val); err != nil {
+				return model.Rule{}, err
+			}
+		case "subject":
+			if _, err := regexp.Compile(val); err != nil {
+				return model.Rule{}, fmt.Errorf("subject: %w", err)
+			}
+			r.Subject = val
+		case "older":
+			if _, _, err := ago(strings.ToLower(val), time.Now()); err != nil {
+				return model.Rule{}, err
+			}
+			r.OlderThan = strings.ToLower(val)
+		case "label":
+			r.Label = val
+		case "has":
+			if strings.ToLower(val) != "unsubscribe" {
+				return model.Rule{}, fmt.Errorf("unknown condition %q (has: only takes unsubscribe)", t)
+			}
+			r.Unsubscribe = true
+		case "every":
+			if _, err := scheduler.ParseSchedule(val); err != nil {
+				return model.Rule{}, err
+			}
+			r.Every = val
+		default:
+			return model.Rule{}, fmt.Errorf("unknown condition %q (want from:, subject:, older:, label:, has:unsubscribe or every:)", t)
+		}
+	}
+	if r.Sender == "" && r.Subject == "" && r.OlderThan == "" && r.Label == "" && !r.Unsubscribe {
+		return model.Rule{}, errors.New("a rule needs at least one condition")
+	}
+	words, err := ruleTerms(action)
+	if err != nil {
+		return model.Rule{}, err
+	}
+	if len(words) == 0 {
+		return model.Rule{}, errors.New("a rule needs an action after ->")
+	}
+	r.Action = model.RuleAction(strings.ToLower(words[0]))
+	switch {
+	case r.Action == model.RuleLabel && len(words) == 2:
+		r.AddLabel = words[1]
+	case r.Action == model.RuleLabel:
+		return model.Rule{}, errors.New("label needs one label name, quoted if it has spaces")
+	case r.Action != model.RuleArchive && r.Action != model.RuleTrash && r.Action != model.RuleRead:
+		return model.Rule{}, fmt.Errorf("unknown action %q (want archive, trash, read or label NAME)", words[0])
+	case len(words) > 1:
+		return model.Rule{}, fmt.Errorf("%s takes no argument", r.Action)
+	}
+	return r, nil
+}
+
+// FormatRule writes r the way ParseRule reads it.
+func FormatRule(r model.Rule) string {
+	var terms []string
+	for _, c := range []struct{ key, val string }{
+		{"from", r.Sender}, {"subject", r.Subject}, {"older", r.OlderThan}, {"label", r.Label},
+	} {
+		if c.val != "" {
+			terms = append(terms, c.key+":"+quoteTerm(c.val))
+		}
+	}
+	if r.Unsubscribe {
+		terms = append(terms, "has:unsubscribe")
+	}
+	if r.Every != "" {
+		terms = append(terms, "every:"+quoteTerm(r.Every))
+	}
+	terms = append(terms, "->", string(r.Action))
+	if r.Action == model.RuleLabel {
+		terms = append(terms, quoteTerm(r.AddLabel))
+	}
+	return strings.Join(terms, " ")
+}
+
+// ruleTerms splits s at spaces, keeping quoted values (key:"a b") whole and
+// unquoted.
+func ruleTerms(s string) ([]string, error) {
+	var out []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return out, nil
+		}
+		i := strings.IndexAny(s, " \t\"")
+		switch {
+		case i < 0:
+			return append(out, s), nil
+		case s[i] != '"':
+			out = append(out, s[:i])
+			s = s[i:]
+		default:
+			q, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated quote in %q", s)
+			}
+			v, _ := strconv.Unquote(q)
+			out = append(out, s[:i]+v)
+			s = s[i+len(q):]
+		}
+	}
+}
+
+func quoteTerm(s string) string {
+	if strings.ContainsAny(s, " \t\"\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// RuleResult is what one rule did in a RunRules pass.
+type RuleResult struct {
+	Rule     model.Rule
+	Messages int // messages archived, trashed, labelled or marked read
+	Err      error
+	// DryRun is set when the rule ran under WithDryRun: Messages is what it
+	// would have changed, and nothing was.
+	DryRun bool
+}
+
+// RunRules applies the enabled rules of store that run after each sync to
+// the cached messages, in order. A message archived or trashed by one rule
+// is not seen by later ones, and the mail of protected senders is left
+// alone. The label and read actions only count messages that still needed
+// them. Runs that changed something or failed are logged. Stores without
+// rules yield no results.
+func RunRules(ctx context.Context, svc *gmailv1.Service, store MessageStore, now time.Time) ([]RuleResult, error) {
+	rs, ok := store.(RuleStore)
+	if !ok {
+		return nil, nil
+	}
+	rules, err := rs.LoadRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load rules: %w", err)
+	}
+	rules = slices.DeleteFunc(rules, func(r model.Rule) bool { return r.Disabled || r.Every != "" })
+	results, err := runRules(ctx, svc, store, rules, now)
+	if err != nil {
+		return nil, err
+	}
+	var changed []RuleResult
+	for _, r := range results {
+		if r.Messages > 0 || r.Err != nil {
+			changed = append(changed, r)
+		}
+	}
+	return results, logRuleRuns(ctx, rs, changed, now)
+}
+
+// RunDueRules runs the enabled cleanup jobs of store (rules with a
+// schedule) whose last logged run is at least their interval before now,
+// as RunRules does, and logs every run.
+func RunDueRules(ctx context.Context, svc *gmailv1.Service, store MessageStore, now time.Time) ([]RuleResult, error) {
+	rs, ok := store.(RuleStore)
+	if !ok {
+		return nil, nil
+	}
+	rules, err := rs.LoadRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load rules: %w", err)
+	}
+	last, err := rs.LastRuleRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load rule runs: %w", err)
+	}
+	rules = slices.DeleteFunc(rules, func(r model.Rule) bool {
+		if r.Disabled || r.Every == "" {
+			return true
+		}
+		schedule, err := scheduler.ParseSchedule(r.Every)
+		return err != nil || (!last[r.ID].Time.IsZero() && schedule.Next(last[r.ID].Time).After(now))
+	})
+	results, err := runRules(ctx, svc, store, rules, now)
+	if err != nil {
+		return nil, err
+	}
+	return results, logRuleRuns(ctx, rs, results, now)
+}
+
+// RunRule runs r on its own now, whatever its schedule, and logs the run.
+func RunRule(ctx context.Context, svc *gmailv1.Service, store MessageStore, r model.Rule, now time.Time) (RuleResult, error) {
+	results, err := runRules(ctx, svc, store, []model.Rule{r}, now)
+	if err != nil {
+		return RuleResult{}, err
+	}
+	if rs, ok := store.(RuleStore); ok {
+		err = logRuleRuns(ctx, rs, results, now)
+	}
+	return results[0], err
+}
+
+func logRuleRuns(ctx context.Context, rs RuleStore, results []RuleResult, now time.Time) error {
+	if IsDryRun(ctx) {
+		// A dry run changed nothing, so it must not hold back the next real one.
+		return nil
+	}
+	for _, r := range results {
+		run := model.RuleRun{RuleID: r.Rule.ID, Time: now, Rule: FormatRule(r.Rule), Messages: r.Messages}
+		if r.Err != nil {
+			run.Err = r.Err.Error()
+		}
+		if err := rs.LogRuleRun(ctx, run); err != nil {
+			return fmt.Errorf("log rule run: %w", err)
+		}
+	}
+	return nil
+}
+
+// runRules applies rules to the cached messages in order.
+func runRules(ctx context.Context, svc *gmailv1.Service, store MessageStore, rules []model.Rule, now time.Time) ([]RuleResult, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	msgs, err := store.LoadAllMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := LoadSenderLists(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	msgs = slices.DeleteFunc(msgs, func(m model.MessageRef) bool { return lists.Protected(m.From) })
+
+	results := make([]RuleResult, len(rules))
+	for i, r := range rules {
+		results[i].Rule, results[i].DryRun = r, IsDryRun(ctx)
+		match, err := ruleMatcher(ctx, svc, r, now)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		var hits []model.MessageRef
+		for _, m := range msgs {
+			if match(m) {
+				hits = append(hits, m)
+			}
+		}
+		n, err := applyRule(ctx, svc, store, r, hits)
+		results[i].Messages, results[i].Err = n, err
+		if err != nil {
+			slog.Error("rule failed", "rule", FormatRule(r), "messages", n, "err", err)
+		} else if n > 0 && !IsDryRun(ctx) {
+			slog.Info("rule", "rule", FormatRule(r), "messages", n)
+		}
+		if r.Action == model.RuleArchive || r.Action == model.RuleTrash {
+			gone := make(map[string]bool, n)
+			for _, m := range hits[:n] {
+				gone[m.ID] = true
+			}
+			msgs = slices.DeleteFunc(msgs, func(m model.MessageRef) bool { return gone[m.ID] })
+		}
+	}
+	return results, nil
+}
+
+// ruleMatcher returns a test for the messages r matches at now, resolving
+// its label condition to an ID.
+func ruleMatcher(ctx context.Context, svc *gmailv1.Service, r model.Rule, now time.Time) (func(model.MessageRef) bool, error) {
+	var subject *regexp.Regexp
+	if r.Subject != "" {
+		re, err := regexp.Compile("(?i)" + r.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("subject: %w", err)
+		}
+		subject = re
+	}
+	var cutoff time.Time
+	if r.OlderThan != "" {
+		t, _, err := ago(r.OlderThan, now)
+		if err != nil {
+			return nil, err
+		}
+		cutoff = t
+	}
+	var label string
+	if r.Label != "" {
+		id, err := ResolveLabel(ctx, svc, r.Label)
+		if err != nil {
+			return nil, err
+		}
+		label = id
+	}
+	return func(m model.MessageRef) bool {
+		if r.Sender != "" && !senderMatches(r.Sender, m.From) {
+			return false
+		}
+		if r.Unsubscribe && m.ListUnsubscribe == "" {
+			return false
+		}
+		if subject != nil && !subject.MatchString(m.Subject) {
+			return false
+		}
+		if !cutoff.IsZero() {
+			date, err := time.Parse(time.RFC3339, m.DateRFC3339)
+			if err != nil || !date.Before(cutoff) {
+				return false
+			}
+		}
+		return label == "" || contains(m.LabelIDs, label)
+	}, nil
+}
+
+// applyRule runs the action of r on msgs and updates the cache. It returns
+// how many messages it changed; archived and trashed ones are msgs[:n].
+func applyRule(ctx context.Context, svc *gmailv1.Service, store MessageStore, r model.Rule, msgs []model.MessageRef) (int, error) {
+	switch r.Action {
+	case model.RuleArchive, model.RuleTrash:
+		remove, kind := ArchiveMessages, "archive"
+		if r.Action == model.RuleTrash {
+			remove, kind = TrashMessages, "trash"
+		}
+		ids := make([]string, len(msgs))
+		for i, m := range msgs {
+			ids[i] = m.ID
+		}
+		if len(ids) == 0 {
+			return 0, nil
+		}
+		if err := remove(ctx, svc, ids); errors.Is(err, ErrDryRun) {
+			return len(ids), nil
+		} else if err != nil {
+			return 0, err
+		}
+		if r.Action == model.RuleTrash {
+			if err := RememberTrashed(ctx, store, msgs, time.Now()); err != nil {
+				return len(ids), err
+			}
+			if err := store.DeleteMessages(ctx, ids); err != nil {
+				return len(ids), err
+			}
+		} else if err := CacheLabelRemoved(ctx, store, ids, "INBOX"); err != nil {
+			return len(ids), err
+		}
+		return len(ids), recordBySender(ctx, store, kind, msgs)
+	case model.RuleRead:
+		return relabel(ctx, svc, store, msgs, "", "UNREAD")
+	case model.RuleLabel:
+		ids, err := ensureLabels(ctx, svc, []string{r.AddLabel})
+		if err != nil {
+			return 0, err
+		}
+		return relabel(ctx, svc, store, msgs, ids[r.AddLabel], "")
+	}
+	return 0, fmt.Errorf("unknown action %q", r.Action)
+}
+
+// relabel adds the label add to, or removes the label remove from, the
+// messages that need it, and updates their cached labels.
+func relabel(ctx context.Context, svc *gmailv1.Service, store MessageStore, msgs []model.MessageRef, add, remove string) (int, error) {
+	labels := make(map[string][]string)
+	for _, m := range msgs {
+		switch {
+		case add != "" && !contains(m.LabelIDs, add):
+			labels[m.ID] = append(slices.Clone(m.LabelIDs), add)
+		case remove != "" && contains(m.LabelIDs, remove):
+			labels[m.ID] = slices.DeleteFunc(slices.Clone(m.LabelIDs), func(l string) bool { return l == remove })
+		}
+	}
+	if len(labels) == 0 {
+		return 0, nil
+	}
+	var addIDs, removeIDs []string
+	if add != "" {
+		addIDs = []string{add}
+	}
+	if remove != "" {
+		removeIDs = []string{remove}
+	}
+	if err := modifyLabels(ctx, svc, remoteIDs(keysOf(labels)), addIDs, removeIDs); errors.Is(err, ErrDryRun) {
+		return len(labels), nil
+	} else if err != nil {
+		return 0, err
+	}
+	if ls, ok := store.(LabelStore); ok {
+		if err := ls.UpdateLabels(ctx, labels); err != nil {
+			return len(labels), err
+		}
+	}
+	return len(labels), nil
+}
+
+// recordBySender adds one action per sender of msgs to the history.
+func recordBySender(ctx context.Context, store MessageStore, kind string, msgs []model.MessageRef) error {
+	bySender := make(map[string]int)
+	for _, m := range msgs {
+		bySender[m.From]++
+	}
+	senders := make([]string, 0, len(bySender))
+	for s := range bySender {
+		senders = append(senders, s)
+	}
+	sort.Strings(senders)
+	var errs []error
+	for _, s := range senders {
+		errs = append(errs, RecordAction(ctx, store, model.Action{Kind: kind, Sender: s, Messages: bySender[s]}))
+	}
+	return errors.Join(errs...)
+}
+
+func keysOf[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RulesSummary describes what a RunRules pass changed in a few words, such
+// as "rules archived 12 and marked 3 read", and joins the errors of the
+// rules that failed. It returns "" when nothing changed. A dry run is
+// described as what the rules would do, as in "rules would archive 12".
+func RulesSummary(results []RuleResult) (string, error) {
+	verbs := []struct {
+		action      model.RuleAction
+		format, dry string
+	}{
+		{model.RuleArchive, "archived %d", "archive %d"},
+		{model.RuleTrash, "trashed %d", "trash %d"},
+		{model.RuleLabel, "labelled %d", "label %d"},
+		{model.RuleRead, "marked %d read", "mark %d read"},
+	}
+	counts := make(map[model.RuleAction]int)
+	dry := false
+	var errs []error
+	for _, r := range results {
+		counts[r.Rule.Action] += r.Messages
+		dry = dry || r.DryRun
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", FormatRule(r.Rule), r.Err))
+		}
+	}
+	var parts []string
+	for _, v := range verbs {
+		if n := counts[v.action]; n > 0 && dry {
+			parts = append(parts, fmt.Sprintf(v.dry, n))
+		} else if n > 0 {
+			parts = append(parts, fmt.Sprintf(v.format, n))
+		}
+	}
+	if len(parts) == 0 {
+		return "", errors.Join(errs...)
+	}
+	summary := "rules " + strings.Join(parts, ", ")
+	if dry {
+		summary = "rules would " + strings.Join(parts, ", ")
+	}
+	if i := strings.LastIndex(summary, ", "); i >= 0 {
+		summary = summary[:i] + " and " + summary[i+2:]
+	}
+	return summary, errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package gmail
+
+import (
+	"errors"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		in   string
+		want model.Rule
+		text string
+	}{
+		{"from:@Shop.example older:30D -> archive",
+			model.Rule{Sender: "@shop.example", OlderThan: "30d", Action: model.RuleArchive},
+			"from:@shop.example older:30d -> archive"},
+		{`subject:"(receipt|invoice) for" label:INBOX -> label "Old receipts"`,
+			model.Rule{Subject: "(receipt|invoice) for", Label: "INBOX", Action: model.RuleLabel, AddLabel: "Old receipts"},
+			`subject:"(receipt|invoice) for" label:INBOX -> label "Old receipts"`},
+		{"from:alerts@bank.example->READ",
+			model.Rule{Sender: "alerts@bank.example", Action: model.RuleRead},
+			"from:alerts@bank.example -> read"},
+		{`has:Unsubscribe older:90d every:"@every 12h" -> trash`,
+			model.Rule{OlderThan: "90d", Unsubscribe: true, Every: "@every 12h", Action: model.RuleTrash},
+			`older:90d has:unsubscribe every:"@every 12h" -> trash`},
+		{`older:1y every:"0 3 * * sun" -> archive`,
+			model.Rule{OlderThan: "1y", Every: "0 3 * * sun", Action: model.RuleArchive},
+			`older:1y every:"0 3 * * sun" -> archive`},
+	}
+	for _, tc := range tests {
+		r, err := ParseRule(tc.in)
+		if err != nil || r != tc.want {
+			t.Errorf("ParseRule(%q) = %+v, %v; want %+v", tc.in, r, err, tc.want)
+			continue
+		}
+		if got := FormatRule(r); got != tc.text {
+			t.Errorf("FormatRule(%+v) = %q; want %q", r, got, tc.text)
+		}
+	}
+	for _, bad := range []string{
+		"from:@shop.example",
+		"-> trash",
+		"from:@shop.example -> delete",
+		"from:@shop.example -> label",
+		"from:@shop.example -> archive now",
+		"older:soon -> archive",
+		"subject:( -> archive",
+		`subject:"open -> archive`,
+		"size:10 -> archive",
+		"has:attachment -> archive",
+		"every:@daily -> archive",
+		"older:90d every:often -> archive",
+	} {
+		if _, err := ParseRule(bad); err == nil {
+			t.Errorf("ParseRule(%q) accepted", bad)
+		}
+	}
+}
+
+func TestRulesSummary(t *testing.T) {
+	failed := errors.New("boom")
+	summary, err := RulesSummary([]RuleResult{
+		{Rule: model.Rule{Action: model.RuleArchive}, Messages: 12},
+		{Rule: model.Rule{Action: model.RuleRead}, Messages: 3},
+		{Rule: model.Rule{Action: model.RuleArchive}, Messages: 1},
+		{Rule: model.Rule{Sender: "a@b.example", Action: model.RuleTrash}, Err: failed},
+	})
+	if summary != "rules archived 13 and marked 3 read" || !errors.Is(err, failed) {
+		t.Fatalf("RulesSummary = %q, %v", summary, err)
+	}
+	if summary, err := RulesSummary(nil); summary != "" || err != nil {
+		t.Fatalf("RulesSummary(nil) = %q, %v", summary, err)
+	}
+	summary, _ = RulesSummary([]RuleResult{
+		{Rule: model.Rule{Action: model.RuleTrash}, Messages: 4, DryRun: true},
+		{Rule: model.Rule{Action: model.RuleLabel}, Messages: 2, DryRun: true},
+	})
+	if summary != "rules would trash 4 and label 2" {
+		t.Fatalf("dry-run RulesSummary = %q", summary)
+	}
+}
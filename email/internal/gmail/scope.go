@@ -0,0 +1,195 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// AllMail is the SyncOptions.Label value that syncs every message outside
+// spam and trash instead of a single label.
+const AllMail = "ALL"
+
+const (
+	metaSyncLabel = "sync_label"
+	metaSyncQuery = "sync_query"
+)
+
+// scope returns the label ID the sync is restricted to, defaulting to INBOX.
+func (o SyncOptions) scope() string {
+	if o.Label == "" {
+		return "INBOX"
+	}
+	return o.Label
+}
+
+// includeSpamTrash reports whether listing needs spam and trash included:
+// when asked to, or when the scope is one of them.
+func (o SyncOptions) includeSpamTrash() bool {
+	scope := o.scope()
+	return o.IncludeSpamTrash || scope == SpamLabel || scope == "TRASH"
+}
+
+// inScope reports whether a message carrying labelIDs belongs to scope.
+func inScope(scope string, labelIDs []string) bool {
+	if scope == AllMail {
+		return !contains(labelIDs, "SPAM") && !contains(labelIDs, "TRASH")
+	}
+	return contains(labelIDs, scope)
+}
+
+// labelChangeEffect classifies a history label change for scope: +1 when the
+// message enters the scope, -1 when it leaves, and 0 when it is unaffected.
+func labelChangeEffect(scope string, changed []string, added bool) int {
+	effect := 0
+	if scope == AllMail {
+		if contains(changed, "SPAM") || contains(changed, "TRASH") {
+			effect = -1
+		}
+	} else if contains(changed, scope) {
+		effect = 1
+	}
+	if !added {
+		effect = -effect
+	}
+	return effect
+}
+
+// LeavesScope reports whether taking label off a message moves it out of
+// scope: archiving, which takes off INBOX, only does for the inbox.
+func LeavesScope(scope, label string) bool {
+	return labelChangeEffect(scope, []string{label}, false) < 0
+}
+
+// CacheLabelRemoved updates the cache after label was taken off ids, as
+// archiving takes off INBOX: they are dropped when that moves them out of
+// the cache's scope, and otherwise stay with label off their cached labels.
+func CacheLabelRemoved(ctx context.Context, store MessageStore, ids []string, label string) error {
+	scope, err := LabelScope(ctx, store)
+	if err != nil {
+		return err
+	}
+	if LeavesScope(scope, label) {
+		return store.DeleteMessages(ctx, ids)
+	}
+	ls, ok := store.(LabelStore)
+	if !ok {
+		return nil
+	}
+	msgs, err := store.GetMessagesByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	labels := make(map[string][]string)
+	for _, m := range msgs {
+		if contains(m.LabelIDs, label) {
+			labels[m.ID] = slices.DeleteFunc(slices.Clone(m.LabelIDs), func(l string) bool { return l == label })
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return ls.UpdateLabels(ctx, labels)
+}
+
+// ResolveLabel turns a user-supplied scope into a label ID. It accepts "" or
+// INBOX, AllMail ("ALL", case-insensitive), a label ID such as
+// CATEGORY_PROMOTIONS, or a user label name.
+func ResolveLabel(ctx context.Context, svc *gmailv1.Service, nameOrID string) (string, error) {
+	nameOrID = strings.TrimSpace(nameOrID)
+	switch {
+	case nameOrID == "" || strings.EqualFold(nameOrID, "INBOX"):
+		return "INBOX", nil
+	case strings.EqualFold(nameOrID, AllMail):
+		return AllMail, nil
+	}
+	resp, err := retry(ctx, func() (*gmailv1.ListLabelsResponse, error) {
+		return svc.Users.Labels.List("me").Context(ctx).Do()
+	})
+	if err != nil {
+		return "", fmt.Errorf("list labels: %w", err)
+	}
+	for _, l := range resp.Labels {
+		if l.Id == nameOrID {
+			return l.Id, nil
+		}
+	}
+	for _, l := range resp.Labels {
+		if strings.EqualFold(l.Name, nameOrID) {
+			return l.Id, nil
+		}
+	}
+	return "", fmt.Errorf("no Gmail label named %q", nameOrID)
+}
+
+// LabelScope returns the label the cache was last synced with (INBOX for
+// caches created before scopes were configurable).
+func LabelScope(ctx context.Context, store MessageStore) (string, error) {
+	label, err := store.GetMetadata(ctx, metaSyncLabel)
+	if err != nil || label != "" {
+		return label, err
+	}
+	return "INBOX", nil
+}
+
+// EnsureLabelScope records label as the cache's sync scope. If the cache was
+// built for a different scope it is cleared, together with the history cursor
+// and any scan checkpoint, so the next sync performs a fresh FullScan. It
+// reports whether the cache was reset.
+func EnsureLabelScope(ctx context.Context, store MessageStore, label string) (bool, error) {
+	if label == "" {
+		label = "INBOX"
+	}
+	current, err := LabelScope(ctx, store)
+	if err != nil {
+		return false, err
+	}
+	if current == label {
+		return false, store.SetMetadata(ctx, metaSyncLabel, label)
+	}
+	if err := resetScope(ctx, store); err != nil {
+		return false, err
+	}
+	return true, store.SetMetadata(ctx, metaSyncLabel, label)
+}
+
+// SyncQuery returns the Gmail search the cache was last synced with, or ""
+// when it holds everything in its label scope.
+func SyncQuery(ctx context.Context, store MessageStore) (string, error) {
+	return store.GetMetadata(ctx, metaSyncQuery)
+}
+
+// EnsureQueryScope records query as the Gmail search the cache is narrowed
+// to. Like EnsureLabelScope, it clears a cache that was built with another
+// search and reports whether it did.
+func EnsureQueryScope(ctx context.Context, store MessageStore, query string) (bool, error) {
+	query = strings.TrimSpace(query)
+	current, err := SyncQuery(ctx, store)
+	if err != nil {
+		return false, err
+	}
+	if current == query {
+		return false, nil
+	}
+	if err := resetScope(ctx, store); err != nil {
+		return false, err
+	}
+	return true, store.SetMetadata(ctx, metaSyncQuery, query)
+}
+
+// resetScope empties the cache along with its history cursor and any scan
+// checkpoint, so the next sync performs a fresh FullScan.
+func resetScope(ctx context.Context, store MessageStore) error {
+	if err := store.ClearMessages(ctx); err != nil {
+		return fmt.Errorf("clear cache for new sync scope: %w", err)
+	}
+	for _, key := range []string{"last_history_id", metaScanHistoryID, metaScanPageToken, metaLastSync, metaRetentionCutoff, metaScanSince, metaRescan} {
+		if err := store.SetMetadata(ctx, key, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
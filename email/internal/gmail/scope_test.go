@@ -0,0 +1,129 @@
+package gmail
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+func TestScopeMembership(t *testing.T) {
+	if !inScope("INBOX", []string{"INBOX", "UNREAD"}) || inScope("INBOX", []string{"CATEGORY_PROMOTIONS"}) {
+		t.Fatal("INBOX scope membership wrong")
+	}
+	if !inScope(AllMail, []string{"SENT"}) || inScope(AllMail, []string{"SPAM"}) || inScope(AllMail, []string{"TRASH", "INBOX"}) {
+		t.Fatal("all-mail scope membership wrong")
+	}
+	if !inScope(SpamLabel, []string{"SPAM"}) || !(SyncOptions{Label: SpamLabel}).includeSpamTrash() || (SyncOptions{}).includeSpamTrash() {
+		t.Fatal("spam scope wrong")
+	}
+}
+
+func TestLabelChangeEffect(t *testing.T) {
+	tests := []struct {
+		scope   string
+		changed []string
+		added   bool
+		want    int
+	}{
+		{"INBOX", []string{"INBOX"}, true, 1},
+		{"INBOX", []string{"INBOX"}, false, -1},
+		{"INBOX", []string{"UNREAD"}, false, 0},
+		{"CATEGORY_PROMOTIONS", []string{"CATEGORY_PROMOTIONS"}, true, 1},
+		{AllMail, []string{"TRASH"}, true, -1},
+		{AllMail, []string{"SPAM"}, false, 1},
+		{AllMail, []string{"INBOX"}, false, 0},
+	}
+	for _, tc := range tests {
+		if got := labelChangeEffect(tc.scope, tc.changed, tc.added); got != tc.want {
+			t.Errorf("labelChangeEffect(%q, %v, %v) = %d; want %d", tc.scope, tc.changed, tc.added, got, tc.want)
+		}
+	}
+}
+
+func TestCacheLabelRemoved(t *testing.T) {
+	for _, tc := range []struct {
+		scope, labels, label string
+		want                 string // labels left on the cached message, "-" if dropped
+	}{
+		{"", "INBOX,UNREAD", "INBOX", "-"},
+		{"INBOX", "INBOX,UNREAD", "INBOX", "-"},
+		{AllMail, "INBOX,UNREAD", "INBOX", "UNREAD"},
+		{"CATEGORY_PROMOTIONS", "INBOX,CATEGORY_PROMOTIONS", "INBOX", "CATEGORY_PROMOTIONS"},
+		{SpamLabel, "SPAM", SpamLabel, "-"},
+	} {
+		st := &cacheStore{scanStore: scanStore{
+			metadataStore: metadataStore{meta: map[string]string{metaSyncLabel: tc.scope}},
+			msgs: map[string]model.MessageRef{
+				"a": {ID: "a", LabelIDs: strings.Split(tc.labels, ",")},
+			},
+		}}
+		if err := CacheLabelRemoved(context.Background(), st, []string{"a"}, tc.label); err != nil {
+			t.Fatalf("scope %q: %v", tc.scope, err)
+		}
+		got := "-"
+		if m, ok := st.msgs["a"]; ok {
+			got = strings.Join(m.LabelIDs, ",")
+		}
+		if got != tc.want {
+			t.Errorf("scope %q, %s taken off: cached %q; want %q", tc.scope, tc.label, got, tc.want)
+		}
+	}
+}
+
+// clearingStore counts ClearMessages calls on top of metadataStore.
+type clearingStore struct {
+	metadataStore
+	cleared int
+}
+
+func (s *clearingStore) ClearMessages(context.Context) error {
+	s.cleared++
+	return nil
+}
+
+func TestEnsureQueryScope(t *testing.T) {
+	ctx := context.Background()
+	st := &clearingStore{metadataStore: metadataStore{meta: map[string]string{"last_history_id": "42"}}}
+	if reset, err := EnsureQueryScope(ctx, st, ""); err != nil || reset || st.cleared != 0 {
+		t.Fatalf("unchanged empty query: reset=%v err=%v cleared=%d", reset, err, st.cleared)
+	}
+	if reset, err := EnsureQueryScope(ctx, st, " category:promotions "); err != nil || !reset || st.cleared != 1 {
+		t.Fatalf("new query: reset=%v err=%v cleared=%d", reset, err, st.cleared)
+	}
+	if q, _ := SyncQuery(ctx, st); q != "category:promotions" || st.meta["last_history_id"] != "" {
+		t.Fatalf("query %q, history %q after reset", q, st.meta["last_history_id"])
+	}
+	if reset, _ := EnsureQueryScope(ctx, st, "category:promotions"); reset || st.cleared != 1 {
+		t.Fatal("same query reset the cache")
+	}
+}
+
+func TestLastSync(t *testing.T) {
+	ctx := context.Background()
+	st := &clearingStore{metadataStore: metadataStore{meta: map[string]string{}}}
+	if last, err := LastSync(ctx, st); err != nil || !last.IsZero() {
+		t.Fatalf("never synced: %v, %v", last, err)
+	}
+	if err := MarkSynced(ctx, st); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := LastSync(ctx, st); err != nil || time.Since(last) > time.Minute {
+		t.Fatalf("after a sync: %v, %v", last, err)
+	}
+	if _, err := EnsureLabelScope(ctx, st, "INBOX"); err != nil {
+		t.Fatal(err)
+	}
+	if reset, err := EnsureLabelScope(ctx, st, "Label_7"); err != nil || !reset {
+		t.Fatalf("new scope: reset=%v err=%v", reset, err)
+	}
+	if last, err := LastSync(ctx, st); err != nil || !last.IsZero() {
+		t.Errorf("a reset cache still reports a sync at %v (%v)", last, err)
+	}
+	st.meta[metaLastSync] = "yesterday"
+	if _, err := LastSync(ctx, st); err == nil {
+		t.Error("an unreadable time was accepted")
+	}
+}
@@ -0,0 +1,183 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// SenderListStore is implemented by stores that persist protected and
+// blocked senders.
+type SenderListStore interface {
+	// SetSenderStatus sets the status of a match; "" removes it.
+	SetSenderStatus(ctx context.Context, match string, status model.SenderStatus) error
+	LoadSenderStatuses(ctx context.Context) ([]model.SenderRule, error)
+}
+
+// SenderLists answers which senders are protected or blocked.
+type SenderLists struct {
+	rules []model.SenderRule
+}
+
+// NewSenderLists returns lists holding rules.
+func NewSenderLists(rules []model.SenderRule) SenderLists {
+	return SenderLists{rules: rules}
+}
+
+// LoadSenderLists reads the lists from store. Stores without sender lists
+// yield empty lists.
+func LoadSenderLists(ctx context.Context, store MessageStore) (SenderLists, error) {
+	ss, ok := store.(SenderListStore)
+	if !ok {
+		return SenderLists{}, nil
+	}
+	rules, err := ss.LoadSenderStatuses(ctx)
+	if err != nil {
+		return SenderLists{}, fmt.Errorf("load sender lists: %w", err)
+	}
+	return NewSenderLists(rules), nil
+}
+
+// Rules returns the protected and blocked senders.
+func (l SenderLists) Rules() []model.SenderRule { return l.rules }
+
+// Status returns the status of the normalized sender email, or of a domain
+// group's "@domain" key. An address listed on its own wins over its domain,
+// and a subdomain over its parent.
+func (l SenderLists) Status(email string) model.SenderStatus {
+	var status model.SenderStatus
+	best := -1
+	for _, r := range l.rules {
+		if r.Match == email {
+			return r.Status
+		}
+		if strings.HasPrefix(r.Match, "@") && len(r.Match) > best && senderMatches(r.Match, email) {
+			status, best = r.Status, len(r.Match)
+		}
+	}
+	return status
+}
+
+// Listed returns the status match was given itself, ignoring the domains
+// that cover it.
+func (l SenderLists) Listed(match string) model.SenderStatus {
+	for _, r := range l.rules {
+		if r.Match == match {
+			return r.Status
+		}
+	}
+	return ""
+}
+
+// Protected reports whether email is a protected sender.
+func (l SenderLists) Protected(email string) bool {
+	return l.Status(email) == model.SenderProtected
+}
+
+// ApplySenderLists sets the Status of every group from the lists.
+func ApplySenderLists(groups []model.SenderGroup, lists SenderLists) {
+	for i := range groups {
+		groups[i].Status = lists.Status(groups[i].Email)
+	}
+}
+
+// ParseSenderMatch normalizes a sender address or "@domain" for the lists.
+func ParseSenderMatch(s string) (string, error) {
+	match := strings.ToLower(strings.TrimSpace(s))
+	at := strings.LastIndexByte(match, '@')
+	if at < 0 || at == len(match)-1 || strings.ContainsAny(match, " \t<>,") {
+		return "", fmt.Errorf("%q is not an address or @domain", s)
+	}
+	return match, nil
+}
+
+// senderMatches reports whether the normalized sender email is covered by
+// match, a full address or an "@domain" that also covers its subdomains.
+func senderMatches(match, email string) bool {
+	match = strings.ToLower(strings.TrimSpace(match))
+	if match == "" || email == "" {
+		return false
+	}
+	if strings.HasPrefix(match, "@") {
+		at := strings.LastIndexByte(email, '@')
+		if at < 0 {
+			return false
+		}
+		domain := email[at+1:]
+		return domain == match[1:] || strings.HasSuffix(domain, "."+match[1:])
+	}
+	return email == match
+}
+
+// Triage acts on the messages an incremental sync brings in. Arrived runs
+// before they are cached and returns those to cache; Cached runs once they
+// are. Errors are reported when the sync ends, which keeps its cursor.
+type Triage interface {
+	Arrived(ctx context.Context, svc *gmailv1.Service, store MessageStore, msgs []model.MessageRef) ([]model.MessageRef, error)
+	Cached(ctx context.Context, svc *gmailv1.Service, msgs []model.MessageRef) error
+}
+
+// NewTriage returns the Triage of the TUI, the daemon and headless sync: it
+// trashes mail from blocked senders before it is cached, then applies the
+// auto-label rules to the rest.
+func NewTriage(rules []AutoLabelRule) Triage { return triage{rules} }
+
+type triage struct{ rules []AutoLabelRule }
+
+func (t triage) Arrived(ctx context.Context, svc *gmailv1.Service, store MessageStore, msgs []model.MessageRef) ([]model.MessageRef, error) {
+	return trashBlocked(ctx, svc, store, msgs)
+}
+
+func (t triage) Cached(ctx context.Context, svc *gmailv1.Service, msgs []model.MessageRef) error {
+	if _, err := ApplyAutoLabels(ctx, svc, t.rules, msgs); err != nil {
+		return fmt.Errorf("auto-label: %w", err)
+	}
+	return nil
+}
+
+// trashBlocked moves the messages of blocked senders to the trash and
+// returns the rest. If the trash request fails every message is returned
+// along with the error, so the caller still caches them, as does a dry run.
+func trashBlocked(ctx context.Context, svc *gmailv1.Service, store MessageStore, msgs []model.MessageRef) ([]model.MessageRef, error) {
+	lists, err := LoadSenderLists(ctx, store)
+	if err != nil || len(lists.rules) == 0 {
+		return msgs, err
+	}
+	var kept, trashed []model.MessageRef
+	var ids []string
+	bySender := make(map[string]int)
+	for _, m := range msgs {
+		if lists.Status(m.From) == model.SenderBlocked {
+			trashed = append(trashed, m)
+			ids = append(ids, m.ID)
+			bySender[m.From]++
+		} else {
+			kept = append(kept, m)
+		}
+	}
+	if len(ids) == 0 {
+		return msgs, nil
+	}
+	if err := TrashMessages(ctx, svc, ids); errors.Is(err, ErrDryRun) {
+		return msgs, nil
+	} else if err != nil {
+		return msgs, fmt.Errorf("trash blocked senders: %w", err)
+	}
+	errs := []error{RememberTrashed(ctx, store, trashed, time.Now())}
+	senders := make([]string, 0, len(bySender))
+	for s := range bySender {
+		senders = append(senders, s)
+	}
+	sort.Strings(senders)
+	for _, s := range senders {
+		errs = append(errs, RecordAction(ctx, store, model.Action{Kind: "trash", Sender: s, Messages: bySender[s]}))
+	}
+	return kept, errors.Join(errs...)
+}
@@ -0,0 +1,49 @@
+package gmail
+
+import (
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestSenderListsStatus(t *testing.T) {
+	lists := NewSenderLists([]model.SenderRule{
+		{Match: "@shop.example", Status: model.SenderBlocked},
+		{Match: "orders@shop.example", Status: model.SenderProtected},
+		{Match: "@mail.bank.example", Status: model.SenderBlocked},
+		{Match: "@bank.example", Status: model.SenderProtected},
+	})
+	tests := []struct {
+		email string
+		want  model.SenderStatus
+	}{
+		{"deals@shop.example", model.SenderBlocked},
+		{"orders@shop.example", model.SenderProtected},
+		{"news@eu.shop.example", model.SenderBlocked},
+		{"alerts@bank.example", model.SenderProtected},
+		{"promo@mail.bank.example", model.SenderBlocked},
+		{"@shop.example", model.SenderBlocked},
+		{"someone@else.example", ""},
+	}
+	for _, tc := range tests {
+		if got := lists.Status(tc.email); got != tc.want {
+			t.Errorf("Status(%q) = %q; want %q", tc.email, got, tc.want)
+		}
+	}
+}
+
+func TestParseSenderMatch(t *testing.T) {
+	for in, want := range map[string]string{
+		" News@Example.com ": "news@example.com",
+		"@Example.com":       "@example.com",
+	} {
+		if got, err := ParseSenderMatch(in); err != nil || got != want {
+			t.Errorf("ParseSenderMatch(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "example.com", "news@", "a b@example.com"} {
+		if _, err := ParseSenderMatch(bad); err == nil {
+			t.Errorf("ParseSenderMatch(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"chuckterm/internal/model"
+)
+
+const metaSession = "tui_session"
+
+// Session is where the TUI was left when it last quit, so the next launch
+// can open there again.
+type Session struct {
+	// View is "groups", "messages" or "body".
+	View string `json:"view"`
+	// Group is the highlighted group, or the open one.
+	Group model.GroupKey `json:"group"`
+	// Message is the ID of the highlighted message, or the open one.
+	Message string `json:"message,omitempty"`
+	// BodyOffset is how far the open message was scrolled, in lines.
+	BodyOffset int `json:"body_offset,omitempty"`
+	// Grouping is the groups' GroupMode.
+	Grouping GroupMode `json:"grouping,omitempty"`
+	// ByDomain is how sessions saved before Grouping recorded GroupByDomain.
+	ByDomain bool `json:"by_domain,omitempty"`
+	// DateFilter is the label of the groups date filter, as ParseDateFilter
+	// reads it.
+	DateFilter string `json:"date_filter,omitempty"`
+	// BulkOnly lists only the groups that look like bulk mail.
+	BulkOnly bool `json:"bulk_only,omitempty"`
+	// GroupFilter is the text of the groups list's / filter.
+	GroupFilter string `json:"group_filter,omitempty"`
+	// Search is the search over the open group's messages.
+	Search string `json:"search,omitempty"`
+}
+
+// LoadSession returns the session SaveSession last stored; a zero Session
+// when there is none.
+func LoadSession(ctx context.Context, store MessageStore) (Session, error) {
+	var s Session
+	v, err := store.GetMetadata(ctx, metaSession)
+	if err != nil || v == "" {
+		return s, err
+	}
+	if err := json.Unmarshal([]byte(v), &s); err != nil {
+		return Session{}, fmt.Errorf("read saved session: %w", err)
+	}
+	return s, nil
+}
+
+// SaveSession stores s for LoadSession, replacing the previous one.
+func SaveSession(ctx context.Context, store MessageStore, s Session) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return store.SetMetadata(ctx, metaSession, string(b))
+}
@@ -0,0 +1,50 @@
+package gmail
+
+import (
+	"context"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+// metadataStore keeps metadata in a map; it has none of the other methods
+// of a MessageStore.
+type metadataStore struct {
+	MessageStore
+	meta map[string]string
+}
+
+func (s *metadataStore) GetMetadata(_ context.Context, key string) (string, error) {
+	return s.meta[key], nil
+}
+
+func (s *metadataStore) SetMetadata(_ context.Context, key, value string) error {
+	s.meta[key] = value
+	return nil
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	st := &metadataStore{meta: map[string]string{}}
+	if s, err := LoadSession(ctx, st); err != nil || s != (Session{}) {
+		t.Fatalf("LoadSession of a new store = %+v, %v", s, err)
+	}
+	want := Session{
+		View:       "body",
+		Group:      model.GroupKey{Email: "news@shop.example", Subject: "Weekly deals"},
+		Message:    "m42",
+		BodyOffset: 12,
+		DateFilter: "older than 30d",
+		Search:     "invoice",
+	}
+	if err := SaveSession(ctx, st, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadSession(ctx, st); err != nil || got != want {
+		t.Errorf("LoadSession = %+v, %v; want %+v", got, err, want)
+	}
+	st.meta[metaSession] = "{"
+	if _, err := LoadSession(ctx, st); err == nil {
+		t.Error("LoadSession of a damaged session succeeded")
+	}
+}
@@ -0,0 +1,190 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/util"
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// SnoozeStore is implemented by stores that keep snoozed messages until
+// they are due back in the inbox.
+type SnoozeStore interface {
+	SaveSnooze(ctx context.Context, s model.Snooze) error
+	DeleteSnooze(ctx context.Context, id string) error
+	LoadSnoozes(ctx context.Context) ([]model.Snooze, error)
+}
+
+// snoozeHour is the hour of day a snooze given as a day ends.
+const snoozeHour = 8
+
+var snoozeForRe = regexp.MustCompile(`^(\d+)\s*(m|mins?|minutes?|h|hours?|d|days?|w|weeks?)$`)
+
+// ParseSnoozeTime reads when a snoozed message should come back:
+//
+//	30m, 2h, 3d, 2w            that long from now
+//	tomorrow, monday, mon      that day at 8:00 (a weekday means the next one)
+//	next week                  next Monday at 8:00
+//	2025-06-01                 that day at 8:00
+//	2025-06-01 14:30           that day and time
+//
+// The time must be in the future.
+func ParseSnoozeTime(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	loc := now.Location()
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), snoozeHour, 0, 0, 0, loc)
+	}
+	var t time.Time
+	if m := snoozeForRe.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n == 0 {
+			return time.Time{}, fmt.Errorf("cannot read snooze time %q", s)
+		}
+		switch m[2][0] {
+		case 'm':
+			t = now.Add(time.Duration(n) * time.Minute)
+		case 'h':
+			t = now.Add(time.Duration(n) * time.Hour)
+		case 'd':
+			t = now.AddDate(0, 0, n)
+		default:
+			t = now.AddDate(0, 0, 7*n)
+		}
+		return t, nil
+	}
+	switch s {
+	case "tomorrow":
+		return at(now.AddDate(0, 0, 1)), nil
+	case "next week":
+		return at(nextWeekday(now, time.Monday)), nil
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if name := strings.ToLower(d.String()); s == name || s == name[:3] {
+			return at(nextWeekday(now, d)), nil
+		}
+	}
+	if day, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		t = at(day)
+	} else if t, err = time.ParseInLocation("2006-01-02 15:04", s, loc); err != nil {
+		return time.Time{}, fmt.Errorf("cannot read snooze time %q (try 2h, 3d, tomorrow, mon or 2025-06-01 09:00)", s)
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("snooze time %s is in the past", t.Format("2006-01-02 15:04"))
+	}
+	return t, nil
+}
+
+// nextWeekday returns the first day after now that is a d.
+func nextWeekday(now time.Time, d time.Weekday) time.Time {
+	days := (int(d)-int(now.Weekday())+6)%7 + 1
+	return now.AddDate(0, 0, days)
+}
+
+// SnoozeMessages takes msgs out of the inbox and the cache until until,
+// when WakeSnoozes puts them back.
+func SnoozeMessages(ctx context.Context, svc *gmailv1.Service, store MessageStore, msgs []model.MessageRef, until time.Time) error {
+	ss, ok := store.(SnoozeStore)
+	if !ok {
+		return fmt.Errorf("snoozing needs a local store")
+	}
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		if model.IsLocalID(m.ID) {
+			return fmt.Errorf("message %s was imported locally and cannot be snoozed", m.ID)
+		}
+		ids = append(ids, m.ID)
+	}
+	if SkipDryRun(ctx, "snooze until %s, %s", until.Format("2006-01-02 15:04"), DescribeIDs(ids)) {
+		return ErrDryRun
+	}
+	// Saved first, so mail never leaves the inbox without a way back.
+	for _, m := range msgs {
+		if err := ss.SaveSnooze(ctx, model.Snooze{ID: m.ID, Until: until, From: m.From, Subject: m.Subject}); err != nil {
+			return err
+		}
+	}
+	err := modifyLabels(ctx, svc, ids, nil, []string{"INBOX"})
+	logChange("snooze", len(ids), err)
+	if err != nil {
+		return fmt.Errorf("snooze %w", err)
+	}
+	return store.DeleteMessages(ctx, ids)
+}
+
+// LoadSnoozes returns the pending snoozes, soonest first, or none when the
+// store keeps no snoozes.
+func LoadSnoozes(ctx context.Context, store MessageStore) ([]model.Snooze, error) {
+	ss, ok := store.(SnoozeStore)
+	if !ok {
+		return nil, nil
+	}
+	return ss.LoadSnoozes(ctx)
+}
+
+// WakeSnoozes puts the snoozed messages that are due at now back in the inbox
+// and the cache, and returns them. A message deleted in the meantime is
+// dropped from the snoozes; others that fail stay for the next call, and
+// their errors are returned joined.
+func WakeSnoozes(ctx context.Context, svc *gmailv1.Service, store MessageStore, now time.Time) ([]model.Snooze, error) {
+	snoozes, err := LoadSnoozes(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	var woken []model.Snooze
+	var errs []error
+	for _, s := range snoozes {
+		if s.Until.After(now) {
+			break
+		}
+		if err := Unsnooze(ctx, svc, store, s.ID); errors.Is(err, ErrDryRun) {
+			continue
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		woken = append(woken, s)
+	}
+	return woken, errors.Join(errs...)
+}
+
+// Unsnooze puts message id back in the inbox and the cache now and forgets
+// its snooze.
+func Unsnooze(ctx context.Context, svc *gmailv1.Service, store MessageStore, id string) error {
+	ss, ok := store.(SnoozeStore)
+	if !ok {
+		return fmt.Errorf("snoozing needs a local store")
+	}
+	if SkipDryRun(ctx, "put snoozed message %s back in the inbox", id) {
+		return ErrDryRun
+	}
+	msg, err := retry(ctx, func() (*gmailv1.Message, error) {
+		req := &gmailv1.ModifyMessageRequest{AddLabelIds: []string{"INBOX"}}
+		return svc.Users.Messages.Modify("me", id, req).Context(ctx).Do()
+	})
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == 404 {
+		return ss.DeleteSnooze(ctx, id)
+	}
+	if err != nil {
+		return fmt.Errorf("unsnooze message %s: %w", id, err)
+	}
+	if msg, err = getMetadata(ctx, svc, msg.Id); err != nil {
+		return fmt.Errorf("unsnooze message %s: %w", id, err)
+	}
+	ref := refFromMetadata(msg)
+	ref.FromName = util.SenderName(ref.From)
+	ref.From = util.NormalizeSender(ref.From)
+	if err := store.UpsertMessages(ctx, []model.MessageRef{ref}); err != nil {
+		return err
+	}
+	return ss.DeleteSnooze(ctx, id)
+}
@@ -0,0 +1,40 @@
+package gmail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSnoozeTime(t *testing.T) {
+	// A Tuesday.
+	now := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in, want string
+	}{
+		{"30m", "2025-07-15T12:30:00Z"},
+		{"2 hours", "2025-07-15T14:00:00Z"},
+		{"3d", "2025-07-18T12:00:00Z"},
+		{"1w", "2025-07-22T12:00:00Z"},
+		{"tomorrow", "2025-07-16T08:00:00Z"},
+		{"Mon", "2025-07-21T08:00:00Z"},
+		{"tuesday", "2025-07-22T08:00:00Z"},
+		{"next  week", "2025-07-21T08:00:00Z"},
+		{"2025-08-01", "2025-08-01T08:00:00Z"},
+		{"2025-07-15 18:30", "2025-07-15T18:30:00Z"},
+	}
+	for _, tc := range tests {
+		got, err := ParseSnoozeTime(tc.in, now)
+		if err != nil {
+			t.Errorf("ParseSnoozeTime(%q): %v", tc.in, err)
+			continue
+		}
+		if s := got.Format(time.RFC3339); s != tc.want {
+			t.Errorf("ParseSnoozeTime(%q) = %s, want %s", tc.in, s, tc.want)
+		}
+	}
+	for _, in := range []string{"", "0h", "soon", "2025-07-15", "2025-07-01 09:00"} {
+		if _, err := ParseSnoozeTime(in, now); err == nil {
+			t.Errorf("ParseSnoozeTime(%q): want an error", in)
+		}
+	}
+}
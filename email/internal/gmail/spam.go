@@ -0,0 +1,58 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// SpamLabel is the label ID of Gmail's spam folder.
+const SpamLabel = "SPAM"
+
+// fullAccess is set by SetFullAccess.
+var fullAccess bool
+
+// SetFullAccess makes NewServiceInteractive also ask for the full
+// mail.google.com scope, which DeleteForever needs, with a token kept apart
+// in token-full.json. Read-only mode takes precedence. Call it before the
+// first service is made.
+func SetFullAccess(on bool) { fullAccess = on }
+
+// NotSpam moves the given messages out of spam and back to the inbox, as
+// Gmail's "Not spam" button does.
+func NotSpam(ctx context.Context, svc *gmailv1.Service, messageIDs []string) error {
+	if SkipDryRun(ctx, "move to the inbox %s", DescribeIDs(messageIDs)) {
+		return ErrDryRun
+	}
+	err := modifyLabels(ctx, svc, remoteIDs(messageIDs), []string{"INBOX"}, []string{SpamLabel})
+	logChange("not spam", len(messageIDs), err)
+	if err != nil {
+		return fmt.Errorf("not spam %w", err)
+	}
+	return nil
+}
+
+// DeleteForever deletes the given messages for good, skipping the trash,
+// using BatchDelete in chunks of batchModifyLimit IDs. It needs the service
+// to hold the scope SetFullAccess asks for.
+func DeleteForever(ctx context.Context, svc *gmailv1.Service, messageIDs []string) error {
+	if SkipDryRun(ctx, "delete for good %s", DescribeIDs(messageIDs)) {
+		return ErrDryRun
+	}
+	ids := remoteIDs(messageIDs)
+	for start := 0; start < len(ids); start += batchModifyLimit {
+		end := min(start+batchModifyLimit, len(ids))
+		req := &gmailv1.BatchDeleteMessagesRequest{Ids: ids[start:end]}
+		err := retryDo(ctx, func() error {
+			return svc.Users.Messages.BatchDelete("me", req).Context(ctx).Do()
+		})
+		if err != nil {
+			err = fmt.Errorf("delete messages %d-%d: %w", start, end-1, err)
+			logChange("delete", len(messageIDs), err)
+			return err
+		}
+	}
+	logChange("delete", len(messageIDs), nil)
+	return nil
+}
@@ -2,10 +2,14 @@ package gmail
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"chuckterm/internal/model"
 	"chuckterm/internal/util"
@@ -13,23 +17,278 @@ import (
 	gmailv1 "google.golang.org/api/gmail/v1"
 )
 
+// SyncOptions configures FullScan and SyncSinceHistory. The zero value syncs
+// INBOX without touching any messages.
+type SyncOptions struct {
+	IncludeSpamTrash bool
+	// Label restricts the sync to one label ID; "" means INBOX and AllMail
+	// means every message outside spam and trash. Use EnsureLabelScope before
+	// syncing so a cache built for another scope is reset first.
+	Label string
+	// Triage acts on the messages that arrive during incremental sync; see
+	// NewTriage. It needs the modify scope, so only callers that may change
+	// mail set it, and syncs without it link none of that code.
+	Triage Triage
+	// NewMessages, if set, receives the messages an incremental sync added
+	// to the cache. FullScan does not call it, and messages Triage moved
+	// away never reach it.
+	NewMessages func([]model.MessageRef)
+	// Workers caps concurrent metadata requests, each a batch of up to 100
+	// messages; 0 uses 16 for FullScan and 8 for SyncSinceHistory.
+	Workers int
+	// ListPageSize is how many messages or history records each list
+	// request asks for; 0 uses 500, the most Gmail returns. FullScan
+	// checkpoints after every page, so smaller pages lose less to an
+	// interruption.
+	ListPageSize int
+	// UpsertBatch caps how many messages each write to the store carries;
+	// 0 uses 500.
+	UpsertBatch int
+	// Retention bounds the cache. FullScan leaves out mail older than its
+	// cutoff; providers call Prune after each sync.
+	Retention Retention
+	// Query narrows the sync to the messages a Gmail search matches within
+	// Label, such as "category:promotions older_than:1y". FullScan lists
+	// only those, and SyncSinceHistory adds only new messages it matches.
+	// Use EnsureQueryScope before syncing, as for Label.
+	Query string
+	// Window, such as "6mo" or "2y", limits a fresh FullScan to mail that
+	// recent; "" scans everything. The scan records where the window
+	// starts (see ScanSince), and ExtendScan reaches further back later.
+	Window string
+}
+
+// Bounds on the SyncOptions that tune how hard a sync works.
+const (
+	MaxWorkers      = 64
+	MaxListPageSize = 500
+	MaxUpsertBatch  = 10000
+)
+
+// Validate checks Workers, ListPageSize and UpsertBatch against their
+// bounds. Zero leaves each at its default.
+func (o SyncOptions) Validate() error {
+	for _, b := range []struct {
+		name     string
+		v, limit int
+	}{
+		{"workers", o.Workers, MaxWorkers},
+		{"list page size", o.ListPageSize, MaxListPageSize},
+		{"upsert batch", o.UpsertBatch, MaxUpsertBatch},
+	} {
+		if b.v < 0 || b.v > b.limit {
+			return fmt.Errorf("%s must be from 1 to %d, or 0 for the default", b.name, b.limit)
+		}
+	}
+	return nil
+}
+
+// workers returns opts.Workers, or def when unset.
+func (o SyncOptions) workers(def int) int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return def
+}
+
+// listPageSize returns opts.ListPageSize, or 500 when unset.
+func (o SyncOptions) listPageSize() int64 {
+	if o.ListPageSize > 0 {
+		return int64(o.ListPageSize)
+	}
+	return MaxListPageSize
+}
+
+// upsertBatch returns opts.UpsertBatch, or 500 when unset.
+func (o SyncOptions) upsertBatch() int {
+	if o.UpsertBatch > 0 {
+		return o.UpsertBatch
+	}
+	return 500
+}
+
+// upsertBatches writes msgs to store opts.upsertBatch() at a time. If
+// written is non-nil, it is called with the running count after each write.
+func upsertBatches(ctx context.Context, store MessageStore, opts SyncOptions, msgs []model.MessageRef, written func(n int)) error {
+	size := opts.upsertBatch()
+	for start := 0; start < len(msgs); start += size {
+		end := min(start+size, len(msgs))
+		if err := store.UpsertMessages(ctx, msgs[start:end]); err != nil {
+			return err
+		}
+		if written != nil {
+			written(end)
+		}
+	}
+	return nil
+}
+
+// SyncProgress reports how far a sync has come. FullScan moves through
+// "fullscan-start", then "listing", "metadata" and "writing" for each page of
+// message IDs, and ends with "fullscan-done"; SyncSinceHistory reports
+// "history-start", "history" and "history-done". Total is 0 while unknown,
+// and during a full scan it is Gmail's estimate, which Done may pass.
 type SyncProgress struct {
 	Done  int
 	Total int
 	Phase string
 }
 
+// metadataProgressStep is how many fetched messages FullScan waits between
+// "metadata" reports, so a fast connection doesn't flood the caller.
+const metadataProgressStep = 25
+
 // MessageStore declares the persistence capabilities required by the historical
 // sync routines. Implementations can back this with SQLite, BoltDB, cloud
 // storage, or an in-memory cache depending on the rewrite strategy.
 type MessageStore interface {
 	UpsertMessages(ctx context.Context, msgs []model.MessageRef) error
 	DeleteMessages(ctx context.Context, ids []string) error
+	// ClearMessages removes every cached message.
+	ClearMessages(ctx context.Context) error
 	LoadAllMessages(ctx context.Context) ([]model.MessageRef, error)
 	CountMessages(ctx context.Context) (int, error)
 	GetMessagesByIDs(ctx context.Context, ids []string) ([]model.MessageRef, error)
 	GetLastHistoryID(ctx context.Context) (string, error)
 	SetLastHistoryID(ctx context.Context, historyID string) error
+	// GetMetadata returns the value stored under key, or "" if it is unset.
+	GetMetadata(ctx context.Context, key string) (string, error)
+	SetMetadata(ctx context.Context, key, value string) error
+}
+
+// GroupSummaryStore is implemented by stores that can aggregate groups and
+// stream message IDs without loading every message. Low-memory mode requires it.
+type GroupSummaryStore interface {
+	LoadGroupSummaries(ctx context.Context) ([]model.GroupSummary, error)
+	StreamGroupMessageIDs(ctx context.Context, email, subject string, batchSize int, fn func(ids []string) error) error
+	// GetGroupMessages returns up to limit messages of a group, newest
+	// first and then by descending ID, from the one past after, or from the
+	// newest when after.ID is "". A limit <= 0 returns them all.
+	GetGroupMessages(ctx context.Context, email, subject string, after model.MessageRef, limit int) ([]model.MessageRef, error)
+}
+
+// GroupPageStore is implemented by stores that can list groups a page at a
+// time, so a large cache can be shown before all of it is aggregated.
+type GroupPageStore interface {
+	GroupSummaryStore
+	LoadGroupSummaryPage(ctx context.Context, offset, limit int) ([]model.GroupSummary, error)
+	GroupTotals(ctx context.Context) (model.GroupTotals, error)
+}
+
+// GroupAgeStore is implemented by stores that can count a group's messages
+// by date without loading them.
+type GroupAgeStore interface {
+	// CountGroupAges counts the messages of a sender+subject group dated in
+	// each span between since, which runs from newest to oldest: counts[0]
+	// is those dated since[0] or later, counts[i] those from since[i] up to
+	// since[i-1], and the last count those before every bound.
+	CountGroupAges(ctx context.Context, email, subject string, since []time.Time) (counts []int, undated int, err error)
+}
+
+// LabelStore is implemented by stores that can update the cached label IDs
+// of messages in place.
+type LabelStore interface {
+	UpdateLabels(ctx context.Context, labels map[string][]string) error
+}
+
+// BodyStore is implemented by stores that cache message bodies for offline
+// reading.
+type BodyStore interface {
+	GetBody(ctx context.Context, id string) (body model.MessageBody, ok bool, err error)
+	PutBody(ctx context.Context, id string, body model.MessageBody, maxBytes int64) error
+}
+
+// PinStore is implemented by stores that persist pinned groups.
+type PinStore interface {
+	SetGroupPinned(ctx context.Context, key model.GroupKey, pinned bool) error
+	LoadPinnedGroups(ctx context.Context) ([]model.GroupKey, error)
+}
+
+// ActivityStore is implemented by stores that keep a history of the
+// archive, trash and unsubscribe actions taken through chuckterm.
+type ActivityStore interface {
+	RecordAction(ctx context.Context, a model.Action) error
+	LoadActions(ctx context.Context, since time.Time) ([]model.Action, error)
+}
+
+// RecordAction adds a to the store's action history, dated now unless
+// a.Time is set. Stores without a history ignore it.
+func RecordAction(ctx context.Context, store MessageStore, a model.Action) error {
+	as, ok := store.(ActivityStore)
+	if !ok {
+		return nil
+	}
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	return as.RecordAction(ctx, a)
+}
+
+// LoadGroupSummariesFromDB builds sorted groups from store-side aggregates.
+// The returned groups carry no MessageIDs; resolve them per group through
+// GroupSummaryStore.StreamGroupMessageIDs when an action needs them.
+func LoadGroupSummariesFromDB(ctx context.Context, store MessageStore) ([]model.SenderGroup, error) {
+	gs, ok := store.(GroupSummaryStore)
+	if !ok {
+		return nil, fmt.Errorf("message store does not support low-memory aggregation")
+	}
+	sums, err := gs.LoadGroupSummaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.SenderGroup, 0, len(sums))
+	for _, s := range sums {
+		if s.Email == "" {
+			continue
+		}
+		out = append(out, groupFromSummary(s))
+	}
+	sortGroupSlice(out)
+	return out, nil
+}
+
+// LoadGroupPage returns up to limit sender+subject groups after skipping
+// offset, sorted by OrderCount with pinned groups first. Like the groups of
+// LoadGroupSummariesFromDB they carry no MessageIDs.
+func LoadGroupPage(ctx context.Context, store GroupPageStore, offset, limit int) ([]model.SenderGroup, error) {
+	sums, err := store.LoadGroupSummaryPage(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.SenderGroup, len(sums))
+	for i, s := range sums {
+		out[i] = groupFromSummary(s)
+	}
+	return out, nil
+}
+
+func groupFromSummary(s model.GroupSummary) model.SenderGroup {
+	return model.SenderGroup{
+		Email:               s.Email,
+		Subject:             s.Subject,
+		DisplayName:         groupName(s),
+		Count:               s.Count,
+		Unread:              s.Unread,
+		Sample:              s.Subject,
+		FirstDate:           s.FirstDate,
+		LastDate:            s.LastDate,
+		Size:                s.Size,
+		Months:              s.Months,
+		UnsubscribeURL:      extractHTTPUnsubscribeURL(s.ListUnsubscribe),
+		UnsubscribeOneClick: s.OneClick,
+		UnsubscribeMailto:   extractMailtoUnsubscribe(s.ListUnsubscribe),
+		Pinned:              s.Pinned,
+		Bulk:                s.Bulk,
+	}
+}
+
+// groupName is the display name of the newest message in s that has one,
+// or one made from the address, as AggregateBySenderSubject names groups.
+func groupName(s model.GroupSummary) string {
+	if s.FromName != "" {
+		return s.FromName
+	}
+	return displayNameFromFrom(s.Email, s.Email)
 }
 
 // LoadGroupsFromDB loads cached messages from DB and returns sender+subject groups sorted.
@@ -45,206 +304,383 @@ func LoadGroupsFromDB(ctx context.Context, store MessageStore) ([]model.SenderGr
 	return SortGroups(m), nil
 }
 
-// FullScan performs a first-time scan of INBOX headers and stores them in the cache.
+// Metadata keys used to checkpoint an in-progress FullScan.
+const (
+	metaScanHistoryID = "fullscan_history_id"
+	metaScanPageToken = "fullscan_page_token"
+)
+
+// metaLastSync records when a sync last finished, in RFC 3339.
+const metaLastSync = "last_sync"
+
+// LastSync returns when FullScan or SyncSinceHistory last finished, or the
+// zero time if the cache has never been synced.
+func LastSync(ctx context.Context, store MessageStore) (time.Time, error) {
+	v, err := store.GetMetadata(ctx, metaLastSync)
+	if err != nil || v == "" {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// MarkSynced records now as the time of the last completed sync, which
+// LastSync returns.
+func MarkSynced(ctx context.Context, store MessageStore) error {
+	return store.SetMetadata(ctx, metaLastSync, time.Now().UTC().Format(time.RFC3339))
+}
+
+// FullScan performs a first-time scan of the configured label (INBOX by default)
+// and stores message headers in the cache.
 // It also captures the current mailbox historyId for future incremental sync.
-func FullScan(ctx context.Context, svc *gmailv1.Service, store MessageStore, includeSpamTrash bool, progress func(SyncProgress)) error {
+//
+// The scan proceeds one list page at a time and checkpoints the next page token
+// after each page is written, so an interrupted scan resumes where it left off.
+// On resume, messages already present in the store are not fetched again and
+// the historyId captured when the scan first started is kept, so the following
+// incremental sync picks up everything that changed in between.
+func FullScan(ctx context.Context, svc *gmailv1.Service, store MessageStore, opts SyncOptions, progress func(SyncProgress)) (err error) {
 	if store == nil {
 		return fmt.Errorf("message store is required")
 	}
-	user := "me"
+	slog.Info("full scan start", "label", opts.Label, "query", opts.Query)
+	defer logSync("full scan", time.Now(), Usage(), &err)
+	progress = logPhases(progress)
 	if progress != nil {
 		progress(SyncProgress{Phase: "fullscan-start"})
 	}
 
-	// Step 1: get current mailbox historyId
-	hid, err := currentHistoryID(ctx, svc)
+	// Step 1: get the mailbox historyId, reusing the one from an interrupted scan
+	hid, err := store.GetMetadata(ctx, metaScanHistoryID)
 	if err != nil {
-		return fmt.Errorf("get current historyId: %w", err)
+		return err
+	}
+	pageToken, err := store.GetMetadata(ctx, metaScanPageToken)
+	if err != nil {
+		return err
+	}
+	resuming := hid != ""
+	s := &scan{svc: svc, store: store, opts: opts, progress: progress}
+	if resuming {
+		if s.done, err = store.CountMessages(ctx); err != nil {
+			return err
+		}
+	} else {
+		hid, err = currentHistoryID(ctx, svc)
+		if err != nil {
+			return fmt.Errorf("get current historyId: %w", err)
+		}
+		if err := store.SetMetadata(ctx, metaScanHistoryID, hid); err != nil {
+			return err
+		}
+		if _, err := StartScanWindow(ctx, store, opts.Window, time.Now()); err != nil {
+			return err
+		}
 	}
 
-	// Step 2: list all message IDs (INBOX only for MVP)
-	list := svc.Users.Messages.List(user).
-		IncludeSpamTrash(includeSpamTrash).
-		MaxResults(500).
-		LabelIds("INBOX")
+	// Step 2: list all message IDs in the label scope
+	cutoff, err := RetentionCutoff(ctx, store, opts.Retention, time.Now())
+	if err != nil {
+		return err
+	}
+	since, err := ScanSince(ctx, store)
+	if err != nil {
+		return err
+	}
+	if since.After(cutoff) {
+		cutoff = since
+	}
+	q := searchQuery(opts.Query, cutoff)
 
-	type job struct{ id string }
-	type result struct {
-		ref model.MessageRef
-		err error
+	// Step 3: fetch each page's metadata concurrently, write it, then checkpoint
+	err = s.pages(ctx, listCall(svc, opts, q), pageToken, resuming, func(next string) error {
+		return store.SetMetadata(ctx, metaScanPageToken, next)
+	})
+	if err != nil {
+		return err
+	}
+	collectErr, done := s.fetchErr, s.done
+	// A rescan kept what was cached; drop and update what changed.
+	if err := finishRescan(ctx, svc, store, opts, q); err != nil && collectErr == nil {
+		collectErr = err
 	}
 
-	jobs := make(chan job, 1000)
-	results := make(chan result, 1000)
+	// Step 4: store historyId if we processed anything, even if some errors occurred
+	if done > 0 {
+		if err := store.SetLastHistoryID(ctx, hid); err != nil && collectErr == nil {
+			collectErr = err
+		}
+	}
+	for _, key := range []string{metaScanHistoryID, metaScanPageToken} {
+		if err := store.SetMetadata(ctx, key, ""); err != nil && collectErr == nil {
+			collectErr = err
+		}
+	}
+	if collectErr == nil {
+		collectErr = MarkSynced(ctx, store)
+	}
 
-	// Step 3: worker pool to fetch metadata
-	const workerCount = 16
-	var wg sync.WaitGroup
-	wg.Add(workerCount)
-	for i := 0; i < workerCount; i++ {
-		go func() {
-			defer wg.Done()
-			for j := range jobs {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-				msg, err := svc.Users.Messages.Get(user, j.id).
-					Format("metadata").
-					MetadataHeaders("From", "Subject", "Date", "List-Unsubscribe", "List-Unsubscribe-Post").
-					Do()
-				if err != nil {
-					results <- result{err: err}
-					continue
-				}
-				var from, subject, date, listUnsub, listUnsubPost string
-				for _, h := range msg.Payload.Headers {
-					switch strings.ToLower(h.Name) {
-					case "from":
-						from = h.Value
-					case "subject":
-						subject = h.Value
-					case "date":
-						date = h.Value
-					case "list-unsubscribe":
-						listUnsub = h.Value
-					case "list-unsubscribe-post":
-						listUnsubPost = h.Value
-					}
-				}
-				rfc3339 := parseDateRFC3339(date)
-				results <- result{ref: model.MessageRef{
-					ID:                  msg.Id,
-					From:                util.NormalizeSender(from),
-					Subject:             subject,
-					DateRFC3339:         rfc3339,
-					ListUnsubscribe:     listUnsub,
-					ListUnsubscribePost: listUnsubPost,
-				}}
-			}
-		}()
+	s.report("fullscan-done", done)
+	return collectErr
+}
+
+// scan carries a listing of message IDs through FullScan or ExtendScan.
+type scan struct {
+	svc      *gmailv1.Service
+	store    MessageStore
+	opts     SyncOptions
+	progress func(SyncProgress)
+	done     int   // messages written
+	total    int   // Gmail's estimate of the listing, once known
+	fetchErr error // first metadata fetch that failed; the scan goes on
+}
+
+func (s *scan) report(phase string, done int) {
+	if s.progress != nil {
+		s.progress(SyncProgress{Phase: phase, Total: s.total, Done: done})
 	}
+}
 
-	// Step 4: page through list, queue jobs
-	go func() {
-		defer close(jobs)
-		pageToken := ""
-		first := true
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
-			call := list
-			if pageToken != "" {
-				call = call.PageToken(pageToken)
+// pages lists list page by page from pageToken, fetches the metadata of
+// each page concurrently and writes it to the store. With skipCached, the
+// messages already cached are not fetched again. After each page but the
+// last, checkpoint receives the next page token.
+func (s *scan) pages(ctx context.Context, list *gmailv1.UsersMessagesListCall, pageToken string, skipCached bool, checkpoint func(next string) error) error {
+	first := true
+	for {
+		s.report("listing", s.done)
+		resp, err := listPage(ctx, list, pageToken)
+		if err != nil {
+			return fmt.Errorf("list messages: %w", err)
+		}
+		// Emit total estimate once if available
+		if first {
+			first = false
+			if resp.ResultSizeEstimate > 0 {
+				s.total = int(resp.ResultSizeEstimate)
+				s.report("fullscan-start", s.done)
 			}
-			resp, err := call.Do()
-			if err != nil {
-				// surface as synthetic result error
-				results <- result{err: fmt.Errorf("list messages: %w", err)}
-				return
+		}
+
+		ids := make([]string, 0, len(resp.Messages))
+		for _, m := range resp.Messages {
+			ids = append(ids, m.Id)
+		}
+		if skipCached {
+			if ids, err = missingIDs(ctx, s.store, ids); err != nil {
+				return err
 			}
-			// Emit total estimate once if available
-			if first && progress != nil {
-				first = false
-				if resp.ResultSizeEstimate > 0 {
-					progress(SyncProgress{Phase: "fullscan-start", Total: int(resp.ResultSizeEstimate), Done: 0})
-				}
+		}
+		s.report("metadata", s.done)
+		pageStart := s.done
+		msgs, err := fetchMetadataBatch(ctx, s.svc, ids, s.opts.workers(16), func(fetched int) {
+			if fetched%metadataProgressStep == 0 {
+				s.report("metadata", pageStart+fetched)
 			}
-			for _, m := range resp.Messages {
-				jobs <- job{id: m.Id}
+		})
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// Keep what was fetched, but leave the checkpoint on the current
+			// page so the rest of it is fetched on resuming.
+			if err := upsertBatches(context.WithoutCancel(ctx), s.store, s.opts, msgs, nil); err != nil {
+				return err
 			}
-			if resp.NextPageToken == "" {
-				return
+			s.done += len(msgs)
+			return ctxErr
+		}
+		if err != nil && s.fetchErr == nil {
+			// Record first error but continue
+			s.fetchErr = err
+		}
+		if len(msgs) > 0 {
+			s.report("writing", pageStart)
+			err := upsertBatches(ctx, s.store, s.opts, msgs, func(n int) {
+				s.done = pageStart + n
+				s.report("writing", s.done)
+			})
+			if err != nil {
+				return err
 			}
-			pageToken = resp.NextPageToken
 		}
-	}()
 
-	// Step 5: collect into batches and write to DB
-	var collectErr error
-	const batch = 500
-	buf := make([]model.MessageRef, 0, batch)
-	done := 0
+		if resp.NextPageToken == "" {
+			return nil
+		}
+		pageToken = resp.NextPageToken
+		if err := checkpoint(pageToken); err != nil {
+			return err
+		}
+	}
+}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// ExtendScan caches the mail between since and the start of the scan
+// window, then moves the window's start to since; the zero since reaches
+// back to the oldest mail. Mail the retention settings would prune is
+// left out. Messages already cached are skipped, so an interrupted
+// extension is simply run again. It returns how many messages it added.
+func ExtendScan(ctx context.Context, svc *gmailv1.Service, store MessageStore, opts SyncOptions, since time.Time, progress func(SyncProgress)) (added int, err error) {
+	if store == nil {
+		return 0, fmt.Errorf("message store is required")
+	}
+	current, err := ScanSince(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+	if current.IsZero() || (!since.IsZero() && !since.Before(current)) {
+		return 0, nil // the window already reaches that far
+	}
+	slog.Info("extend scan start", "label", opts.Label, "since", since, "until", current)
+	defer logSync("extend scan", time.Now(), Usage(), &err)
+	progress = logPhases(progress)
 
-	for r := range results {
-		if r.err != nil {
-			// Record first error but continue
-			if collectErr == nil {
-				collectErr = r.err
-			}
-			continue
-		}
-		if r.ref.From == "" {
-			// skip unparsable sender
-			continue
+	from := since
+	cutoff, err := RetentionCutoff(ctx, store, opts.Retention, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if cutoff.After(from) {
+		from = cutoff
+	}
+	if from.Before(current) {
+		q := strings.TrimSpace(searchQuery(opts.Query, from) + fmt.Sprintf(" before:%d", current.Unix()))
+		list := listCall(svc, opts, q)
+		s := &scan{svc: svc, store: store, opts: opts, progress: progress}
+		if err := s.pages(ctx, list, "", true, func(string) error { return nil }); err != nil {
+			return s.done, err
 		}
-		buf = append(buf, r.ref)
-		done++
-		if progress != nil && done%50 == 0 {
-			progress(SyncProgress{Phase: "fullscan", Done: done})
+		if s.fetchErr != nil {
+			// Keep the window so the messages that failed are tried again.
+			return s.done, s.fetchErr
 		}
-		if len(buf) >= batch {
-			if err := store.UpsertMessages(ctx, buf); err != nil && collectErr == nil {
-				collectErr = err
-			}
-			if progress != nil {
-				progress(SyncProgress{Phase: "fullscan", Done: done})
-			}
-			buf = buf[:0]
+		added = s.done
+	}
+	return added, SetScanSince(ctx, store, since)
+}
+
+// listCall lists the messages of the sync scope that the Gmail search q
+// matches, or all of them for an empty q, opts.ListPageSize at a time.
+func listCall(svc *gmailv1.Service, opts SyncOptions, q string) *gmailv1.UsersMessagesListCall {
+	list := svc.Users.Messages.List("me").
+		IncludeSpamTrash(opts.includeSpamTrash()).
+		MaxResults(opts.listPageSize())
+	if scope := opts.scope(); scope != AllMail {
+		list = list.LabelIds(scope)
+	}
+	if q != "" {
+		list = list.Q(q)
+	}
+	return list
+}
+
+// searchQuery combines the user's Gmail search with a lower date bound,
+// either of which may be unset.
+func searchQuery(query string, after time.Time) string {
+	var parts []string
+	if query = strings.TrimSpace(query); query != "" {
+		parts = append(parts, "("+query+")")
+	}
+	if !after.IsZero() {
+		parts = append(parts, fmt.Sprintf("after:%d", after.Unix()))
+	}
+	return strings.Join(parts, " ")
+}
+
+// keepMatching returns the messages of msgs that opts.Query matches within
+// the sync scope. Gmail cannot test one message against a search, so this
+// lists the matches dated from a day before the oldest of msgs and keeps
+// those; the day allows for Date headers that differ from Gmail's own
+// receipt time.
+func keepMatching(ctx context.Context, svc *gmailv1.Service, opts SyncOptions, msgs []model.MessageRef) ([]model.MessageRef, error) {
+	if len(msgs) == 0 {
+		return msgs, nil
+	}
+	want := make(map[string]bool, len(msgs))
+	var oldest time.Time
+	for _, m := range msgs {
+		want[m.ID] = true
+		if t, err := time.Parse(time.RFC3339, m.DateRFC3339); err == nil && (oldest.IsZero() || t.Before(oldest)) {
+			oldest = t
 		}
 	}
-	if len(buf) > 0 {
-		if err := store.UpsertMessages(ctx, buf); err != nil && collectErr == nil {
-			collectErr = err
+	if !oldest.IsZero() {
+		oldest = oldest.Add(-24 * time.Hour)
+	}
+	list := listCall(svc, opts, searchQuery(opts.Query, oldest))
+	matched := make(map[string]bool)
+	pageToken := ""
+	for len(matched) < len(want) {
+		resp, err := listPage(ctx, list, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("list messages matching %q: %w", opts.Query, err)
 		}
-		if progress != nil {
-			progress(SyncProgress{Phase: "fullscan", Done: done})
+		for _, m := range resp.Messages {
+			if want[m.Id] {
+				matched[m.Id] = true
+			}
+		}
+		if resp.NextPageToken == "" {
+			break
 		}
+		pageToken = resp.NextPageToken
 	}
-
-	// Step 6: store historyId if we processed anything, even if some errors occurred
-	if done > 0 {
-		if err := store.SetLastHistoryID(ctx, hid); err != nil && collectErr == nil {
-			collectErr = err
+	out := msgs[:0]
+	for _, m := range msgs {
+		if matched[m.ID] {
+			out = append(out, m)
 		}
 	}
+	return out, nil
+}
 
-	if progress != nil {
-		progress(SyncProgress{Phase: "fullscan-done", Done: done})
+// missingIDs returns the subset of ids that are not yet in the store.
+func missingIDs(ctx context.Context, store MessageStore, ids []string) ([]string, error) {
+	existing, err := store.GetMessagesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
 	}
-	return collectErr
+	have := make(map[string]struct{}, len(existing))
+	for _, m := range existing {
+		have[m.ID] = struct{}{}
+	}
+	out := ids[:0]
+	for _, id := range ids {
+		if _, ok := have[id]; !ok {
+			out = append(out, id)
+		}
+	}
+	return out, nil
 }
 
 // SyncSinceHistory performs an incremental sync using Gmail History API starting from lastHistoryID.
-// It applies INBOX message additions/removals to the local cache and updates the stored historyId.
-func SyncSinceHistory(ctx context.Context, svc *gmailv1.Service, store MessageStore, lastHistoryID string, progress func(SyncProgress)) error {
+// It applies additions/removals within the label scope to the local cache, applies any
+// auto-label rules to the added messages, and updates the stored historyId.
+func SyncSinceHistory(ctx context.Context, svc *gmailv1.Service, store MessageStore, lastHistoryID string, opts SyncOptions, progress func(SyncProgress)) (err error) {
 	if store == nil {
 		return fmt.Errorf("message store is required")
 	}
 	if strings.TrimSpace(lastHistoryID) == "" {
 		return fmt.Errorf("lastHistoryID is required")
 	}
+	slog.Info("incremental sync start", "label", opts.Label, "history_id", lastHistoryID)
+	defer logSync("incremental sync", time.Now(), Usage(), &err)
+	progress = logPhases(progress)
 	user := "me"
 
 	addSet := make(map[string]struct{})
 	delSet := make(map[string]struct{})
+	// Latest label IDs of messages whose labels changed without moving them
+	// in or out of scope (e.g. read, starred).
+	labelSet := make(map[string][]string)
 
 	// Page through history records
 	startID, err := strconv.ParseUint(lastHistoryID, 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid lastHistoryID %q: %w", lastHistoryID, err)
 	}
+	scope := opts.scope()
 	call := svc.Users.History.List(user).StartHistoryId(startID).
-		MaxResults(500).LabelId("INBOX")
+		MaxResults(opts.listPageSize())
+	if scope != AllMail {
+		call = call.LabelId(scope)
+	}
 	var newestHistoryID string
 
 	for {
@@ -253,8 +689,13 @@ func SyncSinceHistory(ctx context.Context, svc *gmailv1.Service, store MessageSt
 			return ctx.Err()
 		default:
 		}
-		resp, err := call.Do()
+		resp, err := retry(ctx, func() (*gmailv1.ListHistoryResponse, error) {
+			return call.Context(ctx).Do()
+		})
 		if err != nil {
+			if isHistoryExpired(err) {
+				return fmt.Errorf("%w: %v", ErrHistoryExpired, err)
+			}
 			return fmt.Errorf("history list: %w", err)
 		}
 		if resp.HistoryId != 0 {
@@ -264,18 +705,17 @@ func SyncSinceHistory(ctx context.Context, svc *gmailv1.Service, store MessageSt
 			if h.Id != 0 {
 				newestHistoryID = fmt.Sprintf("%d", h.Id)
 			}
-			// Messages added to INBOX
+			// Messages added within the scope
 			for _, ma := range h.MessagesAdded {
 				if ma.Message == nil {
 					continue
 				}
-				// consider as add if message has INBOX
-				if hasLabel(ma.Message, "INBOX") {
+				if inScope(scope, ma.Message.LabelIds) {
 					addSet[ma.Message.Id] = struct{}{}
 					delete(delSet, ma.Message.Id)
 				}
 			}
-			// Messages removed from INBOX (treat as deletion from cache)
+			// Messages deleted outright
 			for _, md := range h.MessagesDeleted {
 				if md.Message == nil {
 					continue
@@ -288,16 +728,32 @@ func SyncSinceHistory(ctx context.Context, svc *gmailv1.Service, store MessageSt
 				if la.Message == nil {
 					continue
 				}
-				if contains(la.LabelIds, "INBOX") {
+				switch labelChangeEffect(scope, la.LabelIds, true) {
+				case 0:
+					if la.Message.LabelIds != nil {
+						labelSet[la.Message.Id] = la.Message.LabelIds
+					}
+				case 1:
 					addSet[la.Message.Id] = struct{}{}
 					delete(delSet, la.Message.Id)
+				case -1:
+					delSet[la.Message.Id] = struct{}{}
+					delete(addSet, la.Message.Id)
 				}
 			}
 			for _, lr := range h.LabelsRemoved {
 				if lr.Message == nil {
 					continue
 				}
-				if contains(lr.LabelIds, "INBOX") {
+				switch labelChangeEffect(scope, lr.LabelIds, false) {
+				case 0:
+					if lr.Message.LabelIds != nil {
+						labelSet[lr.Message.Id] = lr.Message.LabelIds
+					}
+				case 1:
+					addSet[lr.Message.Id] = struct{}{}
+					delete(delSet, lr.Message.Id)
+				case -1:
 					delSet[lr.Message.Id] = struct{}{}
 					delete(addSet, lr.Message.Id)
 				}
@@ -318,19 +774,51 @@ func SyncSinceHistory(ctx context.Context, svc *gmailv1.Service, store MessageSt
 
 	// Fetch metadata for adds
 	addIDs := keys(addSet)
+	var labelErr, blockErr error
 	if len(addIDs) > 0 {
-		msgs, err := fetchMetadataBatch(ctx, svc, addIDs)
+		msgs, err := fetchMetadataBatch(ctx, svc, addIDs, opts.workers(8), nil)
 		if err != nil {
 			return err
 		}
-		if err := store.UpsertMessages(ctx, msgs); err != nil {
+		// A triage failure must not lose the sync cursor; the messages are
+		// cached as usual and the error reported at the end.
+		if opts.Triage != nil {
+			msgs, blockErr = opts.Triage.Arrived(ctx, svc, store, msgs)
+		}
+		if opts.Query != "" {
+			if msgs, err = keepMatching(ctx, svc, opts, msgs); err != nil {
+				return err
+			}
+		}
+		if err := upsertBatches(ctx, store, opts, msgs, nil); err != nil {
 			return err
 		}
+		if opts.NewMessages != nil {
+			opts.NewMessages(msgs)
+		}
+		if opts.Triage != nil {
+			labelErr = opts.Triage.Cached(ctx, svc, msgs)
+		}
 		if progress != nil {
 			progress(SyncProgress{Phase: "history", Total: total, Done: len(addIDs)})
 		}
 	}
 
+	// Refresh labels of messages that stayed in scope. Added messages were
+	// just fetched with their current labels and deleted ones are gone.
+	if ls, ok := store.(LabelStore); ok {
+		for id := range labelSet {
+			_, added := addSet[id]
+			_, deleted := delSet[id]
+			if added || deleted {
+				delete(labelSet, id)
+			}
+		}
+		if err := ls.UpdateLabels(ctx, labelSet); err != nil {
+			return err
+		}
+	}
+
 	// Apply deletions
 	if len(delSet) > 0 {
 		if err := store.DeleteMessages(ctx, keys(delSet)); err != nil {
@@ -353,71 +841,77 @@ func SyncSinceHistory(ctx context.Context, svc *gmailv1.Service, store MessageSt
 	if err := store.SetLastHistoryID(ctx, newestHistoryID); err != nil {
 		return err
 	}
+	if err := MarkSynced(ctx, store); err != nil {
+		return err
+	}
 
 	if progress != nil {
 		progress(SyncProgress{Phase: "history-done", Total: total, Done: total})
 	}
-	return nil
+	return errors.Join(blockErr, labelErr)
 }
 
-func fetchMetadataBatch(ctx context.Context, svc *gmailv1.Service, ids []string) ([]model.MessageRef, error) {
-	user := "me"
-	type job struct{ id string }
+// fetchMetadataBatch fetches the metadata of ids with workerCount concurrent
+// requests. Each request is a batch of up to metadataBatchSize gets, or a
+// single get when svc is not set up for batching (see EnableBatch). If
+// fetched is non-nil, it is called from the workers with the running count
+// after each request.
+func fetchMetadataBatch(ctx context.Context, svc *gmailv1.Service, ids []string, workerCount int, fetched func(n int)) ([]model.MessageRef, error) {
 	type result struct {
 		ref model.MessageRef
 		err error
 	}
-	jobs := make(chan job, len(ids))
-	results := make(chan result, len(ids))
+	hc := batchClient(svc)
+	size := 1
+	if hc != nil {
+		size = metadataBatchSize
+	}
+	var chunks [][]string
+	for start := 0; start < len(ids); start += size {
+		chunks = append(chunks, ids[start:min(start+size, len(ids))])
+	}
+	jobs := make(chan []string, len(chunks))
+	results := make(chan result, len(ids)+len(chunks))
+	var count atomic.Int64
 
-	const workerCount = 8
 	var wg sync.WaitGroup
 	wg.Add(workerCount)
 	for i := 0; i < workerCount; i++ {
 		go func() {
 			defer wg.Done()
-			for j := range jobs {
+			for chunk := range jobs {
 				select {
 				case <-ctx.Done():
 					return
 				default:
 				}
-				msg, err := svc.Users.Messages.Get(user, j.id).
-					Format("metadata").
-					MetadataHeaders("From", "Subject", "Date", "List-Unsubscribe", "List-Unsubscribe-Post").
-					Do()
+				var msgs []*gmailv1.Message
+				var err error
+				if hc != nil {
+					msgs, err = batchGetMetadata(ctx, svc, hc, chunk)
+				} else {
+					var msg *gmailv1.Message
+					if msg, err = getMetadata(ctx, svc, chunk[0]); err == nil {
+						msgs = append(msgs, msg)
+					}
+				}
+				if fetched != nil {
+					fetched(int(count.Add(int64(len(chunk)))))
+				}
+				for _, msg := range msgs {
+					ref := refFromMetadata(msg)
+					ref.FromName = util.SenderName(ref.From)
+					ref.From = util.NormalizeSender(ref.From)
+					results <- result{ref: ref}
+				}
 				if err != nil {
 					results <- result{err: err}
-					continue
-				}
-				var from, subject, date, listUnsub, listUnsubPost string
-				for _, h := range msg.Payload.Headers {
-					switch strings.ToLower(h.Name) {
-					case "from":
-						from = h.Value
-					case "subject":
-						subject = h.Value
-					case "date":
-						date = h.Value
-					case "list-unsubscribe":
-						listUnsub = h.Value
-					case "list-unsubscribe-post":
-						listUnsubPost = h.Value
-					}
 				}
-				results <- result{ref: model.MessageRef{
-					ID:                  msg.Id,
-					From:                util.NormalizeSender(from),
-					Subject:             subject,
-					DateRFC3339:         parseDateRFC3339(date),
-					ListUnsubscribe:     listUnsub,
-					ListUnsubscribePost: listUnsubPost,
-				}}
 			}
 		}()
 	}
-	for _, id := range ids {
-		jobs <- job{id: id}
+	for _, chunk := range chunks {
+		jobs <- chunk
 	}
 	close(jobs)
 	wg.Wait()
@@ -435,19 +929,16 @@ func fetchMetadataBatch(ctx context.Context, svc *gmailv1.Service, ids []string)
 		}
 		out = append(out, r.ref)
 	}
+	if firstErr == nil {
+		// Workers stop early on cancellation, leaving messages unfetched.
+		firstErr = ctx.Err()
+	}
 	if firstErr != nil {
 		return out, firstErr
 	}
 	return out, nil
 }
 
-func hasLabel(m *gmailv1.Message, id string) bool {
-	if m == nil {
-		return false
-	}
-	return contains(m.LabelIds, id)
-}
-
 func contains[T comparable](arr []T, v T) bool {
 	for _, x := range arr {
 		if x == v {
@@ -466,9 +957,22 @@ func keys(m map[string]struct{}) []string {
 	return out
 }
 
+// AccountEmail returns the address of the authenticated mailbox.
+func AccountEmail(ctx context.Context, svc *gmailv1.Service) (string, error) {
+	profile, err := retry(ctx, func() (*gmailv1.Profile, error) {
+		return svc.Users.GetProfile("me").Context(ctx).Do()
+	})
+	if err != nil {
+		return "", err
+	}
+	return profile.EmailAddress, nil
+}
+
 // currentHistoryID returns the current mailbox largest historyId as a string.
 func currentHistoryID(ctx context.Context, svc *gmailv1.Service) (string, error) {
-	profile, err := svc.Users.GetProfile("me").Do()
+	profile, err := retry(ctx, func() (*gmailv1.Profile, error) {
+		return svc.Users.GetProfile("me").Context(ctx).Do()
+	})
 	if err != nil {
 		return "", err
 	}
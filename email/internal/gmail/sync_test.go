@@ -0,0 +1,154 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestSearchQuery(t *testing.T) {
+	after := time.Unix(1700000000, 0)
+	tests := []struct {
+		query string
+		after time.Time
+		want  string
+	}{
+		{"", time.Time{}, ""},
+		{"", after, "after:1700000000"},
+		{" from:a OR from:b ", time.Time{}, "(from:a OR from:b)"},
+		{"category:promotions", after, "(category:promotions) after:1700000000"},
+	}
+	for _, tc := range tests {
+		if got := searchQuery(tc.query, tc.after); got != tc.want {
+			t.Errorf("searchQuery(%q, %v) = %q; want %q", tc.query, tc.after, got, tc.want)
+		}
+	}
+}
+
+func TestKeepMatching(t *testing.T) {
+	var gotQ, gotLabel string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		gotQ, gotLabel = r.URL.Query().Get("q"), r.URL.Query().Get("labelIds")
+		if r.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(w, `{"messages":[{"id":"x"},{"id":"a"}],"nextPageToken":"p2"}`)
+			return
+		}
+		fmt.Fprint(w, `{"messages":[{"id":"c"}]}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []model.MessageRef{
+		{ID: "a", DateRFC3339: "2024-03-02T10:00:00Z"},
+		{ID: "b", DateRFC3339: "2024-03-05T10:00:00Z"},
+		{ID: "c"},
+	}
+	opts := SyncOptions{Label: "CATEGORY_PROMOTIONS", Query: "larger:1M"}
+	got, err := keepMatching(ctx, svc, opts, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "c" {
+		t.Fatalf("keepMatching = %+v", got)
+	}
+	after := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC).Unix()
+	if want := fmt.Sprintf("(larger:1M) after:%d", after); gotQ != want || gotLabel != "CATEGORY_PROMOTIONS" {
+		t.Fatalf("listed q=%q label=%q; want q=%q", gotQ, gotLabel, want)
+	}
+}
+
+func TestSyncOptionsValidate(t *testing.T) {
+	for _, opts := range []SyncOptions{
+		{},
+		{Workers: 1, ListPageSize: 50, UpsertBatch: 100},
+		{Workers: MaxWorkers, ListPageSize: MaxListPageSize, UpsertBatch: MaxUpsertBatch},
+	} {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", opts, err)
+		}
+	}
+	for _, opts := range []SyncOptions{
+		{Workers: -1},
+		{Workers: MaxWorkers + 1},
+		{ListPageSize: 501},
+		{UpsertBatch: -5},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted out-of-bounds tuning", opts)
+		}
+	}
+}
+
+// upsertRecorder records the size of each write.
+type upsertRecorder struct {
+	MessageStore
+	sizes []int
+}
+
+func (s *upsertRecorder) UpsertMessages(_ context.Context, msgs []model.MessageRef) error {
+	s.sizes = append(s.sizes, len(msgs))
+	return nil
+}
+
+func TestUpsertBatches(t *testing.T) {
+	msgs := make([]model.MessageRef, 250)
+	st := &upsertRecorder{}
+	var written []int
+	if err := upsertBatches(context.Background(), st, SyncOptions{UpsertBatch: 100}, msgs, func(n int) { written = append(written, n) }); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(st.sizes) != "[100 100 50]" || fmt.Sprint(written) != "[100 200 250]" {
+		t.Errorf("wrote %v, reporting %v; want [100 100 50] and [100 200 250]", st.sizes, written)
+	}
+}
+
+func TestFullScanCancelledKeepsFetched(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/gmail/v1/users/me/profile":
+			fmt.Fprint(w, `{"historyId":"42"}`)
+		case "/gmail/v1/users/me/messages":
+			fmt.Fprint(w, `{"messages":[{"id":"m1"},{"id":"m2"}],"nextPageToken":"p2"}`)
+		case "/gmail/v1/users/me/messages/m2":
+			// The user cancels while the second message is fetched.
+			cancel()
+			<-r.Context().Done()
+		default:
+			fmt.Fprint(w, `{"id":"m1","payload":{"headers":[{"name":"From","value":"a@b.example"}]}}`)
+		}
+	}))
+	defer srv.Close()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := &scanStore{metadataStore: metadataStore{meta: map[string]string{}}, msgs: map[string]model.MessageRef{}}
+	err = FullScan(ctx, svc, st, SyncOptions{Workers: 1}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("FullScan = %v; want context.Canceled", err)
+	}
+	if _, ok := st.msgs["m1"]; !ok || len(st.msgs) != 1 {
+		t.Errorf("cached %v; want m1, fetched before the cancel", st.msgs)
+	}
+	if st.meta[metaScanHistoryID] != "42" || st.meta[metaScanPageToken] != "" {
+		t.Errorf("scan state = %v; want it kept on the first page to resume", st.meta)
+	}
+}
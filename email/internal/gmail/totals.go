@@ -0,0 +1,26 @@
+package gmail
+
+import (
+	"context"
+
+	"chuckterm/internal/model"
+)
+
+// CacheTotals counts the cached groups, messages and unread messages. Stores
+// that page their groups total them themselves; others are read in full.
+func CacheTotals(ctx context.Context, store MessageStore) (model.GroupTotals, error) {
+	if ps, ok := store.(GroupPageStore); ok {
+		return ps.GroupTotals(ctx)
+	}
+	msgs, err := store.LoadAllMessages(ctx)
+	if err != nil {
+		return model.GroupTotals{}, err
+	}
+	t := model.GroupTotals{Groups: len(AggregateBySenderSubject(msgs)), Messages: len(msgs)}
+	for _, m := range msgs {
+		if m.HasLabel("UNREAD") {
+			t.Unread++
+		}
+	}
+	return t, nil
+}
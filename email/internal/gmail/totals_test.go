@@ -0,0 +1,31 @@
+package gmail
+
+import (
+	"context"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+// messagesStore holds a fixed set of messages; it has none of the other
+// methods of a MessageStore.
+type messagesStore struct {
+	MessageStore
+	msgs []model.MessageRef
+}
+
+func (s *messagesStore) LoadAllMessages(context.Context) ([]model.MessageRef, error) {
+	return s.msgs, nil
+}
+
+func TestCacheTotals(t *testing.T) {
+	st := &messagesStore{msgs: []model.MessageRef{
+		{ID: "m1", From: "news@shop.example", Subject: "Deals", LabelIDs: []string{"INBOX", "UNREAD"}},
+		{ID: "m2", From: "news@shop.example", Subject: "Deals", LabelIDs: []string{"INBOX"}},
+		{ID: "m3", From: "ann@x.example", Subject: "Lunch", LabelIDs: []string{"INBOX", "UNREAD"}},
+	}}
+	got, err := CacheTotals(context.Background(), st)
+	if want := (model.GroupTotals{Groups: 2, Messages: 3, Unread: 2}); err != nil || got != want {
+		t.Errorf("CacheTotals = %+v, %v; want %+v", got, err, want)
+	}
+}
@@ -0,0 +1,157 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/util"
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// TrashStore is implemented by stores that remember the messages moved to
+// the trash, so they can be restored after they have left the cache.
+type TrashStore interface {
+	SaveTrashed(ctx context.Context, msgs []model.Trashed) error
+	DeleteTrashed(ctx context.Context, ids []string) error
+	// LoadTrashed returns the remembered messages, most recently trashed
+	// first.
+	LoadTrashed(ctx context.Context) ([]model.Trashed, error)
+}
+
+// TrashRetention is how long Gmail keeps mail in the trash before deleting
+// it for good.
+const TrashRetention = 30 * 24 * time.Hour
+
+// trashListLimit is how many messages ListTrash reads from Gmail's trash.
+const trashListLimit = 500
+
+// RememberTrashed records msgs as trashed at at. Stores without a trash log
+// ignore it.
+func RememberTrashed(ctx context.Context, store MessageStore, msgs []model.MessageRef, at time.Time) error {
+	ts, ok := store.(TrashStore)
+	if !ok || len(msgs) == 0 {
+		return nil
+	}
+	trashed := make([]model.Trashed, 0, len(msgs))
+	for _, m := range msgs {
+		trashed = append(trashed, model.Trashed{ID: m.ID, From: m.From, Subject: m.Subject, Date: m.DateRFC3339, At: at})
+	}
+	return ts.SaveTrashed(ctx, trashed)
+}
+
+// LoadTrashed returns the remembered trashed messages, most recently trashed
+// first, or none when the store keeps no trash log. Messages trashed longer
+// than TrashRetention before now are gone from Gmail and are forgotten.
+func LoadTrashed(ctx context.Context, store MessageStore, now time.Time) ([]model.Trashed, error) {
+	ts, ok := store.(TrashStore)
+	if !ok {
+		return nil, nil
+	}
+	all, err := ts.LoadTrashed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := now.Add(-TrashRetention)
+	var kept []model.Trashed
+	var expired []string
+	for _, t := range all {
+		if t.At.Before(cutoff) {
+			expired = append(expired, t.ID)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+	if len(expired) > 0 {
+		if err := ts.DeleteTrashed(ctx, expired); err != nil {
+			return kept, err
+		}
+	}
+	return kept, nil
+}
+
+// ListTrash returns the newest messages in Gmail's trash, up to
+// trashListLimit of them, whoever trashed them. Their At is zero, as Gmail
+// does not say when a message was trashed.
+func ListTrash(ctx context.Context, svc *gmailv1.Service) ([]model.Trashed, error) {
+	resp, err := retry(ctx, func() (*gmailv1.ListMessagesResponse, error) {
+		return svc.Users.Messages.List("me").LabelIds("TRASH").IncludeSpamTrash(true).
+			MaxResults(trashListLimit).Context(ctx).Do()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	ids := make([]string, len(resp.Messages))
+	for i, m := range resp.Messages {
+		ids[i] = m.Id
+	}
+	refs, err := fetchMetadataBatch(ctx, svc, ids, 8, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	out := make([]model.Trashed, len(refs))
+	for i, r := range refs {
+		out[i] = model.Trashed{ID: r.ID, From: r.From, Subject: r.Subject, Date: r.DateRFC3339}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Date > out[j].Date })
+	return out, nil
+}
+
+// UntrashMessages takes ids out of the trash, back under the labels they
+// had, caches those that belong to the cache's scope again and forgets them
+// in the trash log. A message Gmail has deleted for good is only forgotten.
+// It returns how many messages it restored; on an error the rest are left
+// in the trash.
+func UntrashMessages(ctx context.Context, svc *gmailv1.Service, store MessageStore, ids []string) (int, error) {
+	if SkipDryRun(ctx, "restore %s from the trash", DescribeIDs(ids)) {
+		return 0, ErrDryRun
+	}
+	scope, err := LabelScope(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+	ts, _ := store.(TrashStore)
+	n := 0
+	for _, id := range remoteIDs(ids) {
+		_, err := retry(ctx, func() (*gmailv1.Message, error) {
+			return svc.Users.Messages.Untrash("me", id).Context(ctx).Do()
+		})
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == 404 {
+			err = nil
+		} else if err == nil {
+			err = recacheUntrashed(ctx, svc, store, scope, id)
+			n++
+		}
+		if err == nil && ts != nil {
+			err = ts.DeleteTrashed(ctx, []string{id})
+		}
+		if err != nil {
+			err = fmt.Errorf("untrash message %s: %w", id, err)
+			logChange("untrash", n, err)
+			return n, err
+		}
+	}
+	logChange("untrash", n, nil)
+	return n, nil
+}
+
+// recacheUntrashed puts the restored message id back in the cache when it
+// belongs to scope.
+func recacheUntrashed(ctx context.Context, svc *gmailv1.Service, store MessageStore, scope, id string) error {
+	msg, err := getMetadata(ctx, svc, id)
+	if err != nil {
+		return err
+	}
+	if !inScope(scope, msg.LabelIds) {
+		return nil
+	}
+	ref := refFromMetadata(msg)
+	ref.FromName = util.SenderName(ref.From)
+	ref.From = util.NormalizeSender(ref.From)
+	return store.UpsertMessages(ctx, []model.MessageRef{ref})
+}
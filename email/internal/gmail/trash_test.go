@@ -0,0 +1,93 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// trashStore is a scanStore with a trash log.
+type trashStore struct {
+	scanStore
+	trashed map[string]model.Trashed
+}
+
+func (s *trashStore) SaveTrashed(_ context.Context, msgs []model.Trashed) error {
+	for _, t := range msgs {
+		s.trashed[t.ID] = t
+	}
+	return nil
+}
+
+func (s *trashStore) DeleteTrashed(_ context.Context, ids []string) error {
+	for _, id := range ids {
+		delete(s.trashed, id)
+	}
+	return nil
+}
+
+func (s *trashStore) LoadTrashed(context.Context) ([]model.Trashed, error) {
+	var out []model.Trashed
+	for _, t := range s.trashed {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func TestUntrashMessages(t *testing.T) {
+	labels := map[string]string{"inbox": "INBOX", "archived": "CATEGORY_UPDATES"}
+	var untrashed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := path.Base(r.URL.Path)
+		if r.Method == http.MethodPost {
+			id = path.Base(path.Dir(r.URL.Path))
+			untrashed = append(untrashed, id)
+		}
+		label, ok := labels[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":404,"message":"Requested entity was not found."}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%q,"labelIds":[%q],"payload":{"headers":[{"name":"From","value":"News <news@example.com>"}]}}`, id, label)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &trashStore{
+		scanStore: scanStore{metadataStore: metadataStore{meta: map[string]string{}}, msgs: map[string]model.MessageRef{}},
+		trashed:   map[string]model.Trashed{},
+	}
+	ids := []string{"inbox", "archived", "gone"}
+	for _, id := range ids {
+		st.trashed[id] = model.Trashed{ID: id, At: time.Now()}
+	}
+
+	n, err := UntrashMessages(ctx, svc, st, ids)
+	if err != nil || n != 2 {
+		t.Fatalf("UntrashMessages = %d, %v; want 2 restored", n, err)
+	}
+	if !slices.Equal(untrashed, ids) {
+		t.Errorf("untrashed %v; want %v", untrashed, ids)
+	}
+	// Only the inbox message belongs to the default scope.
+	if _, ok := st.msgs["inbox"]; !ok || len(st.msgs) != 1 || st.msgs["inbox"].From != "news@example.com" {
+		t.Errorf("cached %+v; want only the inbox message", st.msgs)
+	}
+	if len(st.trashed) != 0 {
+		t.Errorf("trash log keeps %+v", st.trashed)
+	}
+}
@@ -1,10 +1,25 @@
 package gmail
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
 )
 
 // OpenUnsubscribeURL parses the List-Unsubscribe header and opens the first
@@ -18,29 +33,278 @@ func OpenUnsubscribeURL(rawHeader string) error {
 	return OpenBrowser(url)
 }
 
+// BrowseUnsubscribe opens an unsubscribe page in the browser, or under a
+// dry run only describes doing so and returns ErrDryRun.
+func BrowseUnsubscribe(ctx context.Context, url string) error {
+	if SkipDryRun(ctx, "open unsubscribe page %s", url) {
+		return ErrDryRun
+	}
+	return OpenBrowser(url)
+}
+
 func OpenBrowser(url string) error {
+	// Validate URL scheme to prevent command injection
+	lower := strings.ToLower(url)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return fmt.Errorf("refusing to open non-HTTP URL: %s", url)
+	}
+	return systemOpen(url)
+}
+
+// systemOpen hands target, a URL or a file path, to the platform's opener,
+// which picks the application for it.
+func systemOpen(target string) error {
 	var cmd string
 	var args []string
 
 	switch runtime.GOOS {
 	case "darwin":
 		cmd = "open"
-		args = []string{url}
+		args = []string{target}
 	case "linux":
 		cmd = "xdg-open"
-		args = []string{url}
+		args = []string{target}
 	case "windows":
 		cmd = "rundll32"
-		args = []string{"url.dll,FileProtocolHandler", url}
+		args = []string{"url.dll,FileProtocolHandler", target}
 	default:
 		return fmt.Errorf("unsupported platform %s", runtime.GOOS)
 	}
+	return exec.Command(cmd, args...).Start()
+}
 
-	// Validate URL scheme to prevent command injection
-	lower := strings.ToLower(url)
-	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
-		return fmt.Errorf("refusing to open non-HTTP URL: %s", url)
+// IsOneClick reports whether a List-Unsubscribe-Post header advertises
+// RFC 8058 one-click unsubscription.
+func IsOneClick(postHeader string) bool {
+	return strings.Contains(strings.ToLower(postHeader), "list-unsubscribe=one-click")
+}
+
+// unsubscribeClient performs one-click POSTs; senders get a bounded time to answer.
+var unsubscribeClient = &http.Client{Timeout: 20 * time.Second, Transport: proxyTransport}
+
+// SetUnsubscribeClient replaces the client one-click POSTs are sent with.
+// Demo mode uses it to trust its local server's certificate.
+func SetUnsubscribeClient(c *http.Client) { unsubscribeClient = c }
+
+// OneClickUnsubscribe sends the RFC 8058 one-click POST to url.
+func OneClickUnsubscribe(ctx context.Context, url string) (err error) {
+	if !strings.HasPrefix(strings.ToLower(url), "https://") {
+		return fmt.Errorf("one-click unsubscribe requires an HTTPS URL")
+	}
+	if SkipDryRun(ctx, "one-click unsubscribe from %s", url) {
+		return ErrDryRun
 	}
+	defer func() { logUnsubscribe(UnsubscribeOneClick, url, err) }()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := unsubscribeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sender answered %s", resp.Status)
+	}
+	return nil
+}
 
-	return exec.Command(cmd, args...).Start()
+// UnsubscribeMethod says how a sender was (or will be) unsubscribed.
+type UnsubscribeMethod string
+
+const (
+	UnsubscribeOneClick UnsubscribeMethod = "one-click" // POST sent and accepted
+	UnsubscribeBrowser  UnsubscribeMethod = "browser"   // queued to open in the browser
+	UnsubscribeMailto   UnsubscribeMethod = "mailto"    // unsubscribe email sent
+)
+
+// UnsubscribeStore is implemented by stores that remember which senders
+// were unsubscribed from.
+type UnsubscribeStore interface {
+	RecordUnsubscribe(ctx context.Context, u model.Unsubscription) error
+	// LoadUnsubscribes returns the latest unsubscribe from each sender.
+	LoadUnsubscribes(ctx context.Context) ([]model.Unsubscription, error)
+}
+
+// RecordUnsubscribe notes that sender was unsubscribed from by method
+// through url, now, in the store's unsubscribes and its action history.
+// Stores without either ignore it.
+func RecordUnsubscribe(ctx context.Context, store MessageStore, sender string, method UnsubscribeMethod, url string) error {
+	now := time.Now()
+	var err error
+	if us, ok := store.(UnsubscribeStore); ok {
+		err = us.RecordUnsubscribe(ctx, model.Unsubscription{Sender: sender, Time: now, Method: string(method), URL: url})
+	}
+	return errors.Join(err, RecordAction(ctx, store, model.Action{Time: now, Kind: "unsubscribe", Sender: sender}))
+}
+
+// LoadUnsubscribes returns the store's unsubscribes by sender; stores
+// without them yield none.
+func LoadUnsubscribes(ctx context.Context, store MessageStore) (map[string]model.Unsubscription, error) {
+	us, ok := store.(UnsubscribeStore)
+	if !ok {
+		return nil, nil
+	}
+	list, err := us.LoadUnsubscribes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load unsubscribes: %w", err)
+	}
+	out := make(map[string]model.Unsubscription, len(list))
+	for _, u := range list {
+		out[u.Sender] = u
+	}
+	return out, nil
+}
+
+// ApplyUnsubscribes sets Unsubscribed on the groups of senders in unsubs.
+func ApplyUnsubscribes(groups []model.SenderGroup, unsubs map[string]model.Unsubscription) {
+	for i := range groups {
+		groups[i].Unsubscribed = nil
+		if u, ok := unsubs[groups[i].Email]; ok {
+			groups[i].Unsubscribed = &u
+		}
+	}
+}
+
+// MailtoUnsubscribe sends the unsubscribe email a mailto: List-Unsubscribe
+// link asks for, with its subject and body, or "unsubscribe" for either when
+// the link gives none. svc is nil for accounts of other providers, which
+// cannot send it.
+func MailtoUnsubscribe(ctx context.Context, svc *gmailv1.Service, link string) error {
+	u, err := url.Parse(link)
+	if err != nil || !strings.EqualFold(u.Scheme, "mailto") {
+		return fmt.Errorf("%q is not a mailto: link", link)
+	}
+	to, err := mail.ParseAddress(u.Opaque)
+	if err != nil {
+		return fmt.Errorf("mailto address %q: %w", u.Opaque, err)
+	}
+	query := u.Query()
+	subject, body := query.Get("subject"), query.Get("body")
+	if subject == "" {
+		subject = "unsubscribe"
+	}
+	if body == "" {
+		body = "unsubscribe"
+	}
+	if SkipDryRun(ctx, "send unsubscribe email to %s", to.Address) {
+		return ErrDryRun
+	}
+	if svc == nil {
+		return errors.New("sending unsubscribe emails needs a Gmail account")
+	}
+	var raw bytes.Buffer
+	fmt.Fprintf(&raw, "To: %s\r\n", to.Address)
+	fmt.Fprintf(&raw, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	raw.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	raw.WriteString(strings.ReplaceAll(body, "\n", "\r\n") + "\r\n")
+	msg := &gmailv1.Message{Raw: base64.URLEncoding.EncodeToString(raw.Bytes())}
+	// Not retried: a retry after a lost response would send it twice.
+	if _, err := svc.Users.Messages.Send("me", msg).Context(ctx).Do(); err != nil {
+		err = fmt.Errorf("send unsubscribe email to %s: %w", to.Address, err)
+		logUnsubscribe(UnsubscribeMailto, link, err)
+		return err
+	}
+	logUnsubscribe(UnsubscribeMailto, link, nil)
+	return nil
+}
+
+// UnsubscribeOutcome is the result of unsubscribing from one sender.
+type UnsubscribeOutcome struct {
+	Sender string
+	URL    string
+	Method UnsubscribeMethod
+	// Err is why one-click failed when the sender fell back to the browser.
+	Err error
+}
+
+// bulkUnsubscribeWorkers bounds concurrent one-click POSTs.
+const bulkUnsubscribeWorkers = 4
+
+// BulkUnsubscribe unsubscribes from every group with an unsubscribe URL,
+// once per distinct URL. Groups that advertise one-click get a POST; the rest,
+// and any whose POST fails, are returned with UnsubscribeBrowser so the caller
+// can open them. progress is called after each sender. Outcomes keep the order
+// of groups. Under a dry run no POST is sent, and senders that would get one
+// are returned with UnsubscribeOneClick and ErrDryRun.
+func BulkUnsubscribe(ctx context.Context, groups []model.SenderGroup, progress func(done, total int)) []UnsubscribeOutcome {
+	var outcomes []UnsubscribeOutcome
+	var oneClick []bool
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		if g.UnsubscribeURL == "" || seen[g.UnsubscribeURL] {
+			continue
+		}
+		seen[g.UnsubscribeURL] = true
+		outcomes = append(outcomes, UnsubscribeOutcome{Sender: g.Email, URL: g.UnsubscribeURL, Method: UnsubscribeBrowser})
+		oneClick = append(oneClick, g.UnsubscribeOneClick)
+	}
+
+	var mu sync.Mutex
+	done := 0
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range bulkUnsubscribeWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if oneClick[i] {
+					if err := OneClickUnsubscribe(ctx, outcomes[i].URL); errors.Is(err, ErrDryRun) {
+						outcomes[i].Method, outcomes[i].Err = UnsubscribeOneClick, err
+					} else if err != nil {
+						outcomes[i].Err = err
+					} else {
+						outcomes[i].Method = UnsubscribeOneClick
+					}
+				}
+				mu.Lock()
+				done++
+				if progress != nil {
+					progress(done, len(outcomes))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range outcomes {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return outcomes
+}
+
+// UnsubscribeGroup unsubscribes from one group the best way its links allow:
+// a one-click POST, else opening its URL with open, else sending the email
+// its mailto: link asks for. A failed POST falls back to open and is kept in
+// the outcome's Err; the error returned is why the last method tried failed.
+// Under a dry run the outcome says which method would be used, and the error
+// is ErrDryRun.
+func UnsubscribeGroup(ctx context.Context, svc *gmailv1.Service, g model.SenderGroup, open func(url string) error) (UnsubscribeOutcome, error) {
+	out := UnsubscribeOutcome{Sender: g.Email, URL: g.UnsubscribeURL}
+	switch {
+	case g.UnsubscribeURL != "":
+		if g.UnsubscribeOneClick {
+			if out.Err = OneClickUnsubscribe(ctx, g.UnsubscribeURL); out.Err == nil || errors.Is(out.Err, ErrDryRun) {
+				out.Method = UnsubscribeOneClick
+				return out, out.Err
+			}
+		}
+		out.Method = UnsubscribeBrowser
+		if SkipDryRun(ctx, "open unsubscribe page %s", g.UnsubscribeURL) {
+			return out, ErrDryRun
+		}
+		return out, open(g.UnsubscribeURL)
+	case g.UnsubscribeMailto != "":
+		out.URL, out.Method = g.UnsubscribeMailto, UnsubscribeMailto
+		return out, MailtoUnsubscribe(ctx, svc, g.UnsubscribeMailto)
+	}
+	return out, fmt.Errorf("%s has no unsubscribe link", g.Email)
 }
@@ -0,0 +1,105 @@
+package gmail
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestIsOneClick(t *testing.T) {
+	if !IsOneClick("List-Unsubscribe=One-Click") {
+		t.Error("expected one-click")
+	}
+	if IsOneClick("") {
+		t.Error("empty header is not one-click")
+	}
+}
+
+func TestBulkUnsubscribe(t *testing.T) {
+	var mu sync.Mutex
+	posts := map[string]string{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		posts[r.URL.Path] = string(body)
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	old := unsubscribeClient
+	unsubscribeClient = srv.Client()
+	defer func() { unsubscribeClient = old }()
+
+	groups := []model.SenderGroup{
+		{Email: "a@x.com", Subject: "1", UnsubscribeURL: srv.URL + "/a", UnsubscribeOneClick: true},
+		{Email: "a@x.com", Subject: "2", UnsubscribeURL: srv.URL + "/a", UnsubscribeOneClick: true}, // same URL
+		{Email: "b@x.com", UnsubscribeURL: srv.URL + "/broken", UnsubscribeOneClick: true},
+		{Email: "c@x.com", UnsubscribeURL: "https://c.example.com/u"},
+		{Email: "d@x.com"},
+	}
+	calls := 0
+	out := BulkUnsubscribe(context.Background(), groups, func(done, total int) { calls++ })
+
+	if len(out) != 3 || calls != 3 {
+		t.Fatalf("outcomes=%d progress calls=%d, want 3 and 3", len(out), calls)
+	}
+	want := []struct {
+		sender string
+		method UnsubscribeMethod
+		failed bool
+	}{
+		{"a@x.com", UnsubscribeOneClick, false},
+		{"b@x.com", UnsubscribeBrowser, true},
+		{"c@x.com", UnsubscribeBrowser, false},
+	}
+	for i, w := range want {
+		if out[i].Sender != w.sender || out[i].Method != w.method || (out[i].Err != nil) != w.failed {
+			t.Errorf("outcome %d = %+v, want %+v", i, out[i], w)
+		}
+	}
+	if posts["/a"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("one-click body = %q", posts["/a"])
+	}
+}
+
+func TestUnsubscribeGroup(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	old := unsubscribeClient
+	unsubscribeClient = srv.Client()
+	defer func() { unsubscribeClient = old }()
+
+	var opened []string
+	open := func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+	ctx := context.Background()
+
+	out, err := UnsubscribeGroup(ctx, nil, model.SenderGroup{Email: "a@x.com", UnsubscribeURL: srv.URL + "/a", UnsubscribeOneClick: true}, open)
+	if err != nil || out.Method != UnsubscribeOneClick || len(opened) != 0 {
+		t.Fatalf("one-click = %+v, %v, opened %q", out, err, opened)
+	}
+	out, err = UnsubscribeGroup(ctx, nil, model.SenderGroup{Email: "b@x.com", UnsubscribeURL: srv.URL + "/broken", UnsubscribeOneClick: true}, open)
+	if err != nil || out.Method != UnsubscribeBrowser || out.Err == nil || len(opened) != 1 {
+		t.Fatalf("failed one-click = %+v, %v, opened %q", out, err, opened)
+	}
+	out, err = UnsubscribeGroup(ctx, nil, model.SenderGroup{Email: "c@x.com", UnsubscribeURL: "https://c.example.com/u"}, open)
+	if err != nil || out.Method != UnsubscribeBrowser || opened[1] != "https://c.example.com/u" {
+		t.Fatalf("browser = %+v, %v, opened %q", out, err, opened)
+	}
+	if _, err := UnsubscribeGroup(ctx, nil, model.SenderGroup{Email: "d@x.com"}, open); err == nil {
+		t.Fatal("no link: want an error")
+	}
+}
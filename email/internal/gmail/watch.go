@@ -0,0 +1,34 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// WatchMailbox registers a users.watch on the given label scope so Gmail
+// publishes a notification to the Pub/Sub topic whenever it changes. The
+// watch lapses at the returned expiration and must be renewed before then.
+func WatchMailbox(ctx context.Context, svc *gmailv1.Service, topic, scope string) (time.Time, error) {
+	req := &gmailv1.WatchRequest{TopicName: topic}
+	if scope != AllMail {
+		req.LabelIds = []string{scope}
+		req.LabelFilterBehavior = "include"
+	}
+	resp, err := retry(ctx, func() (*gmailv1.WatchResponse, error) {
+		return svc.Users.Watch("me", req).Context(ctx).Do()
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("register watch on %s: %w", topic, err)
+	}
+	return time.UnixMilli(resp.Expiration), nil
+}
+
+// StopWatch cancels the mailbox's push notifications.
+func StopWatch(ctx context.Context, svc *gmailv1.Service) error {
+	return retryDo(ctx, func() error {
+		return svc.Users.Stop("me").Context(ctx).Do()
+	})
+}
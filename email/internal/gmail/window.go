@@ -0,0 +1,52 @@
+package gmail
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// metaScanSince is the start of the scan window: the cache holds the mail
+// of its scope dated from then on. Unset, it holds all of it.
+const metaScanSince = "scan_since"
+
+// WindowStart returns when a scan window such as "6mo" or "2y" starts,
+// counting back from now. "" and "all" mean no window: the zero time.
+func WindowStart(window string, now time.Time) (time.Time, error) {
+	window = strings.TrimSpace(window)
+	if window == "" || strings.EqualFold(window, "all") {
+		return time.Time{}, nil
+	}
+	t, _, err := ago(window, now)
+	return t, err
+}
+
+// ScanSince returns the start of the cache's scan window, or the zero time
+// when the cache covers mail of any age.
+func ScanSince(ctx context.Context, store MessageStore) (time.Time, error) {
+	v, err := store.GetMetadata(ctx, metaScanSince)
+	if err != nil || v == "" {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// StartScanWindow records the window a fresh scan of the cache covers and
+// returns its start. Providers call it before a full scan.
+func StartScanWindow(ctx context.Context, store MessageStore, window string, now time.Time) (time.Time, error) {
+	since, err := WindowStart(window, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return since, SetScanSince(ctx, store, since)
+}
+
+// SetScanSince records since as the start of the scan window, the zero
+// time meaning no window.
+func SetScanSince(ctx context.Context, store MessageStore, since time.Time) error {
+	v := ""
+	if !since.IsZero() {
+		v = since.UTC().Format(time.RFC3339)
+	}
+	return store.SetMetadata(ctx, metaScanSince, v)
+}
@@ -0,0 +1,105 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestWindowStart(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for window, want := range map[string]time.Time{
+		"":    {},
+		"all": {},
+		"6mo": time.Date(2026, 4, 17, 12, 0, 0, 0, time.UTC),
+		"2y":  time.Date(2024, 10, 17, 12, 0, 0, 0, time.UTC),
+	} {
+		if got, err := WindowStart(window, now); err != nil || !got.Equal(want) {
+			t.Errorf("WindowStart(%q) = %v, %v; want %v", window, got, err, want)
+		}
+	}
+	if _, err := WindowStart("soon", now); err == nil {
+		t.Error("WindowStart accepted a bad window")
+	}
+}
+
+// scanStore keeps upserted messages and metadata in memory.
+type scanStore struct {
+	metadataStore
+	msgs map[string]model.MessageRef
+}
+
+func (s *scanStore) UpsertMessages(_ context.Context, msgs []model.MessageRef) error {
+	for _, m := range msgs {
+		s.msgs[m.ID] = m
+	}
+	return nil
+}
+
+func (s *scanStore) GetMessagesByIDs(_ context.Context, ids []string) ([]model.MessageRef, error) {
+	var out []model.MessageRef
+	for _, id := range ids {
+		if m, ok := s.msgs[id]; ok {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func TestExtendScan(t *testing.T) {
+	var listed string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/gmail/v1/users/me/messages" {
+			listed = r.URL.Query().Get("q")
+			fmt.Fprint(w, `{"messages":[{"id":"old1"},{"id":"cached"}]}`)
+			return
+		}
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		fmt.Fprintf(w, `{"id":%q,"labelIds":["INBOX"],"payload":{"headers":[{"name":"From","value":"a@b.example"}]}}`, id)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	svc, err := gmailv1.NewService(ctx, option.WithEndpoint(srv.URL), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := &scanStore{metadataStore: metadataStore{meta: map[string]string{}}, msgs: map[string]model.MessageRef{"cached": {ID: "cached"}}}
+	if n, err := ExtendScan(ctx, svc, st, SyncOptions{}, time.Time{}, nil); err != nil || n != 0 || listed != "" {
+		t.Fatalf("ExtendScan without a window = %d, %v (listed %q)", n, err, listed)
+	}
+
+	current := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	if err := SetScanSince(ctx, st, current); err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	n, err := ExtendScan(ctx, svc, st, SyncOptions{Query: "category:promotions"}, since, nil)
+	if err != nil || n != 1 {
+		t.Fatalf("ExtendScan = %d, %v; want 1 message added", n, err)
+	}
+	if want := fmt.Sprintf("(category:promotions) after:%d before:%d", since.Unix(), current.Unix()); listed != want {
+		t.Errorf("listed q=%q; want %q", listed, want)
+	}
+	if _, ok := st.msgs["old1"]; !ok {
+		t.Error("old1 was not cached")
+	}
+	if got, _ := ScanSince(ctx, st); !got.Equal(since) {
+		t.Errorf("ScanSince = %v; want %v", got, since)
+	}
+
+	listed = ""
+	if n, err := ExtendScan(ctx, svc, st, SyncOptions{}, current, nil); err != nil || n != 0 || listed != "" {
+		t.Errorf("ExtendScan within the window = %d, %v (listed %q)", n, err, listed)
+	}
+}
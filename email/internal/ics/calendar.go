@@ -0,0 +1,114 @@
+package ics
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"common/atomicfile"
+)
+
+// AddToFile stores ev in the iCalendar file at path, creating the file if
+// needed. An event with the same UID is replaced, so adding an updated
+// invitation again does not duplicate it.
+func AddToFile(path string, ev Event) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var kept []string
+	if len(data) > 0 {
+		kept = otherEvents(string(data), ev.UID)
+	}
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//chuckterm//EN\r\n")
+	for _, block := range kept {
+		b.WriteString(block)
+	}
+	writeEvent(&b, ev)
+	b.WriteString("END:VCALENDAR\r\n")
+	return atomicfile.WriteFile(path, []byte(b.String()), 0o600)
+}
+
+// otherEvents returns the raw VEVENT blocks of an existing calendar whose
+// UID differs from uid, each ending in CRLF.
+func otherEvents(data, uid string) []string {
+	var (
+		blocks []string
+		cur    []string
+		curUID string
+	)
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.EqualFold(line, "BEGIN:VEVENT"):
+			cur, curUID = []string{line}, ""
+		case cur != nil:
+			cur = append(cur, line)
+			if strings.HasPrefix(strings.ToUpper(line), "UID:") {
+				curUID = line[4:]
+			}
+			if strings.EqualFold(line, "END:VEVENT") {
+				if uid == "" || curUID != uid {
+					blocks = append(blocks, strings.Join(cur, "\r\n")+"\r\n")
+				}
+				cur = nil
+			}
+		}
+	}
+	return blocks
+}
+
+func writeEvent(b *strings.Builder, ev Event) {
+	prop := func(name, value string) {
+		if value != "" {
+			b.WriteString(fold(name + ":" + value))
+		}
+	}
+	b.WriteString("BEGIN:VEVENT\r\n")
+	prop("UID", ev.UID)
+	prop("DTSTAMP", time.Now().UTC().Format("20060102T150405Z"))
+	if ev.AllDay {
+		prop("DTSTART;VALUE=DATE", ev.Start.Format("20060102"))
+		if !ev.End.IsZero() {
+			prop("DTEND;VALUE=DATE", ev.End.Format("20060102"))
+		}
+	} else {
+		prop("DTSTART", ev.Start.UTC().Format("20060102T150405Z"))
+		if !ev.End.IsZero() {
+			prop("DTEND", ev.End.UTC().Format("20060102T150405Z"))
+		}
+	}
+	prop("SUMMARY", escape(ev.Summary))
+	prop("LOCATION", escape(ev.Location))
+	prop("DESCRIPTION", escape(ev.Description))
+	prop("URL", ev.URL)
+	if ev.Organizer != "" {
+		email := ev.Organizer
+		if i := strings.LastIndex(email, "<"); i >= 0 {
+			email = strings.TrimSuffix(email[i+1:], ">")
+		}
+		prop("ORGANIZER", "mailto:"+email)
+	}
+	prop("STATUS", ev.Status)
+	b.WriteString("END:VEVENT\r\n")
+}
+
+// fold splits a content line into 75-octet pieces as RFC 5545 requires,
+// without breaking UTF-8 sequences.
+func fold(line string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,191 @@
+// Package ics reads calendar invitations (RFC 5545) from messages and keeps
+// accepted ones in a local .ics file.
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event is the part of a VEVENT shown to the user and written to the local
+// calendar.
+type Event struct {
+	UID         string    `json:"uid"`
+	Method      string    `json:"method,omitempty"` // REQUEST, CANCEL, ... from the VCALENDAR
+	Summary     string    `json:"summary"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end,omitzero"`
+	AllDay      bool      `json:"all_day,omitempty"`
+	Organizer   string    `json:"organizer,omitempty"` // "Name <email>" or the email alone
+	Status      string    `json:"status,omitempty"`
+	// RSVP holds response links found in the message, keyed by label
+	// ("Yes", "No", "Maybe").
+	RSVP []Link `json:"rsvp,omitempty"`
+}
+
+// Link is a labelled URL.
+type Link struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// property is one content line: NAME;PARAM=VALUE:value.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse returns the events of an iCalendar document.
+func Parse(data string) ([]Event, error) {
+	var (
+		events []Event
+		cur    *Event
+		method string
+		depth  int // nesting inside the current VEVENT (VALARM etc.)
+	)
+	for _, line := range unfold(data) {
+		p, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && cur == nil:
+			cur = &Event{}
+			depth = 0
+			continue
+		case p.name == "BEGIN" && cur != nil:
+			depth++
+			continue
+		case p.name == "END" && cur != nil && depth > 0:
+			depth--
+			continue
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT") && cur != nil:
+			if cur.Start.IsZero() {
+				return nil, fmt.Errorf("event %q has no DTSTART", cur.Summary)
+			}
+			events = append(events, *cur)
+			cur = nil
+			continue
+		case p.name == "METHOD" && cur == nil:
+			method = strings.ToUpper(p.value)
+			continue
+		}
+		if cur == nil || depth > 0 {
+			continue
+		}
+		switch p.name {
+		case "UID":
+			cur.UID = p.value
+		case "SUMMARY":
+			cur.Summary = unescape(p.value)
+		case "DESCRIPTION":
+			cur.Description = unescape(p.value)
+		case "LOCATION":
+			cur.Location = unescape(p.value)
+		case "URL":
+			cur.URL = p.value
+		case "STATUS":
+			cur.Status = strings.ToUpper(p.value)
+		case "ORGANIZER":
+			email := p.value
+			if len(email) >= 7 && strings.EqualFold(email[:7], "mailto:") {
+				email = email[7:]
+			}
+			if cn := strings.Trim(p.params["CN"], `"`); cn != "" && cn != email {
+				cur.Organizer = cn + " <" + email + ">"
+			} else {
+				cur.Organizer = email
+			}
+		case "DTSTART", "DTEND":
+			t, allDay, err := parseTime(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p.name, err)
+			}
+			if p.name == "DTSTART" {
+				cur.Start, cur.AllDay = t, allDay
+			} else {
+				cur.End = t
+			}
+		}
+	}
+	for i := range events {
+		events[i].Method = method
+	}
+	return events, nil
+}
+
+// unfold joins continuation lines (those starting with a space or tab).
+func unfold(data string) []string {
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func parseLine(line string) (property, bool) {
+	// The value starts at the first colon outside a quoted parameter.
+	inQuote, colon := false, -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return property{}, false
+	}
+	head := strings.Split(line[:colon], ";")
+	p := property{name: strings.ToUpper(head[0]), value: line[colon+1:], params: map[string]string{}}
+	for _, kv := range head[1:] {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			p.params[strings.ToUpper(k)] = v
+		}
+	}
+	return p, true
+}
+
+// parseTime reads a DATE or DATE-TIME value: UTC ("Z"), with a TZID, or
+// floating (taken as local time).
+func parseTime(p property) (time.Time, bool, error) {
+	v := p.value
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		return t, false, err
+	}
+	loc := time.Local
+	if tzid := strings.Trim(p.params["TZID"], `"`); tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	return t, false, err
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`).Replace(s)
+}
@@ -0,0 +1,97 @@
+package ics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const invite = "BEGIN:VCALENDAR\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nBEGIN:STANDARD\r\nDTSTART:19701025T030000\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20240305T100000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20240305T110000\r\n" +
+	"ORGANIZER;CN=\"Alice: Team Lead\":mailto:alice@example.com\r\n" +
+	"UID:abc123@example.com\r\n" +
+	"SUMMARY:Quarterly planning\\, part 1\r\n" +
+	"LOCATION:Room 4\\; 2nd floor\r\n" +
+	"DESCRIPTION:Agenda:\\n- budget\\n- hiring and a very long line that is folded\r\n" +
+	"  across two lines\r\n" +
+	"BEGIN:VALARM\r\nTRIGGER:-PT15M\r\nDESCRIPTION:Reminder\r\nEND:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20240306\r\n" +
+	"UID:allday@example.com\r\n" +
+	"SUMMARY:Offsite\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	events, err := Parse(invite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events", len(events))
+	}
+	ev := events[0]
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	if !ev.Start.Equal(time.Date(2024, 3, 5, 10, 0, 0, 0, berlin)) || ev.End.Sub(ev.Start) != time.Hour || ev.AllDay {
+		t.Errorf("times: %v – %v allDay=%v", ev.Start, ev.End, ev.AllDay)
+	}
+	if ev.Summary != "Quarterly planning, part 1" || ev.Location != "Room 4; 2nd floor" {
+		t.Errorf("text: %q / %q", ev.Summary, ev.Location)
+	}
+	if ev.Description != "Agenda:\n- budget\n- hiring and a very long line that is folded across two lines" {
+		t.Errorf("description: %q", ev.Description)
+	}
+	if ev.Organizer != "Alice: Team Lead <alice@example.com>" || ev.Method != "REQUEST" || ev.UID != "abc123@example.com" {
+		t.Errorf("organizer/method/uid: %q %q %q", ev.Organizer, ev.Method, ev.UID)
+	}
+	if ad := events[1]; !ad.AllDay || ad.Start.Day() != 6 {
+		t.Errorf("all-day event: %+v", ad)
+	}
+}
+
+func TestAddToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	events, _ := Parse(invite)
+	for _, ev := range events {
+		if err := AddToFile(path, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Re-adding an updated invitation replaces it.
+	updated := events[0]
+	updated.Summary = "Quarterly planning (moved)"
+	updated.Start = updated.Start.Add(time.Hour)
+	if err := AddToFile(path, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("unfolded line: %q", line)
+		}
+	}
+	got, err := Parse(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+	var moved Event
+	for _, ev := range got {
+		if ev.UID == updated.UID {
+			moved = ev
+		}
+	}
+	if moved.Summary != updated.Summary || !moved.Start.Equal(updated.Start) || moved.Organizer != "alice@example.com" {
+		t.Errorf("replaced event: %+v", moved)
+	}
+}
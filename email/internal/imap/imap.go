@@ -0,0 +1,391 @@
+// Package imap is a small IMAP4rev1 client (RFC 3501) with just what
+// chuckterm needs: reading a folder's headers and flags, downloading single
+// messages, and moving messages to other folders.
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client is one logged-in connection. It is not safe for concurrent use.
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	caps map[string]bool
+}
+
+// Mailbox is the state of the folder Select opened.
+type Mailbox struct {
+	Name        string
+	UIDValidity uint32
+	UIDNext     uint32
+	Exists      int
+}
+
+// Folder is one entry of List. Attributes carry the RFC 6154 special-use
+// marks, such as \Archive and \Trash, when the server supports them.
+type Folder struct {
+	Name       string
+	Attributes []string
+}
+
+// Message is what Fetch returned for one message. Header and Body are only
+// set when they were asked for.
+type Message struct {
+	UID    uint32
+	Size   int64
+	Flags  []string
+	Header []byte
+	Body   []byte
+}
+
+// HasFlag reports whether the message carries flag, such as \Seen.
+func (m Message) HasFlag(flag string) bool {
+	for _, f := range m.Flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusError is a NO or BAD answer to a command. Other errors from a
+// Client mean the connection is no longer usable.
+type StatusError struct {
+	Status string
+	Text   string
+}
+
+func (e *StatusError) Error() string { return e.Status + " " + e.Text }
+
+// dialTimeout bounds connecting and the TLS handshake.
+const dialTimeout = 30 * time.Second
+
+// Dial connects to addr, a host:port, over TLS, or in plain text when
+// tlsConfig is nil, and reads the server greeting.
+func Dial(ctx context.Context, addr string, tlsConfig *tls.Config) (*Client, error) {
+	d := net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		td := tls.Dialer{NetDialer: &d, Config: tlsConfig}
+		conn, err = td.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(ctx, conn)
+}
+
+// NewClient starts a session on conn, which has just been opened, by
+// reading the greeting.
+func NewClient(ctx context.Context, conn net.Conn) (*Client, error) {
+	c := &Client{conn: conn, r: bufio.NewReader(conn), caps: make(map[string]bool)}
+	defer c.watch(ctx)()
+	resp, err := c.readResponse()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read greeting: %w", err)
+	}
+	if resp.status != "OK" && resp.status != "PREAUTH" {
+		conn.Close()
+		return nil, fmt.Errorf("server refused the connection: %s %s", resp.status, resp.text)
+	}
+	c.setCaps(resp.code)
+	return c, nil
+}
+
+// Close drops the connection without logging out.
+func (c *Client) Close() error { return c.conn.Close() }
+
+// Logout ends the session and closes the connection.
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.do(ctx, "LOGOUT", nil)
+	c.conn.Close()
+	return err
+}
+
+// Can reports whether the server announced capability name, such as MOVE.
+func (c *Client) Can(name string) bool { return c.caps[strings.ToUpper(name)] }
+
+// Login authenticates with a user name and password, then refreshes the
+// capabilities, which often change once logged in.
+func (c *Client) Login(ctx context.Context, user, password string) error {
+	if c.Can("LOGINDISABLED") {
+		return errors.New("the server does not allow password logins on this connection")
+	}
+	if err := c.do(ctx, "LOGIN "+quote(user)+" "+quote(password), nil); err != nil {
+		return fmt.Errorf("login as %s: %w", user, err)
+	}
+	return c.capability(ctx)
+}
+
+func (c *Client) capability(ctx context.Context) error {
+	clear(c.caps)
+	return c.do(ctx, "CAPABILITY", func(r response) error {
+		if len(r.fields) > 0 && strings.EqualFold(atomOf(r.fields[0]), "CAPABILITY") {
+			for _, f := range r.fields[1:] {
+				c.caps[strings.ToUpper(atomOf(f))] = true
+			}
+		}
+		return nil
+	})
+}
+
+func (c *Client) setCaps(code string) {
+	words := strings.Fields(code)
+	if len(words) == 0 || !strings.EqualFold(words[0], "CAPABILITY") {
+		return
+	}
+	for _, w := range words[1:] {
+		c.caps[strings.ToUpper(w)] = true
+	}
+}
+
+// List returns every folder on the server.
+func (c *Client) List(ctx context.Context) ([]Folder, error) {
+	var out []Folder
+	err := c.do(ctx, `LIST "" "*"`, func(r response) error {
+		if len(r.fields) < 4 || !strings.EqualFold(atomOf(r.fields[0]), "LIST") {
+			return nil
+		}
+		f := Folder{Name: atomOf(r.fields[3])}
+		attrs, _ := r.fields[1].([]any)
+		for _, a := range attrs {
+			f.Attributes = append(f.Attributes, atomOf(a))
+		}
+		out = append(out, f)
+		return nil
+	})
+	return out, err
+}
+
+// Select opens folder for reading and writing.
+func (c *Client) Select(ctx context.Context, folder string) (Mailbox, error) {
+	mb := Mailbox{Name: folder}
+	err := c.do(ctx, "SELECT "+quote(folder), func(r response) error {
+		switch {
+		case r.status == "OK":
+			words := strings.Fields(r.code)
+			if len(words) == 2 {
+				n, _ := strconv.ParseUint(words[1], 10, 32)
+				switch strings.ToUpper(words[0]) {
+				case "UIDVALIDITY":
+					mb.UIDValidity = uint32(n)
+				case "UIDNEXT":
+					mb.UIDNext = uint32(n)
+				}
+			}
+		case len(r.fields) == 2 && strings.EqualFold(atomOf(r.fields[1]), "EXISTS"):
+			mb.Exists, _ = strconv.Atoi(atomOf(r.fields[0]))
+		}
+		return nil
+	})
+	if err != nil {
+		return Mailbox{}, fmt.Errorf("select %s: %w", folder, err)
+	}
+	return mb, nil
+}
+
+// Search returns the UIDs of the messages in the selected folder that
+// match criteria, such as "ALL" or "UID 120:*".
+func (c *Client) Search(ctx context.Context, criteria string) ([]uint32, error) {
+	var uids []uint32
+	err := c.do(ctx, "UID SEARCH "+criteria, func(r response) error {
+		if len(r.fields) == 0 || !strings.EqualFold(atomOf(r.fields[0]), "SEARCH") {
+			return nil
+		}
+		for _, f := range r.fields[1:] {
+			n, err := strconv.ParseUint(atomOf(f), 10, 32)
+			if err != nil {
+				return fmt.Errorf("search result %q: %w", atomOf(f), err)
+			}
+			uids = append(uids, uint32(n))
+		}
+		return nil
+	})
+	return uids, err
+}
+
+// FetchOptions selects what Fetch reads besides the UID, size and flags.
+type FetchOptions struct {
+	// HeaderFields, when set, reads only these header fields.
+	HeaderFields []string
+	// Body reads the whole message.
+	Body bool
+}
+
+// Fetch reads the messages with the given UIDs from the selected folder,
+// calling fn for each as it arrives. Nothing is marked read.
+func (c *Client) Fetch(ctx context.Context, uids []uint32, opts FetchOptions, fn func(Message) error) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	items := "UID RFC822.SIZE FLAGS"
+	if len(opts.HeaderFields) > 0 {
+		items += " BODY.PEEK[HEADER.FIELDS (" + strings.Join(opts.HeaderFields, " ") + ")]"
+	}
+	if opts.Body {
+		items += " BODY.PEEK[]"
+	}
+	return c.do(ctx, "UID FETCH "+SeqSet(uids)+" ("+items+")", func(r response) error {
+		if len(r.fields) < 3 || !strings.EqualFold(atomOf(r.fields[1]), "FETCH") {
+			return nil
+		}
+		attrs, _ := r.fields[2].([]any)
+		var m Message
+		for i := 0; i+1 < len(attrs); i += 2 {
+			key, val := strings.ToUpper(atomOf(attrs[i])), attrs[i+1]
+			switch {
+			case key == "UID":
+				n, _ := strconv.ParseUint(atomOf(val), 10, 32)
+				m.UID = uint32(n)
+			case key == "RFC822.SIZE":
+				m.Size, _ = strconv.ParseInt(atomOf(val), 10, 64)
+			case key == "FLAGS":
+				flags, _ := val.([]any)
+				for _, f := range flags {
+					m.Flags = append(m.Flags, atomOf(f))
+				}
+			case key == "BODY[]":
+				m.Body = []byte(atomOf(val))
+			case strings.HasPrefix(key, "BODY[HEADER.FIELDS"):
+				m.Header = []byte(atomOf(val))
+			}
+		}
+		if m.UID == 0 {
+			// An unsolicited flag update for a message outside uids.
+			return nil
+		}
+		return fn(m)
+	})
+}
+
+// ErrCannotMove is returned by Move on servers with neither MOVE nor
+// UIDPLUS. Moving there would take a plain EXPUNGE, which also deletes every
+// other message in the folder marked \Deleted, such as by another client.
+var ErrCannotMove = errors.New("the server supports neither MOVE nor UIDPLUS, so messages cannot be moved without expunging others")
+
+// Move moves the messages with the given UIDs from the selected folder to
+// folder, with MOVE (RFC 6851) when the server has it and otherwise copy,
+// delete and UID EXPUNGE (RFC 4315), which only removes these messages.
+func (c *Client) Move(ctx context.Context, uids []uint32, folder string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	set := SeqSet(uids)
+	if c.Can("MOVE") {
+		return c.do(ctx, "UID MOVE "+set+" "+quote(folder), nil)
+	}
+	if !c.Can("UIDPLUS") {
+		return ErrCannotMove
+	}
+	if err := c.do(ctx, "UID COPY "+set+" "+quote(folder), nil); err != nil {
+		return err
+	}
+	if err := c.do(ctx, "UID STORE "+set+` +FLAGS.SILENT (\Deleted)`, nil); err != nil {
+		return err
+	}
+	return c.do(ctx, "UID EXPUNGE "+set, nil)
+}
+
+// AddFlags sets flags, such as \Seen, on the messages with the given UIDs
+// in the selected folder.
+func (c *Client) AddFlags(ctx context.Context, uids []uint32, flags ...string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	return c.do(ctx, "UID STORE "+SeqSet(uids)+" +FLAGS.SILENT ("+strings.Join(flags, " ")+")", nil)
+}
+
+// SeqSet writes uids, in any order, as an IMAP sequence set with runs of
+// consecutive UIDs collapsed, as in "1:3,7".
+func SeqSet(uids []uint32) string {
+	sorted := append([]uint32(nil), uids...)
+	slices.Sort(sorted)
+	var sb strings.Builder
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatUint(uint64(sorted[i]), 10))
+		if sorted[j] != sorted[i] {
+			sb.WriteByte(':')
+			sb.WriteString(strconv.FormatUint(uint64(sorted[j]), 10))
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
+
+// do sends command and reads until its tagged response, passing each
+// untagged response to each, which may be nil. A NO or BAD answer is
+// returned as an error.
+func (c *Client) do(ctx context.Context, command string, each func(response) error) error {
+	defer c.watch(ctx)()
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return c.ioErr(ctx, err)
+	}
+	var cbErr error
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return c.ioErr(ctx, err)
+		}
+		switch r.tag {
+		case tag:
+			if cbErr != nil {
+				return cbErr
+			}
+			if r.status != "OK" {
+				return &StatusError{Status: r.status, Text: r.text}
+			}
+			return nil
+		case "*":
+			if r.status == "BYE" && !strings.HasPrefix(command, "LOGOUT") {
+				return fmt.Errorf("server closed the connection: %s", r.text)
+			}
+			if each != nil && cbErr == nil {
+				cbErr = each(r)
+			}
+		}
+	}
+}
+
+// watch makes the blocking reads and writes of the connection give up when
+// ctx is done, until the returned function is called.
+func (c *Client) watch(ctx context.Context) (stop func()) {
+	s := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Unix(1, 0)) })
+	return func() { s() }
+}
+
+func (c *Client) ioErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// quote writes s as an IMAP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,188 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// exchange is one command a scripted server expects, without its tag, and
+// the lines it answers with; "TAG" in them is replaced by the command's tag.
+type exchange struct {
+	command string
+	reply   []string
+}
+
+// scripted starts a client against a server that sends greeting and then
+// plays script, failing the test on any other command.
+func scripted(t *testing.T, greeting string, script []exchange) *Client {
+	t.Helper()
+	server, conn := net.Pipe()
+	t.Cleanup(func() { server.Close() })
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		server.Write([]byte(greeting + "\r\n"))
+		for _, ex := range script {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, command, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+			if command != ex.command {
+				t.Errorf("command = %q; want %q", command, ex.command)
+				server.Write([]byte(tag + " BAD unexpected\r\n"))
+				return
+			}
+			for _, l := range ex.reply {
+				server.Write([]byte(strings.ReplaceAll(l, "TAG", tag) + "\r\n"))
+			}
+		}
+	}()
+	c, err := NewClient(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func TestSeqSet(t *testing.T) {
+	tests := []struct {
+		uids []uint32
+		want string
+	}{
+		{[]uint32{7}, "7"},
+		{[]uint32{3, 1, 2, 7}, "1:3,7"},
+		{[]uint32{1, 2, 2, 5, 6, 9}, "1:2,5:6,9"},
+	}
+	for _, tc := range tests {
+		if got := SeqSet(tc.uids); got != tc.want {
+			t.Errorf("SeqSet(%v) = %q; want %q", tc.uids, got, tc.want)
+		}
+	}
+}
+
+func TestLoginSelectSearch(t *testing.T) {
+	ctx := context.Background()
+	c := scripted(t, "* OK [CAPABILITY IMAP4rev1 LOGINDISABLED] hi", []exchange{})
+	if err := c.Login(ctx, "me", "pw"); err == nil {
+		t.Fatal("Login succeeded despite LOGINDISABLED")
+	}
+
+	c = scripted(t, "* OK ready", []exchange{
+		{`LOGIN "me@example.com" "p\"w"`, []string{"TAG OK logged in"}},
+		{"CAPABILITY", []string{"* CAPABILITY IMAP4rev1 MOVE UIDPLUS", "TAG OK done"}},
+		{`SELECT "INBOX"`, []string{
+			"* 3 EXISTS",
+			"* OK [UIDVALIDITY 42] UIDs valid",
+			"* OK [UIDNEXT 9] next",
+			"* FLAGS (\\Seen \\Flagged)",
+			"TAG OK [READ-WRITE] selected",
+		}},
+		{"UID SEARCH ALL", []string{"* SEARCH 2 5 8", "TAG OK done"}},
+	})
+	if err := c.Login(ctx, "me@example.com", `p"w`); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !c.Can("move") || c.Can("LOGINDISABLED") {
+		t.Errorf("capabilities after login = %v", c.caps)
+	}
+	mb, err := c.Select(ctx, "INBOX")
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if mb.UIDValidity != 42 || mb.UIDNext != 9 || mb.Exists != 3 {
+		t.Errorf("Select = %+v", mb)
+	}
+	uids, err := c.Search(ctx, "ALL")
+	if err != nil || SeqSet(uids) != "2,5,8" {
+		t.Errorf("Search = %v, %v", uids, err)
+	}
+}
+
+func TestFetchLiterals(t *testing.T) {
+	header := "From: a@example.com\r\nSubject: (hi)\r\n\r\n"
+	c := scripted(t, "* PREAUTH ready", []exchange{
+		{"UID FETCH 4:5 (UID RFC822.SIZE FLAGS BODY.PEEK[HEADER.FIELDS (FROM SUBJECT)])", []string{
+			"* 1 FETCH (UID 4 RFC822.SIZE 1200 FLAGS (\\Seen) BODY[HEADER.FIELDS (FROM SUBJECT)] {" + strconv.Itoa(len(header)) + "}\r\n" + header + ")",
+			"* 2 FETCH (FLAGS () UID 5 RFC822.SIZE 80 BODY[HEADER.FIELDS (FROM SUBJECT)] \"\")",
+			"* 1 FETCH (FLAGS (\\Seen \\Flagged))",
+			"TAG OK done",
+		}},
+	})
+	var got []Message
+	err := c.Fetch(context.Background(), []uint32{5, 4}, FetchOptions{HeaderFields: []string{"FROM", "SUBJECT"}}, func(m Message) error {
+		got = append(got, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Fetch returned %d messages; want 2 (the unsolicited update is dropped)", len(got))
+	}
+	if got[0].UID != 4 || got[0].Size != 1200 || string(got[0].Header) != header || !got[0].HasFlag(`\seen`) {
+		t.Errorf("first message = %+v", got[0])
+	}
+	if got[1].UID != 5 || got[1].HasFlag(`\Seen`) || len(got[1].Header) != 0 {
+		t.Errorf("second message = %+v", got[1])
+	}
+}
+
+func TestMoveFallsBackToCopy(t *testing.T) {
+	ctx := context.Background()
+	c := scripted(t, "* OK [CAPABILITY IMAP4rev1] ready", []exchange{
+		{`UID COPY 3:4 "Archive"`, []string{"TAG OK copied"}},
+		{`UID STORE 3:4 +FLAGS.SILENT (\Deleted)`, []string{"TAG OK stored"}},
+		{"UID EXPUNGE 3:4", []string{"* 1 EXPUNGE", "* 1 EXPUNGE", "TAG OK expunged"}},
+		{`UID MOVE 9 "Trash"`, []string{"TAG NO [TRYCREATE] no such folder"}},
+	})
+	// Without UIDPLUS only a plain EXPUNGE could finish the move.
+	if err := c.Move(ctx, []uint32{4, 3}, "Archive"); !errors.Is(err, ErrCannotMove) {
+		t.Fatalf("Move without MOVE or UIDPLUS = %v; want ErrCannotMove", err)
+	}
+	c.caps["UIDPLUS"] = true
+	if err := c.Move(ctx, []uint32{4, 3}, "Archive"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	c.caps["MOVE"] = true
+	err := c.Move(ctx, []uint32{9}, "Trash")
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.Status != "NO" {
+		t.Errorf("Move to a missing folder = %v; want a NO StatusError", err)
+	}
+}
+
+func TestAddFlags(t *testing.T) {
+	c := scripted(t, "* OK ready", []exchange{
+		{`UID STORE 2,5:6 +FLAGS.SILENT (\Seen)`, []string{"TAG OK stored"}},
+	})
+	if err := c.AddFlags(context.Background(), []uint32{6, 2, 5}, `\Seen`); err != nil {
+		t.Fatalf("AddFlags: %v", err)
+	}
+	if err := c.AddFlags(context.Background(), nil, `\Seen`); err != nil {
+		t.Errorf("AddFlags with no UIDs: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	c := scripted(t, "* OK ready", []exchange{
+		{`LIST "" "*"`, []string{
+			`* LIST (\HasNoChildren) "/" "INBOX"`,
+			`* LIST (\HasNoChildren \Archive) "/" "Archive Box"`,
+			`* LIST (\Trash) "/" {12}` + "\r\nDeleted Mail",
+			"TAG OK done",
+		}},
+	})
+	folders, err := c.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(folders) != 3 || folders[1].Name != "Archive Box" || folders[2].Name != "Deleted Mail" || folders[2].Attributes[0] != `\Trash` {
+		t.Errorf("List = %+v", folders)
+	}
+}
@@ -0,0 +1,217 @@
+package imap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// response is one server response. Status responses (OK, NO, BAD, BYE,
+// PREAUTH) have status, the response code between brackets, and text; data
+// responses have the values after the tag in fields. Values are strings,
+// nil for NIL, and []any for parenthesised lists.
+type response struct {
+	tag    string
+	status string
+	code   string
+	text   string
+	fields []any
+}
+
+// maxLiteral bounds a literal the server may send, such as one message.
+const maxLiteral = 64 << 20
+
+func (c *Client) readResponse() (response, error) {
+	tag, err := readAtom(c.r)
+	if err != nil {
+		return response{}, err
+	}
+	r := response{tag: tag}
+	if tag == "+" {
+		r.text, err = readRest(c.r)
+		return r, err
+	}
+	if err := skipSpace(c.r); err != nil {
+		return r, err
+	}
+	first, err := readValue(c.r)
+	if err != nil {
+		return r, err
+	}
+	switch s := strings.ToUpper(atomOf(first)); s {
+	case "OK", "NO", "BAD", "BYE", "PREAUTH":
+		r.status = s
+		rest, err := readRest(c.r)
+		if err != nil {
+			return r, err
+		}
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, "[") {
+			if end := strings.IndexByte(rest, ']'); end > 0 {
+				r.code, rest = rest[1:end], strings.TrimSpace(rest[end+1:])
+			}
+		}
+		r.text = rest
+		return r, nil
+	}
+	r.fields = []any{first}
+	more, err := readValues(c.r, 0)
+	r.fields = append(r.fields, more...)
+	return r, err
+}
+
+// readValues reads values up to the end of the line, or up to the ')'
+// closing a list when close is set.
+func readValues(r *bufio.Reader, close byte) ([]any, error) {
+	var out []any
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return out, err
+		}
+		switch {
+		case b == ' ':
+		case close != 0 && b == close:
+			return out, nil
+		case close == 0 && b == '\r':
+			if b, err = r.ReadByte(); err != nil || b != '\n' {
+				return out, errors.Join(err, errors.New("imap: CR without LF"))
+			}
+			return out, nil
+		case close == 0 && b == '\n':
+			return out, nil
+		case b == '\r' || b == '\n':
+			return out, errors.New("imap: line ended inside a list")
+		default:
+			r.UnreadByte()
+			v, err := readValue(r)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, v)
+		}
+	}
+}
+
+func readValue(r *bufio.Reader) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case '(':
+		list, err := readValues(r, ')')
+		if list == nil {
+			list = []any{}
+		}
+		return list, err
+	case '"':
+		return readQuoted(r)
+	case '{':
+		return readLiteral(r)
+	}
+	r.UnreadByte()
+	atom, err := readAtom(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(atom, "NIL") {
+		return nil, nil
+	}
+	return atom, nil
+}
+
+// readAtom reads up to a space, parenthesis or line end. A bracketed part,
+// as in BODY[HEADER.FIELDS (FROM)], is read whole.
+func readAtom(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	depth := 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return sb.String(), err
+		}
+		switch {
+		case b == '[':
+			depth++
+		case b == ']' && depth > 0:
+			depth--
+		case b == '\r' || b == '\n' || (depth == 0 && (b == ' ' || b == '(' || b == ')')):
+			r.UnreadByte()
+			if sb.Len() == 0 {
+				return "", fmt.Errorf("imap: expected a value, got %q", b)
+			}
+			return sb.String(), nil
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func readQuoted(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if b, err = r.ReadByte(); err != nil {
+				return "", err
+			}
+		case '\r', '\n':
+			return "", errors.New("imap: line ended inside a quoted string")
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// readLiteral reads a {n} literal; the '{' is already read.
+func readLiteral(r *bufio.Reader) (string, error) {
+	size, err := r.ReadString('}')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(size, "}"), "+"))
+	if err != nil || n < 0 || n > maxLiteral {
+		return "", fmt.Errorf("imap: bad literal size {%s", size)
+	}
+	if line, err := r.ReadString('\n'); err != nil || strings.TrimRight(line, "\r\n") != "" {
+		return "", errors.Join(err, errors.New("imap: literal size not followed by CRLF"))
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func readRest(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func skipSpace(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != ' ' {
+		r.UnreadByte()
+	}
+	return nil
+}
+
+// atomOf returns v as a string, or "" for NIL and lists.
+func atomOf(v any) string {
+	s, _ := v.(string)
+	return s
+}
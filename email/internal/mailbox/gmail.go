@@ -0,0 +1,75 @@
+package mailbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+
+	gmailv1 "google.golang.org/api/gmail/v1"
+)
+
+// Gmail is the provider for a Gmail account.
+func Gmail(svc *gmailv1.Service) Provider { return gmailProvider{svc} }
+
+type gmailProvider struct{ svc *gmailv1.Service }
+
+func (p gmailProvider) Account(ctx context.Context) (string, error) {
+	return gmail.AccountEmail(ctx, p.svc)
+}
+
+func (p gmailProvider) Scope(ctx context.Context, label string) (string, error) {
+	return gmail.ResolveLabel(ctx, p.svc, label)
+}
+
+func (p gmailProvider) Sync(ctx context.Context, store gmail.MessageStore, opts gmail.SyncOptions, progress func(gmail.SyncProgress)) error {
+	hid, err := store.GetLastHistoryID(ctx)
+	if err != nil {
+		return err
+	}
+	// Without a historyId the cache is empty or a full scan was
+	// interrupted, which FullScan resumes. An expired historyId needs a
+	// rescan.
+	if hid != "" {
+		err = gmail.SyncSinceHistory(ctx, p.svc, store, hid, opts, progress)
+		if errors.Is(err, gmail.ErrHistoryExpired) {
+			err = gmail.Rescan(ctx, p.svc, store, opts, progress)
+		}
+	} else {
+		err = gmail.FullScan(ctx, p.svc, store, opts, progress)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := gmail.Prune(ctx, store, opts.Retention); err != nil {
+		return err
+	}
+	// Messages cached before an upgrade lack the fields it added.
+	return gmail.RefreshMetadata(ctx, p.svc, store, opts, progress)
+}
+
+func (p gmailProvider) Extend(ctx context.Context, store gmail.MessageStore, opts gmail.SyncOptions, since time.Time, progress func(gmail.SyncProgress)) (int, error) {
+	return gmail.ExtendScan(ctx, p.svc, store, opts, since, progress)
+}
+
+func (p gmailProvider) Archive(ctx context.Context, ids []string) error {
+	return gmail.ArchiveMessages(ctx, p.svc, ids)
+}
+
+func (p gmailProvider) Trash(ctx context.Context, ids []string) error {
+	return gmail.TrashMessages(ctx, p.svc, ids)
+}
+
+func (p gmailProvider) MarkRead(ctx context.Context, ids []string) error {
+	return gmail.MarkRead(ctx, p.svc, ids)
+}
+
+func (p gmailProvider) Body(ctx context.Context, id string) (model.MessageBody, error) {
+	return gmail.GetMessageBody(ctx, p.svc, id)
+}
+
+func (p gmailProvider) SaveAttachment(ctx context.Context, id string, att model.Attachment, dir string) (string, error) {
+	return gmail.DownloadAttachment(ctx, p.svc, id, att, dir)
+}
@@ -0,0 +1,493 @@
+package mailbox
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chuckterm/internal/emlimport"
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/imap"
+	"chuckterm/internal/model"
+)
+
+// IMAPConfig is an account on an IMAP server.
+type IMAPConfig struct {
+	// Addr is the server's host:port; a bare host means port 993. The
+	// connection is always TLS.
+	Addr     string
+	User     string
+	Password string
+	// Archive and Trash are the folders archived and trashed messages move
+	// to. Unset, they are the folders the server marks \Archive and \Trash,
+	// or else "Archive" and "Trash".
+	Archive string
+	Trash   string
+}
+
+// imapHeaderFields are the header fields Sync caches.
+var imapHeaderFields = []string{"FROM", "SUBJECT", "DATE", "LIST-UNSUBSCRIBE", "LIST-UNSUBSCRIBE-POST", "PRECEDENCE", "X-MAILER", "LIST-ID"}
+
+// imapFetchChunk is how many messages Sync asks for headers at a time.
+const imapFetchChunk = 200
+
+// IMAP is the provider for an IMAP account. Labels name folders, with ""
+// and gmail.AllMail both meaning INBOX, which the server has no equivalent
+// of. It keeps one connection open, reconnecting once when a command finds
+// it broken.
+func IMAP(cfg IMAPConfig) Provider {
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		cfg.Addr = net.JoinHostPort(cfg.Addr, "993")
+	}
+	host, _, _ := net.SplitHostPort(cfg.Addr)
+	return &imapProvider{cfg: cfg, dial: func(ctx context.Context) (*imap.Client, error) {
+		return imap.Dial(ctx, cfg.Addr, &tls.Config{ServerName: host})
+	}}
+}
+
+type imapProvider struct {
+	cfg  IMAPConfig
+	dial func(ctx context.Context) (*imap.Client, error)
+
+	mu       sync.Mutex
+	c        *imap.Client
+	selected string // folder selected on c
+	archive  string
+	trash    string
+}
+
+func (p *imapProvider) Account(ctx context.Context) (string, error) {
+	return p.cfg.User, nil
+}
+
+// scopePrefix starts every scope, so switching accounts resets the cache.
+func (p *imapProvider) scopePrefix() string {
+	return "imap:" + p.cfg.User + "@" + p.cfg.Addr + "/"
+}
+
+func (p *imapProvider) Scope(ctx context.Context, label string) (string, error) {
+	if label == "" || label == gmail.AllMail {
+		label = "INBOX"
+	}
+	return p.scopePrefix() + label, nil
+}
+
+// folder is the folder a scope from Scope names.
+func (p *imapProvider) folder(scope string) string {
+	if f, ok := strings.CutPrefix(scope, p.scopePrefix()); ok {
+		return f
+	}
+	return "INBOX"
+}
+
+// session runs fn on the open connection with folder selected, dialing
+// first if needed, and once more on a new connection if the old one broke.
+// fn must be safe to run twice.
+func (p *imapProvider) session(ctx context.Context, folder string, fn func(c *imap.Client, mb imap.Mailbox) error) error {
+	return p.run(ctx, folder, true, fn)
+}
+
+// change is session for an fn that changes mail. It only redials when the
+// connection broke before fn started: a COPY may have taken effect before
+// the connection dropped, and repeating it would copy the messages twice.
+func (p *imapProvider) change(ctx context.Context, folder string, fn func(c *imap.Client, mb imap.Mailbox) error) error {
+	return p.run(ctx, folder, false, fn)
+}
+
+func (p *imapProvider) run(ctx context.Context, folder string, repeatable bool, fn func(c *imap.Client, mb imap.Mailbox) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		started := false
+		err := p.connect(ctx)
+		if err == nil {
+			var mb imap.Mailbox
+			// Selecting again each time refreshes UIDNEXT and UIDVALIDITY.
+			if mb, err = p.c.Select(ctx, folder); err == nil {
+				p.selected = folder
+				started = true
+				err = fn(p.c, mb)
+			}
+		}
+		var serr *imap.StatusError
+		if err == nil || errors.As(err, &serr) || errors.Is(err, imap.ErrCannotMove) || ctx.Err() != nil {
+			return err
+		}
+		p.drop()
+		if attempt > 0 || (started && !repeatable) {
+			return err
+		}
+		slog.Debug("imap connection lost, reconnecting", "err", err)
+	}
+}
+
+func (p *imapProvider) connect(ctx context.Context) error {
+	if p.c != nil {
+		return nil
+	}
+	c, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.Login(ctx, p.cfg.User, p.cfg.Password); err != nil {
+		c.Close()
+		return fmt.Errorf("imap login as %s: %w", p.cfg.User, err)
+	}
+	p.c = c
+	if p.archive == "" || p.trash == "" {
+		p.archive, p.trash = p.specialFolders(ctx, c)
+	}
+	return nil
+}
+
+// specialFolders picks the archive and trash folders from the config, the
+// server's special-use marks, or the usual names, in that order.
+func (p *imapProvider) specialFolders(ctx context.Context, c *imap.Client) (archive, trash string) {
+	archive, trash = p.cfg.Archive, p.cfg.Trash
+	if archive != "" && trash != "" {
+		return archive, trash
+	}
+	folders, err := c.List(ctx)
+	if err != nil {
+		slog.Warn("imap list folders", "err", err)
+	}
+	for _, f := range folders {
+		for _, attr := range f.Attributes {
+			switch {
+			case archive == "" && strings.EqualFold(attr, `\Archive`):
+				archive = f.Name
+			case trash == "" && strings.EqualFold(attr, `\Trash`):
+				trash = f.Name
+			}
+		}
+	}
+	if archive == "" {
+		archive = "Archive"
+	}
+	if trash == "" {
+		trash = "Trash"
+	}
+	return archive, trash
+}
+
+func (p *imapProvider) drop() {
+	if p.c != nil {
+		p.c.Close()
+		p.c, p.selected = nil, ""
+	}
+}
+
+// Sync caches the headers of messages new to the folder, drops the ones
+// that left it and refreshes the read and starred state of the rest. The
+// store's history ID holds the folder's UIDVALIDITY; when the server
+// changes it, UIDs were reassigned and the cache is rebuilt. Gmail search
+// queries are not supported.
+func (p *imapProvider) Sync(ctx context.Context, store gmail.MessageStore, opts gmail.SyncOptions, progress func(gmail.SyncProgress)) (err error) {
+	if opts.Query != "" {
+		return fmt.Errorf("imap: cannot sync with a Gmail search query (%q)", opts.Query)
+	}
+	if progress == nil {
+		progress = func(gmail.SyncProgress) {}
+	}
+	folder := p.folder(opts.Label)
+	slog.Info("imap sync start", "folder", folder)
+	defer func(start time.Time) {
+		if err != nil {
+			slog.Error("imap sync failed", "folder", folder, "duration", time.Since(start), "err", err)
+		} else {
+			slog.Info("imap sync done", "folder", folder, "duration", time.Since(start))
+		}
+	}(time.Now())
+	return p.session(ctx, folder, func(c *imap.Client, mb imap.Mailbox) error {
+		validity := strconv.FormatUint(uint64(mb.UIDValidity), 10)
+		last, err := store.GetLastHistoryID(ctx)
+		if err != nil {
+			return err
+		}
+		full := last != validity
+		if full {
+			progress(gmail.SyncProgress{Phase: "fullscan-start"})
+			if err := store.ClearMessages(ctx); err != nil {
+				return err
+			}
+			if _, err := gmail.StartScanWindow(ctx, store, opts.Window, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		progress(gmail.SyncProgress{Phase: "listing"})
+		// Mail older than the retention cutoff or the scan window is left
+		// out, and dropped from the cache with the messages deleted on the
+		// server.
+		criteria := "ALL"
+		cutoff, err := gmail.RetentionCutoff(ctx, store, opts.Retention, time.Now())
+		if err != nil {
+			return err
+		}
+		since, err := gmail.ScanSince(ctx, store)
+		if err != nil {
+			return err
+		}
+		if since.After(cutoff) {
+			cutoff = since
+		}
+		if !cutoff.IsZero() {
+			criteria = "SINCE " + cutoff.Format("2-Jan-2006")
+		}
+		uids, err := c.Search(ctx, criteria)
+		if err != nil {
+			return fmt.Errorf("imap search %s: %w", folder, err)
+		}
+		cached, err := store.LoadAllMessages(ctx)
+		if err != nil {
+			return err
+		}
+		present := make(map[string]bool, len(uids))
+		for _, uid := range uids {
+			present[strconv.FormatUint(uint64(uid), 10)] = true
+		}
+		known := make(map[string]bool, len(cached))
+		var gone []string
+		var still []uint32
+		for _, m := range cached {
+			known[m.ID] = true
+			if uid, err := strconv.ParseUint(m.ID, 10, 32); err != nil {
+				continue // imported locally
+			} else if !present[m.ID] {
+				gone = append(gone, m.ID)
+			} else {
+				still = append(still, uint32(uid))
+			}
+		}
+		if len(gone) > 0 {
+			if err := store.DeleteMessages(ctx, gone); err != nil {
+				return err
+			}
+		}
+		if ls, ok := store.(gmail.LabelStore); ok && len(still) > 0 {
+			labels := make(map[string][]string, len(still))
+			err := c.Fetch(ctx, still, imap.FetchOptions{}, func(m imap.Message) error {
+				labels[strconv.FormatUint(uint64(m.UID), 10)] = imapLabels(folder, m)
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("imap fetch flags: %w", err)
+			}
+			if err := ls.UpdateLabels(ctx, labels); err != nil {
+				return err
+			}
+		}
+
+		// After a cache upgrade, the headers of every message are fetched
+		// again for the fields it added.
+		refresh, err := gmail.MetadataRefreshPending(ctx, store)
+		if err != nil {
+			return err
+		}
+		var missing []uint32
+		for _, uid := range uids {
+			if refresh || !known[strconv.FormatUint(uint64(uid), 10)] {
+				missing = append(missing, uid)
+			}
+		}
+		var added []model.MessageRef
+		for start := 0; start < len(missing); start += imapFetchChunk {
+			progress(gmail.SyncProgress{Phase: "metadata", Total: len(missing), Done: start})
+			chunk := missing[start:min(start+imapFetchChunk, len(missing))]
+			refs := make([]model.MessageRef, 0, len(chunk))
+			err := c.Fetch(ctx, chunk, imap.FetchOptions{HeaderFields: imapHeaderFields}, func(m imap.Message) error {
+				refs = append(refs, imapRef(folder, m))
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("imap fetch headers: %w", err)
+			}
+			if err := store.UpsertMessages(ctx, refs); err != nil {
+				return err
+			}
+			if !full {
+				for _, ref := range refs {
+					if !known[ref.ID] {
+						added = append(added, ref)
+					}
+				}
+			}
+		}
+		progress(gmail.SyncProgress{Phase: "fullscan-done", Total: len(missing), Done: len(missing)})
+		if err := store.SetLastHistoryID(ctx, validity); err != nil {
+			return err
+		}
+		if refresh {
+			if err := gmail.MetadataRefreshed(ctx, store); err != nil {
+				return err
+			}
+		}
+		if len(added) > 0 && opts.NewMessages != nil {
+			opts.NewMessages(added)
+		}
+		if err := gmail.MarkSynced(ctx, store); err != nil {
+			return err
+		}
+		_, err = gmail.Prune(ctx, store, opts.Retention)
+		return err
+	})
+}
+
+// imapRef is the cached form of a message fetched with imapHeaderFields.
+func imapRef(folder string, m imap.Message) model.MessageRef {
+	ref, err := emlimport.Parse(append(m.Header, "\r\n"...))
+	if err != nil {
+		// Without a parseable From the message still counts toward the
+		// folder; it groups under an empty sender.
+		ref = model.MessageRef{}
+	}
+	ref.ID = strconv.FormatUint(uint64(m.UID), 10)
+	ref.SizeBytes = m.Size
+	ref.LabelIDs = imapLabels(folder, m)
+	return ref
+}
+
+// imapLabels maps a message's flags onto the Gmail label IDs the rest of
+// chuckterm reads.
+func imapLabels(folder string, m imap.Message) []string {
+	var labels []string
+	if strings.EqualFold(folder, "INBOX") {
+		labels = append(labels, "INBOX")
+	}
+	if !m.HasFlag(`\Seen`) {
+		labels = append(labels, "UNREAD")
+	}
+	if m.HasFlag(`\Flagged`) {
+		labels = append(labels, "STARRED")
+	}
+	return labels
+}
+
+// uids parses the message IDs Sync stored, skipping locally imported ones.
+func uids(ids []string) []uint32 {
+	out := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		if n, err := strconv.ParseUint(id, 10, 32); err == nil {
+			out = append(out, uint32(n))
+		}
+	}
+	return out
+}
+
+// Extend moves the start of the scan window back and syncs, which fetches
+// the older messages the folder search now returns.
+func (p *imapProvider) Extend(ctx context.Context, store gmail.MessageStore, opts gmail.SyncOptions, since time.Time, progress func(gmail.SyncProgress)) (int, error) {
+	current, err := gmail.ScanSince(ctx, store)
+	if err != nil || current.IsZero() || (!since.IsZero() && !since.Before(current)) {
+		return 0, err
+	}
+	before, err := store.CountMessages(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := gmail.SetScanSince(ctx, store, since); err != nil {
+		return 0, err
+	}
+	if err := p.Sync(ctx, store, opts, progress); err != nil {
+		return 0, err
+	}
+	after, err := store.CountMessages(ctx)
+	return max(after-before, 0), err
+}
+
+func (p *imapProvider) Archive(ctx context.Context, ids []string) error {
+	if gmail.SkipDryRun(ctx, "archive %s", gmail.DescribeIDs(ids)) {
+		return gmail.ErrDryRun
+	}
+	return p.move(ctx, "archive", ids, func() string { return p.archive })
+}
+
+func (p *imapProvider) Trash(ctx context.Context, ids []string) error {
+	if gmail.SkipDryRun(ctx, "trash %s", gmail.DescribeIDs(ids)) {
+		return gmail.ErrDryRun
+	}
+	return p.move(ctx, "trash", ids, func() string { return p.trash })
+}
+
+func (p *imapProvider) MarkRead(ctx context.Context, ids []string) error {
+	if gmail.SkipDryRun(ctx, "mark read %s", gmail.DescribeIDs(ids)) {
+		return gmail.ErrDryRun
+	}
+	err := p.session(ctx, p.current(), func(c *imap.Client, _ imap.Mailbox) error {
+		return c.AddFlags(ctx, uids(ids), `\Seen`)
+	})
+	if err != nil {
+		err = fmt.Errorf("mark read: %w", err)
+		slog.Error("mark read failed", "messages", len(ids), "err", err)
+		return err
+	}
+	slog.Info("mark read", "messages", len(ids))
+	return nil
+}
+
+// move moves ids out of the folder last synced into the folder to names,
+// which is read once connected.
+func (p *imapProvider) move(ctx context.Context, action string, ids []string, to func() string) error {
+	err := p.change(ctx, p.current(), func(c *imap.Client, _ imap.Mailbox) error {
+		return c.Move(ctx, uids(ids), to())
+	})
+	if err != nil {
+		err = fmt.Errorf("%s: %w", action, err)
+		slog.Error(action+" failed", "messages", len(ids), "err", err)
+		return err
+	}
+	slog.Info(action, "messages", len(ids))
+	return nil
+}
+
+// current is the folder selected last, or INBOX before any sync.
+func (p *imapProvider) current() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.selected == "" {
+		return "INBOX"
+	}
+	return p.selected
+}
+
+// raw reads a whole message from the current folder.
+func (p *imapProvider) raw(ctx context.Context, id string) ([]byte, error) {
+	uid := uids([]string{id})
+	if len(uid) == 0 {
+		return nil, fmt.Errorf("message %s is not on the IMAP server", id)
+	}
+	var raw []byte
+	err := p.session(ctx, p.current(), func(c *imap.Client, _ imap.Mailbox) error {
+		return c.Fetch(ctx, uid, imap.FetchOptions{Body: true}, func(m imap.Message) error {
+			raw = m.Body
+			return nil
+		})
+	})
+	if err == nil && raw == nil {
+		err = fmt.Errorf("message %s is no longer in the folder", id)
+	}
+	return raw, err
+}
+
+func (p *imapProvider) Body(ctx context.Context, id string) (model.MessageBody, error) {
+	raw, err := p.raw(ctx, id)
+	if err != nil {
+		return model.MessageBody{}, err
+	}
+	return gmail.BodyFromRaw(ctx, raw)
+}
+
+func (p *imapProvider) SaveAttachment(ctx context.Context, id string, att model.Attachment, dir string) (string, error) {
+	raw, err := p.raw(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return gmail.SaveAttachmentFromRaw(raw, att, dir)
+}
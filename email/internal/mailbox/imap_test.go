@@ -0,0 +1,356 @@
+package mailbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/imap"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+)
+
+type fakeMessage struct {
+	uid   uint32
+	flags []string
+	raw   string
+}
+
+// fakeServer is just enough of an IMAP server for the provider: one
+// session per connection, folders of messages, and MOVE.
+type fakeServer struct {
+	mu       sync.Mutex
+	validity uint32
+	next     uint32
+	folders  map[string][]fakeMessage
+	conns    []net.Conn
+	// hangUpAfter, if set, is a UID command after which the server hangs
+	// up instead of answering, once.
+	hangUpAfter string
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{validity: 1, next: 1, folders: map[string][]fakeMessage{"INBOX": nil, "Archive": nil, "Bin": nil}}
+}
+
+func (s *fakeServer) add(folder, raw string, flags ...string) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uid := s.next
+	s.next++
+	s.folders[folder] = append(s.folders[folder], fakeMessage{uid: uid, flags: flags, raw: raw})
+	return uid
+}
+
+func (s *fakeServer) flags(folder string, uid uint32) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.folders[folder] {
+		if m.uid == uid {
+			return m.flags
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) uids(folder string) []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []uint32
+	for _, m := range s.folders[folder] {
+		out = append(out, m.uid)
+	}
+	return out
+}
+
+// dial opens a new session, as the provider's dial does.
+func (s *fakeServer) dial(ctx context.Context) (*imap.Client, error) {
+	server, conn := net.Pipe()
+	s.mu.Lock()
+	s.conns = append(s.conns, server)
+	s.mu.Unlock()
+	go s.serve(server)
+	return imap.NewClient(ctx, conn)
+}
+
+// hangUp drops every open session.
+func (s *fakeServer) hangUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	fmt.Fprint(w, "* OK fake ready\r\n")
+	w.Flush()
+	selected := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		s.mu.Lock()
+		switch verb, args, _ := strings.Cut(cmd, " "); verb {
+		case "LOGIN":
+			fmt.Fprintf(w, "%s OK logged in\r\n", tag)
+		case "CAPABILITY":
+			fmt.Fprintf(w, "* CAPABILITY IMAP4rev1 MOVE\r\n%s OK done\r\n", tag)
+		case "LIST":
+			fmt.Fprintf(w, "* LIST () \"/\" \"INBOX\"\r\n* LIST (\\Archive) \"/\" \"Archive\"\r\n* LIST (\\Trash) \"/\" \"Bin\"\r\n%s OK done\r\n", tag)
+		case "SELECT":
+			selected = strings.Trim(args, `"`)
+			fmt.Fprintf(w, "* %d EXISTS\r\n* OK [UIDVALIDITY %d] ok\r\n* OK [UIDNEXT %d] ok\r\n%s OK [READ-WRITE] done\r\n",
+				len(s.folders[selected]), s.validity, s.next, tag)
+		case "UID":
+			s.uidCommand(w, tag, selected, args)
+			if sub, _, _ := strings.Cut(args, " "); sub == s.hangUpAfter {
+				s.hangUpAfter = ""
+				s.mu.Unlock()
+				return
+			}
+		default:
+			fmt.Fprintf(w, "%s BAD unknown command\r\n", tag)
+		}
+		s.mu.Unlock()
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) uidCommand(w *bufio.Writer, tag, folder, args string) {
+	verb, args, _ := strings.Cut(args, " ")
+	set, rest, _ := strings.Cut(args, " ")
+	msgs := s.folders[folder]
+	switch verb {
+	case "SEARCH":
+		fmt.Fprint(w, "* SEARCH")
+		for _, m := range msgs {
+			fmt.Fprintf(w, " %d", m.uid)
+		}
+		fmt.Fprintf(w, "\r\n%s OK done\r\n", tag)
+	case "FETCH":
+		want := parseSet(set)
+		for i, m := range msgs {
+			if !want[m.uid] {
+				continue
+			}
+			fmt.Fprintf(w, "* %d FETCH (UID %d RFC822.SIZE %d FLAGS (%s)", i+1, m.uid, len(m.raw), strings.Join(m.flags, " "))
+			if strings.Contains(rest, "HEADER.FIELDS") {
+				header, _, _ := strings.Cut(m.raw, "\r\n\r\n")
+				header += "\r\n\r\n"
+				fmt.Fprintf(w, " BODY[HEADER.FIELDS (FROM)] {%d}\r\n%s", len(header), header)
+			}
+			if strings.Contains(rest, "BODY.PEEK[]") {
+				fmt.Fprintf(w, " BODY[] {%d}\r\n%s", len(m.raw), m.raw)
+			}
+			fmt.Fprint(w, ")\r\n")
+		}
+		fmt.Fprintf(w, "%s OK done\r\n", tag)
+	case "MOVE":
+		to := strings.Trim(rest, `"`)
+		want := parseSet(set)
+		var keep []fakeMessage
+		for _, m := range msgs {
+			if want[m.uid] {
+				s.folders[to] = append(s.folders[to], m)
+			} else {
+				keep = append(keep, m)
+			}
+		}
+		s.folders[folder] = keep
+		fmt.Fprintf(w, "%s OK moved\r\n", tag)
+	case "STORE":
+		flags := strings.Fields(strings.Trim(strings.TrimPrefix(rest, "+FLAGS.SILENT "), "()"))
+		want := parseSet(set)
+		for i, m := range msgs {
+			if want[m.uid] {
+				msgs[i].flags = append(m.flags, flags...)
+			}
+		}
+		fmt.Fprintf(w, "%s OK stored\r\n", tag)
+	default:
+		fmt.Fprintf(w, "%s BAD unknown UID command\r\n", tag)
+	}
+}
+
+func parseSet(set string) map[uint32]bool {
+	out := make(map[uint32]bool)
+	for _, part := range strings.Split(set, ",") {
+		lo, hi, ok := strings.Cut(part, ":")
+		if !ok {
+			hi = lo
+		}
+		a, _ := strconv.ParseUint(lo, 10, 32)
+		b, _ := strconv.ParseUint(hi, 10, 32)
+		for n := a; n <= b; n++ {
+			out[uint32(n)] = true
+		}
+	}
+	return out
+}
+
+func rawMessage(from, subject string) string {
+	return "From: " + from + "\r\nSubject: " + subject + "\r\nDate: Mon, 2 Jan 2006 15:04:05 +0000\r\n" +
+		"List-Unsubscribe: <https://example.com/u>\r\nContent-Type: text/plain\r\n\r\nHello from " + from + "\r\n"
+}
+
+func newTestProvider(srv *fakeServer) *imapProvider {
+	return &imapProvider{cfg: IMAPConfig{Addr: "imap.example.com:993", User: "me@example.com", Password: "pw"}, dial: srv.dial}
+}
+
+func cachedIDs(t *testing.T, st gmail.MessageStore) map[string]model.MessageRef {
+	t.Helper()
+	msgs, err := st.LoadAllMessages(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]model.MessageRef)
+	for _, m := range msgs {
+		out[m.ID] = m
+	}
+	return out
+}
+
+func TestIMAPSync(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeServer()
+	news := srv.add("INBOX", rawMessage("News <news@example.com>", "Weekly"))
+	bill := srv.add("INBOX", rawMessage("bills@example.com", "Invoice"), `\Seen`, `\Flagged`)
+	p := newTestProvider(srv)
+	st := store.NewMemoryStore()
+
+	if err := Sync(ctx, p, st, "INBOX", gmail.SyncOptions{}, nil); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	got := cachedIDs(t, st)
+	first := got[strconv.Itoa(int(news))]
+	if len(got) != 2 || first.From != "news@example.com" || first.Subject != "Weekly" || first.ListUnsubscribe == "" {
+		t.Fatalf("cache after first sync = %+v", got)
+	}
+	if !slices.Equal(first.LabelIDs, []string{"INBOX", "UNREAD"}) {
+		t.Errorf("unread message labels = %v", first.LabelIDs)
+	}
+	if labels := got[strconv.Itoa(int(bill))].LabelIDs; !slices.Equal(labels, []string{"INBOX", "STARRED"}) {
+		t.Errorf("starred message labels = %v", labels)
+	}
+
+	// Another client reads one message and deletes the other; mail arrives.
+	srv.mu.Lock()
+	srv.folders["INBOX"] = []fakeMessage{{uid: news, flags: []string{`\Seen`}, raw: rawMessage("News <news@example.com>", "Weekly")}}
+	srv.mu.Unlock()
+	promo := srv.add("INBOX", rawMessage("promo@example.com", "Sale"))
+	var added []model.MessageRef
+	opts := gmail.SyncOptions{NewMessages: func(msgs []model.MessageRef) { added = append(added, msgs...) }}
+	if err := Sync(ctx, p, st, "INBOX", opts, nil); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	got = cachedIDs(t, st)
+	if len(got) != 2 || got[strconv.Itoa(int(promo))].From != "promo@example.com" {
+		t.Errorf("cache after second sync = %+v", got)
+	}
+	if slices.Contains(got[strconv.Itoa(int(news))].LabelIDs, "UNREAD") {
+		t.Error("message read elsewhere is still unread")
+	}
+	if len(added) != 1 || added[0].From != "promo@example.com" {
+		t.Errorf("NewMessages got %+v", added)
+	}
+
+	// UIDs reassigned: the cache is rebuilt.
+	srv.mu.Lock()
+	srv.validity++
+	srv.mu.Unlock()
+	added = nil
+	if err := Sync(ctx, p, st, "INBOX", opts, nil); err != nil {
+		t.Fatalf("sync after UIDVALIDITY change: %v", err)
+	}
+	if n, _ := st.CountMessages(ctx); n != 2 || added != nil {
+		t.Errorf("after UIDVALIDITY change: %d cached, %d reported new", n, len(added))
+	}
+
+	if err := Sync(ctx, p, st, "INBOX", gmail.SyncOptions{Query: "older_than:1y"}, nil); err == nil {
+		t.Error("sync with a Gmail query succeeded on IMAP")
+	}
+}
+
+func TestIMAPActions(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeServer()
+	a := srv.add("INBOX", rawMessage("a@example.com", "One"))
+	b := srv.add("INBOX", rawMessage("b@example.com", "Two"))
+	p := newTestProvider(srv)
+	if err := Sync(ctx, p, store.NewMemoryStore(), "", gmail.SyncOptions{}, nil); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	body, err := p.Body(ctx, strconv.Itoa(int(a)))
+	if err != nil || !strings.Contains(body.Text, "Hello from a@example.com") {
+		t.Errorf("Body = %q, %v", body.Text, err)
+	}
+
+	dry := gmail.WithDryRun(ctx, nil)
+	if err := p.MarkRead(dry, []string{strconv.Itoa(int(a))}); !errors.Is(err, gmail.ErrDryRun) {
+		t.Errorf("dry-run MarkRead = %v", err)
+	}
+	if err := p.MarkRead(ctx, []string{strconv.Itoa(int(a))}); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if got := srv.flags("INBOX", a); !slices.Equal(got, []string{`\Seen`}) {
+		t.Errorf("flags after MarkRead = %v", got)
+	}
+	if err := p.Archive(dry, []string{strconv.Itoa(int(a))}); !errors.Is(err, gmail.ErrDryRun) {
+		t.Errorf("dry-run Archive = %v", err)
+	}
+	if err := p.Archive(ctx, []string{strconv.Itoa(int(a))}); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	// A dropped connection is redialled once.
+	srv.hangUp()
+	if err := p.Trash(ctx, []string{strconv.Itoa(int(b))}); err != nil {
+		t.Fatalf("Trash after hang-up: %v", err)
+	}
+	if in, arch, bin := srv.uids("INBOX"), srv.uids("Archive"), srv.uids("Bin"); len(in) != 0 || !slices.Equal(arch, []uint32{a}) || !slices.Equal(bin, []uint32{b}) {
+		t.Errorf("folders after actions: INBOX %v, Archive %v, Bin %v", in, arch, bin)
+	}
+
+	// A move whose answer was lost may have happened; it is not repeated.
+	c := srv.add("INBOX", rawMessage("c@example.com", "Three"))
+	srv.mu.Lock()
+	srv.hangUpAfter = "MOVE"
+	srv.mu.Unlock()
+	if err := p.Archive(ctx, []string{strconv.Itoa(int(c))}); err == nil {
+		t.Error("Archive succeeded although the connection dropped before the answer")
+	}
+	if arch := srv.uids("Archive"); !slices.Equal(arch, []uint32{a, c}) {
+		t.Errorf("Archive folder after a lost answer = %v", arch)
+	}
+}
+
+func TestIMAPScope(t *testing.T) {
+	p := newTestProvider(newFakeServer())
+	ctx := context.Background()
+	for label, folder := range map[string]string{"": "INBOX", gmail.AllMail: "INBOX", "Lists/Go": "Lists/Go"} {
+		scope, err := p.Scope(ctx, label)
+		if err != nil || p.folder(scope) != folder {
+			t.Errorf("Scope(%q) = %q, %v; folder %q, want %q", label, scope, err, p.folder(scope), folder)
+		}
+	}
+	if other := IMAP(IMAPConfig{Addr: "mail.example.org", User: "me@example.com"}).(*imapProvider); other.cfg.Addr != "mail.example.org:993" {
+		t.Errorf("default port: Addr = %q", other.cfg.Addr)
+	}
+}
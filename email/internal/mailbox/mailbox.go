@@ -0,0 +1,63 @@
+// Package mailbox puts the mail services chuckterm triages behind one
+// interface: Gmail, through the gmail package, and any IMAP server, such as
+// Fastmail, iCloud or a self-hosted one.
+//
+// Providers cover the sender-group workflow: caching headers, reading
+// messages, and archiving, trashing and marking them read. Labels, rules, snoozing and
+// mailto: unsubscribes are Gmail's alone and keep using the gmail package.
+package mailbox
+
+import (
+	"context"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+)
+
+// Provider is one mail account.
+type Provider interface {
+	// Account names the signed-in account, such as an email address.
+	Account(ctx context.Context) (string, error)
+	// Scope names what Sync caches for label, such as a Gmail label ID or
+	// an IMAP folder, for gmail.EnsureLabelScope to reset a cache that was
+	// filled from somewhere else.
+	Scope(ctx context.Context, label string) (string, error)
+	// Sync brings store up to date with opts.Label: all of it the first
+	// time or after an interrupted sync, otherwise what changed since the
+	// last one.
+	Sync(ctx context.Context, store gmail.MessageStore, opts gmail.SyncOptions, progress func(gmail.SyncProgress)) error
+	// Extend reaches the scan window (see gmail.ScanSince) back to since,
+	// or to the oldest mail for the zero time, caching the older mail it
+	// takes in, and returns how many messages that added.
+	Extend(ctx context.Context, store gmail.MessageStore, opts gmail.SyncOptions, since time.Time, progress func(gmail.SyncProgress)) (int, error)
+	// Archive takes messages out of the inbox, and Trash moves them to the
+	// trash. Both return gmail.ErrDryRun under gmail.WithDryRun.
+	Archive(ctx context.Context, ids []string) error
+	Trash(ctx context.Context, ids []string) error
+	// MarkRead marks messages read without moving them. It returns
+	// gmail.ErrDryRun under gmail.WithDryRun.
+	MarkRead(ctx context.Context, ids []string) error
+	// Body reads a message, and SaveAttachment writes one of the
+	// attachments Body listed into dir, returning the path written.
+	Body(ctx context.Context, id string) (model.MessageBody, error)
+	SaveAttachment(ctx context.Context, id string, att model.Attachment, dir string) (string, error)
+}
+
+// Sync resolves label, resets store when it holds another scope or was
+// synced with another opts.Query, and syncs it, as chuckterm sync and the
+// daemon do.
+func Sync(ctx context.Context, p Provider, store gmail.MessageStore, label string, opts gmail.SyncOptions, progress func(gmail.SyncProgress)) error {
+	scope, err := p.Scope(ctx, label)
+	if err != nil {
+		return err
+	}
+	if _, err := gmail.EnsureLabelScope(ctx, store, scope); err != nil {
+		return err
+	}
+	if _, err := gmail.EnsureQueryScope(ctx, store, opts.Query); err != nil {
+		return err
+	}
+	opts.Label = scope
+	return p.Sync(ctx, store, opts, progress)
+}
@@ -1,35 +1,268 @@
 package model
 
+import (
+	"strings"
+	"time"
+
+	"chuckterm/internal/ics"
+)
+
 // MessageRef holds the minimal info we need for trash/undo and previews.
 type MessageRef struct {
 	ID                 string
 	Subject            string
 	DateRFC3339        string
 	From               string
+	FromName           string // display name from the From header; "" if absent or cached before names were stored
 	ListUnsubscribe    string // List-Unsubscribe header value
 	ListUnsubscribePost string // List-Unsubscribe-Post header value
+	LabelIDs            []string // Gmail label IDs (UNREAD, STARRED, IMPORTANT, CATEGORY_*, user labels)
+	SizeBytes           int64    // Gmail's size estimate; 0 if cached before sizes were stored
+	Snippet             string   // Gmail's plain-text excerpt of the body; "" if cached before snippets were stored
+	Precedence          string   // Precedence header value ("bulk", "list", ...)
+	XMailer             string   // X-Mailer header value
+	ListID              string   // List-Id header value
+}
+
+// HasLabel reports whether the message carries the given label ID.
+func (m MessageRef) HasLabel(id string) bool {
+	for _, l := range m.LabelIDs {
+		if l == id {
+			return true
+		}
+	}
+	return false
 }
 
+// Unread reports whether the message carries the UNREAD label.
+func (m MessageRef) Unread() bool { return m.HasLabel("UNREAD") }
+
+// BulkPrecedences are the Precedence header values automated mail declares.
+var BulkPrecedences = []string{"bulk", "list", "junk"}
+
+// BulkMailers are lowercase fragments of the X-Mailer headers newsletter and
+// marketing services send.
+var BulkMailers = []string{"mailchimp", "sendgrid", "mailgun", "sendinblue", "brevo", "hubspot", "marketo", "klaviyo", "mailjet", "mailerlite", "constant contact", "campaign monitor", "phplist"}
+
+// Bulk reports whether the message looks like automated bulk mail rather
+// than correspondence: it came through a mailing list (List-Id or
+// List-Unsubscribe), declares a bulk Precedence, or was sent by a known
+// newsletter service. Messages cached before the Precedence, X-Mailer and
+// List-Id headers were stored are judged by List-Unsubscribe alone.
+func (m MessageRef) Bulk() bool {
+	if m.ListID != "" || m.ListUnsubscribe != "" {
+		return true
+	}
+	precedence := strings.ToLower(strings.TrimSpace(m.Precedence))
+	for _, p := range BulkPrecedences {
+		if precedence == p {
+			return true
+		}
+	}
+	mailer := strings.ToLower(m.XMailer)
+	for _, b := range BulkMailers {
+		if strings.Contains(mailer, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalIDPrefix marks messages that exist only in the local cache (e.g. .eml
+// files imported without uploading them to Gmail). API actions skip them.
+const LocalIDPrefix = "eml:"
+
+// IsLocalID reports whether id refers to a local-only message.
+func IsLocalID(id string) bool { return strings.HasPrefix(id, LocalIDPrefix) }
+
 // SenderGroup aggregates messages by normalized sender email.
 type SenderGroup struct {
 	Email          string
 	Subject        string   // exact, case-sensitive subject used for grouping (may be empty)
 	DisplayName    string
 	Count          int
+	Unread         int      // messages carrying the UNREAD label
 	Sample         string   // representative subject/snippet
 	FirstDate      string   // oldest RFC3339 among grouped
 	LastDate       string   // newest RFC3339 among grouped
+	Size           int64    // summed size estimate of the grouped messages, in bytes
+	Months         map[string]int // messages per month of their date, keyed "2006-01"
 	MessageIDs     []string // all Gmail message IDs in this group
 	UnsubscribeURL string   // first HTTP unsubscribe link found in group (empty if none)
+	// UnsubscribeOneClick is set when the message carrying UnsubscribeURL
+	// advertised RFC 8058 one-click unsubscription.
+	UnsubscribeOneClick bool
+	// UnsubscribeMailto is the first mailto: unsubscribe link found in the
+	// group, for senders without an HTTP one.
+	UnsubscribeMailto string
+	// Unsubscribed is set once chuckterm unsubscribed from the sender; see
+	// gmail.ApplyUnsubscribes.
+	Unsubscribed *Unsubscription
+	Pinned         bool     // kept at the top of the list regardless of sort order
+	Status         SenderStatus // protected or blocked sender; see gmail.ApplySenderLists
+	Senders        int        // distinct sender addresses; set on domain groups
+	Bulk           bool       // some message in the group looks like bulk mail (see MessageRef.Bulk)
+	Members        []GroupKey // exact sender+subject groups merged into this one (domain or normalized-subject groups)
+}
+
+// IsDomain reports whether g gathers every sender of a domain (see
+// gmail.ByDomain) rather than one sender and subject.
+func (g SenderGroup) IsDomain() bool { return strings.HasPrefix(g.Email, "@") }
+
+// GroupKey identifies a sender+subject group independently of its contents.
+type GroupKey struct {
+	Email   string
+	Subject string
 }
 
 func (g SenderGroup) FilterValue() string { return g.DisplayName }
 func (g SenderGroup) Title() string       { return g.DisplayName }
 func (g SenderGroup) Description() string { return g.Subject }
 
+// GroupSummary is a sender+subject aggregate computed by the store without
+// materialising the individual messages (used by low-memory mode).
+type GroupSummary struct {
+	Email           string
+	Subject         string
+	Count           int
+	Unread          int
+	FirstDate       string
+	LastDate        string
+	Size            int64
+	ListUnsubscribe string // one List-Unsubscribe header from the group, preferring HTTP links
+	OneClick        bool   // some message in the group advertised one-click unsubscription
+	Pinned          bool
+	Months          map[string]int // messages per month, keyed "2006-01"
+	Bulk            bool           // some message in the group looks like bulk mail
+	FromName        string         // display name of the newest message that has one
+}
+
+// GroupTotals sizes the cache when only some groups are loaded.
+type GroupTotals struct {
+	Groups   int // sender+subject groups
+	Messages int
+	Unread   int
+}
+
+// Action records an archive, trash or unsubscribe done through chuckterm,
+// for the activity figures of the stats view.
+type Action struct {
+	Time     time.Time
+	Kind     string // "archive", "trash" or "unsubscribe"
+	Sender   string
+	Subject  string // "" when the action covered every subject of the sender
+	Messages int    // messages archived or trashed; 0 for unsubscribe
+}
+
+// Unsubscription records the latest unsubscribe from a sender done through
+// chuckterm.
+type Unsubscription struct {
+	Sender string
+	Time   time.Time
+	Method string // "one-click", "browser" or "mailto"; see gmail.UnsubscribeMethod
+	URL    string // the link posted to or opened, or the mailto: link written to
+}
+
+// Snooze is a message taken out of the inbox until Until, when it is put
+// back.
+type Snooze struct {
+	ID      string // Gmail message ID
+	Until   time.Time
+	From    string
+	Subject string
+}
+
+// Trashed is a message moved to the trash, remembered so it can be
+// restored.
+type Trashed struct {
+	ID      string // Gmail message ID
+	From    string
+	Subject string
+	Date    string    // the message's date, RFC 3339
+	At      time.Time // when it was trashed; zero for mail only found in Gmail's trash
+}
+
+// SenderStatus marks a sender, or a whole domain, for special handling.
+type SenderStatus string
+
+const (
+	// SenderProtected senders are left out of bulk and automatic actions.
+	SenderProtected SenderStatus = "protected"
+	// SenderBlocked senders have their new mail moved to the trash on sync.
+	SenderBlocked SenderStatus = "blocked"
+)
+
+// SenderRule gives a sender a status. Match is either a full address
+// ("news@example.com") or a domain prefixed with "@" ("@example.com"), which
+// also covers its subdomains.
+type SenderRule struct {
+	Match  string
+	Status SenderStatus
+}
+
+// RuleAction is what a rule does to the messages it matches.
+type RuleAction string
+
+const (
+	RuleArchive RuleAction = "archive"
+	RuleTrash   RuleAction = "trash"
+	RuleLabel   RuleAction = "label" // add AddLabel
+	RuleRead    RuleAction = "read"  // mark as read
+)
+
+// Rule is an automatic action run over the cache after each sync, or on its
+// own schedule by the daemon. A message matches when it meets every condition
+// that is set.
+type Rule struct {
+	ID          int64
+	Sender      string // address, or @domain covering its subdomains
+	Subject     string // regular expression, matched ignoring case
+	OlderThan   string // age such as 30d or 6mo
+	Label       string // label name or ID the message carries
+	Unsubscribe bool   // only messages with a List-Unsubscribe header
+	Action      RuleAction
+	AddLabel    string // label name for RuleLabel
+	Disabled    bool
+	// Every is the schedule of a cleanup job, such as @daily or 12h; ""
+	// runs the rule after each sync instead.
+	Every string
+}
+
+// RuleRun records one run of a rule: the scheduled runs of cleanup jobs,
+// and the runs after a sync that changed something or failed.
+type RuleRun struct {
+	RuleID   int64
+	Time     time.Time
+	Rule     string // the rule as written when it ran
+	Messages int    // messages it changed
+	Err      string
+}
+
+// Attachment describes a file attached to a message.
+type Attachment struct {
+	PartID       string `json:"part_id"`
+	Filename     string `json:"filename"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
+	AttachmentID string `json:"attachment_id,omitempty"` // empty when the data is inline in the part
+}
+
+// MessageBody is the readable content of a message.
+type MessageBody struct {
+	Text        string
+	Attachments []Attachment
+	Invites     []ics.Event // calendar events the message carries
+}
+
 // FetchProgress is sent from the fetcher to the UI as pages stream in.
 type FetchProgress struct {
 	AddOrUpdate []SenderGroup // incremental snapshot for replacements
 	Completed   bool
 	Err         error
-}
\ No newline at end of file
+}
+// Contact is an address mail has come from, offered when addressing mail.
+type Contact struct {
+	Email    string
+	Name     string // display name of the newest message that had one
+	LastSeen string // RFC 3339 date of the newest message from it
+}
@@ -0,0 +1,266 @@
+// Package push receives Gmail change notifications delivered through Cloud
+// Pub/Sub, either as push requests to a local webhook or by pulling from a
+// subscription.
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2/google"
+)
+
+// Config selects how notifications are received. Topic is required; exactly
+// one of WebhookAddr and Subscription should be set.
+type Config struct {
+	// Topic is the Pub/Sub topic Gmail publishes to,
+	// e.g. projects/my-project/topics/gmail.
+	Topic string
+	// WebhookAddr is the listen address (e.g. ":8085") for a push
+	// subscription's endpoint.
+	WebhookAddr string
+	// WebhookToken must match the "token" query parameter of incoming push
+	// requests. It is required with WebhookAddr, since the endpoint is
+	// publicly reachable.
+	WebhookToken string
+	// Subscription is a pull subscription,
+	// e.g. projects/my-project/subscriptions/chuckterm.
+	Subscription string
+}
+
+// Enabled reports whether push notifications are configured.
+func (c Config) Enabled() bool { return c.Topic != "" }
+
+// Validate checks that exactly one delivery mode is configured, and that a
+// webhook has a token.
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if (c.WebhookAddr == "") == (c.Subscription == "") {
+		return errors.New("push: set exactly one of a webhook address or a pull subscription")
+	}
+	if c.WebhookAddr != "" && c.WebhookToken == "" {
+		return errors.New("push: a webhook needs a token")
+	}
+	return nil
+}
+
+// Notification is the payload Gmail publishes when the mailbox changes.
+type Notification struct {
+	EmailAddress string `json:"emailAddress"`
+	HistoryID    uint64 `json:"historyId"`
+}
+
+// pubsubMessage is the Pub/Sub message envelope shared by push and pull.
+type pubsubMessage struct {
+	Data      string `json:"data"`
+	MessageID string `json:"messageId"`
+}
+
+func decode(m pubsubMessage) (Notification, error) {
+	var n Notification
+	raw, err := base64.StdEncoding.DecodeString(m.Data)
+	if err != nil {
+		return n, fmt.Errorf("decode notification data: %w", err)
+	}
+	// historyId arrives as a JSON number but may exceed float precision in
+	// other clients; accept both numbers and strings.
+	var aux struct {
+		EmailAddress string          `json:"emailAddress"`
+		HistoryID    json.RawMessage `json:"historyId"`
+	}
+	if err := json.Unmarshal(raw, &aux); err != nil {
+		return n, fmt.Errorf("decode notification: %w", err)
+	}
+	id, err := strconv.ParseUint(string(bytes.Trim(aux.HistoryID, `"`)), 10, 64)
+	if err != nil {
+		return n, fmt.Errorf("decode notification historyId: %w", err)
+	}
+	return Notification{EmailAddress: aux.EmailAddress, HistoryID: id}, nil
+}
+
+// Handler returns an http.Handler for a Pub/Sub push subscription endpoint.
+// Valid notifications are passed to notify; malformed ones are acknowledged
+// anyway so Pub/Sub does not redeliver them forever.
+func Handler(token string, notify func(Notification)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var body struct {
+			Message pubsubMessage `json:"message"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body); err == nil {
+			if n, err := decode(body.Message); err == nil {
+				notify(n)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Serve runs the webhook on addr until ctx is cancelled.
+func Serve(ctx context.Context, addr, token string, notify func(Notification)) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("push webhook: %w", err)
+	}
+	srv := &http.Server{Handler: Handler(token, notify), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("push webhook: %w", err)
+	}
+	return ctx.Err()
+}
+
+// pubsubEndpoint is the Pub/Sub REST base URL; tests point it at a fake.
+var pubsubEndpoint = "https://pubsub.googleapis.com/v1/"
+
+const pubsubScope = "https://www.googleapis.com/auth/pubsub"
+
+// Pull receives notifications from a pull subscription until ctx is
+// cancelled, authenticating with Application Default Credentials.
+func Pull(ctx context.Context, subscription string, notify func(Notification)) error {
+	client, err := google.DefaultClient(ctx, pubsubScope)
+	if err != nil {
+		return fmt.Errorf("pubsub credentials: %w", err)
+	}
+	return pull(ctx, client, subscription, notify)
+}
+
+func pull(ctx context.Context, client *http.Client, subscription string, notify func(Notification)) error {
+	failures := 0
+	for ctx.Err() == nil {
+		var resp struct {
+			ReceivedMessages []struct {
+				AckID   string        `json:"ackId"`
+				Message pubsubMessage `json:"message"`
+			} `json:"receivedMessages"`
+		}
+		err := post(ctx, client, subscription+":pull", map[string]any{"maxMessages": 100}, &resp)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			failures++
+			if failures >= 5 {
+				return fmt.Errorf("pubsub pull: %w", err)
+			}
+			sleep(ctx, time.Duration(failures)*2*time.Second)
+			continue
+		}
+		failures = 0
+		var ackIDs []string
+		for _, rm := range resp.ReceivedMessages {
+			ackIDs = append(ackIDs, rm.AckID)
+			if n, err := decode(rm.Message); err == nil {
+				notify(n)
+			}
+		}
+		if len(ackIDs) > 0 {
+			if err := post(ctx, client, subscription+":acknowledge", map[string]any{"ackIds": ackIDs}, nil); err != nil && ctx.Err() == nil {
+				return fmt.Errorf("pubsub acknowledge: %w", err)
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+func post(ctx context.Context, client *http.Client, path string, body, out any) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pubsubEndpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// renewEvery is how often the Gmail watch is re-registered. Watches expire
+// after seven days; Google recommends renewing daily.
+var renewEvery = 24 * time.Hour
+
+// Run registers the Gmail watch via register, renews it daily, and delivers
+// notifications from the configured transport to notify until ctx is
+// cancelled or the transport fails.
+func Run(ctx context.Context, cfg Config, register func(ctx context.Context) error, notify func(Notification)) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := register(ctx); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	renewErr := make(chan error, 1)
+	go func() {
+		t := time.NewTicker(renewEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := register(ctx); err != nil && ctx.Err() == nil {
+					renewErr <- err
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	var err error
+	if cfg.WebhookAddr != "" {
+		err = Serve(ctx, cfg.WebhookAddr, cfg.WebhookToken, notify)
+	} else {
+		err = Pull(ctx, cfg.Subscription, notify)
+	}
+	select {
+	case rerr := <-renewErr:
+		return rerr
+	default:
+		return err
+	}
+}
@@ -0,0 +1,104 @@
+package push
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func envelope(data string) string {
+	return base64.StdEncoding.EncodeToString([]byte(data))
+}
+
+func TestHandler(t *testing.T) {
+	var got []Notification
+	h := Handler("s3cret", func(n Notification) { got = append(got, n) })
+
+	body := `{"message":{"data":"` + envelope(`{"emailAddress":"a@example.com","historyId":12345}`) + `","messageId":"1"}}`
+	cases := []struct {
+		url  string
+		body string
+		want int
+	}{
+		{"/?token=s3cret", body, http.StatusNoContent},
+		{"/?token=wrong", body, http.StatusForbidden},
+		{"/?token=s3cret", `{"message":{"data":"!!"}}`, http.StatusNoContent},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, c.url, strings.NewReader(c.body)))
+		if rec.Code != c.want {
+			t.Errorf("POST %s: status %d, want %d", c.url, rec.Code, c.want)
+		}
+	}
+	if len(got) != 1 || got[0] != (Notification{EmailAddress: "a@example.com", HistoryID: 12345}) {
+		t.Fatalf("notifications = %+v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	topic := "projects/p/topics/gmail"
+	cases := []struct {
+		cfg Config
+		ok  bool
+	}{
+		{Config{}, true},
+		{Config{Topic: topic, WebhookAddr: ":8085", WebhookToken: "s3cret"}, true},
+		{Config{Topic: topic, WebhookAddr: ":8085"}, false},
+		{Config{Topic: topic, Subscription: "projects/p/subscriptions/s"}, true},
+		{Config{Topic: topic}, false},
+		{Config{Topic: topic, WebhookAddr: ":8085", WebhookToken: "s3cret", Subscription: "projects/p/subscriptions/s"}, false},
+	}
+	for _, c := range cases {
+		if err := c.cfg.Validate(); (err == nil) != c.ok {
+			t.Errorf("Validate(%+v) = %v", c.cfg, err)
+		}
+	}
+}
+
+func TestPull(t *testing.T) {
+	var mu sync.Mutex
+	var acked []string
+	pulls := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":pull"):
+			pulls++
+			if pulls > 1 {
+				cancel()
+				json.NewEncoder(w).Encode(map[string]any{})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"receivedMessages": []map[string]any{
+				{"ackId": "ack-1", "message": map[string]string{"data": envelope(`{"emailAddress":"a@example.com","historyId":"99"}`)}},
+			}})
+		case strings.HasSuffix(r.URL.Path, ":acknowledge"):
+			var req struct{ AckIDs []string }
+			json.NewDecoder(r.Body).Decode(&req)
+			acked = append(acked, req.AckIDs...)
+		}
+	}))
+	defer srv.Close()
+	old := pubsubEndpoint
+	pubsubEndpoint = srv.URL + "/v1/"
+	defer func() { pubsubEndpoint = old }()
+
+	var got []Notification
+	pull(ctx, srv.Client(), "projects/p/subscriptions/s", func(n Notification) { got = append(got, n) })
+	if len(got) != 1 || got[0].HistoryID != 99 {
+		t.Fatalf("notifications = %+v", got)
+	}
+	if len(acked) != 1 || acked[0] != "ack-1" {
+		t.Fatalf("acked = %v", acked)
+	}
+}
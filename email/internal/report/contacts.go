@@ -0,0 +1,118 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chuckterm/internal/model"
+)
+
+// Contact is one distinct sender with the volume of mail they sent.
+type Contact struct {
+	Email     string
+	Name      string // most recent display name; "" if none is cached
+	Count     int
+	Unread    int
+	FirstDate string
+	LastDate  string
+}
+
+// Contacts lists the distinct senders of msgs, most messages first.
+func Contacts(msgs []model.MessageRef) []Contact {
+	byEmail := make(map[string]*Contact)
+	named := make(map[string]string) // date of the message Name came from
+	for _, m := range msgs {
+		if m.From == "" {
+			continue
+		}
+		c, ok := byEmail[m.From]
+		if !ok {
+			c = &Contact{Email: m.From}
+			byEmail[m.From] = c
+		}
+		c.Count++
+		if m.Unread() {
+			c.Unread++
+		}
+		if ts := m.DateRFC3339; ts != "" {
+			if c.FirstDate == "" || ts < c.FirstDate {
+				c.FirstDate = ts
+			}
+			if c.LastDate == "" || ts > c.LastDate {
+				c.LastDate = ts
+			}
+		}
+		if m.FromName != "" && (c.Name == "" || m.DateRFC3339 > named[m.From]) {
+			c.Name = m.FromName
+			named[m.From] = m.DateRFC3339
+		}
+	}
+	out := make([]Contact, 0, len(byEmail))
+	for _, c := range byEmail {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count == out[j].Count {
+			return out[i].Email < out[j].Email
+		}
+		return out[i].Count > out[j].Count
+	})
+	return out
+}
+
+// WriteContactsCSV writes contacts as CSV with a header row. Dates are
+// YYYY-MM-DD.
+func WriteContactsCSV(w io.Writer, contacts []Contact) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "email", "messages", "unread", "first_seen", "last_seen"})
+	for _, c := range contacts {
+		cw.Write([]string{c.Name, c.Email, strconv.Itoa(c.Count), strconv.Itoa(c.Unread), day(c.FirstDate), day(c.LastDate)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteVCards writes contacts as vCard 3.0, which address books such as
+// Google Contacts and macOS Contacts import. Contacts without a name are
+// named after their address.
+func WriteVCards(w io.Writer, contacts []Contact) error {
+	for _, c := range contacts {
+		name := c.Name
+		if name == "" {
+			name = c.Email
+		}
+		note := fmt.Sprintf("%d messages", c.Count)
+		if c.FirstDate != "" {
+			note += fmt.Sprintf(", first %s, last %s", day(c.FirstDate), day(c.LastDate))
+		}
+		lines := []string{
+			"BEGIN:VCARD",
+			"VERSION:3.0",
+			"FN:" + vcardEscape(name),
+			"N:;" + vcardEscape(name) + ";;;",
+			"EMAIL;TYPE=INTERNET:" + c.Email,
+			"NOTE:" + vcardEscape(note),
+			"END:VCARD",
+		}
+		if _, err := io.WriteString(w, strings.Join(lines, "\r\n")+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vcardEscape escapes a text value (RFC 2426 section 4).
+func vcardEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\n", `\n`).Replace(s)
+}
+
+func day(rfc3339 string) string {
+	if len(rfc3339) >= 10 {
+		return rfc3339[:10]
+	}
+	return rfc3339
+}
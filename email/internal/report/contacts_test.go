@@ -0,0 +1,59 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestContacts(t *testing.T) {
+	msgs := []model.MessageRef{
+		{From: "a@x.com", FromName: "Ann", DateRFC3339: "2024-01-05T00:00:00Z"},
+		{From: "a@x.com", FromName: "Ann Lee", DateRFC3339: "2024-02-05T00:00:00Z", LabelIDs: []string{"UNREAD"}},
+		{From: "a@x.com", DateRFC3339: "2024-03-05T00:00:00Z"},
+		{From: "b@y.com", DateRFC3339: "2024-02-06T00:00:00Z"},
+		{From: ""},
+	}
+	got := Contacts(msgs)
+	if len(got) != 2 {
+		t.Fatalf("Contacts = %+v", got)
+	}
+	a := got[0]
+	if a.Email != "a@x.com" || a.Name != "Ann Lee" || a.Count != 3 || a.Unread != 1 ||
+		a.FirstDate != "2024-01-05T00:00:00Z" || a.LastDate != "2024-03-05T00:00:00Z" {
+		t.Fatalf("first contact = %+v", a)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteContactsCSV(&buf, got); err != nil {
+		t.Fatal(err)
+	}
+	want := "name,email,messages,unread,first_seen,last_seen\n" +
+		"Ann Lee,a@x.com,3,1,2024-01-05,2024-03-05\n" +
+		",b@y.com,1,0,2024-02-06,2024-02-06\n"
+	if buf.String() != want {
+		t.Fatalf("CSV:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	got[0].Name = "Lee, Ann"
+	if err := WriteVCards(&buf, got); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"FN:Lee\\, Ann\r\n",
+		"EMAIL;TYPE=INTERNET:a@x.com\r\n",
+		"NOTE:3 messages\\, first 2024-01-05\\, last 2024-03-05\r\n",
+		"FN:b@y.com\r\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("vCard output lacks %q:\n%s", line, out)
+		}
+	}
+	if n := strings.Count(out, "BEGIN:VCARD"); n != 2 {
+		t.Errorf("%d vCards, want 2", n)
+	}
+}
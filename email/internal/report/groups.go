@@ -0,0 +1,53 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+// WriteGroupsCSV writes sender groups as CSV with a header row, one row per
+// group in order. Dates are YYYY-MM-DD. The unsubscribe column is
+// one-click, browser, mailto or none, and unsubscribed the day chuckterm
+// last unsubscribed from the sender.
+func WriteGroupsCSV(w io.Writer, groups []model.SenderGroup) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"sender", "name", "subject", "messages", "unread", "size_bytes", "first_date", "last_date",
+		"unsubscribe", "unsubscribe_url", "unsubscribed"})
+	for _, g := range groups {
+		link := g.UnsubscribeURL
+		if link == "" {
+			link = g.UnsubscribeMailto
+		}
+		unsubscribed := ""
+		if g.Unsubscribed != nil {
+			unsubscribed = g.Unsubscribed.Time.Format(time.DateOnly)
+		}
+		cw.Write([]string{
+			g.Email, g.DisplayName, g.Subject,
+			strconv.Itoa(g.Count), strconv.Itoa(g.Unread), strconv.FormatInt(g.Size, 10),
+			day(g.FirstDate), day(g.LastDate),
+			UnsubscribeKind(g), link, unsubscribed,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// UnsubscribeKind says how a group can be unsubscribed from: one-click,
+// browser, mailto when it only has a mailto: link, or none when it has no
+// link.
+func UnsubscribeKind(g model.SenderGroup) string {
+	switch {
+	case g.UnsubscribeURL == "" && g.UnsubscribeMailto != "":
+		return "mailto"
+	case g.UnsubscribeURL == "":
+		return "none"
+	case g.UnsubscribeOneClick:
+		return "one-click"
+	}
+	return "browser"
+}
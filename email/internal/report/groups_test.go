@@ -0,0 +1,32 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+func TestWriteGroupsCSV(t *testing.T) {
+	groups := []model.SenderGroup{
+		{Email: "news@shop.example", DisplayName: "Shop", Subject: "Sale, today only", Count: 12, Unread: 3, Size: 4096,
+			FirstDate: "2024-01-05T08:00:00Z", LastDate: "2024-06-01T08:00:00Z",
+			UnsubscribeURL: "https://shop.example/u", UnsubscribeOneClick: true},
+		{Email: "a@x.com", Subject: "hi", Count: 1, FirstDate: "2024-02-05T00:00:00Z", LastDate: "2024-02-05T00:00:00Z"},
+		{Email: "list@lists.example", Subject: "[list] news", Count: 2, FirstDate: "2024-03-01T00:00:00Z", LastDate: "2024-03-02T00:00:00Z",
+			UnsubscribeMailto: "mailto:leave@lists.example",
+			Unsubscribed:      &model.Unsubscription{Time: time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC), Method: "mailto"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteGroupsCSV(&buf, groups); err != nil {
+		t.Fatal(err)
+	}
+	want := "sender,name,subject,messages,unread,size_bytes,first_date,last_date,unsubscribe,unsubscribe_url,unsubscribed\n" +
+		"news@shop.example,Shop,\"Sale, today only\",12,3,4096,2024-01-05,2024-06-01,one-click,https://shop.example/u,\n" +
+		"a@x.com,,hi,1,0,0,2024-02-05,2024-02-05,none,,\n" +
+		"list@lists.example,,[list] news,2,0,0,2024-03-01,2024-03-02,mailto,mailto:leave@lists.example,2024-03-03\n"
+	if buf.String() != want {
+		t.Fatalf("CSV:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,87 @@
+// Package report computes read-only sender and volume summaries from cached
+// messages. It depends only on the model types so it can be linked into the
+// readonly chuckterm-report binary.
+package report
+
+import (
+	"sort"
+	"strings"
+
+	"chuckterm/internal/model"
+)
+
+// Row is one line of a ranked report.
+type Row struct {
+	Key       string // sender address, domain, or month (YYYY-MM)
+	Count     int
+	FirstDate string
+	LastDate  string
+}
+
+// BySender ranks senders by message count, descending.
+func BySender(msgs []model.MessageRef) []Row {
+	return ranked(msgs, func(m model.MessageRef) string { return m.From })
+}
+
+// ByDomain ranks sender domains by message count, descending.
+func ByDomain(msgs []model.MessageRef) []Row {
+	return ranked(msgs, func(m model.MessageRef) string {
+		if at := strings.LastIndexByte(m.From, '@'); at >= 0 {
+			return m.From[at+1:]
+		}
+		return m.From
+	})
+}
+
+// ByMonth returns message counts per calendar month in chronological order.
+// Messages without a parseable date are skipped.
+func ByMonth(msgs []model.MessageRef) []Row {
+	rows := aggregate(msgs, func(m model.MessageRef) string {
+		if len(m.DateRFC3339) < 7 {
+			return ""
+		}
+		return m.DateRFC3339[:7]
+	})
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	return rows
+}
+
+func ranked(msgs []model.MessageRef, key func(model.MessageRef) string) []Row {
+	rows := aggregate(msgs, key)
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count == rows[j].Count {
+			return rows[i].Key < rows[j].Key
+		}
+		return rows[i].Count > rows[j].Count
+	})
+	return rows
+}
+
+func aggregate(msgs []model.MessageRef, key func(model.MessageRef) string) []Row {
+	byKey := make(map[string]*Row)
+	for _, m := range msgs {
+		k := key(m)
+		if k == "" {
+			continue
+		}
+		r, ok := byKey[k]
+		if !ok {
+			r = &Row{Key: k}
+			byKey[k] = r
+		}
+		r.Count++
+		if ts := m.DateRFC3339; ts != "" {
+			if r.FirstDate == "" || ts < r.FirstDate {
+				r.FirstDate = ts
+			}
+			if r.LastDate == "" || ts > r.LastDate {
+				r.LastDate = ts
+			}
+		}
+	}
+	out := make([]Row, 0, len(byKey))
+	for _, r := range byKey {
+		out = append(out, *r)
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package report
+
+import (
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestRankings(t *testing.T) {
+	msgs := []model.MessageRef{
+		{From: "a@x.com", DateRFC3339: "2024-01-05T00:00:00Z"},
+		{From: "a@x.com", DateRFC3339: "2024-02-05T00:00:00Z"},
+		{From: "b@x.com", DateRFC3339: "2024-02-06T00:00:00Z"},
+		{From: "c@y.com"},
+	}
+
+	senders := BySender(msgs)
+	if len(senders) != 3 || senders[0].Key != "a@x.com" || senders[0].Count != 2 {
+		t.Fatalf("BySender = %+v", senders)
+	}
+	if senders[0].FirstDate != "2024-01-05T00:00:00Z" || senders[0].LastDate != "2024-02-05T00:00:00Z" {
+		t.Fatalf("unexpected date range %+v", senders[0])
+	}
+
+	domains := ByDomain(msgs)
+	if len(domains) != 2 || domains[0].Key != "x.com" || domains[0].Count != 3 {
+		t.Fatalf("ByDomain = %+v", domains)
+	}
+
+	months := ByMonth(msgs)
+	if len(months) != 2 || months[0].Key != "2024-01" || months[1].Count != 2 {
+		t.Fatalf("ByMonth = %+v", months)
+	}
+}
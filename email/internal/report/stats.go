@@ -0,0 +1,86 @@
+package report
+
+import (
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+// Stats summarises the cache and the action history for the stats view.
+type Stats struct {
+	Messages int
+	Unread   int
+	Senders  int
+	// WithUnsubscribe counts the messages, and SendersWithUnsubscribe the
+	// senders, carrying an unsubscribe link.
+	WithUnsubscribe        int
+	SendersWithUnsubscribe int
+	TopSenders             []Row // the largest senders by message count
+	Months                 []Row // the last months, oldest first, including empty ones
+	Recent, AllTime        Activity
+}
+
+// Activity totals the actions of one period.
+type Activity struct {
+	Archived, Trashed int // messages
+	Unsubscribed      int // senders
+}
+
+// Summarize computes Stats with the top senders and the months up to now.
+// Actions are counted in Recent when they happened in the recent window
+// before now.
+func Summarize(msgs []model.MessageRef, actions []model.Action, now time.Time, top, months int, recent time.Duration) Stats {
+	s := Stats{Messages: len(msgs)}
+	unsubscribable := make(map[string]bool)
+	for _, m := range msgs {
+		if m.Unread() {
+			s.Unread++
+		}
+		if m.ListUnsubscribe != "" {
+			s.WithUnsubscribe++
+			unsubscribable[m.From] = true
+		}
+	}
+	s.SendersWithUnsubscribe = len(unsubscribable)
+
+	senders := BySender(msgs)
+	s.Senders = len(senders)
+	s.TopSenders = senders[:min(top, len(senders))]
+	s.Months = lastMonths(ByMonth(msgs), now, months)
+
+	for _, a := range actions {
+		s.AllTime.add(a)
+		if now.Sub(a.Time) <= recent {
+			s.Recent.add(a)
+		}
+	}
+	return s
+}
+
+func (t *Activity) add(a model.Action) {
+	switch a.Kind {
+	case "archive":
+		t.Archived += a.Messages
+	case "trash":
+		t.Trashed += a.Messages
+	case "unsubscribe":
+		t.Unsubscribed++
+	}
+}
+
+// lastMonths returns a row for each of the n months up to and including
+// now's, taking counts from rows keyed by month.
+func lastMonths(rows []Row, now time.Time, n int) []Row {
+	counts := make(map[string]Row, len(rows))
+	for _, r := range rows {
+		counts[r.Key] = r
+	}
+	out := make([]Row, n)
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1-n, 0)
+	for i := range out {
+		key := first.AddDate(0, i, 0).Format("2006-01")
+		out[i] = counts[key]
+		out[i].Key = key
+	}
+	return out
+}
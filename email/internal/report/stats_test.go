@@ -0,0 +1,36 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+func TestSummarize(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	msgs := []model.MessageRef{
+		{From: "a@x.com", DateRFC3339: "2024-01-05T00:00:00Z", ListUnsubscribe: "<https://x.com/u>"},
+		{From: "a@x.com", DateRFC3339: "2024-03-05T00:00:00Z", ListUnsubscribe: "<https://x.com/u>", LabelIDs: []string{"UNREAD"}},
+		{From: "b@y.com", DateRFC3339: "2023-06-01T00:00:00Z"},
+	}
+	actions := []model.Action{
+		{Time: now.AddDate(0, -2, 0), Kind: "trash", Messages: 5},
+		{Time: now.AddDate(0, 0, -3), Kind: "archive", Messages: 7},
+		{Time: now.AddDate(0, 0, -1), Kind: "unsubscribe"},
+	}
+	s := Summarize(msgs, actions, now, 1, 3, 30*24*time.Hour)
+	if s.Messages != 3 || s.Unread != 1 || s.Senders != 2 || s.WithUnsubscribe != 2 || s.SendersWithUnsubscribe != 1 {
+		t.Errorf("totals = %+v", s)
+	}
+	if len(s.TopSenders) != 1 || s.TopSenders[0].Key != "a@x.com" {
+		t.Errorf("TopSenders = %+v", s.TopSenders)
+	}
+	if len(s.Months) != 3 || s.Months[0].Key != "2024-01" || s.Months[0].Count != 1 ||
+		s.Months[1].Key != "2024-02" || s.Months[1].Count != 0 || s.Months[2].Count != 1 {
+		t.Errorf("Months = %+v", s.Months)
+	}
+	if s.Recent != (Activity{Archived: 7, Unsubscribed: 1}) || s.AllTime != (Activity{Archived: 7, Trashed: 5, Unsubscribed: 1}) {
+		t.Errorf("Recent = %+v, AllTime = %+v", s.Recent, s.AllTime)
+	}
+}
@@ -0,0 +1,797 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"chuckterm/internal/model"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore keeps the cache in a single bbolt file: pure Go, one writer at a
+// time, and no server or CGO. Values are JSON, encrypted when the store was
+// opened with a passphrase. It supports everything MemoryStore does,
+// persistently, but not the low-memory aggregation of SQLiteStore.
+type BoltStore struct {
+	db     *bolt.DB
+	sealer *sealer
+}
+
+// Bolt buckets. Keys are message IDs, metadata keys, sender matches or,
+// for the logs and rules, big-endian sequence numbers so they iterate in
+// the order they were written.
+var (
+	bucketMessages = []byte("messages")
+	bucketMetadata = []byte("metadata")
+	bucketPinned   = []byte("pinned")
+	bucketBodies   = []byte("bodies")
+	bucketActions  = []byte("actions")
+	bucketSenders  = []byte("senders")
+	bucketRules    = []byte("rules")
+	bucketRuleRuns = []byte("rule_runs")
+	bucketUnsubs   = []byte("unsubscribes")
+	bucketSnoozes  = []byte("snoozes")
+	bucketContacts = []byte("contacts")
+	bucketTrashed  = []byte("trashed")
+
+	// bucketEncryption holds the scrypt salt and a sealed check value of an
+	// encrypted file; it is never encrypted itself.
+	bucketEncryption = []byte("encryption")
+)
+
+// boltFields is bumped when MessageRef gains a field that sync fills in.
+// Opening a file last written with a lower one that holds messages has the
+// next sync fetch their metadata again (see gmail.RefreshMetadata).
+const boltFields = "1"
+
+// Metadata keys for boltFields and the refresh it triggers.
+var (
+	metaBoltFields      = []byte("message_fields")
+	metaRefreshMetadata = []byte("refresh_metadata")
+)
+
+// boltLockTimeout is how long opening waits for another process holding
+// the file, such as the daemon mid-sync, to let go.
+const boltLockTimeout = 5 * time.Second
+
+// NewBoltStore opens (or creates) the bbolt file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	return openBolt(path, "")
+}
+
+// NewEncryptedBoltStore opens (or creates) the bbolt file at path with its
+// values encrypted under passphrase. An existing file must have been created
+// encrypted, with the same passphrase.
+func NewEncryptedBoltStore(path, passphrase string) (*BoltStore, error) {
+	if passphrase == "" {
+		return nil, errors.New("database passphrase is empty")
+	}
+	return openBolt(path, passphrase)
+}
+
+func openBolt(path, passphrase string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create db directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltLockTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("open %s: another chuckterm has it open", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open bolt: %w", err)
+	}
+	s := &BoltStore{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := s.setupEncryption(tx, passphrase); err != nil {
+			return err
+		}
+		for _, b := range [][]byte{bucketMessages, bucketMetadata, bucketPinned, bucketBodies, bucketActions,
+			bucketSenders, bucketRules, bucketRuleRuns, bucketUnsubs, bucketSnoozes, bucketTrashed} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(bucketContacts) == nil {
+			// Files from before contacts were kept start from their messages.
+			if _, err := tx.CreateBucket(bucketContacts); err != nil {
+				return err
+			}
+			err := eachJSON(s, tx, bucketMessages, func(_ []byte, m model.MessageRef) error {
+				return s.seeContact(tx, m)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return s.checkFields(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// checkFields flags the messages of a file from before the latest boltFields
+// bump for a metadata refresh, and records the current one.
+func (s *BoltStore) checkFields(tx *bolt.Tx) error {
+	v, err := s.get(tx, bucketMetadata, metaBoltFields)
+	if err != nil || string(v) == boltFields {
+		return err
+	}
+	if k, _ := tx.Bucket(bucketMessages).Cursor().First(); k != nil {
+		if err := s.put(tx, bucketMetadata, metaRefreshMetadata, []byte("*")); err != nil {
+			return err
+		}
+	}
+	return s.put(tx, bucketMetadata, metaBoltFields, []byte(boltFields))
+}
+
+// setupEncryption checks passphrase against the file, which is new,
+// encrypted or in the clear, and sets s.sealer for an encrypted one.
+func (s *BoltStore) setupEncryption(tx *bolt.Tx, passphrase string) error {
+	enc := tx.Bucket(bucketEncryption)
+	switch {
+	case enc == nil && passphrase == "":
+		return nil
+	case enc == nil && tx.Bucket(bucketMessages) != nil:
+		return errors.New("the cache is not encrypted; move it away to start an encrypted one")
+	case enc == nil:
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		sl, err := newSealer(passphrase, salt)
+		if err != nil {
+			return err
+		}
+		if enc, err = tx.CreateBucket(bucketEncryption); err != nil {
+			return err
+		}
+		if err := enc.Put([]byte("salt"), salt); err != nil {
+			return err
+		}
+		s.sealer = sl
+		return enc.Put([]byte("check"), sl.seal(bucketEncryption, []byte("check"), sealCheck))
+	case passphrase == "":
+		return errors.New("the cache is encrypted and no passphrase was given")
+	}
+	sl, err := newSealer(passphrase, enc.Get([]byte("salt")))
+	if err != nil {
+		return err
+	}
+	if _, v, err := sl.open(bucketEncryption, []byte("check"), enc.Get([]byte("check"))); err != nil || !bytes.Equal(v, sealCheck) {
+		return errors.New("wrong database passphrase")
+	}
+	s.sealer = sl
+	return nil
+}
+
+func (s *BoltStore) Close() error { return s.db.Close() }
+
+func seqKey(n uint64) []byte { return binary.BigEndian.AppendUint64(nil, n) }
+
+// pinKey joins a group key; sender addresses never contain NUL.
+func pinKey(k model.GroupKey) []byte { return []byte(k.Email + "\x00" + k.Subject) }
+
+// put stores value under key in bucket name, sealed if the file is
+// encrypted.
+func (s *BoltStore) put(tx *bolt.Tx, name, key, value []byte) error {
+	return tx.Bucket(name).Put(s.sealer.storedKey(name, key), s.sealer.seal(name, key, value))
+}
+
+// get returns the value under key in bucket name, or nil if there is none.
+// The value is only valid for the life of tx.
+func (s *BoltStore) get(tx *bolt.Tx, name, key []byte) ([]byte, error) {
+	v := tx.Bucket(name).Get(s.sealer.storedKey(name, key))
+	if v == nil {
+		return nil, nil
+	}
+	k, v, err := s.sealer.open(name, key, v)
+	if err == nil && !bytes.Equal(k, key) {
+		err = errSealed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s %q: %w", name, key, err)
+	}
+	return v, nil
+}
+
+func (s *BoltStore) delete(tx *bolt.Tx, name, key []byte) error {
+	return tx.Bucket(name).Delete(s.sealer.storedKey(name, key))
+}
+
+// each calls fn with every key and value of bucket name, in stored key
+// order, which for the buckets with hidden keys is no order at all.
+func (s *BoltStore) each(tx *bolt.Tx, name []byte, fn func(k, v []byte) error) error {
+	return tx.Bucket(name).ForEach(func(stored, v []byte) error {
+		k, v, err := s.sealer.open(name, stored, v)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		return fn(k, v)
+	})
+}
+
+// putJSON stores v under key in bucket name.
+func (s *BoltStore) putJSON(tx *bolt.Tx, name, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.put(tx, name, key, data)
+}
+
+// eachJSON decodes every value of bucket name into a fresh T.
+func eachJSON[T any](s *BoltStore, tx *bolt.Tx, name []byte, fn func(k []byte, v T) error) error {
+	return s.each(tx, name, func(k, data []byte) error {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("decode %s %q: %w", name, k, err)
+		}
+		return fn(k, v)
+	})
+}
+
+func (s *BoltStore) UpsertMessages(ctx context.Context, msgs []model.MessageRef) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, m := range msgs {
+			if err := s.putJSON(tx, bucketMessages, []byte(m.ID), m); err != nil {
+				return err
+			}
+			if err := s.seeContact(tx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// seeContact records the sender of m in the contacts.
+func (s *BoltStore) seeContact(tx *bolt.Tx, m model.MessageRef) error {
+	if m.From == "" {
+		return nil
+	}
+	var c model.Contact
+	data, err := s.get(tx, bucketContacts, []byte(m.From))
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &c); err != nil {
+			return fmt.Errorf("decode contact: %w", err)
+		}
+	}
+	return s.putJSON(tx, bucketContacts, []byte(m.From), seenContact(c, m))
+}
+
+// LoadContacts returns the senders of every message stored, most recently
+// seen first.
+func (s *BoltStore) LoadContacts(ctx context.Context) ([]model.Contact, error) {
+	var out []model.Contact
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketContacts, func(_ []byte, c model.Contact) error {
+			out = append(out, c)
+			return nil
+		})
+	})
+	sortContacts(out)
+	return out, err
+}
+
+func (s *BoltStore) DeleteMessages(ctx context.Context, ids []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			if err := s.delete(tx, bucketMessages, []byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) ClearMessages(ctx context.Context) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketMessages); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(bucketMessages)
+		return err
+	})
+}
+
+func (s *BoltStore) LoadAllMessages(ctx context.Context) ([]model.MessageRef, error) {
+	var out []model.MessageRef
+	err := s.db.View(func(tx *bolt.Tx) error {
+		out = make([]model.MessageRef, 0, tx.Bucket(bucketMessages).Stats().KeyN)
+		return eachJSON(s, tx, bucketMessages, func(_ []byte, m model.MessageRef) error {
+			out = append(out, m)
+			return nil
+		})
+	})
+	return out, err
+}
+
+func (s *BoltStore) GetMessagesByIDs(ctx context.Context, ids []string) ([]model.MessageRef, error) {
+	var out []model.MessageRef
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			data, err := s.get(tx, bucketMessages, []byte(id))
+			if err != nil {
+				return err
+			}
+			if data == nil {
+				continue
+			}
+			var m model.MessageRef
+			if err := json.Unmarshal(data, &m); err != nil {
+				return fmt.Errorf("decode message %s: %w", id, err)
+			}
+			out = append(out, m)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// UpdateLabels replaces the label IDs of cached messages, keyed by message
+// ID. IDs that are not cached are ignored.
+func (s *BoltStore) UpdateLabels(ctx context.Context, labels map[string][]string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for id, ids := range labels {
+			data, err := s.get(tx, bucketMessages, []byte(id))
+			if err != nil {
+				return err
+			}
+			if data == nil {
+				continue
+			}
+			var m model.MessageRef
+			if err := json.Unmarshal(data, &m); err != nil {
+				return fmt.Errorf("decode message %s: %w", id, err)
+			}
+			m.LabelIDs = ids
+			if err := s.putJSON(tx, bucketMessages, []byte(id), m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) CountMessages(ctx context.Context) (int, error) {
+	n := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketMessages).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+func (s *BoltStore) GetLastHistoryID(ctx context.Context) (string, error) {
+	return s.GetMetadata(ctx, "last_history_id")
+}
+
+func (s *BoltStore) SetLastHistoryID(ctx context.Context, historyID string) error {
+	return s.SetMetadata(ctx, "last_history_id", historyID)
+}
+
+// GetMetadata returns the value stored under key, or "" if it is unset.
+func (s *BoltStore) GetMetadata(ctx context.Context, key string) (string, error) {
+	var v string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data, err := s.get(tx, bucketMetadata, []byte(key))
+		v = string(data)
+		return err
+	})
+	return v, err
+}
+
+// SetMetadata stores value under key, replacing any previous value.
+func (s *BoltStore) SetMetadata(ctx context.Context, key, value string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.put(tx, bucketMetadata, []byte(key), []byte(value))
+	})
+}
+
+// SetGroupPinned pins or unpins the sender+subject group.
+func (s *BoltStore) SetGroupPinned(ctx context.Context, key model.GroupKey, pinned bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if pinned {
+			return s.put(tx, bucketPinned, pinKey(key), nil)
+		}
+		return s.delete(tx, bucketPinned, pinKey(key))
+	})
+}
+
+// LoadPinnedGroups returns the keys of all pinned groups.
+func (s *BoltStore) LoadPinnedGroups(ctx context.Context) ([]model.GroupKey, error) {
+	var out []model.GroupKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return s.each(tx, bucketPinned, func(k, _ []byte) error {
+			email, subject, _ := bytes.Cut(k, []byte{0})
+			out = append(out, model.GroupKey{Email: string(email), Subject: string(subject)})
+			return nil
+		})
+	})
+	return out, err
+}
+
+// SetSenderStatus protects or blocks the sender address or @domain match; an
+// empty status removes it from the lists.
+func (s *BoltStore) SetSenderStatus(ctx context.Context, match string, status model.SenderStatus) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if status == "" {
+			return s.delete(tx, bucketSenders, []byte(match))
+		}
+		return s.put(tx, bucketSenders, []byte(match), []byte(status))
+	})
+}
+
+// LoadSenderStatuses returns every protected and blocked sender, ordered by match.
+func (s *BoltStore) LoadSenderStatuses(ctx context.Context) ([]model.SenderRule, error) {
+	var out []model.SenderRule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return s.each(tx, bucketSenders, func(k, v []byte) error {
+			out = append(out, model.SenderRule{Match: string(k), Status: model.SenderStatus(v)})
+			return nil
+		})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Match < out[j].Match })
+	return out, err
+}
+
+// SaveRule adds r when its ID is 0 and replaces the rule with its ID
+// otherwise. It returns the rule's ID.
+func (s *BoltStore) SaveRule(ctx context.Context, r model.Rule) (int64, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRules)
+		if r.ID == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			r.ID = int64(id)
+		} else if b.Get(seqKey(uint64(r.ID))) == nil {
+			return fmt.Errorf("no rule %d", r.ID)
+		}
+		return s.putJSON(tx, bucketRules, seqKey(uint64(r.ID)), r)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return r.ID, nil
+}
+
+// DeleteRule removes the rule with the given ID.
+func (s *BoltStore) DeleteRule(ctx context.Context, id int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.delete(tx, bucketRules, seqKey(uint64(id)))
+	})
+}
+
+// LoadRules returns every rule in the order they were added, which is the
+// order they run in.
+func (s *BoltStore) LoadRules(ctx context.Context) ([]model.Rule, error) {
+	var out []model.Rule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketRules, func(_ []byte, r model.Rule) error {
+			out = append(out, r)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// RecordUnsubscribe stores u as the latest unsubscribe from its sender.
+func (s *BoltStore) RecordUnsubscribe(ctx context.Context, u model.Unsubscription) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.putJSON(tx, bucketUnsubs, []byte(u.Sender), u)
+	})
+}
+
+// LoadUnsubscribes returns the latest unsubscribe from each sender, ordered
+// by sender.
+func (s *BoltStore) LoadUnsubscribes(ctx context.Context) ([]model.Unsubscription, error) {
+	var out []model.Unsubscription
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketUnsubs, func(_ []byte, u model.Unsubscription) error {
+			out = append(out, u)
+			return nil
+		})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Sender < out[j].Sender })
+	return out, err
+}
+
+// SaveSnooze stores sn, replacing any earlier snooze of the same message.
+func (s *BoltStore) SaveSnooze(ctx context.Context, sn model.Snooze) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.putJSON(tx, bucketSnoozes, []byte(sn.ID), sn)
+	})
+}
+
+// DeleteSnooze forgets the snooze of message id.
+func (s *BoltStore) DeleteSnooze(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.delete(tx, bucketSnoozes, []byte(id))
+	})
+}
+
+// LoadSnoozes returns the pending snoozes, soonest first.
+func (s *BoltStore) LoadSnoozes(ctx context.Context) ([]model.Snooze, error) {
+	var out []model.Snooze
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketSnoozes, func(_ []byte, sn model.Snooze) error {
+			out = append(out, sn)
+			return nil
+		})
+	})
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Until.Before(out[j].Until) })
+	return out, err
+}
+
+// SaveTrashed remembers msgs as trashed, replacing earlier records of the
+// same messages.
+func (s *BoltStore) SaveTrashed(ctx context.Context, msgs []model.Trashed) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, t := range msgs {
+			if err := s.putJSON(tx, bucketTrashed, []byte(t.ID), t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteTrashed forgets the trashed messages ids.
+func (s *BoltStore) DeleteTrashed(ctx context.Context, ids []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			if err := s.delete(tx, bucketTrashed, []byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadTrashed returns the trashed messages, most recently trashed first.
+func (s *BoltStore) LoadTrashed(ctx context.Context) ([]model.Trashed, error) {
+	var out []model.Trashed
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketTrashed, func(_ []byte, t model.Trashed) error {
+			out = append(out, t)
+			return nil
+		})
+	})
+	sortTrashed(out)
+	return out, err
+}
+
+// LogRuleRun appends run to the log of rule runs.
+func (s *BoltStore) LogRuleRun(ctx context.Context, run model.RuleRun) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		seq, err := tx.Bucket(bucketRuleRuns).NextSequence()
+		if err != nil {
+			return err
+		}
+		return s.putJSON(tx, bucketRuleRuns, seqKey(seq), run)
+	})
+}
+
+// LoadRuleRuns returns up to limit logged rule runs, newest first.
+func (s *BoltStore) LoadRuleRuns(ctx context.Context, limit int) ([]model.RuleRun, error) {
+	var out []model.RuleRun
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketRuleRuns, func(_ []byte, r model.RuleRun) error {
+			out = append(out, r)
+			return nil
+		})
+	})
+	slices.Reverse(out)
+	slices.SortStableFunc(out, func(a, b model.RuleRun) int { return b.Time.Compare(a.Time) })
+	return out[:min(limit, len(out))], err
+}
+
+// LastRuleRuns returns the latest logged run of each rule.
+func (s *BoltStore) LastRuleRuns(ctx context.Context) (map[int64]model.RuleRun, error) {
+	out := make(map[int64]model.RuleRun)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketRuleRuns, func(_ []byte, r model.RuleRun) error {
+			out[r.RuleID] = r
+			return nil
+		})
+	})
+	return out, err
+}
+
+// RecordAction appends a to the action history.
+func (s *BoltStore) RecordAction(ctx context.Context, a model.Action) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		seq, err := tx.Bucket(bucketActions).NextSequence()
+		if err != nil {
+			return err
+		}
+		return s.putJSON(tx, bucketActions, seqKey(seq), a)
+	})
+}
+
+// LoadActions returns the actions recorded at or after since, oldest first.
+func (s *BoltStore) LoadActions(ctx context.Context, since time.Time) ([]model.Action, error) {
+	var out []model.Action
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return eachJSON(s, tx, bucketActions, func(_ []byte, a model.Action) error {
+			if !a.Time.Before(since) {
+				out = append(out, a)
+			}
+			return nil
+		})
+	})
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, err
+}
+
+// A cached body is stored as its size and last read time, 8 bytes each,
+// then its JSON, so eviction can walk the bucket without decoding bodies.
+// Only the JSON is sealed.
+const bodyHeaderLen = 16
+
+func bodyValue(size int64, accessed time.Time, data []byte) []byte {
+	v := binary.BigEndian.AppendUint64(nil, uint64(size))
+	v = binary.BigEndian.AppendUint64(v, uint64(accessed.UnixNano()))
+	return append(v, data...)
+}
+
+// GetBody returns the cached body of a message and whether it was cached,
+// marking it as recently read.
+func (s *BoltStore) GetBody(ctx context.Context, id string) (model.MessageBody, bool, error) {
+	var body model.MessageBody
+	found := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBodies)
+		v := b.Get([]byte(id))
+		if len(v) < bodyHeaderLen {
+			return nil
+		}
+		sealed := bytes.Clone(v[bodyHeaderLen:])
+		_, data, err := s.sealer.open(bucketBodies, []byte(id), sealed)
+		if err != nil {
+			return fmt.Errorf("read cached body of %s: %w", id, err)
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return fmt.Errorf("decode cached body of %s: %w", id, err)
+		}
+		found = true
+		size := int64(binary.BigEndian.Uint64(v))
+		return b.Put([]byte(id), bodyValue(size, time.Now(), sealed))
+	})
+	return body, found, err
+}
+
+// PutBody caches a message body, then evicts the least recently read bodies
+// until the cache holds at most maxBytes. A body larger than maxBytes is not
+// cached.
+func (s *BoltStore) PutBody(ctx context.Context, id string, body model.MessageBody, maxBytes int64) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	size := int64(len(data))
+	if size > maxBytes {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBodies)
+		if err := b.Put([]byte(id), bodyValue(size, time.Now(), s.sealer.seal(bucketBodies, []byte(id), data))); err != nil {
+			return err
+		}
+		type entry struct {
+			id       string
+			size     int64
+			accessed int64
+		}
+		var entries []entry
+		var total int64
+		b.ForEach(func(k, v []byte) error {
+			if len(v) >= bodyHeaderLen {
+				e := entry{string(k), int64(binary.BigEndian.Uint64(v)), int64(binary.BigEndian.Uint64(v[8:]))}
+				entries = append(entries, e)
+				total += e.size
+			}
+			return nil
+		})
+		if total <= maxBytes {
+			return nil
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].accessed < entries[j].accessed })
+		for _, e := range entries {
+			if total <= maxBytes {
+				break
+			}
+			if err := b.Delete([]byte(e.id)); err != nil {
+				return err
+			}
+			total -= e.size
+		}
+		return nil
+	})
+}
+
+// UsedBytes is the size of the file less the pages bbolt has freed for
+// reuse.
+func (s *BoltStore) UsedBytes(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		st := s.db.Stats()
+		n = tx.Size() - int64(st.FreePageN+st.PendingPageN)*int64(s.db.Info().PageSize)
+		return nil
+	})
+	return n, err
+}
+
+// Maintain checks the file's consistency, then rewrites it without free
+// pages. The store is closed and reopened around the rewrite, so nothing
+// else may use it meanwhile.
+func (s *BoltStore) Maintain(ctx context.Context) (Maintenance, error) {
+	path := s.db.Path()
+	m := Maintenance{SizeBefore: fileSizes(path)}
+	s.db.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			m.Problems = append(m.Problems, err.Error())
+		}
+		return nil
+	})
+	if len(m.Problems) > 0 {
+		return m, fmt.Errorf("integrity check found %d problems", len(m.Problems))
+	}
+	tmp := path + ".compact"
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0o600, nil)
+	if err != nil {
+		return m, fmt.Errorf("compact: %w", err)
+	}
+	err = bolt.Compact(dst, s.db, 4<<20)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return m, fmt.Errorf("compact: %w", err)
+	}
+	s.db.Close()
+	renameErr := os.Rename(tmp, path)
+	if renameErr != nil {
+		os.Remove(tmp)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltLockTimeout})
+	if err != nil {
+		return m, fmt.Errorf("reopen %s: %w", path, err)
+	}
+	s.db = db
+	if renameErr != nil {
+		return m, fmt.Errorf("compact: %w", renameErr)
+	}
+	m.SizeAfter = fileSizes(path)
+	return m, nil
+}
+
+// BackupTo writes a consistent copy of the file to path from a read
+// transaction, which is safe while the store is in use.
+func (s *BoltStore) BackupTo(ctx context.Context, path string) error {
+	os.Remove(path)
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(path, 0o600)
+	})
+}
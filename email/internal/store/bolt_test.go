@@ -0,0 +1,303 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.bolt")
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	ctx := context.Background()
+
+	msgs := []model.MessageRef{
+		{ID: "1", From: "a@b.com", Subject: "hello", LabelIDs: []string{"INBOX", "UNREAD"}, SizeBytes: 120},
+		{ID: "2", From: "c@d.com", Subject: "world", LabelIDs: []string{"INBOX"}},
+		{ID: "3", From: "e@f.com", Subject: "gone"},
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatalf("UpsertMessages: %v", err)
+	}
+	if err := s.UpdateLabels(ctx, map[string][]string{"2": {"INBOX", "STARRED"}, "9": {"INBOX"}}); err != nil {
+		t.Fatalf("UpdateLabels: %v", err)
+	}
+	if err := s.DeleteMessages(ctx, []string{"3"}); err != nil {
+		t.Fatalf("DeleteMessages: %v", err)
+	}
+	s.SetLastHistoryID(ctx, "42")
+	s.SetGroupPinned(ctx, model.GroupKey{Email: "c@d.com", Subject: "world"}, true)
+	s.SetGroupPinned(ctx, model.GroupKey{Email: "a@b.com", Subject: "hello"}, true)
+	s.SetGroupPinned(ctx, model.GroupKey{Email: "a@b.com", Subject: "hello"}, false)
+	s.SetSenderStatus(ctx, "@shop.example", model.SenderBlocked)
+	id, err := s.SaveRule(ctx, model.Rule{Sender: "@shop.example", Action: model.RuleArchive})
+	if err != nil {
+		t.Fatalf("SaveRule: %v", err)
+	}
+	if _, err := s.SaveRule(ctx, model.Rule{ID: id + 1, Action: model.RuleTrash}); err == nil {
+		t.Error("SaveRule of a missing ID succeeded")
+	}
+	now := time.Now().Truncate(time.Second)
+	s.LogRuleRun(ctx, model.RuleRun{RuleID: id, Time: now.Add(-time.Hour), Messages: 1})
+	s.LogRuleRun(ctx, model.RuleRun{RuleID: id, Time: now, Messages: 2})
+	s.RecordAction(ctx, model.Action{Time: now, Kind: "archive", Sender: "a@b.com", Messages: 3})
+	s.SaveSnooze(ctx, model.Snooze{ID: "1", Until: now.Add(2 * time.Hour)})
+	s.SaveSnooze(ctx, model.Snooze{ID: "2", Until: now.Add(time.Hour)})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Everything survives reopening.
+	s, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if n, _ := s.CountMessages(ctx); n != 2 {
+		t.Errorf("CountMessages = %d; want 2", n)
+	}
+	got, err := s.GetMessagesByIDs(ctx, []string{"1", "2", "3"})
+	if err != nil || len(got) != 2 || !got[0].Unread() || got[0].SizeBytes != 120 || !got[1].HasLabel("STARRED") {
+		t.Errorf("GetMessagesByIDs = %+v, %v", got, err)
+	}
+	if hid, _ := s.GetLastHistoryID(ctx); hid != "42" {
+		t.Errorf("GetLastHistoryID = %q", hid)
+	}
+	if pinned, _ := s.LoadPinnedGroups(ctx); len(pinned) != 1 || pinned[0].Subject != "world" {
+		t.Errorf("LoadPinnedGroups = %v", pinned)
+	}
+	if rules, _ := s.LoadSenderStatuses(ctx); len(rules) != 1 || rules[0].Status != model.SenderBlocked {
+		t.Errorf("LoadSenderStatuses = %v", rules)
+	}
+	if rules, _ := s.LoadRules(ctx); len(rules) != 1 || rules[0].ID != id || rules[0].Sender != "@shop.example" {
+		t.Errorf("LoadRules = %+v", rules)
+	}
+	if runs, _ := s.LoadRuleRuns(ctx, 1); len(runs) != 1 || runs[0].Messages != 2 {
+		t.Errorf("LoadRuleRuns = %+v", runs)
+	}
+	if last, _ := s.LastRuleRuns(ctx); last[id].Messages != 2 {
+		t.Errorf("LastRuleRuns = %+v", last)
+	}
+	if acts, _ := s.LoadActions(ctx, now); len(acts) != 1 || acts[0].Messages != 3 {
+		t.Errorf("LoadActions = %+v", acts)
+	}
+	if snoozes, _ := s.LoadSnoozes(ctx); len(snoozes) != 2 || snoozes[0].ID != "2" {
+		t.Errorf("LoadSnoozes = %+v", snoozes)
+	}
+	if contacts, _ := s.LoadContacts(ctx); len(contacts) != 3 || contacts[2].Email != "e@f.com" {
+		t.Errorf("LoadContacts = %+v; want the deleted message's sender kept", contacts)
+	}
+
+	if err := s.ClearMessages(ctx); err != nil {
+		t.Fatalf("ClearMessages: %v", err)
+	}
+	if n, _ := s.CountMessages(ctx); n != 0 {
+		t.Errorf("CountMessages after clear = %d", n)
+	}
+}
+
+func TestBoltBodyCache(t *testing.T) {
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "cache.bolt"))
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	body := func(text string) model.MessageBody { return model.MessageBody{Text: text} }
+	// The budget holds two of these bodies.
+	one, _ := json.Marshal(body("first body...."))
+	budget := int64(2*len(one) + 5)
+	s.PutBody(ctx, "a", body("first body...."), budget)
+	s.PutBody(ctx, "b", body("second body..."), budget)
+	time.Sleep(time.Millisecond)
+	if _, ok, _ := s.GetBody(ctx, "a"); !ok {
+		t.Fatal("GetBody(a) missed")
+	}
+	s.PutBody(ctx, "c", body("third body...."), budget)
+	if _, ok, _ := s.GetBody(ctx, "b"); ok {
+		t.Error("least recently read body b was not evicted")
+	}
+	if b, ok, _ := s.GetBody(ctx, "a"); !ok || b.Text != "first body...." {
+		t.Errorf("GetBody(a) = %q, %v", b.Text, ok)
+	}
+	s.PutBody(ctx, "huge", body(string(make([]byte, budget))), budget)
+	if _, ok, _ := s.GetBody(ctx, "huge"); ok {
+		t.Error("body over the budget was cached")
+	}
+
+	backup := filepath.Join(t.TempDir(), "copy.bolt")
+	if err := s.BackupTo(ctx, backup); err != nil {
+		t.Fatalf("BackupTo: %v", err)
+	}
+	c, err := NewBoltStore(backup)
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer c.Close()
+	if _, ok, _ := c.GetBody(ctx, "c"); !ok {
+		t.Error("backup is missing body c")
+	}
+}
+
+func TestBoltMaintain(t *testing.T) {
+	s, err := NewEncryptedBoltStore(filepath.Join(t.TempDir(), "cache.bolt"), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	var msgs []model.MessageRef
+	var stale []string
+	for i := range 2000 {
+		msgs = append(msgs, model.MessageRef{ID: fmt.Sprint(i), Subject: strings.Repeat("x", 100)})
+		if i > 0 {
+			stale = append(stale, fmt.Sprint(i))
+		}
+	}
+	s.UpsertMessages(ctx, msgs)
+	s.DeleteMessages(ctx, stale)
+	id, _ := s.SaveRule(ctx, model.Rule{Sender: "@shop.example", Action: model.RuleArchive})
+
+	m, err := s.Maintain(ctx)
+	if err != nil {
+		t.Fatalf("Maintain: %v (problems %v)", err, m.Problems)
+	}
+	if m.SizeAfter >= m.SizeBefore {
+		t.Errorf("size %d -> %d; want it to shrink", m.SizeBefore, m.SizeAfter)
+	}
+	if got, err := s.GetMessagesByIDs(ctx, []string{"0"}); err != nil || len(got) != 1 {
+		t.Errorf("after Maintain: GetMessagesByIDs = %v, %v", got, err)
+	}
+	if next, _ := s.SaveRule(ctx, model.Rule{Sender: "@other.example", Action: model.RuleTrash}); next != id+1 {
+		t.Errorf("rule ID after Maintain = %d; want %d", next, id+1)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, backend := range []string{"", BackendSQLite, BackendBolt, BackendMemory} {
+		s, err := Open(backend, filepath.Join(dir, "cache-"+backend), "")
+		if err != nil {
+			t.Errorf("Open(%q): %v", backend, err)
+			continue
+		}
+		s.Close()
+	}
+	if _, err := Open("mongo", filepath.Join(dir, "x"), ""); err == nil {
+		t.Error("Open of an unknown backend succeeded")
+	}
+	if _, err := Open(BackendSQLite, filepath.Join(dir, "y"), "secret"); err == nil {
+		t.Error("Open of an encrypted SQLite store succeeded")
+	}
+}
+
+func TestBoltFlagsMetadataRefresh(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "old.bolt")
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertMessages(ctx, []model.MessageRef{{ID: "1", From: "ada@x.example"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Files written before boltFields existed lack the key.
+	if err := s.SetMetadata(ctx, string(metaBoltFields), ""); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	for _, want := range []string{"*", ""} {
+		s, err = NewBoltStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := s.GetMetadata(ctx, "refresh_metadata")
+		if err != nil || v != want {
+			t.Fatalf("refresh_metadata = %q, %v; want %q", v, err, want)
+		}
+		// The refresh is done; opening again must not ask for another.
+		if err := s.SetMetadata(ctx, "refresh_metadata", ""); err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+	}
+}
+
+func TestEncryptedBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.bolt")
+	s, err := NewEncryptedBoltStore(path, "secret")
+	if err != nil {
+		t.Fatalf("NewEncryptedBoltStore: %v", err)
+	}
+	ctx := context.Background()
+	s.UpsertMessages(ctx, []model.MessageRef{{ID: "1", From: "doctor@clinic.example", Subject: "Your test results"}})
+	s.SetGroupPinned(ctx, model.GroupKey{Email: "doctor@clinic.example", Subject: "Your test results"}, true)
+	s.SetSenderStatus(ctx, "@clinic.example", model.SenderProtected)
+	s.SetSenderStatus(ctx, "@bank.example", model.SenderProtected)
+	s.RecordUnsubscribe(ctx, model.Unsubscription{Sender: "news@clinic.example"})
+	s.PutBody(ctx, "1", model.MessageBody{Text: "cholesterol is fine"}, 1<<20)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"doctor@clinic", "test results", "clinic.example", "cholesterol"} {
+		if bytes.Contains(raw, []byte(secret)) {
+			t.Errorf("file contains %q in the clear", secret)
+		}
+	}
+
+	if _, err := NewBoltStore(path); err == nil {
+		t.Error("opening without a passphrase succeeded")
+	}
+	if _, err := NewEncryptedBoltStore(path, "wrong"); err == nil {
+		t.Error("opening with the wrong passphrase succeeded")
+	}
+	s, err = NewEncryptedBoltStore(path, "secret")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if got, _ := s.GetMessagesByIDs(ctx, []string{"1"}); len(got) != 1 || got[0].Subject != "Your test results" {
+		t.Errorf("GetMessagesByIDs = %+v", got)
+	}
+	if pinned, _ := s.LoadPinnedGroups(ctx); len(pinned) != 1 || pinned[0].Email != "doctor@clinic.example" {
+		t.Errorf("LoadPinnedGroups = %+v", pinned)
+	}
+	s.SetSenderStatus(ctx, "@bank.example", "")
+	if senders, _ := s.LoadSenderStatuses(ctx); len(senders) != 1 || senders[0].Match != "@clinic.example" {
+		t.Errorf("LoadSenderStatuses = %+v", senders)
+	}
+	if unsubs, _ := s.LoadUnsubscribes(ctx); len(unsubs) != 1 || unsubs[0].Sender != "news@clinic.example" {
+		t.Errorf("LoadUnsubscribes = %+v", unsubs)
+	}
+	if b, ok, err := s.GetBody(ctx, "1"); !ok || b.Text != "cholesterol is fine" {
+		t.Errorf("GetBody = %q, %v, %v", b.Text, ok, err)
+	}
+
+	plain := filepath.Join(t.TempDir(), "plain.bolt")
+	p, err := NewBoltStore(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if _, err := NewEncryptedBoltStore(plain, "secret"); err == nil {
+		t.Error("encrypting an existing clear file succeeded")
+	}
+}
@@ -0,0 +1,402 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+// MemoryStore keeps the cache in process memory. Nothing survives a restart,
+// which suits demo mode and tests. It supports labels, pins, bodies, sender
+// lists, rules, their run log, unsubscribes, snoozes, the trash log, contacts and the action history but not the low-memory aggregation of SQLiteStore.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	messages map[string]model.MessageRef
+	metadata map[string]string
+	pinned   map[model.GroupKey]bool
+	bodies   map[string]model.MessageBody
+	actions  []model.Action
+	senders  map[string]model.SenderStatus
+	rules    []model.Rule
+	ruleID   int64
+	ruleRuns []model.RuleRun
+	unsubs   map[string]model.Unsubscription
+	snoozes  map[string]model.Snooze
+	trashed  map[string]model.Trashed
+	contacts map[string]model.Contact
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		messages: map[string]model.MessageRef{},
+		metadata: map[string]string{},
+		pinned:   map[model.GroupKey]bool{},
+		bodies:   map[string]model.MessageBody{},
+		senders:  map[string]model.SenderStatus{},
+		contacts: map[string]model.Contact{},
+	}
+}
+
+func (s *MemoryStore) Close() error { return nil }
+
+func (s *MemoryStore) UpsertMessages(ctx context.Context, msgs []model.MessageRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range msgs {
+		m.LabelIDs = append([]string(nil), m.LabelIDs...)
+		s.messages[m.ID] = m
+		if m.From != "" {
+			s.contacts[m.From] = seenContact(s.contacts[m.From], m)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteMessages(ctx context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.messages, id)
+	}
+	return nil
+}
+
+func (s *MemoryStore) ClearMessages(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.messages)
+	return nil
+}
+
+func (s *MemoryStore) LoadAllMessages(ctx context.Context) ([]model.MessageRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.MessageRef, 0, len(s.messages))
+	for _, m := range s.messages {
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) GetMessagesByIDs(ctx context.Context, ids []string) ([]model.MessageRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []model.MessageRef
+	for _, id := range ids {
+		if m, ok := s.messages[id]; ok {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// UpdateLabels replaces the label IDs of cached messages, keyed by message
+// ID. IDs that are not cached are ignored.
+func (s *MemoryStore) UpdateLabels(ctx context.Context, labels map[string][]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, ids := range labels {
+		if m, ok := s.messages[id]; ok {
+			m.LabelIDs = append([]string(nil), ids...)
+			s.messages[id] = m
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CountMessages(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.messages), nil
+}
+
+func (s *MemoryStore) GetLastHistoryID(ctx context.Context) (string, error) {
+	return s.GetMetadata(ctx, "last_history_id")
+}
+
+func (s *MemoryStore) SetLastHistoryID(ctx context.Context, historyID string) error {
+	return s.SetMetadata(ctx, "last_history_id", historyID)
+}
+
+// GetMetadata returns the value stored under key, or "" if it is unset.
+func (s *MemoryStore) GetMetadata(ctx context.Context, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.metadata[key], nil
+}
+
+// SetMetadata stores value under key, replacing any previous value.
+func (s *MemoryStore) SetMetadata(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata[key] = value
+	return nil
+}
+
+// SetGroupPinned pins or unpins the sender+subject group.
+func (s *MemoryStore) SetGroupPinned(ctx context.Context, key model.GroupKey, pinned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pinned {
+		s.pinned[key] = true
+	} else {
+		delete(s.pinned, key)
+	}
+	return nil
+}
+
+// LoadPinnedGroups returns the keys of all pinned groups.
+func (s *MemoryStore) LoadPinnedGroups(ctx context.Context) ([]model.GroupKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.GroupKey, 0, len(s.pinned))
+	for k := range s.pinned {
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+// SetSenderStatus protects or blocks the sender address or @domain match; an
+// empty status removes it from the lists.
+func (s *MemoryStore) SetSenderStatus(ctx context.Context, match string, status model.SenderStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == "" {
+		delete(s.senders, match)
+	} else {
+		s.senders[match] = status
+	}
+	return nil
+}
+
+// LoadSenderStatuses returns every protected and blocked sender, ordered by match.
+func (s *MemoryStore) LoadSenderStatuses(ctx context.Context) ([]model.SenderRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.SenderRule, 0, len(s.senders))
+	for m, st := range s.senders {
+		out = append(out, model.SenderRule{Match: m, Status: st})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Match < out[j].Match })
+	return out, nil
+}
+
+// SaveRule adds r when its ID is 0 and replaces the rule with its ID
+// otherwise. It returns the rule's ID.
+func (s *MemoryStore) SaveRule(ctx context.Context, r model.Rule) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.ID == 0 {
+		s.ruleID++
+		r.ID = s.ruleID
+		s.rules = append(s.rules, r)
+		return r.ID, nil
+	}
+	for i := range s.rules {
+		if s.rules[i].ID == r.ID {
+			s.rules[i] = r
+			return r.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no rule %d", r.ID)
+}
+
+// DeleteRule removes the rule with the given ID.
+func (s *MemoryStore) DeleteRule(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = slices.DeleteFunc(s.rules, func(r model.Rule) bool { return r.ID == id })
+	return nil
+}
+
+// LoadRules returns every rule in the order they were added.
+func (s *MemoryStore) LoadRules(ctx context.Context) ([]model.Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.rules), nil
+}
+
+// RecordUnsubscribe stores u as the latest unsubscribe from its sender.
+func (s *MemoryStore) RecordUnsubscribe(ctx context.Context, u model.Unsubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unsubs == nil {
+		s.unsubs = make(map[string]model.Unsubscription)
+	}
+	s.unsubs[u.Sender] = u
+	return nil
+}
+
+// LoadUnsubscribes returns the latest unsubscribe from each sender, ordered
+// by sender.
+func (s *MemoryStore) LoadUnsubscribes(ctx context.Context) ([]model.Unsubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.Unsubscription, 0, len(s.unsubs))
+	for _, u := range s.unsubs {
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Sender < out[j].Sender })
+	return out, nil
+}
+
+// SaveSnooze stores sn, replacing any earlier snooze of the same message.
+func (s *MemoryStore) SaveSnooze(ctx context.Context, sn model.Snooze) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snoozes == nil {
+		s.snoozes = make(map[string]model.Snooze)
+	}
+	s.snoozes[sn.ID] = sn
+	return nil
+}
+
+// DeleteSnooze forgets the snooze of message id.
+func (s *MemoryStore) DeleteSnooze(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.snoozes, id)
+	return nil
+}
+
+// LoadSnoozes returns the pending snoozes, soonest first.
+// LoadContacts returns the senders of every message upserted, most
+// recently seen first.
+func (s *MemoryStore) LoadContacts(ctx context.Context) ([]model.Contact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.Contact, 0, len(s.contacts))
+	for _, c := range s.contacts {
+		out = append(out, c)
+	}
+	sortContacts(out)
+	return out, nil
+}
+
+func (s *MemoryStore) LoadSnoozes(ctx context.Context) ([]model.Snooze, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.Snooze, 0, len(s.snoozes))
+	for _, sn := range s.snoozes {
+		out = append(out, sn)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Until.Equal(out[j].Until) {
+			return out[i].Until.Before(out[j].Until)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// SaveTrashed remembers msgs as trashed, replacing earlier records of the
+// same messages.
+func (s *MemoryStore) SaveTrashed(ctx context.Context, msgs []model.Trashed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trashed == nil {
+		s.trashed = make(map[string]model.Trashed)
+	}
+	for _, t := range msgs {
+		s.trashed[t.ID] = t
+	}
+	return nil
+}
+
+// DeleteTrashed forgets the trashed messages ids.
+func (s *MemoryStore) DeleteTrashed(ctx context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.trashed, id)
+	}
+	return nil
+}
+
+// LoadTrashed returns the trashed messages, most recently trashed first.
+func (s *MemoryStore) LoadTrashed(ctx context.Context) ([]model.Trashed, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]model.Trashed, 0, len(s.trashed))
+	for _, t := range s.trashed {
+		out = append(out, t)
+	}
+	sortTrashed(out)
+	return out, nil
+}
+
+// LogRuleRun appends run to the log of rule runs.
+func (s *MemoryStore) LogRuleRun(ctx context.Context, run model.RuleRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ruleRuns = append(s.ruleRuns, run)
+	return nil
+}
+
+// LoadRuleRuns returns up to limit logged rule runs, newest first.
+func (s *MemoryStore) LoadRuleRuns(ctx context.Context, limit int) ([]model.RuleRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := slices.Clone(s.ruleRuns)
+	slices.Reverse(out)
+	slices.SortStableFunc(out, func(a, b model.RuleRun) int { return b.Time.Compare(a.Time) })
+	return out[:min(limit, len(out))], nil
+}
+
+// LastRuleRuns returns the latest logged run of each rule.
+func (s *MemoryStore) LastRuleRuns(ctx context.Context) (map[int64]model.RuleRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[int64]model.RuleRun)
+	for _, r := range s.ruleRuns {
+		out[r.RuleID] = r
+	}
+	return out, nil
+}
+
+// RecordAction appends a to the action history.
+func (s *MemoryStore) RecordAction(ctx context.Context, a model.Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, a)
+	return nil
+}
+
+// LoadActions returns the actions recorded at or after since, oldest first.
+func (s *MemoryStore) LoadActions(ctx context.Context, since time.Time) ([]model.Action, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []model.Action
+	for _, a := range s.actions {
+		if !a.Time.Before(since) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// GetBody returns the cached body of message id, if any.
+func (s *MemoryStore) GetBody(ctx context.Context, id string) (model.MessageBody, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.bodies[id]
+	return b, ok, nil
+}
+
+// PutBody caches a body. Memory is not budgeted beyond skipping bodies
+// larger than maxBytes on their own.
+func (s *MemoryStore) PutBody(ctx context.Context, id string, body model.MessageBody, maxBytes int64) error {
+	if int64(len(body.Text)) > maxBytes {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bodies[id] = body
+	return nil
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"chuckterm/internal/model"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	msgs := []model.MessageRef{
+		{ID: "1", From: "a@b.com", Subject: "hello", LabelIDs: []string{"INBOX", "UNREAD"}},
+		{ID: "2", From: "c@d.com", Subject: "world", LabelIDs: []string{"INBOX"}},
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatalf("UpsertMessages: %v", err)
+	}
+	// The store keeps its own copy of the label slice.
+	msgs[0].LabelIDs[1] = "STARRED"
+	if err := s.UpdateLabels(ctx, map[string][]string{"2": {"INBOX", "STARRED"}, "9": {"INBOX"}}); err != nil {
+		t.Fatalf("UpdateLabels: %v", err)
+	}
+	got, err := s.GetMessagesByIDs(ctx, []string{"1", "2", "9"})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("GetMessagesByIDs = %v, %v", got, err)
+	}
+	if !got[0].Unread() || !got[1].HasLabel("STARRED") {
+		t.Fatalf("labels = %v, %v", got[0].LabelIDs, got[1].LabelIDs)
+	}
+
+	if err := s.DeleteMessages(ctx, []string{"1"}); err != nil {
+		t.Fatalf("DeleteMessages: %v", err)
+	}
+	if n, _ := s.CountMessages(ctx); n != 1 {
+		t.Fatalf("CountMessages = %d, want 1", n)
+	}
+	if contacts, _ := s.LoadContacts(ctx); len(contacts) != 2 || contacts[0].Email != "a@b.com" {
+		t.Fatalf("LoadContacts = %+v", contacts)
+	}
+
+	if err := s.SetLastHistoryID(ctx, "42"); err != nil {
+		t.Fatalf("SetLastHistoryID: %v", err)
+	}
+	if hid, _ := s.GetLastHistoryID(ctx); hid != "42" {
+		t.Fatalf("GetLastHistoryID = %q", hid)
+	}
+
+	key := model.GroupKey{Email: "c@d.com", Subject: "world"}
+	s.SetGroupPinned(ctx, key, true)
+	if pinned, _ := s.LoadPinnedGroups(ctx); len(pinned) != 1 || pinned[0] != key {
+		t.Fatalf("LoadPinnedGroups = %v", pinned)
+	}
+
+	s.PutBody(ctx, "2", model.MessageBody{Text: "body"}, 10)
+	s.PutBody(ctx, "big", model.MessageBody{Text: "0123456789abc"}, 10)
+	if b, ok, _ := s.GetBody(ctx, "2"); !ok || b.Text != "body" {
+		t.Fatalf("GetBody(2) = %q, %v", b.Text, ok)
+	}
+	if _, ok, _ := s.GetBody(ctx, "big"); ok {
+		t.Fatal("body over the cap must not be cached")
+	}
+}
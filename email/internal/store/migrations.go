@@ -0,0 +1,208 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations upgrade the schema one step at a time. migrations[i] moves a
+// database from user_version i to i+1; append new steps, never edit old ones.
+// Databases created before versioning report user_version 0 and may already
+// contain some of these objects, so early steps are written to be idempotent.
+var migrations = []func(tx *sql.Tx) error{
+	// 1: messages and metadata.
+	execMigration(`
+CREATE TABLE IF NOT EXISTS messages (
+	id                    TEXT PRIMARY KEY,
+	from_email            TEXT NOT NULL,
+	subject               TEXT NOT NULL DEFAULT '',
+	date_rfc3339          TEXT NOT NULL DEFAULT '',
+	list_unsubscribe      TEXT NOT NULL DEFAULT '',
+	list_unsubscribe_post TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS metadata (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL DEFAULT ''
+);`),
+	// 2: pinned groups.
+	execMigration(`
+CREATE TABLE IF NOT EXISTS pinned_groups (
+	from_email TEXT NOT NULL,
+	subject    TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (from_email, subject)
+);`),
+	// 3: label IDs per message.
+	addLabelIDsColumn,
+	// 4: cached message bodies, evicted least recently read first.
+	execMigration(`
+CREATE TABLE bodies (
+	id          TEXT PRIMARY KEY,
+	body        TEXT NOT NULL,
+	size        INTEGER NOT NULL,
+	accessed_at INTEGER NOT NULL
+);
+CREATE INDEX bodies_accessed_at ON bodies (accessed_at);`),
+	// 5: attachment listings alongside cached bodies.
+	execMigration(`ALTER TABLE bodies ADD COLUMN attachments TEXT NOT NULL DEFAULT '';`),
+	// 6: calendar invitations alongside cached bodies. Bodies cached before
+	// this were never checked for invitations, so they are dropped and
+	// refetched on next open.
+	execMigration(`
+ALTER TABLE bodies ADD COLUMN invites TEXT NOT NULL DEFAULT '';
+DELETE FROM bodies;`),
+	// 7: sender display names. Existing rows keep an empty name until step
+	// 20's refresh.
+	execMigration(`ALTER TABLE messages ADD COLUMN from_name TEXT NOT NULL DEFAULT '';`),
+	// 8: Gmail's per-message size estimate, for sorting groups by size.
+	// Existing rows count as 0 bytes until step 20's refresh.
+	execMigration(`ALTER TABLE messages ADD COLUMN size_estimate INTEGER NOT NULL DEFAULT 0;`),
+	// 9: Gmail's body snippets, searched in the messages view.
+	execMigration(`ALTER TABLE messages ADD COLUMN snippet TEXT NOT NULL DEFAULT '';`),
+	// 10: archive, trash and unsubscribe history for the stats view.
+	execMigration(`
+CREATE TABLE actions (
+	at       INTEGER NOT NULL,
+	kind     TEXT NOT NULL,
+	sender   TEXT NOT NULL DEFAULT '',
+	subject  TEXT NOT NULL DEFAULT '',
+	messages INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX actions_at ON actions (at);`),
+	// 11: protected and blocked senders.
+	execMigration(`
+CREATE TABLE sender_lists (
+	match  TEXT PRIMARY KEY,
+	status TEXT NOT NULL
+);`),
+	// 12: rules run after each sync.
+	execMigration(`
+CREATE TABLE rules (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	sender     TEXT NOT NULL DEFAULT '',
+	subject    TEXT NOT NULL DEFAULT '',
+	older_than TEXT NOT NULL DEFAULT '',
+	label      TEXT NOT NULL DEFAULT '',
+	action     TEXT NOT NULL,
+	add_label  TEXT NOT NULL DEFAULT '',
+	disabled   INTEGER NOT NULL DEFAULT 0
+);`),
+	// 13: scheduled rules (cleanup jobs) and the log of rule runs.
+	execMigration(`
+ALTER TABLE rules ADD COLUMN has_unsubscribe INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rules ADD COLUMN every TEXT NOT NULL DEFAULT '';
+CREATE TABLE rule_runs (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	rule_id  INTEGER NOT NULL,
+	at       INTEGER NOT NULL,
+	rule     TEXT NOT NULL,
+	messages INTEGER NOT NULL,
+	error    TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX rule_runs_rule ON rule_runs (rule_id, at);`),
+	// 14: senders unsubscribed from.
+	execMigration(`
+CREATE TABLE unsubscribes (
+	sender TEXT PRIMARY KEY,
+	at     INTEGER NOT NULL,
+	method TEXT NOT NULL,
+	url    TEXT NOT NULL DEFAULT ''
+);`),
+	// 15: snoozed messages waiting to return to the inbox.
+	execMigration(`
+CREATE TABLE snoozes (
+	id      TEXT PRIMARY KEY,
+	until   INTEGER NOT NULL,
+	sender  TEXT NOT NULL DEFAULT '',
+	subject TEXT NOT NULL DEFAULT ''
+);`),
+	// 16: retention drops the oldest messages first.
+	execMigration(`CREATE INDEX messages_date ON messages (date_rfc3339);`),
+	// 17: contacts, the senders seen, to complete addresses when writing
+	// mail; filled from the cached messages.
+	execMigration(`
+CREATE TABLE contacts (
+	email     TEXT PRIMARY KEY,
+	name      TEXT NOT NULL DEFAULT '',
+	last_seen TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO contacts (email, last_seen)
+	SELECT from_email, MAX(date_rfc3339) FROM messages WHERE from_email != '' GROUP BY from_email;
+UPDATE contacts SET name = COALESCE((
+	SELECT from_name FROM messages
+	WHERE from_email = contacts.email AND from_name != ''
+	ORDER BY date_rfc3339 DESC LIMIT 1), '');`),
+	// 18: messages moved to the trash, to restore them from the trash view.
+	execMigration(`
+CREATE TABLE trashed (
+	id      TEXT PRIMARY KEY,
+	at      INTEGER NOT NULL,
+	sender  TEXT NOT NULL DEFAULT '',
+	subject TEXT NOT NULL DEFAULT '',
+	date    TEXT NOT NULL DEFAULT ''
+);`),
+	// 19: the headers that tell bulk mail from correspondence. Existing rows
+	// leave them empty until step 20's refresh.
+	execMigration(`
+ALTER TABLE messages ADD COLUMN precedence TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN x_mailer TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN list_id TEXT NOT NULL DEFAULT '';`),
+	// 20: a cache holding rows from before steps 7, 8 and 19 has the next
+	// sync fetch their metadata again (see gmail.RefreshMetadata).
+	execMigration(`
+INSERT OR REPLACE INTO metadata (key, value)
+	SELECT 'refresh_metadata', '*' WHERE EXISTS (SELECT 1 FROM messages);`),
+}
+
+func execMigration(stmt string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmt)
+		return err
+	}
+}
+
+// migrate applies every migration newer than the database's user_version,
+// each in its own transaction together with the version bump.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, len(migrations))
+	}
+	for v := version; v < len(migrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[v](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrate schema to version %d: %w", v+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migrate schema to version %d: %w", v+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migrate schema to version %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+// addLabelIDsColumn upgrades caches created before label IDs were stored.
+// Their rows keep no labels until the next sync fetches their metadata
+// again (see gmail.RefreshMetadata); the cache and sync cursors are kept.
+func addLabelIDsColumn(tx *sql.Tx) error {
+	var n int
+	err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info('messages') WHERE name = 'label_ids'").Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = tx.Exec(`
+		ALTER TABLE messages ADD COLUMN label_ids TEXT NOT NULL DEFAULT '';
+		INSERT OR REPLACE INTO metadata (key, value)
+			SELECT 'refresh_metadata', '*' WHERE EXISTS (SELECT 1 FROM messages);`)
+	return err
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+)
+
+// datedMessages returns n messages, one a day, the newest half a day old.
+func datedMessages(n int) []model.MessageRef {
+	now := time.Now().UTC().Add(-12 * time.Hour)
+	var msgs []model.MessageRef
+	for i := range n {
+		msgs = append(msgs, model.MessageRef{
+			ID:          fmt.Sprint(i),
+			From:        "news@example.com",
+			Subject:     strings.Repeat("s", 200),
+			DateRFC3339: now.AddDate(0, 0, i-n+1).Format(time.RFC3339),
+		})
+	}
+	return msgs
+}
+
+func TestPruneByAge(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range map[string]gmail.MessageStore{"sqlite": testStore(t), "memory": NewMemoryStore()} {
+		msgs := append(datedMessages(60), model.MessageRef{ID: "undated", From: "a@b.com"})
+		s.UpsertMessages(ctx, msgs)
+		n, err := gmail.Prune(ctx, s, gmail.Retention{MaxAge: "30d"})
+		if err != nil {
+			t.Fatalf("%s: Prune: %v", name, err)
+		}
+		// Days 0..29 are more than 30 days old; the undated message stays.
+		if left, _ := s.CountMessages(ctx); n != 30 || left != 31 {
+			t.Errorf("%s: dropped %d, %d left; want 30 and 31", name, n, left)
+		}
+		if got, _ := s.GetMessagesByIDs(ctx, []string{"29", "30"}); len(got) != 1 || got[0].ID != "30" {
+			t.Errorf("%s: kept %+v", name, got)
+		}
+	}
+}
+
+func TestPruneBySize(t *testing.T) {
+	ctx := context.Background()
+	s := testStore(t)
+	s.UpsertMessages(ctx, datedMessages(2000))
+	full, err := s.UsedBytes(ctx)
+	if err != nil || full == 0 {
+		t.Fatalf("UsedBytes = %d, %v", full, err)
+	}
+	r := gmail.Retention{MaxBytes: full / 2}
+	n, err := gmail.Prune(ctx, s, r)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if used, _ := s.UsedBytes(ctx); used > r.MaxBytes || n == 0 || n > 1500 {
+		t.Errorf("dropped %d messages, %d bytes used; cap %d", n, used, r.MaxBytes)
+	}
+	if got, _ := s.GetMessagesByIDs(ctx, []string{"0", "1999"}); len(got) != 1 || got[0].ID != "1999" {
+		t.Errorf("kept %+v; want only the newest", got)
+	}
+	// Mail older than what was dropped is left out of later syncs.
+	cutoff, err := gmail.RetentionCutoff(ctx, s, r, time.Now())
+	if err != nil || cutoff.IsZero() {
+		t.Errorf("RetentionCutoff = %v, %v", cutoff, err)
+	}
+	if cutoff, _ := gmail.RetentionCutoff(ctx, s, gmail.Retention{}, time.Now()); !cutoff.IsZero() {
+		t.Errorf("RetentionCutoff without retention = %v", cutoff)
+	}
+}
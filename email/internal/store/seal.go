@@ -0,0 +1,105 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// A sealer encrypts the values of an encrypted bolt file with AES-256-GCM,
+// keyed from a passphrase with scrypt. Buckets keyed by mail data (sender
+// addresses, subjects) get their keys replaced by an HMAC, and every sealed
+// value carries its real key so those buckets can still be listed. Message
+// IDs, sequence numbers and metadata names are left as they are. A nil
+// sealer stores everything in the clear.
+type sealer struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+// hiddenKeyBuckets are the buckets whose keys are mail data.
+var hiddenKeyBuckets = map[string]bool{
+	string(bucketPinned):   true,
+	string(bucketSenders):  true,
+	string(bucketContacts): true,
+	string(bucketUnsubs):   true,
+}
+
+var errSealed = errors.New("cannot decrypt: wrong passphrase or damaged file")
+
+// sealCheck is sealed into a new encrypted file so a wrong passphrase is
+// caught on open rather than on the first read.
+var sealCheck = []byte("chuckterm")
+
+func newSealer(passphrase string, salt []byte) (*sealer, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 64)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead, mac: key[32:]}, nil
+}
+
+// storedKey returns the key an entry of bucket is stored under.
+func (s *sealer) storedKey(bucket, key []byte) []byte {
+	if s == nil || !hiddenKeyBuckets[string(bucket)] {
+		return key
+	}
+	h := hmac.New(sha256.New, s.mac)
+	h.Write(bucket)
+	h.Write([]byte{0})
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// seal encrypts key and value together, bound to bucket.
+func (s *sealer) seal(bucket, key, value []byte) []byte {
+	if s == nil {
+		return value
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	rand.Read(nonce)
+	plain := binary.AppendUvarint(nil, uint64(len(key)))
+	plain = append(append(plain, key...), value...)
+	return s.aead.Seal(nonce, nonce, plain, bucket)
+}
+
+// open reverses seal, returning the real key and the value. Without a
+// sealer it returns the stored key and value unchanged.
+func (s *sealer) open(bucket, stored, sealed []byte) (key, value []byte, err error) {
+	if s == nil {
+		return stored, sealed, nil
+	}
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, nil, errSealed
+	}
+	plain, err := s.aead.Open(nil, sealed[:n], sealed[n:], bucket)
+	if err != nil {
+		return nil, nil, errSealed
+	}
+	kl, m := binary.Uvarint(plain)
+	if m <= 0 || kl > uint64(len(plain)-m) {
+		return nil, nil, errSealed
+	}
+	key, value = plain[m:m+int(kl)], plain[m+int(kl):]
+	if !hiddenKeyBuckets[string(bucket)] && !bytes.Equal(key, stored) {
+		// A value copied under another entry's key.
+		return nil, nil, errSealed
+	}
+	return key, value, nil
+}
@@ -3,10 +3,12 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"chuckterm/internal/model"
 
@@ -15,7 +17,8 @@ import (
 
 // SQLiteStore implements gmail.MessageStore backed by a local SQLite database.
 type SQLiteStore struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
 }
 
 // NewSQLiteStore opens (or creates) the database at the given path and runs migrations.
@@ -40,30 +43,28 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 		return nil, err
 	}
 
-	return &SQLiteStore{db: db}, nil
+	return &SQLiteStore{db: db, path: dbPath}, nil
 }
 
-func migrate(db *sql.DB) error {
-	const schema = `
-CREATE TABLE IF NOT EXISTS messages (
-	id                    TEXT PRIMARY KEY,
-	from_email            TEXT NOT NULL,
-	subject               TEXT NOT NULL DEFAULT '',
-	date_rfc3339          TEXT NOT NULL DEFAULT '',
-	list_unsubscribe      TEXT NOT NULL DEFAULT '',
-	list_unsubscribe_post TEXT NOT NULL DEFAULT ''
-);
+// messageColumns lists the messages columns in the order scanMessage reads them.
+const messageColumns = "id, from_email, subject, date_rfc3339, list_unsubscribe, list_unsubscribe_post, label_ids, from_name, size_estimate, snippet, precedence, x_mailer, list_id"
 
-CREATE TABLE IF NOT EXISTS metadata (
-	key   TEXT PRIMARY KEY,
-	value TEXT NOT NULL DEFAULT ''
-);
-`
-	_, err := db.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("migrate schema: %w", err)
+func scanMessage(rows *sql.Rows) (model.MessageRef, error) {
+	var m model.MessageRef
+	var labels string
+	err := rows.Scan(&m.ID, &m.From, &m.Subject, &m.DateRFC3339, &m.ListUnsubscribe, &m.ListUnsubscribePost, &labels, &m.FromName, &m.SizeBytes, &m.Snippet, &m.Precedence, &m.XMailer, &m.ListID)
+	m.LabelIDs = splitLabels(labels)
+	return m, err
+}
+
+// Label IDs are stored comma-separated; Gmail label IDs never contain commas.
+func joinLabels(ids []string) string { return strings.Join(ids, ",") }
+
+func splitLabels(s string) []string {
+	if s == "" {
+		return nil
 	}
-	return nil
+	return strings.Split(s, ",")
 }
 
 func (s *SQLiteStore) Close() error {
@@ -77,15 +78,34 @@ func (s *SQLiteStore) UpsertMessages(ctx context.Context, msgs []model.MessageRe
 	}
 	defer tx.Rollback()
 
+	contacts, err := tx.PrepareContext(ctx, `
+		INSERT INTO contacts (email, name, last_seen) VALUES (?, ?, ?)
+		ON CONFLICT(email) DO UPDATE SET
+			name      = CASE WHEN excluded.name != '' AND (contacts.name = '' OR excluded.last_seen >= contacts.last_seen)
+			            THEN excluded.name ELSE contacts.name END,
+			last_seen = MAX(contacts.last_seen, excluded.last_seen)
+	`)
+	if err != nil {
+		return err
+	}
+	defer contacts.Close()
+
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO messages (id, from_email, subject, date_rfc3339, list_unsubscribe, list_unsubscribe_post)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO messages (id, from_email, subject, date_rfc3339, list_unsubscribe, list_unsubscribe_post, label_ids, from_name, size_estimate, snippet, precedence, x_mailer, list_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			from_email            = excluded.from_email,
 			subject               = excluded.subject,
 			date_rfc3339          = excluded.date_rfc3339,
 			list_unsubscribe      = excluded.list_unsubscribe,
-			list_unsubscribe_post = excluded.list_unsubscribe_post
+			list_unsubscribe_post = excluded.list_unsubscribe_post,
+			label_ids             = excluded.label_ids,
+			from_name             = excluded.from_name,
+			size_estimate         = excluded.size_estimate,
+			snippet               = excluded.snippet,
+			precedence            = excluded.precedence,
+			x_mailer              = excluded.x_mailer,
+			list_id               = excluded.list_id
 	`)
 	if err != nil {
 		return err
@@ -93,10 +113,16 @@ func (s *SQLiteStore) UpsertMessages(ctx context.Context, msgs []model.MessageRe
 	defer stmt.Close()
 
 	for _, m := range msgs {
-		_, err := stmt.ExecContext(ctx, m.ID, m.From, m.Subject, m.DateRFC3339, m.ListUnsubscribe, m.ListUnsubscribePost)
+		_, err := stmt.ExecContext(ctx, m.ID, m.From, m.Subject, m.DateRFC3339, m.ListUnsubscribe, m.ListUnsubscribePost, joinLabels(m.LabelIDs), m.FromName, m.SizeBytes, m.Snippet, m.Precedence, m.XMailer, m.ListID)
 		if err != nil {
 			return err
 		}
+		if m.From == "" {
+			continue
+		}
+		if _, err := contacts.ExecContext(ctx, m.From, m.FromName, m.DateRFC3339); err != nil {
+			return err
+		}
 	}
 	return tx.Commit()
 }
@@ -125,9 +151,14 @@ func (s *SQLiteStore) DeleteMessages(ctx context.Context, ids []string) error {
 	return tx.Commit()
 }
 
+func (s *SQLiteStore) ClearMessages(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM messages")
+	return err
+}
+
 func (s *SQLiteStore) LoadAllMessages(ctx context.Context) ([]model.MessageRef, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, from_email, subject, date_rfc3339, list_unsubscribe, list_unsubscribe_post FROM messages")
+		"SELECT "+messageColumns+" FROM messages")
 	if err != nil {
 		return nil, err
 	}
@@ -135,8 +166,8 @@ func (s *SQLiteStore) LoadAllMessages(ctx context.Context) ([]model.MessageRef,
 
 	var msgs []model.MessageRef
 	for rows.Next() {
-		var m model.MessageRef
-		if err := rows.Scan(&m.ID, &m.From, &m.Subject, &m.DateRFC3339, &m.ListUnsubscribe, &m.ListUnsubscribePost); err != nil {
+		m, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
 		msgs = append(msgs, m)
@@ -154,7 +185,7 @@ func (s *SQLiteStore) GetMessagesByIDs(ctx context.Context, ids []string) ([]mod
 		placeholders[i] = "?"
 		args[i] = id
 	}
-	query := "SELECT id, from_email, subject, date_rfc3339, list_unsubscribe, list_unsubscribe_post FROM messages WHERE id IN (" + strings.Join(placeholders, ",") + ")"
+	query := "SELECT " + messageColumns + " FROM messages WHERE id IN (" + strings.Join(placeholders, ",") + ")"
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
@@ -163,8 +194,8 @@ func (s *SQLiteStore) GetMessagesByIDs(ctx context.Context, ids []string) ([]mod
 
 	var msgs []model.MessageRef
 	for rows.Next() {
-		var m model.MessageRef
-		if err := rows.Scan(&m.ID, &m.From, &m.Subject, &m.DateRFC3339, &m.ListUnsubscribe, &m.ListUnsubscribePost); err != nil {
+		m, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
 		msgs = append(msgs, m)
@@ -172,25 +203,785 @@ func (s *SQLiteStore) GetMessagesByIDs(ctx context.Context, ids []string) ([]mod
 	return msgs, rows.Err()
 }
 
+// UpdateLabels replaces the label IDs of cached messages, keyed by message
+// ID. IDs that are not cached are ignored.
+func (s *SQLiteStore) UpdateLabels(ctx context.Context, labels map[string][]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "UPDATE messages SET label_ids = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, ids := range labels {
+		if _, err := stmt.ExecContext(ctx, joinLabels(ids), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *SQLiteStore) CountMessages(ctx context.Context) (int, error) {
 	var count int
 	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages").Scan(&count)
 	return count, err
 }
 
+// OldestMessages returns up to limit dated messages from before before (any
+// date when it is zero), oldest first.
+func (s *SQLiteStore) OldestMessages(ctx context.Context, before time.Time, limit int) ([]model.MessageRef, error) {
+	bound := "9999"
+	if !before.IsZero() {
+		bound = before.UTC().Format(time.RFC3339)
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE date_rfc3339 != '' AND date_rfc3339 < ?
+		ORDER BY date_rfc3339 LIMIT ?`, bound, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []model.MessageRef
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// UsedBytes is the size of the database's pages in use; pages freed by
+// deletes are reused before the file grows.
+func (s *SQLiteStore) UsedBytes(ctx context.Context) (int64, error) {
+	var pages, free, size int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT p.page_count, f.freelist_count, s.page_size FROM pragma_page_count p, pragma_freelist_count f, pragma_page_size s").
+		Scan(&pages, &free, &size)
+	return (pages - free) * size, err
+}
+
 func (s *SQLiteStore) GetLastHistoryID(ctx context.Context) (string, error) {
+	return s.GetMetadata(ctx, "last_history_id")
+}
+
+func (s *SQLiteStore) SetLastHistoryID(ctx context.Context, historyID string) error {
+	return s.SetMetadata(ctx, "last_history_id", historyID)
+}
+
+// GetMetadata returns the value stored under key, or "" if it is unset.
+func (s *SQLiteStore) GetMetadata(ctx context.Context, key string) (string, error) {
 	var val string
-	err := s.db.QueryRowContext(ctx, "SELECT value FROM metadata WHERE key = 'last_history_id'").Scan(&val)
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM metadata WHERE key = ?", key).Scan(&val)
 	if err == sql.ErrNoRows {
 		return "", nil
 	}
 	return val, err
 }
 
-func (s *SQLiteStore) SetLastHistoryID(ctx context.Context, historyID string) error {
+// SetMetadata stores value under key, replacing any previous value.
+func (s *SQLiteStore) SetMetadata(ctx context.Context, key, value string) error {
 	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO metadata (key, value) VALUES ('last_history_id', ?)
+		INSERT INTO metadata (key, value) VALUES (?, ?)
 		ON CONFLICT(key) DO UPDATE SET value = excluded.value
-	`, historyID)
+	`, key, value)
+	return err
+}
+
+// SetGroupPinned pins or unpins the sender+subject group. Pins outlive the
+// group's messages so a sender stays pinned across archive and resync.
+func (s *SQLiteStore) SetGroupPinned(ctx context.Context, key model.GroupKey, pinned bool) error {
+	var err error
+	if pinned {
+		_, err = s.db.ExecContext(ctx,
+			"INSERT OR IGNORE INTO pinned_groups (from_email, subject) VALUES (?, ?)", key.Email, key.Subject)
+	} else {
+		_, err = s.db.ExecContext(ctx,
+			"DELETE FROM pinned_groups WHERE from_email = ? AND subject = ?", key.Email, key.Subject)
+	}
+	return err
+}
+
+// LoadPinnedGroups returns the keys of all pinned groups.
+func (s *SQLiteStore) LoadPinnedGroups(ctx context.Context) ([]model.GroupKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT from_email, subject FROM pinned_groups")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.GroupKey
+	for rows.Next() {
+		var k model.GroupKey
+		if err := rows.Scan(&k.Email, &k.Subject); err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+// SetSenderStatus protects or blocks the sender address or @domain match,
+// replacing its previous status; an empty status removes it from the lists.
+func (s *SQLiteStore) SetSenderStatus(ctx context.Context, match string, status model.SenderStatus) error {
+	var err error
+	if status == "" {
+		_, err = s.db.ExecContext(ctx, "DELETE FROM sender_lists WHERE match = ?", match)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			INSERT INTO sender_lists (match, status) VALUES (?, ?)
+			ON CONFLICT(match) DO UPDATE SET status = excluded.status
+		`, match, string(status))
+	}
+	return err
+}
+
+// LoadSenderStatuses returns every protected and blocked sender, ordered by match.
+func (s *SQLiteStore) LoadSenderStatuses(ctx context.Context) ([]model.SenderRule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT match, status FROM sender_lists ORDER BY match")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.SenderRule
+	for rows.Next() {
+		var r model.SenderRule
+		if err := rows.Scan(&r.Match, &r.Status); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// SaveRule adds r when its ID is 0 and replaces the rule with its ID
+// otherwise. It returns the rule's ID.
+func (s *SQLiteStore) SaveRule(ctx context.Context, r model.Rule) (int64, error) {
+	if r.ID == 0 {
+		res, err := s.db.ExecContext(ctx, `
+			INSERT INTO rules (sender, subject, older_than, label, has_unsubscribe, action, add_label, disabled, every)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.Sender, r.Subject, r.OlderThan, r.Label, r.Unsubscribe, string(r.Action), r.AddLabel, r.Disabled, r.Every)
+		if err != nil {
+			return 0, err
+		}
+		return res.LastInsertId()
+	}
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE rules SET sender = ?, subject = ?, older_than = ?, label = ?, has_unsubscribe = ?,
+			action = ?, add_label = ?, disabled = ?, every = ?
+		WHERE id = ?
+	`, r.Sender, r.Subject, r.OlderThan, r.Label, r.Unsubscribe, string(r.Action), r.AddLabel, r.Disabled, r.Every, r.ID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return 0, fmt.Errorf("no rule %d", r.ID)
+	}
+	return r.ID, nil
+}
+
+// DeleteRule removes the rule with the given ID.
+func (s *SQLiteStore) DeleteRule(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM rules WHERE id = ?", id)
+	return err
+}
+
+// LoadRules returns every rule in the order they were added, which is the
+// order they run in.
+func (s *SQLiteStore) LoadRules(ctx context.Context) ([]model.Rule, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, sender, subject, older_than, label, has_unsubscribe, action, add_label, disabled, every FROM rules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.Rule
+	for rows.Next() {
+		var r model.Rule
+		if err := rows.Scan(&r.ID, &r.Sender, &r.Subject, &r.OlderThan, &r.Label, &r.Unsubscribe,
+			&r.Action, &r.AddLabel, &r.Disabled, &r.Every); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// RecordUnsubscribe stores u as the latest unsubscribe from its sender.
+func (s *SQLiteStore) RecordUnsubscribe(ctx context.Context, u model.Unsubscription) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO unsubscribes (sender, at, method, url) VALUES (?, ?, ?, ?)
+		ON CONFLICT(sender) DO UPDATE SET at = excluded.at, method = excluded.method, url = excluded.url
+	`, u.Sender, u.Time.Unix(), u.Method, u.URL)
+	return err
+}
+
+// LoadUnsubscribes returns the latest unsubscribe from each sender, ordered
+// by sender.
+func (s *SQLiteStore) LoadUnsubscribes(ctx context.Context) ([]model.Unsubscription, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT sender, at, method, url FROM unsubscribes ORDER BY sender")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.Unsubscription
+	for rows.Next() {
+		var u model.Unsubscription
+		var at int64
+		if err := rows.Scan(&u.Sender, &at, &u.Method, &u.URL); err != nil {
+			return nil, err
+		}
+		u.Time = time.Unix(at, 0)
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// SaveSnooze stores sn, replacing any earlier snooze of the same message.
+func (s *SQLiteStore) SaveSnooze(ctx context.Context, sn model.Snooze) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO snoozes (id, until, sender, subject) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET until = excluded.until, sender = excluded.sender, subject = excluded.subject
+	`, sn.ID, sn.Until.Unix(), sn.From, sn.Subject)
+	return err
+}
+
+// DeleteSnooze forgets the snooze of message id.
+func (s *SQLiteStore) DeleteSnooze(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM snoozes WHERE id = ?", id)
+	return err
+}
+
+// LoadSnoozes returns the pending snoozes, soonest first.
+// LoadContacts returns the senders of every message stored, most recently
+// seen first.
+func (s *SQLiteStore) LoadContacts(ctx context.Context) ([]model.Contact, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT email, name, last_seen FROM contacts ORDER BY last_seen DESC, email")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.Contact
+	for rows.Next() {
+		var c model.Contact
+		if err := rows.Scan(&c.Email, &c.Name, &c.LastSeen); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) LoadSnoozes(ctx context.Context) ([]model.Snooze, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, until, sender, subject FROM snoozes ORDER BY until, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.Snooze
+	for rows.Next() {
+		var sn model.Snooze
+		var until int64
+		if err := rows.Scan(&sn.ID, &until, &sn.From, &sn.Subject); err != nil {
+			return nil, err
+		}
+		sn.Until = time.Unix(until, 0)
+		out = append(out, sn)
+	}
+	return out, rows.Err()
+}
+
+// SaveTrashed remembers msgs as trashed, replacing earlier records of the
+// same messages.
+func (s *SQLiteStore) SaveTrashed(ctx context.Context, msgs []model.Trashed) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO trashed (id, at, sender, subject, date) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET at = excluded.at, sender = excluded.sender,
+			subject = excluded.subject, date = excluded.date
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, t := range msgs {
+		if _, err := stmt.ExecContext(ctx, t.ID, t.At.Unix(), t.From, t.Subject, t.Date); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteTrashed forgets the trashed messages ids.
+func (s *SQLiteStore) DeleteTrashed(ctx context.Context, ids []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "DELETE FROM trashed WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, id := range ids {
+		if _, err := stmt.ExecContext(ctx, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadTrashed returns the trashed messages, most recently trashed first.
+func (s *SQLiteStore) LoadTrashed(ctx context.Context) ([]model.Trashed, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, at, sender, subject, date FROM trashed ORDER BY at DESC, date DESC, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.Trashed
+	for rows.Next() {
+		var t model.Trashed
+		var at int64
+		if err := rows.Scan(&t.ID, &at, &t.From, &t.Subject, &t.Date); err != nil {
+			return nil, err
+		}
+		t.At = time.Unix(at, 0)
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// LogRuleRun appends run to the log of rule runs.
+func (s *SQLiteStore) LogRuleRun(ctx context.Context, run model.RuleRun) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO rule_runs (rule_id, at, rule, messages, error) VALUES (?, ?, ?, ?, ?)",
+		run.RuleID, run.Time.Unix(), run.Rule, run.Messages, run.Err)
+	return err
+}
+
+// LoadRuleRuns returns up to limit logged rule runs, newest first.
+func (s *SQLiteStore) LoadRuleRuns(ctx context.Context, limit int) ([]model.RuleRun, error) {
+	return s.queryRuleRuns(ctx,
+		"SELECT rule_id, at, rule, messages, error FROM rule_runs ORDER BY at DESC, id DESC LIMIT ?", limit)
+}
+
+// LastRuleRuns returns the latest logged run of each rule.
+func (s *SQLiteStore) LastRuleRuns(ctx context.Context) (map[int64]model.RuleRun, error) {
+	runs, err := s.queryRuleRuns(ctx, `
+		SELECT rule_id, at, rule, messages, error FROM rule_runs
+		WHERE id IN (SELECT MAX(id) FROM rule_runs GROUP BY rule_id)`)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int64]model.RuleRun, len(runs))
+	for _, r := range runs {
+		out[r.RuleID] = r
+	}
+	return out, nil
+}
+
+func (s *SQLiteStore) queryRuleRuns(ctx context.Context, query string, args ...any) ([]model.RuleRun, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.RuleRun
+	for rows.Next() {
+		var r model.RuleRun
+		var at int64
+		if err := rows.Scan(&r.RuleID, &at, &r.Rule, &r.Messages, &r.Err); err != nil {
+			return nil, err
+		}
+		r.Time = time.Unix(at, 0)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// RecordAction appends a to the action history.
+func (s *SQLiteStore) RecordAction(ctx context.Context, a model.Action) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO actions (at, kind, sender, subject, messages) VALUES (?, ?, ?, ?, ?)",
+		a.Time.Unix(), a.Kind, a.Sender, a.Subject, a.Messages)
+	return err
+}
+
+// LoadActions returns the actions recorded at or after since, oldest first.
+func (s *SQLiteStore) LoadActions(ctx context.Context, since time.Time) ([]model.Action, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT at, kind, sender, subject, messages FROM actions WHERE at >= ? ORDER BY at, rowid", since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.Action
+	for rows.Next() {
+		var a model.Action
+		var at int64
+		if err := rows.Scan(&at, &a.Kind, &a.Sender, &a.Subject, &a.Messages); err != nil {
+			return nil, err
+		}
+		a.Time = time.Unix(at, 0)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// summaryColumns aggregates one sender+subject group in the order
+// scanSummaries reads them.
+var summaryColumns = `from_email, subject, COUNT(*),
+	SUM(',' || label_ids || ',' LIKE '%,UNREAD,%'),
+	COALESCE(MIN(NULLIF(date_rfc3339, '')), ''),
+	COALESCE(MAX(NULLIF(date_rfc3339, '')), ''),
+	SUM(size_estimate),
+	COALESCE(MAX(CASE WHEN list_unsubscribe LIKE '%http%' THEN list_unsubscribe END),
+		MAX(NULLIF(list_unsubscribe, '')), ''),
+	MAX(list_unsubscribe_post LIKE '%one-click%'),
+	EXISTS (SELECT 1 FROM pinned_groups p WHERE p.from_email = messages.from_email AND p.subject = messages.subject),
+	COALESCE(GROUP_CONCAT(substr(NULLIF(date_rfc3339, ''), 1, 7)), ''),
+	MAX(` + bulkCondition() + `),
+	COALESCE(MAX(CASE WHEN from_name != '' THEN date_rfc3339 || '|' || from_name END), '')`
+
+// bulkCondition is model.MessageRef.Bulk as an SQL condition on a row of
+// messages.
+func bulkCondition() string {
+	conds := []string{
+		"list_id != ''",
+		"list_unsubscribe != ''",
+		"LOWER(TRIM(precedence)) IN ('" + strings.Join(model.BulkPrecedences, "', '") + "')",
+	}
+	for _, mailer := range model.BulkMailers {
+		conds = append(conds, "LOWER(x_mailer) LIKE '%"+mailer+"%'")
+	}
+	return strings.Join(conds, " OR ")
+}
+
+// LoadGroupSummaries aggregates messages by sender and subject in SQL so the
+// caller never has to hold every message in memory.
+func (s *SQLiteStore) LoadGroupSummaries(ctx context.Context) ([]model.GroupSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+summaryColumns+` FROM messages GROUP BY from_email, subject`)
+	if err != nil {
+		return nil, err
+	}
+	return scanSummaries(rows)
+}
+
+// LoadGroupSummaryPage returns up to limit groups after skipping offset, in
+// the order of gmail.OrderCount: pinned groups first, then by message count,
+// sender and subject. Messages without a sender are left out.
+func (s *SQLiteStore) LoadGroupSummaryPage(ctx context.Context, offset, limit int) ([]model.GroupSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+summaryColumns+`
+		FROM messages
+		WHERE from_email != ''
+		GROUP BY from_email, subject
+		ORDER BY 10 DESC, 3 DESC, from_email, subject -- pinned, then count
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanSummaries(rows)
+}
+
+// GroupTotals counts the sender+subject groups and their messages, leaving
+// out messages without a sender as LoadGroupSummaryPage does.
+func (s *SQLiteStore) GroupTotals(ctx context.Context) (model.GroupTotals, error) {
+	var t model.GroupTotals
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(n), 0), COALESCE(SUM(unread), 0)
+		FROM (
+			SELECT COUNT(*) AS n, SUM(',' || label_ids || ',' LIKE '%,UNREAD,%') AS unread
+			FROM messages
+			WHERE from_email != ''
+			GROUP BY from_email, subject
+		)`).Scan(&t.Groups, &t.Messages, &t.Unread)
+	return t, err
+}
+
+func scanSummaries(rows *sql.Rows) ([]model.GroupSummary, error) {
+	defer rows.Close()
+	var out []model.GroupSummary
+	for rows.Next() {
+		var g model.GroupSummary
+		var months, named string
+		if err := rows.Scan(&g.Email, &g.Subject, &g.Count, &g.Unread, &g.FirstDate, &g.LastDate, &g.Size, &g.ListUnsubscribe, &g.OneClick, &g.Pinned, &months, &g.Bulk, &named); err != nil {
+			return nil, err
+		}
+		g.Months = countMonths(months)
+		// named is "date|name" of the newest message with a name; dates
+		// hold no "|".
+		_, g.FromName, _ = strings.Cut(named, "|")
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// countMonths counts the months of a comma-separated list such as
+// "2024-01,2024-02,2024-01".
+func countMonths(list string) map[string]int {
+	if list == "" {
+		return nil
+	}
+	months := make(map[string]int)
+	for _, month := range strings.Split(list, ",") {
+		months[month]++
+	}
+	return months
+}
+
+// StreamGroupMessageIDs calls fn with successive batches of message IDs that
+// belong to the given sender+subject group.
+func (s *SQLiteStore) StreamGroupMessageIDs(ctx context.Context, email, subject string, batchSize int, fn func(ids []string) error) error {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id FROM messages WHERE from_email = ? AND subject = ?", email, subject)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// WAL mode lets fn write to the database while this reader holds its snapshot.
+	buf := make([]string, 0, batchSize)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		buf = append(buf, id)
+		if len(buf) >= batchSize {
+			if err := fn(buf); err != nil {
+				return err
+			}
+			buf = make([]string, 0, batchSize)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(buf) > 0 {
+		return fn(buf)
+	}
+	return nil
+}
+
+// GetGroupMessages returns up to limit messages of a sender+subject group,
+// newest first and then by ID, starting after the message after unless its
+// ID is empty. A limit <= 0 returns every message.
+func (s *SQLiteStore) GetGroupMessages(ctx context.Context, email, subject string, after model.MessageRef, limit int) ([]model.MessageRef, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE from_email = ? AND subject = ?
+			AND (? = '' OR date_rfc3339 < ? OR (date_rfc3339 = ? AND id < ?))
+		ORDER BY date_rfc3339 DESC, id DESC LIMIT ?`,
+		email, subject, after.ID, after.DateRFC3339, after.DateRFC3339, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []model.MessageRef
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// CountGroupAges counts the messages of a sender+subject group by date, as
+// gmail.GroupAgeStore describes. Dates are stored in UTC, so the bounds
+// compare as text.
+func (s *SQLiteStore) CountGroupAges(ctx context.Context, email, subject string, since []time.Time) ([]int, int, error) {
+	var bucket strings.Builder
+	bucket.WriteString("CASE WHEN date_rfc3339 = '' THEN -1")
+	args := make([]any, 0, len(since)+2)
+	for i, t := range since {
+		fmt.Fprintf(&bucket, " WHEN date_rfc3339 >= ? THEN %d", i)
+		args = append(args, t.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(&bucket, " ELSE %d END", len(since))
+	args = append(args, email, subject)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+bucket.String()+` AS bucket, COUNT(*)
+		FROM messages WHERE from_email = ? AND subject = ?
+		GROUP BY bucket`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts := make([]int, len(since)+1)
+	undated := 0
+	for rows.Next() {
+		var b, n int
+		if err := rows.Scan(&b, &n); err != nil {
+			return nil, 0, err
+		}
+		if b < 0 {
+			undated = n
+		} else {
+			counts[b] = n
+		}
+	}
+	return counts, undated, rows.Err()
+}
+
+// GetBody returns the cached body of a message and whether it was cached,
+// marking it as recently read.
+func (s *SQLiteStore) GetBody(ctx context.Context, id string) (model.MessageBody, bool, error) {
+	var body model.MessageBody
+	var attachments, invites string
+	err := s.db.QueryRowContext(ctx, "SELECT body, attachments, invites FROM bodies WHERE id = ?", id).
+		Scan(&body.Text, &attachments, &invites)
+	if err == sql.ErrNoRows {
+		return body, false, nil
+	}
+	if err != nil {
+		return body, false, err
+	}
+	if attachments != "" {
+		if err := json.Unmarshal([]byte(attachments), &body.Attachments); err != nil {
+			return body, false, fmt.Errorf("decode cached attachments of %s: %w", id, err)
+		}
+	}
+	if invites != "" {
+		if err := json.Unmarshal([]byte(invites), &body.Invites); err != nil {
+			return body, false, fmt.Errorf("decode cached invites of %s: %w", id, err)
+		}
+	}
+	_, err = s.db.ExecContext(ctx, "UPDATE bodies SET accessed_at = ? WHERE id = ?", time.Now().UnixNano(), id)
+	return body, true, err
+}
+
+// PutBody caches a message body, then evicts the least recently read bodies
+// until the cache holds at most maxBytes. A body larger than maxBytes is not
+// cached.
+func (s *SQLiteStore) PutBody(ctx context.Context, id string, body model.MessageBody, maxBytes int64) error {
+	var attachments, invites []byte
+	var err error
+	if len(body.Attachments) > 0 {
+		if attachments, err = json.Marshal(body.Attachments); err != nil {
+			return err
+		}
+	}
+	if len(body.Invites) > 0 {
+		if invites, err = json.Marshal(body.Invites); err != nil {
+			return err
+		}
+	}
+	size := len(body.Text) + len(attachments) + len(invites)
+	if int64(size) > maxBytes {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO bodies (id, body, attachments, invites, size, accessed_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			body        = excluded.body,
+			attachments = excluded.attachments,
+			invites     = excluded.invites,
+			size        = excluded.size,
+			accessed_at = excluded.accessed_at
+	`, id, body.Text, string(attachments), string(invites), size, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	// Walk bodies newest first and drop everything past the budget.
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM bodies WHERE id IN (
+			SELECT id FROM (
+				SELECT id, SUM(size) OVER (ORDER BY accessed_at DESC, id) AS running
+				FROM bodies
+			) WHERE running > ?
+		)`, maxBytes)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Maintain checks the database's integrity, then rebuilds it without free
+// pages, refreshes the query planner's statistics and folds the write-ahead
+// log into it.
+func (s *SQLiteStore) Maintain(ctx context.Context) (Maintenance, error) {
+	m := Maintenance{SizeBefore: fileSizes(s.path, s.path+"-wal")}
+	rows, err := s.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return m, err
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return m, err
+		}
+		if line != "ok" {
+			m.Problems = append(m.Problems, line)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return m, err
+	}
+	if len(m.Problems) > 0 {
+		return m, fmt.Errorf("integrity check found %d problems", len(m.Problems))
+	}
+	// VACUUM goes through the write-ahead log too, so fold it in last.
+	for _, stmt := range []string{"VACUUM", "ANALYZE", "PRAGMA wal_checkpoint(TRUNCATE)"} {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return m, fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	m.SizeAfter = fileSizes(s.path, s.path+"-wal")
+	return m, nil
+}
+
+// BackupTo writes a consistent copy of the database to path using VACUUM INTO,
+// which is safe while other connections are reading or writing.
+func (s *SQLiteStore) BackupTo(ctx context.Context, path string) error {
+	os.Remove(path)
+	_, err := s.db.ExecContext(ctx, "VACUUM INTO ?", path)
 	return err
 }
@@ -2,9 +2,15 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"chuckterm/internal/ics"
 	"chuckterm/internal/model"
 )
 
@@ -24,7 +30,7 @@ func TestUpsertAndLoad(t *testing.T) {
 	ctx := context.Background()
 
 	msgs := []model.MessageRef{
-		{ID: "1", From: "a@b.com", Subject: "hello", DateRFC3339: "2024-01-01T00:00:00Z"},
+		{ID: "1", From: "a@b.com", FromName: "Ann B", Subject: "hello", DateRFC3339: "2024-01-01T00:00:00Z", Snippet: "Hi there"},
 		{ID: "2", From: "c@d.com", Subject: "world", DateRFC3339: "2024-01-02T00:00:00Z", ListUnsubscribe: "<https://unsub.example.com>"},
 	}
 	if err := s.UpsertMessages(ctx, msgs); err != nil {
@@ -55,7 +61,7 @@ func TestUpsertAndLoad(t *testing.T) {
 	loaded, _ = s.LoadAllMessages(ctx)
 	found := false
 	for _, m := range loaded {
-		if m.ID == "1" && m.Subject == "updated" {
+		if m.ID == "1" && m.Subject == "updated" && m.FromName == "Ann B" && m.Snippet == "Hi there" {
 			found = true
 		}
 	}
@@ -109,3 +115,712 @@ func TestHistoryID(t *testing.T) {
 		t.Fatalf("expected 99999, got %q", hid)
 	}
 }
+
+func TestLoadGroupSummariesAndStreamIDs(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	msgs := []model.MessageRef{
+		{ID: "1", From: "a@b.com", FromName: "B News", Subject: "news", DateRFC3339: "2024-01-01T00:00:00Z", ListUnsubscribe: "<mailto:x@b.com>", SizeBytes: 1000},
+		{ID: "2", From: "a@b.com", FromName: "B Weekly", Subject: "news", DateRFC3339: "2024-02-01T00:00:00Z", ListUnsubscribe: "<https://b.com/unsub>", SizeBytes: 2500},
+		{ID: "3", From: "a@b.com", Subject: "news", DateRFC3339: ""},
+		{ID: "4", From: "c@d.com", Subject: "hi", DateRFC3339: "2024-03-01T00:00:00Z"},
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatalf("UpsertMessages: %v", err)
+	}
+
+	sums, err := s.LoadGroupSummaries(ctx)
+	if err != nil {
+		t.Fatalf("LoadGroupSummaries: %v", err)
+	}
+	if len(sums) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(sums))
+	}
+	var news model.GroupSummary
+	for _, g := range sums {
+		if g.Email == "a@b.com" {
+			news = g
+		}
+	}
+	if news.Count != 3 || news.Size != 3500 {
+		t.Fatalf("expected count 3 and size 3500, got %d and %d", news.Count, news.Size)
+	}
+	if news.FirstDate != "2024-01-01T00:00:00Z" || news.LastDate != "2024-02-01T00:00:00Z" {
+		t.Fatalf("unexpected date range %q..%q", news.FirstDate, news.LastDate)
+	}
+	if news.FromName != "B Weekly" {
+		t.Fatalf("expected the newest display name, got %q", news.FromName)
+	}
+	if news.ListUnsubscribe != "<https://b.com/unsub>" {
+		t.Fatalf("expected HTTP unsubscribe header, got %q", news.ListUnsubscribe)
+	}
+	if len(news.Months) != 2 || news.Months["2024-01"] != 1 || news.Months["2024-02"] != 1 {
+		t.Fatalf("unexpected months %v", news.Months)
+	}
+
+	var batches [][]string
+	err = s.StreamGroupMessageIDs(ctx, "a@b.com", "news", 2, func(ids []string) error {
+		batches = append(batches, ids)
+		return s.DeleteMessages(ctx, ids)
+	})
+	if err != nil {
+		t.Fatalf("StreamGroupMessageIDs: %v", err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+	count, _ := s.CountMessages(ctx)
+	if count != 1 {
+		t.Fatalf("expected 1 message left, got %d", count)
+	}
+
+	latest, err := s.GetGroupMessages(ctx, "c@d.com", "hi", model.MessageRef{}, 10)
+	if err != nil || len(latest) != 1 || latest[0].ID != "4" {
+		t.Fatalf("GetGroupMessages = %v, %v", latest, err)
+	}
+}
+
+func TestGetGroupMessagesPages(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	var msgs []model.MessageRef
+	for _, m := range []struct{ id, date string }{
+		{"a", "2024-03-01T00:00:00Z"},
+		{"b", "2024-02-01T00:00:00Z"},
+		{"c", "2024-02-01T00:00:00Z"},
+		{"d", "2024-01-01T00:00:00Z"},
+		{"e", ""},
+	} {
+		msgs = append(msgs, model.MessageRef{ID: m.id, From: "a@b.com", Subject: "news", DateRFC3339: m.date})
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatalf("UpsertMessages: %v", err)
+	}
+
+	for _, tc := range []struct {
+		limit int
+		want  string
+	}{
+		{1, "a,c,b,d,e"},
+		{2, "a,c,b,d,e"},
+		{0, "a,c,b,d,e"}, // all at once
+	} {
+		var got []string
+		var after model.MessageRef
+		for pages := 0; pages < 10; pages++ {
+			page, err := s.GetGroupMessages(ctx, "a@b.com", "news", after, tc.limit)
+			if err != nil {
+				t.Fatalf("limit %d: GetGroupMessages: %v", tc.limit, err)
+			}
+			for _, m := range page {
+				got = append(got, m.ID)
+			}
+			if len(page) == 0 || tc.limit <= 0 || len(page) < tc.limit {
+				break
+			}
+			after = page[len(page)-1]
+		}
+		if g := strings.Join(got, ","); g != tc.want {
+			t.Errorf("limit %d: paged %s; want %s", tc.limit, g, tc.want)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	if v, err := s.GetMetadata(ctx, "fullscan_page_token"); err != nil || v != "" {
+		t.Fatalf("unset key: v=%q err=%v", v, err)
+	}
+	if err := s.SetMetadata(ctx, "fullscan_page_token", "abc"); err != nil {
+		t.Fatalf("SetMetadata: %v", err)
+	}
+	s.SetMetadata(ctx, "fullscan_page_token", "def")
+	if v, _ := s.GetMetadata(ctx, "fullscan_page_token"); v != "def" {
+		t.Fatalf("expected def, got %q", v)
+	}
+	// Keys are independent of the history cursor.
+	if hid, _ := s.GetLastHistoryID(ctx); hid != "" {
+		t.Fatalf("expected empty history id, got %q", hid)
+	}
+}
+
+func TestCountGroupAges(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	day := func(n int) string { return now.AddDate(0, 0, -n).Format(time.RFC3339) }
+	msgs := []model.MessageRef{
+		{ID: "1", From: "news@x.example", Subject: "Hi", DateRFC3339: day(1)},
+		{ID: "2", From: "news@x.example", Subject: "Hi", DateRFC3339: day(7)},
+		{ID: "3", From: "news@x.example", Subject: "Hi", DateRFC3339: day(20)},
+		{ID: "4", From: "news@x.example", Subject: "Hi", DateRFC3339: day(400)},
+		{ID: "5", From: "news@x.example", Subject: "Hi"},
+		{ID: "6", From: "news@x.example", Subject: "Other", DateRFC3339: day(1)},
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatal(err)
+	}
+	since := []time.Time{now.AddDate(0, 0, -7), now.AddDate(0, 0, -30), now.AddDate(0, 0, -90)}
+	counts, undated, err := s.CountGroupAges(ctx, "news@x.example", "Hi", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(counts, []int{2, 1, 0, 1}) || undated != 1 {
+		t.Errorf("CountGroupAges = %v, %d undated; want [2 1 0 1], 1 undated", counts, undated)
+	}
+}
+
+func TestPinnedGroups(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	a := model.GroupKey{Email: "a@b.com", Subject: "hello"}
+	b := model.GroupKey{Email: "c@d.com"}
+	for _, k := range []model.GroupKey{a, b, a} {
+		if err := s.SetGroupPinned(ctx, k, true); err != nil {
+			t.Fatalf("SetGroupPinned: %v", err)
+		}
+	}
+	if err := s.SetGroupPinned(ctx, a, false); err != nil {
+		t.Fatalf("unpin: %v", err)
+	}
+	pinned, err := s.LoadPinnedGroups(ctx)
+	if err != nil || len(pinned) != 1 || pinned[0] != b {
+		t.Fatalf("LoadPinnedGroups = %v, %v", pinned, err)
+	}
+}
+
+func TestSenderStatuses(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	for _, r := range []model.SenderRule{
+		{Match: "news@b.com", Status: model.SenderBlocked},
+		{Match: "@bank.com", Status: model.SenderProtected},
+		{Match: "news@b.com", Status: model.SenderProtected},
+		{Match: "gone@c.com", Status: model.SenderBlocked},
+	} {
+		if err := s.SetSenderStatus(ctx, r.Match, r.Status); err != nil {
+			t.Fatalf("SetSenderStatus: %v", err)
+		}
+	}
+	if err := s.SetSenderStatus(ctx, "gone@c.com", ""); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	got, err := s.LoadSenderStatuses(ctx)
+	want := []model.SenderRule{
+		{Match: "@bank.com", Status: model.SenderProtected},
+		{Match: "news@b.com", Status: model.SenderProtected},
+	}
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("LoadSenderStatuses = %v, %v", got, err)
+	}
+}
+
+func TestRules(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	first := model.Rule{Sender: "@shop.example", OlderThan: "30d", Unsubscribe: true, Action: model.RuleArchive, Every: "@daily"}
+	second := model.Rule{Subject: "receipt", Label: "INBOX", Action: model.RuleLabel, AddLabel: "Receipts"}
+	var err error
+	if first.ID, err = s.SaveRule(ctx, first); err != nil {
+		t.Fatalf("SaveRule: %v", err)
+	}
+	if second.ID, err = s.SaveRule(ctx, second); err != nil {
+		t.Fatalf("SaveRule: %v", err)
+	}
+	second.Disabled = true
+	if _, err := s.SaveRule(ctx, second); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := s.SaveRule(ctx, model.Rule{ID: 99, Action: model.RuleTrash}); err == nil {
+		t.Fatal("updating a missing rule succeeded")
+	}
+	rules, err := s.LoadRules(ctx)
+	if err != nil || len(rules) != 2 || rules[0] != first || rules[1] != second {
+		t.Fatalf("LoadRules = %+v, %v", rules, err)
+	}
+	if err := s.DeleteRule(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	if rules, _ := s.LoadRules(ctx); len(rules) != 1 || rules[0].ID != second.ID {
+		t.Fatalf("after delete: %+v", rules)
+	}
+}
+
+func TestUnsubscribes(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, u := range []model.Unsubscription{
+		{Sender: "news@b.example", Time: day, Method: "browser", URL: "https://b.example/u"},
+		{Sender: "list@a.example", Time: day, Method: "mailto", URL: "mailto:leave@a.example"},
+		{Sender: "news@b.example", Time: day.Add(time.Hour), Method: "one-click", URL: "https://b.example/1"},
+	} {
+		if err := s.RecordUnsubscribe(ctx, u); err != nil {
+			t.Fatalf("RecordUnsubscribe: %v", err)
+		}
+	}
+	got, err := s.LoadUnsubscribes(ctx)
+	if err != nil || len(got) != 2 || got[0].Method != "mailto" || got[1].Method != "one-click" ||
+		!got[1].Time.Equal(day.Add(time.Hour)) || got[1].URL != "https://b.example/1" {
+		t.Fatalf("LoadUnsubscribes = %+v, %v", got, err)
+	}
+}
+
+func TestSnoozes(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, sn := range []model.Snooze{
+		{ID: "m1", Until: day.Add(48 * time.Hour), From: "a@x.example", Subject: "later"},
+		{ID: "m2", Until: day.Add(24 * time.Hour), From: "b@x.example", Subject: "sooner"},
+		{ID: "m1", Until: day.Add(2 * time.Hour), From: "a@x.example", Subject: "later"},
+	} {
+		if err := s.SaveSnooze(ctx, sn); err != nil {
+			t.Fatalf("SaveSnooze: %v", err)
+		}
+	}
+	got, err := s.LoadSnoozes(ctx)
+	if err != nil || len(got) != 2 || got[0].ID != "m1" || !got[0].Until.Equal(day.Add(2*time.Hour)) || got[1].Subject != "sooner" {
+		t.Fatalf("LoadSnoozes = %+v, %v", got, err)
+	}
+	if err := s.DeleteSnooze(ctx, "m1"); err != nil {
+		t.Fatalf("DeleteSnooze: %v", err)
+	}
+	if got, err := s.LoadSnoozes(ctx); err != nil || len(got) != 1 || got[0].ID != "m2" {
+		t.Fatalf("after delete = %+v, %v", got, err)
+	}
+}
+
+func TestTrashed(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	err := s.SaveTrashed(ctx, []model.Trashed{
+		{ID: "m1", At: day, From: "a@x.example", Subject: "first", Date: "2025-02-01T09:00:00Z"},
+		{ID: "m2", At: day, From: "a@x.example", Subject: "second", Date: "2025-02-02T09:00:00Z"},
+		{ID: "m3", At: day.Add(time.Hour), From: "b@x.example", Subject: "later", Date: "2025-01-01T09:00:00Z"},
+	})
+	if err != nil {
+		t.Fatalf("SaveTrashed: %v", err)
+	}
+	got, err := s.LoadTrashed(ctx)
+	if err != nil || len(got) != 3 || got[0].ID != "m3" || got[1].ID != "m2" || got[2].Subject != "first" || !got[2].At.Equal(day) {
+		t.Fatalf("LoadTrashed = %+v, %v", got, err)
+	}
+	if err := s.DeleteTrashed(ctx, []string{"m3", "m1"}); err != nil {
+		t.Fatalf("DeleteTrashed: %v", err)
+	}
+	if got, err := s.LoadTrashed(ctx); err != nil || len(got) != 1 || got[0].ID != "m2" {
+		t.Fatalf("after delete = %+v, %v", got, err)
+	}
+}
+
+func TestContacts(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	err := s.UpsertMessages(ctx, []model.MessageRef{
+		{ID: "1", From: "ada@x.example", FromName: "Ada L", DateRFC3339: "2025-03-02T10:00:00Z"},
+		{ID: "2", From: "ada@x.example", FromName: "Ada Lovelace", DateRFC3339: "2025-03-03T10:00:00Z"},
+		{ID: "3", From: "ada@x.example", DateRFC3339: "2025-03-04T10:00:00Z"},
+		{ID: "4", From: "bob@x.example", FromName: "Old Bob", DateRFC3339: "2025-03-01T10:00:00Z"},
+	})
+	if err != nil {
+		t.Fatalf("UpsertMessages: %v", err)
+	}
+	// Contacts outlive their messages.
+	s.DeleteMessages(ctx, []string{"1", "2", "3", "4"})
+	want := []model.Contact{
+		{Email: "ada@x.example", Name: "Ada Lovelace", LastSeen: "2025-03-04T10:00:00Z"},
+		{Email: "bob@x.example", Name: "Old Bob", LastSeen: "2025-03-01T10:00:00Z"},
+	}
+	if got, err := s.LoadContacts(ctx); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadContacts = %+v, %v; want %+v", got, err, want)
+	}
+}
+
+func TestBulkSummaries(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	msgs := []model.MessageRef{
+		{ID: "1", From: "news@shop.example", Subject: "Sale", XMailer: "MailChimp Mailer - **CID1234**"},
+		{ID: "2", From: "dev@lists.example", Subject: "Re: patch", ListID: "<dev.lists.example>"},
+		{ID: "3", From: "alerts@bank.example", Subject: "Alert", Precedence: " Bulk "},
+		{ID: "4", From: "ada@x.example", Subject: "Lunch?", XMailer: "Apple Mail (2.3445)"},
+		{ID: "5", From: "ada@x.example", Subject: "Lunch?", Precedence: "first-class"},
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetMessagesByIDs(ctx, []string{"1", "2", "3"})
+	if err != nil || len(got) != 3 {
+		t.Fatalf("GetMessagesByIDs = %+v, %v", got, err)
+	}
+	for i, m := range got {
+		if !reflect.DeepEqual(m, msgs[i]) {
+			t.Fatalf("message %s = %+v, want %+v", m.ID, m, msgs[i])
+		}
+	}
+
+	sums, err := s.LoadGroupSummaries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range sums {
+		if want := g.Email != "ada@x.example"; g.Bulk != want {
+			t.Fatalf("%s: Bulk = %v, want %v", g.Email, g.Bulk, want)
+		}
+	}
+	for _, m := range msgs {
+		if want := m.From != "ada@x.example"; m.Bulk() != want {
+			t.Fatalf("%s: MessageRef.Bulk = %v, want %v", m.ID, m.Bulk(), want)
+		}
+	}
+}
+
+func TestMigrateFillsContacts(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "v16.db")
+	s, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.db.Exec(`
+		DROP TABLE contacts;
+		DROP TABLE trashed;
+		ALTER TABLE messages DROP COLUMN precedence;
+		ALTER TABLE messages DROP COLUMN x_mailer;
+		ALTER TABLE messages DROP COLUMN list_id;
+		INSERT INTO messages (id, from_email, from_name, date_rfc3339) VALUES
+			('1', 'ada@x.example', 'Ada', '2025-03-02'),
+			('2', 'ada@x.example', '', '2025-03-03'),
+			('3', 'bob@x.example', '', '2025-03-01');
+		PRAGMA user_version = 16;`)
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	want := []model.Contact{
+		{Email: "ada@x.example", Name: "Ada", LastSeen: "2025-03-03"},
+		{Email: "bob@x.example", LastSeen: "2025-03-01"},
+	}
+	if got, err := s.LoadContacts(context.Background()); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadContacts = %+v, %v; want %+v", got, err, want)
+	}
+}
+
+func TestMigrateFlagsMetadataRefresh(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "v19.db")
+	s, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.GetMetadata(ctx, "refresh_metadata"); err != nil || v != "" {
+		t.Fatalf("new cache: refresh_metadata = %q, %v; want it unset", v, err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO messages (id, from_email) VALUES ('1', 'ada@x.example');
+		PRAGMA user_version = 19;`)
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	if v, err := s.GetMetadata(ctx, "refresh_metadata"); err != nil || v != "*" {
+		t.Fatalf("upgraded cache: refresh_metadata = %q, %v; want *", v, err)
+	}
+}
+
+func TestRuleRuns(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	runs := []model.RuleRun{
+		{RuleID: 1, Time: day, Rule: "older:90d -> archive", Messages: 12},
+		{RuleID: 2, Time: day.Add(time.Hour), Rule: "label:x -> read", Err: "no label x"},
+		{RuleID: 1, Time: day.Add(24 * time.Hour), Rule: "older:90d -> archive", Messages: 3},
+	}
+	for _, r := range runs {
+		if err := s.LogRuleRun(ctx, r); err != nil {
+			t.Fatalf("LogRuleRun: %v", err)
+		}
+	}
+	got, err := s.LoadRuleRuns(ctx, 2)
+	if err != nil || len(got) != 2 || got[0].Messages != 3 || !got[0].Time.Equal(runs[2].Time) || got[1].Err != "no label x" {
+		t.Fatalf("LoadRuleRuns = %+v, %v", got, err)
+	}
+	last, err := s.LastRuleRuns(ctx)
+	if err != nil || len(last) != 2 || last[1].Messages != 3 || last[2].Err == "" {
+		t.Fatalf("LastRuleRuns = %+v, %v", last, err)
+	}
+}
+
+func TestActions(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	actions := []model.Action{
+		{Time: day.AddDate(0, 0, -40), Kind: "trash", Sender: "old@b.com", Messages: 3},
+		{Time: day, Kind: "archive", Sender: "a@b.com", Subject: "news", Messages: 12},
+		{Time: day, Kind: "unsubscribe", Sender: "a@b.com"},
+	}
+	for _, a := range actions {
+		if err := s.RecordAction(ctx, a); err != nil {
+			t.Fatalf("RecordAction: %v", err)
+		}
+	}
+	got, err := s.LoadActions(ctx, day.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("LoadActions: %v", err)
+	}
+	if len(got) != 2 || got[0].Kind != "archive" || got[0].Messages != 12 || got[0].Subject != "news" ||
+		!got[0].Time.Equal(day) || got[1].Kind != "unsubscribe" {
+		t.Fatalf("LoadActions = %+v", got)
+	}
+}
+
+func TestLoadGroupSummaryPage(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	msgs := []model.MessageRef{
+		{ID: "1", From: "a@b.com", Subject: "news", LabelIDs: []string{"UNREAD"}},
+		{ID: "2", From: "a@b.com", Subject: "news"},
+		{ID: "3", From: "a@b.com", Subject: "news"},
+		{ID: "4", From: "c@d.com", Subject: "hi", LabelIDs: []string{"UNREAD"}},
+		{ID: "5", From: "c@d.com", Subject: "hi"},
+		{ID: "6", From: "b@b.com", Subject: "hi"},
+		{ID: "7", From: "e@f.com", Subject: "pinned"},
+		{ID: "8", From: "", Subject: "no sender"},
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatalf("UpsertMessages: %v", err)
+	}
+	if err := s.SetGroupPinned(ctx, model.GroupKey{Email: "e@f.com", Subject: "pinned"}, true); err != nil {
+		t.Fatalf("SetGroupPinned: %v", err)
+	}
+
+	var got []string
+	for offset := 0; ; offset += 2 {
+		page, err := s.LoadGroupSummaryPage(ctx, offset, 2)
+		if err != nil {
+			t.Fatalf("LoadGroupSummaryPage(%d): %v", offset, err)
+		}
+		for _, g := range page {
+			got = append(got, fmt.Sprintf("%s %d %v", g.Email, g.Count, g.Pinned))
+		}
+		if len(page) < 2 {
+			break
+		}
+	}
+	want := []string{"e@f.com 1 true", "a@b.com 3 false", "c@d.com 2 false", "b@b.com 1 false"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("pages = %v; want %v", got, want)
+	}
+
+	totals, err := s.GroupTotals(ctx)
+	if err != nil || totals != (model.GroupTotals{Groups: 4, Messages: 7, Unread: 2}) {
+		t.Fatalf("GroupTotals = %+v, %v", totals, err)
+	}
+}
+
+func TestLabelIDs(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	msgs := []model.MessageRef{
+		{ID: "1", From: "a@b.com", Subject: "s", LabelIDs: []string{"INBOX", "UNREAD", "CATEGORY_PROMOTIONS"}},
+		{ID: "2", From: "a@b.com", Subject: "s", LabelIDs: []string{"INBOX", "STARRED"}},
+		{ID: "3", From: "a@b.com", Subject: "s"},
+	}
+	if err := s.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatalf("UpsertMessages: %v", err)
+	}
+	got, err := s.GetMessagesByIDs(ctx, []string{"1"})
+	if err != nil || len(got) != 1 || !got[0].Unread() || !got[0].HasLabel("CATEGORY_PROMOTIONS") {
+		t.Fatalf("GetMessagesByIDs = %+v, %v", got, err)
+	}
+
+	if err := s.UpdateLabels(ctx, map[string][]string{"1": {"INBOX"}, "2": {"INBOX", "UNREAD"}, "missing": {"UNREAD"}}); err != nil {
+		t.Fatalf("UpdateLabels: %v", err)
+	}
+	sums, err := s.LoadGroupSummaries(ctx)
+	if err != nil || len(sums) != 1 || sums[0].Unread != 1 {
+		t.Fatalf("LoadGroupSummaries = %+v, %v", sums, err)
+	}
+	if n, _ := s.CountMessages(ctx); n != 3 {
+		t.Fatalf("UpdateLabels must not insert, count = %d", n)
+	}
+}
+
+func TestMigrateAddsLabelIDs(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = old.Exec(`
+		CREATE TABLE messages (id TEXT PRIMARY KEY, from_email TEXT NOT NULL, subject TEXT NOT NULL DEFAULT '',
+			date_rfc3339 TEXT NOT NULL DEFAULT '', list_unsubscribe TEXT NOT NULL DEFAULT '',
+			list_unsubscribe_post TEXT NOT NULL DEFAULT '');
+		CREATE TABLE metadata (key TEXT PRIMARY KEY, value TEXT NOT NULL DEFAULT '');
+		INSERT INTO messages (id, from_email) VALUES ('1', 'a@b.com');
+		INSERT INTO metadata VALUES ('last_history_id', '42'), ('sync_label', 'ALL');`)
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if n, _ := s.CountMessages(ctx); n != 1 {
+		t.Fatalf("expected cache to be kept, count = %d", n)
+	}
+	if hid, _ := s.GetLastHistoryID(ctx); hid != "42" {
+		t.Fatalf("expected history cursor to be kept, got %q", hid)
+	}
+	if label, _ := s.GetMetadata(ctx, "sync_label"); label != "ALL" {
+		t.Fatalf("sync label = %q", label)
+	}
+	if v, _ := s.GetMetadata(ctx, "refresh_metadata"); v != "*" {
+		t.Fatalf("refresh_metadata = %q; want the labels refreshed on the next sync", v)
+	}
+}
+
+func TestMigrateSetsVersion(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "v.db")
+	for i := 0; i < 2; i++ { // reopening must be a no-op
+		s, err := NewSQLiteStore(dbPath)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		var v int
+		s.db.QueryRow("PRAGMA user_version").Scan(&v)
+		s.Close()
+		if v != len(migrations) {
+			t.Fatalf("user_version = %d, want %d", v, len(migrations))
+		}
+	}
+
+	db, _ := sql.Open("sqlite", dbPath)
+	db.Exec("PRAGMA user_version = 999")
+	db.Close()
+	if s, err := NewSQLiteStore(dbPath); err == nil {
+		s.Close()
+		t.Fatal("expected error opening a database from a newer build")
+	}
+}
+
+func TestBodyCache(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	if _, ok, err := s.GetBody(ctx, "1"); ok || err != nil {
+		t.Fatalf("empty cache: ok=%v err=%v", ok, err)
+	}
+	s.PutBody(ctx, "1", model.MessageBody{Text: "aaaa"}, 10)
+	s.PutBody(ctx, "2", model.MessageBody{Text: "bbbb"}, 10)
+	// Reading 1 makes 2 the least recently read.
+	if body, ok, _ := s.GetBody(ctx, "1"); !ok || body.Text != "aaaa" {
+		t.Fatalf("GetBody(1) = %q, %v", body.Text, ok)
+	}
+	s.PutBody(ctx, "3", model.MessageBody{Text: "cccc"}, 10)
+	if _, ok, _ := s.GetBody(ctx, "2"); ok {
+		t.Fatal("expected 2 to be evicted")
+	}
+	for _, id := range []string{"1", "3"} {
+		if _, ok, _ := s.GetBody(ctx, id); !ok {
+			t.Fatalf("expected %s to be cached", id)
+		}
+	}
+	s.PutBody(ctx, "big", model.MessageBody{Text: "0123456789abc"}, 10)
+	if _, ok, _ := s.GetBody(ctx, "big"); ok {
+		t.Fatal("body over the cap must not be cached")
+	}
+}
+
+func TestBodyCacheAttachments(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	in := model.MessageBody{Text: "hi", Attachments: []model.Attachment{
+		{PartID: "1", Filename: "a.pdf", MimeType: "application/pdf", Size: 1200, AttachmentID: "att-1"},
+	}}
+	if err := s.PutBody(ctx, "m", in, 1<<20); err != nil {
+		t.Fatalf("PutBody: %v", err)
+	}
+	out, ok, err := s.GetBody(ctx, "m")
+	if err != nil || !ok || out.Text != "hi" || len(out.Attachments) != 1 || out.Attachments[0] != in.Attachments[0] {
+		t.Fatalf("GetBody = %+v, %v, %v", out, ok, err)
+	}
+}
+
+func TestBodyCacheInvites(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	start := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	in := model.MessageBody{Text: "hi", Invites: []ics.Event{{
+		UID: "ev-1", Summary: "Standup", Start: start, End: start.Add(30 * time.Minute),
+		RSVP: []ics.Link{{Label: "Yes", URL: "https://example.com/yes"}},
+	}}}
+	if err := s.PutBody(ctx, "m", in, 1<<20); err != nil {
+		t.Fatalf("PutBody: %v", err)
+	}
+	out, ok, err := s.GetBody(ctx, "m")
+	if err != nil || !ok || len(out.Invites) != 1 {
+		t.Fatalf("GetBody = %+v, %v, %v", out, ok, err)
+	}
+	got := out.Invites[0]
+	if got.UID != "ev-1" || got.Summary != "Standup" || !got.Start.Equal(start) || len(got.RSVP) != 1 || got.RSVP[0].URL != "https://example.com/yes" {
+		t.Fatalf("invite = %+v", got)
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	var msgs []model.MessageRef
+	for i := range 2000 {
+		msgs = append(msgs, model.MessageRef{ID: fmt.Sprint(i), From: "a@b.com", Subject: strings.Repeat("x", 100)})
+	}
+	s.UpsertMessages(ctx, msgs)
+	s.ClearMessages(ctx)
+	m, err := s.Maintain(ctx)
+	if err != nil {
+		t.Fatalf("Maintain: %v (problems %v)", err, m.Problems)
+	}
+	if m.SizeAfter == 0 || m.SizeAfter >= m.SizeBefore {
+		t.Errorf("size %d -> %d; want it to shrink", m.SizeBefore, m.SizeAfter)
+	}
+	if err := s.SetMetadata(ctx, "k", "v"); err != nil {
+		t.Errorf("store unusable after Maintain: %v", err)
+	}
+}
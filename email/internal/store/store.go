@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+)
+
+// Store is what every backend offers: the message cache and the optional
+// stores the gmail package looks for. Only SQLiteStore adds the low-memory
+// aggregation (gmail.GroupPageStore).
+type Store interface {
+	gmail.MessageStore
+	gmail.LabelStore
+	gmail.BodyStore
+	gmail.PinStore
+	gmail.ActivityStore
+	gmail.SenderListStore
+	gmail.RuleStore
+	gmail.UnsubscribeStore
+	gmail.SnoozeStore
+	gmail.TrashStore
+	gmail.ContactStore
+	Close() error
+}
+
+// Maintenance is what Maintainer.Maintain found and did.
+type Maintenance struct {
+	// SizeBefore and SizeAfter are the bytes on disk, write-ahead log
+	// included.
+	SizeBefore, SizeAfter int64
+	// Problems lists what the integrity check found; it is empty for a
+	// sound file.
+	Problems []string
+}
+
+// Maintainer is implemented by stores whose file needs occasional upkeep:
+// an integrity check, then compacting it and refreshing its statistics. A
+// file that fails the check is left as it is and Maintain returns an error.
+type Maintainer interface {
+	Maintain(ctx context.Context) (Maintenance, error)
+}
+
+// fileSizes returns the summed size of the paths that exist.
+func fileSizes(paths ...string) int64 {
+	var n int64
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil {
+			n += fi.Size()
+		}
+	}
+	return n
+}
+
+// Backends Open accepts.
+const (
+	BackendSQLite = "sqlite"
+	BackendBolt   = "bolt"
+	BackendMemory = "memory"
+)
+
+// ParseBackend checks a backend name from config; "" means SQLite.
+func ParseBackend(s string) (string, error) {
+	switch b := strings.ToLower(s); b {
+	case "":
+		return BackendSQLite, nil
+	case BackendSQLite, BackendBolt, BackendMemory:
+		return b, nil
+	}
+	return "", fmt.Errorf("unknown store %q (want sqlite, bolt or memory)", s)
+}
+
+// Open opens the store of the named backend at path; "" means SQLite and
+// the memory backend ignores path. A passphrase encrypts the bolt store;
+// SQLite cannot be encrypted and the memory store has nothing at rest.
+func Open(backend, path, passphrase string) (Store, error) {
+	b, err := ParseBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b == BackendBolt && passphrase != "":
+		return NewEncryptedBoltStore(path, passphrase)
+	case b == BackendSQLite && passphrase != "":
+		return nil, errors.New("only the bolt store can be encrypted")
+	}
+	switch b {
+	case BackendBolt:
+		return NewBoltStore(path)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	}
+	return NewSQLiteStore(path)
+}
+
+var (
+	_ gmail.SizedStore          = (*SQLiteStore)(nil)
+	_ gmail.OldestMessagesStore = (*SQLiteStore)(nil)
+	_ gmail.SizedStore          = (*BoltStore)(nil)
+	_ Maintainer                = (*SQLiteStore)(nil)
+	_ Maintainer                = (*BoltStore)(nil)
+
+	_ Store = (*SQLiteStore)(nil)
+	_ Store = (*BoltStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// seenContact updates c, the contact for m's sender, with m.
+func seenContact(c model.Contact, m model.MessageRef) model.Contact {
+	c.Email = m.From
+	if m.FromName != "" && (c.Name == "" || m.DateRFC3339 >= c.LastSeen) {
+		c.Name = m.FromName
+	}
+	c.LastSeen = max(c.LastSeen, m.DateRFC3339)
+	return c
+}
+
+// sortTrashed orders trashed messages most recently trashed first, then
+// newest first, as SQLiteStore.LoadTrashed does.
+func sortTrashed(ts []model.Trashed) {
+	sort.Slice(ts, func(i, j int) bool {
+		switch {
+		case !ts[i].At.Equal(ts[j].At):
+			return ts[i].At.After(ts[j].At)
+		case ts[i].Date != ts[j].Date:
+			return ts[i].Date > ts[j].Date
+		}
+		return ts[i].ID < ts[j].ID
+	})
+}
+
+// sortContacts orders contacts most recently seen first.
+func sortContacts(cs []model.Contact) {
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].LastSeen != cs[j].LastSeen {
+			return cs[i].LastSeen > cs[j].LastSeen
+		}
+		return cs[i].Email < cs[j].Email
+	})
+}
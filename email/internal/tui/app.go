@@ -2,57 +2,315 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"chuckterm/internal/gmail"
+	"chuckterm/internal/ics"
+	"chuckterm/internal/mailbox"
 	"chuckterm/internal/model"
+	"chuckterm/internal/push"
+	"chuckterm/internal/report"
+	"chuckterm/internal/util"
+	"common/notify"
+	"common/ui"
+	"common/usage"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	gmailv1 "google.golang.org/api/gmail/v1"
 )
 
 type viewState int
 
 const (
-	viewLoading  viewState = iota
-	viewAuth               // waiting for auth code input
-	viewGroups             // main groups list
-	viewMessages           // messages within a group
-	viewBody               // single message body
+	viewLoading     viewState = iota
+	viewAuth                  // waiting for auth code input
+	viewGroups                // main groups list
+	viewMessages              // messages within a group
+	viewBody                  // single message body
+	viewUnsubscribe           // unsubscribe queue status table
+	viewStats                 // mailbox and activity statistics
+	viewRules                 // automatic rules run after each sync
+	viewDrafts                // Gmail drafts
+	viewCompose               // writing a message or editing a draft
+	viewSetup                 // first run: asking for the OAuth client credentials
+	viewAccounts              // the configured accounts, to switch between
+	viewTrash                 // trashed mail, to restore
+	viewProfile               // every subject group of one sender
 )
 
+// Options tunes AppModel behaviour; the zero value is the default mode.
+type Options struct {
+	// LowMemory keeps only per-group aggregates in RAM: groups are built in
+	// SQL and paged in as the list is scrolled, message IDs are streamed from
+	// the store when an action needs them, and the messages list pages a
+	// group's messages in groupMessagePage at a time.
+	LowMemory bool
+	// AutoLabels are applied to newly arrived messages during incremental sync.
+	AutoLabels []gmail.AutoLabelRule
+	// Label is the Gmail label (name or ID) to sync; "" means INBOX and
+	// "ALL" means all mail. Changing it rebuilds the cache.
+	Label string
+	// Query narrows the sync to a Gmail search within Label; see
+	// gmail.SyncOptions.Query. Changing it rebuilds the cache too.
+	Query string
+	// Window limits a fresh full scan to recent mail; see
+	// gmail.SyncOptions.Window.
+	Window string
+	// Push, when enabled, registers a Gmail watch after the first sync and
+	// runs an incremental sync as soon as a notification arrives.
+	Push push.Config
+	// BodyCacheBytes caps the message bodies cached for offline reading;
+	// 0 disables the cache.
+	BodyCacheBytes int64
+	// BodyMemory is how many recently opened bodies are also kept in
+	// memory, so moving between the messages of a group redraws them
+	// without a fetch; 0 disables it.
+	BodyMemory int
+	// Notifier, if set, announces unread mail found by incremental syncs.
+	Notifier notify.Notifier
+	// CalendarFile is the .ics file invitations are added to from the
+	// body view; "" disables adding.
+	CalendarFile string
+	// Signatures are added to new messages, by account address; "default"
+	// covers accounts not listed, and "" turns the signature off. An
+	// account without one uses the signature in its Gmail settings.
+	Signatures map[string]string
+	// Workers caps concurrent metadata requests during sync, and
+	// ListPageSize and UpsertBatch size its list requests and cache writes;
+	// 0 uses the gmail package defaults (see gmail.SyncOptions).
+	Workers      int
+	ListPageSize int
+	UpsertBatch  int
+	// Retention bounds the cache, applied after each sync.
+	Retention gmail.Retention
+	// Sort orders the groups list; "" means by message count.
+	Sort gmail.GroupOrder
+	// Subjects decides which subjects share a group; "" compares them
+	// exactly.
+	Subjects gmail.SubjectGrouping
+	// Confirm selects the actions that ask before running.
+	Confirm Confirmations
+	// Usage, if set, counts which key bindings are used.
+	Usage *usage.Counter
+	// Keys remaps the groups-view actions; check it with Keymap.Validate.
+	Keys Keymap
+	// Service, if set, is used instead of authenticating; demo mode points
+	// it at a local fake of the Gmail API.
+	Service *gmailv1.Service
+	// Mailbox, if set, is an account of another provider, such as IMAP,
+	// triaged instead of Gmail. Gmail's own features (rules, snoozing,
+	// push, export and mailto: unsubscribes) are then unavailable.
+	Mailbox mailbox.Provider
+	// Demo marks the session as a demo: the title says so and links to the
+	// real Gmail web UI are disabled.
+	Demo bool
+	// DownloadDir is where attachments are saved; "" means the user's
+	// downloads directory.
+	DownloadDir string
+	// PageSize, if positive, loads the groups from the store this many at a
+	// time while they are listed by count, fetching more as the list is
+	// scrolled; 0 loads them all at once.
+	PageSize int
+	// Preview starts with the body of the highlighted message shown beside
+	// the messages list; v toggles it.
+	Preview bool
+	// DryRun stops archives, trashes, label changes, unsubscribes and
+	// snoozes from touching Gmail: each is described to Audit instead and
+	// the status bar says what would have happened.
+	DryRun bool
+	// Audit, if set, receives a line for each action a dry run skipped.
+	Audit func(line string)
+	// ReadOnly refuses the keys of the actions that change mail, for
+	// exploring a mailbox safely; see gmail.WithReadOnly.
+	ReadOnly bool
+	// Spam reviews the spam folder, with Label set to gmail.SpamLabel: the
+	// archive key moves mail back to the inbox, the trash key deletes it
+	// for good after a prompt, and unsubscribing is refused.
+	Spam bool
+	// Accounts, if set, lists the configured accounts with their cached
+	// counts for the accounts view, whose enter quits with SwitchAccount
+	// set. Nil without other accounts.
+	Accounts func(ctx context.Context) ([]Account, error)
+}
+
+// Confirmations lists the actions that show a yes/no prompt first.
+type Confirmations struct {
+	Archive         bool
+	Trash           bool
+	BulkUnsubscribe bool
+}
+
+// groupMessagePage is how many messages of a group without MessageIDs, in
+// low-memory mode or paged in, the messages list loads at a time.
+const groupMessagePage = 1000
+
 type AppModel struct {
 	// Core state
 	service   *gmailv1.Service
+	mailbox   mailbox.Provider // service's provider, or Options.Mailbox
 	store     gmail.MessageStore
 	configDir string
+	opts      Options
 	Err       error
-	status    string
+	// SwitchAccount names the account chosen in the accounts view when the
+	// program quit to open it; see Options.Accounts.
+	SwitchAccount string
+
+	// Status line: progress text, with toasts shown over it
+	statusBar ui.StatusBar
+	toasts    ui.Toasts
+	confirm   ui.Confirm
+	showHelp  bool // key reference overlay, closed by any key
 
 	// Auth flow
 	uiEvents      chan interface{}
 	userResponses chan string
 	textInput     textinput.Model
 	authURL       string
+	deviceCode    *gmail.DeviceCode // set during the device flow
+	setupInput    textinput.Model   // credentials path or JSON on first run
+	setupErr      string
+	// A sign-in that expired mid-session is renewed over authReturn, the
+	// view it interrupted; reauthRetries run again afterwards.
+	reauthing     bool
+	reauthRetries []tea.Cmd
+	authReturn    viewState
 
 	// View state machine
 	view          viewState
 	groups        []model.SenderGroup
+	grouping      gmail.GroupMode // what the groups gather: subjects, senders or domains
+	groupsOffset  int             // store rows m.groups was paged in from (see paging.go)
+	unloaded      model.GroupTotals
+	loadingMore   bool
 	selectedGroup *model.SenderGroup
 	selectedMsg   *model.MessageRef
+	senders       gmail.SenderLists               // protected and blocked senders
+	unsubs        map[string]model.Unsubscription // senders unsubscribed from, by address
+
+	// Where the last run quit, until restoreSession reopens it once the
+	// groups show; bodyOffset is the scroll position of its open message.
+	session    *gmail.Session
+	bodyOffset int
+
+	// Visual selection of the vim profile, from visualAnchor to the
+	// highlighted group; pendingG is the first g of gg.
+	visual       bool
+	visualAnchor int
+	pendingG     bool
 
 	// Sub-models
 	groupsList   list.Model
 	messagesList list.Model
 	bodyViewport viewport.Model
 
+	// Date filter over m.groups, and the prompt that edits it
+	dateFilter gmail.DateFilter
+	dateInput  textinput.Model
+	// bulkOnly lists only the groups that look like bulk mail.
+	bulkOnly bool
+
+	// Search over the open group's messages; groupMsgs is the unfiltered list
+	groupMsgs    []model.MessageRef
+	msgsGroup    model.SenderGroup // the group groupMsgs belongs to
+	msgsMore     bool              // the store holds more of its messages (see paging.go)
+	loadingMsgs  bool
+	groupHeading string // sender and subject of the open group
+	groupTitle   string // messages list title without a search
+	searchInput  textinput.Model
+
+	// Snooze prompt for the highlighted message
+	snoozeInput textinput.Model
+	snoozing    model.MessageRef
+
+	// Gmail filter prompt for the highlighted group's sender
+	filterInput   textinput.Model
+	filterFor     model.SenderGroup
+	searchApplied bool // a search narrows the list while the prompt is closed
+
+	// Group detail panel (age histogram of the highlighted group)
+	showDetail    bool
+	detailKey     string
+	detailBuckets ageBuckets
+
+	// Open message
+	bodies        *util.LRU[string, model.MessageBody] // recent bodies by message ID
+	body          model.MessageBody
+	attachmentIdx int      // highlighted attachment in the body view
+	links         []string // links of the open message, numbered from 1
+	linkInput     textinput.Model
+	savedPath     string // attachment downloaded last, offered for opening
+
+	// Unsubscribe queue
+	unsubRunning   bool
+	unsubQueue     []queuedUnsub // senders queued with Q or U, in order
+	reportViewport viewport.Model
+	pendingUnsub   model.SenderGroup // group u asked about before unsubscribing
+
+	statsViewport viewport.Model
+	stats         *report.Stats // last computed, redrawn on resize
+
+	// Rules view, and the prompt that adds or edits a rule
+	rulesList  list.Model
+	ruleInput  textinput.Model
+	ruleEditID int64 // rule the prompt edits; 0 adds one
+
+	// Drafts view, and the compose form over the view it was opened from
+	draftsList    list.Model
+	composer      composeForm
+	composeReturn viewState
+	gmailSig      *string // signature from Gmail's settings, once loaded
+
+	// Accounts view, listing Options.Accounts
+	accountsList list.Model
+
+	// Trash view: the trash log, or Gmail's trash when trashLive
+	trashList list.Model
+	trashLive bool
+
+	// Sender profile, and the view esc in the messages list returns to
+	profile        gmail.SenderProfile
+	profileList    list.Model
+	messagesReturn viewState
+
+	// Push notifications
+	pushStarted bool
+	pushSyncing bool
+	pushPending bool // a notification arrived while pushSyncing or scanning
+
+	// Status bar: the account and the state of the cache
+	account string
+	syncing bool // a startup or manual sync is running
+	// syncCancel stops the sync syncCmd started, until it finishes.
+	syncCancel context.CancelFunc
+	syncErr    error
+	lastSync   time.Time
+	meter      syncMeter // progress of the running sync
+
+	// Full scan running in the background; its groups are reloaded as the
+	// cache fills up.
+	scanning    bool
+	scanLoading bool      // a reload of the groups is under way
+	scanShown   time.Time // when the groups were last reloaded
+
 	// Layout
 	width, height int
+	layout        layoutMode
+	previewKey    string // group shown in the messages pane (wide layout)
+	bodyShown     bool   // bodyViewport holds a fetched message
+	bodyMsgID     string // the message bodyViewport shows
+	preview       bool   // the body pane follows the highlighted message
+	previewMsgID  string // message the preview last asked for
 
 	// Program reference for sending messages from goroutines
 	program *tea.Program
@@ -66,62 +324,185 @@ func (m *AppModel) SetProgram(p *tea.Program) {
 
 type authResultMsg struct {
 	service *gmailv1.Service
+	mailbox mailbox.Provider // set instead of service for other providers
 	err     error
 }
 
 type authURLMsg string
 
+// deviceCodeMsg starts the device flow's wait for approval.
+type deviceCodeMsg gmail.DeviceCode
+
 type syncProgressMsg struct {
 	phase string
 	done  int
 	total int
 }
 
-func NewAppModel(store gmail.MessageStore, configDir string) AppModel {
+func NewAppModel(store gmail.MessageStore, configDir string, opts Options) AppModel {
 	ti := textinput.New()
 	ti.Placeholder = "Paste auth code here"
 	ti.Focus()
+	ci := textinput.New()
+	ci.Prompt = "> "
+	ci.Placeholder = "~/Downloads/client_secret_....json"
 
-	gl := list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0)
+	gl := list.New([]list.Item{}, ui.NewDelegate(false), 0, 0)
 	// Remove esc from the list's built-in Quit binding so it doesn't exit on home
 	gl.KeyMap.Quit.SetKeys("q")
+	opts.Keys = opts.Keys.withDefaults()
+	ml := list.New([]list.Item{}, ui.NewDelegate(false), 0, 0)
+	di := textinput.New()
+	di.Prompt = "Dates: "
+	di.Placeholder = "older than 6 months, last 30 days or 2024-01-01..2024-06-30; empty shows all"
+	si := textinput.New()
+	si.Prompt = "Search: "
+	si.Placeholder = "words in the subject, snippet or date"
+	zi := textinput.New()
+	zi.Prompt = "Snooze until: "
+	zi.Placeholder = "2h, 3d, tomorrow, mon, next week or 2025-06-01 09:00"
+	li := textinput.New()
+	li.Prompt = "Open link: "
+	li.Placeholder = "number from the Links list"
+	fi := textinput.New()
+	fi.Prompt = "Future mail from the sender: "
+	fi.Placeholder = "archive (skip the inbox), trash or label NAME"
+	rl := list.New([]list.Item{}, ui.NewDelegate(false), 0, 0)
+	ri := textinput.New()
+	ri.Prompt = "Rule: "
+	ri.Placeholder = "from:@shop.example older:30d -> archive (also subject:, label:, has:unsubscribe, every:@daily; trash, read, label NAME)"
+	dl := list.New([]list.Item{}, ui.NewDelegate(false), 0, 0)
+	al := list.New([]list.Item{}, ui.NewDelegate(false), 0, 0)
+	tl := list.New([]list.Item{}, ui.NewDelegate(false), 0, 0)
+	pl := list.New([]list.Item{}, ui.NewDelegate(false), 0, 0)
+	// ? opens chuckterm's own help overlay instead of the list's.
+	for _, l := range []*list.Model{&gl, &ml, &rl, &dl, &al, &tl, &pl} {
+		l.KeyMap.ShowFullHelp.SetHelp("?", "help")
+	}
 
 	return AppModel{
-		store:        store,
-		configDir:    configDir,
-		status:       "Authenticating...",
-		view:         viewLoading,
-		uiEvents:     make(chan interface{}),
-		userResponses: make(chan string),
-		textInput:    ti,
-		groupsList:   gl,
-		messagesList: list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0),
-		bodyViewport: viewport.New(0, 0),
+		store:          store,
+		configDir:      configDir,
+		opts:           opts,
+		statusBar:      ui.StatusBar{Text: "Authenticating..."},
+		view:           viewLoading,
+		uiEvents:       make(chan interface{}),
+		userResponses:  make(chan string),
+		textInput:      ti,
+		setupInput:     ci,
+		groupsList:     gl,
+		messagesList:   ml,
+		dateInput:      di,
+		searchInput:    si,
+		snoozeInput:    zi,
+		linkInput:      li,
+		filterInput:    fi,
+		rulesList:      rl,
+		ruleInput:      ri,
+		draftsList:     dl,
+		accountsList:   al,
+		trashList:      tl,
+		profileList:    pl,
+		composer:       newComposeForm(),
+		bodyViewport:   viewport.New(0, 0),
+		reportViewport: viewport.New(0, 0),
+		statsViewport:  viewport.New(0, 0),
+		preview:        opts.Preview,
+		bodies:         util.NewLRU[string, model.MessageBody](opts.BodyMemory),
+		meter:          newSyncMeter(),
+	}
+}
+
+// resize picks the layout for the current terminal size and sizes the
+// sub-models to fit it.
+func (m *AppModel) resize() {
+	layout := layoutFor(m.width, m.height)
+	if layout != m.layout {
+		m.layout = layout
+		m.groupsList.SetDelegate(m.groupsDelegate())
+		m.messagesList.SetDelegate(m.messagesDelegate())
+	}
+
+	contentH := m.height - m.chromeHeight()
+	groupsW, messagesW, bodyW := m.width, m.width, m.width
+	groupsH, messagesH := contentH, contentH
+	switch {
+	case m.layout == layoutWide:
+		groupsW, messagesW, bodyW = paneWidths(m.width)
+		// Pane borders take a column and a row on each side.
+		groupsW, messagesW, bodyW = groupsW-2, messagesW-2, bodyW-2
+		contentH -= 2
+		groupsH, messagesH = contentH, contentH
+	case m.split():
+		messagesW, bodyW = m.width/2-2, m.width-m.width/2-2
+		contentH -= 2
+		messagesH = contentH
 	}
+	if m.detailVisible() {
+		groupsH -= detailPanelHeight
+	}
+	m.groupsList.SetSize(groupsW, max(groupsH, 3))
+	m.messagesList.SetSize(messagesW, max(messagesH, 3))
+	m.bodyViewport.Width = bodyW
+	m.bodyViewport.Height = max(contentH, 1)
+	m.reportViewport.Width = m.width
+	m.reportViewport.Height = max(m.height-m.chromeHeight(), 1)
+	m.statsViewport.Width = m.width
+	m.statsViewport.Height = m.reportViewport.Height
+	m.rulesList.SetSize(m.width, max(m.reportViewport.Height, 3))
+	m.draftsList.SetSize(m.width, max(m.reportViewport.Height, 3))
+	m.accountsList.SetSize(m.width, max(m.reportViewport.Height, 3))
+	m.trashList.SetSize(m.width, max(m.reportViewport.Height, 3))
+	m.profileList.SetSize(m.width, max(m.reportViewport.Height-profileHeaderHeight, 3))
+	m.composer.setSize(m.width, m.reportViewport.Height)
+	if m.stats != nil {
+		m.statsViewport.SetContent(renderStats(*m.stats, gmail.Usage(), m.width))
+	}
+	if m.layout == layoutWide {
+		m.refreshPreview()
+	}
+}
+
+// detailVisible reports whether the group detail panel is drawn; compact
+// terminals have no room for it.
+func (m *AppModel) detailVisible() bool {
+	return m.showDetail && m.layout != layoutCompact
 }
 
 func (m *AppModel) Init() tea.Cmd {
-	return tea.Batch(m.authenticateCmd(), textinput.Blink)
+	m.loadSession()
+	if p := m.opts.Mailbox; p != nil {
+		return tea.Batch(func() tea.Msg { return authResultMsg{mailbox: p} }, statusTick())
+	}
+	if svc := m.opts.Service; svc != nil {
+		return tea.Batch(func() tea.Msg { return authResultMsg{service: svc} }, statusTick())
+	}
+	return tea.Batch(m.authenticateCmd(), textinput.Blink, statusTick())
 }
 
 func (m *AppModel) authenticateCmd() tea.Cmd {
 	return func() tea.Msg {
 		go func() {
-			svc, err := gmail.NewServiceInteractive(context.Background(), m.configDir, m.uiEvents, m.userResponses)
+			svc, err := gmail.NewServiceInteractive(m.rootContext(), m.configDir, m.uiEvents, m.userResponses)
 			m.uiEvents <- authResultMsg{service: svc, err: err}
 		}()
 
 		// The gmail auth flow sends a raw string (the auth URL) first,
 		// then the goroutine above sends authResultMsg when done.
-		// Convert the string to our named type so Update can match it.
-		event := <-m.uiEvents
-		switch v := event.(type) {
-		case string:
-			return authURLMsg(v)
-		default:
-			return event
-		}
+		return authEvent(<-m.uiEvents)
+	}
+}
+
+// authEvent converts what the gmail auth flow sends on uiEvents to the
+// named types Update matches.
+func authEvent(event interface{}) tea.Msg {
+	switch v := event.(type) {
+	case string:
+		return authURLMsg(v)
+	case gmail.DeviceCode:
+		return deviceCodeMsg(v)
 	}
+	return event
 }
 
 func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -129,78 +510,397 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		listH := msg.Height - 4 // room for footer
-		m.groupsList.SetSize(msg.Width, listH)
-		m.messagesList.SetSize(msg.Width, listH)
-		m.bodyViewport.Width = msg.Width
-		m.bodyViewport.Height = msg.Height - 6 // room for header + footer
-		return m, nil
+		m.resize()
+		return m, m.previewCmd()
 
 	case tea.KeyMsg:
 		return m.handleKey(msg)
 
 	case authResultMsg:
+		if errors.Is(msg.err, gmail.ErrNoCredentials) {
+			return m.startSetup()
+		}
 		if msg.err != nil {
 			m.Err = msg.err
-			m.status = "Authentication failed!"
+			m.statusBar.Text = "Authentication failed!"
 			return m, tea.Quit
 		}
-		m.service = msg.service
-		m.status = "Syncing..."
-		return m, m.syncCmd()
+		m.service, m.mailbox = msg.service, msg.mailbox
+		if m.service != nil {
+			m.mailbox = mailbox.Gmail(m.service)
+		}
+		m.statusBar.Text = "Syncing..."
+		m.syncing = true
+		return m, tea.Batch(m.syncCmd(), m.accountCmd())
+
+	case accountMsg:
+		m.account = string(msg)
+		return m, nil
+
+	case statusTickMsg:
+		return m, statusTick()
 
 	case authURLMsg:
 		m.authURL = string(msg)
 		m.view = viewAuth
-		return m, nil
+		// The result comes from the loopback redirect or a pasted code.
+		return m, func() tea.Msg { return <-m.uiEvents }
+
+	case deviceCodeMsg:
+		code := gmail.DeviceCode(msg)
+		m.deviceCode = &code
+		m.view = viewAuth
+		// Nothing is typed in the device flow; wait for the approval.
+		return m, func() tea.Msg { return <-m.uiEvents }
+
+	case reauthNeededMsg:
+		return m, m.reauthenticate(msg.retry)
+
+	case reauthResultMsg:
+		return m, m.reauthenticated(msg)
 
 	case syncProgressMsg:
-		if msg.total > 0 {
-			m.status = fmt.Sprintf("Syncing... %d / %d messages", msg.done, msg.total)
-		} else {
-			m.status = fmt.Sprintf("Syncing... %d messages", msg.done)
+		now := time.Now()
+		m.meter.update(msg, now)
+		if m.scanning && !m.scanLoading && now.Sub(m.scanShown) >= scanReloadEvery {
+			m.scanLoading, m.scanShown = true, now
+			return m, m.scanGroupsCmd()
 		}
 		return m, nil
 
+	case detailMsg:
+		if msg.key == m.detailKey {
+			m.detailBuckets = msg.buckets
+		}
+		return m, nil
+
+	case scanGroupsMsg:
+		m.scanLoading = false
+		if msg.err != nil || !m.scanning {
+			// The reload after the scan shows the rest, or the error.
+			return m, nil
+		}
+		return m, m.replaceGroups(msg.groups)
+
 	case syncCompleteMsg:
 		if msg.err != nil {
 			m.Err = msg.err
-			m.status = "Sync failed!"
+			m.statusBar.Text = "Sync failed!"
 			return m, tea.Quit
 		}
-		m.groups = msg.groups
-		m.groupsList.SetItems(groupsToItems(m.groups))
-		m.groupsList.Title = fmt.Sprintf("Inbox (%d groups)", len(m.groups))
+		m.setGroups(msg.groups)
+		m.showGroups()
+		m.detailKey = ""
+		detail := m.refreshDetail()
 		m.view = viewGroups
-		m.status = ""
+		m.previewKey = ""
+		if m.layout == layoutWide {
+			m.refreshPreview()
+		}
+		m.statusBar.Text = ""
+		m.meter.reset()
+		m.syncing = msg.background
+		m.scanning, m.scanShown = msg.scanning, time.Now()
+		m.syncErr = nil
+		m.lastSync = msg.lastSync
+		var rules tea.Cmd
+		if !msg.background {
+			m.endSync()
+			rules = m.rulesCmd(nil)
+		}
+		if msg.cancelled {
+			rules = m.toasts.Push("Sync cancelled; the next one picks up where it stopped")
+		}
+		restore := m.restoreSession()
+		if m.opts.Push.Enabled() && !m.pushStarted && m.store != nil && m.service != nil {
+			m.pushStarted = true
+			return m, tea.Batch(detail, rules, restore, m.pushCmd())
+		}
+		return m, tea.Batch(detail, rules, restore)
+
+	case regroupedMsg:
+		if msg.err != nil {
+			m.grouping = msg.previous
+			return m, m.toasts.Push(fmt.Sprintf("Regrouping failed: %v", msg.err))
+		}
+		m.setGroups(msg.groups)
+		m.showGroups()
+		m.groupsList.ResetSelected()
+		m.detailKey = ""
+		detail := m.refreshDetail()
+		m.previewKey = ""
+		if m.layout == layoutWide {
+			m.refreshPreview()
+		}
+		switch m.grouping {
+		case gmail.GroupBySender:
+			return m, tea.Batch(detail, m.toasts.Push("Grouped by sender"))
+		case gmail.GroupByDomain:
+			return m, tea.Batch(detail, m.toasts.Push("Grouped by sender domain"))
+		}
+		return m, tea.Batch(detail, m.toasts.Push("Grouped by sender and subject"))
+
+	case syncFinishedMsg:
+		if gmail.IsAuthError(msg.err) && m.store != nil {
+			// Pick the sync up again once signed in; a full scan resumes
+			// where it stopped.
+			m.pushSyncing, m.scanning = true, false
+			m.endSync()
+			return m, m.reauthenticate(m.pushSyncCmd())
+		}
+		m.syncing, m.scanning = false, false
+		m.endSync()
+		m.statusBar.Text = ""
+		m.meter.reset()
+		if errors.Is(msg.err, context.Canceled) {
+			// Show what the sync cached before it stopped.
+			return m, tea.Batch(m.refreshGroupsCmd(), m.toasts.Push("Sync cancelled; the next one picks up where it stopped"))
+		}
+		m.syncErr = msg.err
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Sync failed: %v", msg.err))
+		}
+		m.lastSync = time.Now()
+		if m.pushPending && !m.pushSyncing {
+			m.pushPending, m.pushSyncing = false, true
+			return m, tea.Batch(m.refreshGroupsCmd(), m.pushSyncCmd())
+		}
+		return m, m.refreshGroupsCmd()
+
+	case groupsRefreshedMsg:
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Reloading groups failed: %v", msg.err))
+		}
+		return m, tea.Batch(m.replaceGroups(msg.groups), m.rulesCmd(nil))
+
+	case rulesRanMsg:
+		return m, m.rulesRan(msg)
+
+	case snoozedMsg:
+		return m, m.snoozed(msg)
+
+	case trashLoadedMsg:
+		return m, m.trashLoaded(msg)
+
+	case profileLoadedMsg:
+		return m, m.profileLoaded(msg)
+
+	case untrashedMsg:
+		return m, m.untrashed(msg)
+
+	case filterCreatedMsg:
+		return m, m.filterCreated(msg)
+
+	case markedReadMsg:
+		return m, m.markedRead(msg)
+
+	case blockedMsg:
+		return m, m.blocked(msg)
+
+	case filtersRemovedMsg:
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Removing the Gmail filter failed: %v", msg.err))
+		}
+		if msg.removed > 0 {
+			return m, m.toasts.Push("Removed the Gmail filter deleting mail from " + msg.sender)
+		}
 		return m, nil
 
-	case actionResultMsg:
+	case moreGroupsMsg:
+		m.loadingMore = false
+		if msg.offset != m.groupsOffset || !m.moreGroups() {
+			// The groups were reloaded while this page was fetched.
+			return m, nil
+		}
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Loading more groups failed: %v", msg.err))
+		}
+		m.appendGroups(msg.groups)
+		m.showGroups()
+		return m, nil
+
+	case moreMessagesMsg:
+		m.loadingMsgs = false
+		if n := len(m.groupMsgs); n == 0 || msg.key != (model.GroupKey{Email: m.msgsGroup.Email, Subject: m.msgsGroup.Subject}) || m.groupMsgs[n-1].ID != msg.after || !m.msgsMore {
+			// Another group was opened while this page was fetched.
+			return m, nil
+		}
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Loading more messages failed: %v", msg.err))
+		}
+		m.appendMessages(msg.msgs)
+		return m, nil
+
+	case pushNotifyMsg:
+		if m.pushSyncing || m.scanning {
+			// Sync once the running sync or full scan is done.
+			m.pushPending = true
+			return m, nil
+		}
+		m.pushSyncing = true
+		return m, m.pushSyncCmd()
+
+	case pushSyncedMsg:
+		m.pushSyncing = false
+		m.syncErr = msg.err
+		var detail tea.Cmd
 		if msg.err != nil {
-			m.status = fmt.Sprintf("%s failed: %v", msg.action, msg.err)
+			m.statusBar.Text = fmt.Sprintf("Push sync failed: %v", msg.err)
 		} else {
-			m.status = fmt.Sprintf("%s complete", msg.action)
+			m.lastSync = time.Now()
+			detail = m.replaceGroups(msg.groups)
+		}
+		if m.pushPending {
+			m.pushPending = false
+			m.pushSyncing = true
+			return m, tea.Batch(detail, m.pushSyncCmd())
 		}
-		return m, clearStatusAfter(2 * time.Second)
+		if msg.err == nil {
+			return m, tea.Batch(detail, m.rulesCmd(nil))
+		}
+		return m, nil
 
-	case bodyFetchedMsg:
+	case pushStoppedMsg:
+		if msg.err != nil {
+			m.statusBar.Text = fmt.Sprintf("Push notifications stopped: %v", msg.err)
+		}
+		return m, nil
+
+	case unsubItemDoneMsg:
+		m.finishQueuedUnsub(msg)
+		return m, m.nextUnsubscribeCmd()
+
+	case statsLoadedMsg:
+		if msg.err != nil {
+			m.statsViewport.SetContent(fmt.Sprintf("Computing stats failed: %v", msg.err))
+			return m, nil
+		}
+		m.stats = &msg.stats
+		m.statsViewport.SetContent(renderStats(msg.stats, gmail.Usage(), m.width))
+		return m, nil
+
+	case actionResultMsg:
+		m.statusBar.Text = ""
+		if msg.dryRun != "" {
+			return m, m.toasts.Push(m.skipped(msg.dryRun))
+		}
+		if msg.unsubscribed {
+			m.refreshUnsubscribes()
+		}
 		if msg.err != nil {
-			m.status = fmt.Sprintf("Failed to load body: %v", msg.err)
+			return m, m.toasts.Push(fmt.Sprintf("%s failed: %v", msg.action, msg.err))
+		}
+		return m, m.toasts.Push(fmt.Sprintf("%s complete", msg.action))
+
+	case previewTickMsg:
+		if mi, ok := m.messagesList.SelectedItem().(messageItem); ok && mi.ID == msg.id && m.previewing() {
+			return m, m.previewBodyCmd(msg.id)
+		}
+		return m, nil
+
+	case bodyFetchedMsg:
+		if msg.preview {
+			m.showPreview(msg)
 			return m, nil
 		}
-		header := ""
-		if m.selectedMsg != nil {
-			header = bodyHeader(m.selectedMsg.From, m.selectedMsg.Subject, m.selectedMsg.DateRFC3339) + "\n\n"
+		if msg.err != nil {
+			m.statusBar.Text = fmt.Sprintf("Failed to load body: %v", msg.err)
+			return m, nil
 		}
-		m.bodyViewport.SetContent(header + msg.body)
+		m.body = msg.body
+		m.attachmentIdx = 0
+		m.renderBody()
 		m.bodyViewport.GotoTop()
+		if m.bodyOffset > 0 {
+			// Reopened from the last session where it was left.
+			m.bodyViewport.SetYOffset(m.bodyOffset)
+			m.bodyOffset = 0
+		}
+		m.bodyShown = true
+		m.bodyMsgID = msg.id
 		m.view = viewBody
-		m.status = ""
+		m.statusBar.Text = ""
+		return m, nil
+
+	case attachmentSavedMsg:
+		m.statusBar.Text = ""
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Download failed: %v", msg.err))
+		}
+		m.savedPath = msg.path
+		prompt := fmt.Sprintf("Saved %s. Open it?", msg.path)
+		if gmail.IsExecutable(msg.path, msg.mimeType) {
+			prompt = fmt.Sprintf("Saved %s. This is a program or installer: opening it runs it with your permissions. Only open it if you trust the sender. Open it anyway?", msg.path)
+		}
+		m.confirm.Ask(confirmOpenAttachment, prompt)
+		return m, nil
+
+	case exportedMsg:
+		m.statusBar.Text = ""
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Export failed: %v", msg.err))
+		}
+		return m, m.toasts.Push(fmt.Sprintf("Exported %s to %s", plural(msg.count, "message"), msg.path))
+
+	case draftsLoadedMsg:
+		return m, m.draftsLoaded(msg)
+
+	case accountsLoadedMsg:
+		return m, m.accountsLoaded(msg)
+
+	case draftSavedMsg:
+		return m, m.draftSaved(msg)
+
+	case draftSentMsg:
+		return m, m.draftSent(msg)
+
+	case draftDeletedMsg:
+		return m, m.draftDeleted(msg)
+
+	case signatureLoadedMsg:
+		m.gmailSig = &msg.text
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Loading the Gmail signature failed: %v", msg.err))
+		}
 		return m, nil
 
-	case statusMsg:
-		if string(msg) == "" {
-			m.status = ""
+	case inviteAddedMsg:
+		if msg.err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Adding invitation failed: %v", msg.err))
+		}
+		return m, m.toasts.Push(fmt.Sprintf("Added %q to %s", msg.summary, msg.path))
+
+	case ui.ToastExpiredMsg:
+		return m, m.toasts.Update(msg)
+
+	case ui.ConfirmMsg:
+		switch {
+		case msg.ID == confirmOpenAttachment:
+			return m, m.openSavedAttachment(msg.Yes)
+		case !msg.Yes:
+			return m, m.toasts.Push("Cancelled")
+		case msg.ID == confirmBulkUnsubscribe:
+			return m.startBulkUnsubscribe()
+		case msg.ID == confirmArchive:
+			return m.archiveSelectedGroup()
+		case msg.ID == confirmTrash:
+			return m.trashSelectedGroup()
+		case msg.ID == confirmTrashSelection:
+			return m.trashVisualSelection()
+		case msg.ID == confirmBlock:
+			return m.blockSelectedGroup()
+		case msg.ID == confirmUnsubscribe:
+			return m, m.unsubscribeCmd(m.pendingUnsub)
+		case msg.ID == confirmDeleteRule:
+			return m.deleteSelectedRule()
+		case msg.ID == confirmDeleteDraft:
+			return m.deleteSelectedDraft()
+		case msg.ID == confirmRestoreTrash:
+			return m.restoreListedTrash()
+		case msg.ID == confirmArchiveSender:
+			return m.actOnSender(false)
+		case msg.ID == confirmTrashSender:
+			return m.actOnSender(true)
 		}
 		return m, nil
 	}
@@ -210,10 +910,24 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch m.view {
 	case viewAuth:
 		m.textInput, cmd = m.textInput.Update(msg)
+	case viewSetup:
+		m.setupInput, cmd = m.setupInput.Update(msg)
 	case viewGroups:
 		m.groupsList, cmd = m.groupsList.Update(msg)
 	case viewMessages:
 		m.messagesList, cmd = m.messagesList.Update(msg)
+	case viewRules:
+		m.rulesList, cmd = m.rulesList.Update(msg)
+	case viewDrafts:
+		m.draftsList, cmd = m.draftsList.Update(msg)
+	case viewAccounts:
+		m.accountsList, cmd = m.accountsList.Update(msg)
+	case viewTrash:
+		m.trashList, cmd = m.trashList.Update(msg)
+	case viewProfile:
+		m.profileList, cmd = m.profileList.Update(msg)
+	case viewCompose:
+		cmd = m.composer.update(msg)
 	case viewBody:
 		m.bodyViewport, cmd = m.bodyViewport.Update(msg)
 	}
@@ -227,17 +941,77 @@ func (m *AppModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch key {
 	case "ctrl+c":
 		return m, tea.Quit
+	case "ctrl+x":
+		if m.syncCancel != nil {
+			return m.cancelSync()
+		}
+	}
+
+	if m.confirm.Active() {
+		return m, m.confirm.HandleKey(msg)
+	}
+	if m.dateInput.Focused() {
+		return m.handleDateInput(msg)
+	}
+	if m.searchInput.Focused() {
+		return m.handleSearchInput(msg)
+	}
+	if m.ruleInput.Focused() {
+		return m.handleRuleInput(msg)
+	}
+	if m.snoozeInput.Focused() {
+		return m.handleSnoozeInput(msg)
+	}
+	if m.filterInput.Focused() {
+		return m.handleFilterInput(msg)
+	}
+	if m.linkInput.Focused() {
+		return m.handleLinkInput(msg)
+	}
+	if m.view == viewCompose {
+		return m.handleComposeKey(msg)
+	}
+	if m.view == viewSetup {
+		return m.handleSetupKey(msg)
+	}
+	if m.showHelp {
+		m.showHelp = false
+		return m, nil
+	}
+	if key == "esc" && m.syncCancel != nil && m.escCancelsSync() {
+		return m.cancelSync()
+	}
+	if key == "?" && m.helpOpen() {
+		m.showHelp = true
+		if name, _ := m.viewKeys(); name != "" {
+			m.opts.Usage.Add(name + ": help")
+		}
+		return m, nil
+	}
+	m.countKey(key)
+	if m.changesMail(key) {
+		return m, m.toasts.Push(readOnlyNotice)
+	}
+	if m.spamRefuses(key) {
+		return m, m.toasts.Push(spamNotice)
 	}
 
 	switch m.view {
 	case viewAuth:
 		switch key {
 		case "enter":
+			if m.deviceCode != nil {
+				return m, nil
+			}
 			val := m.textInput.Value()
 			m.textInput.Reset()
 			return m, func() tea.Msg {
-				m.userResponses <- val
-				return <-m.uiEvents
+				// The flow may have finished through the redirect already.
+				select {
+				case m.userResponses <- val:
+				default:
+				}
+				return nil
 			}
 		case "q":
 			return m, tea.Quit
@@ -253,38 +1027,188 @@ func (m *AppModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.groupsList, cmd = m.groupsList.Update(msg)
 			return m, cmd
 		}
+		km := m.opts.Keys
+		if km.Profile == ProfileVim {
+			if ok, cmd := m.handleVimKey(key); ok {
+				return m, cmd
+			}
+			if m.visual && key == km.Trash {
+				return m.askTrashVisual()
+			}
+		}
 		switch key {
 		case "q":
 			return m, tea.Quit
 		case "enter":
 			return m.enterGroup()
-		case "e":
+		case km.Archive:
+			if m.opts.Spam && m.opts.Confirm.Archive {
+				return m.askGroupAction(confirmArchive, "Move to the inbox")
+			}
+			if m.opts.Confirm.Archive || m.selectedProtected() {
+				return m.askGroupAction(confirmArchive, "Archive")
+			}
 			return m.archiveSelectedGroup()
-		case "#":
+		case km.Trash:
+			if m.opts.Spam {
+				return m.askGroupAction(confirmTrash, "Delete forever")
+			}
+			if m.opts.Confirm.Trash || m.selectedProtected() {
+				return m.askGroupAction(confirmTrash, "Move to trash")
+			}
 			return m.trashSelectedGroup()
-		case "u":
+		case km.Read:
+			return m.markSelectedGroupRead()
+		case km.Unsubscribe:
 			return m.unsubscribeSelectedGroup()
-		case "s":
-			m.status = "Syncing..."
+		case km.Pin:
+			return m.togglePinSelectedGroup()
+		case km.Protect:
+			return m.toggleSenderStatus(model.SenderProtected)
+		case km.Block:
+			return m.toggleBlock()
+		case km.Filter:
+			return m.filterSelectedGroup()
+		case km.Sort:
+			return m.cycleGroupOrder()
+		case km.Bulk:
+			return m.toggleBulkOnly()
+		case km.Dates:
+			m.dateInput.SetValue(m.dateFilter.Label)
+			m.dateInput.CursorEnd()
+			return m, m.dateInput.Focus()
+		case km.Domains:
+			if m.store == nil {
+				return m, m.toasts.Push("Changing the grouping needs a local store")
+			}
+			previous := m.grouping
+			m.grouping = m.grouping.Next()
+			return m, m.regroupCmd(previous)
+		case km.UnsubscribeAll:
+			return m.askBulkUnsubscribe()
+		case km.Queue:
+			return m.toggleQueuedSelectedGroup()
+		case km.Sync:
+			if m.scanning {
+				return m, m.toasts.Push("The full scan is still running; ctrl+x cancels it")
+			}
+			if m.syncCancel != nil {
+				return m, m.toasts.Push("A sync is already running; ctrl+x cancels it")
+			}
+			m.statusBar.Text = "Syncing..."
+			m.syncing = true
 			return m, m.syncCmd()
+		case km.Export:
+			return m.exportSelectedGroup()
+		case km.Stats:
+			return m.openStats()
+		case km.Rules:
+			return m.openRules()
+		case km.Drafts:
+			return m.openDrafts()
+		case km.Accounts:
+			return m.openAccounts()
+		case km.Trashed:
+			return m.openTrash()
+		case km.Sender:
+			return m.openProfile()
+		case km.Details:
+			m.showDetail = !m.showDetail
+			m.resize()
+			return m, m.refreshDetail()
+		}
+		if key == "/" {
+			// The list filters only the groups it holds.
+			if err := m.loadRemainingGroups(); err != nil {
+				return m, m.toasts.Push(fmt.Sprintf("Loading groups failed: %v", err))
+			}
 		}
 		var cmd tea.Cmd
 		m.groupsList, cmd = m.groupsList.Update(msg)
-		return m, cmd
+		detail := m.refreshDetail()
+		if m.layout == layoutWide {
+			m.refreshPreview()
+		}
+		return m, tea.Batch(cmd, detail, m.loadMoreCmd())
 
 	case viewMessages:
+		if m.messagesList.FilterState() == list.Filtering {
+			var cmd tea.Cmd
+			m.messagesList, cmd = m.messagesList.Update(msg)
+			return m, cmd
+		}
 		switch key {
 		case "q":
 			return m, tea.Quit
 		case "esc":
-			m.view = viewGroups
+			if m.searchApplied {
+				m.clearSearch()
+				return m, nil
+			}
+			m.view = m.messagesReturn
 			m.selectedGroup = nil
 			return m, nil
 		case "enter":
 			return m.enterMessage()
+		case "s":
+			// The search looks through the messages it holds.
+			if err := m.loadRemainingMessages(); err != nil {
+				return m, m.toasts.Push(fmt.Sprintf("Loading messages failed: %v", err))
+			}
+			m.searchApplied = false
+			return m, m.searchInput.Focus()
+		case "v":
+			return m.togglePreview()
+		case "x":
+			return m.exportSelectedMessage()
+		case "z":
+			return m.snoozeSelectedMessage()
 		}
 		var cmd tea.Cmd
 		m.messagesList, cmd = m.messagesList.Update(msg)
+		return m, tea.Batch(cmd, m.previewCmd(), m.loadMoreMessagesCmd())
+
+	case viewRules:
+		return m.handleRulesKey(msg)
+
+	case viewDrafts:
+		return m.handleDraftsKey(msg)
+
+	case viewAccounts:
+		return m.handleAccountsKey(msg)
+
+	case viewTrash:
+		return m.handleTrashKey(msg)
+
+	case viewProfile:
+		return m.handleProfileKey(msg)
+
+	case viewStats:
+		switch key {
+		case "q":
+			return m, tea.Quit
+		case "esc":
+			m.view = viewGroups
+			return m, nil
+		case "r":
+			return m, m.statsCmd()
+		}
+		var cmd tea.Cmd
+		m.statsViewport, cmd = m.statsViewport.Update(msg)
+		return m, cmd
+
+	case viewUnsubscribe:
+		switch key {
+		case "q":
+			return m, tea.Quit
+		case "esc":
+			m.view = viewGroups
+			return m, nil
+		case "c":
+			return m.cancelUnsubscribeQueue()
+		}
+		var cmd tea.Cmd
+		m.reportViewport, cmd = m.reportViewport.Update(msg)
 		return m, cmd
 
 	case viewBody:
@@ -296,11 +1220,28 @@ func (m *AppModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.selectedMsg = nil
 			return m, nil
 		case "o":
+			if m.opts.Demo {
+				return m, m.toasts.Push("The demo mailbox has no Gmail web view")
+			}
 			if m.selectedMsg != nil {
 				url := fmt.Sprintf("https://mail.google.com/mail/u/0/#inbox/%s", m.selectedMsg.ID)
 				gmail.OpenBrowser(url)
 			}
 			return m, nil
+		case "tab":
+			if n := len(m.body.Attachments); n > 0 {
+				m.attachmentIdx = (m.attachmentIdx + 1) % n
+				m.renderBody()
+			}
+			return m, nil
+		case "d":
+			return m.downloadSelectedAttachment()
+		case "c":
+			return m.addInviteToCalendar()
+		case "l":
+			return m.promptLink("")
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			return m.promptLink(key)
 		}
 		var cmd tea.Cmd
 		m.bodyViewport, cmd = m.bodyViewport.Update(msg)
@@ -319,193 +1260,1267 @@ func (m *AppModel) enterGroup() (tea.Model, tea.Cmd) {
 	g := gi.SenderGroup
 	m.selectedGroup = &g
 
-	// Load full message data from store to get dates and other fields.
-	var msgs []model.MessageRef
-	if m.store != nil {
-		loaded, err := m.store.GetMessagesByIDs(context.Background(), g.MessageIDs)
-		if err == nil && len(loaded) > 0 {
-			msgs = loaded
-		}
+	if key := g.Email + "||" + g.Subject; key != m.previewKey {
+		m.showGroupMessages(g)
+		m.previewKey = key
 	}
-	if msgs == nil {
-		msgs = buildMessageRefsFromGroup(g)
-	}
-	m.messagesList.SetItems(sortedMessageItems(msgs))
-	m.messagesList.Title = fmt.Sprintf("%s — %s (%d messages)", g.DisplayName, g.Subject, g.Count)
 	m.view = viewMessages
-	return m, nil
+	m.messagesReturn = viewGroups
+	return m, tea.Batch(m.previewCmd(), m.prefetchCmd(m.groupMsgs))
 }
 
-// buildMessageRefsFromGroup creates minimal MessageRef stubs from the group's
-// message IDs. Used as a fallback when the store is unavailable.
-func buildMessageRefsFromGroup(g model.SenderGroup) []model.MessageRef {
-	refs := make([]model.MessageRef, len(g.MessageIDs))
-	for i, id := range g.MessageIDs {
-		refs[i] = model.MessageRef{
-			ID:      id,
-			From:    g.Email,
-			Subject: g.Subject,
-		}
+// showGroupMessages fills the messages list with the group's messages.
+func (m *AppModel) showGroupMessages(g model.SenderGroup) {
+	m.groupMsgs, m.msgsMore = m.loadGroupMessages(g)
+	m.msgsGroup = g
+	m.loadingMsgs = false
+	m.groupHeading = g.DisplayName + " — " + g.Subject
+	if g.IsDomain() {
+		m.groupHeading = g.DisplayName + " — " + plural(g.Senders, "sender")
+	} else if isSenderGroup(g) {
+		m.groupHeading = g.DisplayName + " — " + plural(len(g.Members), "subject")
 	}
-	return refs
+	m.setGroupTitle()
+	m.clearSearch()
+	m.bodyShown = false
+	m.previewMsgID = ""
 }
 
-func (m *AppModel) enterMessage() (tea.Model, tea.Cmd) {
-	selected := m.messagesList.SelectedItem()
-	if selected == nil {
+// handleSearchInput edits the messages search, narrowing the list as the
+// query is typed: enter keeps the search and returns to the list, esc clears
+// it.
+func (m *AppModel) handleSearchInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.clearSearch()
+		return m, nil
+	case "enter":
+		m.searchInput.Blur()
+		m.searchApplied = len(searchTerms(m.searchInput.Value())) > 0
 		return m, nil
 	}
-	mi := selected.(messageItem)
-	ref := mi.MessageRef
-	m.selectedMsg = &ref
-	m.status = "Loading message..."
-	return m, m.fetchBodyCmd(ref.ID)
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	m.applySearch()
+	return m, tea.Batch(cmd, m.previewCmd())
 }
 
-func (m *AppModel) archiveSelectedGroup() (tea.Model, tea.Cmd) {
-	selected := m.groupsList.SelectedItem()
-	if selected == nil {
-		return m, nil
+// clearSearch closes the search prompt and shows all of the group's messages.
+func (m *AppModel) clearSearch() {
+	m.searchInput.Blur()
+	m.searchInput.Reset()
+	m.searchApplied = false
+	m.applySearch()
+}
+
+// applySearch fills the messages list with the group's messages that match
+// the search, highlighting the matches.
+func (m *AppModel) applySearch() {
+	query := strings.TrimSpace(m.searchInput.Value())
+	terms := searchTerms(query)
+	msgs := searchMessages(m.groupMsgs, terms)
+	m.messagesList.SetDelegate(m.messagesDelegate())
+	m.messagesList.SetItems(sortedMessageItems(msgs))
+	m.messagesList.ResetSelected()
+	m.messagesList.Title = m.groupTitle
+	if len(terms) > 0 {
+		m.messagesList.Title = fmt.Sprintf("%s (%d of %d messages match %q)", m.groupHeading, len(msgs), len(m.groupMsgs), query)
 	}
-	gi := selected.(groupItem)
-	ids := gi.MessageIDs
+}
 
-	// Optimistically remove from list
-	idx := m.groupsList.Index()
-	m.groupsList.RemoveItem(idx)
-	m.status = "Archiving..."
+// messagesDelegate draws the messages list for the current layout and search.
+func (m *AppModel) messagesDelegate() list.ItemDelegate {
+	return searchDelegate{
+		DefaultDelegate: ui.NewDelegate(m.layout == layoutCompact),
+		terms:           searchTerms(m.searchInput.Value()),
+	}
+}
 
-	return m, m.archiveCmd(ids)
+// refreshPreview shows the highlighted group's messages in the messages pane
+// of the wide layout.
+func (m *AppModel) refreshPreview() {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return
+	}
+	if key := gi.Email + "||" + gi.Subject; key != m.previewKey {
+		m.showGroupMessages(gi.SenderGroup)
+		m.previewKey = key
+	}
 }
 
-func (m *AppModel) trashSelectedGroup() (tea.Model, tea.Cmd) {
-	selected := m.groupsList.SelectedItem()
-	if selected == nil {
-		return m, nil
+// setGroupTitle titles the messages list with the open group and how many
+// of its messages are loaded.
+func (m *AppModel) setGroupTitle() {
+	g := m.msgsGroup
+	m.groupTitle = fmt.Sprintf("%s (%d messages)", m.groupHeading, g.Count)
+	if len(m.groupMsgs) < g.Count {
+		m.groupTitle = fmt.Sprintf("%s (newest %d of %d messages)", m.groupHeading, len(m.groupMsgs), g.Count)
 	}
-	gi := selected.(groupItem)
-	ids := gi.MessageIDs
+}
 
-	idx := m.groupsList.Index()
-	m.groupsList.RemoveItem(idx)
-	m.status = "Trashing..."
+// loadGroupMessages loads full message data from the store to get dates and
+// other fields, falling back to stubs built from the group's IDs. Groups
+// without MessageIDs load their first groupMessagePage messages, and more
+// reports whether the store may hold others.
+func (m *AppModel) loadGroupMessages(g model.SenderGroup) (msgs []model.MessageRef, more bool) {
+	ctx := context.Background()
+	if m.store != nil {
+		if gs, ok := m.store.(gmail.GroupSummaryStore); ok && len(g.MessageIDs) == 0 {
+			loaded, err := groupMessages(ctx, gs, g, model.MessageRef{}, groupMessagePage)
+			if err == nil && len(loaded) > 0 {
+				return loaded, len(loaded) == groupMessagePage
+			}
+		}
+		loaded, err := m.store.GetMessagesByIDs(ctx, g.MessageIDs)
+		if err == nil && len(loaded) > 0 {
+			return loaded, false
+		}
+	}
+	return buildMessageRefsFromGroup(g), false
+}
 
-	return m, m.trashCmd(ids)
+// forEachGroupIDBatch calls fn with the group's message IDs. Groups built
+// from summaries, in low-memory mode or paged in, carry none; their IDs are
+// streamed from the store in batches instead.
+func (m *AppModel) forEachGroupIDBatch(ctx context.Context, g model.SenderGroup, fn func(ids []string) error) error {
+	if gs, ok := m.store.(gmail.GroupSummaryStore); ok && len(g.MessageIDs) == 0 {
+		if len(g.Members) == 0 {
+			return gs.StreamGroupMessageIDs(ctx, g.Email, g.Subject, 1000, fn)
+		}
+		for _, k := range g.Members {
+			if err := gs.StreamGroupMessageIDs(ctx, k.Email, k.Subject, 1000, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fn(g.MessageIDs)
 }
 
-func (m *AppModel) unsubscribeSelectedGroup() (tea.Model, tea.Cmd) {
+// groupMessages returns up to limit messages of g from the store after the
+// message after, in the store's order: newest first, then by descending ID.
+// It reads the member groups of a merged group one by one.
+func groupMessages(ctx context.Context, gs gmail.GroupSummaryStore, g model.SenderGroup, after model.MessageRef, limit int) ([]model.MessageRef, error) {
+	if len(g.Members) == 0 {
+		return gs.GetGroupMessages(ctx, g.Email, g.Subject, after, limit)
+	}
+	var msgs []model.MessageRef
+	for _, k := range g.Members {
+		part, err := gs.GetGroupMessages(ctx, k.Email, k.Subject, after, limit)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, part...)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].DateRFC3339 != msgs[j].DateRFC3339 {
+			return msgs[i].DateRFC3339 > msgs[j].DateRFC3339
+		}
+		return msgs[i].ID > msgs[j].ID
+	})
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+// refreshDetail counts the highlighted group's messages by age in the
+// background when the highlighted group changes.
+func (m *AppModel) refreshDetail() tea.Cmd {
+	if !m.showDetail {
+		return nil
+	}
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return nil
+	}
+	key := gi.Email + "||" + gi.Subject
+	if key == m.detailKey {
+		return nil
+	}
+	m.detailKey = key
+	m.detailBuckets = ageBuckets{}
+	st, g := m.store, gi.SenderGroup
+	return func() tea.Msg {
+		return detailMsg{key: key, buckets: groupAges(context.Background(), st, g, time.Now())}
+	}
+}
+
+// buildMessageRefsFromGroup creates minimal MessageRef stubs from the group's
+// message IDs. Used as a fallback when the store is unavailable.
+func buildMessageRefsFromGroup(g model.SenderGroup) []model.MessageRef {
+	refs := make([]model.MessageRef, len(g.MessageIDs))
+	for i, id := range g.MessageIDs {
+		refs[i] = model.MessageRef{
+			ID:      id,
+			From:    g.Email,
+			Subject: g.Subject,
+		}
+	}
+	return refs
+}
+
+func (m *AppModel) enterMessage() (tea.Model, tea.Cmd) {
+	selected := m.messagesList.SelectedItem()
+	if selected == nil {
+		return m, nil
+	}
+	mi := selected.(messageItem)
+	ref := mi.MessageRef
+	m.selectedMsg = &ref
+	if m.previewing() && m.bodyShown && m.bodyMsgID == ref.ID {
+		// The preview already shows it; just move the focus there.
+		m.view = viewBody
+		return m, nil
+	}
+	m.statusBar.Text = "Loading message..."
+	return m, m.fetchBodyCmd(ref.ID)
+}
+
+func (m *AppModel) archiveSelectedGroup() (tea.Model, tea.Cmd) {
 	selected := m.groupsList.SelectedItem()
 	if selected == nil {
 		return m, nil
 	}
 	gi := selected.(groupItem)
-	if gi.UnsubscribeURL == "" {
-		m.status = "No unsubscribe URL available for this group"
-		return m, clearStatusAfter(2 * time.Second)
+
+	// Optimistically remove from list
+	if !m.changesNothing() && m.archiveHides() {
+		m.groupsList.RemoveItem(m.groupsList.Index())
+		m.removeGroup(gi.SenderGroup)
+	}
+	m.statusBar.Text = "Archiving..."
+	if m.opts.Spam {
+		m.statusBar.Text = "Moving to the inbox..."
 	}
 
-	// Open in browser (non-blocking)
-	return m, func() tea.Msg {
-		err := gmail.OpenUnsubscribeURL(gi.SenderGroup.UnsubscribeURL)
+	return m, m.archiveCmd(gi.SenderGroup)
+}
+
+func (m *AppModel) trashSelectedGroup() (tea.Model, tea.Cmd) {
+	selected := m.groupsList.SelectedItem()
+	if selected == nil {
+		return m, nil
+	}
+	gi := selected.(groupItem)
+
+	if !m.changesNothing() {
+		m.groupsList.RemoveItem(m.groupsList.Index())
+		m.removeGroup(gi.SenderGroup)
+	}
+	m.statusBar.Text = "Trashing..."
+	if m.opts.Spam {
+		m.statusBar.Text = "Deleting..."
+	}
+
+	return m, m.trashCmd(gi.SenderGroup)
+}
+
+// markSelectedGroupRead marks the highlighted group's unread messages read,
+// leaving them in the inbox.
+func (m *AppModel) markSelectedGroupRead() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	if gi.Unread == 0 {
+		return m, m.toasts.Push("Nothing unread from " + gi.DisplayName)
+	}
+	m.statusBar.Text = "Marking read..."
+	return m, m.markReadCmd(gi.SenderGroup)
+}
+
+// markedRead reports a group marked read and lowers its unread count.
+func (m *AppModel) markedRead(msg markedReadMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Marking read failed: %v", msg.err))
+	}
+	if msg.dryRun {
+		return m.toasts.Push(m.skipped(fmt.Sprintf("mark %s from %s read", plural(msg.marked, "message"), msg.group.Email)))
+	}
+	for i := range m.groups {
+		if m.groups[i].Email == msg.group.Email && m.groups[i].Subject == msg.group.Subject {
+			m.groups[i].Unread = max(m.groups[i].Unread-msg.marked, 0)
+		}
+	}
+	m.showGroups()
+	m.selectGroup(model.GroupKey{Email: msg.group.Email, Subject: msg.group.Subject})
+	return m.toasts.Push(fmt.Sprintf("Marked %s from %s read", plural(msg.marked, "message"), msg.group.DisplayName))
+}
+
+// togglePinSelectedGroup pins or unpins the highlighted group and re-sorts
+// the list, keeping the group highlighted.
+func (m *AppModel) togglePinSelectedGroup() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	ps, ok := m.store.(gmail.PinStore)
+	if !ok {
+		return m, m.toasts.Push("Pinning needs a local store")
+	}
+	key := model.GroupKey{Email: gi.Email, Subject: gi.Subject}
+	if err := ps.SetGroupPinned(context.Background(), key, !gi.Pinned); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Pin failed: %v", err))
+	}
+	pinned, err := ps.LoadPinnedGroups(context.Background())
+	if err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Pin failed: %v", err))
+	}
+	gmail.ApplyPins(m.groups, pinned, m.opts.Sort)
+	m.dropTrailingGroup(key)
+	m.showGroups()
+	m.selectGroup(key)
+	if gi.Pinned {
+		return m, m.toasts.Push("Unpinned " + gi.DisplayName)
+	}
+	return m, m.toasts.Push("Pinned " + gi.DisplayName)
+}
+
+// toggleSenderStatus gives the sender of the highlighted group status, or
+// takes it away when the sender already has it. On a domain group it covers
+// the whole domain.
+func (m *AppModel) toggleSenderStatus(status model.SenderStatus) (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	return m.toggleStatusOf(gi.SenderGroup, status)
+}
+
+// toggleStatusOf gives the sender of gi status, or takes it away when the
+// sender already has it, keeping gi highlighted in the groups list.
+func (m *AppModel) toggleStatusOf(gi model.SenderGroup, status model.SenderStatus) (tea.Model, tea.Cmd) {
+	ss, ok := m.store.(gmail.SenderListStore)
+	if !ok {
+		return m, m.toasts.Push("Sender lists need a local store")
+	}
+	ctx := context.Background()
+	set := status
+	if m.senders.Listed(gi.Email) == status {
+		set = ""
+	}
+	if err := ss.SetSenderStatus(ctx, gi.Email, set); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Updating sender lists failed: %v", err))
+	}
+	senders, err := gmail.LoadSenderLists(ctx, m.store)
+	if err != nil {
+		return m, m.toasts.Push(err.Error())
+	}
+	m.senders = senders
+	key := model.GroupKey{Email: gi.Email, Subject: gi.Subject}
+	m.showGroups()
+	m.selectGroup(key)
+	switch now := senders.Status(gi.Email); {
+	case set == "" && now != "":
+		return m, m.toasts.Push(fmt.Sprintf("%s is still %s through its domain", gi.DisplayName, now))
+	case set == "":
+		return m, m.toasts.Push(fmt.Sprintf("%s is no longer %s", gi.DisplayName, status))
+	}
+	return m, m.toasts.Push("Protected " + gi.DisplayName)
+}
+
+// cycleGroupOrder re-sorts the list by the next order in gmail.GroupOrders,
+// keeping the highlighted group highlighted. The order sticks for later
+// syncs of this session.
+func (m *AppModel) cycleGroupOrder() (tea.Model, tea.Cmd) {
+	if err := m.loadRemainingGroups(); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Loading groups failed: %v", err))
+	}
+	m.opts.Sort = m.opts.Sort.Next()
+	selected, hasSelection := m.groupsList.SelectedItem().(groupItem)
+	gmail.SortGroupsBy(m.groups, m.opts.Sort)
+	m.showGroups()
+	if hasSelection {
+		m.selectGroup(model.GroupKey{Email: selected.Email, Subject: selected.Subject})
+	}
+	return m, m.toasts.Push("Sorted by " + orderNames[m.opts.Sort])
+}
+
+// showGroups fills the groups list with the groups that pass the date
+// filter. m.groups stays the unfiltered list.
+func (m *AppModel) showGroups() {
+	gmail.ApplySenderLists(m.groups, m.senders)
+	gmail.ApplyUnsubscribes(m.groups, m.unsubs)
+	groups := m.dateFilter.Apply(m.groups)
+	if m.bulkOnly {
+		groups = bulkGroups(groups)
+	}
+	m.groupsList.SetItems(groupsToItems(groups, m.queuedSenders()))
+	m.groupsList.Title = m.groupsTitle()
+}
+
+// selectGroup highlights the listed group with the given key, if any.
+func (m *AppModel) selectGroup(key model.GroupKey) {
+	for i, it := range m.groupsList.Items() {
+		if g := it.(groupItem); g.Email == key.Email && g.Subject == key.Subject {
+			m.groupsList.Select(i)
+			return
+		}
+	}
+}
+
+// replaceGroups shows groups reloaded in the background, keeping the
+// highlight at the same position. It returns the command recounting the
+// detail panel.
+func (m *AppModel) replaceGroups(set groupSet) tea.Cmd {
+	idx := m.groupsList.Index()
+	m.setGroups(set)
+	m.showGroups()
+	m.groupsList.Select(min(idx, max(len(m.groupsList.Items())-1, 0)))
+	m.detailKey = ""
+	detail := m.refreshDetail()
+	if m.view == viewGroups && m.layout == layoutWide {
+		m.previewKey = ""
+		m.refreshPreview()
+	}
+	return detail
+}
+
+// removeGroup drops g from m.groups after an action removed it from the
+// list, so refiltering or re-sorting does not bring it back.
+func (m *AppModel) removeGroup(g model.SenderGroup) {
+	for i := range m.groups {
+		if m.groups[i].Email == g.Email && m.groups[i].Subject == g.Subject {
+			m.groups = append(m.groups[:i], m.groups[i+1:]...)
+			if m.moreGroups() {
+				// Its messages leave the store, moving later rows up.
+				m.groupsOffset--
+			}
+			return
+		}
+	}
+}
+
+// handleDateInput edits the date filter prompt: enter applies it, esc
+// leaves the filter as it was.
+func (m *AppModel) handleDateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.dateInput.Blur()
+		return m, nil
+	case "enter":
+		f, err := gmail.ParseDateFilter(m.dateInput.Value(), time.Now())
 		if err != nil {
-			return actionResultMsg{action: "Unsubscribe", err: err}
+			return m, m.toasts.Push(err.Error())
+		}
+		if err := m.loadRemainingGroups(); err != nil {
+			return m, m.toasts.Push(fmt.Sprintf("Loading groups failed: %v", err))
+		}
+		m.dateInput.Blur()
+		m.dateFilter = f
+		m.showGroups()
+		m.groupsList.ResetSelected()
+		m.detailKey = ""
+		detail := m.refreshDetail()
+		m.previewKey = ""
+		if m.layout == layoutWide {
+			m.refreshPreview()
+		}
+		if !f.Active() {
+			return m, tea.Batch(detail, m.toasts.Push("Showing groups of every date"))
+		}
+		return m, tea.Batch(detail, m.toasts.Push(fmt.Sprintf("Date filter: %s (%d groups)", f.Label, len(m.groupsList.Items()))))
+	}
+	var cmd tea.Cmd
+	m.dateInput, cmd = m.dateInput.Update(msg)
+	return m, cmd
+}
+
+// orderNames describe the group orders in titles and toasts.
+var orderNames = map[gmail.GroupOrder]string{
+	gmail.OrderCount:  "message count",
+	gmail.OrderNewest: "newest",
+	gmail.OrderOldest: "oldest",
+	gmail.OrderSender: "sender",
+	gmail.OrderSize:   "size",
+}
+
+// bulkUnsubscribeGroups returns the visible groups (honouring an active
+// filter) that carry an unsubscribe URL, leaving out protected senders and
+// those already unsubscribed from, and how many groups of each it left out.
+func (m *AppModel) bulkUnsubscribeGroups() (groups []model.SenderGroup, protected, done int) {
+	for _, it := range m.groupsList.VisibleItems() {
+		gi, ok := it.(groupItem)
+		switch {
+		case !ok || gi.UnsubscribeURL == "":
+		case gi.Status == model.SenderProtected:
+			protected++
+		case gi.Unsubscribed != nil:
+			done++
+		default:
+			groups = append(groups, gi.SenderGroup)
 		}
-		return actionResultMsg{action: "Unsubscribe (opened browser)"}
 	}
+	return groups, protected, done
+}
+
+// ui.Confirm IDs of the prompts shown before destructive actions.
+const (
+	confirmBulkUnsubscribe = "bulk-unsubscribe"
+	confirmArchive         = "archive"
+	confirmTrash           = "trash"
+	confirmBlock           = "block"
+	confirmDeleteRule      = "delete-rule"
+	confirmUnsubscribe     = "unsubscribe"
+	confirmOpenAttachment  = "open-attachment"
+	confirmDeleteDraft     = "delete-draft"
+	confirmTrashSelection  = "trash-selection"
+	confirmRestoreTrash    = "restore-trash"
+	confirmArchiveSender   = "archive-sender"
+	confirmTrashSender     = "trash-sender"
+)
+
+// countKey records the use of key when it is one of the current view's
+// bindings, naming it by view and help text so remapped keys count alike.
+func (m *AppModel) countKey(key string) {
+	if m.opts.Usage == nil {
+		return
+	}
+	if m.view == viewGroups && m.groupsList.FilterState() == list.Filtering {
+		return
+	}
+	view, keys := m.viewKeys()
+	for _, k := range keys {
+		if k.Keys == key {
+			m.opts.Usage.Add(view + ": " + k.Help)
+			return
+		}
+	}
+}
+
+// askGroupAction asks before applying verb to every message of the
+// highlighted group.
+func (m *AppModel) askGroupAction(id, verb string) (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	prompt := fmt.Sprintf("%s all %d messages from %s?", verb, gi.Count, gi.DisplayName)
+	if gi.Status == model.SenderProtected {
+		prompt = fmt.Sprintf("%s is protected. %s", gi.DisplayName, prompt)
+	}
+	m.confirm.Ask(id, prompt)
+	return m, nil
+}
+
+// selectedProtected reports whether the highlighted group is from a
+// protected sender, whose mail is only archived or trashed after a prompt.
+func (m *AppModel) selectedProtected() bool {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	return ok && gi.Status == model.SenderProtected
+}
+
+// askBulkUnsubscribe starts the unsubscribe queue, after asking when
+// configured to or when the queue sends email. With nothing queued it queues
+// every listed group with an unsubscribe URL first; while the queue runs it
+// shows its progress.
+func (m *AppModel) askBulkUnsubscribe() (tea.Model, tea.Cmd) {
+	if m.unsubRunning {
+		m.showUnsubscribeQueue()
+		return m, nil
+	}
+	if waiting, emails := m.waitingUnsubs(); waiting > 0 {
+		if !m.opts.Confirm.BulkUnsubscribe && emails == 0 {
+			return m.startBulkUnsubscribe()
+		}
+		prompt := fmt.Sprintf("Unsubscribe from the %s?", plural(waiting, "queued sender"))
+		if emails > 0 {
+			prompt = fmt.Sprintf("Unsubscribe from the %s, sending %s from your account?", plural(waiting, "queued sender"), plural(emails, "unsubscribe email"))
+		}
+		m.confirm.Ask(confirmBulkUnsubscribe, prompt)
+		return m, nil
+	}
+	if err := m.loadRemainingGroups(); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Loading groups failed: %v", err))
+	}
+	groups, protected, done := m.bulkUnsubscribeGroups()
+	n := len(groups)
+	if n == 0 && protected+done > 0 {
+		return m, m.toasts.Push("Every listed group with an unsubscribe URL is protected or already unsubscribed from")
+	}
+	if n == 0 {
+		return m, m.toasts.Push("No listed group has an unsubscribe URL")
+	}
+	if !m.opts.Confirm.BulkUnsubscribe {
+		return m.startBulkUnsubscribe()
+	}
+	var skipped []string
+	if protected > 0 {
+		skipped = append(skipped, plural(protected, "protected group"))
+	}
+	if done > 0 {
+		skipped = append(skipped, plural(done, "group")+" already unsubscribed from")
+	}
+	prompt := fmt.Sprintf("Unsubscribe from all %d listed groups with an unsubscribe link?", n)
+	if len(skipped) > 0 {
+		prompt = fmt.Sprintf("Unsubscribe from all %d listed groups with an unsubscribe link, skipping %s?", n, strings.Join(skipped, " and "))
+	}
+	m.confirm.Ask(confirmBulkUnsubscribe, prompt)
+	return m, nil
+}
+
+// startBulkUnsubscribe runs the queue, filling it from the listed groups
+// when nothing is waiting, and shows its progress.
+func (m *AppModel) startBulkUnsubscribe() (tea.Model, tea.Cmd) {
+	if waiting, _ := m.waitingUnsubs(); waiting == 0 {
+		groups, _, _ := m.bulkUnsubscribeGroups()
+		for _, g := range groups {
+			m.queueUnsub(g)
+		}
+	}
+	m.unsubRunning = true
+	m.showUnsubscribeQueue()
+	m.showGroups()
+	return m, m.nextUnsubscribeCmd()
+}
+
+func (m *AppModel) unsubscribeSelectedGroup() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	return m.unsubscribeGroup(gi.SenderGroup)
+}
+
+// unsubscribeGroup unsubscribes from the sender of gi through the group's
+// link, asking first when that sends mail or was done before.
+func (m *AppModel) unsubscribeGroup(gi model.SenderGroup) (tea.Model, tea.Cmd) {
+	if gi.IsDomain() {
+		return m, m.toasts.Push(fmt.Sprintf("Unsubscribing works per sender; press %s to group by sender", m.opts.Keys.Domains))
+	}
+	if gi.UnsubscribeURL == "" && gi.UnsubscribeMailto == "" {
+		return m, m.toasts.Push("No unsubscribe link available for this group")
+	}
+	var prompt []string
+	if u := gi.Unsubscribed; u != nil {
+		prompt = append(prompt, fmt.Sprintf("You unsubscribed from %s on %s (%s).", gi.DisplayName, u.Time.Format(time.DateOnly), u.Method))
+	}
+	// Sending mail on the user's behalf always asks first.
+	if gi.UnsubscribeURL == "" {
+		prompt = append(prompt, fmt.Sprintf("Send an unsubscribe email to %s?", strings.TrimPrefix(gi.UnsubscribeMailto, "mailto:")))
+	} else if len(prompt) > 0 {
+		prompt = append(prompt, "Unsubscribe again?")
+	}
+	if len(prompt) > 0 {
+		m.pendingUnsub = gi
+		m.confirm.Ask(confirmUnsubscribe, strings.Join(prompt, " "))
+		return m, nil
+	}
+	return m, m.unsubscribeCmd(gi)
 }
 
 // Commands
 
 func (m *AppModel) syncCmd() tea.Cmd {
-	return func() tea.Msg {
-		ctx := context.Background()
+	// Auto-labels and blocked senders are subject to a dry run. esc and
+	// ctrl+x cancel the sync (see cancelSync).
+	ctx, cancel := context.WithCancel(m.actionContext())
+	m.syncCancel = cancel
+	return m.authGuard(func() tea.Msg {
+		msg := m.runSync(ctx)
+		if errors.Is(msg.err, context.Canceled) && m.store != nil {
+			// Show what the sync cached before it was cancelled.
+			groups, err := m.loadGroups(context.Background(), 0)
+			last, _ := gmail.LastSync(context.Background(), m.store)
+			return syncCompleteMsg{groups: groups, lastSync: last, cancelled: true, err: err}
+		}
+		return msg
+	})
+}
 
-		progress := func(sp gmail.SyncProgress) {
-			if m.program != nil {
-				m.program.Send(syncProgressMsg{
-					phase: sp.Phase,
-					done:  sp.Done,
-					total: sp.Total,
-				})
-			}
+// cancelSync stops the sync syncCmd started. The messages it fetched stay
+// cached, and a full scan resumes from there on the next sync.
+func (m *AppModel) cancelSync() (tea.Model, tea.Cmd) {
+	m.syncCancel()
+	m.syncCancel = nil
+	m.statusBar.Text = "Cancelling sync..."
+	return m, nil
+}
+
+// endSync releases the context of the sync syncCmd started, once it is over.
+func (m *AppModel) endSync() {
+	if m.syncCancel != nil {
+		m.syncCancel()
+		m.syncCancel = nil
+	}
+}
+
+// escCancelsSync reports whether esc cancels the running sync: on the
+// loading screen, and in the groups view when there is no filter or visual
+// selection for it to clear.
+func (m *AppModel) escCancelsSync() bool {
+	switch m.view {
+	case viewLoading:
+		return true
+	case viewGroups:
+		return m.groupsList.FilterState() == list.Unfiltered && !m.visual
+	}
+	return false
+}
+
+// runSync brings the cache up to date, or starts doing so in the
+// background, and returns the groups to show meanwhile.
+func (m *AppModel) runSync(ctx context.Context) syncCompleteMsg {
+	progress := func(sp gmail.SyncProgress) {
+		if m.program != nil {
+			m.program.Send(syncProgressMsg{
+				phase: sp.Phase,
+				done:  sp.Done,
+				total: sp.Total,
+			})
 		}
+	}
 
-		if m.store != nil {
-			count, _ := m.store.CountMessages(ctx)
-			if count > 0 {
-				// Load cached groups first
-				groups, err := gmail.LoadGroupsFromDB(ctx, m.store)
-				if err == nil && len(groups) > 0 {
-					// Background incremental sync
-					go func() {
-						hid, _ := m.store.GetLastHistoryID(ctx)
-						if hid != "" {
-							gmail.SyncSinceHistory(ctx, m.service, m.store, hid, progress)
-						}
-					}()
-					return syncCompleteMsg{groups: groups}
-				}
+	opts := m.syncOptions()
+	if m.store != nil {
+		scope, err := m.mailbox.Scope(ctx, m.opts.Label)
+		if err != nil {
+			return syncCompleteMsg{err: err}
+		}
+		if _, err := gmail.EnsureLabelScope(ctx, m.store, scope); err != nil {
+			return syncCompleteMsg{err: err}
+		}
+		if _, err := gmail.EnsureQueryScope(ctx, m.store, opts.Query); err != nil {
+			return syncCompleteMsg{err: err}
+		}
+		opts.Label = scope
+	}
+
+	if m.store != nil {
+		count, _ := m.store.CountMessages(ctx)
+		hid, _ := m.store.GetLastHistoryID(ctx)
+		// Without a historyId the cache is empty or a full scan was
+		// interrupted; fall through so FullScan resumes it.
+		if count > 0 && hid != "" {
+			// Load cached groups first
+			groups, err := m.loadGroups(ctx, 0)
+			if err == nil && len(groups.groups) > 0 {
+				// Background incremental sync
+				go func() {
+					err := m.mailbox.Sync(ctx, m.store, opts, progress)
+					if m.program != nil {
+						m.program.Send(syncFinishedMsg{err: err})
+					}
+				}()
+				last, _ := gmail.LastSync(ctx, m.store)
+				return syncCompleteMsg{groups: groups, lastSync: last, background: m.program != nil}
 			}
+		}
 
-			// Empty DB: do full scan
-			err := gmail.FullScan(ctx, m.service, m.store, false, progress)
+		// Empty DB or interrupted scan: do (or resume) a full scan.
+		// In the running TUI it goes on in the background, and the
+		// groups fill in as it writes them.
+		if m.program != nil {
+			groups, err := m.loadGroups(ctx, 0)
 			if err != nil {
 				return syncCompleteMsg{err: err}
 			}
-			groups, err := gmail.LoadGroupsFromDB(ctx, m.store)
-			return syncCompleteMsg{groups: groups, err: err}
+			go func() {
+				err := m.mailbox.Sync(ctx, m.store, opts, progress)
+				m.program.Send(syncFinishedMsg{err: err})
+			}()
+			return syncCompleteMsg{groups: groups, background: true, scanning: true}
 		}
-
-		// No store: fetch directly (legacy path)
-		emails, err := gmail.FetchInitialEmails(ctx, m.service, 200)
+		err := m.mailbox.Sync(ctx, m.store, opts, progress)
 		if err != nil {
 			return syncCompleteMsg{err: err}
 		}
-		groupMap := gmail.AggregateBySenderSubject(emails)
-		return syncCompleteMsg{groups: gmail.SortGroups(groupMap)}
+		groups, err := m.loadGroups(ctx, 0)
+		last, _ := gmail.LastSync(ctx, m.store)
+		return syncCompleteMsg{groups: groups, lastSync: last, err: err}
+	}
+
+	// No store: fetch directly (legacy path)
+	if m.service == nil {
+		return syncCompleteMsg{err: errors.New("this account needs a local store")}
+	}
+	emails, err := gmail.FetchInitialEmails(ctx, m.service, 200)
+	if err != nil {
+		return syncCompleteMsg{err: err}
 	}
+	groups := gmail.SortGroups(gmail.AggregateBySenderSubject(emails))
+	gmail.SortGroupsBy(groups, m.opts.Sort)
+	return syncCompleteMsg{groups: groupSet{groups: groups}, lastSync: time.Now()}
 }
 
-func (m *AppModel) archiveCmd(ids []string) tea.Cmd {
+// pushCmd registers the Gmail watch and blocks receiving notifications,
+// forwarding each one to the Update loop as a pushNotifyMsg.
+func (m *AppModel) pushCmd() tea.Cmd {
 	return func() tea.Msg {
-		err := gmail.ArchiveMessages(context.Background(), m.service, ids)
-		if err == nil && m.store != nil {
-			m.store.DeleteMessages(context.Background(), ids)
+		ctx := context.Background()
+		register := func(ctx context.Context) error {
+			scope, err := gmail.LabelScope(ctx, m.store)
+			if err != nil {
+				return err
+			}
+			_, err = gmail.WatchMailbox(ctx, m.service, m.opts.Push.Topic, scope)
+			return err
 		}
-		return actionResultMsg{action: "Archive", err: err}
+		err := push.Run(ctx, m.opts.Push, register, func(push.Notification) {
+			if m.program != nil {
+				m.program.Send(pushNotifyMsg{})
+			}
+		})
+		return pushStoppedMsg{err: err}
+	}
+}
+
+// regroupCmd reloads the groups from the store after the grouping changed
+// from previous.
+func (m *AppModel) regroupCmd(previous gmail.GroupMode) tea.Cmd {
+	return func() tea.Msg {
+		groups, err := m.loadGroups(context.Background(), 0)
+		return regroupedMsg{groups: groups, previous: previous, err: err}
+	}
+}
+
+// refreshGroupsCmd reloads the groups from the store once the background
+// sync has finished, so new mail shows without pressing s. The rules run
+// after it, so their own reload cannot be overtaken by this one.
+func (m *AppModel) refreshGroupsCmd() tea.Cmd {
+	// Reload as many groups as are loaded now, so the highlight stays put.
+	window := m.groupsOffset
+	return func() tea.Msg {
+		groups, err := m.loadGroups(context.Background(), window)
+		return groupsRefreshedMsg{groups: groups, err: err}
 	}
 }
 
-func (m *AppModel) trashCmd(ids []string) tea.Cmd {
+// scanReloadEvery is how often the groups are reloaded while a full scan
+// fills the cache.
+const scanReloadEvery = 3 * time.Second
+
+// scanGroupsCmd reloads the groups written so far by the running full scan.
+func (m *AppModel) scanGroupsCmd() tea.Cmd {
+	// Reload as many groups as are loaded now, so the highlight stays put.
+	window := m.groupsOffset
 	return func() tea.Msg {
-		err := gmail.TrashMessages(context.Background(), m.service, ids)
-		if err == nil && m.store != nil {
-			m.store.DeleteMessages(context.Background(), ids)
+		groups, err := m.loadGroups(context.Background(), window)
+		return scanGroupsMsg{groups: groups, err: err}
+	}
+}
+
+// pushSyncCmd runs an incremental sync in response to a push notification
+// and reloads the groups without leaving the current view.
+func (m *AppModel) pushSyncCmd() tea.Cmd {
+	// Reload as many groups as are loaded now, so the highlight stays put.
+	window := m.groupsOffset
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		opts := m.syncOptions()
+		var err error
+		if opts.Label, err = gmail.LabelScope(ctx, m.store); err != nil {
+			return pushSyncedMsg{err: err}
+		}
+		if err := m.mailbox.Sync(ctx, m.store, opts, nil); err != nil {
+			return pushSyncedMsg{err: err}
+		}
+		groups, err := m.loadGroups(ctx, window)
+		return pushSyncedMsg{groups: groups, err: err}
+	})
+}
+
+// groupsTitle is the groups list title: the scope, the number of groups (or
+// senders, or domains), the date filter if one is set and, unless they are sorted by
+// count, the order.
+func (m *AppModel) groupsTitle() string {
+	noun := "groups"
+	switch m.grouping {
+	case gmail.GroupBySender:
+		noun = "senders"
+	case gmail.GroupByDomain:
+		noun = "domains"
+	}
+	count := fmt.Sprintf("%d %s", len(m.groups), noun)
+	if m.moreGroups() {
+		count = fmt.Sprintf("%d of %d %s loaded", len(m.groups), len(m.groups)+m.unloaded.Groups, noun)
+	}
+	var filters []string
+	if m.dateFilter.Active() {
+		filters = append(filters, m.dateFilter.Label)
+	}
+	if m.bulkOnly {
+		filters = append(filters, "bulk mail only")
+	}
+	if len(filters) > 0 {
+		count = fmt.Sprintf("%d of %d %s %s", len(m.groupsList.Items()), len(m.groups), noun, strings.Join(filters, ", "))
+	}
+	if m.opts.Sort == "" || m.opts.Sort == gmail.OrderCount {
+		return fmt.Sprintf("%s (%s)", m.scopeTitle(), count)
+	}
+	if m.opts.Sort == gmail.OrderSize {
+		// Sum what is listed, so the figure follows the date filter.
+		var size int64
+		for _, it := range m.groupsList.Items() {
+			if g, ok := it.(groupItem); ok {
+				size += g.Size
+			}
+		}
+		return fmt.Sprintf("%s (%s, by size, %s)", m.scopeTitle(), count, humanSize(size))
+	}
+	return fmt.Sprintf("%s (%s, by %s)", m.scopeTitle(), count, orderNames[m.opts.Sort])
+}
+
+// scopeTitle names the synced label for the groups list title.
+func (m *AppModel) scopeTitle() string {
+	title := m.opts.Label
+	switch strings.ToUpper(m.opts.Label) {
+	case "", "INBOX":
+		title = "Inbox"
+	case gmail.AllMail:
+		title = "All mail"
+	case gmail.SpamLabel:
+		title = "Spam"
+	}
+	if m.opts.Spam {
+		title = "Spam review"
+	}
+	if m.opts.Query != "" {
+		title += " matching " + m.opts.Query
+	}
+	if m.opts.Demo {
+		title = "Demo: " + title
+	}
+	return title
+}
+
+func (m *AppModel) syncOptions() gmail.SyncOptions {
+	opts := gmail.SyncOptions{Triage: gmail.NewTriage(m.opts.AutoLabels), Workers: m.opts.Workers, ListPageSize: m.opts.ListPageSize, UpsertBatch: m.opts.UpsertBatch, Retention: m.opts.Retention, Query: m.opts.Query, Window: m.opts.Window}
+	if n := m.opts.Notifier; n != nil {
+		opts.NewMessages = func(msgs []model.MessageRef) {
+			title, body, ok := gmail.NewMailNotice(msgs)
+			if !ok {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			// A missed alert is not worth interrupting the sync for.
+			n.Notify(ctx, title, body)
+		}
+	}
+	return opts
+}
+
+// loadGroups reads the cached groups, aggregating in the store when running
+// in low-memory mode. When they can be paged it loads only the first window,
+// of at least window groups.
+func (m *AppModel) loadGroups(ctx context.Context, window int) (groupSet, error) {
+	senders, err := gmail.LoadSenderLists(ctx, m.store)
+	if err != nil {
+		return groupSet{}, err
+	}
+	unsubs, err := gmail.LoadUnsubscribes(ctx, m.store)
+	if err != nil {
+		return groupSet{}, err
+	}
+	if m.pageable() {
+		set, err := loadGroupWindow(ctx, m.store.(gmail.GroupPageStore), max(window, m.opts.PageSize))
+		set.senders, set.unsubs = senders, unsubs
+		return set, err
+	}
+	var groups []model.SenderGroup
+	if m.opts.LowMemory {
+		groups, err = gmail.LoadGroupSummariesFromDB(ctx, m.store)
+	} else {
+		groups, err = gmail.LoadGroupsFromDB(ctx, m.store)
+	}
+	if err != nil {
+		return groupSet{}, err
+	}
+	groups = m.opts.Subjects.Apply(groups)
+	groups = m.grouping.Apply(groups)
+	if ps, ok := m.store.(gmail.PinStore); ok {
+		pinned, err := ps.LoadPinnedGroups(ctx)
+		if err != nil {
+			return groupSet{}, err
+		}
+		gmail.ApplyPins(groups, pinned, m.opts.Sort)
+	} else {
+		gmail.SortGroupsBy(groups, m.opts.Sort)
+	}
+	return groupSet{groups: groups, senders: senders, unsubs: unsubs}, nil
+}
+
+// actionContext is the context the commands that change mail run under,
+// from rootContext; under a dry run they only describe themselves to
+// Options.Audit.
+func (m *AppModel) actionContext() context.Context {
+	if !m.opts.DryRun {
+		return m.rootContext()
+	}
+	return gmail.WithDryRun(m.rootContext(), m.opts.Audit)
+}
+
+func (m *AppModel) archiveCmd(g model.SenderGroup) tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		n, err := m.archiveGroup(ctx, g)
+		verb, action := "archive", "Archive"
+		if m.opts.Spam {
+			verb, action = "move to the inbox", "Moving to the inbox"
+		}
+		if gmail.IsDryRun(ctx) && err == nil {
+			return actionResultMsg{dryRun: fmt.Sprintf("%s %s from %s", verb, plural(n, "message"), g.Email)}
+		}
+		m.recordAction(model.Action{Kind: "archive", Sender: g.Email, Subject: g.Subject, Messages: n})
+		return actionResultMsg{action: action, err: err}
+	})
+}
+
+func (m *AppModel) trashCmd(g model.SenderGroup) tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		n, err := m.trashGroup(ctx, g)
+		verb, action := "trash", "Trash"
+		if m.opts.Spam {
+			verb, action = "delete for good", "Delete"
+		}
+		if gmail.IsDryRun(ctx) && err == nil {
+			return actionResultMsg{dryRun: fmt.Sprintf("%s %s from %s", verb, plural(n, "message"), g.Email)}
+		}
+		m.recordAction(model.Action{Kind: "trash", Sender: g.Email, Subject: g.Subject, Messages: n})
+		return actionResultMsg{action: action, err: err}
+	})
+}
+
+// markReadCmd marks the unread messages of g read and updates their cached
+// labels.
+func (m *AppModel) markReadCmd(g model.SenderGroup) tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		n := 0
+		err := m.forEachGroupIDBatch(ctx, g, func(ids []string) error {
+			unread, labels := ids, map[string][]string{}
+			if m.store != nil {
+				msgs, err := m.store.GetMessagesByIDs(ctx, ids)
+				if err != nil {
+					return err
+				}
+				unread = nil
+				for _, msg := range msgs {
+					if msg.Unread() {
+						unread = append(unread, msg.ID)
+						labels[msg.ID] = slices.DeleteFunc(slices.Clone(msg.LabelIDs), func(l string) bool { return l == "UNREAD" })
+					}
+				}
+			}
+			if len(unread) == 0 {
+				return nil
+			}
+			if err := m.mailbox.MarkRead(ctx, unread); err != nil && !errors.Is(err, gmail.ErrDryRun) {
+				return err
+			}
+			n += len(unread)
+			if ls, ok := m.store.(gmail.LabelStore); ok && !gmail.IsDryRun(ctx) {
+				return ls.UpdateLabels(ctx, labels)
+			}
+			return nil
+		})
+		return markedReadMsg{group: g, marked: n, err: err, dryRun: gmail.IsDryRun(ctx)}
+	})
+}
+
+// archiveGroup archives every message of g, or in spam review moves them
+// back to the inbox, drops them from the cache and returns how many it
+// archived.
+func (m *AppModel) archiveGroup(ctx context.Context, g model.SenderGroup) (int, error) {
+	n := 0
+	err := m.forEachGroupIDBatch(ctx, g, func(ids []string) error {
+		if err := m.archiveIDs(ctx, ids); err != nil && !errors.Is(err, gmail.ErrDryRun) {
+			return err
+		}
+		n += len(ids)
+		if m.store == nil || gmail.IsDryRun(ctx) {
+			return nil
 		}
-		return actionResultMsg{action: "Trash", err: err}
+		return gmail.CacheLabelRemoved(ctx, m.store, ids, m.archivedLabel())
+	})
+	return n, err
+}
+
+// trashGroup moves every message of g to the trash, or in spam review
+// deletes them, drops them from the cache and returns how many it trashed.
+func (m *AppModel) trashGroup(ctx context.Context, g model.SenderGroup) (int, error) {
+	n := 0
+	err := m.forEachGroupIDBatch(ctx, g, func(ids []string) error {
+		if err := m.trashIDs(ctx, ids); err != nil && !errors.Is(err, gmail.ErrDryRun) {
+			return err
+		}
+		n += len(ids)
+		if m.store == nil || gmail.IsDryRun(ctx) {
+			return nil
+		}
+		return m.store.DeleteMessages(ctx, ids)
+	})
+	return n, err
+}
+
+// renderBody fills the body viewport with the open message, keeping the
+// scroll position.
+func (m *AppModel) renderBody() {
+	header := ""
+	if m.selectedMsg != nil {
+		header = bodyHeader(m.selectedMsg.From, m.selectedMsg.Subject, m.selectedMsg.DateRFC3339) + "\n\n"
+	}
+	links, listed := gmail.ExtractLinks(m.body.Text)
+	m.links = links
+	m.bodyViewport.SetContent(header + renderInvites(m.body.Invites) + renderAttachments(m.body.Attachments, m.attachmentIdx) + m.body.Text + renderLinks(links, listed))
+}
+
+// openSavedAttachment opens the attachment just downloaded with the
+// system's application for it when yes, and otherwise only says where it
+// went.
+func (m *AppModel) openSavedAttachment(yes bool) tea.Cmd {
+	if !yes {
+		return m.toasts.Push("Saved " + m.savedPath)
+	}
+	if err := gmail.OpenFile(m.savedPath); err != nil {
+		return m.toasts.Push(fmt.Sprintf("Opening %s failed: %v", m.savedPath, err))
 	}
+	return m.toasts.Push("Opened " + m.savedPath)
 }
 
+// addInviteToCalendar writes the open message's invitation to the local
+// calendar file, replacing an earlier copy of the same event.
+func (m *AppModel) addInviteToCalendar() (tea.Model, tea.Cmd) {
+	if len(m.body.Invites) == 0 {
+		return m, m.toasts.Push("This message has no invitation")
+	}
+	if m.opts.CalendarFile == "" {
+		return m, m.toasts.Push("No calendar file configured")
+	}
+	ev := m.body.Invites[0]
+	path := m.opts.CalendarFile
+	return m, func() tea.Msg {
+		return inviteAddedMsg{summary: ev.Summary, path: path, err: ics.AddToFile(path, ev)}
+	}
+}
+
+func (m *AppModel) downloadSelectedAttachment() (tea.Model, tea.Cmd) {
+	if m.selectedMsg == nil || len(m.body.Attachments) == 0 {
+		return m, m.toasts.Push("This message has no attachments")
+	}
+	att := m.body.Attachments[m.attachmentIdx]
+	id := m.selectedMsg.ID
+	m.statusBar.Text = "Downloading " + att.Filename + "..."
+	return m, m.authGuard(func() tea.Msg {
+		dir, err := m.downloadDir()
+		if err != nil {
+			return attachmentSavedMsg{err: err}
+		}
+		path, err := m.mailbox.SaveAttachment(context.Background(), id, att, dir)
+		return attachmentSavedMsg{path: path, mimeType: att.MimeType, err: err}
+	})
+}
+
+// fetchBodyCmd reads a message body from memory or the offline cache,
+// fetching and caching it on a miss.
 func (m *AppModel) fetchBodyCmd(messageID string) tea.Cmd {
+	if body, ok := m.bodies.Get(messageID); ok {
+		return func() tea.Msg { return bodyFetchedMsg{id: messageID, body: body} }
+	}
+	return m.authGuard(func() tea.Msg {
+		body, err := m.loadBody(context.Background(), messageID)
+		return bodyFetchedMsg{id: messageID, body: body, err: err}
+	})
+}
+
+// loadBody returns a message body from the offline cache, or fetches it
+// and caches it there, and keeps it in memory either way.
+func (m *AppModel) loadBody(ctx context.Context, messageID string) (model.MessageBody, error) {
+	bs, cached := m.store.(gmail.BodyStore)
+	cached = cached && m.opts.BodyCacheBytes > 0
+	if cached {
+		if body, ok, err := bs.GetBody(ctx, messageID); err == nil && ok {
+			m.bodies.Add(messageID, body)
+			return body, nil
+		}
+	}
+	body, err := m.mailbox.Body(ctx, messageID)
+	if err != nil {
+		return body, err
+	}
+	m.bodies.Add(messageID, body)
+	if cached {
+		// A cache write failure only costs a refetch next time.
+		bs.PutBody(ctx, messageID, body, m.opts.BodyCacheBytes)
+	}
+	return body, nil
+}
+
+// Bodies fetched ahead when a group opens, and how many at once.
+const (
+	prefetchBodies  = 5
+	prefetchWorkers = 2
+)
+
+// prefetchCmd loads the bodies of the first messages of refs into the
+// caches in the background, so opening one shows it at once. Failures are
+// left for opening the message to report.
+func (m *AppModel) prefetchCmd(refs []model.MessageRef) tea.Cmd {
+	if m.bodies == nil && m.opts.BodyCacheBytes == 0 {
+		return nil
+	}
+	var ids []string
+	for _, r := range refs {
+		if len(ids) == prefetchBodies {
+			break
+		}
+		if _, ok := m.bodies.Get(r.ID); !ok && !model.IsLocalID(r.ID) {
+			ids = append(ids, r.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
 	return func() tea.Msg {
-		body, err := gmail.GetMessageBody(context.Background(), m.service, messageID)
-		return bodyFetchedMsg{body: body, err: err}
+		ctx := context.Background()
+		jobs := make(chan string)
+		var wg sync.WaitGroup
+		for range min(prefetchWorkers, len(ids)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for id := range jobs {
+					m.loadBody(ctx, id)
+				}
+			}()
+		}
+		for _, id := range ids {
+			jobs <- id
+		}
+		close(jobs)
+		wg.Wait()
+		return nil
 	}
 }
 
-func clearStatusAfter(d time.Duration) tea.Cmd {
-	return tea.Tick(d, func(time.Time) tea.Msg {
-		return statusMsg("")
-	})
+// authView asks for the sign-in the running auth flow needs.
+func (m *AppModel) authView() string {
+	if m.deviceCode != nil {
+		return fmt.Sprintf("On any device, open this URL and enter the code below:\n\n%s\n\n    %s\n\nWaiting for approval (the code expires at %s). Press q to quit.",
+			m.deviceCode.URL, m.deviceCode.Code, m.deviceCode.Expires.Format("15:04"))
+	}
+	return "Please open this URL in your browser to authenticate:\n\n" +
+		m.authURL + "\n\n" +
+		m.textInput.View()
 }
 
 // View renders the appropriate view based on current state.
 func (m *AppModel) View() string {
+	if m.view == viewSetup {
+		return m.setupView()
+	}
+
 	// Auth code input
 	if m.view == viewAuth {
-		return "Please open this URL in your browser to authenticate:\n\n" +
-			m.authURL + "\n\n" +
-			m.textInput.View()
+		if m.reauthing {
+			return "Your Gmail sign-in expired or was revoked. Sign in again to carry on where you left off.\n\n" + m.authView()
+		}
+		return m.authView()
 	}
 
 	// Error state
@@ -515,35 +2530,141 @@ func (m *AppModel) View() string {
 
 	// Loading/syncing
 	if m.view == viewLoading {
-		if m.status != "" {
-			return m.status + "\n"
+		text := "Loading..."
+		switch {
+		case m.meter.active():
+			text = m.meter.View(m.width)
+		case m.statusBar.Text != "":
+			text = m.statusBar.Text
 		}
-		return "Loading...\n"
+		if m.syncCancel != nil {
+			text += "\n\n" + m.footerStyle().Render("esc cancels the sync")
+		}
+		return text + "\n"
 	}
 
 	var b strings.Builder
+	footer := m.footerStyle()
 
+	switch {
+	case m.view == viewUnsubscribe:
+		b.WriteString(m.reportViewport.View())
+		b.WriteString("\n")
+	case m.view == viewStats:
+		b.WriteString(m.statsViewport.View())
+		b.WriteString("\n")
+	case m.view == viewRules:
+		b.WriteString(m.rulesList.View())
+		b.WriteString("\n")
+	case m.view == viewDrafts:
+		b.WriteString(m.draftsList.View())
+		b.WriteString("\n")
+	case m.view == viewAccounts:
+		b.WriteString(m.accountsList.View())
+		b.WriteString("\n")
+	case m.view == viewTrash:
+		b.WriteString(m.trashList.View())
+		b.WriteString("\n")
+	case m.view == viewProfile:
+		b.WriteString(renderProfile(m.profile, m.width))
+		b.WriteString("\n")
+		b.WriteString(m.profileList.View())
+		b.WriteString("\n")
+	case m.view == viewCompose:
+		b.WriteString(m.composer.View(m.width))
+		b.WriteString("\n")
+	case m.layout == layoutWide:
+		b.WriteString(m.wideView())
+		b.WriteString("\n")
+	case m.split() && (m.view == viewMessages || m.view == viewBody):
+		b.WriteString(m.splitView())
+		b.WriteString("\n")
+	}
 	switch m.view {
 	case viewGroups:
-		b.WriteString(m.groupsList.View())
-		b.WriteString("\n")
-		b.WriteString(groupsFooter())
+		if m.layout != layoutWide {
+			b.WriteString(m.groupsList.View())
+			b.WriteString("\n")
+			if m.detailVisible() {
+				if gi, ok := m.groupsList.SelectedItem().(groupItem); ok {
+					b.WriteString(renderGroupDetail(gi.SenderGroup, m.detailBuckets, m.width))
+					b.WriteString("\n")
+				}
+			}
+		}
+		b.WriteString(groupsFooter(footer, m.groupKeys()))
 	case viewMessages:
-		b.WriteString(m.messagesList.View())
-		b.WriteString("\n")
-		b.WriteString(messagesFooter())
+		if m.layout != layoutWide && !m.split() {
+			b.WriteString(m.messagesList.View())
+			b.WriteString("\n")
+		}
+		b.WriteString(messagesFooter(footer))
 	case viewBody:
-		b.WriteString(m.bodyViewport.View())
-		b.WriteString("\n")
-		b.WriteString(bodyFooter())
+		if m.layout != layoutWide && !m.split() {
+			b.WriteString(m.bodyViewport.View())
+			b.WriteString("\n")
+		}
+		b.WriteString(bodyFooter(footer))
+	case viewUnsubscribe:
+		b.WriteString(unsubscribeFooter(footer))
+	case viewStats:
+		b.WriteString(statsFooter(footer))
+	case viewRules:
+		b.WriteString(rulesFooter(footer))
+	case viewDrafts:
+		b.WriteString(draftsFooter(footer))
+	case viewAccounts:
+		b.WriteString(accountsFooter(footer))
+	case viewTrash:
+		b.WriteString(trashFooter(footer))
+	case viewProfile:
+		b.WriteString(profileFooter(footer, m.opts.Keys.profileKeys()))
+	case viewCompose:
+		b.WriteString(composeFooter(footer))
 	}
 
-	if m.status != "" {
-		b.WriteString("\n")
-		b.WriteString(m.status)
+	b.WriteString("\n")
+	b.WriteString(m.statusLine())
+
+	if m.showHelp {
+		return ui.Overlay(b.String(), ui.HelpView(m.helpSections()), m.width, m.height)
 	}
+	return ui.Overlay(b.String(), m.confirm.View(m.width), m.width, m.height)
+}
 
-	return b.String()
+// statusLine shows the date filter or search prompt while it is open, else
+// the current toast or status text beside the account and cache state.
+func (m *AppModel) statusLine() string {
+	if m.dateInput.Focused() {
+		return m.dateInput.View()
+	}
+	if m.searchInput.Focused() {
+		return m.searchInput.View()
+	}
+	if m.ruleInput.Focused() {
+		return m.ruleInput.View()
+	}
+	if m.snoozeInput.Focused() {
+		return m.snoozeInput.View()
+	}
+	if m.filterInput.Focused() {
+		return m.filterInput.View()
+	}
+	if m.linkInput.Focused() {
+		return m.linkInput.View()
+	}
+	right := m.statusRight(time.Now())
+	if toast, ok := m.toasts.Current(); ok {
+		return ui.StatusBar{Text: toast, Right: right}.View(m.width)
+	}
+	text := m.statusBar.Text
+	if m.visual && m.view == viewGroups && text == "" {
+		text = fmt.Sprintf("-- VISUAL -- %s selected; %s trashes them, V or esc ends", plural(len(m.visualGroups()), "group"), m.opts.Keys.Trash)
+	}
+	if m.meter.active() {
+		text = m.meter.View(m.width - lipgloss.Width(right) - 1)
+	}
+	return ui.StatusBar{Text: text, Right: right}.View(m.width)
 }
 
 // trimDate converts an RFC3339 timestamp to a short date string.
@@ -0,0 +1,232 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"chuckterm/internal/mailbox"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bodyProvider serves bodies and records which it was asked for. Its other
+// methods are not called.
+type bodyProvider struct {
+	mailbox.Provider
+	mu      sync.Mutex
+	fetched []string
+}
+
+func (p *bodyProvider) Body(ctx context.Context, id string) (model.MessageBody, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetched = append(p.fetched, id)
+	return model.MessageBody{Text: "body of " + id}, nil
+}
+
+func TestPrefetchBodies(t *testing.T) {
+	var refs []model.MessageRef
+	for _, id := range []string{"m1", model.LocalIDPrefix + "1", "m2", "m3", "m4", "m5", "m6", "m7"} {
+		refs = append(refs, model.MessageRef{ID: id})
+	}
+	for _, tc := range []struct {
+		name       string
+		memory     int
+		cacheBytes int64
+		want       []string // fetched by the first prefetch
+	}{
+		{"off", 0, 0, nil},
+		{"memory", 10, 0, []string{"m2", "m3", "m4", "m5", "m6"}},
+		{"disk", 0, 1 << 20, []string{"m1", "m2", "m3", "m4", "m5"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := store.NewMemoryStore()
+			m := NewAppModel(db, t.TempDir(), Options{BodyMemory: tc.memory, BodyCacheBytes: tc.cacheBytes})
+			p := &bodyProvider{}
+			m.mailbox = p
+			if m.bodies != nil {
+				// Already in memory, so not fetched again.
+				m.bodies.Add("m1", model.MessageBody{Text: "body of m1"})
+			}
+			cmd := m.prefetchCmd(refs)
+			if tc.want == nil {
+				if cmd != nil {
+					t.Fatal("prefetching with no cache to fill")
+				}
+				return
+			}
+			cmd()
+			slices.Sort(p.fetched)
+			if !slices.Equal(p.fetched, tc.want) {
+				t.Errorf("fetched %q; want %q", p.fetched, tc.want)
+			}
+			for _, id := range tc.want {
+				if m.bodies != nil {
+					if _, ok := m.bodies.Get(id); !ok {
+						t.Errorf("%s is not in memory", id)
+					}
+				}
+				if tc.cacheBytes > 0 {
+					if _, ok, err := db.GetBody(context.Background(), id); err != nil || !ok {
+						t.Errorf("%s is not in the body cache: %v", id, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPrefetchSkipsCachedBodies(t *testing.T) {
+	m := NewAppModel(store.NewMemoryStore(), t.TempDir(), Options{BodyMemory: 10})
+	m.mailbox = &bodyProvider{}
+	refs := []model.MessageRef{{ID: "m1"}, {ID: "m2"}}
+	m.prefetchCmd(refs)()
+	if cmd := m.prefetchCmd(refs); cmd != nil {
+		t.Error("prefetched bodies already in memory")
+	}
+}
+
+func TestSyncFinishedReloadsGroups(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemoryStore()
+	if err := db.UpsertMessages(ctx, []model.MessageRef{
+		{ID: "a1", From: "a@x.example", Subject: "A", DateRFC3339: "2024-01-01T00:00:00Z"},
+		{ID: "a2", From: "a@x.example", Subject: "A", DateRFC3339: "2024-01-02T00:00:00Z"},
+		{ID: "b1", From: "b@x.example", Subject: "B", DateRFC3339: "2024-01-03T00:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m := NewAppModel(db, t.TempDir(), Options{})
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	set, err := m.loadGroups(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.replaceGroups(set)
+	m.view = viewGroups
+	m.syncing = true
+	m.groupsList.Select(1)
+
+	// A failed sync is reported and leaves the list as it was.
+	m.Update(syncFinishedMsg{err: errors.New("offline")})
+	if m.syncing || m.syncErr == nil || !m.lastSync.IsZero() {
+		t.Errorf("after a failed sync: syncing %v, error %v, last sync %v", m.syncing, m.syncErr, m.lastSync)
+	}
+
+	// New mail arrives and the next sync finishes.
+	if err := db.UpsertMessages(ctx, []model.MessageRef{
+		{ID: "c1", From: "c@x.example", Subject: "C", DateRFC3339: "2024-01-04T00:00:00Z"},
+		{ID: "a3", From: "a@x.example", Subject: "A", DateRFC3339: "2024-01-05T00:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m.syncing = true
+	_, cmd := m.Update(syncFinishedMsg{})
+	if m.syncing || m.lastSync.IsZero() {
+		t.Errorf("after the sync: syncing %v, last sync %v", m.syncing, m.lastSync)
+	}
+	if cmd == nil {
+		t.Fatal("the groups were not reloaded")
+	}
+	msg, ok := cmd().(groupsRefreshedMsg)
+	if !ok {
+		t.Fatalf("reload returned %T", msg)
+	}
+	m.Update(msg)
+	if len(m.groups) != 3 || len(m.groupsList.Items()) != 3 {
+		t.Fatalf("%d groups, %d listed after the reload; want 3", len(m.groups), len(m.groupsList.Items()))
+	}
+	var total int
+	for _, g := range m.groups {
+		total += g.Count
+	}
+	if total != 5 {
+		t.Errorf("groups hold %d messages; want 5", total)
+	}
+	if got := m.groupsList.Index(); got != 1 {
+		t.Errorf("highlight moved to %d", got)
+	}
+	if m.view != viewGroups {
+		t.Errorf("view changed to %d", m.view)
+	}
+}
+
+func TestFullScanReloadsGroups(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemoryStore()
+	if err := db.UpsertMessages(ctx, []model.MessageRef{
+		{ID: "a1", From: "a@x.example", Subject: "A", DateRFC3339: "2024-01-01T00:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m := NewAppModel(db, t.TempDir(), Options{})
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	set, err := m.loadGroups(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Update(syncCompleteMsg{groups: set, background: true, scanning: true})
+	if !m.scanning || !m.syncing || m.view != viewGroups {
+		t.Fatalf("scan started: scanning %v, syncing %v, view %d", m.scanning, m.syncing, m.view)
+	}
+
+	// Progress soon after the groups were shown does not reload them.
+	if _, cmd := m.Update(syncProgressMsg{phase: "fetch", done: 1, total: 10}); cmd != nil {
+		t.Error("reloaded the groups before scanReloadEvery passed")
+	}
+
+	// The scan writes more mail; the next progress after scanReloadEvery
+	// reloads the groups, once.
+	if err := db.UpsertMessages(ctx, []model.MessageRef{
+		{ID: "b1", From: "b@x.example", Subject: "B", DateRFC3339: "2024-01-02T00:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m.scanShown = time.Now().Add(-scanReloadEvery)
+	_, cmd := m.Update(syncProgressMsg{phase: "fetch", done: 5, total: 10})
+	if cmd == nil || !m.scanLoading {
+		t.Fatal("the groups were not reloaded during the scan")
+	}
+	m.scanShown = time.Now().Add(-scanReloadEvery)
+	if _, again := m.Update(syncProgressMsg{phase: "fetch", done: 6, total: 10}); again != nil {
+		t.Error("reloaded the groups while a reload was under way")
+	}
+	msg, ok := cmd().(scanGroupsMsg)
+	if !ok {
+		t.Fatalf("reload returned %T", msg)
+	}
+	m.Update(msg)
+	if m.scanLoading || len(m.groupsList.Items()) != 2 {
+		t.Errorf("after the reload: loading %v, %d groups listed; want 2", m.scanLoading, len(m.groupsList.Items()))
+	}
+
+	// Neither a sync nor a push notification starts during the scan.
+	press(&m, m.opts.Keys.Sync)
+	if m.statusBar.Text == "Syncing..." {
+		t.Error("started a sync during the full scan")
+	}
+	if _, cmd := m.Update(pushNotifyMsg{}); cmd != nil || !m.pushPending {
+		t.Errorf("push notification during the scan: cmd %v, pending %v", cmd, m.pushPending)
+	}
+
+	// The scan ends and the notification that waited is synced.
+	_, cmd = m.Update(syncFinishedMsg{})
+	if m.scanning || m.syncing || cmd == nil {
+		t.Errorf("after the scan: scanning %v, syncing %v, cmd %v", m.scanning, m.syncing, cmd)
+	}
+	if m.pushPending || !m.pushSyncing {
+		t.Errorf("after the scan: push pending %v, push syncing %v", m.pushPending, m.pushSyncing)
+	}
+
+	// A reload that finishes after the scan is dropped.
+	m.Update(scanGroupsMsg{})
+	if len(m.groupsList.Items()) != 2 {
+		t.Errorf("a late reload left %d groups listed", len(m.groupsList.Items()))
+	}
+}
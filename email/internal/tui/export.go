@@ -0,0 +1,121 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chuckterm/internal/export"
+	"chuckterm/internal/gmail"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// downloadDir returns where attachments and exports are saved.
+func (m *AppModel) downloadDir() (string, error) {
+	if m.opts.DownloadDir != "" {
+		return m.opts.DownloadDir, nil
+	}
+	return gmail.DownloadsDir()
+}
+
+// exportSelectedGroup saves the highlighted group's mail to an mbox file in
+// the download directory, e.g. to keep it before trashing the group.
+func (m *AppModel) exportSelectedGroup() (tea.Model, tea.Cmd) {
+	selected := m.groupsList.SelectedItem()
+	if selected == nil {
+		return m, nil
+	}
+	g := selected.(groupItem).SenderGroup
+	m.statusBar.Text = fmt.Sprintf("Exporting %s...", plural(g.Count, "message"))
+	return m, func() tea.Msg {
+		ctx := context.Background()
+		var ids []string
+		err := m.forEachGroupIDBatch(ctx, g, func(batch []string) error {
+			ids = append(ids, batch...)
+			return nil
+		})
+		if err != nil {
+			return exportedMsg{err: err}
+		}
+		dir, err := m.downloadDir()
+		if err != nil {
+			return exportedMsg{err: err}
+		}
+		path, err := uniquePath(dir, exportStem(g.Email), ".mbox")
+		if err != nil {
+			return exportedMsg{err: err}
+		}
+		n, err := m.exportTo(ctx, path, export.Mbox, ids)
+		return exportedMsg{path: path, count: n, err: err}
+	}
+}
+
+// exportSelectedMessage saves the highlighted message as an .eml file in the
+// download directory.
+func (m *AppModel) exportSelectedMessage() (tea.Model, tea.Cmd) {
+	selected, ok := m.messagesList.SelectedItem().(messageItem)
+	if !ok {
+		return m, nil
+	}
+	id := selected.ID
+	m.statusBar.Text = "Exporting message..."
+	return m, func() tea.Msg {
+		dir, err := m.downloadDir()
+		if err != nil {
+			return exportedMsg{err: err}
+		}
+		n, err := m.exportTo(context.Background(), dir, export.EML, []string{id})
+		return exportedMsg{path: dir, count: n, err: err}
+	}
+}
+
+// exportTo writes the messages to a new export at path.
+func (m *AppModel) exportTo(ctx context.Context, path string, f export.Format, ids []string) (int, error) {
+	if m.service == nil {
+		return 0, errors.New("exporting needs a Gmail account")
+	}
+	w, err := export.Create(path, f)
+	if err != nil {
+		return 0, err
+	}
+	n, err := gmail.ExportMessages(ctx, m.service, w, ids, nil)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// exportStem names a group's export after its sender and today's date.
+func exportStem(sender string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, sender)
+	if name == "" {
+		name = "messages"
+	}
+	return name + "-" + time.Now().Format("2006-01-02")
+}
+
+// uniquePath returns dir/stem+ext, or dir/stem (n)+ext for the first n
+// that is not taken yet.
+func uniquePath(dir, stem, ext string) (string, error) {
+	for n := 0; n < 1000; n++ {
+		name := stem + ext
+		if n > 0 {
+			name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+		}
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("too many files named %s%s in %s", stem, ext, dir)
+}
@@ -0,0 +1,189 @@
+package tui
+
+import (
+	"fmt"
+
+	"common/ui"
+)
+
+// Keymap holds the keys of the groups-view actions that users may remap.
+// An empty field keeps the default key.
+type Keymap struct {
+	Archive        string
+	Trash          string
+	Read           string
+	Unsubscribe    string
+	UnsubscribeAll string
+	Queue          string
+	Pin            string
+	Sort           string
+	Domains        string
+	Dates          string
+	Details        string
+	Sync           string
+	Export         string
+	Stats          string
+	Protect        string
+	Block          string
+	Rules          string
+	Filter         string
+	Drafts         string
+	Accounts       string
+	Trashed        string
+	Sender         string
+	Bulk           string
+	// Profile is "vim" for vim-style navigation and visual selection in
+	// the groups view; empty or "default" for the list's own keys.
+	Profile string
+}
+
+// ProfileVim adds gg and G to jump to the first and last group and V to
+// select a range of groups, and makes d the trash key.
+const ProfileVim = "vim"
+
+// vimKeys are the fixed keys the vim profile adds to the groups view.
+var vimKeys = []ui.Key{
+	{Keys: "gg/G", Help: "first/last"},
+	{Keys: "V", Help: "select range"},
+}
+
+// DefaultKeymap is the built-in binding of the remappable actions.
+var DefaultKeymap = Keymap{
+	Archive:        "e",
+	Trash:          "#",
+	Read:           "r",
+	Unsubscribe:    "u",
+	UnsubscribeAll: "U",
+	Queue:          "Q",
+	Pin:            "p",
+	Sort:           "o",
+	Domains:        "D",
+	Dates:          "t",
+	Details:        "i",
+	Sync:           "s",
+	Export:         "x",
+	Stats:          "S",
+	Protect:        "P",
+	Block:          "B",
+	Rules:          "R",
+	Filter:         "F",
+	Drafts:         "W",
+	Accounts:       "A",
+	Trashed:        "T",
+	Sender:         "f",
+	Bulk:           "b",
+}
+
+// reservedKeys are the fixed keys of the groups view and the navigation
+// keys of its list.
+var reservedKeys = []string{
+	"enter", "q", "esc", "ctrl+c", "ctrl+x", "/", "?",
+	"up", "down", "j", "k", "left", "right", "h", "l",
+	"pgup", "pgdown", "home", "end", "g", "G",
+}
+
+// withDefaults fills unset fields from DefaultKeymap, after the vim profile
+// has bound trash to d.
+func (k Keymap) withDefaults() Keymap {
+	if k.Profile == ProfileVim && k.Trash == "" {
+		k.Trash = "d"
+	}
+	for _, f := range []struct {
+		v   *string
+		def string
+	}{
+		{&k.Archive, DefaultKeymap.Archive},
+		{&k.Trash, DefaultKeymap.Trash},
+		{&k.Read, DefaultKeymap.Read},
+		{&k.Unsubscribe, DefaultKeymap.Unsubscribe},
+		{&k.UnsubscribeAll, DefaultKeymap.UnsubscribeAll},
+		{&k.Queue, DefaultKeymap.Queue},
+		{&k.Pin, DefaultKeymap.Pin},
+		{&k.Sort, DefaultKeymap.Sort},
+		{&k.Domains, DefaultKeymap.Domains},
+		{&k.Dates, DefaultKeymap.Dates},
+		{&k.Details, DefaultKeymap.Details},
+		{&k.Sync, DefaultKeymap.Sync},
+		{&k.Export, DefaultKeymap.Export},
+		{&k.Stats, DefaultKeymap.Stats},
+		{&k.Protect, DefaultKeymap.Protect},
+		{&k.Block, DefaultKeymap.Block},
+		{&k.Rules, DefaultKeymap.Rules},
+		{&k.Filter, DefaultKeymap.Filter},
+		{&k.Drafts, DefaultKeymap.Drafts},
+		{&k.Accounts, DefaultKeymap.Accounts},
+		{&k.Trashed, DefaultKeymap.Trashed},
+		{&k.Sender, DefaultKeymap.Sender},
+		{&k.Bulk, DefaultKeymap.Bulk},
+	} {
+		if *f.v == "" {
+			*f.v = f.def
+		}
+	}
+	return k
+}
+
+// Validate reports an unknown profile, or a key bound to two actions or to
+// a fixed key.
+func (k Keymap) Validate() error {
+	switch k.Profile {
+	case "", "default", ProfileVim:
+	default:
+		return fmt.Errorf("unknown profile %q; use default or vim", k.Profile)
+	}
+	k = k.withDefaults()
+	seen := map[string]string{}
+	reserved := reservedKeys
+	if k.Profile == ProfileVim {
+		reserved = append(reserved[:len(reserved):len(reserved)], "V")
+	}
+	for _, r := range reserved {
+		seen[r] = "a built-in key"
+	}
+	for _, b := range k.bindings() {
+		if other, ok := seen[b.Keys]; ok {
+			return fmt.Errorf("key %q is bound to both %s and %s", b.Keys, other, b.Help)
+		}
+		seen[b.Keys] = b.Help
+	}
+	return nil
+}
+
+// bindings lists the remappable actions with their help text.
+func (k Keymap) bindings() []ui.Key {
+	return []ui.Key{
+		{Keys: k.Archive, Help: "archive"},
+		{Keys: k.Trash, Help: "trash"},
+		{Keys: k.Read, Help: "mark read"},
+		{Keys: k.Unsubscribe, Help: "unsubscribe"},
+		{Keys: k.UnsubscribeAll, Help: "unsubscribe all"},
+		{Keys: k.Queue, Help: "queue unsubscribe"},
+		{Keys: k.Pin, Help: "pin"},
+		{Keys: k.Sort, Help: "sort"},
+		{Keys: k.Domains, Help: "grouping"},
+		{Keys: k.Dates, Help: "date filter"},
+		{Keys: k.Details, Help: "details"},
+		{Keys: k.Sync, Help: "sync"},
+		{Keys: k.Export, Help: "export"},
+		{Keys: k.Stats, Help: "stats"},
+		{Keys: k.Protect, Help: "protect"},
+		{Keys: k.Block, Help: "block"},
+		{Keys: k.Rules, Help: "rules"},
+		{Keys: k.Filter, Help: "gmail filter"},
+		{Keys: k.Drafts, Help: "drafts"},
+		{Keys: k.Accounts, Help: "accounts"},
+		{Keys: k.Trashed, Help: "trashed mail"},
+		{Keys: k.Sender, Help: "sender profile"},
+		{Keys: k.Bulk, Help: "bulk mail only"},
+	}
+}
+
+// groupKeys are the bindings of the groups view under k.
+func (k Keymap) groupKeys() []ui.Key {
+	keys := []ui.Key{{Keys: "enter", Help: "open"}}
+	if k.Profile == ProfileVim {
+		keys = append(keys, vimKeys...)
+	}
+	keys = append(keys, k.bindings()...)
+	return append(keys, ui.Key{Keys: "ctrl+x", Help: "cancel sync"}, ui.Key{Keys: "q", Help: "quit"})
+}
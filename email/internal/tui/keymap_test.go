@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeymapValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		keys Keymap
+		err  string
+	}{
+		{"defaults", Keymap{}, ""},
+		{"vim defaults", Keymap{Profile: ProfileVim}, ""},
+		{"remapped", Keymap{Archive: "a", Trash: "d"}, ""},
+		{"unknown profile", Keymap{Profile: "emacs"}, `unknown profile "emacs"`},
+		{"duplicate", Keymap{Archive: "x"}, `key "x" is bound to both archive and export`},
+		{"duplicate of a default", Keymap{Pin: "e"}, `key "e" is bound to both archive and pin`},
+		{"built-in", Keymap{Archive: "q"}, `key "q" is bound to both a built-in key and archive`},
+		{"list cursor", Keymap{Archive: "j"}, `key "j" is bound to both a built-in key and archive`},
+		{"list page", Keymap{Trash: "pgdown"}, `key "pgdown" is bound to both a built-in key and trash`},
+		{"list start", Keymap{Read: "g"}, `key "g" is bound to both a built-in key and mark read`},
+		{"list end", Keymap{Read: "end"}, `key "end" is bound to both a built-in key and mark read`},
+		{"list prev page", Keymap{Sort: "h"}, `key "h" is bound to both a built-in key and sort`},
+		{"vim trash", Keymap{Profile: ProfileVim, Archive: "d"}, `key "d" is bound to both archive and trash`},
+		{"vim visual", Keymap{Profile: ProfileVim, Pin: "V"}, `key "V" is bound to both a built-in key and pin`},
+		{"V outside vim", Keymap{Pin: "V"}, ""},
+	} {
+		err := tc.keys.Validate()
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: error %v; want %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestKeymapWithDefaults(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		keys Keymap
+		want func(Keymap) Keymap
+	}{
+		{"empty", Keymap{}, func(k Keymap) Keymap { return k }},
+		{"set fields kept", Keymap{Archive: "a", Sync: "y"}, func(k Keymap) Keymap {
+			k.Archive, k.Sync = "a", "y"
+			return k
+		}},
+		{"vim trash", Keymap{Profile: ProfileVim}, func(k Keymap) Keymap {
+			k.Profile, k.Trash = ProfileVim, "d"
+			return k
+		}},
+		{"vim keeps a set trash", Keymap{Profile: ProfileVim, Trash: "X"}, func(k Keymap) Keymap {
+			k.Profile, k.Trash = ProfileVim, "X"
+			return k
+		}},
+	} {
+		if got, want := tc.keys.withDefaults(), tc.want(DefaultKeymap); got != want {
+			t.Errorf("%s: got %+v; want %+v", tc.name, got, want)
+		}
+	}
+	for _, b := range (Keymap{}).withDefaults().bindings() {
+		if b.Keys == "" {
+			t.Errorf("%s has no key", b.Help)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+
+	"common/ui"
+)
+
+// layoutMode is chosen from the terminal size on every WindowSizeMsg.
+type layoutMode int
+
+const (
+	layoutSingle  layoutMode = iota // one view at a time (default)
+	layoutCompact                   // one view, single-line items, terse footer
+	layoutWide                      // groups, messages and body side by side
+)
+
+const (
+	compactMaxWidth  = 80  // narrower terminals use the compact layout
+	compactMaxHeight = 20  // as do shorter ones
+	wideMinWidth     = 160 // wider terminals get three panes
+)
+
+func layoutFor(width, height int) layoutMode {
+	switch {
+	case width < compactMaxWidth || height < compactMaxHeight:
+		return layoutCompact
+	case width >= wideMinWidth:
+		return layoutWide
+	}
+	return layoutSingle
+}
+
+// paneWidths splits the terminal width 30/30/40 between the groups,
+// messages and body panes, giving any rounding remainder to the body.
+func paneWidths(width int) (groups, messages, body int) {
+	groups = width * 3 / 10
+	messages = width * 3 / 10
+	return groups, messages, width - groups - messages
+}
+
+var placeholderStyle = ui.MutedStyle.Padding(1, 2)
+
+// footerStyle returns the footer style for the current layout, cut to the
+// terminal width; compact footers also drop the blank line above them.
+func (m *AppModel) footerStyle() lipgloss.Style {
+	if m.layout == layoutCompact {
+		return ui.CompactFooterStyle.MaxWidth(m.width)
+	}
+	return ui.FooterStyle.MaxWidth(m.width)
+}
+
+// chromeHeight is the number of lines below the main content: the footer
+// plus one line reserved for the status message.
+func (m *AppModel) chromeHeight() int {
+	return lipgloss.Height(m.footerStyle().Render("x")) + 1
+}
+
+// pane frames content at the given outer size, highlighting the focused pane.
+func pane(content string, width, height int, focused bool) string {
+	style := ui.PaneStyle
+	if focused {
+		style = ui.FocusedPaneStyle
+	}
+	inner := lipgloss.NewStyle().Width(width - 2).Height(height - 2).MaxWidth(width - 2).MaxHeight(height - 2)
+	return style.Render(inner.Render(content))
+}
+
+// wideView renders the three-pane layout.
+func (m *AppModel) wideView() string {
+	gw, mw, bw := paneWidths(m.width)
+	h := m.height - m.chromeHeight()
+
+	groups := m.groupsList.View()
+	if m.showDetail {
+		if gi, ok := m.groupsList.SelectedItem().(groupItem); ok {
+			groups += "\n" + renderGroupDetail(gi.SenderGroup, m.detailBuckets, gw-2)
+		}
+	}
+	messages := m.messagesList.View()
+	body := placeholderStyle.Render("Press enter on a message to read it here.")
+	if m.bodyShown {
+		body = m.bodyViewport.View()
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Top,
+		pane(groups, gw, h, m.view == viewGroups),
+		pane(messages, mw, h, m.view == viewMessages),
+		pane(body, bw, h, m.view == viewBody),
+	)
+}
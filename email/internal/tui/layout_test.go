@@ -0,0 +1,46 @@
+package tui
+
+import "testing"
+
+func TestLayoutFor(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		want          layoutMode
+	}{
+		{40, 12, layoutCompact},
+		{compactMaxWidth - 1, 50, layoutCompact},
+		{compactMaxWidth, 50, layoutSingle},
+		{120, compactMaxHeight - 1, layoutCompact},
+		{120, compactMaxHeight, layoutSingle},
+		{wideMinWidth - 1, 50, layoutSingle},
+		{wideMinWidth, 50, layoutWide},
+		{240, 60, layoutWide},
+		// A short terminal stays compact however wide it is.
+		{240, compactMaxHeight - 1, layoutCompact},
+	} {
+		if got := layoutFor(tc.width, tc.height); got != tc.want {
+			t.Errorf("layoutFor(%d, %d) = %d; want %d", tc.width, tc.height, got, tc.want)
+		}
+	}
+}
+
+func TestPaneWidths(t *testing.T) {
+	for _, tc := range []struct {
+		width                  int
+		groups, messages, body int
+	}{
+		{wideMinWidth, 48, 48, 64},
+		{161, 48, 48, 65},
+		{169, 50, 50, 69},
+		{200, 60, 60, 80},
+		{333, 99, 99, 135},
+	} {
+		g, m, b := paneWidths(tc.width)
+		if g != tc.groups || m != tc.messages || b != tc.body {
+			t.Errorf("paneWidths(%d) = %d, %d, %d; want %d, %d, %d", tc.width, g, m, b, tc.groups, tc.messages, tc.body)
+		}
+		if g+m+b != tc.width {
+			t.Errorf("paneWidths(%d) adds up to %d", tc.width, g+m+b)
+		}
+	}
+}
@@ -1,22 +1,247 @@
 package tui
 
-import "chuckterm/internal/model"
+import (
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"chuckterm/internal/report"
+)
 
 // Async message types for Bubble Tea commands.
 
 type syncCompleteMsg struct {
-	groups []model.SenderGroup
+	groups     groupSet
+	lastSync   time.Time // when the cache was last brought up to date
+	background bool      // a sync continues; syncFinishedMsg ends it
+	scanning   bool      // the sync that continues is a full scan
+	cancelled  bool      // the sync was cancelled; groups are what it cached
+	err        error
+}
+
+// scanGroupsMsg carries the groups reloaded while a full scan runs.
+type scanGroupsMsg struct {
+	groups groupSet
 	err    error
 }
 
-type actionResultMsg struct {
-	action string // "archive", "trash", "unsubscribe"
+// syncFinishedMsg ends the sync syncCmd leaves running after showing the
+// cached groups.
+type syncFinishedMsg struct {
+	err error
+}
+
+// groupsRefreshedMsg carries the groups reloaded after the background sync.
+type groupsRefreshedMsg struct {
+	groups groupSet
 	err    error
 }
 
+// accountMsg carries the address of the authenticated mailbox.
+type accountMsg string
+
+// statusTickMsg refreshes the age of the last sync in the status bar.
+type statusTickMsg struct{}
+
+type actionResultMsg struct {
+	action       string // "archive", "trash", "unsubscribe"
+	err          error
+	unsubscribed bool   // an unsubscribe was recorded
+	dryRun       string // what a dry run would have done instead
+}
+
 type bodyFetchedMsg struct {
-	body string
+	id      string
+	body    model.MessageBody
+	err     error
+	preview bool // fetched for the preview pane rather than opened
+}
+
+// previewTickMsg fires once the highlight has rested on a message for
+// previewDelay.
+type previewTickMsg struct {
+	id string
+}
+
+type attachmentSavedMsg struct {
+	path     string
+	mimeType string // as the message declared it
+	err      error
+}
+
+type exportedMsg struct {
+	path  string
+	count int
+	err   error
+}
+
+type inviteAddedMsg struct {
+	summary, path string
+	err           error
+}
+
+type statsLoadedMsg struct {
+	stats report.Stats
+	err   error
+}
+
+// unsubItemDoneMsg reports one sender of the unsubscribe queue.
+type unsubItemDoneMsg struct {
+	sender  string
+	outcome gmail.UnsubscribeOutcome
+	err     error
+}
+
+// pushNotifyMsg signals that Gmail reported a mailbox change.
+type pushNotifyMsg struct{}
+
+// rulesRanMsg reports a RunRules pass and, after a sync, the snoozed
+// messages it put back. groups is set when either changed the cache and the
+// groups were reloaded.
+type rulesRanMsg struct {
+	results []gmail.RuleResult
+	groups  *groupSet
+	manual  bool
+	err     error
+	woken   int
+	wakeErr error
+}
+
+// snoozedMsg reports a snoozed message, with the groups reloaded after it
+// left the cache.
+type snoozedMsg struct {
+	until  time.Time
+	groups *groupSet
+	err    error
+	dryRun bool // nothing was snoozed
+}
+
+// trashLoadedMsg carries the trash log, or Gmail's trash when live.
+type trashLoadedMsg struct {
+	msgs []model.Trashed
+	live bool
 	err  error
 }
 
-type statusMsg string
+// profileLoadedMsg carries the subject groups of a sender for its profile;
+// name is the display name the groups list showed.
+type profileLoadedMsg struct {
+	email, name string
+	groups      []model.SenderGroup
+	err         error
+}
+
+// untrashedMsg reports messages restored from the trash, with the groups
+// reloaded after they came back to the cache.
+type untrashedMsg struct {
+	restored int
+	groups   *groupSet
+	err      error
+	dryRun   bool // nothing was restored
+}
+
+// filterCreatedMsg reports the Gmail filter made for a sender.
+type filterCreatedMsg struct {
+	sender string
+	action gmail.FilterAction
+	err    error
+	dryRun bool // no filter was made
+}
+
+// markedReadMsg reports the messages of a group marked read.
+type markedReadMsg struct {
+	group  model.SenderGroup
+	marked int
+	err    error
+	dryRun bool // nothing was marked
+}
+
+// blockedMsg reports the mail of a newly blocked sender trashed.
+type blockedMsg struct {
+	group   model.SenderGroup
+	trashed int
+	err     error
+	dryRun  bool // nothing was trashed
+}
+
+// filtersRemovedMsg reports the Gmail filters removed when a sender was
+// unblocked.
+type filtersRemovedMsg struct {
+	sender  string
+	removed int
+	err     error
+}
+
+// accountsLoadedMsg carries the configured accounts and their caches.
+type accountsLoadedMsg struct {
+	accounts []Account
+	err      error
+}
+
+// draftsLoadedMsg carries the account's Gmail drafts.
+type draftsLoadedMsg struct {
+	drafts []gmail.Draft
+	err    error
+}
+
+// draftSavedMsg reports the compose form saved to Drafts on closing; draft
+// has the ID Gmail gave it.
+type draftSavedMsg struct {
+	draft  gmail.Draft
+	err    error
+	dryRun bool // nothing was saved
+}
+
+// draftSentMsg reports a message sent from the compose form.
+type draftSentMsg struct {
+	draft  gmail.Draft
+	err    error
+	dryRun bool // nothing was sent
+}
+
+// draftDeletedMsg reports a draft deleted from the drafts view.
+type draftDeletedMsg struct {
+	draft  gmail.Draft
+	err    error
+	dryRun bool // nothing was deleted
+}
+
+// signatureLoadedMsg carries the signature set in Gmail's settings.
+type signatureLoadedMsg struct {
+	text string
+	err  error
+}
+
+type pushSyncedMsg struct {
+	groups groupSet
+	err    error
+}
+
+// regroupedMsg carries the groups reloaded after the grouping changed from
+// previous, which is restored when reloading fails.
+type regroupedMsg struct {
+	groups   groupSet
+	previous gmail.GroupMode
+	err      error
+}
+
+// moreGroupsMsg carries the page of groups fetched from offset as the groups
+// list was scrolled.
+type moreGroupsMsg struct {
+	offset int
+	groups []model.SenderGroup
+	err    error
+}
+
+// moreMessagesMsg carries the page of the open group's messages fetched
+// after the message with ID after as the messages list was scrolled.
+type moreMessagesMsg struct {
+	key   model.GroupKey
+	after string
+	msgs  []model.MessageRef
+	err   error
+}
+
+type pushStoppedMsg struct {
+	err error
+}
@@ -0,0 +1,219 @@
+package tui
+
+import (
+	"context"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// groupPageMargin is how close to the last loaded group the highlight gets
+// before the next page is fetched.
+const groupPageMargin = 20
+
+// groupSet is what loadGroups returns: every group, or when paged the first
+// window of them and the totals of the whole cache.
+type groupSet struct {
+	groups  []model.SenderGroup
+	paged   bool
+	totals  model.GroupTotals
+	senders gmail.SenderLists
+	unsubs  map[string]model.Unsubscription
+}
+
+// pageable reports whether the groups can be loaded a page at a time: the
+// store pages them, and they are listed as it pages them, by sender and
+// subject in count order without a date filter.
+func (m *AppModel) pageable() bool {
+	if m.opts.PageSize <= 0 || (m.grouping != "" && m.grouping != gmail.GroupBySubject) || m.dateFilter.Active() || m.bulkOnly {
+		return false
+	}
+	if m.opts.Subjects != "" && m.opts.Subjects != gmail.SubjectsExact {
+		return false
+	}
+	if m.opts.Sort != "" && m.opts.Sort != gmail.OrderCount {
+		return false
+	}
+	_, ok := m.store.(gmail.GroupPageStore)
+	return ok
+}
+
+// loadGroupWindow loads the first window groups of the store.
+func loadGroupWindow(ctx context.Context, ps gmail.GroupPageStore, window int) (groupSet, error) {
+	totals, err := ps.GroupTotals(ctx)
+	if err != nil {
+		return groupSet{}, err
+	}
+	groups, err := gmail.LoadGroupPage(ctx, ps, 0, window)
+	if err != nil {
+		return groupSet{}, err
+	}
+	return groupSet{groups: groups, paged: true, totals: totals}, nil
+}
+
+// setGroups replaces m.groups with a loaded set.
+func (m *AppModel) setGroups(set groupSet) {
+	m.groups = set.groups
+	m.senders = set.senders
+	m.unsubs = set.unsubs
+	m.groupsOffset = len(set.groups)
+	m.unloaded = model.GroupTotals{}
+	m.loadingMore = false
+	if m.visual {
+		// The rows of the selection may have moved.
+		m.setVisual(false)
+	}
+	if set.paged {
+		m.unloaded = set.totals
+		m.countLoaded(set.groups)
+	}
+}
+
+// moreGroups reports whether the store holds groups that are not loaded.
+func (m *AppModel) moreGroups() bool {
+	return m.unloaded.Groups > 0
+}
+
+// countLoaded takes newly loaded groups off m.unloaded.
+func (m *AppModel) countLoaded(groups []model.SenderGroup) {
+	for _, g := range groups {
+		m.unloaded.Groups--
+		m.unloaded.Messages -= g.Count
+		m.unloaded.Unread -= g.Unread
+	}
+	if m.unloaded.Groups <= 0 {
+		m.unloaded = model.GroupTotals{}
+	}
+}
+
+// appendGroups adds a page fetched from m.groupsOffset, skipping groups that
+// are already loaded. A short page means the store has no more.
+func (m *AppModel) appendGroups(page []model.SenderGroup) {
+	loaded := make(map[model.GroupKey]bool, len(m.groups))
+	for _, g := range m.groups {
+		loaded[model.GroupKey{Email: g.Email, Subject: g.Subject}] = true
+	}
+	for _, g := range page {
+		if !loaded[model.GroupKey{Email: g.Email, Subject: g.Subject}] {
+			m.groups = append(m.groups, g)
+		}
+	}
+	m.groupsOffset += len(page)
+	m.countLoaded(page)
+	if len(page) < m.opts.PageSize {
+		m.unloaded = model.GroupTotals{}
+	}
+}
+
+// loadMoreCmd fetches the next page once the highlight is within
+// groupPageMargin of the last loaded group.
+func (m *AppModel) loadMoreCmd() tea.Cmd {
+	if !m.moreGroups() || m.loadingMore || m.groupsList.FilterState() != list.Unfiltered {
+		return nil
+	}
+	if m.groupsList.Index() < len(m.groupsList.Items())-groupPageMargin {
+		return nil
+	}
+	ps, ok := m.store.(gmail.GroupPageStore)
+	if !ok {
+		return nil
+	}
+	m.loadingMore = true
+	offset, limit := m.groupsOffset, m.opts.PageSize
+	return func() tea.Msg {
+		groups, err := gmail.LoadGroupPage(context.Background(), ps, offset, limit)
+		return moreGroupsMsg{offset: offset, groups: groups, err: err}
+	}
+}
+
+// loadRemainingGroups loads every group not loaded yet, for the actions that
+// work on the whole list: other orders, date filters, the list filter and
+// bulk unsubscribe.
+func (m *AppModel) loadRemainingGroups() error {
+	if !m.moreGroups() {
+		return nil
+	}
+	ps, ok := m.store.(gmail.GroupPageStore)
+	if !ok {
+		return nil
+	}
+	rest, err := gmail.LoadGroupPage(context.Background(), ps, m.groupsOffset, -1)
+	if err != nil {
+		return err
+	}
+	m.appendGroups(rest)
+	m.unloaded = model.GroupTotals{}
+	m.showGroups()
+	return nil
+}
+
+// dropTrailingGroup unloads the group with the given key when it was just
+// unpinned and sorted to the end of a partial list: unloaded groups may
+// belong before it, so it waits to be paged in again.
+func (m *AppModel) dropTrailingGroup(key model.GroupKey) {
+	n := len(m.groups)
+	if !m.moreGroups() || n == 0 {
+		return
+	}
+	last := m.groups[n-1]
+	if last.Pinned || last.Email != key.Email || last.Subject != key.Subject {
+		return
+	}
+	m.groups = m.groups[:n-1]
+	m.groupsOffset--
+	m.unloaded.Groups++
+	m.unloaded.Messages += last.Count
+	m.unloaded.Unread += last.Unread
+}
+
+// loadMoreMessagesCmd fetches the next groupMessagePage messages of the open
+// group once the highlight is within groupPageMargin of the last loaded one.
+func (m *AppModel) loadMoreMessagesCmd() tea.Cmd {
+	if !m.msgsMore || m.loadingMsgs || m.searchApplied || len(m.groupMsgs) == 0 {
+		return nil
+	}
+	if m.messagesList.Index() < len(m.messagesList.Items())-groupPageMargin {
+		return nil
+	}
+	gs, ok := m.store.(gmail.GroupSummaryStore)
+	if !ok {
+		return nil
+	}
+	m.loadingMsgs = true
+	g, after := m.msgsGroup, m.groupMsgs[len(m.groupMsgs)-1]
+	key := model.GroupKey{Email: g.Email, Subject: g.Subject}
+	return func() tea.Msg {
+		msgs, err := groupMessages(context.Background(), gs, g, after, groupMessagePage)
+		return moreMessagesMsg{key: key, after: after.ID, msgs: msgs, err: err}
+	}
+}
+
+// appendMessages adds a page of the open group's messages to the end of the
+// list, keeping the highlight. A short page means the store has no more.
+func (m *AppModel) appendMessages(page []model.MessageRef) {
+	m.groupMsgs = append(m.groupMsgs, page...)
+	m.msgsMore = len(page) == groupMessagePage
+	m.setGroupTitle()
+	m.messagesList.SetItems(sortedMessageItems(m.groupMsgs))
+	m.messagesList.Title = m.groupTitle
+}
+
+// loadRemainingMessages loads every message of the open group not loaded
+// yet, for the search, which looks through them all.
+func (m *AppModel) loadRemainingMessages() error {
+	gs, ok := m.store.(gmail.GroupSummaryStore)
+	if !m.msgsMore || !ok || len(m.groupMsgs) == 0 {
+		return nil
+	}
+	rest, err := groupMessages(context.Background(), gs, m.msgsGroup, m.groupMsgs[len(m.groupMsgs)-1], 0)
+	if err != nil {
+		return err
+	}
+	m.groupMsgs = append(m.groupMsgs, rest...)
+	m.msgsMore, m.loadingMsgs = false, false
+	m.setGroupTitle()
+	return nil
+}
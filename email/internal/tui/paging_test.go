@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+)
+
+func TestMessagesPageIn(t *testing.T) {
+	db, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	total := groupMessagePage + groupMessagePage/2
+	msgs := make([]model.MessageRef, total)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range msgs {
+		msgs[i] = model.MessageRef{
+			ID:          fmt.Sprintf("m%05d", i),
+			From:        "news@shop.example",
+			Subject:     "Sale",
+			DateRFC3339: start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+		}
+	}
+	ctx := context.Background()
+	if err := db.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewAppModel(db, t.TempDir(), Options{LowMemory: true})
+	m.showGroupMessages(model.SenderGroup{Email: "news@shop.example", Subject: "Sale", Count: total})
+	if len(m.groupMsgs) != groupMessagePage || !m.msgsMore {
+		t.Fatalf("first page: %d messages, more %v", len(m.groupMsgs), m.msgsMore)
+	}
+	if cmd := m.loadMoreMessagesCmd(); cmd != nil {
+		t.Fatal("paged in with the highlight at the top")
+	}
+
+	m.messagesList.Select(groupMessagePage - 1)
+	cmd := m.loadMoreMessagesCmd()
+	if cmd == nil {
+		t.Fatal("no page loaded at the end of the list")
+	}
+	if m.loadMoreMessagesCmd() != nil {
+		t.Fatal("a second page was requested while the first loads")
+	}
+	next, _ := m.Update(cmd())
+	m = *next.(*AppModel)
+	if len(m.groupMsgs) != total || m.msgsMore || m.loadingMsgs {
+		t.Fatalf("after paging: %d messages, more %v, loading %v", len(m.groupMsgs), m.msgsMore, m.loadingMsgs)
+	}
+	if got := len(m.messagesList.Items()); got != total {
+		t.Errorf("list holds %d messages; want %d", got, total)
+	}
+	if got := m.messagesList.Index(); got != groupMessagePage-1 {
+		t.Errorf("highlight moved to %d", got)
+	}
+	seen := make(map[string]bool, total)
+	for i, r := range m.groupMsgs {
+		if seen[r.ID] {
+			t.Fatalf("%s listed twice", r.ID)
+		}
+		seen[r.ID] = true
+		if i > 0 && r.DateRFC3339 > m.groupMsgs[i-1].DateRFC3339 {
+			t.Fatalf("%s is out of order", r.ID)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"context"
+
+	"chuckterm/internal/gmail"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// readOnlyNotice is shown for the keys read-only mode refuses.
+const readOnlyNotice = "Read-only mode: chuckterm changes no mail"
+
+// changesMail reports whether key starts, in the current view, an action
+// that changes mail or sends some, which read-only mode refuses. A dry run
+// changes nothing, so under one every key works, and keys typed into a
+// list's filter are only text.
+func (m *AppModel) changesMail(key string) bool {
+	if !m.opts.ReadOnly || m.opts.DryRun {
+		return false
+	}
+	switch m.view {
+	case viewGroups:
+		if m.groupsList.FilterState() == list.Filtering {
+			return false
+		}
+		km := m.opts.Keys
+		for _, k := range []string{km.Archive, km.Trash, km.Read, km.Unsubscribe, km.UnsubscribeAll, km.Block, km.Filter} {
+			if key == k {
+				return true
+			}
+		}
+	case viewMessages:
+		return key == "z" && m.messagesList.FilterState() != list.Filtering
+	case viewRules:
+		return key == "r" && m.rulesList.FilterState() != list.Filtering
+	case viewDrafts:
+		return (key == "n" || key == "enter" || key == "d") && m.draftsList.FilterState() != list.Filtering
+	case viewTrash:
+		return (key == "u" || key == "U") && m.trashList.FilterState() != list.Filtering
+	case viewProfile:
+		km := m.opts.Keys
+		return (key == km.Archive || key == km.Trash || key == km.Unsubscribe) && m.profileList.FilterState() != list.Filtering
+	}
+	return false
+}
+
+// changesNothing reports whether actions only describe themselves: under a
+// dry run, or in read-only mode should one get past changesMail. It is
+// gmail.IsDryRun of actionContext, for code that has no context.
+func (m *AppModel) changesNothing() bool { return m.opts.DryRun || m.opts.ReadOnly }
+
+// rootContext is the context the TUI signs in and runs actions under,
+// carrying Options.ReadOnly.
+func (m *AppModel) rootContext() context.Context {
+	if m.opts.ReadOnly {
+		return gmail.WithReadOnly(context.Background())
+	}
+	return context.Background()
+}
+
+// skipped words the toast for an action that changed nothing, action being
+// what it would have done, such as "archive 3 messages".
+func (m *AppModel) skipped(action string) string {
+	if m.opts.DryRun {
+		return "Dry run: would " + action
+	}
+	return "Read-only mode: did not " + action
+}
@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+
+	"chuckterm/internal/gmail"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// failedMsg is implemented by the results of commands that call Gmail, so
+// authGuard can tell when one failed because the sign-in expired.
+type failedMsg interface{ failure() error }
+
+func (msg syncCompleteMsg) failure() error    { return msg.err }
+func (msg pushSyncedMsg) failure() error      { return msg.err }
+func (msg actionResultMsg) failure() error    { return msg.err }
+func (msg bodyFetchedMsg) failure() error     { return msg.err }
+func (msg attachmentSavedMsg) failure() error { return msg.err }
+func (msg rulesRanMsg) failure() error        { return msg.err }
+func (msg snoozedMsg) failure() error         { return msg.err }
+func (msg unsubItemDoneMsg) failure() error   { return msg.err }
+func (msg filterCreatedMsg) failure() error   { return msg.err }
+func (msg blockedMsg) failure() error         { return msg.err }
+func (msg markedReadMsg) failure() error      { return msg.err }
+func (msg filtersRemovedMsg) failure() error  { return msg.err }
+func (msg draftsLoadedMsg) failure() error    { return msg.err }
+func (msg draftSavedMsg) failure() error      { return msg.err }
+func (msg draftSentMsg) failure() error       { return msg.err }
+func (msg draftDeletedMsg) failure() error    { return msg.err }
+func (msg signatureLoadedMsg) failure() error { return msg.err }
+func (msg trashLoadedMsg) failure() error     { return msg.err }
+func (msg untrashedMsg) failure() error       { return msg.err }
+
+// reauthNeededMsg asks for a new sign-in, after which retry runs again.
+type reauthNeededMsg struct {
+	retry tea.Cmd
+}
+
+// reauthResultMsg ends a sign-in started mid-session.
+type reauthResultMsg struct {
+	err error
+}
+
+// authGuard runs cmd, and when it fails because the access token expired or
+// was revoked, signs in again and then runs cmd once more instead of
+// reporting the failure.
+func (m *AppModel) authGuard(cmd tea.Cmd) tea.Cmd {
+	if m.service == nil {
+		return cmd
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		if f, ok := msg.(failedMsg); ok && gmail.IsAuthError(f.failure()) {
+			return reauthNeededMsg{retry: cmd}
+		}
+		return msg
+	}
+}
+
+// reauthenticate queues retry and, unless a sign-in is already under way,
+// opens the auth view over the current one.
+func (m *AppModel) reauthenticate(retry tea.Cmd) tea.Cmd {
+	m.reauthRetries = append(m.reauthRetries, retry)
+	if m.reauthing {
+		return nil
+	}
+	m.reauthing = true
+	m.authReturn = m.view
+	m.statusBar.Text = "Your Gmail sign-in expired; signing in again..."
+	svc := m.service
+	return func() tea.Msg {
+		go func() {
+			err := gmail.Reauthenticate(context.Background(), svc, m.uiEvents, m.userResponses)
+			m.uiEvents <- reauthResultMsg{err: err}
+		}()
+		return authEvent(<-m.uiEvents)
+	}
+}
+
+// reauthenticated returns to the view the sign-in interrupted and reruns
+// the commands that were waiting for it.
+func (m *AppModel) reauthenticated(msg reauthResultMsg) tea.Cmd {
+	retries := m.reauthRetries
+	m.reauthing, m.reauthRetries = false, nil
+	m.view, m.authURL, m.deviceCode = m.authReturn, "", nil
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Signing in again failed: %v", msg.err))
+	}
+	return tea.Batch(append(retries, m.toasts.Push("Signed in again"))...)
+}
@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"context"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// loadSession reads where the last run quit. The grouping and date filter
+// apply before the groups first load; the rest waits for restoreSession.
+func (m *AppModel) loadSession() {
+	if m.store == nil || m.opts.Demo {
+		return
+	}
+	s, err := gmail.LoadSession(context.Background(), m.store)
+	if err != nil || s.View == "" {
+		return
+	}
+	m.session = &s
+	m.grouping = s.Grouping
+	if s.ByDomain && m.grouping == "" {
+		m.grouping = gmail.GroupByDomain
+	}
+	if f, err := gmail.ParseDateFilter(s.DateFilter, time.Now()); err == nil {
+		m.dateFilter = f
+	}
+	m.bulkOnly = s.BulkOnly
+}
+
+// restoreSession reopens the group, message and searches of the last run,
+// once the groups show. Whatever no longer exists is skipped.
+func (m *AppModel) restoreSession() tea.Cmd {
+	s := m.session
+	m.session = nil
+	if s == nil {
+		return nil
+	}
+	if s.GroupFilter != "" {
+		if err := m.loadRemainingGroups(); err == nil {
+			m.groupsList.SetFilterText(s.GroupFilter)
+		}
+	}
+	if !m.selectVisibleGroup(s.Group) && m.moreGroups() {
+		// The group may lie beyond the loaded pages.
+		if err := m.loadRemainingGroups(); err == nil {
+			m.selectVisibleGroup(s.Group)
+		}
+	}
+	m.detailKey = ""
+	detail := m.refreshDetail()
+	m.previewKey = ""
+	if m.layout == layoutWide {
+		m.refreshPreview()
+	}
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok || gi.Email != s.Group.Email || gi.Subject != s.Group.Subject || s.View == "groups" {
+		return detail
+	}
+	_, cmd := m.enterGroup()
+	cmd = tea.Batch(detail, cmd)
+	if s.Search != "" {
+		m.searchInput.SetValue(s.Search)
+		m.applySearch()
+		m.searchApplied = len(searchTerms(s.Search)) > 0
+	}
+	for i, it := range m.messagesList.Items() {
+		if it.(messageItem).ID != s.Message {
+			continue
+		}
+		m.messagesList.Select(i)
+		if s.View != "body" {
+			return tea.Batch(cmd, m.previewCmd())
+		}
+		m.bodyOffset = s.BodyOffset
+		_, open := m.enterMessage()
+		return tea.Batch(cmd, open)
+	}
+	return cmd
+}
+
+// selectVisibleGroup highlights the group with the given key among those
+// the list shows, reporting whether it is there.
+func (m *AppModel) selectVisibleGroup(key model.GroupKey) bool {
+	for i, it := range m.groupsList.VisibleItems() {
+		if g := it.(groupItem); g.Email == key.Email && g.Subject == key.Subject {
+			m.groupsList.Select(i)
+			return true
+		}
+	}
+	return false
+}
+
+// SaveSession stores where the user is, for the next launch to open there.
+// It keeps the previous session when the groups never showed.
+func (m *AppModel) SaveSession() error {
+	if m.store == nil || m.opts.Demo {
+		return nil
+	}
+	s := gmail.Session{View: "groups", Grouping: m.grouping, DateFilter: m.dateFilter.Label, BulkOnly: m.bulkOnly}
+	if m.groupsList.FilterState() == list.FilterApplied {
+		s.GroupFilter = m.groupsList.FilterValue()
+	}
+	switch {
+	case m.view == viewLoading || m.view == viewAuth:
+		return nil
+	case m.view == viewBody && m.selectedGroup != nil && m.selectedMsg != nil:
+		s.View = "body"
+		s.Message = m.selectedMsg.ID
+		s.BodyOffset = m.bodyViewport.YOffset
+	case m.view == viewMessages && m.selectedGroup != nil:
+		s.View = "messages"
+		if mi, ok := m.messagesList.SelectedItem().(messageItem); ok {
+			s.Message = mi.ID
+		}
+	}
+	if s.View == "groups" {
+		if gi, ok := m.groupsList.SelectedItem().(groupItem); ok {
+			s.Group = model.GroupKey{Email: gi.Email, Subject: gi.Subject}
+		}
+	} else {
+		s.Group = model.GroupKey{Email: m.selectedGroup.Email, Subject: m.selectedGroup.Subject}
+		s.Search = m.searchInput.Value()
+	}
+	return gmail.SaveSession(context.Background(), m.store, s)
+}
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"context"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// spamNotice is shown for the keys spam review refuses.
+const spamNotice = "Spam review: unsubscribing from spam only confirms your address"
+
+// spamRefuses reports whether spam review refuses key in the current view.
+// Unsubscribe links in spam are not to be trusted, so they stay unused.
+func (m *AppModel) spamRefuses(key string) bool {
+	if !m.opts.Spam {
+		return false
+	}
+	km := m.opts.Keys
+	switch m.view {
+	case viewGroups:
+		return m.groupsList.FilterState() != list.Filtering && (key == km.Unsubscribe || key == km.UnsubscribeAll || key == km.Queue)
+	case viewProfile:
+		return m.profileList.FilterState() != list.Filtering && key == km.Unsubscribe
+	}
+	return false
+}
+
+// spamKeys are the groups-view bindings in spam review, where archiving
+// rescues a group and trashing deletes it for good.
+func (k Keymap) spamKeys() []ui.Key {
+	var keys []ui.Key
+	for _, b := range k.groupKeys() {
+		switch b.Keys {
+		case k.Archive:
+			b.Help = "not spam"
+		case k.Trash:
+			b.Help = "delete forever"
+		case k.Unsubscribe, k.UnsubscribeAll, k.Queue:
+			continue
+		}
+		keys = append(keys, b)
+	}
+	return keys
+}
+
+// groupKeys are the bindings of the groups view in the current mode.
+func (m *AppModel) groupKeys() []ui.Key {
+	if m.opts.Spam {
+		return m.opts.Keys.spamKeys()
+	}
+	return m.opts.Keys.groupKeys()
+}
+
+// archiveIDs archives ids, or in spam review moves them back to the inbox.
+func (m *AppModel) archiveIDs(ctx context.Context, ids []string) error {
+	if m.opts.Spam {
+		return gmail.NotSpam(ctx, m.service, ids)
+	}
+	return m.mailbox.Archive(ctx, ids)
+}
+
+// archivedLabel is the label archiveIDs takes off: INBOX, or in spam review
+// SPAM.
+func (m *AppModel) archivedLabel() string {
+	if m.opts.Spam {
+		return gmail.SpamLabel
+	}
+	return "INBOX"
+}
+
+// archiveHides reports whether archiving takes a group off the list, which
+// it does when it moves the mail out of the cache's scope: not under ALL or
+// a label other than INBOX, where archived mail stays.
+func (m *AppModel) archiveHides() bool {
+	if m.store == nil {
+		return true
+	}
+	scope, err := gmail.LabelScope(context.Background(), m.store)
+	return err != nil || gmail.LeavesScope(scope, m.archivedLabel())
+}
+
+// trashIDs moves ids to the trash, remembering them in the trash log, or in
+// spam review deletes them for good.
+func (m *AppModel) trashIDs(ctx context.Context, ids []string) error {
+	if m.opts.Spam {
+		return gmail.DeleteForever(ctx, m.service, ids)
+	}
+	if err := m.mailbox.Trash(ctx, ids); err != nil {
+		return err
+	}
+	if m.store != nil && !gmail.IsDryRun(ctx) {
+		// Remembered for the trash view, which can restore them.
+		if msgs, err := m.store.GetMessagesByIDs(ctx, ids); err == nil {
+			gmail.RememberTrashed(ctx, m.store, msgs, time.Now())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Account is one entry of the accounts view, as Options.Accounts reports
+// it.
+type Account struct {
+	Name     string
+	Current  bool // the account this TUI shows
+	Messages int
+	Unread   int
+	LastSync time.Time // zero if never synced
+	Err      error     // its cache could not be read
+}
+
+// accountItem shows one account in the accounts view.
+type accountItem struct {
+	Account
+}
+
+func (a accountItem) FilterValue() string { return a.Name }
+func (a accountItem) Title() string {
+	if a.Current {
+		return a.Name + " (open)"
+	}
+	return a.Name
+}
+func (a accountItem) Description() string {
+	if a.Err != nil {
+		return "cache unreadable: " + a.Err.Error()
+	}
+	desc := fmt.Sprintf("%s · %d unread · ", plural(a.Messages, "message"), a.Unread)
+	if a.LastSync.IsZero() {
+		return desc + "never synced"
+	}
+	return desc + "synced " + a.LastSync.Local().Format("2006-01-02 15:04")
+}
+
+// accountsKeys are the bindings of the accounts view.
+var accountsKeys = []ui.Key{
+	{Keys: "enter", Help: "switch to"},
+	{Keys: "r", Help: "reload"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+func accountsFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(accountsKeys))
+}
+
+// openAccounts switches to the accounts view.
+func (m *AppModel) openAccounts() (tea.Model, tea.Cmd) {
+	if m.opts.Accounts == nil {
+		return m, m.toasts.Push("No accounts to switch to; list them under [accounts] in config.toml")
+	}
+	m.view = viewAccounts
+	m.accountsList.Title = "Accounts"
+	m.statusBar.Text = "Loading accounts..."
+	return m, m.accountsCmd()
+}
+
+func (m *AppModel) accountsCmd() tea.Cmd {
+	return func() tea.Msg {
+		accounts, err := m.opts.Accounts(context.Background())
+		return accountsLoadedMsg{accounts: accounts, err: err}
+	}
+}
+
+func (m *AppModel) accountsLoaded(msg accountsLoadedMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Loading accounts failed: %v", msg.err))
+	}
+	items := make([]list.Item, len(msg.accounts))
+	for i, a := range msg.accounts {
+		items[i] = accountItem{a}
+	}
+	m.accountsList.Title = fmt.Sprintf("Accounts (%d)", len(items))
+	return m.accountsList.SetItems(items)
+}
+
+// handleAccountsKey handles the keys of the accounts view. enter on another
+// account quits this TUI with SwitchAccount set, for the caller to open
+// that account in its place.
+func (m *AppModel) handleAccountsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.accountsList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.accountsList, cmd = m.accountsList.Update(msg)
+		return m, cmd
+	}
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "esc":
+		m.view = viewGroups
+		return m, nil
+	case "enter":
+		selected, ok := m.accountsList.SelectedItem().(accountItem)
+		if !ok {
+			return m, nil
+		}
+		if selected.Current {
+			m.view = viewGroups
+			return m, nil
+		}
+		m.SwitchAccount = selected.Name
+		return m, tea.Quit
+	case "r":
+		m.statusBar.Text = "Loading accounts..."
+		return m, m.accountsCmd()
+	}
+	var cmd tea.Cmd
+	m.accountsList, cmd = m.accountsList.Update(msg)
+	return m, cmd
+}
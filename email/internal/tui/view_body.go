@@ -2,19 +2,148 @@ package tui
 
 import (
 	"fmt"
+	"strings"
+
+	"chuckterm/internal/ics"
+	"chuckterm/internal/model"
+	"common/ui"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
 var headerStyle = lipgloss.NewStyle().
 	Bold(true).
-	Foreground(lipgloss.Color("39")).
+	Foreground(ui.Accent).
 	PaddingBottom(1)
 
 func bodyHeader(from, subject, date string) string {
 	return headerStyle.Render(fmt.Sprintf("From: %s\nSubject: %s\nDate: %s", from, subject, trimDate(date)))
 }
 
-func bodyFooter() string {
-	return footerStyle.Render("o: open in gmail  esc: back  q: quit")
+var attachmentStyle = lipgloss.NewStyle().Foreground(ui.Warn)
+
+// renderAttachments lists a message's attachments above its body, marking
+// the one d would download.
+func renderAttachments(atts []model.Attachment, selected int) string {
+	if len(atts) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Attachments (%d):\n", len(atts)))
+	for i, a := range atts {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+		sb.WriteString(attachmentStyle.Render(fmt.Sprintf("%s[%d] %s  %s, %s", marker, i+1, a.Filename, a.MimeType, humanSize(a.Size))))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+var inviteStyle = lipgloss.NewStyle().Foreground(ui.Good)
+
+// renderInvites summarises calendar invitations above the body: title, time
+// in local time, place, organizer and any RSVP links found in the message.
+func renderInvites(events []ics.Event) string {
+	if len(events) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, ev := range events {
+		title := "Invitation: " + ev.Summary
+		switch strings.ToUpper(ev.Method) {
+		case "CANCEL":
+			title = "Cancelled: " + ev.Summary
+		case "REPLY":
+			title = "Reply: " + ev.Summary
+		}
+		lines := []string{title, "  When:      " + inviteTime(ev)}
+		if ev.Location != "" {
+			lines = append(lines, "  Where:     "+ev.Location)
+		}
+		if ev.Organizer != "" {
+			lines = append(lines, "  Organizer: "+ev.Organizer)
+		}
+		if ev.Status != "" && !strings.EqualFold(ev.Status, "CONFIRMED") {
+			lines = append(lines, "  Status:    "+strings.ToLower(ev.Status))
+		}
+		for _, l := range ev.RSVP {
+			lines = append(lines, fmt.Sprintf("  %-10s %s", l.Label+":", l.URL))
+		}
+		sb.WriteString(inviteStyle.Render(strings.Join(lines, "\n")))
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+// inviteTime formats an event's time span in local time.
+func inviteTime(ev ics.Event) string {
+	if ev.Start.IsZero() {
+		return "unknown"
+	}
+	if ev.AllDay {
+		// DTEND of an all-day event is exclusive.
+		end := ev.End.AddDate(0, 0, -1)
+		if ev.End.IsZero() || !end.After(ev.Start) {
+			return ev.Start.Format("Mon Jan 2 2006") + " (all day)"
+		}
+		return ev.Start.Format("Mon Jan 2") + " – " + end.Format("Mon Jan 2 2006") + " (all day)"
+	}
+	start := ev.Start.Local()
+	s := start.Format("Mon Jan 2 2006 15:04")
+	if ev.End.IsZero() {
+		return s
+	}
+	end := ev.End.Local()
+	if end.YearDay() == start.YearDay() && end.Year() == start.Year() {
+		return s + "–" + end.Format("15:04 MST")
+	}
+	return s + " – " + end.Format("Mon Jan 2 2006 15:04 MST")
+}
+
+// humanSize formats a byte count with a binary unit.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// renderLinks numbers the links found in the body text that its own Links
+// list, from an HTML body, does not have, continuing that list.
+func renderLinks(links []string, listed int) string {
+	if len(links) == listed {
+		return ""
+	}
+	var sb strings.Builder
+	if listed == 0 {
+		sb.WriteString("\n\nLinks:")
+	}
+	for i := listed; i < len(links); i++ {
+		sb.WriteString(fmt.Sprintf("\n[%d] %s", i+1, links[i]))
+	}
+	return sb.String()
+}
+
+// bodyKeys are the bindings of the body view.
+var bodyKeys = []ui.Key{
+	{Keys: "tab", Help: "next attachment"},
+	{Keys: "d", Help: "download to ~/Downloads and open"},
+	{Keys: "c", Help: "add invite to calendar"},
+	{Keys: "l/1-9", Help: "open link N"},
+	{Keys: "o", Help: "open in gmail"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+func bodyFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(bodyKeys))
 }
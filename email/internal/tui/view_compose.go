@@ -0,0 +1,509 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// draftItem shows one Gmail draft in the drafts view.
+type draftItem struct {
+	gmail.Draft
+}
+
+func (d draftItem) FilterValue() string { return d.Subject + " " + d.To }
+func (d draftItem) Title() string {
+	if strings.TrimSpace(d.Subject) == "" {
+		return "(no subject)"
+	}
+	return d.Subject
+}
+func (d draftItem) Description() string {
+	to := d.To
+	if strings.TrimSpace(to) == "" {
+		to = "no recipient yet"
+	}
+	return "to " + to + " · " + trimDate(d.Date)
+}
+
+// draftsKeys are the bindings of the drafts view.
+var draftsKeys = []ui.Key{
+	{Keys: "n", Help: "new message"},
+	{Keys: "enter", Help: "edit"},
+	{Keys: "d", Help: "delete"},
+	{Keys: "r", Help: "reload"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+// composeKeys are the bindings of the compose form; every other key types.
+var composeKeys = []ui.Key{
+	{Keys: "tab", Help: "next field"},
+	{Keys: "shift+tab", Help: "previous field"},
+	{Keys: "ctrl+s", Help: "send"},
+	{Keys: "esc", Help: "save to drafts and close"},
+	{Keys: "ctrl+x", Help: "discard changes"},
+	{Keys: "up/down enter", Help: "pick a suggested address"},
+}
+
+func draftsFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(draftsKeys))
+}
+
+func composeFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(composeKeys))
+}
+
+// Fields of the compose form, in tab order.
+const (
+	fieldTo = iota
+	fieldCc
+	fieldSubject
+	fieldBody
+)
+
+// maxSuggestions caps the addresses suggested under the To and Cc lines.
+const maxSuggestions = 5
+
+// composeForm is a plain-text message being written: address and subject
+// lines over a body editor. draft is the copy it was opened with, to tell
+// whether anything changed. Addresses typed in To and Cc are completed from
+// contacts; suggest holds the matches for the one being typed.
+type composeForm struct {
+	lines    [fieldBody]textinput.Model
+	body     textarea.Model
+	focus    int
+	draft    gmail.Draft
+	sending  bool
+	contacts []model.Contact
+	suggest  []model.Contact
+	pick     int // highlighted suggestion
+}
+
+func newComposeForm() composeForm {
+	var f composeForm
+	for i, prompt := range []string{"To:      ", "Cc:      ", "Subject: "} {
+		f.lines[i] = textinput.New()
+		f.lines[i].Prompt = prompt
+	}
+	f.lines[fieldTo].Placeholder = "name@example.com, Someone <someone@example.com>"
+	f.body = textarea.New()
+	f.body.ShowLineNumbers = false
+	f.body.Prompt = ""
+	f.body.MaxHeight = 0
+	f.body.Placeholder = "Write the message. Plain text only."
+	return f
+}
+
+// load fills the form from d and focuses the first empty address field,
+// or the body when the recipients are set.
+func (f *composeForm) load(d gmail.Draft) tea.Cmd {
+	f.draft, f.sending = d, false
+	f.lines[fieldTo].SetValue(d.To)
+	f.lines[fieldCc].SetValue(d.Cc)
+	f.lines[fieldSubject].SetValue(d.Subject)
+	f.body.SetValue(d.Body)
+	f.body.CursorStart()
+	if d.To == "" {
+		return f.setFocus(fieldTo)
+	}
+	return f.setFocus(fieldBody)
+}
+
+// value is the message as the form has it now.
+func (f *composeForm) value() gmail.Draft {
+	return gmail.Draft{
+		ID:      f.draft.ID,
+		To:      strings.Trim(f.lines[fieldTo].Value(), " ,"),
+		Cc:      strings.Trim(f.lines[fieldCc].Value(), " ,"),
+		Subject: f.lines[fieldSubject].Value(),
+		Body:    f.body.Value(),
+	}
+}
+
+// changed reports whether the form differs from the draft it was opened with.
+func (f *composeForm) changed() bool {
+	d, v := f.draft, f.value()
+	return d.To != v.To || d.Cc != v.Cc || d.Subject != v.Subject || d.Body != v.Body
+}
+
+func (f *composeForm) setFocus(i int) tea.Cmd {
+	f.focus = (i + fieldBody + 1) % (fieldBody + 1)
+	f.suggest = nil
+	for j := range f.lines {
+		f.lines[j].Blur()
+	}
+	f.body.Blur()
+	if f.focus == fieldBody {
+		return f.body.Focus()
+	}
+	return f.lines[f.focus].Focus()
+}
+
+func (f *composeForm) setSize(w, h int) {
+	for i := range f.lines {
+		f.lines[i].Width = max(w-lipgloss.Width(f.lines[i].Prompt)-1, 1)
+	}
+	f.body.SetWidth(w)
+	// The address lines and the rule under them take four rows.
+	f.body.SetHeight(max(h-4, 1))
+}
+
+// update passes msg to the focused field and refreshes the suggestions.
+func (f *composeForm) update(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	if f.focus == fieldBody {
+		f.body, cmd = f.body.Update(msg)
+		return cmd
+	}
+	f.lines[f.focus], cmd = f.lines[f.focus].Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		f.suggest = gmail.MatchContacts(f.contacts, f.addressQuery(), maxSuggestions)
+		f.pick = 0
+	}
+	return cmd
+}
+
+// addressQuery is the start of the address being typed at the end of the
+// focused To or Cc line, after its last comma.
+func (f *composeForm) addressQuery() string {
+	if f.focus != fieldTo && f.focus != fieldCc {
+		return ""
+	}
+	line := f.lines[f.focus]
+	v := line.Value()
+	if line.Position() != len([]rune(v)) {
+		return ""
+	}
+	return strings.TrimSpace(v[strings.LastIndex(v, ",")+1:])
+}
+
+// complete replaces the address being typed with the highlighted
+// suggestion, ready for the next one.
+func (f *composeForm) complete() {
+	line := &f.lines[f.focus]
+	v := line.Value()
+	prefix := ""
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		prefix = v[:i+1] + " "
+	}
+	line.SetValue(prefix + addressText(f.suggest[f.pick]) + ", ")
+	line.CursorEnd()
+	f.suggest = nil
+}
+
+// addressText is c as typed in an address line: Name <address>, with the
+// name quoted when it has characters that would split it.
+func addressText(c model.Contact) string {
+	if c.Name == "" {
+		return c.Email
+	}
+	name := c.Name
+	if strings.ContainsAny(name, `()<>[]:;@\,."`) {
+		name = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+	return name + " <" + c.Email + ">"
+}
+
+func (f *composeForm) View(width int) string {
+	var b strings.Builder
+	for i, l := range f.lines {
+		b.WriteString(l.View())
+		b.WriteString("\n")
+		if i != f.focus {
+			continue
+		}
+		indent := strings.Repeat(" ", lipgloss.Width(l.Prompt))
+		for j, c := range f.suggest {
+			mark := "  "
+			if j == f.pick {
+				mark = "› "
+			}
+			b.WriteString(indent + mark + addressText(c) + "\n")
+		}
+	}
+	b.WriteString(strings.Repeat("─", max(width, 1)))
+	b.WriteString("\n")
+	// The suggestions push the body down; its last lines give way.
+	body := strings.Split(f.body.View(), "\n")
+	b.WriteString(strings.Join(body[:max(len(body)-len(f.suggest), 1)], "\n"))
+	return b.String()
+}
+
+// openDrafts switches to the drafts view and loads the account's drafts.
+func (m *AppModel) openDrafts() (tea.Model, tea.Cmd) {
+	if m.service == nil {
+		return m, m.toasts.Push("Drafts need a Gmail account")
+	}
+	m.view = viewDrafts
+	m.draftsList.Title = "Drafts"
+	m.statusBar.Text = "Loading drafts..."
+	if _, ok := m.configSignature(); !ok && m.gmailSig == nil {
+		return m, tea.Batch(m.draftsCmd(), m.signatureCmd())
+	}
+	return m, m.draftsCmd()
+}
+
+// configSignature is the account's signature from the config, if it has
+// one.
+func (m *AppModel) configSignature() (string, bool) {
+	for addr, sig := range m.opts.Signatures {
+		if strings.EqualFold(addr, m.account) {
+			return sig, true
+		}
+	}
+	sig, ok := m.opts.Signatures["default"]
+	return sig, ok
+}
+
+// signature is added to new messages: the config's, else Gmail's.
+func (m *AppModel) signature() string {
+	if sig, ok := m.configSignature(); ok {
+		return sig
+	}
+	if m.gmailSig != nil {
+		return *m.gmailSig
+	}
+	return ""
+}
+
+func (m *AppModel) signatureCmd() tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		sig, err := gmail.Signature(m.actionContext(), m.service)
+		return signatureLoadedMsg{text: sig, err: err}
+	})
+}
+
+func (m *AppModel) draftsCmd() tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		drafts, err := gmail.ListDrafts(m.actionContext(), m.service)
+		return draftsLoadedMsg{drafts: drafts, err: err}
+	})
+}
+
+func (m *AppModel) draftsLoaded(msg draftsLoadedMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Loading drafts failed: %v", msg.err))
+	}
+	items := make([]list.Item, len(msg.drafts))
+	for i, d := range msg.drafts {
+		items[i] = draftItem{d}
+	}
+	m.draftsList.Title = fmt.Sprintf("Drafts (%d)", len(items))
+	return m.draftsList.SetItems(items)
+}
+
+// handleDraftsKey handles the keys of the drafts view.
+func (m *AppModel) handleDraftsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.draftsList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.draftsList, cmd = m.draftsList.Update(msg)
+		return m, cmd
+	}
+	selected, ok := m.draftsList.SelectedItem().(draftItem)
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "esc":
+		m.view = viewGroups
+		return m, nil
+	case "n":
+		return m.compose(gmail.Draft{Body: gmail.AppendSignature("", m.signature())})
+	case "enter":
+		if ok {
+			return m.compose(selected.Draft)
+		}
+		return m, nil
+	case "d":
+		if ok {
+			m.confirm.Ask(confirmDeleteDraft, fmt.Sprintf("Delete the draft %q? Gmail does not keep deleted drafts in the trash.", selected.Title()))
+		}
+		return m, nil
+	case "r":
+		m.statusBar.Text = "Loading drafts..."
+		return m, m.draftsCmd()
+	}
+	var cmd tea.Cmd
+	m.draftsList, cmd = m.draftsList.Update(msg)
+	return m, cmd
+}
+
+// compose opens the compose form on d, returning to the current view when
+// it closes.
+func (m *AppModel) compose(d gmail.Draft) (tea.Model, tea.Cmd) {
+	m.composeReturn = m.view
+	m.view = viewCompose
+	m.composer.contacts = nil
+	if cs, ok := m.store.(gmail.ContactStore); ok {
+		contacts, err := cs.LoadContacts(context.Background())
+		if err != nil {
+			return m, tea.Batch(m.composer.load(d), m.toasts.Push(fmt.Sprintf("Loading contacts failed: %v", err)))
+		}
+		m.composer.contacts = contacts
+	}
+	return m, m.composer.load(d)
+}
+
+// handleComposeKey handles the keys of the compose form. It runs before the
+// global keys so that q and ? can be typed.
+func (m *AppModel) handleComposeKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.composer.sending {
+		return m, nil
+	}
+	if n := len(m.composer.suggest); n > 0 {
+		switch msg.String() {
+		case "down", "ctrl+n":
+			m.composer.pick = (m.composer.pick + 1) % n
+			return m, nil
+		case "up", "ctrl+p":
+			m.composer.pick = (m.composer.pick + n - 1) % n
+			return m, nil
+		case "enter":
+			m.composer.complete()
+			return m, nil
+		case "esc":
+			m.composer.suggest = nil
+			return m, nil
+		}
+	}
+	switch msg.String() {
+	case "tab":
+		return m, m.composer.setFocus(m.composer.focus + 1)
+	case "shift+tab":
+		return m, m.composer.setFocus(m.composer.focus - 1)
+	case "esc":
+		return m, m.closeCompose()
+	case "ctrl+x":
+		m.view = m.composeReturn
+		if m.composer.changed() {
+			return m, m.toasts.Push("Discarded the changes")
+		}
+		return m, nil
+	case "ctrl+s":
+		d := m.composer.value()
+		m.composer.sending = true
+		m.statusBar.Text = "Sending..."
+		return m, m.sendDraftCmd(d)
+	}
+	return m, m.composer.update(msg)
+}
+
+// closeCompose leaves the compose form, saving what was written to Gmail's
+// Drafts so it is not lost. An untouched or empty new message is dropped.
+func (m *AppModel) closeCompose() tea.Cmd {
+	m.view = m.composeReturn
+	d := m.composer.value()
+	if !m.composer.changed() || (d.ID == "" && d.Empty()) {
+		return nil
+	}
+	m.statusBar.Text = "Saving the draft..."
+	return m.authGuard(func() tea.Msg {
+		id, err := gmail.SaveDraft(m.actionContext(), m.service, d)
+		if errors.Is(err, gmail.ErrDryRun) {
+			return draftSavedMsg{draft: d, dryRun: true}
+		}
+		d.ID = id
+		return draftSavedMsg{draft: d, err: err}
+	})
+}
+
+// draftSaved reports a draft saved on closing the form. When saving failed
+// the form opens again with the text, so it is not lost.
+func (m *AppModel) draftSaved(msg draftSavedMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	switch {
+	case msg.err != nil:
+		toast := m.toasts.Push(fmt.Sprintf("Saving the draft failed: %v", msg.err))
+		if m.view == viewCompose {
+			return toast
+		}
+		m.composeReturn = m.view
+		m.view = viewCompose
+		focus := m.composer.load(msg.draft)
+		// Keep the saved state as it was, so esc tries again.
+		m.composer.draft = gmail.Draft{ID: msg.draft.ID}
+		return tea.Batch(toast, focus)
+	case msg.dryRun:
+		return m.toasts.Push(m.skipped(fmt.Sprintf("save the draft %q", msg.draft.Subject)))
+	}
+	toast := m.toasts.Push("Saved to Drafts")
+	if m.view == viewDrafts {
+		return tea.Batch(toast, m.draftsCmd())
+	}
+	return toast
+}
+
+// sendDraftCmd sends d, removing it from Drafts when it was saved there.
+func (m *AppModel) sendDraftCmd(d gmail.Draft) tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		err := gmail.SendDraft(m.actionContext(), m.service, d)
+		if errors.Is(err, gmail.ErrDryRun) {
+			return draftSentMsg{draft: d, dryRun: true}
+		}
+		return draftSentMsg{draft: d, err: err}
+	})
+}
+
+// draftSent closes the form on a sent message; on failure it stays open.
+func (m *AppModel) draftSent(msg draftSentMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	m.composer.sending = false
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Sending failed: %v", msg.err))
+	}
+	if m.view == viewCompose {
+		m.view = m.composeReturn
+	}
+	if msg.dryRun {
+		return m.toasts.Push(m.skipped(fmt.Sprintf("send %q to %s", msg.draft.Subject, msg.draft.To)))
+	}
+	toast := m.toasts.Push("Sent to " + msg.draft.To)
+	if m.view == viewDrafts && msg.draft.ID != "" {
+		return tea.Batch(toast, m.draftsCmd())
+	}
+	return toast
+}
+
+// deleteSelectedDraft deletes the highlighted draft from Gmail.
+func (m *AppModel) deleteSelectedDraft() (tea.Model, tea.Cmd) {
+	d, ok := m.draftsList.SelectedItem().(draftItem)
+	if !ok {
+		return m, nil
+	}
+	return m, m.authGuard(func() tea.Msg {
+		err := gmail.DeleteDraft(m.actionContext(), m.service, d.ID)
+		if errors.Is(err, gmail.ErrDryRun) {
+			return draftDeletedMsg{draft: d.Draft, dryRun: true}
+		}
+		return draftDeletedMsg{draft: d.Draft, err: err}
+	})
+}
+
+func (m *AppModel) draftDeleted(msg draftDeletedMsg) tea.Cmd {
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Deleting the draft failed: %v", msg.err))
+	}
+	if msg.dryRun {
+		return m.toasts.Push(m.skipped(fmt.Sprintf("delete the draft %q", draftItem{msg.draft}.Title())))
+	}
+	for i, it := range m.draftsList.Items() {
+		if it.(draftItem).ID == msg.draft.ID {
+			m.draftsList.RemoveItem(i)
+			break
+		}
+	}
+	m.draftsList.Title = fmt.Sprintf("Drafts (%d)", len(m.draftsList.Items()))
+	return m.toasts.Push("Deleted the draft")
+}
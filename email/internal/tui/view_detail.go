@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"common/ui"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// detailPanelHeight is the number of lines the group detail panel occupies.
+const detailPanelHeight = 7
+
+// ageBuckets counts a group's messages by age.
+type ageBuckets struct {
+	week, month, quarter, older, undated int
+}
+
+// detailMsg carries the age histogram of the group with the given key.
+type detailMsg struct {
+	key     string
+	buckets ageBuckets
+}
+
+// groupAges counts g's messages by age relative to now, in the store when
+// it can count them in place, and from g's messages otherwise.
+func groupAges(ctx context.Context, st gmail.MessageStore, g model.SenderGroup, now time.Time) ageBuckets {
+	if as, ok := st.(gmail.GroupAgeStore); ok && len(g.MessageIDs) == 0 {
+		if b, err := countAges(ctx, as, g, now); err == nil {
+			return b
+		}
+	}
+	var msgs []model.MessageRef
+	if gs, ok := st.(gmail.GroupSummaryStore); ok && len(g.MessageIDs) == 0 {
+		msgs, _ = groupMessages(ctx, gs, g, model.MessageRef{}, 0)
+	} else if st != nil {
+		msgs, _ = st.GetMessagesByIDs(ctx, g.MessageIDs)
+	}
+	if len(msgs) == 0 {
+		msgs = buildMessageRefsFromGroup(g)
+	}
+	dates := make([]string, len(msgs))
+	for i, msg := range msgs {
+		dates[i] = msg.DateRFC3339
+	}
+	return bucketAges(dates, now)
+}
+
+// countAges is bucketAges counted in the store, member group by member group.
+func countAges(ctx context.Context, as gmail.GroupAgeStore, g model.SenderGroup, now time.Time) (ageBuckets, error) {
+	keys := g.Members
+	if len(keys) == 0 {
+		keys = []model.GroupKey{{Email: g.Email, Subject: g.Subject}}
+	}
+	since := []time.Time{now.Add(-7 * 24 * time.Hour), now.Add(-30 * 24 * time.Hour), now.Add(-90 * 24 * time.Hour)}
+	var b ageBuckets
+	for _, k := range keys {
+		counts, undated, err := as.CountGroupAges(ctx, k.Email, k.Subject, since)
+		if err != nil {
+			return ageBuckets{}, err
+		}
+		b.week += counts[0]
+		b.month += counts[1]
+		b.quarter += counts[2]
+		b.older += counts[3]
+		b.undated += undated
+	}
+	return b, nil
+}
+
+// bucketAges sorts RFC3339 dates into last-7-days, 8–30 days, 31–90 days,
+// and older buckets relative to now.
+func bucketAges(dates []string, now time.Time) ageBuckets {
+	var b ageBuckets
+	for _, d := range dates {
+		t, err := time.Parse(time.RFC3339, d)
+		if err != nil {
+			b.undated++
+			continue
+		}
+		switch age := now.Sub(t); {
+		case age <= 7*24*time.Hour:
+			b.week++
+		case age <= 30*24*time.Hour:
+			b.month++
+		case age <= 90*24*time.Hour:
+			b.quarter++
+		default:
+			b.older++
+		}
+	}
+	return b
+}
+
+var (
+	detailStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(ui.Muted).
+			Padding(0, 1)
+	barStyle = lipgloss.NewStyle().Foreground(ui.Accent)
+)
+
+// renderGroupDetail draws the age histogram for a group.
+func renderGroupDetail(g model.SenderGroup, b ageBuckets, width int) string {
+	rows := []struct {
+		label string
+		n     int
+	}{
+		{"last 7d", b.week},
+		{"8–30d  ", b.month},
+		{"31–90d ", b.quarter},
+		{"older  ", b.older},
+	}
+	maxN := 1
+	for _, r := range rows {
+		maxN = max(maxN, r.n)
+	}
+	barWidth := max(width-30, 10)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s  first %s  last %s", g.Email, trimDate(g.FirstDate), trimDate(g.LastDate))
+	if g.Size > 0 {
+		fmt.Fprintf(&sb, "  %s", humanSize(g.Size))
+	}
+	if u := g.Unsubscribed; u != nil {
+		fmt.Fprintf(&sb, "  unsubscribed %s (%s)", u.Time.Format(time.DateOnly), u.Method)
+	}
+	sb.WriteString("\n")
+	for i, r := range rows {
+		bar := strings.Repeat("█", r.n*barWidth/maxN)
+		if r.n > 0 && bar == "" {
+			bar = "▏"
+		}
+		fmt.Fprintf(&sb, "%s %6d %s", r.label, r.n, barStyle.Render(bar))
+		if i < len(rows)-1 {
+			sb.WriteString("\n")
+		}
+	}
+	if b.undated > 0 {
+		fmt.Fprintf(&sb, "  (+%d undated)", b.undated)
+	}
+	return detailStyle.Width(max(width-2, 20)).Render(sb.String())
+}
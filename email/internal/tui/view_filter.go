@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// filterSelectedGroup opens the prompt for what a Gmail filter should do
+// with future mail from the highlighted group's sender, or domain when
+// grouping by domain.
+func (m *AppModel) filterSelectedGroup() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	if m.service == nil {
+		return m, m.toasts.Push("Gmail filters need a Gmail account")
+	}
+	m.filterFor = gi.SenderGroup
+	m.filterInput.Reset()
+	return m, m.filterInput.Focus()
+}
+
+func (m *AppModel) handleFilterInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.filterInput.Blur()
+		return m, nil
+	case "enter":
+		action, err := gmail.ParseFilterAction(m.filterInput.Value())
+		if err != nil {
+			return m, m.toasts.Push(err.Error())
+		}
+		m.filterInput.Blur()
+		m.statusBar.Text = "Creating filter..."
+		return m, m.filterCmd(m.filterFor.Email, action)
+	}
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	return m, cmd
+}
+
+// filterCmd creates a Gmail filter applying action to mail from sender.
+func (m *AppModel) filterCmd(sender string, action gmail.FilterAction) tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		_, err := gmail.CreateSenderFilter(m.actionContext(), m.service, sender, action)
+		if errors.Is(err, gmail.ErrDryRun) {
+			return filterCreatedMsg{sender: sender, action: action, dryRun: true}
+		}
+		return filterCreatedMsg{sender: sender, action: action, err: err}
+	})
+}
+
+func (m *AppModel) filterCreated(msg filterCreatedMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Creating the filter failed: %v", msg.err))
+	}
+	if msg.dryRun {
+		return m.toasts.Push(m.skipped(fmt.Sprintf("create a Gmail filter from:%s -> %s", msg.sender, msg.action)))
+	}
+	done := map[string]string{
+		gmail.FilterArchive: "skip the inbox",
+		gmail.FilterTrash:   "go to the trash",
+		gmail.FilterLabel:   "be labelled " + msg.action.Label,
+	}[msg.action.Kind]
+	return m.toasts.Push(fmt.Sprintf("Gmail filter created: new mail from %s will %s", msg.sender, done))
+}
+
+// toggleBlock unblocks the highlighted sender, removing the Gmail filter
+// blocking made, or asks before blocking it.
+func (m *AppModel) toggleBlock() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	if _, ok := m.store.(gmail.SenderListStore); !ok {
+		return m, m.toasts.Push("Sender lists need a local store")
+	}
+	if m.senders.Listed(gi.Email) == model.SenderBlocked {
+		_, cmd := m.toggleSenderStatus(model.SenderBlocked)
+		if m.service == nil {
+			return m, cmd
+		}
+		return m, tea.Batch(cmd, m.removeFiltersCmd(gi.Email))
+	}
+	future := "Gmail deletes its future mail"
+	if m.service == nil {
+		future = "new mail from it is trashed as it syncs"
+	}
+	prompt := fmt.Sprintf("Block %s? Its %s go to the trash and %s.", gi.DisplayName, plural(gi.Count, "message"), future)
+	if gi.Status == model.SenderProtected {
+		prompt = fmt.Sprintf("%s is protected. %s", gi.DisplayName, prompt)
+	}
+	m.confirm.Ask(confirmBlock, prompt)
+	return m, nil
+}
+
+// blockSelectedGroup blocks the highlighted sender and trashes its mail.
+// On Gmail a filter then deletes its future mail as it arrives; otherwise
+// the block list trashes it during syncs.
+func (m *AppModel) blockSelectedGroup() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	ctx := context.Background()
+	if err := m.store.(gmail.SenderListStore).SetSenderStatus(ctx, gi.Email, model.SenderBlocked); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Updating sender lists failed: %v", err))
+	}
+	senders, err := gmail.LoadSenderLists(ctx, m.store)
+	if err != nil {
+		return m, m.toasts.Push(err.Error())
+	}
+	m.senders = senders
+	if m.changesNothing() {
+		m.showGroups()
+		m.selectGroup(model.GroupKey{Email: gi.Email, Subject: gi.Subject})
+	} else {
+		m.groupsList.RemoveItem(m.groupsList.Index())
+		m.removeGroup(gi.SenderGroup)
+	}
+	m.statusBar.Text = "Blocking..."
+	g := gi.SenderGroup
+	return m, m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		n, err := m.trashGroup(ctx, g)
+		if gmail.IsDryRun(ctx) && err == nil {
+			return blockedMsg{group: g, trashed: n, dryRun: true}
+		}
+		m.recordAction(model.Action{Kind: "trash", Sender: g.Email, Subject: g.Subject, Messages: n})
+		return blockedMsg{group: g, trashed: n, err: err}
+	})
+}
+
+// blocked reports the trashed mail of a blocked sender and, on Gmail,
+// goes on to create the filter for its future mail.
+func (m *AppModel) blocked(msg blockedMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Blocked %s, but trashing its mail failed: %v", msg.group.DisplayName, msg.err))
+	}
+	text := fmt.Sprintf("Blocked %s and trashed %s", msg.group.DisplayName, plural(msg.trashed, "message"))
+	if msg.dryRun {
+		text = m.skipped(fmt.Sprintf("trash %s from %s", plural(msg.trashed, "message"), msg.group.Email))
+	}
+	toast := m.toasts.Push(text)
+	if m.service == nil {
+		return toast
+	}
+	m.statusBar.Text = "Creating filter..."
+	return tea.Batch(toast, m.filterCmd(msg.group.Email, gmail.FilterAction{Kind: gmail.FilterTrash}))
+}
+
+// removeFiltersCmd deletes the Gmail filters that send mail from sender to
+// the trash.
+func (m *AppModel) removeFiltersCmd(sender string) tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		n, err := gmail.RemoveSenderFilters(m.actionContext(), m.service, sender, gmail.FilterAction{Kind: gmail.FilterTrash})
+		if errors.Is(err, gmail.ErrDryRun) {
+			err = nil
+		}
+		return filtersRemovedMsg{sender: sender, removed: n, err: err}
+	})
+}
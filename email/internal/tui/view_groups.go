@@ -2,45 +2,144 @@ package tui
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"chuckterm/internal/model"
+	"common/ui"
 
 	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 // groupItem wraps SenderGroup to customize list display.
 type groupItem struct {
 	model.SenderGroup
+	queued bool // waiting in the unsubscribe queue
 }
 
 func (g groupItem) FilterValue() string { return g.DisplayName + " " + g.Subject }
 func (g groupItem) Title() string {
 	indicator := " "
-	if g.UnsubscribeURL != "" {
+	if g.UnsubscribeURL != "" || g.UnsubscribeMailto != "" {
 		indicator = "@ "
 	}
-	return fmt.Sprintf("%s%s (%d)", indicator, g.DisplayName, g.Count)
+	if g.Bulk {
+		indicator = "~" + indicator
+	}
+	if g.Unsubscribed != nil {
+		indicator = "✓" + indicator
+	}
+	if g.queued {
+		indicator = "»" + indicator
+	}
+	if g.Pinned {
+		indicator = "*" + indicator
+	}
+	switch g.Status {
+	case model.SenderProtected:
+		indicator = "+" + indicator
+	case model.SenderBlocked:
+		indicator = "-" + indicator
+	}
+	counts := strconv.Itoa(g.Count)
+	if g.Unread > 0 {
+		counts += fmt.Sprintf(", %d unread", g.Unread)
+	}
+	if g.Size > 0 {
+		counts += ", " + humanSize(g.Size)
+	}
+	return fmt.Sprintf("%s%s (%s)", indicator, g.DisplayName, counts)
 }
 func (g groupItem) Description() string {
-	if g.Subject != "" {
-		return g.Subject
+	desc := g.Sample
+	if g.IsDomain() {
+		desc = plural(g.Senders, "sender") + " · " + g.Sample
+	} else if isSenderGroup(g.SenderGroup) {
+		desc = plural(len(g.Members), "subject") + " · " + g.Sample
+	} else if g.Subject != "" {
+		desc = g.Subject
 	}
-	return g.Sample
+	if spark := monthSparkline(g.Months, time.Now()); spark != "" {
+		return spark + " " + desc
+	}
+	return desc
 }
 
-var footerStyle = lipgloss.NewStyle().
-	Foreground(lipgloss.Color("241")).
-	PaddingTop(1)
+// sparkMonths is how many months the group sparkline covers, ending with
+// the current one.
+const sparkMonths = 12
+
+// monthSparkline draws how many messages arrived in each of the last
+// sparkMonths months, or "" for a group without dated messages.
+func monthSparkline(months map[string]int, now time.Time) string {
+	if len(months) == 0 {
+		return ""
+	}
+	counts := make([]int, sparkMonths)
+	first := time.Date(now.Year(), now.Month()-sparkMonths+1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range counts {
+		counts[i] = months[first.AddDate(0, i, 0).Format("2006-01")]
+	}
+	return ui.Sparkline(counts)
+}
 
-func groupsFooter() string {
-	return footerStyle.Render("enter: open  e: archive  #: trash  u: unsubscribe  s: sync  q: quit  @=unsubscribe available")
+// isSenderGroup reports whether g gathers all subjects of one sender (see
+// gmail.BySender).
+func isSenderGroup(g model.SenderGroup) bool {
+	return !g.IsDomain() && g.Subject == "" && len(g.Members) > 0
+}
+
+// plural formats a count with its noun, adding an s unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func groupsFooter(style lipgloss.Style, keys []ui.Key) string {
+	return style.Render(ui.Hints(keys) + "  @=unsubscribe available  ✓=unsubscribed  »=queued  ~=bulk  *=pinned  +=protected  -=blocked")
+}
+
+// bulkGroups returns the groups that look like bulk mail.
+func bulkGroups(groups []model.SenderGroup) []model.SenderGroup {
+	var out []model.SenderGroup
+	for _, g := range groups {
+		if g.Bulk {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// toggleBulkOnly lists only the bulk mail groups, or every group again.
+func (m *AppModel) toggleBulkOnly() (tea.Model, tea.Cmd) {
+	if err := m.loadRemainingGroups(); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Loading groups failed: %v", err))
+	}
+	m.bulkOnly = !m.bulkOnly
+	m.showGroups()
+	m.groupsList.ResetSelected()
+	m.detailKey = ""
+	detail := m.refreshDetail()
+	m.previewKey = ""
+	if m.layout == layoutWide {
+		m.refreshPreview()
+	}
+	if !m.bulkOnly {
+		return m, tea.Batch(detail, m.toasts.Push("Showing all mail"))
+	}
+	return m, tea.Batch(detail, m.toasts.Push(fmt.Sprintf("Showing bulk mail only (%s)", plural(len(m.groupsList.Items()), "group"))))
 }
 
-func groupsToItems(groups []model.SenderGroup) []list.Item {
+// groupsToItems wraps groups for the list, marking those whose sender is
+// in queued.
+func groupsToItems(groups []model.SenderGroup, queued map[string]bool) []list.Item {
 	items := make([]list.Item, len(groups))
 	for i, g := range groups {
-		items[i] = groupItem{g}
+		items[i] = groupItem{g, queued[g.Email]}
 	}
 	return items
 }
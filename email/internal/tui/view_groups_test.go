@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"context"
+	"testing"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestGroupItemTitle(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		group model.SenderGroup
+		want  string
+	}{
+		{"count", model.SenderGroup{DisplayName: "Shop", Count: 3}, " Shop (3)"},
+		{"unread", model.SenderGroup{DisplayName: "Shop", Count: 3, Unread: 2}, " Shop (3, 2 unread)"},
+		{"size", model.SenderGroup{DisplayName: "Shop", Count: 3, Size: 1536}, " Shop (3, 1.5 KiB)"},
+		{"unread and size", model.SenderGroup{DisplayName: "Shop", Count: 3, Unread: 1, Size: 900}, " Shop (3, 1 unread, 900 B)"},
+	} {
+		if got := (groupItem{SenderGroup: tc.group}).Title(); got != tc.want {
+			t.Errorf("%s: %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGroupsTitleSize(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemoryStore()
+	if err := db.UpsertMessages(ctx, []model.MessageRef{
+		{ID: "a1", From: "a@x.example", Subject: "A", DateRFC3339: "2024-01-01T00:00:00Z", SizeBytes: 1024},
+		{ID: "a2", From: "a@x.example", Subject: "A", DateRFC3339: "2024-01-02T00:00:00Z", SizeBytes: 1024},
+		{ID: "b1", From: "b@x.example", Subject: "B", DateRFC3339: "2024-01-03T00:00:00Z", SizeBytes: 1024},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		sort gmail.GroupOrder
+		want string
+	}{
+		{gmail.OrderCount, "Inbox (2 groups)"},
+		{gmail.OrderNewest, "Inbox (2 groups, by newest)"},
+		{gmail.OrderSize, "Inbox (2 groups, by size, 3.0 KiB)"},
+	} {
+		m := NewAppModel(db, t.TempDir(), Options{Sort: tc.sort})
+		m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+		set, err := m.loadGroups(ctx, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.replaceGroups(set)
+		if got := m.groupsTitle(); got != tc.want {
+			t.Errorf("sorted by %s: %q; want %q", tc.sort, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package tui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
+
+	"common/ui"
+)
+
+// generalKeys work in every view except while typing a filter.
+var generalKeys = []ui.Key{
+	{Keys: "?", Help: "show this help"},
+	{Keys: "ctrl+c", Help: "quit"},
+}
+
+// viewKeys returns the usage name and the bindings of the current view, or
+// no bindings where help is not offered (loading and authentication).
+func (m *AppModel) viewKeys() (string, []ui.Key) {
+	switch m.view {
+	case viewGroups:
+		return "groups", m.groupKeys()
+	case viewMessages:
+		return "messages", messageKeys
+	case viewBody:
+		return "body", bodyKeys
+	case viewUnsubscribe:
+		return "unsubscribe", unsubscribeKeys
+	case viewStats:
+		return "stats", statsKeys
+	case viewRules:
+		return "rules", rulesKeys
+	case viewDrafts:
+		return "drafts", draftsKeys
+	case viewAccounts:
+		return "accounts", accountsKeys
+	case viewTrash:
+		return "trash", trashKeys
+	case viewProfile:
+		return "profile", m.opts.Keys.profileKeys()
+	}
+	return "", nil
+}
+
+// helpSections lists the current view's own bindings, then the navigation
+// keys of the list or viewport it shows, read from that widget's key map
+// so disabled bindings (e.g. paging a one-page list) are left out.
+func (m *AppModel) helpSections() []ui.HelpSection {
+	_, keys := m.viewKeys()
+	var title string
+	var nav []ui.Key
+	switch m.view {
+	case viewGroups:
+		title, nav = "Groups", listKeys(m.groupsList.FullHelp())
+	case viewMessages:
+		title, nav = "Messages", listKeys(m.messagesList.FullHelp())
+	case viewBody:
+		title, nav = "Message", viewportKeys(m.bodyViewport.KeyMap)
+	case viewUnsubscribe:
+		title, nav = "Unsubscribe queue", viewportKeys(m.reportViewport.KeyMap)
+	case viewStats:
+		title, nav = "Stats", viewportKeys(m.statsViewport.KeyMap)
+	case viewRules:
+		title, nav = "Rules", listKeys(m.rulesList.FullHelp())
+	case viewDrafts:
+		title, nav = "Drafts", listKeys(m.draftsList.FullHelp())
+	case viewAccounts:
+		title, nav = "Accounts", listKeys(m.accountsList.FullHelp())
+	case viewTrash:
+		title, nav = "Trash", listKeys(m.trashList.FullHelp())
+	case viewProfile:
+		title, nav = "Sender profile", listKeys(m.profileList.FullHelp())
+	}
+	return []ui.HelpSection{
+		{Title: title, Keys: keys},
+		{Title: "Navigation", Keys: withoutKeys(nav, keys, generalKeys)},
+		{Title: "General", Keys: generalKeys},
+	}
+}
+
+// helpOpen reports whether ? opens the help overlay now: not before the
+// groups are loaded, and not while a list filter is being typed.
+func (m *AppModel) helpOpen() bool {
+	switch m.view {
+	case viewGroups:
+		return m.groupsList.FilterState() != list.Filtering
+	case viewMessages:
+		return m.messagesList.FilterState() != list.Filtering
+	case viewRules:
+		return m.rulesList.FilterState() != list.Filtering && !m.ruleInput.Focused()
+	case viewDrafts:
+		return m.draftsList.FilterState() != list.Filtering
+	case viewAccounts:
+		return m.accountsList.FilterState() != list.Filtering
+	case viewTrash:
+		return m.trashList.FilterState() != list.Filtering
+	case viewProfile:
+		return m.profileList.FilterState() != list.Filtering
+	case viewBody, viewUnsubscribe, viewStats:
+		return true
+	}
+	return false
+}
+
+func listKeys(groups [][]key.Binding) []ui.Key {
+	var out []ui.Key
+	for _, g := range groups {
+		out = append(out, bindingKeys(g)...)
+	}
+	return out
+}
+
+func viewportKeys(km viewport.KeyMap) []ui.Key {
+	return bindingKeys([]key.Binding{km.Up, km.Down, km.PageUp, km.PageDown, km.HalfPageUp, km.HalfPageDown, km.Left, km.Right})
+}
+
+// bindingKeys converts the enabled bindings that have help text.
+func bindingKeys(bs []key.Binding) []ui.Key {
+	var out []ui.Key
+	for _, b := range bs {
+		h := b.Help()
+		if b.Enabled() && h.Key != "" {
+			out = append(out, ui.Key{Keys: h.Key, Help: h.Desc})
+		}
+	}
+	return out
+}
+
+// withoutKeys drops the entries of keys already documented in one of the
+// other sections, so the bindings AppModel intercepts (q, ?) are not listed
+// twice with the widget's meaning.
+func withoutKeys(keys []ui.Key, other ...[]ui.Key) []ui.Key {
+	taken := map[string]bool{}
+	for _, o := range other {
+		for _, k := range o {
+			taken[k.Keys] = true
+		}
+	}
+	var out []ui.Key
+	for _, k := range keys {
+		if !taken[k.Keys] {
+			taken[k.Keys] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
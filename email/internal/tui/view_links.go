@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"chuckterm/internal/gmail"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// promptLink opens the prompt for the number of the link to open, starting
+// with digit when one was typed. With fewer than ten links a digit is the
+// whole number, so that link opens straight away.
+func (m *AppModel) promptLink(digit string) (tea.Model, tea.Cmd) {
+	if len(m.links) == 0 {
+		return m, m.toasts.Push("This message has no links")
+	}
+	if digit != "" && len(m.links) < 10 {
+		return m.openLinkNumber(digit)
+	}
+	m.linkInput.Reset()
+	m.linkInput.SetValue(digit)
+	return m, m.linkInput.Focus()
+}
+
+func (m *AppModel) handleLinkInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.linkInput.Blur()
+		return m, nil
+	case "enter":
+		m.linkInput.Blur()
+		return m.openLinkNumber(m.linkInput.Value())
+	}
+	var cmd tea.Cmd
+	m.linkInput, cmd = m.linkInput.Update(msg)
+	return m, cmd
+}
+
+// openLinkNumber opens link n of the open message: web links in the
+// browser, mailto: links in the compose form.
+func (m *AppModel) openLinkNumber(n string) (tea.Model, tea.Cmd) {
+	i, err := gmail.LinkNumber(n, len(m.links))
+	if err != nil {
+		return m, m.toasts.Push(err.Error())
+	}
+	link := m.links[i-1]
+	if strings.HasPrefix(strings.ToLower(link), "mailto:") {
+		if m.service == nil {
+			return m, m.toasts.Push("Writing mail needs a Gmail account")
+		}
+		if m.opts.ReadOnly && !m.opts.DryRun {
+			return m, m.toasts.Push(readOnlyNotice)
+		}
+		d, err := gmail.MailtoDraft(link)
+		if err != nil {
+			return m, m.toasts.Push(err.Error())
+		}
+		d.Body = gmail.AppendSignature(d.Body, m.signature())
+		return m.compose(d)
+	}
+	if err := gmail.OpenBrowser(link); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Opening link %d failed: %v", i, err))
+	}
+	return m, m.toasts.Push(fmt.Sprintf("Opened link %d: %s", i, link))
+}
@@ -5,8 +5,10 @@ import (
 	"sort"
 
 	"chuckterm/internal/model"
+	"common/ui"
 
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
 )
 
 // messageItem wraps MessageRef for the list display.
@@ -15,7 +17,12 @@ type messageItem struct {
 }
 
 func (m messageItem) FilterValue() string { return m.Subject }
-func (m messageItem) Title() string       { return m.Subject }
+func (m messageItem) Title() string {
+	if m.Unread() {
+		return "• " + m.Subject
+	}
+	return m.Subject
+}
 func (m messageItem) Description() string {
 	if m.DateRFC3339 != "" {
 		return fmt.Sprintf("From: %s  Date: %s", m.From, trimDate(m.DateRFC3339))
@@ -23,8 +30,19 @@ func (m messageItem) Description() string {
 	return fmt.Sprintf("From: %s", m.From)
 }
 
-func messagesFooter() string {
-	return footerStyle.Render("enter: view body  esc: back  q: quit")
+// messageKeys are the bindings of the messages view.
+var messageKeys = []ui.Key{
+	{Keys: "enter", Help: "view body"},
+	{Keys: "s", Help: "search"},
+	{Keys: "v", Help: "preview"},
+	{Keys: "x", Help: "export"},
+	{Keys: "z", Help: "snooze"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+func messagesFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(messageKeys))
 }
 
 // sortedMessageItems returns MessageRefs sorted reverse chronologically as list items.
@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// previewDelay is how long the highlight rests on a message before its body
+// is fetched for the preview, so holding j doesn't fetch every message it
+// passes.
+const previewDelay = 150 * time.Millisecond
+
+// previewing reports whether the body pane follows the highlighted message.
+// Compact terminals have no room for a second pane.
+func (m *AppModel) previewing() bool {
+	return m.preview && m.layout != layoutCompact
+}
+
+// split reports whether the standard layout is divided between the messages
+// list and the preview; the wide layout has its own body pane.
+func (m *AppModel) split() bool {
+	return m.previewing() && m.layout == layoutSingle
+}
+
+// togglePreview turns the preview pane on or off.
+func (m *AppModel) togglePreview() (tea.Model, tea.Cmd) {
+	if m.layout == layoutCompact {
+		return m, m.toasts.Push("The terminal is too small for a preview")
+	}
+	m.preview = !m.preview
+	m.previewMsgID = ""
+	m.resize()
+	if !m.preview {
+		return m, m.toasts.Push("Preview off")
+	}
+	return m, tea.Batch(m.toasts.Push("Preview on"), m.previewCmd())
+}
+
+// previewCmd schedules a preview of the highlighted message once the
+// highlight has rested on it for previewDelay.
+func (m *AppModel) previewCmd() tea.Cmd {
+	if !m.previewing() || m.view != viewMessages {
+		return nil
+	}
+	mi, ok := m.messagesList.SelectedItem().(messageItem)
+	if !ok || mi.ID == m.previewMsgID {
+		return nil
+	}
+	m.previewMsgID = mi.ID
+	id := mi.ID
+	return tea.Tick(previewDelay, func(time.Time) tea.Msg { return previewTickMsg{id: id} })
+}
+
+// previewBodyCmd fetches a body for the preview pane.
+func (m *AppModel) previewBodyCmd(id string) tea.Cmd {
+	fetch := m.fetchBodyCmd(id)
+	return func() tea.Msg {
+		msg := fetch().(bodyFetchedMsg)
+		msg.preview = true
+		return msg
+	}
+}
+
+// showPreview puts a fetched body in the preview pane, unless the highlight
+// has moved on since it was asked for.
+func (m *AppModel) showPreview(msg bodyFetchedMsg) {
+	mi, ok := m.messagesList.SelectedItem().(messageItem)
+	if !ok || mi.ID != msg.id || m.view != viewMessages {
+		return
+	}
+	m.bodyShown = true
+	m.bodyViewport.GotoTop()
+	if msg.err != nil {
+		m.bodyMsgID = ""
+		m.bodyViewport.SetContent(placeholderStyle.Render(fmt.Sprintf("Cannot load a preview: %v", msg.err)))
+		return
+	}
+	ref := mi.MessageRef
+	m.selectedMsg = &ref
+	m.body = msg.body
+	m.bodyMsgID = msg.id
+	m.attachmentIdx = 0
+	m.renderBody()
+}
+
+// splitView renders the messages list beside the preview pane.
+func (m *AppModel) splitView() string {
+	mw := m.width / 2
+	h := m.height - m.chromeHeight()
+	body := placeholderStyle.Render("The highlighted message shows here.")
+	if m.bodyShown {
+		body = m.bodyViewport.View()
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top,
+		pane(m.messagesList.View(), mw, h, m.view == viewMessages),
+		pane(body, m.width-mw, h, m.view == viewBody),
+	)
+}
@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"context"
+	"testing"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// messagesModel opens a group of three messages, m1 newest, in a terminal
+// of the given size, with their bodies already in memory.
+func messagesModel(t *testing.T, opts Options, width, height int) *AppModel {
+	t.Helper()
+	db := store.NewMemoryStore()
+	msgs := []model.MessageRef{
+		{ID: "m1", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-01-03T00:00:00Z"},
+		{ID: "m2", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-01-02T00:00:00Z"},
+		{ID: "m3", From: "news@shop.example", Subject: "Sale", DateRFC3339: "2024-01-01T00:00:00Z"},
+	}
+	if err := db.UpsertMessages(context.Background(), msgs); err != nil {
+		t.Fatal(err)
+	}
+	opts.BodyMemory = 10
+	m := NewAppModel(db, t.TempDir(), opts)
+	for _, r := range msgs {
+		m.bodies.Add(r.ID, model.MessageBody{Text: "body of " + r.ID})
+	}
+	m.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	m.showGroupMessages(model.SenderGroup{Email: "news@shop.example", Subject: "Sale", Count: len(msgs), MessageIDs: []string{"m1", "m2", "m3"}})
+	m.view = viewMessages
+	return &m
+}
+
+func TestPreviewLayout(t *testing.T) {
+	for _, tc := range []struct {
+		name                    string
+		width, height           int
+		previewing, split, wide bool
+	}{
+		{"compact", 70, 30, false, false, false},
+		{"standard", 120, 40, true, true, false},
+		{"wide", 200, 50, true, false, true},
+	} {
+		m := messagesModel(t, Options{Preview: true}, tc.width, tc.height)
+		if m.previewing() != tc.previewing || m.split() != tc.split || (m.layout == layoutWide) != tc.wide {
+			t.Errorf("%s: previewing %v, split %v, layout %d", tc.name, m.previewing(), m.split(), m.layout)
+		}
+	}
+
+	m := messagesModel(t, Options{}, 70, 30)
+	m.togglePreview()
+	if m.preview {
+		t.Error("preview turned on in a compact terminal")
+	}
+}
+
+func TestPreviewFollowsHighlight(t *testing.T) {
+	m := messagesModel(t, Options{Preview: true}, 120, 40)
+	tick := m.previewCmd()
+	if tick == nil {
+		t.Fatal("no preview scheduled for the highlighted message")
+	}
+	if m.previewCmd() != nil {
+		t.Error("the same message was scheduled twice")
+	}
+
+	// The highlight moves on before the tick fires: m1 is not fetched.
+	m.messagesList.Select(1)
+	next := m.previewCmd()
+	if _, cmd := m.Update(tick()); cmd != nil {
+		t.Error("a stale tick fetched a body")
+	}
+	_, fetch := m.Update(next())
+	if fetch == nil {
+		t.Fatal("the tick for the highlighted message fetched nothing")
+	}
+	m.Update(fetch())
+	if !m.bodyShown || m.bodyMsgID != "m2" || m.view != viewMessages {
+		t.Fatalf("preview shows %q (shown %v), view %d", m.bodyMsgID, m.bodyShown, m.view)
+	}
+
+	// enter on the previewed message only moves the focus.
+	if _, cmd := m.enterMessage(); cmd != nil || m.view != viewBody {
+		t.Errorf("enter fetched again or left the focus on view %d", m.view)
+	}
+}
+
+func TestPreviewIgnoresMovedHighlight(t *testing.T) {
+	m := messagesModel(t, Options{Preview: true}, 120, 40)
+	m.previewCmd()
+	fetch := m.previewBodyCmd("m1")
+	m.messagesList.Select(2)
+	m.Update(fetch())
+	if m.bodyShown {
+		t.Errorf("the preview shows %q after the highlight moved to m3", m.bodyMsgID)
+	}
+}
@@ -0,0 +1,279 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// profileHeaderHeight is the number of lines the sender summary above the
+// profile's subject list occupies.
+const profileHeaderHeight = 6
+
+// profileItem shows one subject group in the sender profile.
+type profileItem struct {
+	model.SenderGroup
+}
+
+func (p profileItem) FilterValue() string { return p.Subject }
+func (p profileItem) Title() string {
+	subject := p.Subject
+	if strings.TrimSpace(subject) == "" {
+		subject = "(no subject)"
+	}
+	counts := strconv.Itoa(p.Count)
+	if p.Unread > 0 {
+		counts += fmt.Sprintf(", %d unread", p.Unread)
+	}
+	if p.Size > 0 {
+		counts += ", " + humanSize(p.Size)
+	}
+	return fmt.Sprintf("%s (%s)", subject, counts)
+}
+func (p profileItem) Description() string {
+	desc := trimDate(p.FirstDate) + " – " + trimDate(p.LastDate)
+	if spark := monthSparkline(p.Months, time.Now()); spark != "" {
+		return spark + " " + desc
+	}
+	return desc
+}
+
+// profileKeys are the bindings of the sender profile under k, whose actions
+// cover every subject group of the sender.
+func (k Keymap) profileKeys() []ui.Key {
+	return []ui.Key{
+		{Keys: "enter", Help: "open subject"},
+		{Keys: k.Archive, Help: "archive all"},
+		{Keys: k.Trash, Help: "trash all"},
+		{Keys: k.Unsubscribe, Help: "unsubscribe"},
+		{Keys: k.Protect, Help: "protect"},
+		{Keys: "r", Help: "reload"},
+		{Keys: "esc", Help: "back"},
+		{Keys: "q", Help: "quit"},
+	}
+}
+
+func profileFooter(style lipgloss.Style, keys []ui.Key) string {
+	return style.Render(ui.Hints(keys))
+}
+
+// openProfile switches to the profile of the highlighted group's sender.
+func (m *AppModel) openProfile() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	if gi.IsDomain() {
+		return m, m.toasts.Push(fmt.Sprintf("Profiles are per sender; press %s to group by sender", m.opts.Keys.Domains))
+	}
+	if m.store == nil {
+		return m, m.toasts.Push("Sender profiles need a local store")
+	}
+	m.view = viewProfile
+	m.profile = gmail.SenderProfile{Email: gi.Email, DisplayName: gi.DisplayName}
+	m.profileList.ResetFilter()
+	m.profileList.SetItems(nil)
+	return m, m.profileCmd()
+}
+
+// profileCmd loads the subject groups of the profile's sender.
+func (m *AppModel) profileCmd() tea.Cmd {
+	m.statusBar.Text = "Loading the sender profile..."
+	email, name := m.profile.Email, m.profile.DisplayName
+	return func() tea.Msg {
+		groups, err := gmail.LoadSenderGroups(context.Background(), m.store, email)
+		return profileLoadedMsg{email: email, name: name, groups: groups, err: err}
+	}
+}
+
+func (m *AppModel) profileLoaded(msg profileLoadedMsg) tea.Cmd {
+	if msg.email != m.profile.Email {
+		return nil // another sender was opened since
+	}
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Loading the sender profile failed: %v", msg.err))
+	}
+	gmail.ApplySenderLists(msg.groups, m.senders)
+	gmail.ApplyUnsubscribes(msg.groups, m.unsubs)
+	m.profile = gmail.NewSenderProfile(msg.email, msg.groups)
+	if m.profile.DisplayName == msg.email {
+		// Groups aggregated by the store carry no display name.
+		m.profile.DisplayName = msg.name
+	}
+	items := make([]list.Item, len(msg.groups))
+	for i, g := range msg.groups {
+		items[i] = profileItem{g}
+	}
+	m.profileList.Title = fmt.Sprintf("%s: %s", m.profile.DisplayName, plural(len(items), "subject"))
+	return m.profileList.SetItems(items)
+}
+
+// renderProfile draws the summary of a sender above its subject list.
+func renderProfile(p gmail.SenderProfile, width int) string {
+	var sb strings.Builder
+	sb.WriteString(p.DisplayName)
+	if p.DisplayName != p.Email {
+		sb.WriteString(" <" + p.Email + ">")
+	}
+	if p.Status != "" {
+		sb.WriteString("  " + string(p.Status))
+	}
+	fmt.Fprintf(&sb, "\n%s", plural(p.Count, "message"))
+	if p.Unread > 0 {
+		fmt.Fprintf(&sb, ", %d unread", p.Unread)
+	}
+	fmt.Fprintf(&sb, " in %s", plural(len(p.Groups), "subject"))
+	if p.Size > 0 {
+		sb.WriteString(" · " + humanSize(p.Size))
+	}
+	fmt.Fprintf(&sb, "\nfirst %s · last %s\n", trimDate(p.FirstDate), trimDate(p.LastDate))
+	switch u := p.Unsubscribe; {
+	case u == nil:
+		sb.WriteString("no unsubscribe link")
+	case u.UnsubscribeOneClick:
+		sb.WriteString("one-click unsubscribe")
+	case u.UnsubscribeURL != "":
+		sb.WriteString("unsubscribe page")
+	default:
+		sb.WriteString("unsubscribe by email")
+	}
+	if u := p.Unsubscribed; u != nil {
+		fmt.Fprintf(&sb, " · unsubscribed %s (%s)", u.Time.Format(time.DateOnly), u.Method)
+	}
+	return detailStyle.Width(max(width-2, 20)).Render(sb.String())
+}
+
+// handleProfileKey handles the keys of the sender profile.
+func (m *AppModel) handleProfileKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.profileList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.profileList, cmd = m.profileList.Update(msg)
+		return m, cmd
+	}
+	km := m.opts.Keys
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "esc":
+		m.view = viewGroups
+		return m, nil
+	case "enter":
+		pi, ok := m.profileList.SelectedItem().(profileItem)
+		if !ok {
+			return m, nil
+		}
+		g := pi.SenderGroup
+		m.selectedGroup = &g
+		m.showGroupMessages(g)
+		m.previewKey = g.Email + "||" + g.Subject
+		m.view = viewMessages
+		m.messagesReturn = viewProfile
+		return m, tea.Batch(m.previewCmd(), m.prefetchCmd(m.groupMsgs))
+	case "r":
+		return m, m.profileCmd()
+	case km.Archive:
+		return m.askProfileAction(confirmArchiveSender, "Archive")
+	case km.Trash:
+		return m.askProfileAction(confirmTrashSender, "Move to trash")
+	case km.Unsubscribe:
+		if m.profile.Unsubscribe == nil {
+			return m, m.toasts.Push("No unsubscribe link available for this sender")
+		}
+		return m.unsubscribeGroup(*m.profile.Unsubscribe)
+	case km.Protect:
+		if len(m.profile.Groups) == 0 {
+			return m, nil
+		}
+		g := m.profile.Groups[0]
+		g.DisplayName = m.profile.DisplayName
+		_, cmd := m.toggleStatusOf(g, model.SenderProtected)
+		return m, tea.Batch(cmd, m.profileCmd())
+	}
+	var cmd tea.Cmd
+	m.profileList, cmd = m.profileList.Update(msg)
+	return m, cmd
+}
+
+// askProfileAction asks before applying verb to all mail of the profile's
+// sender.
+func (m *AppModel) askProfileAction(id, verb string) (tea.Model, tea.Cmd) {
+	p := m.profile
+	if p.Count == 0 {
+		return m, nil
+	}
+	if m.opts.Spam {
+		verb = map[string]string{confirmArchiveSender: "Move to the inbox", confirmTrashSender: "Delete forever"}[id]
+	}
+	prompt := fmt.Sprintf("%s all %s from %s, in %s?", verb, plural(p.Count, "message"), p.DisplayName, plural(len(p.Groups), "subject"))
+	if p.Status == model.SenderProtected {
+		prompt = fmt.Sprintf("%s is protected. %s", p.DisplayName, prompt)
+	}
+	m.confirm.Ask(id, prompt)
+	return m, nil
+}
+
+// actOnSender archives or trashes every subject group of the profile's
+// sender and returns to the groups, which no longer list it.
+func (m *AppModel) actOnSender(trash bool) (tea.Model, tea.Cmd) {
+	groups := m.profile.Groups
+	if !m.changesNothing() && (trash || m.archiveHides()) {
+		for _, g := range slices.Clone(m.groups) {
+			if g.Email == m.profile.Email {
+				m.removeGroup(g)
+			}
+		}
+		m.showGroups()
+	}
+	m.view = viewGroups
+	if trash {
+		m.statusBar.Text = "Trashing..."
+		if m.opts.Spam {
+			m.statusBar.Text = "Deleting..."
+		}
+		return m, m.trashGroupsCmd(groups)
+	}
+	m.statusBar.Text = "Archiving..."
+	if m.opts.Spam {
+		m.statusBar.Text = "Moving to the inbox..."
+	}
+	return m, m.archiveGroupsCmd(groups)
+}
+
+// archiveGroupsCmd archives the mail of groups, one group after another,
+// stopping at the first failure.
+func (m *AppModel) archiveGroupsCmd(groups []model.SenderGroup) tea.Cmd {
+	verb, action := "archive", "Archive"
+	if m.opts.Spam {
+		verb, action = "move to the inbox", "Moving to the inbox"
+	}
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		total := 0
+		for _, g := range groups {
+			n, err := m.archiveGroup(ctx, g)
+			total += n
+			if !gmail.IsDryRun(ctx) {
+				m.recordAction(model.Action{Kind: "archive", Sender: g.Email, Subject: g.Subject, Messages: n})
+			}
+			if err != nil {
+				return actionResultMsg{action: action, err: fmt.Errorf("%s: %w", g.Subject, err)}
+			}
+		}
+		if gmail.IsDryRun(ctx) {
+			return actionResultMsg{dryRun: fmt.Sprintf("%s %s from %s", verb, plural(total, "message"), plural(len(groups), "group"))}
+		}
+		return actionResultMsg{action: action}
+	})
+}
@@ -0,0 +1,298 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ruleItem shows one rule in the rules view, with its latest logged run.
+type ruleItem struct {
+	model.Rule
+	last *model.RuleRun
+}
+
+func (r ruleItem) FilterValue() string { return gmail.FormatRule(r.Rule) }
+func (r ruleItem) Title() string       { return gmail.FormatRule(r.Rule) }
+func (r ruleItem) Description() string {
+	desc := "runs after each sync"
+	if r.Every != "" {
+		desc = "cleanup job, runs " + r.Every + " in the daemon"
+	}
+	if r.Disabled {
+		desc = "disabled"
+	}
+	switch {
+	case r.last == nil:
+	case r.last.Err != "":
+		desc += fmt.Sprintf(" · failed %s: %s", r.last.Time.Format("2006-01-02 15:04"), r.last.Err)
+	default:
+		desc += fmt.Sprintf(" · last run %s changed %d", r.last.Time.Format("2006-01-02 15:04"), r.last.Messages)
+	}
+	return desc
+}
+
+// rulesKeys are the bindings of the rules view.
+var rulesKeys = []ui.Key{
+	{Keys: "a", Help: "add"},
+	{Keys: "enter", Help: "edit"},
+	{Keys: "e", Help: "enable/disable"},
+	{Keys: "d", Help: "delete"},
+	{Keys: "r", Help: "run it now"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+func rulesFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(rulesKeys))
+}
+
+// openRules switches to the rules view.
+func (m *AppModel) openRules() (tea.Model, tea.Cmd) {
+	if _, ok := m.store.(gmail.RuleStore); !ok {
+		return m, m.toasts.Push("Rules need a local store")
+	}
+	if err := m.loadRules(); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Loading rules failed: %v", err))
+	}
+	m.view = viewRules
+	return m, nil
+}
+
+// loadRules fills the rules list from the store.
+func (m *AppModel) loadRules() error {
+	rs := m.store.(gmail.RuleStore)
+	rules, err := rs.LoadRules(context.Background())
+	if err != nil {
+		return err
+	}
+	last, err := rs.LastRuleRuns(context.Background())
+	if err != nil {
+		return err
+	}
+	items := make([]list.Item, len(rules))
+	for i, r := range rules {
+		it := ruleItem{Rule: r}
+		if run, ok := last[r.ID]; ok {
+			it.last = &run
+		}
+		items[i] = it
+	}
+	m.rulesList.SetItems(items)
+	m.rulesList.Title = fmt.Sprintf("Rules (%d)", len(rules))
+	return nil
+}
+
+// handleRulesKey handles the keys of the rules view.
+func (m *AppModel) handleRulesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.rulesList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.rulesList, cmd = m.rulesList.Update(msg)
+		return m, cmd
+	}
+	selected, ok := m.rulesList.SelectedItem().(ruleItem)
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "esc":
+		m.view = viewGroups
+		return m, nil
+	case "a":
+		return m.editRule(model.Rule{})
+	case "enter":
+		if ok {
+			return m.editRule(selected.Rule)
+		}
+		return m, nil
+	case "e":
+		if !ok {
+			return m, nil
+		}
+		selected.Disabled = !selected.Disabled
+		return m.saveRule(selected.Rule)
+	case "d":
+		if ok {
+			m.confirm.Ask(confirmDeleteRule, fmt.Sprintf("Delete the rule %s?", gmail.FormatRule(selected.Rule)))
+		}
+		return m, nil
+	case "r":
+		if !ok {
+			return m, nil
+		}
+		m.statusBar.Text = "Running the rule..."
+		return m, m.rulesCmd(&selected.Rule)
+	}
+	var cmd tea.Cmd
+	m.rulesList, cmd = m.rulesList.Update(msg)
+	return m, cmd
+}
+
+// editRule opens the rule prompt on r; a rule with ID 0 is added.
+func (m *AppModel) editRule(r model.Rule) (tea.Model, tea.Cmd) {
+	m.ruleEditID = r.ID
+	m.ruleInput.SetValue("")
+	if r.ID != 0 {
+		m.ruleInput.SetValue(gmail.FormatRule(r))
+	}
+	m.ruleInput.CursorEnd()
+	return m, m.ruleInput.Focus()
+}
+
+// handleRuleInput edits the rule prompt: enter saves the rule, esc drops
+// the change.
+func (m *AppModel) handleRuleInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.ruleInput.Blur()
+		return m, nil
+	case "enter":
+		r, err := gmail.ParseRule(m.ruleInput.Value())
+		if err != nil {
+			return m, m.toasts.Push(err.Error())
+		}
+		r.ID = m.ruleEditID
+		if sel, ok := m.rulesList.SelectedItem().(ruleItem); ok && sel.ID == r.ID {
+			r.Disabled = sel.Disabled
+		}
+		m.ruleInput.Blur()
+		return m.saveRule(r)
+	}
+	var cmd tea.Cmd
+	m.ruleInput, cmd = m.ruleInput.Update(msg)
+	return m, cmd
+}
+
+// saveRule stores r and redraws the list with r highlighted.
+func (m *AppModel) saveRule(r model.Rule) (tea.Model, tea.Cmd) {
+	id, err := m.store.(gmail.RuleStore).SaveRule(context.Background(), r)
+	if err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Saving the rule failed: %v", err))
+	}
+	if err := m.loadRules(); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Loading rules failed: %v", err))
+	}
+	for i, it := range m.rulesList.Items() {
+		if it.(ruleItem).ID == id {
+			m.rulesList.Select(i)
+		}
+	}
+	if r.ID == 0 {
+		if r.Every != "" {
+			return m, m.toasts.Push("Added the cleanup job; the daemon runs it " + r.Every + ", or run it now with r")
+		}
+		return m, m.toasts.Push("Added the rule; it runs after each sync, or now with r")
+	}
+	return m, nil
+}
+
+// deleteSelectedRule removes the highlighted rule.
+func (m *AppModel) deleteSelectedRule() (tea.Model, tea.Cmd) {
+	r, ok := m.rulesList.SelectedItem().(ruleItem)
+	if !ok {
+		return m, nil
+	}
+	if err := m.store.(gmail.RuleStore).DeleteRule(context.Background(), r.ID); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Deleting the rule failed: %v", err))
+	}
+	if err := m.loadRules(); err != nil {
+		return m, m.toasts.Push(fmt.Sprintf("Loading rules failed: %v", err))
+	}
+	return m, m.toasts.Push("Deleted the rule")
+}
+
+// rulesCmd runs the rules over the cache, as after each sync, or only the
+// rule given, from the rules view, and reloads the groups when they changed
+// anything.
+func (m *AppModel) rulesCmd(only *model.Rule) tea.Cmd {
+	if m.store == nil || m.service == nil {
+		return nil
+	}
+	// Reload as many groups as are loaded now, so the highlight stays put.
+	window := m.groupsOffset
+	manual := only != nil
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		var results []gmail.RuleResult
+		var err error
+		if only != nil {
+			var res gmail.RuleResult
+			res, err = gmail.RunRule(ctx, m.service, m.store, *only, time.Now())
+			results = []gmail.RuleResult{res}
+		} else {
+			results, err = gmail.RunRules(ctx, m.service, m.store, time.Now())
+		}
+		out := rulesRanMsg{results: results, manual: manual, err: err}
+		if only == nil {
+			// Snoozed mail comes back after the rules, which leave it be
+			// until the next sync.
+			woken, err := gmail.WakeSnoozes(ctx, m.service, m.store, time.Now())
+			out.woken, out.wakeErr = len(woken), err
+		}
+		changed := out.woken > 0
+		for _, r := range results {
+			changed = changed || r.Messages > 0
+		}
+		if changed {
+			groups, err := m.loadGroups(ctx, window)
+			out.groups = &groups
+			if out.err == nil {
+				out.err = err
+			}
+		}
+		return out
+	})
+}
+
+// rulesRan reports a rules pass in a toast. A pass after a sync that
+// changed nothing stays quiet.
+func (m *AppModel) rulesRan(msg rulesRanMsg) tea.Cmd {
+	if msg.manual {
+		m.statusBar.Text = ""
+	}
+	if m.view == viewRules {
+		if err := m.loadRules(); err != nil {
+			return m.toasts.Push(fmt.Sprintf("Loading rules failed: %v", err))
+		}
+	}
+	var cmd tea.Cmd
+	switch {
+	case msg.wakeErr != nil:
+		cmd = m.toasts.Push(fmt.Sprintf("Unsnoozing failed: %v", msg.wakeErr))
+	case msg.woken > 0:
+		cmd = m.toasts.Push(capitalize(plural(msg.woken, "snoozed message")) + " back in the inbox")
+	}
+	if msg.groups != nil {
+		cmd = tea.Batch(cmd, m.replaceGroups(*msg.groups))
+	}
+	if msg.err != nil {
+		return tea.Batch(cmd, m.toasts.Push(fmt.Sprintf("Rules failed: %v", msg.err)))
+	}
+	summary, err := gmail.RulesSummary(msg.results)
+	switch {
+	case err != nil && summary != "":
+		return tea.Batch(cmd, m.toasts.Push(fmt.Sprintf("%s; %v", capitalize(summary), err)))
+	case err != nil:
+		return tea.Batch(cmd, m.toasts.Push(err.Error()))
+	case summary != "":
+		return tea.Batch(cmd, m.toasts.Push(capitalize(summary)))
+	case msg.manual:
+		return tea.Batch(cmd, m.toasts.Push("The rule matched nothing"))
+	}
+	return cmd
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,125 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"chuckterm/internal/model"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// searchTerms splits a messages view search into its lowercased terms.
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// searchMessages returns the messages whose subject, snippet or date contains
+// every term. The date matches both as shown ("Jan 2, 2006") and as
+// YYYY-MM-DD.
+func searchMessages(msgs []model.MessageRef, terms []string) []model.MessageRef {
+	if len(terms) == 0 {
+		return msgs
+	}
+	var out []model.MessageRef
+	for _, msg := range msgs {
+		text := strings.ToLower(strings.Join([]string{msg.Subject, msg.Snippet, trimDate(msg.DateRFC3339), msg.DateRFC3339}, "\n"))
+		matched := true
+		for _, t := range terms {
+			if !strings.Contains(text, t) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// matchedRunes returns the indexes of the runes of s covered by a term,
+// ignoring case, in the form lipgloss.StyleRunes takes.
+func matchedRunes(s string, terms []string) []int {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	covered := make([]bool, len(runes))
+	for _, t := range terms {
+		term := []rune(t)
+		for i := 0; i+len(term) <= len(runes); i++ {
+			if string(runes[i:i+len(term)]) == t {
+				for j := range term {
+					covered[i+j] = true
+				}
+			}
+		}
+	}
+	var idx []int
+	for i, c := range covered {
+		if c {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// snippetExcerpt returns the snippet from a little before its first match,
+// so the match shows even when the snippet is longer than the row.
+func snippetExcerpt(snippet string, terms []string) string {
+	idx := matchedRunes(snippet, terms)
+	const lead = 20
+	if len(idx) == 0 || idx[0] <= lead {
+		return snippet
+	}
+	return "…" + string([]rune(snippet)[idx[0]-lead:])
+}
+
+// searchDelegate draws the messages list with the terms of the messages
+// view search highlighted. Without terms it draws like its DefaultDelegate.
+type searchDelegate struct {
+	list.DefaultDelegate
+	terms []string
+}
+
+// Render mirrors list.DefaultDelegate.Render, highlighting search matches
+// instead of the list's own filter matches. While searching, the description
+// shows the date and the matching part of the snippet.
+func (d searchDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	mi, ok := item.(messageItem)
+	if !ok || len(d.terms) == 0 || m.Width() <= 0 {
+		d.DefaultDelegate.Render(w, m, index, item)
+		return
+	}
+	s := &d.Styles
+	width := m.Width() - s.NormalTitle.GetPaddingLeft() - s.NormalTitle.GetPaddingRight()
+	title := ansi.Truncate(mi.Title(), width, "…")
+	desc := trimDate(mi.DateRFC3339)
+	if mi.Snippet != "" {
+		desc = strings.TrimPrefix(desc+" · "+snippetExcerpt(mi.Snippet, d.terms), " · ")
+	}
+	desc = ansi.Truncate(desc, width, "…")
+
+	titleStyle, descStyle := s.NormalTitle, s.NormalDesc
+	if index == m.Index() {
+		titleStyle, descStyle = s.SelectedTitle, s.SelectedDesc
+	}
+	title = titleStyle.Render(highlight(title, d.terms, titleStyle, s.FilterMatch))
+	if !d.ShowDescription {
+		fmt.Fprint(w, title) //nolint: errcheck
+		return
+	}
+	desc = descStyle.Render(highlight(desc, d.terms, descStyle, s.FilterMatch))
+	fmt.Fprintf(w, "%s\n%s", title, desc) //nolint: errcheck
+}
+
+// highlight styles the runes of s covered by a term with match on top of base.
+func highlight(s string, terms []string, base, match lipgloss.Style) string {
+	unmatched := base.Inline(true)
+	return lipgloss.StyleRunes(s, matchedRunes(s, terms), unmatched.Inherit(match), unmatched)
+}
@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"chuckterm/internal/gmail"
+	"common/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var setupErrorStyle = lipgloss.NewStyle().Foreground(ui.Bad)
+
+// setupKeys are the bindings of the first-run setup.
+var setupKeys = []ui.Key{
+	{Keys: "enter", Help: "save and sign in"},
+	{Keys: "esc", Help: "quit"},
+}
+
+// startSetup shows the first-run setup, which asks for the OAuth client
+// credentials chuckterm could not find.
+func (m *AppModel) startSetup() (tea.Model, tea.Cmd) {
+	m.view = viewSetup
+	m.statusBar.Text = ""
+	m.setupErr = ""
+	m.setupInput.Reset()
+	return m, m.setupInput.Focus()
+}
+
+// handleSetupKey edits the credentials prompt: enter checks and saves the
+// credentials, then signs in; esc quits.
+func (m *AppModel) handleSetupKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		return m, tea.Quit
+	case "enter":
+		path, err := gmail.InstallCredentials(m.configDir, m.setupInput.Value())
+		if err != nil {
+			m.setupErr = err.Error()
+			return m, nil
+		}
+		m.setupInput.Blur()
+		m.view = viewLoading
+		m.statusBar.Text = fmt.Sprintf("Saved %s. Authenticating...", path)
+		return m, m.authenticateCmd()
+	}
+	m.setupErr = ""
+	var cmd tea.Cmd
+	m.setupInput, cmd = m.setupInput.Update(msg)
+	return m, cmd
+}
+
+func (m *AppModel) setupView() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Welcome to chuckterm"))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "chuckterm talks to Gmail with OAuth client credentials of your own, which it looks for at\n%s. To create them:\n\n", gmail.CredentialsPath(m.configDir))
+	for i, step := range []string{
+		"Open https://console.cloud.google.com and create a project (or pick one).",
+		"Under APIs & Services > Library, enable the Gmail API.",
+		"Under OAuth consent screen, set the app up as External and add your address as a test user.",
+		"Under Credentials, create an OAuth client ID of type Desktop app and download its JSON.",
+	} {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, step)
+	}
+	b.WriteString("\nThen enter the path of the downloaded file, or paste its contents:\n\n")
+	b.WriteString(m.setupInput.View())
+	b.WriteString("\n\n")
+	if m.setupErr != "" {
+		b.WriteString(setupErrorStyle.Render(m.setupErr))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(m.footerStyle().Render(ui.Hints(setupKeys)))
+	return b.String()
+}
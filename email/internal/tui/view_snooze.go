@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// snoozeSelectedMessage opens the prompt for when the highlighted message
+// should come back.
+func (m *AppModel) snoozeSelectedMessage() (tea.Model, tea.Cmd) {
+	mi, ok := m.messagesList.SelectedItem().(messageItem)
+	if !ok {
+		return m, nil
+	}
+	if m.service == nil {
+		return m, m.toasts.Push("Snoozing needs a Gmail account")
+	}
+	if _, ok := m.store.(gmail.SnoozeStore); !ok {
+		return m, m.toasts.Push("Snoozing needs a local store")
+	}
+	m.snoozing = mi.MessageRef
+	m.snoozeInput.Reset()
+	return m, m.snoozeInput.Focus()
+}
+
+func (m *AppModel) handleSnoozeInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.snoozeInput.Blur()
+		return m, nil
+	case "enter":
+		until, err := gmail.ParseSnoozeTime(m.snoozeInput.Value(), time.Now())
+		if err != nil {
+			return m, m.toasts.Push(err.Error())
+		}
+		m.snoozeInput.Blur()
+		if !m.changesNothing() {
+			m.removeMessage(m.snoozing.ID)
+		}
+		m.statusBar.Text = "Snoozing..."
+		return m, m.snoozeCmd(m.snoozing, until)
+	}
+	var cmd tea.Cmd
+	m.snoozeInput, cmd = m.snoozeInput.Update(msg)
+	return m, cmd
+}
+
+// removeMessage drops message id from the open group's messages, going
+// back to the groups when none is left.
+func (m *AppModel) removeMessage(id string) {
+	for i, r := range m.groupMsgs {
+		if r.ID == id {
+			m.groupMsgs = append(m.groupMsgs[:i], m.groupMsgs[i+1:]...)
+			break
+		}
+	}
+	for i, it := range m.messagesList.Items() {
+		if it.(messageItem).ID == id {
+			m.messagesList.RemoveItem(i)
+			break
+		}
+	}
+	if len(m.groupMsgs) == 0 {
+		m.view = viewGroups
+		m.selectedGroup = nil
+	}
+}
+
+// snoozeCmd snoozes msg until until and reloads the groups it left.
+func (m *AppModel) snoozeCmd(msg model.MessageRef, until time.Time) tea.Cmd {
+	window := m.groupsOffset
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		if err := gmail.SnoozeMessages(ctx, m.service, m.store, []model.MessageRef{msg}, until); errors.Is(err, gmail.ErrDryRun) {
+			return snoozedMsg{until: until, dryRun: true}
+		} else if err != nil {
+			return snoozedMsg{err: err}
+		}
+		groups, err := m.loadGroups(ctx, window)
+		return snoozedMsg{until: until, groups: &groups, err: err}
+	})
+}
+
+func (m *AppModel) snoozed(msg snoozedMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Snooze failed: %v", msg.err))
+	}
+	if msg.dryRun {
+		return m.toasts.Push(m.skipped("snooze until " + msg.until.Format("Mon 2 Jan 15:04")))
+	}
+	return tea.Batch(m.replaceGroups(*msg.groups), m.toasts.Push("Snoozed until "+msg.until.Format("Mon 2 Jan 15:04")))
+}
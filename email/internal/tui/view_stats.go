@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"chuckterm/internal/report"
+	"common/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	statsTopSenders = 10
+	statsMonths     = 12
+	statsRecent     = 30 * 24 * time.Hour
+)
+
+var sectionStyle = lipgloss.NewStyle().Bold(true)
+
+// statsKeys are the bindings of the stats view.
+var statsKeys = []ui.Key{
+	{Keys: "r", Help: "refresh"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+func statsFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(statsKeys))
+}
+
+// recordAction adds a to the action history the stats view reports. The
+// history is informational, so failing to write it is not reported. Spam
+// review keeps none, as rescuing and deleting spam are neither.
+func (m *AppModel) recordAction(a model.Action) {
+	if m.store == nil || m.opts.Spam || (a.Kind != "unsubscribe" && a.Messages == 0) {
+		return
+	}
+	gmail.RecordAction(context.Background(), m.store, a)
+}
+
+// openStats switches to the stats view and computes its figures.
+func (m *AppModel) openStats() (tea.Model, tea.Cmd) {
+	if m.store == nil {
+		return m, m.toasts.Push("Stats need a local store")
+	}
+	m.view = viewStats
+	m.stats = nil
+	m.statsViewport.SetContent("Computing stats...")
+	return m, m.statsCmd()
+}
+
+// statsCmd summarises the whole cache and the action history.
+func (m *AppModel) statsCmd() tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		msgs, err := m.store.LoadAllMessages(ctx)
+		if err != nil {
+			return statsLoadedMsg{err: err}
+		}
+		var actions []model.Action
+		if as, ok := m.store.(gmail.ActivityStore); ok {
+			if actions, err = as.LoadActions(ctx, time.Time{}); err != nil {
+				return statsLoadedMsg{err: err}
+			}
+		}
+		return statsLoadedMsg{stats: report.Summarize(msgs, actions, time.Now(), statsTopSenders, statsMonths, statsRecent)}
+	}
+}
+
+// statsAPIRows is how many Gmail API methods the stats view lists.
+const statsAPIRows = 8
+
+// renderStats draws the stats view: cache totals, the top senders and the
+// monthly volume as bars, what was archived, trashed and unsubscribed, and
+// the Gmail API quota this session used.
+func renderStats(s report.Stats, api gmail.QuotaUsage, width int) string {
+	var sb strings.Builder
+	sb.WriteString(headerStyle.Render("Mailbox stats"))
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "%s from %s, %d unread\n", plural(s.Messages, "message"), plural(s.Senders, "sender"), s.Unread)
+	fmt.Fprintf(&sb, "Unsubscribe links: %d%% of messages, %d of %s\n\n",
+		percent(s.WithUnsubscribe, s.Messages), s.SendersWithUnsubscribe, plural(s.Senders, "sender"))
+
+	sb.WriteString(sectionStyle.Render("Top senders"))
+	sb.WriteString("\n")
+	sb.WriteString(renderBars(s.TopSenders, width))
+	sb.WriteString("\n")
+	sb.WriteString(sectionStyle.Render("Messages per month"))
+	sb.WriteString("\n")
+	sb.WriteString(renderBars(s.Months, width))
+	sb.WriteString("\n")
+
+	rows := []struct {
+		label           string
+		recent, allTime int
+	}{
+		{"Archived messages", s.Recent.Archived, s.AllTime.Archived},
+		{"Trashed messages", s.Recent.Trashed, s.AllTime.Trashed},
+		{"Unsubscribed senders", s.Recent.Unsubscribed, s.AllTime.Unsubscribed},
+	}
+	fmt.Fprintf(&sb, "%s  %12s  %8s\n", sectionStyle.Render(fmt.Sprintf("%-20s", "Activity")), "last 30 days", "all time")
+	for _, r := range rows {
+		fmt.Fprintf(&sb, "%-20s  %12d  %8d\n", r.label, r.recent, r.allTime)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(renderAPIUsage(api))
+	return sb.String()
+}
+
+// renderAPIUsage lists the Gmail API methods called this session by the
+// quota units they cost, with the busiest second against the per-user
+// limit of 250 units a second.
+func renderAPIUsage(api gmail.QuotaUsage) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s  %8s  %8s\n", sectionStyle.Render(fmt.Sprintf("%-24s", "Gmail API this session")), "calls", "units")
+	if api.Calls == 0 {
+		sb.WriteString(doneStyle.Render("(none)") + "\n")
+		return sb.String()
+	}
+	for i, op := range api.Operations {
+		if i == statsAPIRows {
+			fmt.Fprintf(&sb, "%-24s\n", fmt.Sprintf("(%d more)", len(api.Operations)-i))
+			break
+		}
+		fmt.Fprintf(&sb, "%-24s  %8d  %8d\n", op.Operation, op.Calls, op.Units)
+	}
+	fmt.Fprintf(&sb, "%-24s  %8d  %8d\n", "Total", api.Calls, api.Units)
+	fmt.Fprintf(&sb, "Busiest second: %d of 250 units", api.PeakUnits)
+	if api.Throttled > 0 {
+		fmt.Fprintf(&sb, "; %s rate limited", plural(api.Throttled, "request"))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// renderBars draws one labelled bar per row, scaled to the largest count.
+func renderBars(rows []report.Row, width int) string {
+	if len(rows) == 0 {
+		return doneStyle.Render("(none)") + "\n"
+	}
+	labelW, maxN := 0, 1
+	for _, r := range rows {
+		labelW = max(labelW, len(r.Key))
+		maxN = max(maxN, r.Count)
+	}
+	labelW = min(labelW, max(width/3, 10))
+	countW := len(fmt.Sprint(maxN))
+	barW := max(width-labelW-countW-4, 10)
+
+	var sb strings.Builder
+	for _, r := range rows {
+		label := r.Key
+		if len(label) > labelW {
+			label = label[:labelW-1] + "…"
+		}
+		bar := strings.Repeat("█", r.Count*barW/maxN)
+		if r.Count > 0 && bar == "" {
+			bar = "▏"
+		}
+		fmt.Fprintf(&sb, "%-*s  %*d %s\n", labelW, label, countW, r.Count, barStyle.Render(bar))
+	}
+	return sb.String()
+}
+
+// percent returns n as a whole percentage of total.
+func percent(n, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return n * 100 / total
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// statusTickInterval is how often the status bar redraws, so the age of the
+// last sync stays current while no keys are pressed.
+const statusTickInterval = 30 * time.Second
+
+func statusTick() tea.Cmd {
+	return tea.Tick(statusTickInterval, func(time.Time) tea.Msg { return statusTickMsg{} })
+}
+
+// accountCmd looks up the address of the authenticated mailbox; the status
+// bar goes without it if that fails.
+func (m *AppModel) accountCmd() tea.Cmd {
+	p := m.mailbox
+	return func() tea.Msg {
+		email, err := p.Account(context.Background())
+		if err != nil {
+			return nil
+		}
+		return accountMsg(email)
+	}
+}
+
+// statusRight is the right side of the status bar: the account, the cached
+// and unread message counts, and the sync state, after dry-run and
+// read-only markers.
+// Compact terminals leave out the account.
+func (m *AppModel) statusRight(now time.Time) string {
+	var parts []string
+	if m.opts.DryRun {
+		parts = append(parts, failStyle.Render("dry run"))
+	}
+	if m.opts.ReadOnly {
+		parts = append(parts, failStyle.Render("read-only"))
+	}
+	if m.account != "" && m.layout != layoutCompact {
+		parts = append(parts, m.account)
+	}
+	if m.view != viewLoading && m.view != viewAuth {
+		total, unread := m.unloaded.Messages, m.unloaded.Unread
+		for _, g := range m.groups {
+			total += g.Count
+			unread += g.Unread
+		}
+		parts = append(parts, plural(total, "message"), fmt.Sprintf("%d unread", unread))
+	}
+	return strings.Join(append(parts, m.syncState(now)), " · ")
+}
+
+// syncState describes the running sync, or how long ago the last one
+// finished.
+func (m *AppModel) syncState(now time.Time) string {
+	switch {
+	case m.syncing || m.pushSyncing:
+		return "syncing"
+	case m.syncErr != nil:
+		return "sync failed"
+	case m.lastSync.IsZero():
+		return "not synced"
+	}
+	d := now.Sub(m.lastSync)
+	switch {
+	case d < time.Minute:
+		return "synced just now"
+	case d < time.Hour:
+		return fmt.Sprintf("synced %dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("synced %dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("synced %dd ago", int(d.Hours()/24))
+}
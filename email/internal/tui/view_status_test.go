@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"chuckterm/internal/model"
+)
+
+func TestSyncState(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		syncing  bool
+		pushing  bool
+		err      error
+		lastSync time.Time
+		want     string
+	}{
+		{"never", false, false, nil, time.Time{}, "not synced"},
+		{"running", true, false, nil, now.Add(-time.Hour), "syncing"},
+		{"push", false, true, errors.New("offline"), time.Time{}, "syncing"},
+		{"failed", false, false, errors.New("offline"), now.Add(-time.Minute), "sync failed"},
+		{"just now", false, false, nil, now.Add(-59 * time.Second), "synced just now"},
+		{"minutes", false, false, nil, now.Add(-time.Minute), "synced 1m ago"},
+		{"under an hour", false, false, nil, now.Add(-59*time.Minute - 59*time.Second), "synced 59m ago"},
+		{"hours", false, false, nil, now.Add(-time.Hour), "synced 1h ago"},
+		{"under a day", false, false, nil, now.Add(-23*time.Hour - 59*time.Minute), "synced 23h ago"},
+		{"days", false, false, nil, now.Add(-49 * time.Hour), "synced 2d ago"},
+	} {
+		m := AppModel{syncing: tc.syncing, pushSyncing: tc.pushing, syncErr: tc.err, lastSync: tc.lastSync}
+		if got := m.syncState(now); got != tc.want {
+			t.Errorf("%s: %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStatusRight(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	groups := []model.SenderGroup{{Count: 3, Unread: 1}, {Count: 1}}
+	for _, tc := range []struct {
+		name string
+		m    AppModel
+		want string
+	}{
+		{
+			name: "loading",
+			m:    AppModel{view: viewLoading, account: "me@x.example"},
+			want: "me@x.example · not synced",
+		},
+		{
+			name: "groups",
+			m:    AppModel{view: viewGroups, account: "me@x.example", groups: groups, lastSync: now.Add(-5 * time.Minute)},
+			want: "me@x.example · 4 messages · 1 unread · synced 5m ago",
+		},
+		{
+			name: "groups not yet paged in",
+			m:    AppModel{view: viewGroups, groups: groups, unloaded: model.GroupTotals{Messages: 96, Unread: 9}},
+			want: "100 messages · 10 unread · not synced",
+		},
+		{
+			name: "compact leaves out the account",
+			m:    AppModel{view: viewGroups, layout: layoutCompact, account: "me@x.example", groups: groups[1:]},
+			want: "1 message · 0 unread · not synced",
+		},
+		{
+			name: "dry run and read-only",
+			m:    AppModel{view: viewAuth, opts: Options{DryRun: true, ReadOnly: true}, syncing: true},
+			want: "dry run · read-only · syncing",
+		},
+	} {
+		if got := tc.m.statusRight(now); got != tc.want {
+			t.Errorf("%s: %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// rateWindow is how far back the sync meter looks to compute throughput, so
+// the rate and ETA follow the current speed rather than the average.
+const rateWindow = 15 * time.Second
+
+// phaseLabels name the SyncProgress phases in the status bar.
+var phaseLabels = map[string]string{
+	"fullscan-start": "Starting scan",
+	"listing":        "Listing",
+	"metadata":       "Fetching metadata",
+	"writing":        "Writing",
+	"fullscan-done":  "Finishing",
+	"history-start":  "Fetching changes",
+	"history":        "Applying changes",
+	"history-done":   "Finishing",
+}
+
+type rateSample struct {
+	at   time.Time
+	done int
+}
+
+// syncMeter turns SyncProgress reports into a progress bar with a rate and
+// an ETA. The zero value is idle.
+type syncMeter struct {
+	phase   string
+	done    int
+	total   int
+	samples []rateSample // within rateWindow of the newest
+	bar     progress.Model
+}
+
+func newSyncMeter() syncMeter {
+	return syncMeter{bar: progress.New(progress.WithSolidFill(string(ui.Accent)), progress.WithoutPercentage())}
+}
+
+// update records a report received at now.
+func (s *syncMeter) update(msg syncProgressMsg, now time.Time) {
+	if msg.done < s.done {
+		// A new sync started; its throughput starts from scratch.
+		s.samples = nil
+	}
+	s.phase, s.done, s.total = msg.phase, msg.done, msg.total
+	s.samples = append(s.samples, rateSample{at: now, done: msg.done})
+	for len(s.samples) > 2 && now.Sub(s.samples[0].at) > rateWindow {
+		s.samples = s.samples[1:]
+	}
+}
+
+// reset returns the meter to idle.
+func (s *syncMeter) reset() {
+	s.phase, s.done, s.total, s.samples = "", 0, 0, nil
+}
+
+func (s *syncMeter) active() bool { return s.phase != "" }
+
+// rate is the messages per second over the recent samples, or 0 when there
+// is too little to go on.
+func (s *syncMeter) rate() float64 {
+	if len(s.samples) < 2 {
+		return 0
+	}
+	first, last := s.samples[0], s.samples[len(s.samples)-1]
+	secs := last.at.Sub(first.at).Seconds()
+	if secs < 1 {
+		return 0
+	}
+	return float64(last.done-first.done) / secs
+}
+
+// View renders the meter in at most width columns: the phase, a bar when the
+// total is known, the counts, and the rate and ETA once they can be
+// estimated.
+func (s *syncMeter) View(width int) string {
+	label := phaseLabels[s.phase]
+	if label == "" {
+		label = "Syncing"
+	}
+	counts := fmt.Sprintf("%d messages", s.done)
+	if s.total > 0 {
+		counts = fmt.Sprintf("%d / %d", s.done, s.total)
+		if s.done > s.total {
+			// The total is Gmail's estimate and was too low.
+			counts = fmt.Sprintf("%d / ~%d", s.done, s.total)
+		}
+	}
+	stats := []string{counts}
+	if r := s.rate(); r > 0 {
+		stats = append(stats, fmt.Sprintf("%.0f/s", r))
+		if s.total > s.done {
+			eta := time.Duration(float64(s.total-s.done) / r * float64(time.Second))
+			stats = append(stats, "ETA "+eta.Round(time.Second).String())
+		}
+	}
+	text := strings.Join(stats, " · ")
+
+	barWidth := min(30, width-lipgloss.Width(label)-lipgloss.Width(text)-2)
+	if s.total == 0 || barWidth < 10 {
+		return label + "  " + text
+	}
+	s.bar.Width = barWidth
+	return label + " " + s.bar.ViewAs(min(float64(s.done)/float64(s.total), 1)) + " " + text
+}
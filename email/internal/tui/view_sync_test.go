@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestSyncMeterRate(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := newSyncMeter()
+	if s.active() {
+		t.Fatal("a new meter is active")
+	}
+	s.update(syncProgressMsg{phase: "metadata", done: 0, total: 1000}, start)
+	if s.rate() != 0 {
+		t.Errorf("rate from one sample = %v", s.rate())
+	}
+	s.update(syncProgressMsg{phase: "metadata", done: 5, total: 1000}, start.Add(500*time.Millisecond))
+	if s.rate() != 0 {
+		t.Errorf("rate over half a second = %v", s.rate())
+	}
+	s.update(syncProgressMsg{phase: "metadata", done: 100, total: 1000}, start.Add(10*time.Second))
+	if got := s.rate(); got != 10 {
+		t.Errorf("rate = %v; want 10", got)
+	}
+	// Samples older than rateWindow drop out, so the rate follows the
+	// current speed.
+	s.update(syncProgressMsg{phase: "writing", done: 500, total: 1000}, start.Add(30*time.Second))
+	if got := s.rate(); got != 20 {
+		t.Errorf("rate after a speed-up = %v; want 20", got)
+	}
+	// A new sync counts from zero again.
+	s.update(syncProgressMsg{phase: "history-start"}, start.Add(31*time.Second))
+	if len(s.samples) != 1 || s.rate() != 0 {
+		t.Errorf("after a restart: %d samples, rate %v", len(s.samples), s.rate())
+	}
+	s.reset()
+	if s.active() {
+		t.Error("active after reset")
+	}
+}
+
+func TestSyncMeterView(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		updates []syncProgressMsg // one second apart
+		width   int
+		want    []string
+		bar     bool
+	}{
+		{
+			name:    "unknown total",
+			updates: []syncProgressMsg{{phase: "history", done: 40}},
+			width:   100,
+			want:    []string{"Applying changes  40 messages"},
+		},
+		{
+			name:    "unknown phase",
+			updates: []syncProgressMsg{{phase: "reticulating", done: 1}},
+			width:   100,
+			want:    []string{"Syncing  1 messages"},
+		},
+		{
+			name:    "bar with rate and ETA",
+			updates: []syncProgressMsg{{phase: "metadata", total: 300}, {phase: "metadata", done: 50, total: 300}, {phase: "metadata", done: 100, total: 300}},
+			width:   100,
+			want:    []string{"Fetching metadata ", " 100 / 300 · 50/s · ETA 4s"},
+			bar:     true,
+		},
+		{
+			name:    "estimate passed",
+			updates: []syncProgressMsg{{phase: "writing", done: 120, total: 100}},
+			width:   100,
+			want:    []string{"Writing ", " 120 / ~100"},
+			bar:     true,
+		},
+		{
+			name:    "too narrow for a bar",
+			updates: []syncProgressMsg{{phase: "listing", done: 10, total: 300}},
+			width:   25,
+			want:    []string{"Listing  10 / 300"},
+		},
+	} {
+		s := newSyncMeter()
+		for i, u := range tc.updates {
+			s.update(u, start.Add(time.Duration(i)*time.Second))
+		}
+		got := s.View(tc.width)
+		for _, w := range tc.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: %q does not contain %q", tc.name, got, w)
+			}
+		}
+		if bar := strings.ContainsAny(got, "░█"); bar != tc.bar {
+			t.Errorf("%s: bar shown %v in %q", tc.name, bar, got)
+		}
+		if w := lipgloss.Width(got); w > tc.width {
+			t.Errorf("%s: %d columns wide; want at most %d", tc.name, w, tc.width)
+		}
+	}
+}
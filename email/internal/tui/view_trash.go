@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// trashItem shows one trashed message in the trash view.
+type trashItem struct {
+	model.Trashed
+}
+
+func (t trashItem) FilterValue() string { return t.From + " " + t.Subject }
+func (t trashItem) Title() string {
+	if strings.TrimSpace(t.Subject) == "" {
+		return "(no subject)"
+	}
+	return t.Subject
+}
+func (t trashItem) Description() string {
+	desc := t.From + " · " + trimDate(t.Date)
+	if !t.At.IsZero() {
+		desc += " · trashed " + t.At.Format("2006-01-02 15:04")
+	}
+	return desc
+}
+
+// trashKeys are the bindings of the trash view.
+var trashKeys = []ui.Key{
+	{Keys: "u", Help: "restore"},
+	{Keys: "U", Help: "restore all listed"},
+	{Keys: "tab", Help: "gmail's trash / trashed here"},
+	{Keys: "r", Help: "reload"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+func trashFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(trashKeys))
+}
+
+// openTrash switches to the trash view, listing the mail moved to the
+// trash through chuckterm.
+func (m *AppModel) openTrash() (tea.Model, tea.Cmd) {
+	if m.service == nil {
+		return m, m.toasts.Push("Restoring from the trash needs a Gmail account")
+	}
+	if _, ok := m.store.(gmail.TrashStore); !ok {
+		return m, m.toasts.Push("The trash view needs a local store")
+	}
+	m.view = viewTrash
+	m.trashLive = false
+	m.trashList.ResetFilter()
+	return m, m.trashListCmd()
+}
+
+// trashListCmd loads the trash log, or Gmail's trash when trashLive is set.
+func (m *AppModel) trashListCmd() tea.Cmd {
+	m.statusBar.Text = "Loading the trash..."
+	if !m.trashLive {
+		return func() tea.Msg {
+			msgs, err := gmail.LoadTrashed(context.Background(), m.store, time.Now())
+			return trashLoadedMsg{msgs: msgs, err: err}
+		}
+	}
+	return m.authGuard(func() tea.Msg {
+		msgs, err := gmail.ListTrash(m.actionContext(), m.service)
+		return trashLoadedMsg{msgs: msgs, live: true, err: err}
+	})
+}
+
+func (m *AppModel) trashLoaded(msg trashLoadedMsg) tea.Cmd {
+	if msg.live != m.trashLive {
+		return nil // the other list was asked for since
+	}
+	m.statusBar.Text = ""
+	if msg.err != nil {
+		return m.toasts.Push(fmt.Sprintf("Loading the trash failed: %v", msg.err))
+	}
+	items := make([]list.Item, len(msg.msgs))
+	for i, t := range msg.msgs {
+		items[i] = trashItem{t}
+	}
+	if msg.live {
+		m.trashList.Title = fmt.Sprintf("Gmail's trash (%d)", len(items))
+	} else {
+		m.trashList.Title = fmt.Sprintf("Trashed with chuckterm (%d)", len(items))
+	}
+	return m.trashList.SetItems(items)
+}
+
+// handleTrashKey handles the keys of the trash view.
+func (m *AppModel) handleTrashKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.trashList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.trashList, cmd = m.trashList.Update(msg)
+		return m, cmd
+	}
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "esc":
+		m.view = viewGroups
+		return m, nil
+	case "u":
+		if t, ok := m.trashList.SelectedItem().(trashItem); ok {
+			return m, m.untrashCmd([]string{t.ID})
+		}
+		return m, nil
+	case "U":
+		n := len(m.trashList.VisibleItems())
+		if n > 0 {
+			m.confirm.Ask(confirmRestoreTrash, fmt.Sprintf("Restore %s from the trash?", plural(n, "message")))
+		}
+		return m, nil
+	case "tab":
+		m.trashLive = !m.trashLive
+		m.trashList.ResetFilter()
+		m.trashList.SetItems(nil)
+		return m, m.trashListCmd()
+	case "r":
+		return m, m.trashListCmd()
+	}
+	var cmd tea.Cmd
+	m.trashList, cmd = m.trashList.Update(msg)
+	return m, cmd
+}
+
+// restoreListedTrash restores every message the trash view lists, as
+// narrowed by its filter.
+func (m *AppModel) restoreListedTrash() (tea.Model, tea.Cmd) {
+	var ids []string
+	for _, it := range m.trashList.VisibleItems() {
+		ids = append(ids, it.(trashItem).ID)
+	}
+	return m, m.untrashCmd(ids)
+}
+
+// untrashCmd takes ids out of the trash and reloads the groups they came
+// back to.
+func (m *AppModel) untrashCmd(ids []string) tea.Cmd {
+	m.statusBar.Text = "Restoring..."
+	window := m.groupsOffset
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		n, err := gmail.UntrashMessages(ctx, m.service, m.store, ids)
+		if errors.Is(err, gmail.ErrDryRun) {
+			return untrashedMsg{restored: len(ids), dryRun: true}
+		}
+		if n == 0 {
+			return untrashedMsg{err: err}
+		}
+		groups, gerr := m.loadGroups(ctx, window)
+		if gerr != nil {
+			return untrashedMsg{restored: n, err: errors.Join(err, gerr)}
+		}
+		return untrashedMsg{restored: n, groups: &groups, err: err}
+	})
+}
+
+func (m *AppModel) untrashed(msg untrashedMsg) tea.Cmd {
+	m.statusBar.Text = ""
+	if msg.dryRun {
+		return m.toasts.Push(m.skipped(fmt.Sprintf("restore %s from the trash", plural(msg.restored, "message"))))
+	}
+	var cmds []tea.Cmd
+	if msg.groups != nil {
+		cmds = append(cmds, m.replaceGroups(*msg.groups))
+	}
+	switch {
+	case msg.err != nil && msg.restored > 0:
+		cmds = append(cmds, m.toasts.Push(fmt.Sprintf("Restored %s, then failed: %v", plural(msg.restored, "message"), msg.err)))
+	case msg.err != nil:
+		cmds = append(cmds, m.toasts.Push(fmt.Sprintf("Restoring failed: %v", msg.err)))
+	default:
+		cmds = append(cmds, m.toasts.Push(fmt.Sprintf("Restored %s from the trash", plural(msg.restored, "message"))))
+	}
+	if m.view == viewTrash {
+		cmds = append(cmds, m.trashListCmd())
+	}
+	return tea.Batch(cmds...)
+}
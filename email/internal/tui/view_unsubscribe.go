@@ -0,0 +1,346 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"common/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle      = lipgloss.NewStyle().Foreground(ui.Good)
+	pendingStyle = lipgloss.NewStyle().Foreground(ui.Warn)
+	doneStyle    = lipgloss.NewStyle().Foreground(ui.Muted)
+	failStyle    = lipgloss.NewStyle().Foreground(ui.Bad)
+)
+
+// unsubState is how far a sender in the unsubscribe queue has got.
+type unsubState int
+
+const (
+	unsubWaiting unsubState = iota
+	unsubWorking
+	unsubDone
+	unsubFailed
+	unsubCancelled
+	unsubDryRun // skipped by a dry run
+)
+
+// queuedUnsub is one sender in the unsubscribe queue.
+type queuedUnsub struct {
+	group   model.SenderGroup
+	state   unsubState
+	outcome gmail.UnsubscribeOutcome
+	err     error
+}
+
+// unsubBrowserPause spaces out the browser tabs the queue opens, so a long
+// queue does not flood the browser.
+const unsubBrowserPause = time.Second
+
+// via names the method the queue used for q, or will try first.
+func (q queuedUnsub) via() gmail.UnsubscribeMethod {
+	switch {
+	case q.outcome.Method != "":
+		return q.outcome.Method
+	case q.group.UnsubscribeURL != "" && q.group.UnsubscribeOneClick:
+		return gmail.UnsubscribeOneClick
+	case q.group.UnsubscribeURL != "":
+		return gmail.UnsubscribeBrowser
+	}
+	return gmail.UnsubscribeMailto
+}
+
+// renderUnsubscribeQueue draws the per-sender status table of the
+// unsubscribe queue.
+func renderUnsubscribeQueue(queue []queuedUnsub) string {
+	senderW := len("Sender")
+	counts := map[unsubState]int{}
+	for _, q := range queue {
+		senderW = max(senderW, len(q.group.Email))
+		counts[q.state]++
+	}
+
+	var sb strings.Builder
+	sb.WriteString(headerStyle.Render(fmt.Sprintf("Unsubscribe queue: %s, %d done, %d failed, %d waiting",
+		plural(len(queue), "sender"), counts[unsubDone], counts[unsubFailed], counts[unsubWaiting]+counts[unsubWorking])))
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "%-*s  %-9s  %s\n", senderW, "Sender", "Via", "Status")
+
+	for _, q := range queue {
+		var status string
+		switch q.state {
+		case unsubWaiting:
+			status = pendingStyle.Render("waiting")
+		case unsubWorking:
+			status = pendingStyle.Render("working...")
+		case unsubCancelled:
+			status = doneStyle.Render("cancelled")
+		case unsubDryRun:
+			status = doneStyle.Render("skipped (dry run)")
+		case unsubFailed:
+			status = failStyle.Render(fmt.Sprintf("failed: %v", q.err))
+		case unsubDone:
+			switch q.outcome.Method {
+			case gmail.UnsubscribeOneClick:
+				status = okStyle.Render("unsubscribed")
+			case gmail.UnsubscribeMailto:
+				status = okStyle.Render("email sent")
+			default:
+				status = okStyle.Render("opened in browser")
+			}
+		}
+		if q.outcome.Err != nil {
+			status += doneStyle.Render(fmt.Sprintf("  (one-click failed: %v)", q.outcome.Err))
+		}
+		fmt.Fprintf(&sb, "%-*s  %-9s  %s\n", senderW, q.group.Email, q.via(), status)
+	}
+	return sb.String()
+}
+
+// queueUnsub adds g's sender to the unsubscribe queue, once per sender and
+// per link. It reports whether g was added.
+func (m *AppModel) queueUnsub(g model.SenderGroup) bool {
+	if !m.unsubRunning {
+		// Start afresh once the last run is over.
+		m.unsubQueue = slices.DeleteFunc(m.unsubQueue, func(q queuedUnsub) bool { return q.state != unsubWaiting })
+	}
+	for _, q := range m.unsubQueue {
+		same := q.group.Email == g.Email ||
+			(g.UnsubscribeURL != "" && q.group.UnsubscribeURL == g.UnsubscribeURL) ||
+			(g.UnsubscribeURL == "" && q.group.UnsubscribeURL == "" && q.group.UnsubscribeMailto == g.UnsubscribeMailto)
+		if same && q.state != unsubCancelled {
+			return false
+		}
+	}
+	m.unsubQueue = append(m.unsubQueue, queuedUnsub{group: g})
+	return true
+}
+
+// queuedSenders returns the senders waiting in the unsubscribe queue.
+func (m *AppModel) queuedSenders() map[string]bool {
+	queued := make(map[string]bool)
+	for _, q := range m.unsubQueue {
+		if q.state == unsubWaiting {
+			queued[q.group.Email] = true
+		}
+	}
+	return queued
+}
+
+// waitingUnsubs counts the senders waiting in the queue, and how many of
+// them will be sent an email.
+func (m *AppModel) waitingUnsubs() (waiting, emails int) {
+	for _, q := range m.unsubQueue {
+		if q.state == unsubWaiting {
+			waiting++
+			if q.group.UnsubscribeURL == "" {
+				emails++
+			}
+		}
+	}
+	return waiting, emails
+}
+
+// toggleQueuedSelectedGroup adds the highlighted sender to the unsubscribe
+// queue, or takes it out again while it is still waiting.
+func (m *AppModel) toggleQueuedSelectedGroup() (tea.Model, tea.Cmd) {
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	if !ok {
+		return m, nil
+	}
+	if gi.IsDomain() {
+		return m, m.toasts.Push(fmt.Sprintf("Unsubscribing works per sender; press %s to group by sender", m.opts.Keys.Domains))
+	}
+	if gi.UnsubscribeURL == "" && gi.UnsubscribeMailto == "" {
+		return m, m.toasts.Push("No unsubscribe link available for this group")
+	}
+	if i := slices.IndexFunc(m.unsubQueue, func(q queuedUnsub) bool {
+		return q.group.Email == gi.Email && q.state == unsubWaiting
+	}); i >= 0 {
+		m.unsubQueue = slices.Delete(m.unsubQueue, i, i+1)
+		m.refreshUnsubscribeQueue()
+		return m, m.toasts.Push(fmt.Sprintf("Removed %s from the unsubscribe queue", gi.DisplayName))
+	}
+	if !m.queueUnsub(gi.SenderGroup) {
+		return m, m.toasts.Push(fmt.Sprintf("%s is already in the unsubscribe queue", gi.DisplayName))
+	}
+	m.refreshUnsubscribeQueue()
+	waiting, _ := m.waitingUnsubs()
+	msg := fmt.Sprintf("Queued %s (%d waiting); press %s to start", gi.DisplayName, waiting, m.opts.Keys.UnsubscribeAll)
+	if m.unsubRunning {
+		msg = fmt.Sprintf("Queued %s", gi.DisplayName)
+	}
+	if u := gi.Unsubscribed; u != nil {
+		msg += fmt.Sprintf("; you unsubscribed on %s", u.Time.Format(time.DateOnly))
+	}
+	return m, m.toasts.Push(msg)
+}
+
+// showUnsubscribeQueue switches to the queue's status table.
+func (m *AppModel) showUnsubscribeQueue() {
+	m.reportViewport.SetContent(renderUnsubscribeQueue(m.unsubQueue))
+	m.reportViewport.GotoTop()
+	m.view = viewUnsubscribe
+}
+
+// refreshUnsubscribeQueue redraws the queue markers in the groups list and
+// the status table, keeping the highlight.
+func (m *AppModel) refreshUnsubscribeQueue() {
+	m.reportViewport.SetContent(renderUnsubscribeQueue(m.unsubQueue))
+	idx := m.groupsList.Index()
+	m.showGroups()
+	m.groupsList.Select(idx)
+}
+
+// nextUnsubscribeCmd starts the worker on the next waiting sender, or ends
+// the run when none is left. Senders are handled one at a time.
+func (m *AppModel) nextUnsubscribeCmd() tea.Cmd {
+	i := slices.IndexFunc(m.unsubQueue, func(q queuedUnsub) bool { return q.state == unsubWaiting })
+	if i < 0 {
+		m.unsubRunning = false
+		m.statusBar.Text = ""
+		counts := map[unsubState]int{}
+		for _, q := range m.unsubQueue {
+			counts[q.state]++
+		}
+		msg := fmt.Sprintf("Unsubscribe queue finished: %d done, %d failed", counts[unsubDone], counts[unsubFailed])
+		if n := counts[unsubCancelled]; n > 0 {
+			msg += fmt.Sprintf(", %d cancelled", n)
+		}
+		if n := counts[unsubDryRun]; n > 0 {
+			msg = m.skipped(fmt.Sprintf("unsubscribe from %s", plural(n, "sender")))
+		}
+		return m.toasts.Push(msg)
+	}
+	m.unsubQueue[i].state = unsubWorking
+	done := 0
+	for _, q := range m.unsubQueue {
+		if q.state != unsubWaiting && q.state != unsubWorking {
+			done++
+		}
+	}
+	m.statusBar.Text = fmt.Sprintf("Unsubscribing... %d / %d senders", done, len(m.unsubQueue))
+	m.reportViewport.SetContent(renderUnsubscribeQueue(m.unsubQueue))
+	g := m.unsubQueue[i].group
+	return m.authGuard(func() tea.Msg {
+		out, err := gmail.UnsubscribeGroup(m.actionContext(), m.service, g, gmail.OpenBrowser)
+		if err == nil {
+			m.recordUnsubscribe(g.Email, out.Method, out.URL)
+			if out.Method == gmail.UnsubscribeBrowser {
+				time.Sleep(unsubBrowserPause)
+			}
+		}
+		return unsubItemDoneMsg{sender: g.Email, outcome: out, err: err}
+	})
+}
+
+// finishQueuedUnsub stores the outcome of the sender the worker was on.
+func (m *AppModel) finishQueuedUnsub(msg unsubItemDoneMsg) {
+	i := slices.IndexFunc(m.unsubQueue, func(q queuedUnsub) bool {
+		return q.group.Email == msg.sender && q.state == unsubWorking
+	})
+	if i < 0 {
+		return
+	}
+	q := &m.unsubQueue[i]
+	q.outcome, q.err, q.state = msg.outcome, msg.err, unsubDone
+	switch {
+	case errors.Is(msg.err, gmail.ErrDryRun):
+		q.outcome.Err, q.state = nil, unsubDryRun
+	case msg.err != nil:
+		q.state = unsubFailed
+	}
+	m.reportViewport.SetContent(renderUnsubscribeQueue(m.unsubQueue))
+	if msg.err == nil {
+		m.refreshUnsubscribes()
+	}
+}
+
+// cancelUnsubscribeQueue drops the senders still waiting; the one being
+// worked on finishes.
+func (m *AppModel) cancelUnsubscribeQueue() (tea.Model, tea.Cmd) {
+	n := 0
+	for i := range m.unsubQueue {
+		if m.unsubQueue[i].state == unsubWaiting {
+			m.unsubQueue[i].state = unsubCancelled
+			n++
+		}
+	}
+	if n == 0 {
+		return m, m.toasts.Push("Nothing is waiting in the queue")
+	}
+	m.refreshUnsubscribeQueue()
+	return m, m.toasts.Push(fmt.Sprintf("Cancelled %s", plural(n, "waiting sender")))
+}
+
+// unsubscribeCmd unsubscribes from g by opening its HTTP link in the
+// browser, or by sending the email its mailto: link asks for.
+func (m *AppModel) unsubscribeCmd(g model.SenderGroup) tea.Cmd {
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		if g.UnsubscribeURL == "" {
+			if err := gmail.MailtoUnsubscribe(ctx, m.service, g.UnsubscribeMailto); errors.Is(err, gmail.ErrDryRun) {
+				return actionResultMsg{dryRun: "send an unsubscribe email to " + strings.TrimPrefix(g.UnsubscribeMailto, "mailto:")}
+			} else if err != nil {
+				return actionResultMsg{action: "Unsubscribe", err: err}
+			}
+			m.recordUnsubscribe(g.Email, gmail.UnsubscribeMailto, g.UnsubscribeMailto)
+			return actionResultMsg{action: "Unsubscribe (email sent)", unsubscribed: true}
+		}
+		if err := gmail.BrowseUnsubscribe(ctx, g.UnsubscribeURL); errors.Is(err, gmail.ErrDryRun) {
+			return actionResultMsg{dryRun: "open " + g.UnsubscribeURL}
+		} else if err != nil {
+			return actionResultMsg{action: "Unsubscribe", err: err}
+		}
+		m.recordUnsubscribe(g.Email, gmail.UnsubscribeBrowser, g.UnsubscribeURL)
+		return actionResultMsg{action: "Unsubscribe (opened browser)", unsubscribed: true}
+	})
+}
+
+// recordUnsubscribe notes an unsubscribe from sender in the store. Like
+// recordAction, failing to write it is not reported.
+func (m *AppModel) recordUnsubscribe(sender string, method gmail.UnsubscribeMethod, url string) {
+	if m.store == nil {
+		return
+	}
+	gmail.RecordUnsubscribe(context.Background(), m.store, sender, method, url)
+}
+
+// refreshUnsubscribes reloads the senders unsubscribed from and redraws
+// the groups with their checkmarks, keeping the highlight.
+func (m *AppModel) refreshUnsubscribes() {
+	if m.store == nil {
+		return
+	}
+	unsubs, err := gmail.LoadUnsubscribes(context.Background(), m.store)
+	if err != nil {
+		return
+	}
+	m.unsubs = unsubs
+	gi, ok := m.groupsList.SelectedItem().(groupItem)
+	m.showGroups()
+	if ok {
+		m.selectGroup(model.GroupKey{Email: gi.Email, Subject: gi.Subject})
+	}
+}
+
+// unsubscribeKeys are the bindings of the unsubscribe queue view.
+var unsubscribeKeys = []ui.Key{
+	{Keys: "c", Help: "cancel waiting"},
+	{Keys: "esc", Help: "back"},
+	{Keys: "q", Help: "quit"},
+}
+
+func unsubscribeFooter(style lipgloss.Style) string {
+	return style.Render(ui.Hints(unsubscribeKeys))
+}
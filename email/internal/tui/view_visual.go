@@ -0,0 +1,189 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+
+	"chuckterm/internal/gmail"
+	"chuckterm/internal/model"
+	"common/ui"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// visualDelegate draws the groups list, marking the rows between the
+// anchor of a visual selection and the cursor. Without a selection it draws
+// like its DefaultDelegate.
+type visualDelegate struct {
+	list.DefaultDelegate
+	visual bool
+	anchor int
+}
+
+func (d visualDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	lo, hi := visualSpan(d.anchor, m.Index())
+	if !d.visual || index == m.Index() || index < lo || index > hi {
+		d.DefaultDelegate.Render(w, m, index, item)
+		return
+	}
+	marked := d.DefaultDelegate
+	marked.Styles.NormalTitle = marked.Styles.SelectedTitle.Foreground(ui.Warn).BorderLeftForeground(ui.Warn)
+	marked.Styles.NormalDesc = marked.Styles.SelectedDesc.Foreground(ui.Warn).BorderLeftForeground(ui.Warn)
+	marked.Render(w, m, index, item)
+}
+
+// visualSpan orders the anchor and cursor of a selection.
+func visualSpan(anchor, cursor int) (lo, hi int) {
+	return min(anchor, cursor), max(anchor, cursor)
+}
+
+// groupsDelegate draws the groups list for the current layout and visual
+// selection.
+func (m *AppModel) groupsDelegate() list.ItemDelegate {
+	return visualDelegate{
+		DefaultDelegate: ui.NewDelegate(m.layout == layoutCompact),
+		visual:          m.visual,
+		anchor:          m.visualAnchor,
+	}
+}
+
+// setVisual starts a visual selection at the highlighted group, or ends the
+// one under way.
+func (m *AppModel) setVisual(on bool) {
+	m.visual = on
+	m.visualAnchor = m.groupsList.Index()
+	m.groupsList.SetDelegate(m.groupsDelegate())
+}
+
+// handleVimKey handles the keys the vim profile adds to the groups view,
+// reporting whether key was one of them.
+func (m *AppModel) handleVimKey(key string) (bool, tea.Cmd) {
+	pendingG := m.pendingG
+	m.pendingG = false
+	switch key {
+	case "g":
+		if !pendingG {
+			m.pendingG = true
+			return true, nil
+		}
+		m.groupsList.Select(0)
+	case "G":
+		// The last group may not be loaded yet.
+		if err := m.loadRemainingGroups(); err != nil {
+			return true, m.toasts.Push(fmt.Sprintf("Loading groups failed: %v", err))
+		}
+		m.groupsList.Select(max(len(m.groupsList.VisibleItems())-1, 0))
+	case "V":
+		m.setVisual(!m.visual)
+		return true, nil
+	case "esc":
+		if !m.visual {
+			return false, nil
+		}
+		m.setVisual(false)
+		return true, nil
+	default:
+		return false, nil
+	}
+	detail := m.refreshDetail()
+	if m.layout == layoutWide {
+		m.refreshPreview()
+	}
+	return true, detail
+}
+
+// visualGroups are the groups of the visual selection, in list order.
+func (m *AppModel) visualGroups() []model.SenderGroup {
+	items := m.groupsList.VisibleItems()
+	lo, hi := visualSpan(m.visualAnchor, m.groupsList.Index())
+	var groups []model.SenderGroup
+	for _, it := range items[min(lo, len(items)):min(hi+1, len(items))] {
+		if gi, ok := it.(groupItem); ok {
+			groups = append(groups, gi.SenderGroup)
+		}
+	}
+	return groups
+}
+
+// askTrashVisual trashes the selected groups, asking first when configured
+// to, when a protected sender is among them or when reviewing spam, which
+// deletes them for good.
+func (m *AppModel) askTrashVisual() (tea.Model, tea.Cmd) {
+	groups := m.visualGroups()
+	if len(groups) == 0 {
+		return m, nil
+	}
+	messages, protected := 0, 0
+	for _, g := range groups {
+		messages += g.Count
+		if g.Status == model.SenderProtected {
+			protected++
+		}
+	}
+	if !m.opts.Confirm.Trash && protected == 0 && !m.opts.Spam {
+		return m.trashVisualSelection()
+	}
+	verb := "Move to trash"
+	if m.opts.Spam {
+		verb = "Delete forever"
+	}
+	prompt := fmt.Sprintf("%s all %s from %s?", verb, plural(messages, "message"), plural(len(groups), "group"))
+	if protected > 0 {
+		prompt = fmt.Sprintf("%s from protected senders. %s", capitalize(plural(protected, "group")), prompt)
+	}
+	m.confirm.Ask(confirmTrashSelection, prompt)
+	return m, nil
+}
+
+// trashVisualSelection moves the mail of every selected group to the trash
+// and ends the selection.
+func (m *AppModel) trashVisualSelection() (tea.Model, tea.Cmd) {
+	groups := m.visualGroups()
+	lo, _ := visualSpan(m.visualAnchor, m.groupsList.Index())
+	m.setVisual(false)
+	if len(groups) == 0 {
+		return m, nil
+	}
+	if !m.changesNothing() {
+		for i := lo + len(groups) - 1; i >= lo; i-- {
+			m.groupsList.RemoveItem(i)
+		}
+		for _, g := range groups {
+			m.removeGroup(g)
+		}
+		m.groupsList.Select(min(lo, max(len(m.groupsList.Items())-1, 0)))
+	}
+	m.statusBar.Text = "Trashing..."
+	if m.opts.Spam {
+		m.statusBar.Text = "Deleting..."
+	}
+	return m, m.trashGroupsCmd(groups)
+}
+
+// trashGroupsCmd moves the mail of groups to the trash, one group after
+// another, stopping at the first failure.
+func (m *AppModel) trashGroupsCmd(groups []model.SenderGroup) tea.Cmd {
+	verb, action := "trash", "Trash"
+	if m.opts.Spam {
+		verb, action = "delete for good", "Delete"
+	}
+	return m.authGuard(func() tea.Msg {
+		ctx := m.actionContext()
+		total := 0
+		for _, g := range groups {
+			n, err := m.trashGroup(ctx, g)
+			total += n
+			if !gmail.IsDryRun(ctx) {
+				m.recordAction(model.Action{Kind: "trash", Sender: g.Email, Subject: g.Subject, Messages: n})
+			}
+			if err != nil {
+				return actionResultMsg{action: action, err: fmt.Errorf("%s: %w", g.DisplayName, err)}
+			}
+		}
+		if gmail.IsDryRun(ctx) {
+			return actionResultMsg{dryRun: fmt.Sprintf("%s %s from %s", verb, plural(total, "message"), plural(len(groups), "group"))}
+		}
+		return actionResultMsg{action: action}
+	})
+}
@@ -0,0 +1,151 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"chuckterm/internal/model"
+	"chuckterm/internal/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// groupsModel lists five groups in db, s1 with five messages down to s5
+// with one, in the groups view.
+func groupsModel(t *testing.T, db store.Store, opts Options) *AppModel {
+	t.Helper()
+	ctx := context.Background()
+	var msgs []model.MessageRef
+	for s := 1; s <= 5; s++ {
+		for i := s; i <= 5; i++ {
+			msgs = append(msgs, model.MessageRef{
+				ID:          fmt.Sprintf("s%d-%d", s, i),
+				From:        fmt.Sprintf("s%d@x.example", s),
+				Subject:     "News",
+				DateRFC3339: fmt.Sprintf("2024-01-%02dT00:00:00Z", i),
+			})
+		}
+	}
+	if err := db.UpsertMessages(ctx, msgs); err != nil {
+		t.Fatal(err)
+	}
+	m := NewAppModel(db, t.TempDir(), opts)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	set, err := m.loadGroups(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.replaceGroups(set)
+	m.view = viewGroups
+	return &m
+}
+
+// press sends each key to m as if typed.
+func press(m *AppModel, keys ...string) {
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		m.Update(msg)
+	}
+}
+
+func TestVimKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		keys     []string
+		want     int
+		pendingG bool
+	}{
+		{"g waits", []string{"g"}, 2, true},
+		{"gg", []string{"g", "g"}, 0, false},
+		{"g then another key", []string{"g", "down", "g"}, 3, true},
+		{"G", []string{"G"}, 4, false},
+	} {
+		m := groupsModel(t, store.NewMemoryStore(), Options{Keys: Keymap{Profile: ProfileVim}})
+		m.groupsList.Select(2)
+		press(m, tc.keys...)
+		if got := m.groupsList.Index(); got != tc.want || m.pendingG != tc.pendingG {
+			t.Errorf("%s: highlight %d, pending g %v; want %d, %v", tc.name, got, m.pendingG, tc.want, tc.pendingG)
+		}
+	}
+}
+
+func TestVimLastGroupLoadsRemainingPages(t *testing.T) {
+	db, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m := groupsModel(t, db, Options{Keys: Keymap{Profile: ProfileVim}, PageSize: 2})
+	if got := len(m.groupsList.Items()); got != 2 || !m.moreGroups() {
+		t.Fatalf("%d groups listed before G; want the first page of 2", got)
+	}
+	press(m, "G")
+	if got := len(m.groupsList.Items()); got != 5 || m.moreGroups() {
+		t.Errorf("%d groups listed after G; want 5", got)
+	}
+	if got := m.groupsList.Index(); got != 4 {
+		t.Errorf("highlight %d; want the last group", got)
+	}
+}
+
+func TestVisualSelection(t *testing.T) {
+	m := groupsModel(t, store.NewMemoryStore(), Options{Keys: Keymap{Profile: ProfileVim}})
+	m.groupsList.Select(3)
+	press(m, "V")
+	m.groupsList.Select(1)
+	var got []string
+	for _, g := range m.visualGroups() {
+		got = append(got, g.Email)
+	}
+	if fmt.Sprint(got) != "[s2@x.example s3@x.example s4@x.example]" {
+		t.Errorf("selected %q", got)
+	}
+	press(m, "esc")
+	if m.visual {
+		t.Error("esc did not end the selection")
+	}
+}
+
+func TestTrashVisualSelection(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   Options
+		ask    bool
+		listed int // groups left after trashing
+	}{
+		{"trash", Options{}, false, 2},
+		{"dry run", Options{DryRun: true}, false, 5},
+		{"confirm", Options{Confirm: Confirmations{Trash: true}}, true, 5},
+		{"spam", Options{Spam: true}, true, 5},
+	} {
+		tc.opts.Keys = Keymap{Profile: ProfileVim}
+		m := groupsModel(t, store.NewMemoryStore(), tc.opts)
+		m.groupsList.Select(1)
+		press(m, "V", "down", "down")
+		_, cmd := m.askTrashVisual()
+		if m.confirm.Active() != tc.ask {
+			t.Errorf("%s: asked %v; want %v", tc.name, m.confirm.Active(), tc.ask)
+		}
+		if !tc.ask && (cmd == nil || m.visual) {
+			t.Errorf("%s: trashing returned %v, selection on %v", tc.name, cmd, m.visual)
+		}
+		if got := len(m.groupsList.Items()); got != tc.listed {
+			t.Errorf("%s: %d groups listed; want %d", tc.name, got, tc.listed)
+		}
+		if !tc.ask && tc.listed == 2 {
+			if got := m.groupsList.Index(); got != 1 {
+				t.Errorf("%s: highlight %d; want 1", tc.name, got)
+			}
+		}
+	}
+}
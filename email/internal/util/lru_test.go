@@ -0,0 +1,30 @@
+package util
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v", v, ok)
+	}
+	// b is now the least recently used.
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	c.Add("a", 10)
+	if v, _ := c.Get("a"); v != 10 {
+		t.Errorf("Get(a) after update = %d", v)
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 || c.Len() != 2 {
+		t.Errorf("Get(c) = %d, %v; Len = %d", v, ok, c.Len())
+	}
+
+	off := NewLRU[string, int](0)
+	off.Add("a", 1)
+	if _, ok := off.Get("a"); ok || off.Len() != 0 {
+		t.Error("a disabled cache stored an entry")
+	}
+}
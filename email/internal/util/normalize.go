@@ -3,6 +3,8 @@ package util
 import (
 	"net/mail"
 	"strings"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 // NormalizeSender extracts and normalizes an email address from a From header.
@@ -47,4 +49,32 @@ func NormalizeSender(fromHeader string) string {
 	// by default to avoid over-grouping across providers. Keep dots as-is.
 
 	return local + "@" + domain
-}
\ No newline at end of file
+}
+
+// SenderName returns the display name of a From header, decoding RFC 2047
+// encoded words, or "" if the header carries only an address.
+func SenderName(fromHeader string) string {
+	if addr, err := mail.ParseAddress(fromHeader); err == nil {
+		return strings.TrimSpace(addr.Name)
+	}
+	// Unparseable headers often still have a usable `Name <addr>` shape.
+	if idx := strings.Index(fromHeader, "<"); idx > 0 {
+		return strings.Trim(strings.TrimSpace(fromHeader[:idx]), `"'`)
+	}
+	return ""
+}
+
+// SenderDomain returns the registrable domain of a normalized address, so
+// ship@email.amazon.com and no-reply@amazon.com both give "amazon.com".
+// Hosts without a known public suffix are returned whole.
+func SenderDomain(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return ""
+	}
+	host := email[at+1:]
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
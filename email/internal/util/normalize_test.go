@@ -21,4 +21,41 @@ func TestNormalizeSender_Basic(t *testing.T) {
 			t.Errorf("NormalizeSender(%q) = %q; want %q", tc.in, got, tc.want)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestSenderName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`Go Weekly <news@example.com>`, "Go Weekly"},
+		{`"Raman, Priya" <priya@example.com>`, "Raman, Priya"},
+		{`=?UTF-8?Q?Ren=C3=A9e?= <renee@example.com>`, "Renée"},
+		{`user@example.com`, ""},
+		{`Broken Name <not an address>`, "Broken Name"},
+		{``, ""},
+	}
+	for _, tc := range tests {
+		if got := SenderName(tc.in); got != tc.want {
+			t.Errorf("SenderName(%q) = %q; want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSenderDomain(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"no-reply@amazon.com", "amazon.com"},
+		{"ship@email.amazon.com", "amazon.com"},
+		{"news@mail.bbc.co.uk", "bbc.co.uk"},
+		{"root@localhost", "localhost"},
+		{"not-an-address", ""},
+	}
+	for _, tc := range tests {
+		if got := SenderDomain(tc.in); got != tc.want {
+			t.Errorf("SenderDomain(%q) = %q; want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Re:, Fwd:, and their German (AW:, WG:) and numbered (Re[2]:) forms.
+	replyPrefix = regexp.MustCompile(`(?i)^((re|fwd?|aw|wg)(\[\d+\])?\s*:\s*)+`)
+	// Bracketed ticket and issue numbers such as [#1234], [JIRA-56] or (#7);
+	// list tags without digits, like [golang-nuts], are kept.
+	ticketTag = regexp.MustCompile(`\[[^\]]*\d[^\]]*\]|\(#?\d+\)`)
+
+	month = `(jan(uary)?|feb(ruary)?|mar(ch)?|apr(il)?|may|june?|july?|aug(ust)?|sep(t(ember)?)?|oct(ober)?|nov(ember)?|dec(ember)?)\.?`
+	// Trailing parts that change from one issue of a mailing to the next.
+	trailingParts = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)` + month + `\s+\d{1,2}(st|nd|rd|th)?(,?\s+\d{4})?$`),
+		regexp.MustCompile(`(?i)\d{1,2}(st|nd|rd|th)?\s+` + month + `(,?\s+\d{4})?$`),
+		regexp.MustCompile(`(?i)` + month + `,?\s+\d{4}$`),
+		regexp.MustCompile(`\d{4}-\d{2}-\d{2}$`),
+		regexp.MustCompile(`\d{1,2}/\d{1,2}(/\d{2,4})?$`),
+		regexp.MustCompile(`(?i)(mon|tues|wednes|thurs|fri|satur|sun)day$`),
+		regexp.MustCompile(`(?i)(#|no\.\s*|issue\s+#?|vol(ume)?\.?\s+|edition\s+#?|episode\s+#?)\d+$`),
+	}
+	trailingSeparators = regexp.MustCompile(`[\s\-–—:|,·•]+$`)
+	spaces             = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeSubject reduces a subject to the part shared by every issue of a
+// recurring mail: reply and forward prefixes, bracketed ticket numbers, and
+// trailing dates and issue numbers are removed, so "Digest — March 3" and
+// "Digest — March 10" both become "Digest". A subject that would be left
+// empty is returned trimmed but otherwise unchanged.
+func NormalizeSubject(subject string) string {
+	s := replyPrefix.ReplaceAllString(strings.TrimSpace(subject), "")
+	s = ticketTag.ReplaceAllString(s, " ")
+	s = spaces.ReplaceAllString(strings.TrimSpace(s), " ")
+	for {
+		before := s
+		for _, re := range trailingParts {
+			s = re.ReplaceAllString(s, "")
+		}
+		s = trailingSeparators.ReplaceAllString(s, "")
+		if s == before {
+			break
+		}
+	}
+	if s == "" {
+		return strings.TrimSpace(subject)
+	}
+	return s
+}
+
+// SubjectSimilarity returns the Jaccard similarity of the character trigrams
+// of two subjects, ignoring case: 1 for equal subjects, 0 for subjects with
+// no trigram in common.
+func SubjectSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 && len(tb) == 0 {
+		return 1
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the set of three-rune windows of each word of s, with
+// the word padded by a space on both sides so short words still count.
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(strings.ToLower(s)) {
+		r := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,46 @@
+package util
+
+import "testing"
+
+func TestNormalizeSubject(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Digest — March 3", "Digest"},
+		{"Digest — March 10", "Digest"},
+		{"Re: Fwd: RE: Lunch plans", "Lunch plans"},
+		{"AW: Re[2]: Angebot", "Angebot"},
+		{"[#1234] Printer is on fire", "Printer is on fire"},
+		{"[golang-nuts] Generics question", "[golang-nuts] Generics question"},
+		{"Weekly Update - Monday, 3rd March 2025", "Weekly Update"},
+		{"Sales report 2024-03-01", "Sales report"},
+		{"Go Weekly Issue #512", "Go Weekly"},
+		{"This week in Rust #580", "This week in Rust"},
+		{"Newsletter Vol. 7 | Jan 2025", "Newsletter"},
+		{"Build failed (#42) on main", "Build failed on main"},
+		{"Top 10 deals", "Top 10 deals"},
+		{"March 3", "March 3"},
+		{"  ", ""},
+	}
+	for _, tc := range tests {
+		if got := NormalizeSubject(tc.in); got != tc.want {
+			t.Errorf("NormalizeSubject(%q) = %q; want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSubjectSimilarity(t *testing.T) {
+	if s := SubjectSimilarity("Weekly deals", "weekly DEALS"); s != 1 {
+		t.Errorf("equal subjects: %v", s)
+	}
+	if s := SubjectSimilarity("Your order has shipped", "Your orders have shipped"); s < 0.6 {
+		t.Errorf("near-identical subjects: %v", s)
+	}
+	if s := SubjectSimilarity("CI failed on main", "CI passed on main"); s >= 0.6 {
+		t.Errorf("different outcomes: %v", s)
+	}
+	if s := SubjectSimilarity("abc", "xyz"); s != 0 {
+		t.Errorf("disjoint subjects: %v", s)
+	}
+}
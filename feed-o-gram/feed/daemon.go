@@ -0,0 +1,93 @@
+package feed
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"common/notify"
+	"common/scheduler"
+	"common/xdg"
+)
+
+// daemon implements `daemon [--every 30m] [--after 4h] [--install]`: it keeps
+// checking the log and reminds once per overdue meal, instead of relying on
+// cron to run "remind".
+func daemon(args []string, file string) int {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	every := fs.String("every", "15m", "how often to check: a duration, @hourly or \"@every 30m\"")
+	after := fs.Duration("after", DefaultRemindAfter, "remind when the last meal is older than this")
+	install := fs.Bool("install", false, "write a systemd user service (Linux) or launchd agent (macOS) running this command, then exit")
+	fs.Parse(args)
+
+	interval, err := scheduler.ParseEvery(*every)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return 2
+	}
+	if *install {
+		return installUnit(scheduler.Unit{Name: "feed-o-gram-remind", Description: "feed-o-gram meal reminders"})
+	}
+
+	state, err := xdg.StateHome()
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	n := notify.New("feed-o-gram")
+	var reminded time.Time // the meal already reminded about
+	s := &scheduler.Scheduler{
+		StateFile: filepath.Join(state, "feed-o-gram", "schedule.json"),
+		Logf: func(format string, args ...any) {
+			fmt.Printf("%s %s\n", time.Now().Format(time.DateTime), fmt.Sprintf(format, args...))
+		},
+		Jobs: []scheduler.Job{{
+			Name:  "remind",
+			Every: interval,
+			Run: func(ctx context.Context) error {
+				last, due, err := mealOverdue(file, *after)
+				if err != nil || !due || last.Equal(reminded) {
+					return err
+				}
+				reminded = last
+				return remind(ctx, n, last)
+			},
+		}},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := s.Run(ctx); err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	return 0
+}
+
+// installUnit writes a service file running the current command line
+// (without --install) from the current directory, so a relative --file
+// still resolves.
+func installUnit(u scheduler.Unit) int {
+	cmd, err := scheduler.CurrentCommand()
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	u.Command = cmd
+	if u.Dir, err = os.Getwd(); err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	path, enable, err := scheduler.Install(u)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\nEnable it with:\n  %s\n", path, enable)
+	return 0
+}
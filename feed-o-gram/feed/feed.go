@@ -0,0 +1,290 @@
+// Package feed implements the feed-o-gram meal logger: entries are appended
+// to a CSV file (or a SQLite database, see migrate) and viewed as one
+// timeline bar per day.
+package feed
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"common/config"
+	"common/notify"
+	"common/usage"
+)
+
+type Log struct {
+	LogType     string
+	Date        string
+	Time        string
+	Description *string
+}
+
+func (l Log) ToSlice() []string {
+	slice := []string{l.LogType, l.Date, l.Time}
+	if l.Description != nil {
+		slice = append(slice, *l.Description)
+	}
+	return slice
+}
+
+const mealIcon = "||"
+
+// DefaultDataFile is the CSV the standalone binary reads and writes, relative
+// to the working directory.
+const DefaultDataFile = "feed-o-gram.csv"
+
+// Settings are the user-tunable options, read from a TOML config file with
+// environment overrides.
+type Settings struct {
+	DataFile string `toml:"data_file" env:"FEED_O_GRAM_FILE"`
+	// UsageStats turns on local usage counting (see "stats --usage").
+	UsageStats bool `toml:"usage_stats" env:"FEED_O_GRAM_USAGE_STATS"`
+}
+
+// LoadSettings reads settings from the TOML file at path (which may be
+// missing), defaulting the data file to dataFile.
+func LoadSettings(path, dataFile string) (Settings, error) {
+	s := Settings{DataFile: dataFile}
+	err := config.Load(path, &s)
+	return s, err
+}
+
+// Main runs the logger with the given arguments (without the program name)
+// and returns the process exit code. settings.DataFile is used unless
+// overridden with --file.
+func Main(args []string, settings Settings) int {
+	fs := flag.NewFlagSet("feed", flag.ExitOnError)
+	file := fs.String("file", settings.DataFile, "CSV file (or .db SQLite database) holding the log")
+	fs.Parse(args)
+
+	rawEntry := fs.Args()
+
+	if len(rawEntry) == 0 {
+		fmt.Println("Error: No input provided")
+		return 2
+	}
+	if rawEntry[0] == "stats" {
+		return usage.Command("feed-o-gram", settings.UsageStats, rawEntry[1:], os.Stdout)
+	}
+	if c, err := usage.New("feed-o-gram", settings.UsageStats); err == nil {
+		c.Add("command: " + rawEntry[0])
+		defer c.Flush()
+	}
+
+	// parse into Input
+	log := Log{}
+
+	switch rawEntry[0] {
+	case "meal":
+		log.LogType = "meal"
+		log.Date = time.Now().Format("2006-01-02")
+
+		// TODO: should be able to parse time like 2pm, human friendly time
+		if len(rawEntry) < 2 {
+			log.Time = time.Now().Format("15:04")
+		} else {
+			log.Time = rawEntry[1]
+		}
+		if len(rawEntry) > 2 && rawEntry[2] != "" {
+			log.Description = &rawEntry[2]
+		}
+		err := Write(*file, log)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			return 1
+		}
+	case "view":
+		logs, err := Read(*file)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			return 1
+		}
+		// create map with log entries for each date and send to PrintFeedogram
+		dateTimes := make(map[string][]string)
+		for _, log := range logs {
+			if log.LogType == "meal" {
+				if _, ok := dateTimes[log.Date]; !ok {
+					dateTimes[log.Date] = make([]string, 0)
+				}
+				dateTimes[log.Date] = append(dateTimes[log.Date], log.Time)
+			}
+		}
+		for date, times := range dateTimes {
+			fmt.Println(PrintFeedogram(date, times))
+		}
+
+	case "remind":
+		after := DefaultRemindAfter
+		if len(rawEntry) > 1 {
+			d, err := time.ParseDuration(rawEntry[1])
+			if err != nil {
+				fmt.Println("Error: ", err)
+				return 2
+			}
+			after = d
+		}
+		last, due, err := mealOverdue(*file, after)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			return 1
+		}
+		if last.IsZero() {
+			fmt.Println("No meals logged yet")
+			return 0
+		}
+		if !due {
+			return 0
+		}
+		if err := remind(context.Background(), notify.New("feed-o-gram"), last); err != nil {
+			fmt.Println("Error: ", err)
+			return 1
+		}
+
+	case "daemon":
+		return daemon(rawEntry[1:], *file)
+
+	case "migrate":
+		return migrate(rawEntry[1:], *file)
+
+	default:
+		fmt.Println("Error: Invalid input")
+		return 2
+	}
+	return 0
+}
+
+// DefaultRemindAfter is how long after the last meal "remind" speaks up.
+const DefaultRemindAfter = 4 * time.Hour
+
+// mealOverdue returns when the last meal in file was eaten (zero if none is
+// logged) and whether that was more than after ago.
+func mealOverdue(file string, after time.Duration) (time.Time, bool, error) {
+	logs, err := Read(file)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	last, ok := LastMeal(logs)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	return last, time.Since(last) >= after, nil
+}
+
+// remind prints and shows the "time to eat" notification for a meal at last.
+func remind(ctx context.Context, n notify.Notifier, last time.Time) error {
+	body := fmt.Sprintf("Last meal was %s ago", time.Since(last).Round(time.Minute))
+	fmt.Println(body)
+	return n.Notify(ctx, "Time to eat", body)
+}
+
+// LastMeal returns when the most recent meal in logs was eaten, in local time.
+func LastMeal(logs []Log) (time.Time, bool) {
+	var last time.Time
+	for _, l := range logs {
+		if l.LogType != "meal" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04", l.Date+" "+l.Time, time.Local)
+		if err != nil {
+			continue
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// Read returns every entry in the data file at path, which is a SQLite
+// database if its extension says so and CSV otherwise.
+func Read(path string) ([]Log, error) {
+	if isSQLite(path) {
+		return readSQLite(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening data file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	// Entries without a description have three fields, the rest four.
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading data file: %v", err)
+	}
+
+	logs := make([]Log, 0, len(records))
+	for _, record := range records {
+		if len(record) < 3 {
+			continue
+		}
+		log := Log{
+			LogType: record[0],
+			Date:    record[1],
+			Time:    record[2],
+		}
+		if len(record) > 3 {
+			log.Description = &record[3]
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, nil
+}
+
+// Write appends log to the data file at path, creating it if needed.
+func Write(path string, log Log) error {
+	if isSQLite(path) {
+		return writeSQLite(path, log)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening data file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write(log.ToSlice())
+	if err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return nil
+}
+
+func PrintFeedogram(date string, times []string) string {
+	// TODO adjust to current terminal width
+	lineLength := 80
+	mealsMinutes := make([]int, 0)
+	for _, timeOfDay := range times {
+		hoursStr, minutesStr := strings.Split(timeOfDay, ":")[0], strings.Split(timeOfDay, ":")[1]
+		hours, err := strconv.Atoi(hoursStr)
+		if err != nil {
+			fmt.Println("Error: ", err)
+		}
+		minutes, err := strconv.Atoi(minutesStr)
+		if err != nil {
+			fmt.Println("Error: ", err)
+		}
+		minutesFromDayStart := hours*60 + minutes
+		mealsMinutes = append(mealsMinutes, minutesFromDayStart)
+	}
+	result := strings.Repeat("-", lineLength-len(mealIcon)*2*len(mealsMinutes))
+	minutesInDay := 24 * 60
+	for _, mealMinutes := range mealsMinutes {
+		// add mealIcon in result based on mealMinutes/minutesInDay * lineLength
+		mealPosition := int(float64(mealMinutes) / float64(minutesInDay) * float64(lineLength))
+		result = result[:mealPosition] + mealIcon + result[mealPosition:]
+	}
+
+	return date + " " + result
+}
@@ -0,0 +1,178 @@
+package feed
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// migrate implements `migrate --from csv [--csv FILE] [--to FILE.db]`: it
+// copies a CSV log into a SQLite database, validating every row on the way.
+// The CSV file is only read, so it stays behind as a backup.
+func migrate(args []string, file string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "format to import from; only csv is supported")
+	src := fs.String("csv", "", "CSV file to import (default: the data file if it is CSV, else "+DefaultDataFile+")")
+	dst := fs.String("to", "", "SQLite database to create (default: the CSV path with a .db extension)")
+	fs.Parse(args)
+
+	if *from != "csv" {
+		fmt.Println("Error: migrate needs --from csv")
+		return 2
+	}
+	if *src == "" {
+		*src = file
+		if isSQLite(file) {
+			*src = DefaultDataFile
+		}
+	}
+	if *dst == "" {
+		*dst = strings.TrimSuffix(*src, filepath.Ext(*src)) + ".db"
+	}
+	if !isSQLite(*dst) {
+		fmt.Println("Error: --to must end in .db, .sqlite or .sqlite3")
+		return 2
+	}
+
+	n, err := countSQLite(*dst)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	if n > 0 {
+		fmt.Printf("Error: %s already holds %d entries; not importing twice\n", *dst, n)
+		return 1
+	}
+
+	f, err := os.Open(*src)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	logs, repaired, skipped := importCSV(f)
+	f.Close()
+
+	if err := writeSQLite(*dst, logs...); err != nil {
+		fmt.Println("Error: ", err)
+		return 1
+	}
+	fmt.Printf("Imported %d entries from %s into %s\n", len(logs), *src, *dst)
+	if len(repaired) > 0 {
+		fmt.Printf("Repaired %d:\n", len(repaired))
+		for _, r := range repaired {
+			fmt.Println("  " + r)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped %d:\n", len(skipped))
+		for _, s := range skipped {
+			fmt.Println("  " + s)
+		}
+	}
+	fmt.Printf("%s was left untouched as a backup. To use the database, set\n  data_file = %q\nin config.toml or pass --file %s.\n", *src, *dst, *dst)
+	return 0
+}
+
+// importCSV reads every row of a CSV log, repairing what can be repaired
+// unambiguously and skipping the rest. It returns the valid entries plus one
+// line-numbered note per repaired and per skipped row.
+func importCSV(r io.Reader) (logs []Log, repaired, skipped []string) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	seen := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				skipped = append(skipped, fmt.Sprintf("line %d: %v", pe.StartLine, pe.Err))
+				continue
+			}
+			skipped = append(skipped, fmt.Sprintf("rest of file: %v", err))
+			break
+		}
+		line, _ := reader.FieldPos(0)
+
+		log, fixes, err := validRow(record)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		key := strings.Join(log.ToSlice(), "\x00")
+		if seen[key] {
+			skipped = append(skipped, fmt.Sprintf("line %d: duplicate of an earlier entry", line))
+			continue
+		}
+		seen[key] = true
+		if len(fixes) > 0 {
+			repaired = append(repaired, fmt.Sprintf("line %d: %s", line, strings.Join(fixes, ", ")))
+		}
+		logs = append(logs, log)
+	}
+	return logs, repaired, skipped
+}
+
+// validRow turns one CSV record into a Log, describing each repair made.
+func validRow(record []string) (Log, []string, error) {
+	if len(record) < 3 || len(record) > 4 {
+		return Log{}, nil, fmt.Errorf("%d fields, want type,date,time[,description]", len(record))
+	}
+	var fixes []string
+	trimmed := false
+	for i, field := range record {
+		record[i] = strings.TrimSpace(field)
+		trimmed = trimmed || record[i] != field
+	}
+	if trimmed {
+		fixes = append(fixes, "trimmed whitespace")
+	}
+
+	log := Log{LogType: strings.ToLower(record[0]), Date: record[1], Time: record[2]}
+	if log.LogType != record[0] {
+		fixes = append(fixes, fmt.Sprintf("type %q → %q", record[0], log.LogType))
+	}
+	if log.LogType != "meal" {
+		return Log{}, nil, fmt.Errorf("unknown entry type %q", record[0])
+	}
+	if _, err := time.Parse("2006-01-02", log.Date); err != nil {
+		return Log{}, nil, fmt.Errorf("invalid date %q", log.Date)
+	}
+	t, err := parseClock(log.Time)
+	if err != nil {
+		return Log{}, nil, fmt.Errorf("invalid time %q", log.Time)
+	}
+	if fixed := t.Format("15:04"); fixed != log.Time {
+		fixes = append(fixes, fmt.Sprintf("time %q → %q", log.Time, fixed))
+		log.Time = fixed
+	}
+	if len(record) == 4 {
+		if record[3] != "" {
+			log.Description = &record[3]
+		} else {
+			fixes = append(fixes, "dropped empty description")
+		}
+	}
+	return log, fixes, nil
+}
+
+// parseClock accepts the HH:MM the logger writes plus the H:MM and
+// HH:MM:SS forms hand edits tend to produce.
+func parseClock(s string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,119 @@
+package feed
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		csv      string
+		logs     []string // each entry's fields joined by |
+		repaired []string
+		skipped  []string
+	}{
+		{
+			name: "clean",
+			csv:  "meal,2024-03-01,08:00\nmeal,2024-03-01,12:30,oats\n",
+			logs: []string{"meal|2024-03-01|08:00", "meal|2024-03-01|12:30|oats"},
+		},
+		{
+			name:     "repairs",
+			csv:      " MEAL ,2024-03-01,8:05\nmeal,2024-03-01,12:30:59,\n",
+			logs:     []string{"meal|2024-03-01|08:05", "meal|2024-03-01|12:30"},
+			repaired: []string{`line 1: trimmed whitespace, type "MEAL" → "meal", time "8:05" → "08:05"`, `line 2: time "12:30:59" → "12:30", dropped empty description`},
+		},
+		{
+			name: "malformed rows",
+			csv:  "meal,2024-03-01\nmeal,2024-03-01,08:00,a,b\nnap,2024-03-01,09:00\nmeal,2024-02-30,10:00\nmeal,2024-03-01,25:00\nmeal,2024-03-01,11:00\n",
+			logs: []string{"meal|2024-03-01|11:00"},
+			skipped: []string{
+				"line 1: 2 fields, want type,date,time[,description]",
+				"line 2: 5 fields, want type,date,time[,description]",
+				`line 3: unknown entry type "nap"`,
+				`line 4: invalid date "2024-02-30"`,
+				`line 5: invalid time "25:00"`,
+			},
+		},
+		{
+			name:    "bad quoting",
+			csv:     "meal,2024-03-01,08:00\nmeal,2024-03-01,\"09:00\n",
+			logs:    []string{"meal|2024-03-01|08:00"},
+			skipped: []string{"line 2: extraneous or missing \" in quoted-field"},
+		},
+		{
+			name:    "duplicates",
+			csv:     "meal,2024-03-01,08:00,oats\nmeal,2024-03-01,8:00,oats\nmeal,2024-03-01,08:00\n",
+			logs:    []string{"meal|2024-03-01|08:00|oats", "meal|2024-03-01|08:00"},
+			skipped: []string{"line 2: duplicate of an earlier entry"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, repaired, skipped := importCSV(strings.NewReader(tc.csv))
+			var got []string
+			for _, l := range logs {
+				got = append(got, strings.Join(l.ToSlice(), "|"))
+			}
+			if !reflect.DeepEqual(got, tc.logs) {
+				t.Errorf("logs = %q; want %q", got, tc.logs)
+			}
+			if !reflect.DeepEqual(repaired, tc.repaired) {
+				t.Errorf("repaired = %q; want %q", repaired, tc.repaired)
+			}
+			if !reflect.DeepEqual(skipped, tc.skipped) {
+				t.Errorf("skipped = %q; want %q", skipped, tc.skipped)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "log.csv")
+	csv := "meal,2024-03-01,08:00\nmeal,2024-03-01,12:30,oats\nbogus\n"
+	if err := os.WriteFile(src, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "log.db")
+
+	if code := migrate([]string{"--from", "csv", "--csv", src}, src); code != 0 {
+		t.Fatalf("migrate exited %d", code)
+	}
+	logs, err := readSQLite(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Errorf("database holds %d entries; want 2", len(logs))
+	}
+	if b, err := os.ReadFile(src); err != nil || string(b) != csv {
+		t.Errorf("CSV changed: %q, %v", b, err)
+	}
+
+	// Running it again must not import the log twice.
+	if code := migrate([]string{"--from", "csv", "--csv", src, "--to", dst}, src); code != 1 {
+		t.Errorf("second migrate exited %d; want 1", code)
+	}
+	if n, err := countSQLite(dst); err != nil || n != 2 {
+		t.Errorf("after the second migrate: %d entries, %v", n, err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no --from", []string{"--csv", src}, 2},
+		{"other format", []string{"--from", "json", "--csv", src}, 2},
+		{"not a database", []string{"--from", "csv", "--csv", src, "--to", filepath.Join(dir, "log.txt")}, 2},
+		{"missing CSV", []string{"--from", "csv", "--csv", filepath.Join(dir, "none.csv")}, 1},
+	} {
+		if code := migrate(tc.args, src); code != tc.want {
+			t.Errorf("%s: exited %d; want %d", tc.name, code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package feed
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// isSQLite reports whether path names a SQLite log rather than a CSV one;
+// the backend is chosen by extension.
+func isSQLite(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return true
+	}
+	return false
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS logs (
+	id          INTEGER PRIMARY KEY,
+	type        TEXT NOT NULL,
+	date        TEXT NOT NULL,
+	time        TEXT NOT NULL,
+	description TEXT
+);`
+
+func openDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening data file: %v", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening data file: %v", err)
+	}
+	return db, nil
+}
+
+// readSQLite returns the logs in the order they were written.
+func readSQLite(path string) ([]Log, error) {
+	// Reading must not create an empty database where none was expected.
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("error opening data file: %v", err)
+	}
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT type, date, time, description FROM logs ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error reading data file: %v", err)
+	}
+	defer rows.Close()
+	var logs []Log
+	for rows.Next() {
+		var l Log
+		var desc sql.NullString
+		if err := rows.Scan(&l.LogType, &l.Date, &l.Time, &desc); err != nil {
+			return nil, fmt.Errorf("error reading data file: %v", err)
+		}
+		if desc.Valid {
+			l.Description = &desc.String
+		}
+		logs = append(logs, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading data file: %v", err)
+	}
+	return logs, nil
+}
+
+// writeSQLite appends logs in one transaction.
+func writeSQLite(path string, logs ...Log) error {
+	db, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	defer tx.Rollback()
+	for _, l := range logs {
+		if _, err := tx.Exec("INSERT INTO logs (type, date, time, description) VALUES (?, ?, ?, ?)",
+			l.LogType, l.Date, l.Time, l.Description); err != nil {
+			return fmt.Errorf("error writing to file: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return nil
+}
+
+// countSQLite returns how many logs the database at path holds; a missing
+// file holds none.
+func countSQLite(path string) (int, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return 0, nil
+	}
+	db, err := openDB(path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	var n int
+	err = db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&n)
+	return n, err
+}
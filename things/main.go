@@ -0,0 +1,135 @@
+// Command things bundles the repository's tools into one binary:
+//
+//	things mail [flags]          the chuckterm Gmail TUI and its subcommands
+//	things feed meal|view ...    the feed-o-gram meal logger
+//	things version               print the build version
+//	things self-update           replace this binary with the latest release
+//
+// Global flags (before the command) configure logging shared by every tool.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"chuckterm/cli"
+	"common/buildinfo"
+	"common/selfupdate"
+	"common/xdg"
+	"niraj.fyi/log/feed"
+)
+
+// Set at release time with -ldflags "-X main.version=v1.2.3
+// -X main.updateKey=<base64 ed25519 public key>". Without a version it is
+// derived from the module build info.
+var version, updateKey string
+
+type command struct {
+	name    string
+	aliases []string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{name: "mail", aliases: []string{"chuckterm"}, summary: "Gmail inbox manager (TUI, backup, restore, import, daemon, contacts)", run: cli.Main},
+	{name: "feed", aliases: []string{"log"}, summary: "feed-o-gram meal logger (meal, view, remind, daemon, migrate)", run: runFeed},
+	{name: "version", summary: "print the build version", run: func([]string) int {
+		fmt.Println(buildinfo.Version(version))
+		return 0
+	}},
+	{name: "self-update", summary: "install the latest release from GitHub (--check to only look)", run: func(args []string) int {
+		u := selfupdate.Updater{Binary: "things", Current: buildinfo.Version(version), PublicKey: updateKey}
+		return u.Run(args)
+	}},
+}
+
+func main() {
+	fs := flag.NewFlagSet("things", flag.ExitOnError)
+	logFile := fs.String("log-file", "", "append structured logs from every tool to this file")
+	showVersion := fs.Bool("version", false, "print the build version and exit")
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(os.Args[1:])
+
+	if *showVersion {
+		fmt.Println(buildinfo.Version(version))
+		return
+	}
+	if fs.NArg() == 0 {
+		usage(fs)
+		os.Exit(2)
+	}
+
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot open log file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		slog.SetDefault(slog.New(slog.NewTextHandler(f, nil)).With("version", buildinfo.Version(version)))
+	}
+
+	name, args := fs.Arg(0), fs.Args()[1:]
+	cmd, ok := lookup(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "things: unknown command %q\n\n", name)
+		usage(fs)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	slog.Info("command start", "command", cmd.name, "args", args)
+	code := cmd.run(args)
+	slog.Info("command exit", "command", cmd.name, "code", code, "duration", time.Since(start))
+	os.Exit(code)
+}
+
+func lookup(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+		for _, a := range c.aliases {
+			if a == name {
+				return c, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// runFeed keeps the meal log in the shared things config directory instead
+// of the working directory the standalone binary uses.
+func runFeed(args []string) int {
+	dir, err := xdg.ConfigDir("things")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot determine config directory: %v\n", err)
+		return 1
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot create config directory: %v\n", err)
+		return 1
+	}
+	settings, err := feed.LoadSettings(filepath.Join(dir, "feed.toml"), filepath.Join(dir, feed.DefaultDataFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load config: %v\n", err)
+		return 1
+	}
+	return feed.Main(args, settings)
+}
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage: things [flags] <command> [args]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	fs.PrintDefaults()
+}